@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/audit"
+	"github.com/charliek/shed/internal/config"
+)
+
+// auditPollInterval is how often audit tail --follow checks for new entries.
+const auditPollInterval = time.Second
+
+var (
+	auditTailLines  int
+	auditTailFollow bool
+	auditTailJSON   bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log",
+	Long: `Inspect the log of actions taken through the HTTP API and SSH server.
+
+Each entry records who acted (the API token or SSH key name and
+fingerprint), from which address, what they did, and when. The log is
+kept in audit.jsonl in the server's state dir and is only ever appended to.`,
+}
+
+var auditTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Show the most recent audit log entries",
+	Args:  cobra.NoArgs,
+	RunE:  runAuditTail,
+}
+
+func init() {
+	auditTailCmd.Flags().IntVarP(&auditTailLines, "lines", "n", 20, "number of entries to show")
+	auditTailCmd.Flags().BoolVarP(&auditTailFollow, "follow", "f", false, "keep printing new entries as they are recorded")
+	auditTailCmd.Flags().BoolVar(&auditTailJSON, "json", false, "print entries as JSON lines")
+	auditCmd.AddCommand(auditTailCmd)
+}
+
+func runAuditTail(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	log := audit.Open(cfg.StateDir)
+
+	if auditTailLines < 1 {
+		return fmt.Errorf("--lines must be at least 1")
+	}
+	entries, err := log.Tail(auditTailLines, nil)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		printAuditEntry(e)
+	}
+
+	if !auditTailFollow {
+		return nil
+	}
+	return followAuditLog(log.Path())
+}
+
+// followAuditLog prints entries appended to the log at path until
+// interrupted, waiting for the file if it doesn't exist yet.
+func followAuditLog(path string) error {
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+
+	var partial string
+	for {
+		time.Sleep(auditPollInterval)
+
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadString('\n')
+			offset += int64(len(line))
+			if err != nil {
+				// Keep a line that is still being written for the next poll
+				partial += line
+				break
+			}
+			line, partial = partial+line, ""
+
+			var e config.AuditEntry
+			if json.Unmarshal([]byte(line), &e) == nil {
+				printAuditEntry(e)
+			}
+		}
+		f.Close()
+	}
+}
+
+// printAuditEntry prints an entry as a JSON line or a line of text.
+func printAuditEntry(e config.AuditEntry) {
+	if auditTailJSON {
+		data, _ := json.Marshal(e)
+		fmt.Println(string(data))
+		return
+	}
+
+	actor := strings.TrimSpace(e.Actor + " " + e.Fingerprint)
+	status := ""
+	if e.Status != 0 {
+		status = strconv.Itoa(e.Status)
+	}
+
+	fields := []string{
+		e.Time.Local().Format("2006-01-02 15:04:05"),
+		e.Via,
+		orDash(actor),
+		orDash(e.Remote),
+		e.Action,
+		orDash(e.Shed),
+		orDash(status),
+	}
+	line := strings.Join(fields, "  ")
+	if e.Detail != "" {
+		line += "  " + strconv.Quote(e.Detail)
+	}
+	fmt.Println(line)
+}
+
+// orDash returns s, or "-" if s is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	units "github.com/docker/go-units"
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/docker"
+)
+
+var (
+	gcDryRun     bool
+	gcUnusedDays int
+	gcJSON       bool
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove images no shed has used for a while",
+	Long: `Remove images no shed has used for image_gc.unused_days days.
+
+The server notes which images sheds and their sidecars use each time it
+collects images, and only images it has seen a shed use are removed. The
+default image, pre-pulled images, images matching image_gc.keep, and images
+any container still uses are kept. The server collects on its own every
+image_gc.interval; this runs a collection now and can run alongside it.`,
+	Example: `  shed-server gc --dry-run
+  shed-server gc --unused-days 7`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+func init() {
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "show what would be removed without removing anything")
+	gcCmd.Flags().IntVar(&gcUnusedDays, "unused-days", 0, "remove images unused for this many days (default image_gc.unused_days)")
+	gcCmd.Flags().BoolVar(&gcJSON, "json", false, "print the result as JSON")
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	days := cfg.ImageGC.UnusedDays
+	if cmd.Flags().Changed("unused-days") {
+		days = gcUnusedDays
+	}
+	if days < 1 {
+		return fmt.Errorf("image collection is not configured: set image_gc.unused_days or pass --unused-days")
+	}
+
+	dockerClient, err := docker.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer dockerClient.Close()
+
+	resp, err := docker.NewImageCollector(dockerClient, cfg).Collect(context.Background(), gcDryRun, days)
+	if err != nil {
+		return err
+	}
+
+	if gcJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+	}
+
+	if len(resp.Resources) == 0 {
+		fmt.Printf("No images unused for %d days.\n", days)
+		return nil
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tSIZE\tREASON")
+	for _, r := range resp.Resources {
+		id := strings.TrimPrefix(r.Name, "sha256:")
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		reason := r.Reason
+		if r.Error != "" {
+			reason = "failed: " + r.Error
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", id, units.BytesSize(float64(r.Size)), reason)
+	}
+	w.Flush()
+
+	fmt.Println()
+	reclaimed := units.BytesSize(float64(resp.SpaceReclaimed))
+	if resp.DryRun {
+		fmt.Printf("Would reclaim %s. Run without --dry-run to remove.\n", reclaimed)
+		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d of %d images; reclaimed %s", failed, len(resp.Resources), reclaimed)
+	}
+	fmt.Printf("Reclaimed %s.\n", reclaimed)
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/charliek/shed/internal/sshd"
+)
+
+var rotateHostKeyFinish bool
+
+var rotateHostKeyCmd = &cobra.Command{
+	Use:   "rotate-host-key",
+	Short: "Replace the SSH host key",
+	Long: `Replace the SSH host key in two steps without locking out clients.
+
+The first run generates the new key. The server keeps presenting the current
+key and publishes both, so clients can trust the new one ahead of time with:
+  shed server refresh <name>
+
+Once clients have refreshed, run again with --finish to switch the server to
+the new key. New connections use it straight away, without a restart, and
+the old key is kept as host_key.old. Clients that haven't refreshed must run
+'shed server refresh' and confirm the changed key.`,
+	Args: cobra.NoArgs,
+	RunE: runRotateHostKey,
+}
+
+func init() {
+	rotateHostKeyCmd.Flags().BoolVar(&rotateHostKeyFinish, "finish", false, "switch to the new host key")
+}
+
+func runRotateHostKey(cmd *cobra.Command, args []string) error {
+	if rotateHostKeyFinish {
+		signer, err := sshd.FinishHostKeyRotation(DefaultHostKeyPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Switched to the new host key %s\n", gossh.FingerprintSHA256(signer.PublicKey()))
+		fmt.Printf("The old key is kept at %s\n", sshd.OldHostKeyPath(DefaultHostKeyPath))
+		fmt.Println("\nClients drop the old key on their next refresh:")
+		fmt.Println("  shed server refresh <name>")
+		return nil
+	}
+
+	signer, err := sshd.RotateHostKey(DefaultHostKeyPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Generated new host key %s\n", gossh.FingerprintSHA256(signer.PublicKey()))
+	fmt.Println("\nThe server keeps presenting the current key for now. On each client, run:")
+	fmt.Println("  shed server refresh <name>")
+	fmt.Println("\nThen switch the server to the new key with:")
+	fmt.Println("  shed-server rotate-host-key --finish")
+	return nil
+}
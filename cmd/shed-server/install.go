@@ -3,36 +3,127 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
 )
 
 const (
-	// systemdUnitPath is where the systemd unit file will be installed
-	systemdUnitPath = "/etc/systemd/system/shed-server.service"
+	// serviceName is the name shed-server is installed under
+	serviceName = "shed-server"
 
 	// defaultBinaryPath is the expected location of the shed-server binary
 	defaultBinaryPath = "/usr/local/bin/shed-server"
 )
 
+var (
+	installInit  string
+	installPrint bool
+)
+
 var installCmd = &cobra.Command{
 	Use:   "install",
-	Short: "Install shed-server as a systemd service",
-	Long: `Install shed-server as a systemd service.
+	Short: "Install shed-server as a system service",
+	Long: `Install shed-server as a system service.
+
+The init system is detected: systemd or OpenRC or runit on Linux, and
+launchd on macOS. Use --init to choose one. The service runs as the user
+who invoked sudo. Requires root privileges.
 
-This command creates a systemd unit file and enables the service.
-Requires root privileges.`,
+With --print the service definition is written to stdout instead, for
+installing by hand or on init systems that aren't detected.`,
+	Args: cobra.NoArgs,
 	RunE: runInstall,
 }
 
+func init() {
+	installCmd.Flags().StringVar(&installInit, "init", "", "init system to install for: "+strings.Join(installerNames(), ", "))
+	installCmd.Flags().BoolVar(&installPrint, "print", false, "print the service definition instead of installing it")
+	installCmd.Flags().BoolVar(&installPrint, "dry-run", false, "same as --print")
+	uninstallCmd.Flags().StringVar(&installInit, "init", "", "init system to uninstall from: "+strings.Join(installerNames(), ", "))
+}
+
+// serviceInstaller installs shed-server as a service under one init system.
+type serviceInstaller interface {
+	// name is the init system's name, as given to --init.
+	name() string
+
+	// path is where the service definition is installed, and mode is the
+	// file mode it is written with.
+	path() string
+	mode() os.FileMode
+
+	// generate returns the service definition for running as u, after
+	// engine's service.
+	generate(u *user.User, engine engineService) string
+
+	// enable makes the init system pick up the installed definition.
+	enable() error
+
+	// disable stops and disables the service before its definition is
+	// removed. Failures are reported but don't stop the uninstall.
+	disable()
+
+	// cleanup runs after the definition is removed.
+	cleanup() error
+
+	// instructions are paragraphs printed once the service is installed.
+	instructions() []string
+}
+
+// installers are the supported init systems by name.
+var installers = map[string]serviceInstaller{
+	"systemd": systemdInstaller{},
+	"launchd": launchdInstaller{},
+	"openrc":  openrcInstaller{},
+	"runit":   runitInstaller{},
+}
+
+// installerNames returns the supported init systems in order.
+func installerNames() []string {
+	names := make([]string, 0, len(installers))
+	for name := range installers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectInstaller returns the installer named by --init, or the one for
+// the running init system. Linux hosts that can't be identified get
+// systemd.
+func selectInstaller() (serviceInstaller, error) {
+	if installInit != "" {
+		inst, ok := installers[installInit]
+		if !ok {
+			return nil, fmt.Errorf("unknown init system %q (must be one of: %s)", installInit, strings.Join(installerNames(), ", "))
+		}
+		return inst, nil
+	}
+
+	switch {
+	case runtime.GOOS == "darwin":
+		return launchdInstaller{}, nil
+	case pathExists("/run/systemd/system"):
+		return systemdInstaller{}, nil
+	case pathExists("/run/openrc") || pathExists("/sbin/openrc-run"):
+		return openrcInstaller{}, nil
+	case pathExists("/run/runit") || pathExists("/etc/runit"):
+		return runitInstaller{}, nil
+	}
+	return systemdInstaller{}, nil
+}
+
 func runInstall(cmd *cobra.Command, args []string) error {
-	// Check for root privileges
-	if os.Geteuid() != 0 {
-		return fmt.Errorf("this command must be run as root (try: sudo shed-server install)")
+	inst, err := selectInstaller()
+	if err != nil {
+		return err
 	}
 
 	// Get current user info (the user who invoked sudo)
@@ -41,22 +132,36 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	fmt.Printf("Installing shed-server as systemd service for user %s...\n", currentUser.Username)
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	engine := engineServiceFor(cfg)
+
+	if installPrint {
+		fmt.Fprintf(os.Stderr, "# %s service for user %s; install to %s\n", inst.name(), currentUser.Username, inst.path())
+		fmt.Print(inst.generate(currentUser, engine))
+		return nil
+	}
+
+	// Check for root privileges
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("this command must be run as root (try: sudo shed-server install, or shed-server install --print)")
+	}
 
-	// Generate systemd unit file content
-	unitContent := generateUnitFile(currentUser)
+	fmt.Printf("Installing shed-server as %s service for user %s...\n", inst.name(), currentUser.Username)
 
 	// Ensure the directory exists
-	unitDir := filepath.Dir(systemdUnitPath)
-	if err := os.MkdirAll(unitDir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", unitDir, err)
+	dir := filepath.Dir(inst.path())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
-	// Write the unit file
-	if err := os.WriteFile(systemdUnitPath, []byte(unitContent), 0644); err != nil {
-		return fmt.Errorf("failed to write unit file: %w", err)
+	// Write the service definition
+	if err := os.WriteFile(inst.path(), []byte(inst.generate(currentUser, engine)), inst.mode()); err != nil {
+		return fmt.Errorf("failed to write service file: %w", err)
 	}
-	fmt.Printf("Created systemd unit file: %s\n", systemdUnitPath)
+	fmt.Printf("Created %s service file: %s\n", inst.name(), inst.path())
 
 	// Ensure /etc/shed directory exists for host key
 	if err := os.MkdirAll("/etc/shed", 0755); err != nil {
@@ -64,33 +169,66 @@ func runInstall(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Println("Created /etc/shed directory")
 
-	// Run systemctl daemon-reload
-	if err := runSystemctl("daemon-reload"); err != nil {
-		return fmt.Errorf("failed to reload systemd: %w", err)
+	if err := inst.enable(); err != nil {
+		return err
 	}
-	fmt.Println("Reloaded systemd daemon")
-
-	// Enable the service
-	if err := runSystemctl("enable", "shed-server"); err != nil {
-		return fmt.Errorf("failed to enable service: %w", err)
-	}
-	fmt.Println("Enabled shed-server service")
 
 	fmt.Println()
 	fmt.Println("Installation complete!")
-	fmt.Println()
-	fmt.Println("To start the service now, run:")
-	fmt.Println("  sudo systemctl start shed-server")
-	fmt.Println()
-	fmt.Println("To check the service status:")
-	fmt.Println("  sudo systemctl status shed-server")
-	fmt.Println()
-	fmt.Println("To view logs:")
-	fmt.Println("  sudo journalctl -u shed-server -f")
+	for _, paragraph := range inst.instructions() {
+		fmt.Println()
+		fmt.Println(paragraph)
+	}
 
 	return nil
 }
 
+// engineService is the container engine's service on this host, which
+// shed-server starts after and must be able to reach.
+type engineService struct {
+	// name is the engine's service name, or empty when the engine runs on
+	// another host.
+	name string
+
+	// socketGroup is the group owning the engine's socket, which the
+	// server needs as a supplementary group on init systems that don't
+	// give it the user's groups. Empty if the socket isn't found.
+	socketGroup string
+}
+
+// Engine sockets used when docker_host and DOCKER_HOST are unset.
+const (
+	dockerSocket = "/var/run/docker.sock"
+	podmanSocket = "/run/podman/podman.sock"
+)
+
+// engineServiceFor returns the engine service the configured runtime runs
+// as, or none if docker_host names an engine on another host.
+func engineServiceFor(cfg *config.ServerConfig) engineService {
+	socket := dockerSocket
+	engine := engineService{name: config.RuntimeDocker}
+	if cfg.Runtime == config.RuntimePodman {
+		socket = podmanSocket
+		engine.name = config.RuntimePodman
+	}
+	if cfg.DockerHost != "" {
+		path, ok := strings.CutPrefix(cfg.DockerHost, "unix://")
+		if !ok {
+			return engineService{}
+		}
+		socket = path
+	}
+
+	if info, err := os.Stat(socket); err == nil {
+		engine.socketGroup = fileGroup(info)
+	} else if engine.name == config.RuntimeDocker {
+		// The engine may not be running yet; its socket is usually
+		// group docker
+		engine.socketGroup = "docker"
+	}
+	return engine
+}
+
 // getCurrentUser returns the user who invoked sudo, or the current user if not running under sudo.
 func getCurrentUser() (*user.User, error) {
 	// Check for SUDO_USER environment variable
@@ -103,47 +241,18 @@ func getCurrentUser() (*user.User, error) {
 	return user.Current()
 }
 
-// generateUnitFile generates the systemd unit file content.
-func generateUnitFile(u *user.User) string {
-	// Get primary group for user
+// groupName returns the name of u's primary group, or its ID if the group
+// can't be looked up.
+func groupName(u *user.User) string {
 	group, err := user.LookupGroupId(u.Gid)
-	groupName := u.Gid
-	if err == nil {
-		groupName = group.Name
-	}
-
-	template := `[Unit]
-Description=Shed Development Environment Server
-After=network.target docker.service
-Requires=docker.service
-
-[Service]
-Type=simple
-User={user}
-Group={group}
-ExecStart={binary} serve
-Restart=on-failure
-RestartSec=5
-Environment=HOME={home}
-
-[Install]
-WantedBy=multi-user.target
-`
-
-	// Replace placeholders
-	content := template
-	content = strings.ReplaceAll(content, "{user}", u.Username)
-	content = strings.ReplaceAll(content, "{group}", groupName)
-	content = strings.ReplaceAll(content, "{binary}", defaultBinaryPath)
-	content = strings.ReplaceAll(content, "{home}", u.HomeDir)
-
-	return content
+	if err != nil {
+		return u.Gid
+	}
+	return group.Name
 }
 
-// runSystemctl runs a systemctl command with the given arguments.
-func runSystemctl(args ...string) error {
-	cmd := exec.Command("systemctl", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// pathExists reports whether path exists.
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileGroup is not known on this platform, so the engine service is used
+// without a socket group.
+func fileGroup(info os.FileInfo) string {
+	return ""
+}
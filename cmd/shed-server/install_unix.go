@@ -0,0 +1,24 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// fileGroup returns the name of the group owning a file, or its ID if the
+// group has no name.
+func fileGroup(info os.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	gid := strconv.FormatUint(uint64(st.Gid), 10)
+	if group, err := user.LookupGroupId(gid); err == nil {
+		return group.Name
+	}
+	return gid
+}
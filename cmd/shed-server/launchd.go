@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+const (
+	// launchdLabel identifies the shed-server job to launchd
+	launchdLabel = "dev.shed.server"
+
+	// launchdPlistPath is where the launchd job definition is installed
+	launchdPlistPath = "/Library/LaunchDaemons/" + launchdLabel + ".plist"
+
+	// launchdLogPath receives the server's output, since launchd has no
+	// journal
+	launchdLogPath = "/var/log/shed-server.log"
+)
+
+// launchdInstaller installs shed-server as a launchd daemon that starts
+// at boot.
+type launchdInstaller struct{}
+
+func (launchdInstaller) name() string      { return "launchd" }
+func (launchdInstaller) path() string      { return launchdPlistPath }
+func (launchdInstaller) mode() os.FileMode { return 0644 }
+
+func (launchdInstaller) enable() error {
+	// Reinstalling replaces a loaded job, which launchd won't bootstrap twice
+	_ = exec.Command("launchctl", "bootout", "system/"+launchdLabel).Run()
+	if err := runLaunchctl("bootstrap", "system", launchdPlistPath); err != nil {
+		return fmt.Errorf("failed to load service: %w", err)
+	}
+	if err := runLaunchctl("enable", "system/"+launchdLabel); err != nil {
+		return fmt.Errorf("failed to enable service: %w", err)
+	}
+	fmt.Println("Loaded and started shed-server service")
+	return nil
+}
+
+func (launchdInstaller) disable() {
+	// Unload the job (ignore errors - it might not be loaded)
+	fmt.Println("Stopping shed-server service...")
+	if err := runLaunchctl("bootout", "system/"+launchdLabel); err != nil {
+		fmt.Printf("Note: Could not unload service (may not be loaded): %v\n", err)
+	}
+}
+
+func (launchdInstaller) cleanup() error { return nil }
+
+func (launchdInstaller) instructions() []string {
+	return []string{
+		"To check the service status:\n  sudo launchctl print system/" + launchdLabel,
+		"To restart the service:\n  sudo launchctl kickstart -k system/" + launchdLabel,
+		"To view logs:\n  tail -f " + launchdLogPath,
+	}
+}
+
+// generate generates the launchd job definition. launchd has no service
+// dependencies, so the engine is ignored.
+func (launchdInstaller) generate(u *user.User, _ engineService) string {
+	template := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{label}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{binary}</string>
+		<string>serve</string>
+	</array>
+	<key>UserName</key>
+	<string>{user}</string>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>HOME</key>
+		<string>{home}</string>
+		<key>PATH</key>
+		<string>/opt/homebrew/bin:/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ThrottleInterval</key>
+	<integer>5</integer>
+	<key>StandardOutPath</key>
+	<string>{log}</string>
+	<key>StandardErrorPath</key>
+	<string>{log}</string>
+</dict>
+</plist>
+`
+
+	// Replace placeholders
+	content := template
+	content = strings.ReplaceAll(content, "{label}", launchdLabel)
+	content = strings.ReplaceAll(content, "{binary}", xmlEscape(defaultBinaryPath))
+	content = strings.ReplaceAll(content, "{user}", xmlEscape(u.Username))
+	content = strings.ReplaceAll(content, "{home}", xmlEscape(u.HomeDir))
+	content = strings.ReplaceAll(content, "{log}", launchdLogPath)
+
+	return content
+}
+
+// xmlEscape escapes s for use as XML text.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// runLaunchctl runs a launchctl command with the given arguments.
+func runLaunchctl(args ...string) error {
+	cmd := exec.Command("launchctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
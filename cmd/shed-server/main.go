@@ -4,6 +4,8 @@ package main
 import (
 	"fmt"
 	"os"
+	// Embed the timezone database so shed timezones validate on hosts without one
+	_ "time/tzdata"
 
 	"github.com/spf13/cobra"
 
@@ -36,6 +38,11 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(uninstallCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(rotateHostKeyCmd)
 }
 
 func main() {
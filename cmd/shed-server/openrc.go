@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+const (
+	// openrcScriptPath is where the OpenRC init script will be installed
+	openrcScriptPath = "/etc/init.d/shed-server"
+
+	// openrcLogPath receives the server's output
+	openrcLogPath = "/var/log/shed-server.log"
+)
+
+// openrcInstaller installs shed-server as an OpenRC service, as used by
+// Alpine and Gentoo.
+type openrcInstaller struct{}
+
+func (openrcInstaller) name() string      { return "openrc" }
+func (openrcInstaller) path() string      { return openrcScriptPath }
+func (openrcInstaller) mode() os.FileMode { return 0755 }
+
+// generate generates the OpenRC init script.
+func (openrcInstaller) generate(u *user.User, engine engineService) string {
+	template := `#!/sbin/openrc-run
+
+name="shed-server"
+description="Shed Development Environment Server"
+command="{binary}"
+command_args="serve"
+command_user="{user}:{group}"
+supervisor="supervise-daemon"
+output_log="{log}"
+error_log="{log}"
+respawn_delay=5
+
+export HOME="{home}"
+
+depend() {
+	need net{engine}
+}
+
+start_pre() {
+	checkpath --file --owner "${command_user}" "${output_log}"
+}
+`
+
+	// Replace placeholders
+	content := template
+	need := ""
+	if engine.name != "" {
+		need = " " + engine.name
+	}
+	content = strings.ReplaceAll(content, "{engine}", need)
+	content = strings.ReplaceAll(content, "{user}", u.Username)
+	content = strings.ReplaceAll(content, "{group}", groupName(u))
+	content = strings.ReplaceAll(content, "{binary}", defaultBinaryPath)
+	content = strings.ReplaceAll(content, "{home}", u.HomeDir)
+	content = strings.ReplaceAll(content, "{log}", openrcLogPath)
+
+	return content
+}
+
+func (openrcInstaller) enable() error {
+	if err := runOpenRC("rc-update", "add", serviceName, "default"); err != nil {
+		return fmt.Errorf("failed to enable service: %w", err)
+	}
+	fmt.Println("Enabled shed-server service")
+	return nil
+}
+
+func (openrcInstaller) disable() {
+	// Stop the service (ignore errors - service might not be running)
+	fmt.Println("Stopping shed-server service...")
+	if err := runOpenRC("rc-service", serviceName, "stop"); err != nil {
+		fmt.Printf("Note: Could not stop service (may not be running): %v\n", err)
+	}
+
+	// Disable the service (ignore errors - service might not be enabled)
+	fmt.Println("Disabling shed-server service...")
+	if err := runOpenRC("rc-update", "del", serviceName, "default"); err != nil {
+		fmt.Printf("Note: Could not disable service (may not be enabled): %v\n", err)
+	}
+}
+
+func (openrcInstaller) cleanup() error { return nil }
+
+func (openrcInstaller) instructions() []string {
+	return []string{
+		"To start the service now, run:\n  sudo rc-service shed-server start",
+		"To check the service status:\n  sudo rc-service shed-server status",
+		"To view logs:\n  tail -f " + openrcLogPath,
+	}
+}
+
+// runOpenRC runs an OpenRC command such as rc-update or rc-service.
+func runOpenRC(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// runitServiceDir is where the runit service directory will be created
+const runitServiceDir = "/etc/sv/shed-server"
+
+// runitRunDirs are the directories runsvdir supervises on common
+// distributions, in the order they are tried.
+var runitRunDirs = []string{"/var/service", "/etc/service", "/etc/runit/runsvdir/default"}
+
+// runitInstaller installs shed-server as a runit service, as used by Void.
+type runitInstaller struct{}
+
+func (runitInstaller) name() string      { return "runit" }
+func (runitInstaller) path() string      { return filepath.Join(runitServiceDir, "run") }
+func (runitInstaller) mode() os.FileMode { return 0755 }
+
+// generate generates the runit run script. chpst sets only the groups it
+// is given, so the engine socket's group is added to the user's own.
+func (runitInstaller) generate(u *user.User, engine engineService) string {
+	template := `#!/bin/sh
+# Shed Development Environment Server
+exec 2>&1
+{wait}exec chpst -u "{user}:{groups}" env HOME="{home}" "{binary}" serve
+`
+
+	// Replace placeholders
+	content := template
+	wait := ""
+	if engine.name != "" {
+		// runsv restarts the script until the engine is up
+		wait = "sv check " + engine.name + " >/dev/null || exit 1\n"
+	}
+	groups := groupName(u)
+	if engine.socketGroup != "" && engine.socketGroup != groups {
+		groups += ":" + engine.socketGroup
+	}
+	content = strings.ReplaceAll(content, "{wait}", wait)
+	content = strings.ReplaceAll(content, "{user}", u.Username)
+	content = strings.ReplaceAll(content, "{groups}", groups)
+	content = strings.ReplaceAll(content, "{binary}", defaultBinaryPath)
+	content = strings.ReplaceAll(content, "{home}", u.HomeDir)
+
+	return content
+}
+
+// runDir returns the directory runsvdir supervises on this host.
+func (runitInstaller) runDir() string {
+	for _, dir := range runitRunDirs {
+		if pathExists(dir) {
+			return dir
+		}
+	}
+	return runitRunDirs[0]
+}
+
+func (r runitInstaller) enable() error {
+	link := filepath.Join(r.runDir(), serviceName)
+	if _, err := os.Lstat(link); err == nil {
+		fmt.Printf("Service already linked: %s\n", link)
+		return nil
+	}
+	if err := os.Symlink(runitServiceDir, link); err != nil {
+		return fmt.Errorf("failed to enable service: %w", err)
+	}
+	fmt.Printf("Enabled shed-server service: %s\n", link)
+	return nil
+}
+
+func (r runitInstaller) disable() {
+	// Stop the service (ignore errors - service might not be running)
+	fmt.Println("Stopping shed-server service...")
+	cmd := exec.Command("sv", "down", serviceName)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("Note: Could not stop service (may not be running): %v\n", err)
+	}
+
+	// Unlink the service so runsvdir stops supervising it
+	fmt.Println("Disabling shed-server service...")
+	if err := os.Remove(filepath.Join(r.runDir(), serviceName)); err != nil {
+		fmt.Printf("Note: Could not disable service (may not be enabled): %v\n", err)
+	}
+}
+
+func (runitInstaller) cleanup() error {
+	// runsv leaves its supervise state in the service directory
+	if err := os.RemoveAll(runitServiceDir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", runitServiceDir, err)
+	}
+	fmt.Printf("Removed runit service directory: %s\n", runitServiceDir)
+	return nil
+}
+
+func (runitInstaller) instructions() []string {
+	return []string{
+		"runsvdir starts the service within a few seconds.",
+		"To check the service status:\n  sudo sv status shed-server",
+		"To restart the service:\n  sudo sv restart shed-server",
+	}
+}
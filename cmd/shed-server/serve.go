@@ -2,22 +2,37 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types/container"
 	"github.com/spf13/cobra"
 
+	"github.com/charliek/shed/internal/activity"
 	"github.com/charliek/shed/internal/api"
+	"github.com/charliek/shed/internal/audit"
+	"github.com/charliek/shed/internal/authkeys"
 	"github.com/charliek/shed/internal/config"
 	"github.com/charliek/shed/internal/docker"
+	"github.com/charliek/shed/internal/events"
+	"github.com/charliek/shed/internal/logging"
+	"github.com/charliek/shed/internal/mdns"
+	"github.com/charliek/shed/internal/proxy"
 	"github.com/charliek/shed/internal/sshd"
+	"github.com/charliek/shed/internal/version"
 )
 
 const (
@@ -26,6 +41,10 @@ const (
 
 	// shutdownTimeout is the maximum time to wait for graceful shutdown
 	shutdownTimeout = 30 * time.Second
+
+	// readHeaderTimeout bounds how long a client may take to send request
+	// headers, so slow clients can't hold connections open
+	readHeaderTimeout = 10 * time.Second
 )
 
 var serveCmd = &cobra.Command{
@@ -42,9 +61,18 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	log.Printf("Starting shed-server...")
-	log.Printf("HTTP port: %d", cfg.HTTPPort)
-	log.Printf("SSH port: %d", cfg.SSHPort)
+	if err := logging.Setup(os.Stderr, cfg.LogFormat, cfg.LogLevel); err != nil {
+		return err
+	}
+
+	slog.Info("Starting shed-server", "http_port", cfg.HTTPPort, "ssh_port", cfg.SSHPort)
+
+	// Forwarded connections go to sheds' addresses, which only the
+	// engine's host can reach
+	if cfg.RemoteEngine() && (cfg.Forwarding.Local || cfg.Forwarding.Remote) {
+		slog.Warn("Disabling SSH port forwarding, which needs the engine on this host", "docker_host", cfg.DockerHost)
+		cfg.Forwarding.Local, cfg.Forwarding.Remote = false, false
+	}
 
 	// Initialize Docker client
 	dockerClient, err := docker.NewClient(cfg)
@@ -52,39 +80,161 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create docker client: %w", err)
 	}
 	defer dockerClient.Close()
-	log.Printf("Connected to Docker")
+	slog.Info("Connected to container runtime", "runtime", dockerClient.Runtime())
+
+	// Forget state kept for sheds removed while the server was down
+	if err := dockerClient.ReconcileState(context.Background()); err != nil {
+		slog.Warn("Failed to reconcile state with containers", "err", err)
+	}
+
+	// Background tasks run until shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Keep images pulled so creating a shed doesn't wait on a pull
+	var prepuller *docker.Prepuller
+	if cfg.Prepull.Interval > 0 {
+		prepuller = docker.NewPrepuller(dockerClient, cfg)
+		go prepuller.Run(bgCtx)
+		slog.Info("Pre-pulling images", "images", len(cfg.PrepullImages()), "interval", cfg.Prepull.Interval)
+	}
+
+	// Remove images sheds stopped using so the disk doesn't fill up
+	var imageCollector *docker.ImageCollector
+	if cfg.ImageGC.Enabled() {
+		imageCollector = docker.NewImageCollector(dockerClient.Docker(), cfg)
+		go imageCollector.Run(bgCtx)
+		slog.Info("Collecting unused images", "unused_days", cfg.ImageGC.UnusedDays, "interval", cfg.ImageGC.Interval)
+	}
+
+	// Shed events come from Docker and from sessions, as well as the
+	// creates and deletes the API makes
+	bus := events.NewBus()
+	go dockerClient.WatchEvents(bgCtx, func(ev config.ShedEvent) {
+		// Secret files are in memory, so restarts outside shed, such as
+		// by the restart policy, need them written again
+		if ev.Type == config.ShedEventStarted {
+			go func() {
+				if err := dockerClient.WriteSecretFiles(bgCtx, ev.Shed); err != nil {
+					slog.Warn("Failed to write secrets", "shed", ev.Shed, "err", err)
+				}
+			}()
+		}
+		bus.Publish(ev)
+	})
 
 	// Create adapters for the different interfaces
-	apiAdapter := &dockerAPIAdapter{client: dockerClient}
+	tracker := activity.NewTracker()
+	apiAdapter := &dockerAPIAdapter{client: dockerClient, prepuller: prepuller, imageCollector: imageCollector, tracker: tracker, events: bus}
 	sshAdapter := &dockerSSHAdapter{client: dockerClient}
+	tracker.OnSession(func(name string, opened bool) {
+		dockerClient.RecordActivity(name, time.Now())
+		typ := config.ShedEventSessionClosed
+		if opened {
+			typ = config.ShedEventSessionOpened
+		}
+		bus.Publish(config.ShedEvent{Type: typ, Shed: name})
+	})
 
 	// Initialize SSH server
-	sshServer, err := sshd.NewServer(sshAdapter, DefaultHostKeyPath, cfg.SSHPort, cfg.Terminal)
+	authorizedKeys := authkeys.NewStore(cfg.StateDir, cfg.AuthorizedKeys)
+	sshServer, err := sshd.NewServer(sshAdapter, sshd.ServerOptions{
+		HostKeyPath: DefaultHostKeyPath,
+		Addrs:       cfg.SSHAddrs(),
+		Terminal:    cfg.Terminal,
+		Forwarding:  cfg.Forwarding,
+		SSH:         cfg.SSH,
+		Keys:        authorizedKeys,
+		Activity:    tracker,
+		Audit:       audit.Open(cfg.StateDir),
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create SSH server: %w", err)
 	}
-	hostKey := sshServer.GetHostPublicKey()
+	if !authorizedKeys.Enabled() {
+		slog.Warn("SSH server accepts any key; register one with 'shed keys add'")
+	}
+
+	// Stop sheds nobody has used for a while
+	if cfg.IdleTimeout > 0 {
+		go docker.NewIdleReaper(dockerClient, cfg, tracker).Run(bgCtx)
+		slog.Info("Stopping idle sheds", "idle_timeout", cfg.IdleTimeout)
+	}
+
+	// Start and stop sheds on their schedules
+	go docker.NewScheduler(dockerClient).Run(bgCtx)
+
+	// Delete or stop sheds whose TTL has run out
+	go docker.NewExpiryReaper(dockerClient, func(name string) {
+		bus.Publish(config.ShedEvent{Type: config.ShedEventDeleted, Shed: name})
+	}).Run(bgCtx)
 
 	// Initialize HTTP API server
-	apiServer := api.NewServer(apiAdapter, cfg, hostKey)
+	apiServer := api.NewServer(apiAdapter, cfg, sshServer)
 	router := apiServer.Router()
+	if !apiServer.AuthEnabled() {
+		slog.Warn("HTTP API is unauthenticated; create a token with 'shed-server token create <name>'")
+	}
 
 	// Create HTTP server
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.HTTPPort),
-		Handler: router,
+		Handler:           router,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	httpListeners := make([]net.Listener, 0, len(cfg.HTTPAddrs()))
+	for _, addr := range cfg.HTTPAddrs() {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range httpListeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		httpListeners = append(httpListeners, ln)
+	}
+
+	// Serve sheds' HTTP ports at preview URLs
+	var proxyServer *http.Server
+	if cfg.Proxy.Enabled() {
+		handler, err := proxy.NewServer(&dockerProxyAdapter{client: dockerClient}, cfg.Proxy)
+		if err != nil {
+			return err
+		}
+		proxyServer = &http.Server{
+			Addr:              cfg.Proxy.Listen,
+			Handler:           handler,
+			ReadHeaderTimeout: readHeaderTimeout,
+		}
 	}
 
 	// Channel to collect errors from servers
-	errChan := make(chan error, 2)
+	errChan := make(chan error, len(httpListeners)+2)
+
+	// Start HTTP server in a goroutine per address
+	for _, ln := range httpListeners {
+		go func(ln net.Listener) {
+			slog.Info("HTTP server listening", "addr", ln.Addr().String())
+			if err := httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+				errChan <- fmt.Errorf("HTTP server error: %w", err)
+			}
+		}(ln)
+	}
 
-	// Start HTTP server in goroutine
-	go func() {
-		log.Printf("HTTP server listening on :%d", cfg.HTTPPort)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errChan <- fmt.Errorf("HTTP server error: %w", err)
-		}
-	}()
+	// Start the preview proxy in goroutine
+	if proxyServer != nil {
+		go func() {
+			slog.Info("Preview proxy listening", "addr", proxyServer.Addr, "url", cfg.Proxy.URL)
+			var err error
+			if cfg.Proxy.TLSCert != "" {
+				err = proxyServer.ListenAndServeTLS(cfg.Proxy.TLSCert, cfg.Proxy.TLSKey)
+			} else {
+				err = proxyServer.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				errChan <- fmt.Errorf("preview proxy error: %w", err)
+			}
+		}()
+	}
 
 	// Start SSH server in goroutine
 	go func() {
@@ -93,18 +243,33 @@ func runServe(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
+	// Let clients on the local network find the server
+	if cfg.MDNS {
+		go advertise(bgCtx, cfg)
+	}
+
 	// Setup signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	log.Printf("Shed server is ready")
+	// SIGUSR1 toggles debug logging without a restart
+	levelChan := make(chan os.Signal, 1)
+	notifyLevelToggle(levelChan)
+	defer signal.Stop(levelChan)
+	go func() {
+		for range levelChan {
+			slog.Info("Log level changed", "level", logging.ToggleDebug(cfg.LogLevel))
+		}
+	}()
+
+	slog.Info("Shed server is ready")
 
 	// Wait for signal or error
 	select {
 	case sig := <-sigChan:
-		log.Printf("Received signal %v, initiating graceful shutdown...", sig)
+		slog.Info("Received signal, shutting down", "signal", sig.String())
 	case err := <-errChan:
-		log.Printf("Server error: %v", err)
+		slog.Error("Server error", "err", err)
 		return err
 	}
 
@@ -113,21 +278,59 @@ func runServe(cmd *cobra.Command, args []string) error {
 	defer cancel()
 
 	// Shutdown HTTP server
-	log.Printf("Shutting down HTTP server...")
+	slog.Info("Shutting down HTTP server")
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Printf("HTTP server shutdown error: %v", err)
+		slog.Error("HTTP server shutdown failed", "err", err)
+	}
+
+	// Async creates outlive their requests
+	slog.Info("Waiting for background jobs")
+	if err := apiServer.WaitForJobs(ctx); err != nil {
+		slog.Warn("Canceled background jobs still running at shutdown", "err", err)
+	}
+
+	if proxyServer != nil {
+		slog.Info("Shutting down preview proxy")
+		if err := proxyServer.Shutdown(ctx); err != nil {
+			slog.Error("Preview proxy shutdown failed", "err", err)
+		}
 	}
 
 	// Shutdown SSH server
-	log.Printf("Shutting down SSH server...")
+	slog.Info("Shutting down SSH server")
 	if err := sshServer.Shutdown(ctx); err != nil {
-		log.Printf("SSH server shutdown error: %v", err)
+		slog.Error("SSH server shutdown failed", "err", err)
 	}
 
-	log.Printf("Shutdown complete")
+	slog.Info("Shutdown complete")
 	return nil
 }
 
+// advertise announces the server over mDNS until ctx is done. Failures
+// are only logged since the server works without it.
+func advertise(ctx context.Context, cfg *config.ServerConfig) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = cfg.Name
+	}
+	host, _, _ = strings.Cut(host, ".")
+
+	svc := mdns.Service{
+		Instance: cfg.Name,
+		Host:     host + ".local.",
+		Port:     cfg.HTTPPort,
+		IPs:      mdns.LocalAddrs(),
+		Text: map[string]string{
+			"ssh_port": strconv.Itoa(cfg.SSHPort),
+			"version":  version.Version,
+		},
+	}
+	slog.Info("Advertising over mDNS", "service", mdns.ServiceType, "name", cfg.Name)
+	if err := mdns.Advertise(ctx, svc); err != nil {
+		slog.Warn("mDNS advertising stopped", "err", err)
+	}
+}
+
 // loadConfig loads the server configuration from the specified path or default locations.
 func loadConfig() (*config.ServerConfig, error) {
 	if configPath != "" {
@@ -138,7 +341,11 @@ func loadConfig() (*config.ServerConfig, error) {
 
 // dockerAPIAdapter adapts the docker.Client to the api.DockerClient interface.
 type dockerAPIAdapter struct {
-	client *docker.Client
+	client         *docker.Client
+	prepuller      *docker.Prepuller
+	imageCollector *docker.ImageCollector
+	tracker        *activity.Tracker
+	events         *events.Bus
 }
 
 // ListSheds returns all shed containers.
@@ -151,14 +358,54 @@ func (a *dockerAPIAdapter) GetShed(ctx context.Context, name string) (*config.Sh
 	return a.client.GetShed(ctx, name)
 }
 
+// GetShedDetails returns a shed with its container details and SSH
+// activity filled in.
+func (a *dockerAPIAdapter) GetShedDetails(ctx context.Context, name string) (*config.Shed, error) {
+	shed, err := a.client.GetShedDetails(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	shed.Details.Connections = a.tracker.Connections(name)
+	shed.Details.LastConnection = a.tracker.LastConnection(name)
+	return shed, nil
+}
+
 // CreateShed creates a new shed container.
-func (a *dockerAPIAdapter) CreateShed(ctx context.Context, req config.CreateShedRequest) (*config.Shed, error) {
-	return a.client.CreateShed(ctx, req)
+func (a *dockerAPIAdapter) CreateShed(ctx context.Context, req config.CreateShedRequest, progress config.ProgressFunc) (*config.Shed, error) {
+	return a.created(a.client.CreateShed(ctx, req, progress))
+}
+
+// IdempotentShed returns the shed a create with the idempotency key made.
+func (a *dockerAPIAdapter) IdempotentShed(key string) (string, bool) {
+	return a.client.IdempotentShed(key)
+}
+
+// created publishes events for a newly created shed, including a failed
+// clone, and passes the creation's result through.
+func (a *dockerAPIAdapter) created(shed *config.Shed, err error) (*config.Shed, error) {
+	if err != nil {
+		return nil, err
+	}
+	a.events.Publish(config.ShedEvent{Type: config.ShedEventCreated, Shed: shed.Name})
+	if shed.SetupError != "" {
+		a.events.Publish(config.ShedEvent{Type: config.ShedEventCloneFailed, Shed: shed.Name, Message: shed.SetupError})
+	}
+	return shed, nil
 }
 
 // DeleteShed removes a shed container and optionally its volume.
 func (a *dockerAPIAdapter) DeleteShed(ctx context.Context, name string, keepVolume bool) error {
-	return a.client.DeleteShed(ctx, name, keepVolume)
+	if err := a.client.DeleteShed(ctx, name, keepVolume); err != nil {
+		return err
+	}
+	a.events.Publish(config.ShedEvent{Type: config.ShedEventDeleted, Shed: name})
+	return nil
+}
+
+// SubscribeEvents returns a channel of shed events and a function that
+// ends the subscription.
+func (a *dockerAPIAdapter) SubscribeEvents() (<-chan config.ShedEvent, func()) {
+	return a.events.Subscribe()
 }
 
 // StartShed starts a stopped shed container.
@@ -171,6 +418,264 @@ func (a *dockerAPIAdapter) StopShed(ctx context.Context, name string) (*config.S
 	return a.client.StopShed(ctx, name)
 }
 
+// PauseShed freezes a running shed container.
+func (a *dockerAPIAdapter) PauseShed(ctx context.Context, name string) (*config.Shed, error) {
+	return a.client.PauseShed(ctx, name)
+}
+
+// ResumeShed unfreezes a paused shed container.
+func (a *dockerAPIAdapter) ResumeShed(ctx context.Context, name string) (*config.Shed, error) {
+	return a.client.ResumeShed(ctx, name)
+}
+
+// WaitForStatus blocks until a shed reaches the given status.
+func (a *dockerAPIAdapter) WaitForStatus(ctx context.Context, name, status string) (*config.Shed, error) {
+	return a.client.WaitForStatus(ctx, name, status)
+}
+
+// ListSessions returns the sessions running inside a shed.
+func (a *dockerAPIAdapter) ListSessions(ctx context.Context, name string) ([]config.Session, error) {
+	return a.client.ListSessions(ctx, name)
+}
+
+// ListPorts returns the TCP ports processes in a running shed are listening on.
+func (a *dockerAPIAdapter) ListPorts(ctx context.Context, name string) ([]config.ListeningPort, error) {
+	return a.client.ListPorts(ctx, name)
+}
+
+// CreateSession starts a detached session in a running shed.
+func (a *dockerAPIAdapter) CreateSession(ctx context.Context, name string, req config.CreateSessionRequest) (*config.Session, error) {
+	return a.client.CreateSession(ctx, name, req)
+}
+
+// RenameSession renames a session in a running shed.
+func (a *dockerAPIAdapter) RenameSession(ctx context.Context, name, session string, req config.RenameSessionRequest) (*config.Session, error) {
+	return a.client.RenameSession(ctx, name, session, req)
+}
+
+// ExecCommand runs a shell command in a running shed. The shed counts as
+// active while it runs.
+func (a *dockerAPIAdapter) ExecCommand(ctx context.Context, name, command string, stdout, stderr io.Writer) (int, error) {
+	end := a.tracker.Begin(name)
+	defer end()
+	return a.client.ExecCommand(ctx, name, command, stdout, stderr)
+}
+
+// KillSession ends a session in a running shed.
+func (a *dockerAPIAdapter) KillSession(ctx context.Context, name, session string) error {
+	return a.client.KillSession(ctx, name, session)
+}
+
+// ExecInSession runs a command in a tmux session and waits for it. The
+// shed counts as in use while it runs.
+func (a *dockerAPIAdapter) ExecInSession(ctx context.Context, name, session, command string) (*config.SessionExecResult, error) {
+	end := a.tracker.Begin(name)
+	defer end()
+	return a.client.ExecInSession(ctx, name, session, command)
+}
+
+// OpenTerminal starts an interactive shell in a running shed. The shed
+// counts as in use until the terminal is closed.
+func (a *dockerAPIAdapter) OpenTerminal(ctx context.Context, name string, req config.TerminalRequest) (api.Terminal, error) {
+	term, err := a.client.OpenTerminal(ctx, name, req)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedTerminal{Terminal: term, end: a.tracker.Begin(name)}, nil
+}
+
+// trackedTerminal ends a shed's activity when its terminal is closed.
+type trackedTerminal struct {
+	*docker.Terminal
+	end  func()
+	once sync.Once
+}
+
+// Close disconnects from the terminal and ends its activity.
+func (t *trackedTerminal) Close() error {
+	t.once.Do(t.end)
+	return t.Terminal.Close()
+}
+
+// GetShedStats returns resource usage and activity for a running shed.
+func (a *dockerAPIAdapter) GetShedStats(ctx context.Context, name string, activity bool) (*config.ShedStats, error) {
+	return a.client.GetShedStats(ctx, name, activity)
+}
+
+// GetWorkspaceDiff returns uncommitted changes in a shed's workspace.
+func (a *dockerAPIAdapter) GetWorkspaceDiff(ctx context.Context, name string, full bool) (*config.WorkspaceDiff, error) {
+	return a.client.GetWorkspaceDiff(ctx, name, full)
+}
+
+// ShedLogs returns a shed's container output.
+func (a *dockerAPIAdapter) ShedLogs(ctx context.Context, name string, opts config.LogOptions) (io.ReadCloser, error) {
+	return a.client.ShedLogs(ctx, name, opts)
+}
+
+// ExportWorkspace returns a tar archive of a shed's workspace.
+func (a *dockerAPIAdapter) ExportWorkspace(ctx context.Context, name string) (io.ReadCloser, error) {
+	return a.client.ExportWorkspace(ctx, name)
+}
+
+// ImportShed creates a shed with its workspace restored from an archive.
+func (a *dockerAPIAdapter) ImportShed(ctx context.Context, req config.CreateShedRequest, archive io.Reader) (*config.Shed, error) {
+	return a.created(a.client.ImportShed(ctx, req, archive))
+}
+
+// CloneShed creates a copy of a shed's workspace and settings.
+func (a *dockerAPIAdapter) CloneShed(ctx context.Context, src, dst string) (*config.Shed, error) {
+	return a.created(a.client.CloneShed(ctx, src, dst))
+}
+
+// UpdateLabels sets and removes a shed's user-defined labels.
+func (a *dockerAPIAdapter) UpdateLabels(ctx context.Context, name string, req config.UpdateLabelsRequest) (*config.Shed, error) {
+	return a.client.UpdateLabels(ctx, name, req)
+}
+
+// UpdateEnv sets and removes a shed's environment variables.
+func (a *dockerAPIAdapter) UpdateEnv(ctx context.Context, name string, req config.UpdateEnvRequest) (*config.Shed, error) {
+	return a.client.UpdateEnv(ctx, name, req)
+}
+
+// ListImages returns the images sheds can be created from.
+func (a *dockerAPIAdapter) ListImages(ctx context.Context) ([]config.Image, error) {
+	return a.client.ListImages(ctx)
+}
+
+// RebuildShed recreates a shed's container, keeping its workspace.
+func (a *dockerAPIAdapter) RebuildShed(ctx context.Context, name string, req config.RebuildShedRequest, progress config.ProgressFunc) (*config.Shed, error) {
+	return a.client.RebuildShed(ctx, name, req, progress)
+}
+
+// BuildImage builds an image from a tar build context.
+func (a *dockerAPIAdapter) BuildImage(ctx context.Context, req config.BuildImageRequest, buildContext io.Reader, output func(string)) (*config.Image, error) {
+	return a.client.BuildImage(ctx, req, buildContext, output)
+}
+
+// PrepullStatus returns the results of scheduled image pulls.
+func (a *dockerAPIAdapter) PrepullStatus() []config.ImagePullStatus {
+	if a.prepuller == nil {
+		return nil
+	}
+	return a.prepuller.Status()
+}
+
+// ImageGCStatus returns the results of image collection.
+func (a *dockerAPIAdapter) ImageGCStatus() *config.ImageGCStatus {
+	if a.imageCollector == nil {
+		return nil
+	}
+	return a.imageCollector.Status()
+}
+
+// DockerHost returns the address of the engine that runs sheds.
+func (a *dockerAPIAdapter) DockerHost() string {
+	return a.client.DockerHost()
+}
+
+// Capabilities reports optional features the runtime supports.
+func (a *dockerAPIAdapter) Capabilities(ctx context.Context) config.ServerCapabilities {
+	return a.client.Capabilities(ctx)
+}
+
+// GetCapacity reports the host's resources, shed counts, and features.
+func (a *dockerAPIAdapter) GetCapacity(ctx context.Context) (*config.ServerCapacity, error) {
+	return a.client.GetCapacity(ctx)
+}
+
+// CreateCheckpoint saves a running shed's process state.
+func (a *dockerAPIAdapter) CreateCheckpoint(ctx context.Context, shedName, name string, leaveRunning bool) (*config.Checkpoint, error) {
+	return a.client.CreateCheckpoint(ctx, shedName, name, leaveRunning)
+}
+
+// ListCheckpoints returns the checkpoints saved for a shed.
+func (a *dockerAPIAdapter) ListCheckpoints(ctx context.Context, shedName string) ([]config.Checkpoint, error) {
+	return a.client.ListCheckpoints(ctx, shedName)
+}
+
+// DeleteCheckpoint removes a saved checkpoint.
+func (a *dockerAPIAdapter) DeleteCheckpoint(ctx context.Context, shedName, name string) error {
+	return a.client.DeleteCheckpoint(ctx, shedName, name)
+}
+
+// RestoreShed starts a stopped shed from a checkpoint.
+func (a *dockerAPIAdapter) RestoreShed(ctx context.Context, shedName, name string) (*config.Shed, error) {
+	return a.client.RestoreShed(ctx, shedName, name)
+}
+
+// ListSchedules returns the schedules of a shed, or of every shed.
+func (a *dockerAPIAdapter) ListSchedules(ctx context.Context, name string) ([]config.Schedule, error) {
+	return a.client.ListSchedules(ctx, name)
+}
+
+// SetSchedule schedules a shed to start, stop, or be backed up.
+func (a *dockerAPIAdapter) SetSchedule(ctx context.Context, name, action, expr string) (*config.Schedule, error) {
+	return a.client.SetSchedule(ctx, name, action, expr)
+}
+
+// DeleteSchedule removes a shed's schedule for an action.
+func (a *dockerAPIAdapter) DeleteSchedule(ctx context.Context, name, action string) error {
+	return a.client.DeleteSchedule(ctx, name, action)
+}
+
+// BackupShed uploads an archive of a shed's workspace to the backup bucket.
+func (a *dockerAPIAdapter) BackupShed(ctx context.Context, name string) (*config.Backup, error) {
+	return a.client.BackupShed(ctx, name)
+}
+
+// ListBackups returns a shed's backups, newest first.
+func (a *dockerAPIAdapter) ListBackups(ctx context.Context, name string) ([]config.Backup, error) {
+	return a.client.ListBackups(ctx, name)
+}
+
+// RestoreBackup replaces a shed's workspace with one of its backups.
+func (a *dockerAPIAdapter) RestoreBackup(ctx context.Context, name, backup string) (*config.Shed, error) {
+	return a.client.RestoreBackup(ctx, name, backup)
+}
+
+// Prune removes shed resources nothing uses, publishing a deleted event for
+// each shed removed.
+func (a *dockerAPIAdapter) Prune(ctx context.Context, req config.PruneRequest) (*config.PruneResponse, error) {
+	resp, err := a.client.Prune(ctx, req)
+	if err != nil || req.DryRun {
+		return resp, err
+	}
+	for _, r := range resp.Resources {
+		if (r.Kind == config.PruneShed || r.Kind == config.PruneContainer) && r.Error == "" {
+			a.events.Publish(config.ShedEvent{Type: config.ShedEventDeleted, Shed: r.Name})
+		}
+	}
+	return resp, nil
+}
+
+// dockerProxyAdapter adapts the docker.Client to the proxy.Resolver interface.
+type dockerProxyAdapter struct {
+	client *docker.Client
+}
+
+// Target returns the address of a running shed's HTTP port.
+func (a *dockerProxyAdapter) Target(ctx context.Context, name string) (string, error) {
+	shed, err := a.client.GetShed(ctx, name)
+	if errors.Is(err, docker.ErrShedNotFound) {
+		return "", proxy.ErrNoRoute
+	}
+	if err != nil {
+		return "", err
+	}
+	if shed.HTTPPort == 0 {
+		return "", proxy.ErrNoRoute
+	}
+	if shed.Status != config.StatusRunning {
+		return "", proxy.ErrNotRunning
+	}
+
+	ip, _, err := a.client.ShedAddress(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(shed.HTTPPort)), nil
+}
+
 // dockerSSHAdapter adapts the docker.Client to the sshd.DockerClient interface.
 type dockerSSHAdapter struct {
 	client *docker.Client
@@ -182,11 +687,17 @@ func (a *dockerSSHAdapter) GetShed(ctx context.Context, name string) (*sshd.Shed
 	if err != nil {
 		return nil, err
 	}
+	env, err := a.client.SessionEnv(ctx, name)
+	if err != nil {
+		return nil, err
+	}
 
 	return &sshd.ShedInfo{
 		Name:        shed.Name,
 		Status:      shed.Status,
 		ContainerID: shed.ContainerID,
+		Forwarding:  shed.Forwarding,
+		Env:         env,
 	}, nil
 }
 
@@ -196,6 +707,51 @@ func (a *dockerSSHAdapter) StartShed(ctx context.Context, name string) error {
 	return err
 }
 
+// ResumeShed unfreezes a paused shed.
+func (a *dockerSSHAdapter) ResumeShed(ctx context.Context, name string) error {
+	_, err := a.client.ResumeShed(ctx, name)
+	return err
+}
+
+// ShedAddress returns a running shed's IP address and network gateway.
+func (a *dockerSSHAdapter) ShedAddress(ctx context.Context, name string) (string, string, error) {
+	return a.client.ShedAddress(ctx, name)
+}
+
+// WaitForStatus blocks until a shed reaches the given status.
+func (a *dockerSSHAdapter) WaitForStatus(ctx context.Context, name, status string) error {
+	_, err := a.client.WaitForStatus(ctx, name, status)
+	return err
+}
+
+// StatPath returns the mode of a path in a container.
+func (a *dockerSSHAdapter) StatPath(ctx context.Context, containerID, path string) (fs.FileMode, error) {
+	stat, err := a.client.Docker().ContainerStatPath(ctx, containerID, path)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return 0, fs.ErrNotExist
+		}
+		return 0, err
+	}
+	return stat.Mode, nil
+}
+
+// CopyFromContainer returns a tar archive of a path in a container.
+func (a *dockerSSHAdapter) CopyFromContainer(ctx context.Context, containerID, path string) (io.ReadCloser, error) {
+	archive, _, err := a.client.Docker().CopyFromContainer(ctx, containerID, path)
+	if cerrdefs.IsNotFound(err) {
+		return nil, fs.ErrNotExist
+	}
+	return archive, err
+}
+
+// CopyToContainer extracts a tar archive into dir in a container.
+func (a *dockerSSHAdapter) CopyToContainer(ctx context.Context, containerID, dir string, content io.Reader) error {
+	return a.client.Docker().CopyToContainer(ctx, containerID, dir, content, container.CopyToContainerOptions{
+		CopyUIDGID: true,
+	})
+}
+
 // ExecInContainer executes a command in a container with the given options.
 func (a *dockerSSHAdapter) ExecInContainer(ctx context.Context, containerID string, opts sshd.ExecOptions) error {
 	dockerClient := a.client.Docker()
@@ -205,6 +761,13 @@ func (a *dockerSSHAdapter) ExecInContainer(ctx context.Context, containerID stri
 	if len(cmd) == 0 {
 		cmd = []string{"/bin/bash", "--login"}
 	}
+	var pidFile string
+	if opts.KillOnCancel {
+		var err error
+		if cmd, pidFile, err = docker.TrackExec(cmd); err != nil {
+			return err
+		}
+	}
 
 	// Create exec configuration
 	execConfig := container.ExecOptions{
@@ -231,6 +794,16 @@ func (a *dockerSSHAdapter) ExecInContainer(ctx context.Context, containerID stri
 	}
 	defer attachResp.Close()
 
+	// When the client goes away, stop reading and kill the command rather
+	// than leave it running
+	stop := context.AfterFunc(ctx, func() {
+		attachResp.Close()
+		if pidFile != "" {
+			a.client.KillExec(containerID, pidFile)
+		}
+	})
+	defer stop()
+
 	// Handle terminal resize if TTY is enabled
 	if opts.TTY && opts.ResizeChan != nil {
 		go func() {
@@ -268,18 +841,7 @@ func (a *dockerSSHAdapter) ExecInContainer(ctx context.Context, containerID stri
 	// Copy container output to stdout - when this finishes, container has exited
 	go func() {
 		defer close(done)
-		if opts.TTY {
-			// In TTY mode, all output goes to stdout
-			if opts.Stdout != nil {
-				_, _ = io.Copy(opts.Stdout, attachResp.Reader)
-			}
-		} else {
-			// In non-TTY mode, we need to demux stdout/stderr
-			// For simplicity, we'll just copy everything to stdout
-			if opts.Stdout != nil {
-				_, _ = io.Copy(opts.Stdout, attachResp.Reader)
-			}
-		}
+		_ = docker.CopyExecOutput(attachResp.Reader, opts.TTY, opts.Stdout, opts.Stderr)
 	}()
 
 	// Wait only for stdout to complete (container exit), not stdin
@@ -292,7 +854,7 @@ func (a *dockerSSHAdapter) ExecInContainer(ctx context.Context, containerID stri
 	}
 
 	if inspectResp.ExitCode != 0 {
-		return fmt.Errorf("command exited with code %d", inspectResp.ExitCode)
+		return &sshd.ExitError{Code: inspectResp.ExitCode}
 	}
 
 	return nil
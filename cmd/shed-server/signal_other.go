@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyLevelToggle does nothing where there is no SIGUSR1; the log level
+// is then only set by the config.
+func notifyLevelToggle(c chan<- os.Signal) {}
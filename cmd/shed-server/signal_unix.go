@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyLevelToggle relays SIGUSR1, which toggles debug logging, to c.
+func notifyLevelToggle(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
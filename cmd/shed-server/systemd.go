@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// systemdUnitPath is where the systemd unit file will be installed
+const systemdUnitPath = "/etc/systemd/system/shed-server.service"
+
+// systemdInstaller installs shed-server as a systemd unit.
+type systemdInstaller struct{}
+
+func (systemdInstaller) name() string      { return "systemd" }
+func (systemdInstaller) path() string      { return systemdUnitPath }
+func (systemdInstaller) mode() os.FileMode { return 0644 }
+
+// generate generates the systemd unit file content.
+func (systemdInstaller) generate(u *user.User, engine engineService) string {
+	template := `[Unit]
+Description=Shed Development Environment Server
+After=network.target{after}{requires}
+
+[Service]
+Type=simple
+User={user}
+Group={group}
+ExecStart={binary} serve
+Restart=on-failure
+RestartSec=5
+Environment=HOME={home}
+
+[Install]
+WantedBy=multi-user.target
+`
+
+	// Replace placeholders
+	content := template
+	after, requires := "", ""
+	if engine.name != "" {
+		after = " " + engine.name + ".service"
+		requires = "\nRequires=" + engine.name + ".service"
+	}
+	content = strings.ReplaceAll(content, "{after}", after)
+	content = strings.ReplaceAll(content, "{requires}", requires)
+	content = strings.ReplaceAll(content, "{user}", u.Username)
+	content = strings.ReplaceAll(content, "{group}", groupName(u))
+	content = strings.ReplaceAll(content, "{binary}", defaultBinaryPath)
+	content = strings.ReplaceAll(content, "{home}", u.HomeDir)
+
+	return content
+}
+
+func (systemdInstaller) enable() error {
+	// Run systemctl daemon-reload
+	if err := runSystemctl("daemon-reload"); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	fmt.Println("Reloaded systemd daemon")
+
+	// Enable the service
+	if err := runSystemctl("enable", serviceName); err != nil {
+		return fmt.Errorf("failed to enable service: %w", err)
+	}
+	fmt.Println("Enabled shed-server service")
+	return nil
+}
+
+func (systemdInstaller) disable() {
+	// Stop the service (ignore errors - service might not be running)
+	fmt.Println("Stopping shed-server service...")
+	if err := runSystemctl("stop", serviceName); err != nil {
+		fmt.Printf("Note: Could not stop service (may not be running): %v\n", err)
+	}
+
+	// Disable the service (ignore errors - service might not be enabled)
+	fmt.Println("Disabling shed-server service...")
+	if err := runSystemctl("disable", serviceName); err != nil {
+		fmt.Printf("Note: Could not disable service (may not be enabled): %v\n", err)
+	}
+}
+
+func (systemdInstaller) cleanup() error {
+	// Reload systemd daemon
+	if err := runSystemctl("daemon-reload"); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	fmt.Println("Reloaded systemd daemon")
+	return nil
+}
+
+func (systemdInstaller) instructions() []string {
+	return []string{
+		"To start the service now, run:\n  sudo systemctl start shed-server",
+		"To check the service status:\n  sudo systemctl status shed-server",
+		"To view logs:\n  sudo journalctl -u shed-server -f",
+	}
+}
+
+// runSystemctl runs a systemctl command with the given arguments.
+func runSystemctl(args ...string) error {
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
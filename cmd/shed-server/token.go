@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/apitoken"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage HTTP API tokens",
+	Long: `Create, list, and revoke bearer tokens for the HTTP API.
+
+Once any token exists, every API request must send one in an
+Authorization header. Tokens take effect without restarting the server.
+Add a token to the CLI with: shed server add <host> --token <token>`,
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new API token",
+	Long: `Create a new API token and print it.
+
+The token is only shown once; the server keeps a hash of it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTokenCreate,
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API tokens",
+	Args:  cobra.NoArgs,
+	RunE:  runTokenList,
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke an API token",
+	Long: `Revoke an API token created with 'shed-server token create'.
+
+Tokens configured in server.yaml must be removed from the file instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTokenRevoke,
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+}
+
+// loadTokenStore loads the token store for the configured server.
+func loadTokenStore() (*apitoken.Store, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return apitoken.NewStore(cfg.StateDir, cfg.APITokens), nil
+}
+
+func runTokenCreate(cmd *cobra.Command, args []string) error {
+	store, err := loadTokenStore()
+	if err != nil {
+		return err
+	}
+
+	token, err := store.Create(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created token %s:\n\n  %s\n\n", args[0], token)
+	fmt.Println("This token will not be shown again. Add it to the CLI with:")
+	fmt.Printf("  shed server add <host> --token %s\n", token)
+	return nil
+}
+
+func runTokenList(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	store := apitoken.NewStore(cfg.StateDir, cfg.APITokens)
+
+	tokens, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 && len(cfg.APITokens) == 0 {
+		fmt.Println("No API tokens. The HTTP API is unauthenticated.")
+		fmt.Println("\nTo create a token:")
+		fmt.Println("  shed-server token create <name>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSOURCE\tCREATED")
+	for _, t := range cfg.APITokens {
+		fmt.Fprintf(w, "%s\tserver.yaml\t-\n", t.Name)
+	}
+	for _, t := range tokens {
+		fmt.Fprintf(w, "%s\tminted\t%s\n", t.Name, t.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+	return nil
+}
+
+func runTokenRevoke(cmd *cobra.Command, args []string) error {
+	store, err := loadTokenStore()
+	if err != nil {
+		return err
+	}
+
+	if err := store.Revoke(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked token %s\n", args[0])
+	return nil
+}
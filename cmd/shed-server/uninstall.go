@@ -9,11 +9,13 @@ import (
 
 var uninstallCmd = &cobra.Command{
 	Use:   "uninstall",
-	Short: "Remove shed-server systemd service",
-	Long: `Remove the shed-server systemd service.
+	Short: "Remove the shed-server system service",
+	Long: `Remove the shed-server system service.
 
-This command stops the service, disables it, removes the unit file,
-and reloads the systemd daemon. Requires root privileges.`,
+This command stops and disables the service, removes its service file, and
+tells the init system. The init system is detected as for install, or can
+be chosen with --init. Requires root privileges.`,
+	Args: cobra.NoArgs,
 	RunE: runUninstall,
 }
 
@@ -23,35 +25,28 @@ func runUninstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("this command must be run as root (try: sudo shed-server uninstall)")
 	}
 
-	fmt.Println("Uninstalling shed-server systemd service...")
-
-	// Stop the service (ignore errors - service might not be running)
-	fmt.Println("Stopping shed-server service...")
-	if err := runSystemctl("stop", "shed-server"); err != nil {
-		fmt.Printf("Note: Could not stop service (may not be running): %v\n", err)
+	inst, err := selectInstaller()
+	if err != nil {
+		return err
 	}
 
-	// Disable the service (ignore errors - service might not be enabled)
-	fmt.Println("Disabling shed-server service...")
-	if err := runSystemctl("disable", "shed-server"); err != nil {
-		fmt.Printf("Note: Could not disable service (may not be enabled): %v\n", err)
-	}
+	fmt.Printf("Uninstalling shed-server %s service...\n", inst.name())
+
+	inst.disable()
 
-	// Remove the unit file
-	if _, err := os.Stat(systemdUnitPath); err == nil {
-		if err := os.Remove(systemdUnitPath); err != nil {
-			return fmt.Errorf("failed to remove unit file: %w", err)
+	// Remove the service file
+	if _, err := os.Stat(inst.path()); err == nil {
+		if err := os.Remove(inst.path()); err != nil {
+			return fmt.Errorf("failed to remove service file: %w", err)
 		}
-		fmt.Printf("Removed systemd unit file: %s\n", systemdUnitPath)
+		fmt.Printf("Removed %s service file: %s\n", inst.name(), inst.path())
 	} else {
-		fmt.Println("Note: Unit file does not exist")
+		fmt.Println("Note: Service file does not exist")
 	}
 
-	// Reload systemd daemon
-	if err := runSystemctl("daemon-reload"); err != nil {
-		return fmt.Errorf("failed to reload systemd: %w", err)
+	if err := inst.cleanup(); err != nil {
+		return err
 	}
-	fmt.Println("Reloaded systemd daemon")
 
 	fmt.Println()
 	fmt.Println("Uninstallation complete!")
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach <name>",
+	Short: "Attach to a tmux or zellij session in a shed",
+	Long: `Attach to a persistent tmux session in a shed, or a zellij session if
+the shed's image has zellij but not tmux.
+
+Without --session, shed reattaches to the session you last attached to
+in this shed if it still exists. Otherwise the existing sessions are
+listed and you can pick one or enter a new name to create it. If the
+shed has no sessions, the "default" session is created. Use --last to
+attach to the most recently active session without prompting.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAttach,
+}
+
+var (
+	attachSession string
+	attachLast    bool
+)
+
+func init() {
+	attachCmd.Flags().StringVarP(&attachSession, "session", "S", "", "Session name to attach to or create")
+	attachCmd.Flags().BoolVar(&attachLast, "last", false, "Attach to the most recently active session")
+	attachCmd.Flags().BoolVar(&startFlag, "start", false, "Start or resume the shed first if it isn't running")
+
+	rootCmd.AddCommand(attachCmd)
+}
+
+func runAttach(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if attachSession != "" && attachLast {
+		return fmt.Errorf("--session and --last cannot be used together")
+	}
+
+	if attachSession != "" {
+		if err := config.ValidateSessionName(attachSession); err != nil {
+			return err
+		}
+	}
+
+	serverName, entry, err := findShedToConnect(name)
+	if err != nil {
+		return err
+	}
+
+	return attachShedOn(name, serverName, entry, attachSession)
+}
+
+// attachShedOn attaches to a session in a running shed on a known
+// server, choosing one if session is empty.
+func attachShedOn(name, serverName string, entry *config.ServerEntry, session string) error {
+	if session == "" {
+		client := NewAPIClientFromEntry(entry)
+		resp, err := client.ListSessions(name)
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+
+		session = rememberedSession(name, resp.Sessions)
+		if session == "" {
+			session, err = chooseSession(name, resp.Sessions)
+			if err != nil {
+				return err
+			}
+			if session == "" {
+				fmt.Println("Cancelled.")
+				return nil
+			}
+		}
+	}
+
+	// Remember the session before ssh replaces this process
+	clientConfig.SetLastSession(name, session)
+	if err := clientConfig.Save(); err != nil {
+		if verboseFlag {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		}
+	}
+
+	// ssh sends the command as one string split by the server, so quote it
+	return sshToShedOn(name, serverName, entry, []string{shellJoin(config.SessionAttachCommand(session))})
+}
+
+// rememberedSession returns the last session attached to in a shed if it is
+// still running, or an empty string. --last takes precedence over memory.
+func rememberedSession(shedName string, sessions []config.Session) string {
+	if attachLast {
+		return ""
+	}
+
+	last := clientConfig.GetLastSession(shedName)
+	if last == "" {
+		return ""
+	}
+
+	for _, s := range sessions {
+		if s.Name == last {
+			return last
+		}
+	}
+	return ""
+}
+
+// chooseSession selects the session to attach to. Sessions are expected to be
+// ordered most recently active first, as returned by the server. An empty
+// result means the user cancelled.
+func chooseSession(shedName string, sessions []config.Session) (string, error) {
+	if len(sessions) == 0 {
+		return config.DefaultSessionName, nil
+	}
+
+	if attachLast {
+		return sessions[0].Name, nil
+	}
+
+	// Without a terminal there is no one to ask; keep the previous behavior
+	if !isInteractive() {
+		return config.DefaultSessionName, nil
+	}
+
+	fmt.Printf("Sessions in %s:\n\n", shedName)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  #\tNAME\tSTATE\tWINDOWS\tLAST ACTIVITY\tPANES")
+	for i, s := range sessions {
+		state := "detached"
+		if s.Attached {
+			state = "attached"
+		}
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%d\t%s\t%s\n",
+			i+1, s.Name, state, s.Windows, formatAgo(s.LastActivity), strings.Join(s.Panes, ", "))
+	}
+	w.Flush()
+
+	fmt.Printf("\nSelect a session number, or enter a new name to create one [1]: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	response, err := reader.ReadString('\n')
+	if err != nil && response == "" {
+		return "", nil
+	}
+	response = strings.TrimSpace(response)
+
+	if response == "" {
+		return sessions[0].Name, nil
+	}
+
+	if n, err := strconv.Atoi(response); err == nil {
+		if n < 1 || n > len(sessions) {
+			return "", fmt.Errorf("invalid selection %d: choose 1-%d", n, len(sessions))
+		}
+		return sessions[n-1].Name, nil
+	}
+
+	if err := config.ValidateSessionName(response); err != nil {
+		return "", err
+	}
+	return response, nil
+}
+
+// isInteractive reports whether stdin is attached to a terminal.
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// isTerminalOutput reports whether stdout is attached to a terminal.
+func isTerminalOutput() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatAgo formats a timestamp as a short relative duration (e.g. "5m ago").
+func formatAgo(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
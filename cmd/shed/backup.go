@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up shed workspaces to object storage",
+	Long: `Back up a shed's workspace to the S3-compatible bucket configured on its
+server, as a compressed archive.
+
+Servers can back up every shed on their backups.schedule, and individual
+sheds on their own schedule:
+
+  shed schedule set dev backup "0 3 * * *"
+
+Old backups are removed as the server's retention settings say. shed info
+shows when a shed was last backed up and whether its latest backup failed.
+Restore a shed from one of its backups with shed restore.`,
+}
+
+var backupNowCmd = &cobra.Command{
+	Use:   "now <name>",
+	Short: "Back up a shed now",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBackupNow,
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list <name>",
+	Short: "List a shed's backups, newest first",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBackupList,
+}
+
+func init() {
+	backupCmd.AddCommand(backupNowCmd)
+	backupCmd.AddCommand(backupListCmd)
+
+	rootCmd.AddCommand(backupCmd)
+}
+
+func runBackupNow(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	if verboseFlag {
+		fmt.Printf("Backing up shed %s on %s...\n", name, serverName)
+	}
+
+	backup, err := NewAPIClientFromEntry(entry).BackupShed(name)
+	if err != nil {
+		return fmt.Errorf("failed to back up shed: %w", err)
+	}
+
+	if ok, err := printStructured(backup); ok {
+		return err
+	}
+
+	printSuccess("Backed up %s as %s (%s)", name, backup.Name, formatBytes(backup.Size))
+	return nil
+}
+
+func runBackupList(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	_, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := NewAPIClientFromEntry(entry).ListBackups(name)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	if ok, err := printStructured(resp.Backups); ok {
+		return err
+	}
+
+	if len(resp.Backups) == 0 {
+		fmt.Printf("No backups found for %s.\n", name)
+		fmt.Println("\nTo back it up:")
+		fmt.Printf("  shed backup now %s\n", name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tSIZE")
+	for _, b := range resp.Backups {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", b.Name, b.CreatedAt.Local().Format("2006-01-02 15:04"), formatBytes(b.Size))
+	}
+	w.Flush()
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint <name>",
+	Short: "Save a running shed's process state",
+	Long: `Checkpoint a running shed's processes to disk with CRIU.
+
+The shed is stopped once checkpointed unless --leave-running is set. Start it
+again with its in-memory state (REPLs, warmed dev servers) intact using:
+
+  shed start <name> --from-checkpoint <checkpoint>
+
+Checkpointing needs a server whose Docker daemon has experimental features
+enabled, which shed server status shows, and CRIU installed on the daemon's
+host, which the first checkpoint reports if it is missing.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCheckpoint,
+}
+
+var checkpointListCmd = &cobra.Command{
+	Use:   "list <name>",
+	Short: "List a shed's checkpoints",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCheckpointList,
+}
+
+var checkpointDeleteCmd = &cobra.Command{
+	Use:   "delete <name> <checkpoint>",
+	Short: "Delete a checkpoint",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runCheckpointDelete,
+}
+
+var (
+	checkpointName         string
+	checkpointLeaveRunning bool
+)
+
+func init() {
+	checkpointCmd.Flags().StringVarP(&checkpointName, "name", "n", "", "Checkpoint name (default: timestamp)")
+	checkpointCmd.Flags().BoolVar(&checkpointLeaveRunning, "leave-running", false, "Keep the shed running after checkpointing")
+
+	checkpointCmd.AddCommand(checkpointListCmd)
+	checkpointCmd.AddCommand(checkpointDeleteCmd)
+
+	rootCmd.AddCommand(checkpointCmd)
+}
+
+func runCheckpoint(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	serverName, entry, err := findRunningShed(name)
+	if err != nil {
+		return err
+	}
+
+	if verboseFlag {
+		fmt.Printf("Checkpointing shed %s on %s...\n", name, serverName)
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	if !checkpointLeaveRunning {
+		noteHistory(name, serverName, shedCommand("start", name))
+	}
+	cp, err := client.CreateCheckpoint(name, &config.CreateCheckpointRequest{
+		Name:         checkpointName,
+		LeaveRunning: checkpointLeaveRunning,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint shed: %w", err)
+	}
+
+	printSuccess("Checkpointed shed %s as %s", name, cp.Name)
+	fmt.Printf("\nRestore with:\n  shed start %s --from-checkpoint %s\n", name, cp.Name)
+
+	return nil
+}
+
+func runCheckpointList(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	_, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	resp, err := client.ListCheckpoints(name)
+	if err != nil {
+		return fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	if ok, err := printStructured(resp.Checkpoints); ok {
+		return err
+	}
+
+	if len(resp.Checkpoints) == 0 {
+		fmt.Printf("No checkpoints found for %s.\n", name)
+		return nil
+	}
+
+	for _, cp := range resp.Checkpoints {
+		fmt.Println(cp.Name)
+	}
+
+	return nil
+}
+
+func runCheckpointDelete(cmd *cobra.Command, args []string) error {
+	name, checkpoint := args[0], args[1]
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	noteHistory(name, serverName, "")
+	if err := client.DeleteCheckpoint(name, checkpoint); err != nil {
+		return fmt.Errorf("failed to delete checkpoint: %w", err)
+	}
+
+	printSuccess("Deleted checkpoint %s from %s", checkpoint, name)
+	return nil
+}
@@ -1,60 +1,136 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/version"
+)
+
+// maxConnectTimeout bounds how long a client waits to connect to a server,
+// so an unreachable server fails fast even when requests may take longer.
+const maxConnectTimeout = 10 * time.Second
+
+// Backoff between retries of a failed request: the first retry waits
+// retryBaseDelay, doubling for each one after up to retryMaxDelay.
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
 )
 
 // APIClient provides methods for interacting with the shed server API.
 type APIClient struct {
 	baseURL    string
+	token      string
 	httpClient *http.Client
+
+	// retries is how many times a failed idempotent request is retried
+	retries int
 }
 
-// NewAPIClient creates a new API client for the given host and port.
+// NewAPIClient creates a new API client for the given host and port. IPv6
+// addresses may be given with or without brackets.
 func NewAPIClient(host string, port int) *APIClient {
+	// A zone, as in fe80::1%eth0, is escaped in URLs
+	addr := strings.ReplaceAll(config.HostPort(host, port), "%", "%25")
 	return &APIClient{
-		baseURL: fmt.Sprintf("http://%s:%d", host, port),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		baseURL:    "http://" + addr,
+		httpClient: newHTTPClient(config.DefaultRequestTimeout),
+		retries:    config.DefaultRequestRetries,
 	}
 }
 
-// NewAPIClientFromEntry creates a new API client from a server entry.
+// NewAPIClientFromEntry creates a new API client from a server entry,
+// using its timeout and retry settings.
 func NewAPIClientFromEntry(entry *config.ServerEntry) *APIClient {
-	return NewAPIClient(entry.Host, entry.HTTPPort)
+	c := NewAPIClient(entry.Host, entry.HTTPPort)
+	c.token = entry.Token
+	c.httpClient = newHTTPClient(entry.RequestTimeout())
+	c.retries = entry.RequestRetries()
+	return c
+}
+
+// newHTTPClient returns an HTTP client whose requests time out after
+// timeout, and whose connections time out after at most maxConnectTimeout.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   min(timeout, maxConnectTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// setHeaders adds the client's version, and its API token if it has one,
+// to a request.
+func (c *APIClient) setHeaders(req *http.Request) {
+	req.Header.Set(config.HeaderClientVersion, version.Version)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
 }
 
 // doRequest performs an HTTP request with JSON body and response handling.
 // It handles connection errors, status code validation, and JSON decoding.
+// Idempotent requests that fail to connect, time out, or get a gateway
+// error are retried with exponential backoff.
 func (c *APIClient) doRequest(method, path string, body, result interface{}, expectedStatus ...int) error {
-	var bodyReader io.Reader
+	var bodyData []byte
 	if body != nil {
-		bodyData, err := json.Marshal(body)
+		var err error
+		bodyData, err = json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("failed to encode request: %w", err)
 		}
-		bodyReader = bytes.NewReader(bodyData)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+path, bodyReader)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
+	retries := 0
+	if idempotent(method) {
+		retries = c.retries
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to connect to server: %w", err)
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(bodyData)
+		}
+		req, err := http.NewRequest(method, c.baseURL+path, bodyReader)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		c.setHeaders(req)
+
+		resp, err = c.httpClient.Do(req)
+		if err == nil && !retryableStatus(resp) {
+			break
+		}
+		if attempt >= retries {
+			if err != nil {
+				return requestError(err, c.httpClient.Timeout)
+			}
+			break
+		}
+		if err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		time.Sleep(retryDelay(attempt))
 	}
 	defer resp.Body.Close()
 
@@ -77,6 +153,9 @@ func (c *APIClient) doRequest(method, path string, body, result interface{}, exp
 	// Decode result if provided
 	if result != nil {
 		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			if isTimeout(err) {
+				return requestError(err, c.httpClient.Timeout)
+			}
 			return fmt.Errorf("failed to parse response: %w", err)
 		}
 	}
@@ -84,28 +163,97 @@ func (c *APIClient) doRequest(method, path string, body, result interface{}, exp
 	return nil
 }
 
+// idempotent reports whether a request with method can safely be sent
+// again after a failure.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryableStatus reports whether a response status means a proxy in front
+// of the server couldn't reach it, which may pass. The same statuses from
+// the server itself, which sets its API version on every response, are
+// answers such as a wait timing out and aren't retried.
+func retryableStatus(resp *http.Response) bool {
+	if resp.Header.Get(config.HeaderAPIVersion) != "" {
+		return false
+	}
+	code := resp.StatusCode
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// retryDelay returns how long to wait before retry attempt+1.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay <= 0 || delay > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return delay
+}
+
+// requestError describes a failed request, telling a server that couldn't
+// be connected to apart from one that stopped responding. timeout is the
+// request's time limit, or zero if it has none.
+func requestError(err error, timeout time.Duration) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		if opErr.Timeout() {
+			return fmt.Errorf("timed out connecting to server: %w", err)
+		}
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	if isTimeout(err) && timeout > 0 {
+		return fmt.Errorf("server did not respond within %s (raise the server's timeout in %s): %w", timeout, config.GetClientConfigPath(), err)
+	}
+	return fmt.Errorf("failed to connect to server: %w", err)
+}
+
+// isTimeout reports whether err is a network timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 // GetInfo retrieves server information.
 func (c *APIClient) GetInfo() (*config.ServerInfo, error) {
 	var info config.ServerInfo
-	if err := c.doRequest(http.MethodGet, "/api/info", nil, &info); err != nil {
+	if err := c.doRequest(http.MethodGet, "/api/v1/info", nil, &info); err != nil {
 		return nil, err
 	}
 	return &info, nil
 }
 
+// GetCapacity retrieves the server's resources and current load.
+func (c *APIClient) GetCapacity() (*config.ServerCapacity, error) {
+	var capacity config.ServerCapacity
+	if err := c.doRequest(http.MethodGet, "/api/v1/server/capacity", nil, &capacity); err != nil {
+		return nil, err
+	}
+	return &capacity, nil
+}
+
 // GetSSHHostKey retrieves the server's SSH host key.
 func (c *APIClient) GetSSHHostKey() (*config.SSHHostKeyResponse, error) {
 	var hostKey config.SSHHostKeyResponse
-	if err := c.doRequest(http.MethodGet, "/api/ssh-host-key", nil, &hostKey); err != nil {
+	if err := c.doRequest(http.MethodGet, "/api/v1/ssh-host-key", nil, &hostKey); err != nil {
 		return nil, err
 	}
 	return &hostKey, nil
 }
 
-// ListSheds retrieves all sheds from the server.
-func (c *APIClient) ListSheds() (*config.ShedsResponse, error) {
+// ListSheds retrieves all sheds from the server, or those with every
+// label given as key=value.
+func (c *APIClient) ListSheds(labels ...string) (*config.ShedsResponse, error) {
+	path := "/api/v1/sheds"
+	if len(labels) > 0 {
+		path += "?" + url.Values{"label": labels}.Encode()
+	}
+
 	var sheds config.ShedsResponse
-	if err := c.doRequest(http.MethodGet, "/api/sheds", nil, &sheds); err != nil {
+	if err := c.doRequest(http.MethodGet, path, nil, &sheds); err != nil {
 		return nil, err
 	}
 	return &sheds, nil
@@ -114,16 +262,212 @@ func (c *APIClient) ListSheds() (*config.ShedsResponse, error) {
 // CreateShed creates a new shed.
 func (c *APIClient) CreateShed(req *config.CreateShedRequest) (*config.Shed, error) {
 	var shed config.Shed
-	if err := c.doRequest(http.MethodPost, "/api/sheds", req, &shed, http.StatusCreated, http.StatusOK); err != nil {
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds", req, &shed, http.StatusCreated, http.StatusOK); err != nil {
 		return nil, err
 	}
 	return &shed, nil
 }
 
+// CreateShedAsync starts creating a shed in the background and returns the
+// job tracking it.
+func (c *APIClient) CreateShedAsync(req *config.CreateShedRequest) (*config.Job, error) {
+	var job config.Job
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds?async=true", req, &job, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs retrieves the server's running and recently finished jobs.
+func (c *APIClient) ListJobs() (*config.JobsResponse, error) {
+	var jobs config.JobsResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/jobs", nil, &jobs); err != nil {
+		return nil, err
+	}
+	return &jobs, nil
+}
+
+// GetJob retrieves a job by ID.
+func (c *APIClient) GetJob(id string) (*config.Job, error) {
+	var job config.Job
+	if err := c.doRequest(http.MethodGet, "/api/v1/jobs/"+id, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CreateShedWithProgress creates a new shed, calling onProgress for each
+// creation phase the server reports. Servers that don't stream progress
+// reply with the shed directly and onProgress is never called.
+func (c *APIClient) CreateShedWithProgress(req *config.CreateShedRequest, onProgress func(config.CreateProgress)) (*config.Shed, error) {
+	return c.doProgressRequest("/api/v1/sheds", req, onProgress)
+}
+
+// RebuildShed recreates a shed's container, keeping its workspace, and
+// calls onProgress for each phase the server reports.
+func (c *APIClient) RebuildShed(name string, req *config.RebuildShedRequest, onProgress func(config.CreateProgress)) (*config.Shed, error) {
+	return c.doProgressRequest("/api/v1/sheds/"+name+"/rebuild", req, onProgress)
+}
+
+// doProgressRequest POSTs body to a shed endpoint that streams progress
+// events, calling onProgress for each until the shed is returned. Servers
+// that don't stream reply with the shed directly.
+func (c *APIClient) doProgressRequest(path string, body interface{}, onProgress func(config.CreateProgress)) (*config.Shed, error) {
+	bodyData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(bodyData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	c.setHeaders(httpReq)
+
+	// Image pulls and clones can take a while, so only the stream's
+	// progress bounds the request
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(httpReq)
+	if err != nil {
+		return nil, requestError(err, 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, c.parseError(resp)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var shed config.Shed
+		if err := json.NewDecoder(resp.Body).Decode(&shed); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		return &shed, nil
+	}
+
+	var shed *config.Shed
+	err = readEvents(resp.Body, func(event string, data []byte) error {
+		switch event {
+		case config.EventProgress:
+			var p config.CreateProgress
+			if err := json.Unmarshal(data, &p); err == nil {
+				onProgress(p)
+			}
+		case config.EventDone:
+			shed = &config.Shed{}
+			if err := json.Unmarshal(data, shed); err != nil {
+				return fmt.Errorf("failed to parse response: %w", err)
+			}
+		case config.EventError:
+			var apiErr config.APIError
+			if err := json.Unmarshal(data, &apiErr); err != nil {
+				return fmt.Errorf("failed to parse error: %w", err)
+			}
+			return fmt.Errorf("%s: %s", apiErr.Error.Code, apiErr.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shed == nil {
+		return nil, fmt.Errorf("server closed the stream before the shed was ready")
+	}
+	return shed, nil
+}
+
+// readEvents reads a server-sent event stream, calling handle with each
+// event's name and data until the stream ends or handle returns an error.
+func readEvents(r io.Reader, handle func(event string, data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event string
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event != "" || len(data) > 0 {
+				if err := handle(event, data); err != nil {
+					return err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event stream: %w", err)
+	}
+	return nil
+}
+
+// StreamEvents follows the server's shed events, calling handle with each
+// one until the stream ends or handle returns an error. If shed is set,
+// only that shed's events are sent.
+func (c *APIClient) StreamEvents(shed string, handle func(config.ShedEvent) error) error {
+	path := "/api/v1/events"
+	if shed != "" {
+		path += "?" + url.Values{"shed": {shed}}.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.setHeaders(req)
+
+	// Events stream indefinitely, so the request has no overall timeout
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return requestError(err, 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+
+	return readEvents(resp.Body, func(_ string, data []byte) error {
+		var ev config.ShedEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("failed to parse event: %w", err)
+		}
+		return handle(ev)
+	})
+}
+
+// SearchSheds retrieves sheds whose metadata matches a query.
+// If fields is empty, all searchable fields are matched.
+func (c *APIClient) SearchSheds(query string, fields []string) (*config.ShedsResponse, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	if len(fields) > 0 {
+		params.Set("fields", strings.Join(fields, ","))
+	}
+
+	var sheds config.ShedsResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/search?"+params.Encode(), nil, &sheds); err != nil {
+		return nil, err
+	}
+	return &sheds, nil
+}
+
 // GetShed retrieves a specific shed by name.
 func (c *APIClient) GetShed(name string) (*config.Shed, error) {
 	var shed config.Shed
-	if err := c.doRequest(http.MethodGet, "/api/sheds/"+name, nil, &shed); err != nil {
+	if err := c.doRequest(http.MethodGet, "/api/v1/sheds/"+name, nil, &shed); err != nil {
 		return nil, err
 	}
 	return &shed, nil
@@ -131,7 +475,7 @@ func (c *APIClient) GetShed(name string) (*config.Shed, error) {
 
 // DeleteShed deletes a shed.
 func (c *APIClient) DeleteShed(name string, keepVolume bool) error {
-	path := "/api/sheds/" + name
+	path := "/api/v1/sheds/" + name
 	if keepVolume {
 		path += "?keep_volume=true"
 	}
@@ -141,7 +485,76 @@ func (c *APIClient) DeleteShed(name string, keepVolume bool) error {
 // StartShed starts a stopped shed.
 func (c *APIClient) StartShed(name string) (*config.Shed, error) {
 	var shed config.Shed
-	if err := c.doRequest(http.MethodPost, "/api/sheds/"+name+"/start", nil, &shed); err != nil {
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/start", nil, &shed); err != nil {
+		return nil, err
+	}
+	return &shed, nil
+}
+
+// RestoreShed starts a stopped shed from a checkpoint.
+func (c *APIClient) RestoreShed(name, checkpoint string) (*config.Shed, error) {
+	var shed config.Shed
+	path := "/api/v1/sheds/" + name + "/start?checkpoint=" + url.QueryEscape(checkpoint)
+	if err := c.doRequest(http.MethodPost, path, nil, &shed); err != nil {
+		return nil, err
+	}
+	return &shed, nil
+}
+
+// CreateCheckpoint saves a running shed's process state.
+func (c *APIClient) CreateCheckpoint(name string, req *config.CreateCheckpointRequest) (*config.Checkpoint, error) {
+	var cp config.Checkpoint
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/checkpoints", req, &cp, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// ListCheckpoints retrieves the checkpoints saved for a shed.
+func (c *APIClient) ListCheckpoints(name string) (*config.CheckpointsResponse, error) {
+	var resp config.CheckpointsResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/sheds/"+name+"/checkpoints", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteCheckpoint removes a saved checkpoint.
+func (c *APIClient) DeleteCheckpoint(name, checkpoint string) error {
+	return c.doRequest(http.MethodDelete, "/api/v1/sheds/"+name+"/checkpoints/"+checkpoint, nil, nil, http.StatusNoContent, http.StatusOK)
+}
+
+// BackupShed backs up a shed's workspace to the server's backup bucket.
+func (c *APIClient) BackupShed(name string) (*config.Backup, error) {
+	var backup config.Backup
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/backups", nil, &backup, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+// ListBackups retrieves a shed's backups, newest first.
+func (c *APIClient) ListBackups(name string) (*config.BackupsResponse, error) {
+	var resp config.BackupsResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/sheds/"+name+"/backups", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RestoreBackup replaces a shed's workspace with one of its backups.
+// Downloading and extracting a large backup can take a while, so the
+// request has no timeout.
+func (c *APIClient) RestoreBackup(name, backup string) (*config.Shed, error) {
+	restoreClient := &APIClient{
+		baseURL:    c.baseURL,
+		token:      c.token,
+		httpClient: &http.Client{Transport: c.httpClient.Transport},
+	}
+
+	var shed config.Shed
+	req := config.RestoreBackupRequest{Backup: backup}
+	if err := restoreClient.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/restore", req, &shed); err != nil {
 		return nil, err
 	}
 	return &shed, nil
@@ -150,12 +563,544 @@ func (c *APIClient) StartShed(name string) (*config.Shed, error) {
 // StopShed stops a running shed.
 func (c *APIClient) StopShed(name string) (*config.Shed, error) {
 	var shed config.Shed
-	if err := c.doRequest(http.MethodPost, "/api/sheds/"+name+"/stop", nil, &shed); err != nil {
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/stop", nil, &shed); err != nil {
 		return nil, err
 	}
 	return &shed, nil
 }
 
+// WaitForShed blocks until a shed reaches the given status or the timeout
+// passes. The HTTP timeout is extended to cover the server-side wait.
+func (c *APIClient) WaitForShed(name, status string, timeout time.Duration) (*config.Shed, error) {
+	waitClient := &APIClient{
+		baseURL:    c.baseURL,
+		token:      c.token,
+		httpClient: &http.Client{Timeout: timeout + c.httpClient.Timeout, Transport: c.httpClient.Transport},
+		retries:    c.retries,
+	}
+
+	query := url.Values{}
+	query.Set("status", status)
+	query.Set("timeout", timeout.String())
+
+	var shed config.Shed
+	if err := waitClient.doRequest(http.MethodGet, "/api/v1/sheds/"+name+"/wait?"+query.Encode(), nil, &shed); err != nil {
+		return nil, err
+	}
+	return &shed, nil
+}
+
+// StreamLogs copies a shed's container output to w. With opts.Follow it
+// returns only when the server ends the stream.
+func (c *APIClient) StreamLogs(name string, opts config.LogOptions, w io.Writer) error {
+	query := url.Values{}
+	if opts.Follow {
+		query.Set("follow", "true")
+	}
+	if opts.Tail >= 0 {
+		query.Set("tail", strconv.Itoa(opts.Tail))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/v1/sheds/"+name+"/logs?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+
+	// Logs can stream indefinitely, so the request has no overall timeout
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return requestError(err, 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("log stream interrupted: %w", err)
+	}
+	return nil
+}
+
+// ExportWorkspace streams a tar archive of a shed's workspace to w.
+func (c *APIClient) ExportWorkspace(name string, w io.Writer) error {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/v1/sheds/"+name+"/export", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+
+	// Large workspaces take a while, so the request has no overall timeout
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return requestError(err, 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("export interrupted: %w", err)
+	}
+	return nil
+}
+
+// ImportShed creates a shed from a workspace archive read from archive.
+func (c *APIClient) ImportShed(name, image string, archive io.Reader) (*config.Shed, error) {
+	query := url.Values{}
+	query.Set("name", name)
+	if image != "" {
+		query.Set("image", image)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/v1/sheds/import?"+query.Encode(), archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	c.setHeaders(req)
+
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, requestError(err, 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, c.parseError(resp)
+	}
+
+	var shed config.Shed
+	if err := json.NewDecoder(resp.Body).Decode(&shed); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &shed, nil
+}
+
+// CloneShed creates dst as a copy of src. Copying a large workspace can
+// take a while, so the request has no timeout.
+func (c *APIClient) CloneShed(src, dst string) (*config.Shed, error) {
+	cloneClient := &APIClient{
+		baseURL:    c.baseURL,
+		token:      c.token,
+		httpClient: &http.Client{Transport: c.httpClient.Transport},
+	}
+
+	var shed config.Shed
+	req := config.CloneShedRequest{Name: dst}
+	if err := cloneClient.doRequest(http.MethodPost, "/api/v1/sheds/"+src+"/clone", req, &shed, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &shed, nil
+}
+
+// UpdateLabels sets and removes a shed's labels.
+func (c *APIClient) UpdateLabels(name string, req config.UpdateLabelsRequest) (*config.Shed, error) {
+	var shed config.Shed
+	if err := c.doRequest(http.MethodPatch, "/api/v1/sheds/"+name+"/labels", req, &shed); err != nil {
+		return nil, err
+	}
+	return &shed, nil
+}
+
+// UpdateEnv sets and removes a shed's environment variables.
+func (c *APIClient) UpdateEnv(name string, req config.UpdateEnvRequest) (*config.Shed, error) {
+	var shed config.Shed
+	if err := c.doRequest(http.MethodPatch, "/api/v1/sheds/"+name+"/env", req, &shed); err != nil {
+		return nil, err
+	}
+	return &shed, nil
+}
+
+// PauseShed freezes a running shed.
+func (c *APIClient) PauseShed(name string) (*config.Shed, error) {
+	var shed config.Shed
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/pause", nil, &shed); err != nil {
+		return nil, err
+	}
+	return &shed, nil
+}
+
+// ResumeShed unfreezes a paused shed.
+func (c *APIClient) ResumeShed(name string) (*config.Shed, error) {
+	var shed config.Shed
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/resume", nil, &shed); err != nil {
+		return nil, err
+	}
+	return &shed, nil
+}
+
+// ListDeployKeys retrieves all deploy keys on the server.
+func (c *APIClient) ListDeployKeys() (*config.DeployKeysResponse, error) {
+	var keys config.DeployKeysResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/deploy-keys", nil, &keys); err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+// CreateDeployKey generates a new deploy key on the server.
+func (c *APIClient) CreateDeployKey(name string) (*config.DeployKey, error) {
+	var key config.DeployKey
+	req := &config.CreateDeployKeyRequest{Name: name}
+	if err := c.doRequest(http.MethodPost, "/api/v1/deploy-keys", req, &key, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetDeployKey retrieves a deploy key's public details.
+func (c *APIClient) GetDeployKey(name string) (*config.DeployKey, error) {
+	var key config.DeployKey
+	if err := c.doRequest(http.MethodGet, "/api/v1/deploy-keys/"+name, nil, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// DeleteDeployKey deletes a deploy key from the server.
+func (c *APIClient) DeleteDeployKey(name string) error {
+	return c.doRequest(http.MethodDelete, "/api/v1/deploy-keys/"+name, nil, nil, http.StatusNoContent, http.StatusOK)
+}
+
+// ListSecrets retrieves the names of all secrets on the server.
+func (c *APIClient) ListSecrets() (*config.SecretsResponse, error) {
+	var resp config.SecretsResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/secrets", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetSecret retrieves a secret with its value.
+func (c *APIClient) GetSecret(name string) (*config.Secret, error) {
+	var secret config.Secret
+	if err := c.doRequest(http.MethodGet, "/api/v1/secrets/"+name, nil, &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// SetSecret creates or replaces a secret on the server.
+func (c *APIClient) SetSecret(name, value string) (*config.Secret, error) {
+	var secret config.Secret
+	req := &config.SetSecretRequest{Value: value}
+	if err := c.doRequest(http.MethodPut, "/api/v1/secrets/"+name, req, &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// DeleteSecret deletes a secret from the server.
+func (c *APIClient) DeleteSecret(name string) error {
+	return c.doRequest(http.MethodDelete, "/api/v1/secrets/"+name, nil, nil, http.StatusNoContent, http.StatusOK)
+}
+
+// ListImages retrieves the images sheds can be created from.
+func (c *APIClient) ListImages() (*config.ImagesResponse, error) {
+	var resp config.ImagesResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/images", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// BuildImage builds an image on the server from body, a Dockerfile or a tar
+// build context depending on contentType, passing build output to onOutput.
+// Builds can take a while, so only the stream bounds the request.
+func (c *APIClient) BuildImage(req config.BuildImageRequest, body io.Reader, contentType string, onOutput func(string)) (*config.Image, error) {
+	query := url.Values{}
+	query.Set("tag", req.Tag)
+	if req.Dockerfile != "" {
+		query.Set("dockerfile", req.Dockerfile)
+	}
+	if req.Pull {
+		query.Set("pull", "true")
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/v1/images/build?"+query.Encode(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Accept", "text/event-stream")
+	c.setHeaders(httpReq)
+
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(httpReq)
+	if err != nil {
+		return nil, requestError(err, 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var image *config.Image
+	err = readEvents(resp.Body, func(event string, data []byte) error {
+		switch event {
+		case config.EventOutput:
+			var out config.BuildOutput
+			if err := json.Unmarshal(data, &out); err == nil {
+				onOutput(out.Stream)
+			}
+		case config.EventDone:
+			image = &config.Image{}
+			if err := json.Unmarshal(data, image); err != nil {
+				return fmt.Errorf("failed to parse response: %w", err)
+			}
+		case config.EventError:
+			var apiErr config.APIError
+			if err := json.Unmarshal(data, &apiErr); err != nil {
+				return fmt.Errorf("failed to parse error: %w", err)
+			}
+			return fmt.Errorf("%s: %s", apiErr.Error.Code, apiErr.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if image == nil {
+		return nil, fmt.Errorf("server closed the stream before the build finished")
+	}
+	return image, nil
+}
+
+// ListKeys retrieves the SSH keys registered on the server.
+func (c *APIClient) ListKeys() (*config.AuthorizedKeysResponse, error) {
+	var keys config.AuthorizedKeysResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/keys", nil, &keys); err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+// AddKey registers an SSH public key on the server.
+func (c *APIClient) AddKey(req *config.AddAuthorizedKeyRequest) (*config.AuthorizedKey, error) {
+	var key config.AuthorizedKey
+	if err := c.doRequest(http.MethodPost, "/api/v1/keys", req, &key, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RemoveKey unregisters an SSH public key from the server.
+func (c *APIClient) RemoveKey(name string) error {
+	return c.doRequest(http.MethodDelete, "/api/v1/keys/"+name, nil, nil, http.StatusNoContent, http.StatusOK)
+}
+
+// Batch runs multiple start/stop/delete operations in a single request.
+func (c *APIClient) Batch(req *config.BatchRequest) (*config.BatchResponse, error) {
+	var resp config.BatchResponse
+	if err := c.doRequest(http.MethodPost, "/api/v1/batch", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Prune removes shed resources nothing uses on the server, or lists them
+// for a dry run.
+func (c *APIClient) Prune(req *config.PruneRequest) (*config.PruneResponse, error) {
+	var resp config.PruneResponse
+	if err := c.doRequest(http.MethodPost, "/api/v1/prune", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListSchedules retrieves the schedules of a shed, or of every shed on the
+// server if name is empty.
+func (c *APIClient) ListSchedules(name string) (*config.SchedulesResponse, error) {
+	path := "/api/v1/schedules"
+	if name != "" {
+		path = "/api/v1/sheds/" + name + "/schedules"
+	}
+
+	var resp config.SchedulesResponse
+	if err := c.doRequest(http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SetSchedule schedules a shed to start, stop, or be backed up at the times
+// expr matches.
+func (c *APIClient) SetSchedule(name, action, expr string) (*config.Schedule, error) {
+	var schedule config.Schedule
+	req := &config.SetScheduleRequest{Cron: expr}
+	if err := c.doRequest(http.MethodPut, "/api/v1/sheds/"+name+"/schedules/"+action, req, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// DeleteSchedule removes a shed's schedule for an action.
+func (c *APIClient) DeleteSchedule(name, action string) error {
+	return c.doRequest(http.MethodDelete, "/api/v1/sheds/"+name+"/schedules/"+action, nil, nil, http.StatusNoContent, http.StatusOK)
+}
+
+// GetShedStats retrieves resource usage for a running shed, along with
+// session activity and workspace size when activity is set.
+func (c *APIClient) GetShedStats(name string, activity bool) (*config.ShedStats, error) {
+	path := "/api/v1/sheds/" + name + "/stats"
+	if !activity {
+		path += "?activity=false"
+	}
+
+	var stats config.ShedStats
+	if err := c.doRequest(http.MethodGet, path, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetWorkspaceDiff retrieves uncommitted changes in a shed's workspace,
+// including the full diff of tracked files when full is set.
+func (c *APIClient) GetWorkspaceDiff(name string, full bool) (*config.WorkspaceDiff, error) {
+	path := "/api/v1/sheds/" + name + "/diff"
+	if full {
+		path += "?full=true"
+	}
+
+	var diff config.WorkspaceDiff
+	if err := c.doRequest(http.MethodGet, path, nil, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// ListPorts retrieves the TCP ports processes in a shed are listening on.
+func (c *APIClient) ListPorts(name string) (*config.PortsResponse, error) {
+	var ports config.PortsResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/sheds/"+name+"/ports", nil, &ports); err != nil {
+		return nil, err
+	}
+	return &ports, nil
+}
+
+// ListSessions retrieves the sessions running in a shed.
+func (c *APIClient) ListSessions(name string) (*config.SessionsResponse, error) {
+	var sessions config.SessionsResponse
+	if err := c.doRequest(http.MethodGet, "/api/v1/sheds/"+name+"/sessions", nil, &sessions); err != nil {
+		return nil, err
+	}
+	return &sessions, nil
+}
+
+// CreateSession starts a detached session in a running shed.
+func (c *APIClient) CreateSession(name string, req *config.CreateSessionRequest) (*config.Session, error) {
+	var session config.Session
+	if err := c.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/sessions", req, &session, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Exec runs a shell command in a shed through the API, streaming its
+// output to stdout and stderr, and returns its exit code. The server stops
+// waiting after timeout, so only the stream bounds the request.
+func (c *APIClient) Exec(name, command string, timeout time.Duration, stdout, stderr io.Writer) (int, error) {
+	bodyData, err := json.Marshal(&config.ExecRequest{Command: command, Timeout: timeout.String()})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/v1/sheds/"+name+"/exec", bytes.NewReader(bodyData))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	c.setHeaders(httpReq)
+
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(httpReq)
+	if err != nil {
+		return 0, requestError(err, 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, c.parseError(resp)
+	}
+
+	var result *config.ExecResult
+	err = readEvents(resp.Body, func(event string, data []byte) error {
+		switch event {
+		case config.EventOutput:
+			var out config.ExecOutput
+			if err := json.Unmarshal(data, &out); err == nil {
+				if out.Stream == config.StreamStderr {
+					io.WriteString(stderr, out.Data)
+				} else {
+					io.WriteString(stdout, out.Data)
+				}
+			}
+		case config.EventDone:
+			result = &config.ExecResult{}
+			if err := json.Unmarshal(data, result); err != nil {
+				return fmt.Errorf("failed to parse response: %w", err)
+			}
+		case config.EventError:
+			var apiErr config.APIError
+			if err := json.Unmarshal(data, &apiErr); err != nil {
+				return fmt.Errorf("failed to parse error: %w", err)
+			}
+			return fmt.Errorf("%s: %s", apiErr.Error.Code, apiErr.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if result == nil {
+		return 0, fmt.Errorf("server closed the stream before the command finished")
+	}
+	return result.ExitCode, nil
+}
+
+// ExecInSession runs a command in a tmux session, creating the session if
+// needed, and waits up to timeout for it to finish.
+func (c *APIClient) ExecInSession(name, session, command string, timeout time.Duration) (*config.SessionExecResult, error) {
+	execClient := &APIClient{
+		baseURL:    c.baseURL,
+		token:      c.token,
+		httpClient: &http.Client{Timeout: timeout + c.httpClient.Timeout, Transport: c.httpClient.Transport},
+		retries:    c.retries,
+	}
+
+	var result config.SessionExecResult
+	req := &config.SessionExecRequest{Command: command, Timeout: timeout.String()}
+	if err := execClient.doRequest(http.MethodPost, "/api/v1/sheds/"+name+"/sessions/"+session+"/exec", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RenameSession renames a session in a running shed.
+func (c *APIClient) RenameSession(name, session, newName string) (*config.Session, error) {
+	var renamed config.Session
+	req := &config.RenameSessionRequest{Name: newName}
+	if err := c.doRequest(http.MethodPatch, "/api/v1/sheds/"+name+"/sessions/"+session, req, &renamed); err != nil {
+		return nil, err
+	}
+	return &renamed, nil
+}
+
+// KillSession ends a session in a running shed.
+func (c *APIClient) KillSession(name, session string) error {
+	return c.doRequest(http.MethodDelete, "/api/v1/sheds/"+name+"/sessions/"+session, nil, nil, http.StatusNoContent, http.StatusOK)
+}
+
 // Ping checks if the server is reachable.
 func (c *APIClient) Ping() bool {
 	client := &http.Client{
@@ -176,11 +1121,37 @@ func (c *APIClient) parseError(resp *http.Response) error {
 		return fmt.Errorf("server returned status %d", resp.StatusCode)
 	}
 
+	// Servers from before API versioning don't know the /api/v1 routes
+	if resp.StatusCode == http.StatusNotFound && resp.Header.Get(config.HeaderAPIVersion) == "" {
+		return fmt.Errorf("server does not support API %s; upgrade shed-server to use this version of shed", version.APIVersion)
+	}
+
 	var apiErr config.APIError
 	if err := json.Unmarshal(body, &apiErr); err != nil {
 		// If not a structured error, return the body as-is
 		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
 	}
 
+	switch apiErr.Error.Code {
+	case config.ErrUnauthorized:
+		return fmt.Errorf("%s: %s (set a token with 'shed server update <name> --token <token>')", apiErr.Error.Code, apiErr.Error.Message)
+	case config.ErrClientTooOld:
+		return fmt.Errorf("%s: %s (upgrade shed to continue)", apiErr.Error.Code, apiErr.Error.Message)
+	case config.ErrRateLimited:
+		if after := resp.Header.Get("Retry-After"); after != "" {
+			return fmt.Errorf("%s: %s (try again in %ss)", apiErr.Error.Code, apiErr.Error.Message, after)
+		}
+	}
+
+	// List each rejected field on its own line
+	if len(apiErr.Error.Fields) > 0 {
+		var b strings.Builder
+		b.WriteString("invalid request:")
+		for _, fe := range apiErr.Error.Fields {
+			fmt.Fprintf(&b, "\n  %s: %s", fe.Field, fe.Message)
+		}
+		return errors.New(b.String())
+	}
+
 	return fmt.Errorf("%s: %s", apiErr.Error.Code, apiErr.Error.Message)
 }
@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
-	"syscall"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -18,7 +21,8 @@ var consoleCmd = &cobra.Command{
 	Long: `Open an interactive SSH console to a shed.
 
 This command replaces the current process with an SSH connection
-to the specified shed.`,
+to the specified shed. Without an ssh binary installed, or with
+--ssh-client native, shed's built-in SSH client is used instead.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runConsole,
 }
@@ -29,11 +33,47 @@ var execCmd = &cobra.Command{
 	Long: `Execute a command in a shed via SSH.
 
 This command replaces the current process with an SSH connection
-that runs the specified command.`,
+that runs the specified command, and exits with the command's exit code.
+Without an ssh binary installed, or with --ssh-client native, shed's
+built-in SSH client is used instead.
+
+With --session, the command is typed into a tmux session's shell instead,
+creating the session if needed, so it runs in the shell's directory and
+stays visible to anyone attached. shed waits for it to finish, then prints
+its output and exits with its exit code. If --timeout passes first, shed
+stops waiting and the command is left running in the session.
+
+With --timeout, a command run over SSH is killed if it runs longer, and
+exits with code 124.
+
+With --api, the command is run by sh -c through the server's HTTP API,
+so it works without SSH keys set up. Its output is streamed, with stdout
+and stderr kept apart, and shed exits with its exit code. If --timeout
+passes first, the command is killed.`,
 	Args: cobra.MinimumNArgs(2),
 	RunE: runExec,
 }
 
+var (
+	execSession string
+	execTimeout time.Duration
+	execAPI     bool
+	startFlag   bool
+)
+
+// startReadyTimeout is how long --start waits for a started shed to be
+// running.
+const startReadyTimeout = 10 * time.Second
+
+func init() {
+	consoleCmd.Flags().BoolVar(&startFlag, "start", false, "Start or resume the shed first if it isn't running")
+	execCmd.Flags().BoolVar(&startFlag, "start", false, "Start or resume the shed first if it isn't running")
+	execCmd.Flags().StringVarP(&execSession, "session", "S", "", "Run the command in this tmux session and wait for its output")
+	execCmd.Flags().DurationVar(&execTimeout, "timeout", config.DefaultSessionExecTimeout, "How long to let the command run; over SSH only if given")
+	execCmd.Flags().BoolVar(&execAPI, "api", false, "Run the command through the HTTP API instead of SSH")
+	execCmd.MarkFlagsMutuallyExclusive("session", "api")
+}
+
 func runConsole(cmd *cobra.Command, args []string) error {
 	name := args[0]
 	return sshToShed(name, nil)
@@ -42,47 +82,269 @@ func runConsole(cmd *cobra.Command, args []string) error {
 func runExec(cmd *cobra.Command, args []string) error {
 	name := args[0]
 	command := args[1:]
+	if execSession != "" {
+		return execInSession(name, execSession, strings.Join(command, " "))
+	}
+	if execAPI {
+		return execViaAPI(name, strings.Join(command, " "))
+	}
+	if cmd.Flags().Changed("timeout") {
+		if execTimeout <= 0 {
+			return fmt.Errorf("--timeout must be positive")
+		}
+		// The SSH server has no timeout of its own, so leave it to
+		// timeout(1), which kills the command's process group
+		secs := (execTimeout + time.Second - 1) / time.Second
+		command = append([]string{"timeout", strconv.Itoa(int(secs))}, command...)
+	}
 	return sshToShed(name, command)
 }
 
+// execInSession runs a command in a tmux session, prints its output, and
+// exits with its exit code.
+func execInSession(name, session, command string) error {
+	if err := config.ValidateSessionName(session); err != nil {
+		return err
+	}
+	if execTimeout <= 0 || execTimeout > config.MaxSessionExecTimeout {
+		return fmt.Errorf("--timeout must be positive and at most %s", config.MaxSessionExecTimeout)
+	}
+
+	_, entry, err := findShedToConnect(name)
+	if err != nil {
+		return err
+	}
+
+	result, err := NewAPIClientFromEntry(entry).ExecInSession(name, session, command, execTimeout)
+	if err != nil {
+		return err
+	}
+
+	if ok, err := printStructured(result); ok {
+		return err
+	}
+
+	if result.Truncated {
+		fmt.Fprintf(os.Stderr, "(output truncated to the last %s)\n", formatBytes(config.MaxSessionExecOutput))
+	}
+	fmt.Print(result.Output)
+	if result.ExitCode != 0 {
+		os.Exit(result.ExitCode)
+	}
+	return nil
+}
+
+// execViaAPI runs a command through the HTTP API, streaming its output,
+// and exits with its exit code.
+func execViaAPI(name, command string) error {
+	if execTimeout <= 0 || execTimeout > config.MaxExecTimeout {
+		return fmt.Errorf("--timeout must be positive and at most %s", config.MaxExecTimeout)
+	}
+
+	_, entry, err := findShedToConnect(name)
+	if err != nil {
+		return err
+	}
+
+	code, err := NewAPIClientFromEntry(entry).Exec(name, command, execTimeout, os.Stdout, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+	return nil
+}
+
 // sshToShed establishes an SSH connection to a shed.
 // If command is nil, an interactive shell is opened.
 // If command is provided, it is executed on the shed.
 func sshToShed(name string, command []string) error {
+	serverName, entry, err := findShedToConnect(name)
+	if err != nil {
+		return err
+	}
+
+	return sshToShedOn(name, serverName, entry, command)
+}
+
+// findRunningShed finds the server hosting a shed and verifies the shed is running.
+func findRunningShed(name string) (string, *config.ServerEntry, error) {
+	return findShed(name, false)
+}
+
+// findShedToConnect finds the server hosting a shed to connect to, starting
+// or resuming the shed first with --start, or if the user agrees when asked.
+func findShedToConnect(name string) (string, *config.ServerEntry, error) {
+	return findShed(name, true)
+}
+
+// findShed finds the server hosting a shed and makes sure it is running,
+// starting or resuming it if mayStart is set and --start is given or the
+// user agrees.
+func findShed(name string, mayStart bool) (string, *config.ServerEntry, error) {
 	// Find the server hosting this shed
 	serverName, entry, err := findShedServer(name)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
 	// Verify the shed is running
 	client := NewAPIClientFromEntry(entry)
 	shed, err := client.GetShed(name)
 	if err != nil {
-		return fmt.Errorf("failed to get shed status: %w", err)
+		return "", nil, fmt.Errorf("failed to get shed status: %w", err)
+	}
+	if shed.Status == config.StatusRunning {
+		return serverName, entry, nil
 	}
 
-	if shed.Status != config.StatusRunning {
-		printError(fmt.Sprintf("shed %q is %s", name, shed.Status),
-			"shed start "+name+"  # Start the shed first")
-		return fmt.Errorf("shed %q is not running", name)
+	verb, hint := "start", "shed start "+name+"  # Start the shed first"
+	if shed.Status == config.StatusPaused {
+		verb, hint = "resume", "shed resume "+name+"  # Resume the shed first"
+	}
+	if mayStart && (startFlag || (isInteractive() && confirm(fmt.Sprintf("Shed %s is %s; %s it now?", name, shed.Status, verb)))) {
+		if err := startForConnect(client, name, shed.Status); err != nil {
+			return "", nil, err
+		}
+		clientConfig.CacheShed(name, serverName, config.StatusRunning)
+		if err := clientConfig.Save(); err != nil {
+			if verboseFlag {
+				fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+			}
+		}
+		return serverName, entry, nil
 	}
 
+	hints := []string{hint}
+	if mayStart {
+		hints = append(hints, "Rerun with --start to "+verb+" it and connect")
+	}
+	printError(fmt.Sprintf("shed %q is %s", name, shed.Status), hints...)
+	return "", nil, fmt.Errorf("shed %q is not running", name)
+}
+
+// startForConnect starts a stopped shed, or resumes a paused one, and waits
+// for it to be ready, as the SSH server does for connections to stopped
+// sheds.
+func startForConnect(client *APIClient, name, status string) error {
+	if status == config.StatusPaused {
+		fmt.Fprintf(os.Stderr, "Resuming shed %s...\n", name)
+		if _, err := client.ResumeShed(name); err != nil {
+			return fmt.Errorf("failed to resume shed: %w", err)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Starting shed %s...\n", name)
+	if _, err := client.StartShed(name); err != nil {
+		return fmt.Errorf("failed to start shed: %w", err)
+	}
+	if _, err := client.WaitForShed(name, config.StatusRunning, startReadyTimeout); err != nil {
+		return fmt.Errorf("shed not ready: %w", err)
+	}
+	return nil
+}
+
+// useNativeSSH reports whether to connect with the built-in SSH client,
+// as chosen by --ssh-client, the config file, or whether ssh is installed.
+func useNativeSSH() (bool, error) {
+	choice := sshClientFlag
+	if choice == "" {
+		choice = clientConfig.SSHClient
+	}
+	if err := config.ValidateSSHClient(choice); err != nil {
+		return false, err
+	}
+
+	switch choice {
+	case config.SSHClientNative:
+		return true, nil
+	case config.SSHClientOpenSSH:
+		return false, nil
+	}
+	_, err := exec.LookPath("ssh")
+	return err != nil, nil
+}
+
+// serverSSHArgs returns the ssh arguments for a server's extra SSH options,
+// matching the entries shed ssh-config writes.
+func serverSSHArgs(opts config.ServerSSHOptions) []string {
+	var args []string
+	if opts.ProxyJump != "" {
+		args = append(args, "-o", "ProxyJump="+opts.ProxyJump)
+	}
+	if opts.IdentityFile != "" {
+		args = append(args, "-o", "IdentityFile="+opts.IdentityFile)
+	}
+	if opts.ForwardAgent {
+		args = append(args, "-o", "ForwardAgent=yes")
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.Options)) {
+		args = append(args, "-o", name+"="+opts.Options[name])
+	}
+	return args
+}
+
+// sshOptionNames returns the names of the options set in opts, as written
+// in the client config.
+func sshOptionNames(opts config.ServerSSHOptions) []string {
+	var names []string
+	if opts.ProxyJump != "" {
+		names = append(names, "proxy_jump")
+	}
+	if opts.IdentityFile != "" {
+		names = append(names, "identity_file")
+	}
+	if opts.ForwardAgent {
+		names = append(names, "forward_agent")
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.Options)) {
+		names = append(names, "options."+name)
+	}
+	return names
+}
+
+// shedSSHArgs returns the ssh arguments that connect to a shed on a
+// server, ending with the destination.
+func shedSSHArgs(name string, entry *config.ServerEntry) []string {
+	args := []string{
+		"-p", strconv.Itoa(entry.SSHPort),
+		"-o", "UserKnownHostsFile=" + config.GetKnownHostsPath(),
+		"-o", "StrictHostKeyChecking=yes",
+	}
+	args = append(args, serverSSHArgs(entry.SSH)...)
+	return append(args, name+"@"+config.NormalizeHost(entry.Host))
+}
+
+// sshToShedOn replaces the current process with an SSH connection to a shed
+// on a known server, or runs the built-in SSH client if OpenSSH isn't
+// installed or isn't wanted. The shed is assumed to be running.
+func sshToShedOn(name, serverName string, entry *config.ServerEntry, command []string) error {
 	if verboseFlag {
 		fmt.Printf("Connecting to %s on %s...\n", name, serverName)
 	}
 
-	// Build SSH command
-	knownHostsPath := config.GetKnownHostsPath()
+	native, err := useNativeSSH()
+	if err != nil {
+		return err
+	}
+	if native {
+		if !entry.SSH.IsZero() {
+			return fmt.Errorf("server %s sets SSH options (%s), which the built-in SSH client doesn't support; install OpenSSH or set ssh_client: openssh", serverName, strings.Join(sshOptionNames(entry.SSH), ", "))
+		}
+		return nativeSSHToShed(name, serverName, entry, command)
+	}
 
+	// Build SSH command
 	sshArgs := []string{
 		"ssh",
 		"-t", // Force pseudo-terminal allocation
-		"-p", strconv.Itoa(entry.SSHPort),
-		"-o", "UserKnownHostsFile=" + knownHostsPath,
-		"-o", "StrictHostKeyChecking=yes",
-		name + "@" + entry.Host,
 	}
+	if patterns := clientConfig.SendEnvPatterns(); len(patterns) > 0 {
+		sshArgs = append(sshArgs, "-o", "SendEnv="+strings.Join(patterns, " "))
+	}
+	sshArgs = append(sshArgs, shedSSHArgs(name, entry)...)
 
 	// Add command if provided
 	if len(command) > 0 {
@@ -96,7 +358,7 @@ func sshToShed(name string, command []string) error {
 	}
 
 	// Replace current process with ssh
-	if err := syscall.Exec(sshPath, sshArgs, os.Environ()); err != nil {
+	if err := execReplace(sshPath, sshArgs); err != nil {
 		return fmt.Errorf("failed to exec ssh: %w", err)
 	}
 
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var deployKeyCmd = &cobra.Command{
+	Use:   "deploy-key",
+	Short: "Manage deploy keys for cloning private repositories",
+	Long: `Manage SSH deploy keys stored on a shed server.
+
+A deploy key is generated on the server and only its public key is shown.
+Add the public key to your git host (e.g. as a GitHub or GitLab deploy key),
+then create sheds with --deploy-key to clone and fetch with it. Name keys
+after a shed for a per-shed key, or after a repository to share one key
+between every shed that uses it.`,
+}
+
+var deployKeyCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Generate a new deploy key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDeployKeyCreate,
+}
+
+var deployKeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List deploy keys",
+	Args:  cobra.NoArgs,
+	RunE:  runDeployKeyList,
+}
+
+var deployKeyShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print a deploy key's public key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDeployKeyShow,
+}
+
+var deployKeyDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a deploy key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDeployKeyDelete,
+}
+
+var deployKeyDeleteForce bool
+
+func init() {
+	deployKeyDeleteCmd.Flags().BoolVarP(&deployKeyDeleteForce, "force", "f", false, "Delete without confirmation")
+
+	deployKeyCmd.AddCommand(deployKeyCreateCmd)
+	deployKeyCmd.AddCommand(deployKeyListCmd)
+	deployKeyCmd.AddCommand(deployKeyShowCmd)
+	deployKeyCmd.AddCommand(deployKeyDeleteCmd)
+
+	rootCmd.AddCommand(deployKeyCmd)
+}
+
+// serverClient returns an API client for the selected server.
+func serverClient() (*APIClient, string, error) {
+	entry, serverName, err := getServerEntry()
+	if err != nil {
+		printError("no server configured",
+			"shed server add <hostname>  # Add a server first")
+		return nil, "", err
+	}
+	return NewAPIClientFromEntry(entry), serverName, nil
+}
+
+func runDeployKeyCreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	noteHistory("", serverName, shedCommand("deploy-key", "delete", name, "--server", serverName))
+	key, err := client.CreateDeployKey(name)
+	if err != nil {
+		return fmt.Errorf("failed to create deploy key: %w", err)
+	}
+
+	if ok, err := printStructured(key); ok {
+		return err
+	}
+
+	printSuccess("Created deploy key %s on %s", key.Name, serverName)
+	fmt.Printf("\nAdd this public key to your git host:\n\n%s\n", key.PublicKey)
+	fmt.Printf("\nThen create a shed with:\n  shed create <name> --repo <url> --deploy-key %s\n", key.Name)
+
+	return nil
+}
+
+func runDeployKeyList(cmd *cobra.Command, args []string) error {
+	client, _, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.ListDeployKeys()
+	if err != nil {
+		return fmt.Errorf("failed to list deploy keys: %w", err)
+	}
+
+	if ok, err := printStructured(resp.DeployKeys); ok {
+		return err
+	}
+
+	if len(resp.DeployKeys) == 0 {
+		fmt.Println("No deploy keys found.")
+		fmt.Println("\nTo create a deploy key:")
+		fmt.Println("  shed deploy-key create <name>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFINGERPRINT\tCREATED")
+	for _, key := range resp.DeployKeys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key.Name, key.Fingerprint, key.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runDeployKeyShow(cmd *cobra.Command, args []string) error {
+	client, _, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	key, err := client.GetDeployKey(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get deploy key: %w", err)
+	}
+
+	if ok, err := printStructured(key); ok {
+		return err
+	}
+
+	fmt.Println(key.PublicKey)
+	return nil
+}
+
+func runDeployKeyDelete(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	if !deployKeyDeleteForce {
+		if !confirm(fmt.Sprintf("Delete deploy key %q on %s?", name, serverName)) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	noteHistory("", serverName, "")
+	if err := client.DeleteDeployKey(name); err != nil {
+		return fmt.Errorf("failed to delete deploy key: %w", err)
+	}
+
+	printSuccess("Deleted deploy key %s", name)
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <name>",
+	Short: "Show uncommitted changes in a shed",
+	Long: `Show uncommitted and untracked changes in a shed's workspace.
+
+Each git repository in the workspace is reported separately, along with
+commits that have not been pushed to its upstream. Run this before deleting
+a shed to check that no work will be lost. Use --full to also print the diff
+of tracked files.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDiff,
+}
+
+var diffFull bool
+
+func init() {
+	diffCmd.Flags().BoolVarP(&diffFull, "full", "f", false, "Print the full diff of tracked files")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	_, entry, err := findRunningShed(name)
+	if err != nil {
+		return err
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	diff, err := client.GetWorkspaceDiff(name, diffFull)
+	if err != nil {
+		return fmt.Errorf("failed to get workspace changes: %w", err)
+	}
+
+	if len(diff.Repos) == 0 {
+		fmt.Printf("No git repositories found in %s.\n", name)
+		return nil
+	}
+
+	dirty := 0
+	for i, repo := range diff.Repos {
+		if i > 0 {
+			fmt.Println()
+		}
+		printRepoStatus(repo)
+		if !repo.Clean() {
+			dirty++
+		}
+	}
+
+	if dirty == 0 {
+		fmt.Printf("\nNo uncommitted changes in %s.\n", name)
+	}
+
+	return nil
+}
+
+// printRepoStatus prints a repository's branch, changed files, and diff if present.
+func printRepoStatus(repo config.RepoStatus) {
+	header := repo.Path
+	if repo.Branch != "" {
+		header += " (" + repo.Branch + ")"
+	}
+
+	var notes []string
+	if repo.Ahead > 0 {
+		notes = append(notes, fmt.Sprintf("%d unpushed", repo.Ahead))
+	}
+	if repo.Behind > 0 {
+		notes = append(notes, fmt.Sprintf("%d behind", repo.Behind))
+	}
+	if repo.Clean() {
+		notes = append(notes, "clean")
+	}
+	if len(notes) > 0 {
+		header += ": " + strings.Join(notes, ", ")
+	}
+	fmt.Println(header)
+
+	for _, change := range repo.Changes {
+		fmt.Printf("  %s %s\n", change.Status, change.Path)
+	}
+
+	if repo.Diff != "" {
+		fmt.Println()
+		fmt.Print(repo.Diff)
+		if repo.Truncated {
+			fmt.Printf("\n... diff truncated at %d bytes\n", config.MaxDiffBytes)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/mdns"
+)
+
+var serverDiscoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Find shed servers on the local network",
+	Long: `Find shed servers advertising themselves on the local network over mDNS,
+and offer to add the ones not configured yet.
+
+Servers advertise unless their config sets mdns: false. Discovery only
+reaches the local network segment, and servers that require an API token
+must be added with 'shed server add --token'.`,
+	Args: cobra.NoArgs,
+	RunE: runServerDiscover,
+}
+
+var (
+	serverDiscoverTimeout time.Duration
+	serverDiscoverYes     bool
+)
+
+func init() {
+	serverDiscoverCmd.Flags().DurationVarP(&serverDiscoverTimeout, "timeout", "t", 3*time.Second, "How long to wait for servers to answer")
+	serverDiscoverCmd.Flags().BoolVarP(&serverDiscoverYes, "yes", "y", false, "Add every new server without asking")
+
+	serverCmd.AddCommand(serverDiscoverCmd)
+}
+
+// discoveredServer is a server found by discover, as printed with
+// --output json or yaml.
+type discoveredServer struct {
+	Name       string `json:"name"`
+	Host       string `json:"host"`
+	HTTPPort   int    `json:"http_port"`
+	SSHPort    int    `json:"ssh_port,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Configured string `json:"configured,omitempty"`
+}
+
+func runServerDiscover(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), serverDiscoverTimeout)
+	defer cancel()
+
+	services, err := mdns.Browse(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to discover servers: %w", err)
+	}
+
+	found := make([]discoveredServer, 0, len(services))
+	for _, svc := range services {
+		found = append(found, discoveredServer{
+			Name:       svc.Instance,
+			Host:       svc.Addr(),
+			HTTPPort:   svc.Port,
+			SSHPort:    svc.TextInt("ssh_port"),
+			Version:    svc.Text["version"],
+			Configured: configuredServer(svc.Addr(), svc.Port),
+		})
+	}
+
+	if ok, err := printStructured(found); ok {
+		return err
+	}
+
+	if len(found) == 0 {
+		fmt.Println("No servers found on the local network.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tHOST\tHTTP\tSSH\tVERSION\tCONFIGURED")
+	for _, s := range found {
+		ssh := "-"
+		if s.SSHPort > 0 {
+			ssh = strconv.Itoa(s.SSHPort)
+		}
+		ver := "-"
+		if s.Version != "" {
+			ver = s.Version
+		}
+		configured := "-"
+		if s.Configured != "" {
+			configured = "as " + s.Configured
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", s.Name, s.Host, s.HTTPPort, ssh, ver, configured)
+	}
+	w.Flush()
+
+	// Offer to add the rest
+	for _, s := range found {
+		if s.Configured != "" {
+			continue
+		}
+		if !serverDiscoverYes {
+			if !isInteractive() {
+				continue
+			}
+			fmt.Println()
+			if !confirm(fmt.Sprintf("Add server %s (%s:%d)?", s.Name, s.Host, s.HTTPPort)) {
+				continue
+			}
+		}
+		name := ""
+		if _, exists := clientConfig.Servers[s.Name]; exists {
+			name = s.Name + "-" + s.Host
+		}
+		if err := addServer(s.Host, s.HTTPPort, name, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to add %s: %v\n", s.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// configuredServer returns the name of the configured server at host and
+// port, or "" if there is none.
+func configuredServer(host string, port int) string {
+	for name, entry := range clientConfig.Servers {
+		if entry.Host == host && entry.HTTPPort == port {
+			return name
+		}
+	}
+	return ""
+}
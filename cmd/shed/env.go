@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage a shed's environment variables",
+	Long: `Manage environment variables set in a shed on top of the server's env file.
+
+Changes apply to sessions started afterwards; sessions already open keep
+their environment. Processes the container itself runs pick them up when the
+shed is rebuilt. Variables can also be given at creation with
+shed create --env:
+
+  shed env set dev NODE_ENV=development API_URL=http://localhost:3000
+  shed env unset dev API_URL
+  shed env list dev`,
+}
+
+var envListCmd = &cobra.Command{
+	Use:   "list <name>",
+	Short: "List a shed's environment variables",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runEnvList,
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set <name> NAME=value...",
+	Short: "Set environment variables in a shed",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runEnvSet,
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset <name> NAME...",
+	Short: "Remove environment variables from a shed",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runEnvUnset,
+}
+
+func init() {
+	envCmd.AddCommand(envListCmd)
+	envCmd.AddCommand(envSetCmd)
+	envCmd.AddCommand(envUnsetCmd)
+
+	rootCmd.AddCommand(envCmd)
+}
+
+func runEnvList(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	_, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	shed, err := NewAPIClientFromEntry(entry).GetShed(name)
+	if err != nil {
+		return fmt.Errorf("failed to get shed: %w", err)
+	}
+	return printEnv(name, shed.Env)
+}
+
+func runEnvSet(cmd *cobra.Command, args []string) error {
+	env, err := parseEnvFlags(args[1:])
+	if err != nil {
+		return err
+	}
+	return updateEnv(args[0], config.UpdateEnvRequest{Set: env})
+}
+
+func runEnvUnset(cmd *cobra.Command, args []string) error {
+	for _, key := range args[1:] {
+		if err := config.ValidateEnvName(key); err != nil {
+			return err
+		}
+	}
+	return updateEnv(args[0], config.UpdateEnvRequest{Remove: args[1:]})
+}
+
+// updateEnv applies req to a shed's environment and prints the result.
+func updateEnv(name string, req config.UpdateEnvRequest) error {
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+	client := NewAPIClientFromEntry(entry)
+
+	shed, err := client.GetShed(name)
+	if err != nil {
+		return fmt.Errorf("failed to get shed: %w", err)
+	}
+
+	noteHistory(name, serverName, envUndo(name, shed.Env, req))
+	shed, err = client.UpdateEnv(name, req)
+	if err != nil {
+		return fmt.Errorf("failed to update environment: %w", err)
+	}
+
+	if structuredOutput() {
+		return printEnv(name, shed.Env)
+	}
+	printSuccess("Updated environment of %s; new sessions will use it", name)
+	return nil
+}
+
+// printEnv prints a shed's environment variables as NAME=value lines.
+func printEnv(name string, env map[string]string) error {
+	if ok, err := printStructured(env); ok {
+		return err
+	}
+
+	if len(env) == 0 {
+		fmt.Printf("Shed %s has no environment variables.\n", name)
+		return nil
+	}
+	for _, kv := range config.EnvList(env) {
+		fmt.Println(kv)
+	}
+	return nil
+}
+
+// envUndo returns the env commands that restore a shed's environment after
+// req is applied.
+func envUndo(name string, env map[string]string, req config.UpdateEnvRequest) string {
+	keys := append(config.SortedLabelKeys(req.Set), req.Remove...)
+	sort.Strings(keys)
+
+	set := []string{"env", "set", name}
+	unset := []string{"env", "unset", name}
+	for _, k := range slices.Compact(keys) {
+		if v, ok := env[k]; ok {
+			set = append(set, k+"="+v)
+		} else if _, added := req.Set[k]; added {
+			unset = append(unset, k)
+		}
+	}
+
+	var undo []string
+	if len(set) > 3 {
+		undo = append(undo, shedCommand(set...))
+	}
+	if len(unset) > 3 {
+		undo = append(undo, shedCommand(unset...))
+	}
+	return strings.Join(undo, " && ")
+}
+
+// parseEnvFlags parses NAME=value arguments or repeated --env flags.
+func parseEnvFlags(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	env := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, err := config.ParseEnv(spec)
+		if err != nil {
+			return nil, err
+		}
+		env[key] = value
+	}
+	return env, nil
+}
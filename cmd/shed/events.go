@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events [name]",
+	Short: "Watch shed events as they happen",
+	Long: `Print shed events from the server as they happen, until interrupted.
+
+Events are sent when sheds are created, started, stopped, paused, resumed,
+or deleted, when a repository fails to clone, and when SSH or terminal
+sessions open and close. Give a shed name to only watch that shed. With
+--output json each event is printed on its own line, and with --output yaml
+as a separate document, so the stream can be piped to other tools.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEvents,
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+}
+
+func runEvents(cmd *cobra.Command, args []string) error {
+	var name string
+	var entry *config.ServerEntry
+	var err error
+	if len(args) == 1 {
+		name = args[0]
+		_, entry, err = findShedServer(name)
+	} else {
+		entry, _, err = getServerEntry()
+	}
+	if err != nil {
+		return err
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	return client.StreamEvents(name, func(ev config.ShedEvent) error {
+		switch outputFlag {
+		case outputJSON:
+			// One event per line
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", data)
+			return nil
+		case outputYAML:
+			fmt.Println("---")
+			return writeStructured(os.Stdout, outputFlag, ev)
+		}
+
+		line := fmt.Sprintf("%s  %-14s %s", ev.Time.Local().Format("15:04:05"), ev.Type, ev.Shed)
+		if ev.Message != "" {
+			line += ": " + ev.Message
+		}
+		fmt.Println(line)
+		return nil
+	})
+}
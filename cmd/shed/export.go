@@ -0,0 +1,192 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <name>",
+	Short: "Save a shed's workspace to a tarball",
+	Long: `Save a shed's workspace volume to a tar archive, which can be restored
+with shed import. The shed may be running or stopped.
+
+The archive is written to <name>.tar by default, or to the file given with
+--file. Files ending in .gz or .tgz are gzipped, and "-" writes to stdout.`,
+	Example: `  shed export widget
+  shed export widget -f ~/backups/widget-$(date +%F).tar.gz`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file> <name>",
+	Short: "Create a shed from an exported workspace",
+	Long: `Create a new shed whose workspace is restored from an archive made by
+shed export. Gzipped archives are accepted, and "-" reads from stdin.
+
+Worktree sheds are exported with their files, but the restored shed has a
+plain workspace that isn't linked to a shared clone.`,
+	Example: `  shed import widget.tar widget
+  shed import widget.tar.gz widget-copy --server other-server`,
+	Args: cobra.ExactArgs(2),
+	RunE: runImport,
+}
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <src> <dst>",
+	Short: "Copy a shed to a new shed",
+	Long: `Create a new shed on the same server with a copy of an existing shed's
+workspace, image, and settings. The source may be running or stopped, and
+is left untouched.
+
+Worktree sheds can't be cloned; use shed export and shed import to copy
+their files into a plain shed.`,
+	Example: `  shed clone widget widget-experiment`,
+	Args:    cobra.ExactArgs(2),
+	RunE:    runClone,
+}
+
+var (
+	exportFile  string
+	importImage string
+)
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFile, "file", "f", "", "File to write (default: <name>.tar)")
+	importCmd.Flags().StringVarP(&importImage, "image", "i", "", "Docker image to use (see shed images)")
+	_ = importCmd.RegisterFlagCompletionFunc("image", completeImages)
+
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(cloneCmd)
+}
+
+// isGzipName reports whether a file name calls for gzip compression.
+func isGzipName(name string) bool {
+	return strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	_, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	output := exportFile
+	if output == "" {
+		output = name + ".tar"
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if output != "-" {
+		f, err = os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", output, err)
+		}
+		w = f
+	}
+
+	var gz *gzip.Writer
+	if isGzipName(output) {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	err = NewAPIClientFromEntry(entry).ExportWorkspace(name, w)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(output)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export shed: %w", err)
+	}
+
+	if f != nil {
+		printSuccess("Exported %s to %s", name, output)
+	}
+	return nil
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	path, name := args[0], args[1]
+
+	entry, serverName, err := getServerEntry()
+	if err != nil {
+		printError("no server configured",
+			"shed server add <hostname>  # Add a server first")
+		return err
+	}
+
+	var archive io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer f.Close()
+		archive = f
+	}
+
+	noteHistory(name, serverName, "")
+	shed, err := NewAPIClientFromEntry(entry).ImportShed(name, importImage, archive)
+	if err != nil {
+		return fmt.Errorf("failed to import shed: %w", err)
+	}
+	noteHistory(name, serverName, shedCommand("delete", name))
+
+	clientConfig.CacheShed(name, serverName, shed.Status)
+	if err := clientConfig.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+	}
+
+	if ok, err := printStructured(shed); ok {
+		return err
+	}
+
+	printSuccess("Imported shed %s on %s", name, serverName)
+	return nil
+}
+
+func runClone(cmd *cobra.Command, args []string) error {
+	src, dst := args[0], args[1]
+
+	serverName, entry, err := findShedServer(src)
+	if err != nil {
+		return err
+	}
+
+	noteHistory(dst, serverName, "")
+	shed, err := NewAPIClientFromEntry(entry).CloneShed(src, dst)
+	if err != nil {
+		return fmt.Errorf("failed to clone shed: %w", err)
+	}
+	noteHistory(dst, serverName, shedCommand("delete", dst))
+
+	clientConfig.CacheShed(dst, serverName, shed.Status)
+	if err := clientConfig.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+	}
+
+	if ok, err := printStructured(shed); ok {
+		return err
+	}
+
+	printSuccess("Cloned %s to %s on %s", src, dst, serverName)
+	return nil
+}
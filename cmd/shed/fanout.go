@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// serverQueryTimeout bounds how long a command querying every configured
+// server waits for any one without its own timeout, so an offline server
+// can't stall it.
+const serverQueryTimeout = 10 * time.Second
+
+// serverResult is one server's answer to a query sent to every server.
+type serverResult[T any] struct {
+	name  string
+	entry *config.ServerEntry
+	value T
+}
+
+// serverError is a server that failed to answer a query sent to every
+// server.
+type serverError struct {
+	name string
+	err  error
+}
+
+// queryServers runs query against every configured server concurrently and
+// returns the answers sorted by server name, along with the servers that
+// failed or didn't answer in time, so the caller gets partial results
+// rather than an error and can report the failures where it shows its
+// output. Failed queries aren't retried.
+func queryServers[T any](query func(client *APIClient) (T, error)) ([]serverResult[T], []serverError) {
+	names := make([]string, 0, len(clientConfig.Servers))
+	for name := range clientConfig.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]serverResult[T], len(names))
+	errs := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		entry := clientConfig.Servers[name]
+		results[i] = serverResult[T]{name: name, entry: &entry}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := results[i].entry
+			client := NewAPIClientFromEntry(entry)
+			if entry.Timeout == 0 {
+				client.httpClient.Timeout = serverQueryTimeout
+			}
+			// Retrying would hold up the answers from every other server
+			client.retries = 0
+			results[i].value, errs[i] = query(client)
+		}(i)
+	}
+	wg.Wait()
+
+	answered := results[:0]
+	var failed []serverError
+	for i, r := range results {
+		if errs[i] != nil {
+			failed = append(failed, serverError{name: r.name, err: errs[i]})
+			continue
+		}
+		answered = append(answered, r)
+	}
+	return answered, failed
+}
+
+// writeServerErrors writes a warning for each server that failed to answer
+// a query, what being the action that failed, e.g. "reach". Commands that
+// redraw the screen write them into the frame, others to stderr.
+func writeServerErrors(w io.Writer, what string, failed []serverError) {
+	for _, f := range failed {
+		fmt.Fprintf(w, "Warning: could not %s %s: %v\n", what, f.name, f.err)
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var forwardCmd = &cobra.Command{
+	Use:   "forward",
+	Short: "Keep port forwards to sheds running in the background",
+	Long: `Manage persistent port forwards from local ports to ports in sheds.
+
+Forwards are kept up by a background process started with the first
+forward, so they survive closing the terminal they were added from. Each
+forward runs its own ssh connection and reconnects after drops, such as a
+server restart or the shed being stopped, until it is removed. The
+background process exits once there are no forwards.
+
+Forwards are stored in ~/.shed/forwards.yaml and the background process
+logs to ~/.shed/forward.log. Requires OpenSSH.
+
+  shed forward add dev 3000
+  shed forward add dev 15432:5432
+  shed forward list
+  shed forward remove dev 3000`,
+}
+
+var forwardAddCmd = &cobra.Command{
+	Use:     "add <name> <port|local:remote>",
+	Aliases: []string{"start"},
+	Short:   "Forward a local port to a port in a shed",
+	Args:    cobra.ExactArgs(2),
+	RunE:    runForwardAdd,
+}
+
+var forwardRemoveCmd = &cobra.Command{
+	Use:     "remove <name> [local-port]",
+	Aliases: []string{"rm", "stop"},
+	Short:   "Remove a shed's forwards, or the one from a local port",
+	Args:    cobra.RangeArgs(1, 2),
+	RunE:    runForwardRemove,
+}
+
+var forwardListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List persistent forwards",
+	Args:    cobra.NoArgs,
+	RunE:    runForwardList,
+}
+
+var forwardDaemonCmd = &cobra.Command{
+	Use:    "daemon",
+	Short:  "Run the background process that keeps forwards up",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE:   runForwardDaemon,
+}
+
+// Timing for the forward daemon.
+const (
+	// forwardPollInterval is how often the daemon rereads the forwards.
+	forwardPollInterval = 2 * time.Second
+	// forwardRetryMin and forwardRetryMax bound the wait before
+	// reconnecting a dropped forward, which doubles after each failure.
+	forwardRetryMin = time.Second
+	forwardRetryMax = 30 * time.Second
+)
+
+func init() {
+	forwardCmd.AddCommand(forwardAddCmd)
+	forwardCmd.AddCommand(forwardRemoveCmd)
+	forwardCmd.AddCommand(forwardListCmd)
+	forwardCmd.AddCommand(forwardDaemonCmd)
+
+	rootCmd.AddCommand(forwardCmd)
+}
+
+func runForwardAdd(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	localPort, remotePort, err := parseForwardSpec(args[1])
+	if err != nil {
+		return err
+	}
+
+	native, err := useNativeSSH()
+	if err != nil {
+		return err
+	}
+	if native {
+		return fmt.Errorf("shed forward runs OpenSSH, which isn't in use; install OpenSSH or set ssh_client: openssh")
+	}
+
+	serverName, _, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	forwards, err := config.LoadForwards()
+	if err != nil {
+		return err
+	}
+	for _, f := range forwards {
+		if f.LocalPort == localPort {
+			return fmt.Errorf("local port %d is already forwarded to port %d in %s", localPort, f.RemotePort, f.Shed)
+		}
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+	if err != nil {
+		return fmt.Errorf("local port %d is in use", localPort)
+	}
+	ln.Close()
+
+	forward := config.Forward{Shed: name, Server: serverName, LocalPort: localPort, RemotePort: remotePort}
+	if err := forward.Validate(); err != nil {
+		return err
+	}
+	if err := config.SaveForwards(append(forwards, forward)); err != nil {
+		return err
+	}
+
+	if err := startForwardDaemon(); err != nil {
+		return err
+	}
+
+	printSuccess("Forwarding localhost:%d to port %d in %s", localPort, remotePort, name)
+	return nil
+}
+
+func runForwardRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	localPort := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port %q", args[1])
+		}
+		localPort = n
+	}
+
+	forwards, err := config.LoadForwards()
+	if err != nil {
+		return err
+	}
+
+	var kept []config.Forward
+	removed := 0
+	for _, f := range forwards {
+		if f.Shed == name && (localPort == 0 || f.LocalPort == localPort) {
+			removed++
+			continue
+		}
+		kept = append(kept, f)
+	}
+	if removed == 0 {
+		if localPort != 0 {
+			return fmt.Errorf("no forward from local port %d to %s", localPort, name)
+		}
+		return fmt.Errorf("no forwards to %s", name)
+	}
+
+	// The daemon stops removed forwards when it next reads the list
+	if err := config.SaveForwards(kept); err != nil {
+		return err
+	}
+
+	if removed == 1 {
+		printSuccess("Removed 1 forward to %s", name)
+	} else {
+		printSuccess("Removed %d forwards to %s", removed, name)
+	}
+	return nil
+}
+
+// forwardStatus is a forward as shown by shed forward list.
+type forwardStatus struct {
+	config.Forward
+	Status string `json:"status"`
+}
+
+func runForwardList(cmd *cobra.Command, args []string) error {
+	forwards, err := config.LoadForwards()
+	if err != nil {
+		return err
+	}
+
+	daemonPID := forwardDaemonPID()
+	statuses := make([]forwardStatus, 0, len(forwards))
+	for _, f := range forwards {
+		status := "stopped"
+		if daemonPID != 0 {
+			status = "connecting"
+			if conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", f.LocalPort), time.Second); err == nil {
+				conn.Close()
+				status = "up"
+			}
+		}
+		statuses = append(statuses, forwardStatus{Forward: f, Status: status})
+	}
+
+	if ok, err := printStructured(statuses); ok {
+		return err
+	}
+
+	if len(statuses) == 0 {
+		fmt.Println("No forwards.")
+		fmt.Println("\nTo add one:")
+		fmt.Println("  shed forward add <name> <port>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SHED\tSERVER\tLOCAL\tREMOTE\tSTATUS")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", s.Shed, s.Server, s.LocalPort, s.RemotePort, s.Status)
+	}
+	w.Flush()
+
+	if daemonPID == 0 {
+		fmt.Println("\nThe forward daemon isn't running; add a forward to start it.")
+	}
+	return nil
+}
+
+// parseForwardSpec parses "port" or "local:remote".
+func parseForwardSpec(spec string) (int, int, error) {
+	local, remote, ok := strings.Cut(spec, ":")
+	if !ok {
+		remote = local
+	}
+	localPort, err := strconv.Atoi(local)
+	if err != nil || localPort < 1 || localPort > 65535 {
+		return 0, 0, fmt.Errorf("invalid forward %q: expected port or local:remote", spec)
+	}
+	remotePort, err := strconv.Atoi(remote)
+	if err != nil || remotePort < 1 || remotePort > 65535 {
+		return 0, 0, fmt.Errorf("invalid forward %q: expected port or local:remote", spec)
+	}
+	return localPort, remotePort, nil
+}
+
+// forwardDaemonPID returns the PID of the running forward daemon, or 0 if
+// it isn't running. The daemon locks its PID file for as long as it runs,
+// so a file left by a daemon that died, whose PID may since have been
+// reused by another process, is ignored.
+func forwardDaemonPID() int {
+	f, err := os.Open(config.GetForwardDaemonPIDPath())
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	if err := tryLock(f); err == nil {
+		return 0
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+	return pid
+}
+
+// startForwardDaemon starts the forward daemon in the background unless it
+// is already running.
+func startForwardDaemon() error {
+	if forwardDaemonPID() != 0 {
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find shed executable: %w", err)
+	}
+
+	logFile, err := os.OpenFile(config.GetForwardDaemonLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open forward log: %w", err)
+	}
+	defer logFile.Close()
+
+	args := []string{"forward", "daemon"}
+	if configFlag != "" {
+		args = append(args, "--config", configFlag)
+	}
+	daemon := exec.Command(exe, args...)
+	daemon.Stdout = logFile
+	daemon.Stderr = logFile
+	detachProcess(daemon)
+	if err := daemon.Start(); err != nil {
+		return fmt.Errorf("failed to start forward daemon: %w", err)
+	}
+	return daemon.Process.Release()
+}
+
+func runForwardDaemon(cmd *cobra.Command, args []string) error {
+	// Holding the lock makes this the only daemon; one started at the same
+	// time finds it taken and exits
+	pidFile, err := os.OpenFile(config.GetForwardDaemonPIDPath(), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open PID file: %w", err)
+	}
+	defer pidFile.Close()
+	if err := tryLock(pidFile); err != nil {
+		return nil
+	}
+	if err := writePIDFile(pidFile); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+	// The file is emptied rather than removed, so a daemon starting now
+	// can't lock a file that is about to disappear
+	defer pidFile.Truncate(0)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger.Printf("forward daemon started (pid %d)", os.Getpid())
+
+	running := make(map[config.Forward]context.CancelFunc)
+	var wg sync.WaitGroup
+	defer func() {
+		for _, cancel := range running {
+			cancel()
+		}
+		wg.Wait()
+		logger.Printf("forward daemon stopped")
+	}()
+
+	ticker := time.NewTicker(forwardPollInterval)
+	defer ticker.Stop()
+
+	for {
+		forwards, err := config.LoadForwards()
+		if err != nil {
+			logger.Printf("%v", err)
+		} else {
+			wanted := make(map[config.Forward]bool, len(forwards))
+			for _, f := range forwards {
+				wanted[f] = true
+				if _, ok := running[f]; ok {
+					continue
+				}
+				fctx, cancel := context.WithCancel(ctx)
+				running[f] = cancel
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					superviseForward(fctx, f, logger)
+				}()
+			}
+			for f, cancel := range running {
+				if !wanted[f] {
+					logger.Printf("removed %s", describeForward(f))
+					cancel()
+					delete(running, f)
+				}
+			}
+			if len(running) == 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// writePIDFile replaces f's contents with this process's PID.
+func writePIDFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// superviseForward keeps a forward's ssh connection up until ctx is done,
+// reconnecting with backoff when it drops.
+func superviseForward(ctx context.Context, f config.Forward, logger *log.Logger) {
+	delay := forwardRetryMin
+	for {
+		logger.Printf("connecting %s", describeForward(f))
+		started := time.Now()
+		err := runForward(ctx, f)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A connection that stayed up a while starts the backoff over
+		if time.Since(started) > forwardRetryMax {
+			delay = forwardRetryMin
+		}
+		if err == nil {
+			err = fmt.Errorf("ssh exited")
+		}
+		logger.Printf("%s dropped: %v; retrying in %s", describeForward(f), err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, forwardRetryMax)
+	}
+}
+
+// runForward runs ssh for one forward until it exits or ctx is done. The
+// client config is reread each time so server changes are picked up.
+func runForward(ctx context.Context, f config.Forward) error {
+	cfg, err := config.LoadClientConfig()
+	if configFlag != "" {
+		cfg, err = config.LoadClientConfigFromPath(configFlag)
+	}
+	if err != nil {
+		return err
+	}
+	entry, err := cfg.GetServer(f.Server)
+	if err != nil {
+		return err
+	}
+
+	sshArgs := []string{
+		"-N",
+		"-o", "BatchMode=yes",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=15",
+		"-o", "ServerAliveCountMax=3",
+		"-L", fmt.Sprintf("127.0.0.1:%d:localhost:%d", f.LocalPort, f.RemotePort),
+	}
+	sshArgs = append(sshArgs, shedSSHArgs(f.Shed, entry)...)
+
+	ssh := exec.CommandContext(ctx, "ssh", sshArgs...)
+	ssh.Stdout = os.Stdout
+	ssh.Stderr = os.Stderr
+	return ssh.Run()
+}
+
+// describeForward describes a forward for the daemon's log.
+func describeForward(f config.Forward) string {
+	return fmt.Sprintf("localhost:%d -> %s:%d on %s", f.LocalPort, f.Shed, f.RemotePort, f.Server)
+}
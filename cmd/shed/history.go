@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent changes made with shed",
+	Long: `Show the commands that created, deleted, started, or stopped sheds or
+changed servers and deploy keys, most recent last.
+
+History is kept locally in ~/.shed/history.jsonl.`,
+	Args: cobra.NoArgs,
+	RunE: runHistory,
+}
+
+var historyUndoCmd = &cobra.Command{
+	Use:   "undo [number]",
+	Short: "Show how to undo a change",
+	Long: `Print the command that reverses a change in the history, such as the
+create command that recreates a deleted shed. Defaults to the most recent
+change that can be undone. Nothing is run; copy the command to use it.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runHistoryUndo,
+}
+
+var historyLimit int
+
+func init() {
+	historyCmd.Flags().IntVarP(&historyLimit, "number", "n", 20, "Number of entries to show (0 for all)")
+
+	historyCmd.AddCommand(historyUndoCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+// pendingHistory is the change the running command is making. It is written
+// to the history log with the command's result when the command finishes.
+var pendingHistory *config.HistoryEntry
+
+// noteHistory marks the running command as a change to record in history,
+// with an optional command that undoes it.
+func noteHistory(shed, server, undo string) {
+	pendingHistory = &config.HistoryEntry{
+		Shed:   shed,
+		Server: server,
+		Undo:   undo,
+	}
+}
+
+// recordHistory writes the running command's change, if it made one, to the
+// history log along with its result.
+func recordHistory(cmdErr error) {
+	if pendingHistory == nil {
+		return
+	}
+
+	entry := *pendingHistory
+	entry.Command = shellJoin(append([]string{"shed"}, os.Args[1:]...))
+	writeHistory(entry, cmdErr)
+}
+
+// appendHistory writes a change to the history log right away, for
+// commands such as ui that make several changes. command is the shed
+// command that makes the same change.
+func appendHistory(command, shed, server, undo string, cmdErr error) {
+	writeHistory(config.HistoryEntry{
+		Command: command,
+		Shed:    shed,
+		Server:  server,
+		Undo:    undo,
+	}, cmdErr)
+}
+
+// writeHistory appends a change and its result to the history log.
+func writeHistory(entry config.HistoryEntry, cmdErr error) {
+	entry.Time = time.Now().UTC()
+	entry.Result = config.HistoryResultOK
+	if cmdErr != nil {
+		entry.Result = config.HistoryResultError
+		entry.Error = cmdErr.Error()
+	}
+
+	if err := config.AppendHistory(entry); err != nil && verboseFlag {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save history: %v\n", err)
+	}
+}
+
+// shedCommand formats a shed command line, quoting arguments as needed.
+func shedCommand(args ...string) string {
+	return shellJoin(append([]string{"shed"}, args...))
+}
+
+// shellJoin joins arguments into a command line that can be pasted into a shell.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`!*?[](){}<>|&;#~") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	entries, err := config.LoadHistory()
+	if err != nil {
+		return err
+	}
+
+	start := 0
+	if historyLimit > 0 && len(entries) > historyLimit {
+		start = len(entries) - historyLimit
+	}
+
+	if ok, err := printStructured(entries[start:]); ok {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No history yet.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tTIME\tSERVER\tRESULT\tCOMMAND")
+	for i := start; i < len(entries); i++ {
+		e := entries[i]
+		server := e.Server
+		if server == "" {
+			server = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			i+1, e.Time.Local().Format("2006-01-02 15:04"), server, e.Result, e.Command)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runHistoryUndo(cmd *cobra.Command, args []string) error {
+	entries, err := config.LoadHistory()
+	if err != nil {
+		return err
+	}
+
+	var entry *config.HistoryEntry
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(entries) {
+			return fmt.Errorf("invalid history number %q: choose 1-%d", args[0], len(entries))
+		}
+		entry = &entries[n-1]
+	} else {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].Undo != "" && entries[i].Result == config.HistoryResultOK {
+				entry = &entries[i]
+				break
+			}
+		}
+		if entry == nil {
+			fmt.Println("No recent changes can be undone.")
+			return nil
+		}
+	}
+
+	fmt.Printf("%s  (%s)\n", entry.Command, entry.Time.Local().Format("2006-01-02 15:04"))
+	switch {
+	case entry.Result != config.HistoryResultOK:
+		fmt.Println("\nThis command failed, so there is nothing to undo.")
+	case entry.Undo == "":
+		fmt.Println("\nThis change cannot be undone with a shed command.")
+	default:
+		fmt.Printf("\nTo undo, run:\n  %s\n", entry.Undo)
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "List images available for new sheds",
+	Long: `List the images on the server that sheds can be created from, as
+selected by the server's images config. Pass one to shed create with -i.`,
+	Args: cobra.NoArgs,
+	RunE: runImages,
+}
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage shed images on the server",
+}
+
+var imageBuildCmd = &cobra.Command{
+	Use:   "build [context-dir]",
+	Short: "Build a shed image on the server",
+	Long: `Build an image on the server so sheds can be created from it with -i.
+Build output is streamed as it happens.
+
+Without a context directory only the Dockerfile is sent, so it can't COPY
+local files. With one, the directory is uploaded as the build context,
+leaving out .git. The Dockerfile must be inside the context directory, and
+"-f -" reads it from stdin.`,
+	Example: `  shed image build -t shed-go:latest
+  shed image build -f docker/Dockerfile.dev -t shed-dev:latest .
+  shed image build --pull -t shed-python:latest --server other-server`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runImageBuild,
+}
+
+var (
+	imageBuildFile string
+	imageBuildTag  string
+	imageBuildPull bool
+)
+
+func init() {
+	imageBuildCmd.Flags().StringVarP(&imageBuildFile, "file", "f", "", "Dockerfile to build (default: Dockerfile)")
+	imageBuildCmd.Flags().StringVarP(&imageBuildTag, "tag", "t", "", "Name for the built image (required)")
+	imageBuildCmd.Flags().BoolVar(&imageBuildPull, "pull", false, "Pull newer versions of base images")
+	_ = imageBuildCmd.MarkFlagRequired("tag")
+
+	imageCmd.AddCommand(imageBuildCmd)
+	rootCmd.AddCommand(imagesCmd)
+	rootCmd.AddCommand(imageCmd)
+}
+
+func runImages(cmd *cobra.Command, args []string) error {
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.ListImages()
+	if err != nil {
+		return fmt.Errorf("failed to list images: %w", err)
+	}
+
+	if ok, err := printStructured(resp.Images); ok {
+		return err
+	}
+
+	if len(resp.Images) == 0 {
+		fmt.Printf("No images available on %s.\n", serverName)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tID\tSIZE\tCREATED")
+	for _, img := range resp.Images {
+		name := img.Name
+		if img.Default {
+			name += " (default)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, shortID(img.ID), formatBytes(img.Size), formatAgo(img.CreatedAt))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runImageBuild(cmd *cobra.Command, args []string) error {
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	req := config.BuildImageRequest{Tag: imageBuildTag, Pull: imageBuildPull}
+
+	var body io.Reader
+	var contentType string
+	if len(args) == 1 {
+		contextDir := args[0]
+		if req.Dockerfile, err = contextDockerfile(contextDir, imageBuildFile); err != nil {
+			return err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeBuildContext(pw, contextDir))
+		}()
+		defer pr.Close()
+		body, contentType = pr, "application/gzip"
+	} else {
+		path := imageBuildFile
+		if path == "" {
+			path = "Dockerfile"
+		}
+		var dockerfile []byte
+		if path == "-" {
+			dockerfile, err = io.ReadAll(os.Stdin)
+		} else {
+			dockerfile, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read Dockerfile: %w", err)
+		}
+		body, contentType = bytes.NewReader(dockerfile), "text/plain"
+	}
+
+	// Build output goes to stderr when stdout is kept for the image
+	buildOutput := os.Stdout
+	if structuredOutput() {
+		buildOutput = os.Stderr
+	} else {
+		fmt.Printf("Building %s on %s...\n", req.Tag, serverName)
+	}
+
+	image, err := client.BuildImage(req, body, contentType, func(text string) {
+		fmt.Fprint(buildOutput, text)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build image: %w", err)
+	}
+
+	if ok, err := printStructured(image); ok {
+		return err
+	}
+
+	printSuccess("Built %s (%s)", image.Name, formatBytes(image.Size))
+	return nil
+}
+
+// contextDockerfile returns the Dockerfile's path within the build context,
+// given a path relative to the working directory as docker build takes it.
+func contextDockerfile(contextDir, file string) (string, error) {
+	if file == "" {
+		return "", nil
+	}
+	if file == "-" {
+		return "", fmt.Errorf("-f - can't be used with a context directory")
+	}
+
+	absContext, err := filepath.Abs(contextDir)
+	if err != nil {
+		return "", err
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absContext, absFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("dockerfile %s is not inside the build context %s", file, contextDir)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// writeBuildContext writes dir to w as a gzipped tar, leaving out .git.
+func writeBuildContext(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read build context: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// completeImages offers the server's images for --image flags.
+func completeImages(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	entry, _, err := getServerEntry()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	resp, err := NewAPIClientFromEntry(entry).ListImages()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names := make([]string, 0, len(resp.Images))
+	for _, img := range resp.Images {
+		if strings.HasPrefix(img.Name, toComplete) {
+			names = append(names, img.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var infoCmd = &cobra.Command{
+	Use:     "info <name>",
+	Aliases: []string{"status"},
+	Short:   "Show details of a shed",
+	Long: `Show a shed's image, mounts, environment, network address, resource
+limits, sidecar services, uptime, and SSH activity, for debugging a shed
+without access to the Docker host.
+
+Environment variable values are not shown since they may hold secrets.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInfo,
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	shed, err := NewAPIClientFromEntry(entry).GetShed(name)
+	if err != nil {
+		return fmt.Errorf("failed to get shed: %w", err)
+	}
+
+	if ok, err := printStructured(listedShed{Shed: *shed, Server: serverName}); ok {
+		return err
+	}
+
+	status := shed.Status
+	if shed.StoppedReason != "" {
+		status += " (" + shed.StoppedReason + ")"
+	} else if shed.Health != "" {
+		status += " (" + shed.Health + ")"
+	}
+
+	fmt.Printf("Name:        %s\n", shed.Name)
+	fmt.Printf("Server:      %s\n", serverName)
+	fmt.Printf("Status:      %s\n", status)
+	fmt.Printf("Created:     %s (%s)\n", shed.CreatedAt.Local().Format(time.DateTime), formatAgo(shed.CreatedAt))
+	if shed.Repo != "" {
+		fmt.Printf("Repo:        %s\n", shed.Repo)
+	}
+	if shed.Owner != "" {
+		fmt.Printf("Owner:       %s\n", shed.Owner)
+	}
+	if shed.SetupError != "" {
+		fmt.Printf("Setup error: %s\n", shed.SetupError)
+	}
+	if shed.LastActivity != nil {
+		fmt.Printf("Last used:   %s\n", formatAgo(*shed.LastActivity))
+	}
+	if b := shed.Backup; b != nil {
+		if b.Last != nil {
+			fmt.Printf("Backed up:   %s (%s, %s)\n", formatAgo(b.Last.CreatedAt), b.Last.Name, formatBytes(b.Last.Size))
+		}
+		if b.FailedAt != nil {
+			fmt.Printf("Backup:      failed %s: %s\n", formatAgo(*b.FailedAt), b.Error)
+		}
+	}
+
+	d := shed.Details
+	image := shed.Image
+	if d != nil && d.ImageID != "" {
+		image += " (" + shortID(d.ImageID) + ")"
+	}
+	fmt.Printf("Image:       %s\n", image)
+	if d != nil && d.ImagePlatform != "" {
+		platform := d.ImagePlatform
+		if d.Emulated {
+			platform += " (emulated)"
+		}
+		fmt.Printf("Platform:    %s\n", platform)
+		if d.Emulated {
+			fmt.Fprintf(os.Stderr, "Warning: the server emulates %s images, which runs them much slower; rebuild with an image for the server's platform\n", d.ImagePlatform)
+		}
+	} else if shed.Platform != "" {
+		fmt.Printf("Platform:    %s\n", shed.Platform)
+	}
+	fmt.Printf("Container:   %s\n", shortID(shed.ContainerID))
+	fmt.Printf("Resources:   %s\n", resourceSummary(shed.Resources))
+	if h := shed.HealthCheck; h != nil {
+		fmt.Printf("Health cmd:  %s\n", h.Command)
+	}
+	if p := shed.PreStop; p != nil {
+		fmt.Printf("Pre-stop:    %s\n", p.Command)
+	}
+	if shed.NoIdleStop {
+		fmt.Println("Idle stop:   disabled")
+	}
+	if shed.ExpiresAt != nil {
+		fmt.Printf("Expires:     %s, %s\n", shed.ExpiresAt.Local().Format("2006-01-02 15:04"), formatExpiry(*shed))
+	}
+	if len(shed.Labels) > 0 {
+		fmt.Printf("Labels:      %s\n", config.FormatLabels(shed.Labels))
+	}
+	if len(shed.Secrets) > 0 {
+		fmt.Printf("Secrets:     %s\n", formatSecretRefs(shed.Secrets))
+	}
+	if shed.PreviewURL != "" {
+		fmt.Printf("Preview:     %s (port %d)\n", shed.PreviewURL, shed.HTTPPort)
+	} else if shed.HTTPPort > 0 {
+		fmt.Printf("HTTP port:   %d (the server has no preview proxy)\n", shed.HTTPPort)
+	}
+	if shed.Network != "" {
+		fmt.Printf("Network:     %s (%s)\n", shed.Network, config.NetworkHostname(shed.Name, shed.Network))
+	}
+
+	// Older servers don't send details
+	if d == nil {
+		return nil
+	}
+
+	if !d.StartedAt.IsZero() {
+		fmt.Printf("Uptime:      %s\n", formatUptime(time.Since(d.StartedAt)))
+	}
+	if d.RestartCount > 0 {
+		fmt.Printf("Restarts:    %d\n", d.RestartCount)
+	}
+	if len(d.Networks) > 0 {
+		for _, network := range config.SortedLabelKeys(d.Networks) {
+			fmt.Printf("IP address:  %s (%s)\n", d.Networks[network], network)
+		}
+	} else if d.IPAddress != "" {
+		fmt.Printf("IP address:  %s\n", d.IPAddress)
+	}
+	if len(d.Ports) > 0 {
+		fmt.Printf("Ports:       %s\n", strings.Join(d.Ports, ", "))
+	}
+	fmt.Printf("Connections: %d open, last %s\n", d.Connections, formatAgo(d.LastConnection))
+	fmt.Printf("Sessions:    %d\n", d.Sessions)
+
+	if len(shed.Services) > 0 {
+		fmt.Println("\nServices:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, svc := range shed.Services {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", svc.Name, svc.Image, svc.Status)
+		}
+		w.Flush()
+	}
+
+	if len(d.Mounts) > 0 {
+		fmt.Println("\nMounts:")
+		for _, m := range d.Mounts {
+			mode := "rw"
+			if m.ReadOnly {
+				mode = "ro"
+			}
+			fmt.Printf("  %-7s %s -> %s (%s)\n", m.Type, m.Source, m.Target, mode)
+		}
+	}
+
+	if len(d.Env) > 0 {
+		fmt.Println("\nEnvironment:")
+		fmt.Printf("  %s\n", strings.Join(d.Env, ", "))
+	}
+
+	return nil
+}
+
+// shortID trims a Docker ID to the 12 characters docker ps shows.
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
+
+// resourceSummary describes a shed's resource limits.
+func resourceSummary(res *config.Resources) string {
+	if res == nil {
+		return "unlimited"
+	}
+	var parts []string
+	if res.CPUs > 0 {
+		parts = append(parts, strconv.FormatFloat(res.CPUs, 'f', -1, 64)+" CPUs")
+	}
+	if res.Memory != "" {
+		parts = append(parts, res.Memory+" memory")
+	}
+	if res.PidsLimit > 0 {
+		parts = append(parts, strconv.FormatInt(res.PidsLimit, 10)+" processes")
+	}
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatUptime formats a duration in days, hours, and minutes (e.g. "2d 3h").
+func formatUptime(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd %dh", int(d.Hours()/24), int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs [id]",
+	Short: "List or follow background jobs such as detached creates",
+	Long: `List the jobs running in the background on a server, such as sheds being
+created with shed create --detach.
+
+Give a job ID to show that job's status, current phase, and result, and
+--wait to follow its progress until it finishes. Servers keep finished jobs
+for an hour; --finished lists them too. Jobs are lost if the server
+restarts.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runJobs,
+}
+
+var (
+	jobsAll      bool
+	jobsFinished bool
+	jobsWait     bool
+)
+
+// jobPollInterval is how often shed jobs --wait checks on a job.
+const jobPollInterval = time.Second
+
+func init() {
+	jobsCmd.Flags().BoolVarP(&jobsAll, "all", "a", false, "List jobs from all servers")
+	jobsCmd.Flags().BoolVar(&jobsFinished, "finished", false, "Include jobs that finished in the last hour")
+	jobsCmd.Flags().BoolVarP(&jobsWait, "wait", "w", false, "Follow the job's progress until it finishes")
+
+	rootCmd.AddCommand(jobsCmd)
+}
+
+// listedJob is a job with the server it runs on, as listed by shed jobs.
+type listedJob struct {
+	config.Job
+	Server string `json:"server"`
+}
+
+func runJobs(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 {
+		return showJob(args[0])
+	}
+	if jobsWait {
+		return fmt.Errorf("--wait needs a job ID")
+	}
+
+	var jobs []listedJob
+	if jobsAll {
+		results, failed := queryServers(func(client *APIClient) (*config.JobsResponse, error) {
+			return client.ListJobs()
+		})
+		writeServerErrors(os.Stderr, "reach", failed)
+		for _, r := range results {
+			for _, job := range r.value.Jobs {
+				jobs = append(jobs, listedJob{Job: job, Server: r.name})
+			}
+		}
+	} else {
+		entry, serverName, err := getServerEntry()
+		if err != nil {
+			return err
+		}
+		resp, err := NewAPIClientFromEntry(entry).ListJobs()
+		if err != nil {
+			return fmt.Errorf("failed to list jobs: %w", err)
+		}
+		for _, job := range resp.Jobs {
+			jobs = append(jobs, listedJob{Job: job, Server: serverName})
+		}
+	}
+
+	shown := make([]listedJob, 0, len(jobs))
+	for _, job := range jobs {
+		if jobsFinished || job.Status == config.JobRunning {
+			shown = append(shown, job)
+		}
+	}
+
+	if ok, err := printStructured(shown); ok {
+		return err
+	}
+
+	if len(shown) == 0 {
+		if jobsFinished {
+			fmt.Println("No jobs.")
+		} else {
+			fmt.Println("No jobs running.")
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := []string{"ID"}
+	if jobsAll {
+		header = append(header, "SERVER")
+	}
+	header = append(header, "TYPE", "SHED", "STATUS", "PHASE", "STARTED")
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, job := range shown {
+		row := []string{job.ID}
+		if jobsAll {
+			row = append(row, job.Server)
+		}
+		row = append(row, job.Type, job.Shed, job.Status, jobPhase(job.Job), formatAgo(job.CreatedAt))
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	return nil
+}
+
+// showJob prints one job, following it until it finishes with --wait.
+func showJob(id string) error {
+	entry, serverName, err := getServerEntry()
+	if err != nil {
+		return err
+	}
+	client := NewAPIClientFromEntry(entry)
+
+	job, err := client.GetJob(id)
+	if err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+
+	if jobsWait {
+		printProgress := createProgressPrinter()
+		var last config.CreateProgress
+		for {
+			if job.Progress != nil && *job.Progress != last {
+				last = *job.Progress
+				printProgress(last)
+			}
+			if job.Status != config.JobRunning {
+				break
+			}
+			time.Sleep(jobPollInterval)
+			if job, err = client.GetJob(id); err != nil {
+				return fmt.Errorf("failed to get job: %w", err)
+			}
+		}
+	}
+
+	if job.Status == config.JobSucceeded && job.Result != nil {
+		clientConfig.CacheShed(job.Result.Name, serverName, job.Result.Status)
+		if err := clientConfig.Save(); err != nil && verboseFlag {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		}
+	}
+
+	if ok, err := printStructured(job); ok {
+		return err
+	}
+
+	fmt.Printf("Job:      %s\n", job.ID)
+	fmt.Printf("Type:     %s\n", job.Type)
+	fmt.Printf("Shed:     %s\n", job.Shed)
+	fmt.Printf("Server:   %s\n", serverName)
+	fmt.Printf("Status:   %s\n", job.Status)
+	fmt.Printf("Started:  %s\n", job.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	if job.FinishedAt != nil {
+		fmt.Printf("Finished: %s (took %s)\n", job.FinishedAt.Local().Format("2006-01-02 15:04:05"), job.FinishedAt.Sub(job.CreatedAt).Round(time.Second))
+	} else {
+		fmt.Printf("Phase:    %s\n", jobPhase(*job))
+	}
+
+	switch job.Status {
+	case config.JobSucceeded:
+		if job.Result != nil && job.Result.SetupError != "" {
+			fmt.Fprintf(os.Stderr, "\nWarning: %s\n", job.Result.SetupError)
+		}
+		fmt.Printf("\nConnect with:\n  shed console %s\n", job.Shed)
+	case config.JobFailed:
+		if job.Error != nil {
+			return fmt.Errorf("job failed: %s", job.Error.Message)
+		}
+		return fmt.Errorf("job failed")
+	}
+	return nil
+}
+
+// jobPhase describes the phase a job is in, or "-" before its first
+// progress event.
+func jobPhase(job config.Job) string {
+	if job.Progress == nil {
+		return "-"
+	}
+	phase := createPhaseLabels[job.Progress.Phase]
+	if phase == "" {
+		phase = job.Progress.Phase
+	}
+	if job.Progress.Status == config.ProgressFailed {
+		phase += " (failed)"
+	}
+	return phase
+}
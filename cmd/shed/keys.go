@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage SSH keys allowed to connect to sheds",
+	Long: `Manage the SSH public keys a shed server accepts.
+
+Until a key is registered (or listed under authorized_keys in server.yaml),
+the server accepts any key. Once one exists, only registered keys can
+connect. A key added with --shed may only connect to that shed.`,
+}
+
+var keysAddCmd = &cobra.Command{
+	Use:   "add [public-key-file]",
+	Short: "Register a public key",
+	Long: `Register a public key with the server.
+
+Defaults to the first of ~/.ssh/id_ed25519.pub, ~/.ssh/id_ecdsa.pub and
+~/.ssh/id_rsa.pub, named after this machine's hostname.`,
+	Example: `  shed keys add
+  shed keys add ~/.ssh/work.pub --name work-laptop
+  shed keys add ci.pub --name ci --shed widget`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runKeysAdd,
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered keys",
+	Args:  cobra.NoArgs,
+	RunE:  runKeysList,
+}
+
+var keysRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a registered key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runKeysRemove,
+}
+
+var (
+	keysAddName     string
+	keysAddShed     string
+	keysRemoveForce bool
+)
+
+// defaultPublicKeys are tried in order when no key file is given.
+var defaultPublicKeys = []string{"id_ed25519.pub", "id_ecdsa.pub", "id_rsa.pub"}
+
+func init() {
+	keysAddCmd.Flags().StringVar(&keysAddName, "name", "", "Name for the key (default: this machine's hostname)")
+	keysAddCmd.Flags().StringVar(&keysAddShed, "shed", "", "Only allow the key to connect to this shed")
+	keysRemoveCmd.Flags().BoolVarP(&keysRemoveForce, "force", "f", false, "Remove without confirmation")
+
+	keysCmd.AddCommand(keysAddCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysRemoveCmd)
+
+	rootCmd.AddCommand(keysCmd)
+}
+
+// findPublicKey returns the path of the first default public key that exists.
+func findPublicKey() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	for _, name := range defaultPublicKeys {
+		path := filepath.Join(home, ".ssh", name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no public key found in %s; pass a key file", filepath.Join(home, ".ssh"))
+}
+
+func runKeysAdd(cmd *cobra.Command, args []string) error {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		found, err := findPublicKey()
+		if err != nil {
+			return err
+		}
+		path = found
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	name := keysAddName
+	if name == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to get hostname, pass --name: %w", err)
+		}
+		name = config.ShedNameFromRepo(strings.Split(hostname, ".")[0])
+	}
+
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	noteHistory("", serverName, shedCommand("keys", "remove", name, "--server", serverName))
+	key, err := client.AddKey(&config.AddAuthorizedKeyRequest{
+		Name:      name,
+		PublicKey: strings.TrimSpace(string(data)),
+		Shed:      keysAddShed,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add key: %w", err)
+	}
+
+	printSuccess("Added key %s (%s) on %s", key.Name, key.Fingerprint, serverName)
+	if key.Shed != "" {
+		fmt.Printf("  It can only connect to shed %s.\n", key.Shed)
+	}
+	return nil
+}
+
+func runKeysList(cmd *cobra.Command, args []string) error {
+	client, _, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.ListKeys()
+	if err != nil {
+		return fmt.Errorf("failed to list keys: %w", err)
+	}
+
+	if ok, err := printStructured(resp.Keys); ok {
+		return err
+	}
+
+	if len(resp.Keys) == 0 {
+		fmt.Println("No keys registered.")
+		fmt.Println("\nTo register this machine's key:")
+		fmt.Println("  shed keys add")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFINGERPRINT\tSHED\tADDED")
+	for _, key := range resp.Keys {
+		shed := key.Shed
+		if shed == "" {
+			shed = "(all)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.Name, key.Fingerprint, shed, key.AddedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runKeysRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	if !keysRemoveForce {
+		if !confirm(fmt.Sprintf("Remove key %q on %s? It will no longer be able to connect.", name, serverName)) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	noteHistory("", serverName, "")
+	if err := client.RemoveKey(name); err != nil {
+		return fmt.Errorf("failed to remove key: %w", err)
+	}
+
+	printSuccess("Removed key %s", name)
+	return nil
+}
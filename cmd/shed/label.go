@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var labelCmd = &cobra.Command{
+	Use:   "label <name> [key=value | key-]...",
+	Short: "Show or change a shed's labels",
+	Long: `Show a shed's labels, or set and remove them.
+
+Each key=value argument sets a label and each key- argument removes one.
+With no arguments the shed's current labels are printed. Labels can also be
+given at creation with shed create --label, and used to filter sheds with
+shed list --label:
+
+  shed label dev team=payments env-
+  shed list --label team=payments`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runLabel,
+}
+
+func init() {
+	rootCmd.AddCommand(labelCmd)
+}
+
+func runLabel(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	req, err := parseLabelChanges(args[1:])
+	if err != nil {
+		return err
+	}
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+	client := NewAPIClientFromEntry(entry)
+
+	shed, err := client.GetShed(name)
+	if err != nil {
+		return fmt.Errorf("failed to get shed: %w", err)
+	}
+
+	if len(args) > 1 {
+		noteHistory(name, serverName, labelUndo(name, shed.Labels, req))
+		shed, err = client.UpdateLabels(name, req)
+		if err != nil {
+			return fmt.Errorf("failed to update labels: %w", err)
+		}
+	}
+
+	if ok, err := printStructured(shed.Labels); ok {
+		return err
+	}
+
+	if len(shed.Labels) == 0 {
+		fmt.Printf("Shed %s has no labels.\n", name)
+		return nil
+	}
+	for _, k := range config.SortedLabelKeys(shed.Labels) {
+		fmt.Printf("%s=%s\n", k, shed.Labels[k])
+	}
+	return nil
+}
+
+// parseLabelChanges parses key=value and key- arguments into an update.
+func parseLabelChanges(args []string) (config.UpdateLabelsRequest, error) {
+	var req config.UpdateLabelsRequest
+	for _, arg := range args {
+		if key, ok := strings.CutSuffix(arg, "-"); ok && !strings.Contains(arg, "=") {
+			if err := config.ValidateLabelKey(key); err != nil {
+				return req, err
+			}
+			req.Remove = append(req.Remove, key)
+			continue
+		}
+
+		key, value, err := config.ParseLabel(arg)
+		if err != nil {
+			return req, fmt.Errorf("%w (use key- to remove a label)", err)
+		}
+		if req.Set == nil {
+			req.Set = make(map[string]string)
+		}
+		req.Set[key] = value
+	}
+	return req, nil
+}
+
+// labelUndo returns the label command that restores labels after req is
+// applied.
+func labelUndo(name string, labels map[string]string, req config.UpdateLabelsRequest) string {
+	keys := append(config.SortedLabelKeys(req.Set), req.Remove...)
+	sort.Strings(keys)
+
+	args := []string{"label", name}
+	for _, k := range slices.Compact(keys) {
+		if v, ok := labels[k]; ok {
+			args = append(args, k+"="+v)
+		} else if _, set := req.Set[k]; set {
+			args = append(args, k+"-")
+		}
+	}
+	if len(args) == 2 {
+		return ""
+	}
+	return shedCommand(args...)
+}
+
+// parseLabelFlags parses repeated --label key=value flags.
+func parseLabelFlags(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, err := config.ParseLabel(spec)
+		if err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
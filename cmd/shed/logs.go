@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <name>",
+	Short: "Show a shed's container output",
+	Long: `Show the output of a shed's container.
+
+Use --follow to keep streaming new output until interrupted, and --tail
+to start from the last N lines.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLogs,
+}
+
+var (
+	logsFollow bool
+	logsTail   int
+)
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Stream new output as it is written")
+	logsCmd.Flags().IntVarP(&logsTail, "tail", "n", -1, "Number of lines to show from the end, or -1 for all")
+
+	rootCmd.AddCommand(logsCmd)
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	_, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	opts := config.LogOptions{Follow: logsFollow, Tail: logsTail}
+	if err := client.StreamLogs(name, opts, os.Stdout); err != nil {
+		return fmt.Errorf("failed to get logs: %w", err)
+	}
+	return nil
+}
@@ -2,8 +2,10 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -13,9 +15,10 @@ import (
 
 var (
 	// Global flags
-	serverFlag  string
-	verboseFlag bool
-	configFlag  string
+	serverFlag    string
+	verboseFlag   bool
+	configFlag    string
+	sshClientFlag string
 
 	// Loaded configuration
 	clientConfig *config.ClientConfig
@@ -31,8 +34,12 @@ on one or more shed servers.`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		// Skip config loading for version command
-		if cmd.Name() == "version" {
+		if err := validateOutput(); err != nil {
+			return err
+		}
+
+		// Skip config loading for version and update, which don't use it
+		if cmd == versionCmd || cmd == updateCmd {
 			return nil
 		}
 
@@ -66,21 +73,29 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&serverFlag, "server", "s", "", "Server to use (default: configured default)")
 	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "Enable verbose output")
 	rootCmd.PersistentFlags().StringVarP(&configFlag, "config", "c", "", "Path to config file")
+	rootCmd.PersistentFlags().StringVar(&sshClientFlag, "ssh-client", "", "SSH client for connecting to sheds: auto, openssh, or native (default: configured, or auto)")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", outputTable, "Output format: table, json, or yaml")
+	_ = rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{outputTable, outputJSON, outputYAML}, cobra.ShellCompDirectiveNoFileComp))
 
 	// Add subcommands
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(serverCmd)
 	rootCmd.AddCommand(createCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(findCmd)
 	rootCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
 	rootCmd.AddCommand(consoleCmd)
 	rootCmd.AddCommand(execCmd)
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	recordHistory(err)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -113,3 +128,13 @@ func printError(msg string, suggestions ...string) {
 		}
 	}
 }
+
+// confirm prints a yes/no prompt and reports whether the user answered yes.
+func confirm(prompt string) bool {
+	fmt.Print(prompt + " [y/N] ")
+
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/sshclient"
+	"github.com/charliek/shed/internal/terminal"
+)
+
+// nativeSSHToShed connects to a shed with the built-in SSH client and exits
+// with the remote command's exit code. It returns only on success or if the
+// session couldn't be started.
+func nativeSSHToShed(name, serverName string, entry *config.ServerEntry, command []string) error {
+	code, err := runNativeSSH(name, serverName, entry, command)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+	return nil
+}
+
+// runNativeSSH runs the session, putting the terminal in raw mode for its
+// duration when there is one.
+func runNativeSSH(name, serverName string, entry *config.ServerEntry, command []string) (int, error) {
+	knownKeys, err := config.GetKnownHosts(entry.Host, entry.SSHPort)
+	if err != nil {
+		return 0, err
+	}
+	if len(knownKeys) == 0 {
+		printError(fmt.Sprintf("no host key recorded for server %q", serverName),
+			"shed server refresh "+serverName+"  # Fetch the server's host key")
+		return 0, fmt.Errorf("no host key for %s", serverName)
+	}
+	var hostKeys []gossh.PublicKey
+	for _, knownKey := range knownKeys {
+		hostKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(knownKey))
+		if err != nil {
+			return 0, fmt.Errorf("invalid host key for %s in %s: %w", serverName, config.GetKnownHostsPath(), err)
+		}
+		hostKeys = append(hostKeys, hostKey)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find home directory: %w", err)
+	}
+	signers, closeAgent := sshclient.LoadSigners(filepath.Join(home, ".ssh"))
+	defer closeAgent()
+
+	opts := sshclient.Options{
+		Host:     config.NormalizeHost(entry.Host),
+		Port:     entry.SSHPort,
+		User:     name,
+		HostKeys: hostKeys,
+		Signers:  signers,
+		Command:  strings.Join(command, " "),
+		Env:      sendEnv(clientConfig.SendEnvPatterns()),
+		Stdin:    os.Stdin,
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+	}
+
+	if isInteractive() {
+		term := os.Getenv("TERM")
+		if term == "" {
+			term = "xterm-256color"
+		}
+		rows, cols := terminalSize()
+		opts.TTY = &sshclient.TTY{
+			Term:   term,
+			Size:   sshclient.WindowSize{Rows: rows, Cols: cols},
+			Resize: watchTerminalSize(),
+		}
+
+		restore, err := enterRawMode()
+		if err != nil {
+			return 0, err
+		}
+		defer restore()
+	}
+
+	if verboseFlag {
+		fmt.Fprintln(os.Stderr, "Using the built-in SSH client")
+	}
+	return sshclient.Run(opts)
+}
+
+// sendEnv returns the local environment variables matching patterns, as
+// NAME=value pairs.
+func sendEnv(patterns []string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if terminal.MatchEnv(patterns, name) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// watchTerminalSize delivers the terminal's size each time it changes.
+func watchTerminalSize() <-chan sshclient.WindowSize {
+	sizes := make(chan sshclient.WindowSize, 1)
+	resized, _ := watchResize()
+	go func() {
+		for range resized {
+			rows, cols := terminalSize()
+			// Drop a stale size that hasn't been sent yet
+			select {
+			case <-sizes:
+			default:
+			}
+			sizes <- sshclient.WindowSize{Rows: rows, Cols: cols}
+		}
+	}()
+	return sizes
+}
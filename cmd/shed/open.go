@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var openCmd = &cobra.Command{
+	Use:   "open <name>",
+	Short: "Open a shed in an editor over SSH",
+	Long: `Open a shed's /workspace in an editor's remote SSH mode.
+
+The shed's SSH config entry (shed-<name>) is added to ~/.ssh/config, or
+the include file if installed with "shed ssh-config --install --include",
+or updated if it is out of date. Other entries are left alone.
+
+Supported editors:
+  code    Visual Studio Code with the Remote - SSH extension (default)
+  cursor  Cursor
+  zed     Zed`,
+	Args: cobra.ExactArgs(1),
+	RunE: runOpen,
+}
+
+var openEditor string
+
+func init() {
+	openCmd.Flags().StringVarP(&openEditor, "editor", "e", "code", "Editor to open: code, cursor, or zed")
+	openCmd.Flags().BoolVar(&startFlag, "start", false, "Start or resume the shed first if it isn't running")
+
+	rootCmd.AddCommand(openCmd)
+}
+
+func runOpen(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	editorArgs, err := editorRemoteArgs(openEditor, sshHostAlias(name))
+	if err != nil {
+		return err
+	}
+	editorPath, err := exec.LookPath(openEditor)
+	if err != nil {
+		return fmt.Errorf("%s not found in PATH: %w", openEditor, err)
+	}
+
+	serverName, entry, err := findShedToConnect(name)
+	if err != nil {
+		return err
+	}
+
+	entries := generateEntries([]shedInfo{{name: name, serverName: serverName, server: entry}})
+	path, err := ensureSSHConfigEntry(entries[0])
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		printSuccess("Updated SSH config at %s", path)
+	}
+
+	// Editor launchers hand off to the running editor and return
+	editor := exec.Command(editorPath, editorArgs...)
+	editor.Stdin = os.Stdin
+	editor.Stdout = os.Stdout
+	editor.Stderr = os.Stderr
+	if err := editor.Run(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", openEditor, err)
+	}
+	return nil
+}
+
+// editorRemoteArgs returns the arguments that open the workspace on an SSH
+// host alias in an editor.
+func editorRemoteArgs(editor, host string) ([]string, error) {
+	switch editor {
+	case "code", "cursor":
+		return []string{"--remote", "ssh-remote+" + host, config.WorkspacePath}, nil
+	case "zed":
+		return []string{"ssh://" + host + config.WorkspacePath}, nil
+	}
+	return nil, fmt.Errorf("unknown editor %q (must be code, cursor, or zed)", editor)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFlag is the format selected with --output.
+var outputFlag string
+
+// validateOutput checks the --output flag.
+func validateOutput() error {
+	switch outputFlag {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid --output %q: must be table, json, or yaml", outputFlag)
+}
+
+// structuredOutput reports whether results should be printed as JSON or
+// YAML instead of for people. Progress and success messages are left out
+// so the output can be parsed.
+func structuredOutput() bool {
+	return outputFlag == outputJSON || outputFlag == outputYAML
+}
+
+// printStructured prints v to stdout in the --output format if it is JSON
+// or YAML, reporting whether it did. Commands print their usual table when
+// it returns false:
+//
+//	if ok, err := printStructured(sheds); ok {
+//		return err
+//	}
+func printStructured(v interface{}) (bool, error) {
+	if !structuredOutput() {
+		return false, nil
+	}
+	return true, writeStructured(os.Stdout, outputFlag, v)
+}
+
+// writeStructured writes v as JSON or YAML. YAML uses the JSON field names
+// and order, so both formats describe the same document.
+func writeStructured(w io.Writer, format string, v interface{}) error {
+	// Empty lists are printed as such rather than as null
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+		v = []interface{}{}
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	if format == outputJSON {
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	// JSON is YAML, so decoding it keeps the keys in order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// blockStyle clears the flow style and quoting YAML nodes decoded from
+// JSON carry, so they are written as ordinary block YAML.
+func blockStyle(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		// Keep quotes only where a plain scalar would change type
+		node.Style = 0
+	} else {
+		node.Style &^= yaml.FlowStyle
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var portsCmd = &cobra.Command{
+	Use:   "ports <name>",
+	Short: "List the ports processes in a shed are listening on",
+	Long: `List the TCP ports processes in a running shed are listening on.
+
+Ports bound to loopback only accept connections from inside the shed and
+can't be forwarded; bind servers to 0.0.0.0 to reach them with shed browse
+or ssh -L.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPorts,
+}
+
+var browseCmd = &cobra.Command{
+	Use:   "browse <name> [port]",
+	Short: "Forward a web server in a shed and open it in a browser",
+	Long: `Forward a port in a shed to localhost over SSH and open it in a browser.
+
+Without a port, the ports processes in the shed are listening on are
+detected. If there is one it is used; otherwise you are asked to pick.
+The same local port is used if it is free, or another one if not. The
+forward runs until you press Ctrl-C.
+
+Requires OpenSSH.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runBrowse,
+}
+
+var (
+	browseLocalPort int
+	browseNoOpen    bool
+)
+
+// forwardReadyTimeout bounds waiting for ssh to start listening on the
+// local end of a forward.
+const forwardReadyTimeout = 15 * time.Second
+
+func init() {
+	browseCmd.Flags().IntVarP(&browseLocalPort, "local-port", "L", 0, "Local port to forward from (default: the shed's port if free)")
+	browseCmd.Flags().BoolVar(&browseNoOpen, "no-open", false, "Forward the port without opening a browser")
+	browseCmd.Flags().BoolVar(&startFlag, "start", false, "Start or resume the shed first if it isn't running")
+
+	rootCmd.AddCommand(portsCmd)
+	rootCmd.AddCommand(browseCmd)
+}
+
+func runPorts(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	_, entry, err := findRunningShed(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := NewAPIClientFromEntry(entry).ListPorts(name)
+	if err != nil {
+		return fmt.Errorf("failed to list ports: %w", err)
+	}
+
+	if ok, err := printStructured(resp.Ports); ok {
+		return err
+	}
+
+	if len(resp.Ports) == 0 {
+		fmt.Printf("No processes in %s are listening on TCP ports.\n", name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PORT\tADDRESS\tPROCESS\tPID")
+	for _, p := range resp.Ports {
+		address := p.Address
+		if p.Loopback() {
+			address += " (shed only)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", p.Port, address, orDash(p.Process), pidOrDash(p.PID))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runBrowse(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	port := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port %q", args[1])
+		}
+		port = n
+	}
+	if browseLocalPort < 0 || browseLocalPort > 65535 {
+		return fmt.Errorf("invalid local port %d", browseLocalPort)
+	}
+
+	native, err := useNativeSSH()
+	if err != nil {
+		return err
+	}
+	if native {
+		return fmt.Errorf("shed browse forwards ports with OpenSSH, which isn't in use; install OpenSSH or set ssh_client: openssh")
+	}
+
+	_, entry, err := findShedToConnect(name)
+	if err != nil {
+		return err
+	}
+
+	if port == 0 {
+		resp, err := NewAPIClientFromEntry(entry).ListPorts(name)
+		if err != nil {
+			return fmt.Errorf("failed to list ports: %w", err)
+		}
+		port, err = choosePort(name, resp.Ports)
+		if err != nil || port == 0 {
+			return err
+		}
+	}
+
+	localPort := browseLocalPort
+	if localPort == 0 {
+		if localPort, err = freeLocalPort(port); err != nil {
+			return err
+		}
+	}
+
+	sshArgs := []string{
+		"-N",
+		"-o", "ExitOnForwardFailure=yes",
+		"-L", fmt.Sprintf("127.0.0.1:%d:localhost:%d", localPort, port),
+	}
+	sshArgs = append(sshArgs, shedSSHArgs(name, entry)...)
+
+	ssh := exec.Command("ssh", sshArgs...)
+	ssh.Stdin = os.Stdin
+	ssh.Stdout = os.Stdout
+	ssh.Stderr = os.Stderr
+	if err := ssh.Start(); err != nil {
+		return fmt.Errorf("failed to run ssh: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- ssh.Wait() }()
+
+	url := fmt.Sprintf("http://localhost:%d", localPort)
+	if err := waitForForward(localPort, exited); err != nil {
+		_ = ssh.Process.Kill()
+		return err
+	}
+
+	fmt.Printf("Forwarding %s to port %d in %s. Press Ctrl-C to stop.\n", url, port, name)
+	if !browseNoOpen {
+		if err := openBrowser(url); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to open browser: %v\n", err)
+		}
+	}
+
+	if err := <-exited; err != nil {
+		return fmt.Errorf("ssh exited: %w", err)
+	}
+	return nil
+}
+
+// choosePort picks a detected port to forward. Loopback ports are left out
+// since they can't be forwarded. Zero means the user cancelled.
+func choosePort(name string, ports []config.ListeningPort) (int, error) {
+	var candidates []config.ListeningPort
+	var loopback []string
+	seen := make(map[int]bool)
+	for _, p := range ports {
+		if p.Loopback() {
+			loopback = append(loopback, strconv.Itoa(p.Port))
+			continue
+		}
+		if !seen[p.Port] {
+			seen[p.Port] = true
+			candidates = append(candidates, p)
+		}
+	}
+
+	if len(candidates) == 0 {
+		msg := fmt.Sprintf("no ports to forward were found in %s", name)
+		if len(loopback) > 0 {
+			msg += fmt.Sprintf(" (listening on loopback only: %s; bind servers to 0.0.0.0 to forward them)", strings.Join(loopback, ", "))
+		}
+		return 0, fmt.Errorf("%s", msg)
+	}
+	if len(candidates) == 1 {
+		return candidates[0].Port, nil
+	}
+
+	if !isInteractive() {
+		ports := make([]string, len(candidates))
+		for i, p := range candidates {
+			ports[i] = strconv.Itoa(p.Port)
+		}
+		return 0, fmt.Errorf("%s is listening on several ports (%s); give the port to forward", name, strings.Join(ports, ", "))
+	}
+
+	fmt.Printf("Ports in %s:\n\n", name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  #\tPORT\tPROCESS")
+	for i, p := range candidates {
+		fmt.Fprintf(w, "  %d\t%d\t%s\n", i+1, p.Port, orDash(p.Process))
+	}
+	w.Flush()
+
+	fmt.Printf("\nSelect a port number [1]: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	response, err := reader.ReadString('\n')
+	if err != nil && response == "" {
+		return 0, nil
+	}
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return candidates[0].Port, nil
+	}
+
+	n, err := strconv.Atoi(response)
+	if err != nil || n < 1 || n > len(candidates) {
+		return 0, fmt.Errorf("invalid selection %q: choose 1-%d", response, len(candidates))
+	}
+	return candidates[n-1].Port, nil
+}
+
+// freeLocalPort returns port if nothing is listening on it locally, or
+// another free port if something is.
+func freeLocalPort(port int) (int, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return 0, fmt.Errorf("failed to find a free local port: %w", err)
+		}
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitForForward waits for ssh to listen on the local port, failing if it
+// exits first.
+func waitForForward(port int, exited <-chan error) error {
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	deadline := time.Now().Add(forwardReadyTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = fmt.Errorf("exited early")
+			}
+			return fmt.Errorf("ssh failed to forward port: %w", err)
+		default:
+		}
+
+		if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out waiting for ssh to forward port %d", port)
+}
+
+// openBrowser opens url in the default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+// orDash returns s, or "-" if it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// pidOrDash formats a PID, or "-" if it is unknown.
+func pidOrDash(pid int) string {
+	if pid == 0 {
+		return "-"
+	}
+	return strconv.Itoa(pid)
+}
@@ -0,0 +1,66 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// execReplace replaces shed with the program at path, so it gets the
+// terminal and signals directly. It only returns on failure.
+func execReplace(path string, args []string) error {
+	return syscall.Exec(path, args, os.Environ())
+}
+
+// watchResize delivers a value each time the terminal is resized, until
+// stop is called.
+func watchResize() (resized <-chan struct{}, stop func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+
+	c := make(chan struct{}, 1)
+	go func() {
+		for range sigs {
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return c, func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}
+}
+
+// detachProcess makes cmd run in its own session, so it outlives the
+// terminal shed was started from.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// tryLock takes an exclusive lock on f without waiting, failing if another
+// process holds it. The lock is released when f is closed or the process
+// exits.
+func tryLock(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+}
+
+// stdinReady waits up to timeout for input on stdin, reporting whether a
+// read would return without blocking.
+func stdinReady(timeout time.Duration) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(os.Stdin.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout/time.Millisecond))
+	if errors.Is(err, unix.EINTR) {
+		return false, nil
+	}
+	return n > 0, err
+}
@@ -0,0 +1,153 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Process creation flags that detach a child from shed's console.
+const (
+	detachedProcess       = 0x00000008
+	createNewProcessGroup = 0x00000200
+)
+
+// procPeekConsoleInput reads console input events without consuming them,
+// and procReadConsoleInput consumes them; x/sys/windows has no wrappers for
+// either.
+var (
+	kernel32             = windows.NewLazySystemDLL("kernel32.dll")
+	procPeekConsoleInput = kernel32.NewProc("PeekConsoleInputW")
+	procReadConsoleInput = kernel32.NewProc("ReadConsoleInputW")
+)
+
+// inputRecord is a console INPUT_RECORD, with the fields of a KEY_EVENT.
+type inputRecord struct {
+	eventType       uint16
+	_               uint16
+	keyDown         int32
+	repeatCount     uint16
+	virtualKeyCode  uint16
+	virtualScanCode uint16
+	char            uint16
+	controlKeyState uint32
+}
+
+// keyEvent is the eventType of key presses and releases.
+const keyEvent = 0x0001
+
+// resizePollInterval is how often the terminal size is checked, as Windows
+// has no signal for resizes.
+const resizePollInterval = 250 * time.Millisecond
+
+// execReplace runs the program at path in place of shed. Windows can't
+// replace a process, so it runs as a child sharing the console, and shed
+// exits with its exit code. It only returns on failure.
+func execReplace(path string, args []string) error {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Ctrl-C reaches every process on the console; leave it to the child
+	signal.Ignore(os.Interrupt)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
+
+// watchResize delivers a value each time the terminal is resized, until
+// stop is called.
+func watchResize() (resized <-chan struct{}, stop func()) {
+	c := make(chan struct{}, 1)
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(resizePollInterval)
+		defer ticker.Stop()
+
+		rows, cols := terminalSize()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if r, cl := terminalSize(); r != rows || cl != cols {
+				rows, cols = r, cl
+				select {
+				case c <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return c, func() { close(done) }
+}
+
+// detachProcess makes cmd run without shed's console, so it outlives the
+// window shed was started from.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess | createNewProcessGroup}
+}
+
+// tryLock takes an exclusive lock on f without waiting, failing if another
+// process holds it. The lock is released when f is closed or the process
+// exits. Windows locks are mandatory, so the locked byte lies far past the
+// end of the file, leaving its contents readable.
+func tryLock(f *os.File) error {
+	ol := &windows.Overlapped{Offset: 0xFFFFFFFF, OffsetHigh: 0x7FFFFFFF}
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+}
+
+// stdinReady waits up to timeout for input on stdin, reporting whether a
+// read would return without blocking. A console also signals focus, mouse,
+// and key release events, which a read skips while it waits for a key, so
+// those are discarded. Only the events inspected are discarded, leaving any
+// that arrived since, or lie past the first batch, for the next call.
+func stdinReady(timeout time.Duration) (bool, error) {
+	h := windows.Handle(os.Stdin.Fd())
+	event, err := windows.WaitForSingleObject(h, uint32(timeout/time.Millisecond))
+	if err != nil {
+		return false, err
+	}
+	if event != windows.WAIT_OBJECT_0 {
+		return false, nil
+	}
+
+	var records [16]inputRecord
+	var n uint32
+	if r, _, err := procPeekConsoleInput.Call(uintptr(h), uintptr(unsafe.Pointer(&records[0])), uintptr(len(records)), uintptr(unsafe.Pointer(&n))); r == 0 {
+		// Not a console, such as a pipe, which is readable once signaled
+		if errors.Is(err, windows.ERROR_INVALID_HANDLE) {
+			return true, nil
+		}
+		return false, err
+	}
+	for _, rec := range records[:n] {
+		if rec.eventType == keyEvent && rec.keyDown != 0 && rec.char != 0 {
+			return true, nil
+		}
+	}
+	if r, _, err := procReadConsoleInput.Call(uintptr(h), uintptr(unsafe.Pointer(&records[0])), uintptr(n), uintptr(unsafe.Pointer(&n))); r == 0 {
+		return false, err
+	}
+	return false, nil
+}
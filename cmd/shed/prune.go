@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove shed resources nothing uses",
+	Long: `Remove shed resources on the server that nothing uses:
+
+  - workspace volumes of sheds that no longer exist
+  - shared clone volumes no shed uses
+  - dedicated networks of sheds that no longer exist
+  - shed containers whose workspace volume is gone
+  - untagged images no container uses, among those the images config selects
+
+With --stopped-days, sheds stopped for at least that many days are also
+deleted along with their workspaces. Use --dry-run to see what would be
+removed first.`,
+	Example: `  shed prune --dry-run
+  shed prune --stopped-days 30
+  shed prune -f --server other-server`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+var (
+	pruneDryRun      bool
+	pruneStoppedDays int
+	pruneForce       bool
+)
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be removed without removing it")
+	pruneCmd.Flags().IntVar(&pruneStoppedDays, "stopped-days", 0, "Also delete sheds stopped for at least this many days")
+	pruneCmd.Flags().BoolVarP(&pruneForce, "force", "f", false, "Skip confirmation")
+
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	req := &config.PruneRequest{DryRun: pruneDryRun, StoppedDays: pruneStoppedDays}
+	if !pruneDryRun && !pruneForce {
+		prompt := fmt.Sprintf("Remove unused volumes, networks, containers, and images on %s?", serverName)
+		if pruneStoppedDays > 0 {
+			prompt = fmt.Sprintf("Remove unused resources on %s, and delete sheds stopped for %d days or more?", serverName, pruneStoppedDays)
+		}
+		if !confirm(prompt) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	if !pruneDryRun {
+		noteHistory("", serverName, "")
+	}
+	resp, err := client.Prune(req)
+	if err != nil {
+		return fmt.Errorf("failed to prune: %w", err)
+	}
+
+	if ok, err := printStructured(resp); ok {
+		return err
+	}
+
+	if len(resp.Resources) == 0 {
+		fmt.Println("Nothing to prune.")
+		return nil
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tSIZE\tREASON")
+	for _, r := range resp.Resources {
+		name := r.Name
+		if r.Kind == config.PruneImage {
+			name = shortID(name)
+		}
+		size := "-"
+		if r.Size > 0 {
+			size = formatBytes(r.Size)
+		}
+		reason := r.Reason
+		if r.Error != "" {
+			reason = "failed: " + r.Error
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Kind, name, size, reason)
+	}
+	w.Flush()
+
+	fmt.Println()
+	if resp.DryRun {
+		fmt.Printf("Would reclaim %s. Run without --dry-run to remove.\n", formatBytes(resp.SpaceReclaimed))
+		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d of %d resources; reclaimed %s", failed, len(resp.Resources), formatBytes(resp.SpaceReclaimed))
+	}
+	printSuccess("Reclaimed %s", formatBytes(resp.SpaceReclaimed))
+	return nil
+}
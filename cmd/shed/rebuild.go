@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var rebuildCmd = &cobra.Command{
+	Use:   "rebuild <name>",
+	Short: "Recreate a shed's container from its image",
+	Long: `Replace a shed's container with a new one, keeping its workspace volume,
+settings, and mounts. Use it to pick up an updated image, or switch to
+another with --image. --pull fetches the image first even if the server
+already has it.
+
+Anything outside /workspace, such as installed packages and running
+processes, is lost. The shed is running afterwards.`,
+	Example: `  shed rebuild widget
+  shed rebuild widget --pull
+  shed rebuild widget --image shed-go:1.25`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRebuild,
+}
+
+var (
+	rebuildImage string
+	rebuildPull  bool
+)
+
+func init() {
+	rebuildCmd.Flags().StringVarP(&rebuildImage, "image", "i", "", "Image to rebuild from (default: the shed's current image)")
+	rebuildCmd.Flags().BoolVar(&rebuildPull, "pull", false, "Pull the image even if it is present")
+	_ = rebuildCmd.RegisterFlagCompletionFunc("image", completeImages)
+
+	rootCmd.AddCommand(rebuildCmd)
+}
+
+func runRebuild(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+	client := NewAPIClientFromEntry(entry)
+
+	// Switching images can be undone by switching back
+	undo := ""
+	if rebuildImage != "" {
+		if shed, err := client.GetShed(name); err == nil && shed.Image != rebuildImage {
+			undo = shedCommand("rebuild", name, "--image", shed.Image)
+		}
+	}
+
+	if !structuredOutput() {
+		fmt.Printf("Rebuilding shed %s on %s...\n", name, serverName)
+	}
+
+	noteHistory(name, serverName, undo)
+	req := &config.RebuildShedRequest{Image: rebuildImage, Pull: rebuildPull}
+	shed, err := client.RebuildShed(name, req, createProgressPrinter())
+	if err != nil {
+		return fmt.Errorf("failed to rebuild shed: %w", err)
+	}
+
+	clientConfig.CacheShed(name, serverName, shed.Status)
+	if err := clientConfig.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+	}
+
+	if ok, err := printStructured(shed); ok {
+		return err
+	}
+
+	printSuccess("Rebuilt shed %s from %s", name, shed.Image)
+	return nil
+}
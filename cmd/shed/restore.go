@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreBackup string
+	restoreForce  bool
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Restore a shed's workspace from a backup",
+	Long: `Replace a shed's workspace with one of its backups. The shed is stopped,
+its workspace emptied and filled from the backup, and then started again.
+Anything in the workspace since the backup was taken is lost.
+
+List a shed's backups with:
+
+  shed backup list <name>`,
+	Example: `  shed restore dev --backup 20260102-030405`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runRestore,
+}
+
+func init() {
+	restoreCmd.Flags().StringVar(&restoreBackup, "backup", "", "Name of the backup to restore (required)")
+	restoreCmd.Flags().BoolVarP(&restoreForce, "force", "f", false, "Restore without confirmation")
+	_ = restoreCmd.MarkFlagRequired("backup")
+
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	if !restoreForce {
+		if !confirm(fmt.Sprintf("Replace the workspace of %s on %s with backup %s? Changes since the backup will be lost.", name, serverName, restoreBackup)) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	if verboseFlag {
+		fmt.Printf("Restoring shed %s on %s from %s...\n", name, serverName, restoreBackup)
+	}
+
+	shed, err := NewAPIClientFromEntry(entry).RestoreBackup(name, restoreBackup)
+	if err != nil {
+		return fmt.Errorf("failed to restore shed: %w", err)
+	}
+
+	if ok, err := printStructured(shed); ok {
+		return err
+	}
+
+	printSuccess("Restored %s from backup %s", name, restoreBackup)
+	return nil
+}
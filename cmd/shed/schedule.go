@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Start, stop, and back up sheds on a schedule",
+	Long: `Start, stop, and back up sheds automatically at the times a cron expression
+matches.
+
+Expressions have five fields: minute, hour, day of month, month, and day of
+week, and are evaluated in the server's timezone setting, or its local time.
+A shed already in the scheduled state is left alone, and sheds stopped by
+their schedule report a stopped reason of "schedule". Quote the expression:
+
+  shed schedule set dev start "0 8 * * mon-fri"
+  shed schedule set dev stop "0 19 * * mon-fri"
+  shed schedule set dev backup "0 3 * * *"
+  shed schedule list
+  shed schedule remove dev`,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list [name]",
+	Short: "List schedules of a shed, or of every shed on the server",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runScheduleList,
+}
+
+var scheduleSetCmd = &cobra.Command{
+	Use:   "set <name> <start|stop|backup> <cron>",
+	Short: "Schedule a shed to start, stop, or be backed up",
+	Args:  cobra.MinimumNArgs(3),
+	RunE:  runScheduleSet,
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove <name> [start|stop|backup]",
+	Short: "Remove a shed's schedules, or only the one for an action",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  runScheduleRemove,
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleSetCmd)
+	scheduleCmd.AddCommand(scheduleRemoveCmd)
+
+	rootCmd.AddCommand(scheduleCmd)
+}
+
+func runScheduleList(cmd *cobra.Command, args []string) error {
+	var client *APIClient
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+		_, entry, err := findShedServer(name)
+		if err != nil {
+			return err
+		}
+		client = NewAPIClientFromEntry(entry)
+	} else {
+		var err error
+		if client, _, err = serverClient(); err != nil {
+			return err
+		}
+	}
+
+	resp, err := client.ListSchedules(name)
+	if err != nil {
+		return fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	if ok, err := printStructured(resp.Schedules); ok {
+		return err
+	}
+
+	if len(resp.Schedules) == 0 {
+		fmt.Println("No schedules found.")
+		fmt.Println("\nTo schedule a shed:")
+		fmt.Println(`  shed schedule set <name> <start|stop|backup> "<cron>"`)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SHED\tACTION\tCRON\tNEXT")
+	for _, s := range resp.Schedules {
+		next := "never"
+		if !s.Next.IsZero() {
+			next = s.Next.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Shed, s.Action, s.Cron, next)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runScheduleSet(cmd *cobra.Command, args []string) error {
+	name, action := args[0], args[1]
+	// Accept an unquoted expression split across arguments
+	expr := strings.Join(args[2:], " ")
+	if err := config.ValidateScheduleAction(action); err != nil {
+		return err
+	}
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+	client := NewAPIClientFromEntry(entry)
+
+	existing, err := client.ListSchedules(name)
+	if err != nil {
+		return fmt.Errorf("failed to list schedules: %w", err)
+	}
+	undo := shedCommand("schedule", "remove", name, action)
+	for _, s := range existing.Schedules {
+		if s.Action == action {
+			undo = shedCommand("schedule", "set", name, action, s.Cron)
+		}
+	}
+
+	noteHistory(name, serverName, undo)
+	schedule, err := client.SetSchedule(name, action, expr)
+	if err != nil {
+		return fmt.Errorf("failed to set schedule: %w", err)
+	}
+
+	if ok, err := printStructured(schedule); ok {
+		return err
+	}
+
+	if schedule.Next.IsZero() {
+		printSuccess("Scheduled %s to %s at %q, which never matches", name, action, schedule.Cron)
+		return nil
+	}
+	printSuccess("Scheduled %s to %s at %q, next at %s", name, action, schedule.Cron, schedule.Next.Local().Format("2006-01-02 15:04"))
+	return nil
+}
+
+func runScheduleRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if len(args) == 2 {
+		if err := config.ValidateScheduleAction(args[1]); err != nil {
+			return err
+		}
+	}
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+	client := NewAPIClientFromEntry(entry)
+
+	existing, err := client.ListSchedules(name)
+	if err != nil {
+		return fmt.Errorf("failed to list schedules: %w", err)
+	}
+	var remove []config.Schedule
+	for _, s := range existing.Schedules {
+		if len(args) == 1 || s.Action == args[1] {
+			remove = append(remove, s)
+		}
+	}
+	if len(remove) == 0 {
+		if len(args) == 2 {
+			return fmt.Errorf("shed %s has no %s schedule", name, args[1])
+		}
+		return fmt.Errorf("shed %s has no schedules", name)
+	}
+
+	undo := make([]string, len(remove))
+	for i, s := range remove {
+		undo[i] = shedCommand("schedule", "set", name, s.Action, s.Cron)
+	}
+	noteHistory(name, serverName, strings.Join(undo, " && "))
+
+	for _, s := range remove {
+		if err := client.DeleteSchedule(name, s.Action); err != nil {
+			return fmt.Errorf("failed to remove %s schedule: %w", s.Action, err)
+		}
+	}
+
+	printSuccess("Removed schedules of %s", name)
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage secrets stored on a shed server",
+	Long: `Manage secrets stored encrypted on a shed server.
+
+Sheds created with --secret get a secret as an environment variable in their
+sessions, or as a file in /run/secrets kept in memory. Secret values never
+appear in the shed's Docker labels or container configuration:
+
+  shed secret set github-token < token.txt
+  shed create api --secret GITHUB_TOKEN=github-token --secret npmrc
+
+Changed secrets reach new sessions right away, and files the next time the
+shed starts.`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name> [value]",
+	Short: "Create or replace a secret",
+	Long: `Create or replace a secret. The value is read from standard input unless given
+as an argument, which keeps it out of your shell history.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runSecretSet,
+}
+
+var secretGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret's value",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretGet,
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secrets",
+	Args:  cobra.NoArgs,
+	RunE:  runSecretList,
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretDelete,
+}
+
+var secretDeleteForce bool
+
+func init() {
+	secretDeleteCmd.Flags().BoolVarP(&secretDeleteForce, "force", "f", false, "Delete without confirmation")
+
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretGetCmd)
+	secretCmd.AddCommand(secretListCmd)
+	secretCmd.AddCommand(secretDeleteCmd)
+
+	rootCmd.AddCommand(secretCmd)
+}
+
+func runSecretSet(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := config.ValidateSecretName(name); err != nil {
+		return err
+	}
+
+	var value string
+	if len(args) == 2 {
+		value = args[1]
+	} else {
+		if isInteractive() {
+			fmt.Fprintf(os.Stderr, "Enter the value for %s, then press Ctrl-D:\n", name)
+		}
+		data, err := io.ReadAll(io.LimitReader(os.Stdin, config.MaxSecretSize+1))
+		if err != nil {
+			return fmt.Errorf("failed to read secret: %w", err)
+		}
+		// A value piped from echo or a file usually ends with one newline
+		value = strings.TrimSuffix(string(data), "\n")
+	}
+	if err := config.ValidateSecretValue(value); err != nil {
+		return err
+	}
+
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	noteHistory("", serverName, "")
+	secret, err := client.SetSecret(name, value)
+	if err != nil {
+		return fmt.Errorf("failed to set secret: %w", err)
+	}
+
+	if ok, err := printStructured(secret); ok {
+		return err
+	}
+
+	printSuccess("Saved secret %s on %s", name, serverName)
+	return nil
+}
+
+func runSecretGet(cmd *cobra.Command, args []string) error {
+	client, _, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	secret, err := client.GetSecret(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	if ok, err := printStructured(secret); ok {
+		return err
+	}
+
+	fmt.Println(secret.Value)
+	return nil
+}
+
+func runSecretList(cmd *cobra.Command, args []string) error {
+	client, _, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.ListSecrets()
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	if ok, err := printStructured(resp.Secrets); ok {
+		return err
+	}
+
+	if len(resp.Secrets) == 0 {
+		fmt.Println("No secrets found.")
+		fmt.Println("\nTo add a secret:")
+		fmt.Println("  shed secret set <name>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUPDATED")
+	for _, secret := range resp.Secrets {
+		fmt.Fprintf(w, "%s\t%s\n", secret.Name, secret.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runSecretDelete(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client, serverName, err := serverClient()
+	if err != nil {
+		return err
+	}
+
+	if !secretDeleteForce {
+		if !confirm(fmt.Sprintf("Delete secret %q on %s? Sheds using it will no longer get it.", name, serverName)) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	noteHistory("", serverName, "")
+	if err := client.DeleteSecret(name); err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	printSuccess("Deleted secret %s", name)
+	return nil
+}
+
+// parseSecretFlags parses repeated --secret flags.
+func parseSecretFlags(specs []string) ([]config.SecretRef, error) {
+	refs := make([]config.SecretRef, 0, len(specs))
+	for _, spec := range specs {
+		ref, err := config.ParseSecretRef(spec)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// formatSecretRefs describes where a shed gets each of its secrets.
+func formatSecretRefs(refs []config.SecretRef) string {
+	parts := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		var to []string
+		if ref.Env != "" {
+			to = append(to, "$"+ref.Env)
+		}
+		if file := ref.FileName(); file != "" {
+			to = append(to, config.SecretsPath+"/"+file)
+		}
+		parts = append(parts, ref.Name+" -> "+strings.Join(to, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
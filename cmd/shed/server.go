@@ -3,10 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
 
 	"github.com/charliek/shed/internal/config"
 )
@@ -39,9 +43,12 @@ var serverListCmd = &cobra.Command{
 var serverRemoveCmd = &cobra.Command{
 	Use:   "remove <name>",
 	Short: "Remove a server",
-	Long:  "Remove a server from the configuration.",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runServerRemove,
+	Long: `Remove a server from the configuration.
+
+Its known_hosts entries are removed too, unless another configured server
+shares its host and SSH port.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runServerRemove,
 }
 
 var serverSetDefaultCmd = &cobra.Command{
@@ -52,30 +59,122 @@ var serverSetDefaultCmd = &cobra.Command{
 	RunE:  runServerSetDefault,
 }
 
+var serverUpdateCmd = &cobra.Command{
+	Use:   "update <name>",
+	Short: "Refresh a server's details",
+	Long: `Re-fetch a server's info and SSH host key and update the local configuration.
+
+Stored ports, the known_hosts entry, and any installed SSH config entries
+are updated to match the server. If the server's host key has changed you
+will be asked to confirm before the new key is trusted. Use --port if the
+server's HTTP port has changed and it is no longer reachable on the stored one.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runServerUpdate,
+}
+
+var serverRefreshCmd = &cobra.Command{
+	Use:   "refresh <name>",
+	Short: "Refresh a server's trusted SSH host keys",
+	Long: `Fetch a server's SSH host keys and replace its known_hosts entries with
+them in a single write.
+
+While the server is rotating its host key (shed-server rotate-host-key), both
+the current and the new key are trusted, so connections keep working when
+the server switches. Refreshing after the rotation finishes drops the old
+key. If the key the server presents isn't one already trusted you will be
+asked to confirm.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runServerRefresh,
+}
+
+var serverVerifyCmd = &cobra.Command{
+	Use:   "verify [name]",
+	Short: "Check servers' SSH host keys",
+	Long: `Fetch the SSH host keys of a server, or of every configured server, and
+compare them with the known_hosts entries without changing anything.
+
+Each server is reported as:
+  ok           The presented key is trusted, and nothing else is
+  rotating     The server is rotating its key and the new one isn't trusted yet
+  stale        Keys the server no longer uses are still trusted
+  changed      The presented key isn't trusted
+  missing      No key is trusted for the server
+  unreachable  The server's keys couldn't be fetched
+
+Run 'shed server refresh <name>' to fix rotating, stale, and missing servers.
+The command fails if any server is changed, missing, or unreachable.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runServerVerify,
+}
+
+var serverRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a server",
+	Long: `Rename a configured server.
+
+The default server setting and cached shed locations are updated to the
+new name, and any installed SSH config entries for its sheds are regenerated.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runServerRename,
+}
+
+var serverStatusCmd = &cobra.Command{
+	Use:   "status [name]",
+	Short: "Show a server's status",
+	Long: `Show a server's version and ports, and the results of its scheduled
+image pre-pulls. Defaults to the current server.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runServerStatus,
+}
+
 var (
-	serverAddPort int
-	serverAddName string
+	serverAddPort     int
+	serverAddName     string
+	serverAddToken    string
+	serverUpdatePort  int
+	serverUpdateYes   bool
+	serverUpdateToken string
+	serverRefreshYes  bool
 )
 
 func init() {
 	serverAddCmd.Flags().IntVarP(&serverAddPort, "port", "p", 8080, "HTTP port of the server")
 	serverAddCmd.Flags().StringVarP(&serverAddName, "name", "n", "", "Name for the server (default: server's hostname)")
+	serverAddCmd.Flags().StringVar(&serverAddToken, "token", "", "API token for servers that require one")
+
+	serverUpdateCmd.Flags().IntVarP(&serverUpdatePort, "port", "p", 0, "HTTP port to connect on (default: stored port)")
+	serverUpdateCmd.Flags().BoolVarP(&serverUpdateYes, "yes", "y", false, "Accept a changed host key without confirmation")
+	serverUpdateCmd.Flags().StringVar(&serverUpdateToken, "token", "", "Replace the stored API token")
+
+	serverRefreshCmd.Flags().BoolVarP(&serverRefreshYes, "yes", "y", false, "Accept a changed host key without confirmation")
 
 	serverCmd.AddCommand(serverAddCmd)
 	serverCmd.AddCommand(serverListCmd)
 	serverCmd.AddCommand(serverRemoveCmd)
 	serverCmd.AddCommand(serverSetDefaultCmd)
+	serverCmd.AddCommand(serverUpdateCmd)
+	serverCmd.AddCommand(serverRefreshCmd)
+	serverCmd.AddCommand(serverVerifyCmd)
+	serverCmd.AddCommand(serverRenameCmd)
+	serverCmd.AddCommand(serverStatusCmd)
 }
 
 func runServerAdd(cmd *cobra.Command, args []string) error {
-	host := args[0]
+	return addServer(args[0], serverAddPort, serverAddName, serverAddToken)
+}
 
+// addServer fetches a server's info and SSH host key and saves it to the
+// client config. An empty name uses the server's own.
+func addServer(host string, port int, name, token string) error {
+	// Store IPv6 addresses bare, however they were typed
+	host = config.NormalizeHost(host)
 	if verboseFlag {
-		fmt.Printf("Connecting to %s:%d...\n", host, serverAddPort)
+		fmt.Printf("Connecting to %s...\n", config.HostPort(host, port))
 	}
 
 	// Connect and get server info
-	client := NewAPIClient(host, serverAddPort)
+	client := NewAPIClient(host, port)
+	client.token = token
 	info, err := client.GetInfo()
 	if err != nil {
 		return fmt.Errorf("failed to get server info: %w", err)
@@ -88,7 +187,6 @@ func runServerAdd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Determine server name
-	name := serverAddName
 	if name == "" {
 		name = info.Name
 	}
@@ -103,13 +201,15 @@ func runServerAdd(cmd *cobra.Command, args []string) error {
 		Host:     host,
 		HTTPPort: info.HTTPPort,
 		SSHPort:  info.SSHPort,
+		Token:    token,
 	}
+	noteHistory("", name, shedCommand("server", "remove", name))
 	if err := clientConfig.AddServer(name, entry); err != nil {
 		return err
 	}
 
-	// Save known host
-	if err := config.AddKnownHost(host, info.SSHPort, hostKeyResp.HostKey); err != nil {
+	// Save known hosts
+	if err := config.ReplaceKnownHosts(host, info.SSHPort, info.SSHPort, publishedHostKeys(hostKeyResp)); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to save SSH host key: %v\n", err)
 	}
 
@@ -118,7 +218,7 @@ func runServerAdd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
-	printSuccess("Added server %s (%s:%d)", name, host, info.HTTPPort)
+	printSuccess("Added server %s (%s)", name, config.HostPort(host, info.HTTPPort))
 	if clientConfig.DefaultServer == name {
 		fmt.Println("  Set as default server")
 	}
@@ -126,8 +226,19 @@ func runServerAdd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// listedServer is a server as printed by server list with --output json or
+// yaml. The API token is left out.
+type listedServer struct {
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+	HTTPPort int    `json:"http_port"`
+	SSHPort  int    `json:"ssh_port"`
+	Online   bool   `json:"online"`
+	Default  bool   `json:"default"`
+}
+
 func runServerList(cmd *cobra.Command, args []string) error {
-	if len(clientConfig.Servers) == 0 {
+	if len(clientConfig.Servers) == 0 && !structuredOutput() {
 		fmt.Println("No servers configured.")
 		fmt.Println("\nTo add a server:")
 		fmt.Println("  shed server add <hostname>")
@@ -141,6 +252,23 @@ func runServerList(cmd *cobra.Command, args []string) error {
 	}
 	sort.Strings(names)
 
+	if structuredOutput() {
+		servers := make([]listedServer, 0, len(names))
+		for _, name := range names {
+			entry := clientConfig.Servers[name]
+			servers = append(servers, listedServer{
+				Name:     name,
+				Host:     entry.Host,
+				HTTPPort: entry.HTTPPort,
+				SSHPort:  entry.SSHPort,
+				Online:   NewAPIClientFromEntry(&entry).Ping(),
+				Default:  name == clientConfig.DefaultServer,
+			})
+		}
+		_, err := printStructured(servers)
+		return err
+	}
+
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintln(w, "NAME\tHOST\tHTTP\tSSH\tSTATUS\tDEFAULT")
 
@@ -171,6 +299,11 @@ func runServerList(cmd *cobra.Command, args []string) error {
 func runServerRemove(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
+	entry, ok := clientConfig.Servers[name]
+	if ok {
+		noteHistory("", name, shedCommand("server", "add", entry.Host, "--port", strconv.Itoa(entry.HTTPPort), "--name", name))
+	}
+
 	if err := clientConfig.RemoveServer(name); err != nil {
 		return err
 	}
@@ -180,12 +313,28 @@ func runServerRemove(cmd *cobra.Command, args []string) error {
 	}
 
 	printSuccess("Removed server %s", name)
+
+	// Keep the host keys while another name still connects with them
+	for _, other := range clientConfig.Servers {
+		if other.Host == entry.Host && other.SSHPort == entry.SSHPort {
+			return nil
+		}
+	}
+	if err := config.RemoveKnownHost(entry.Host, entry.SSHPort); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove SSH host key: %v\n", err)
+	}
 	return nil
 }
 
 func runServerSetDefault(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
+	undo := ""
+	if clientConfig.DefaultServer != "" {
+		undo = shedCommand("server", "set-default", clientConfig.DefaultServer)
+	}
+	noteHistory("", name, undo)
+
 	if err := clientConfig.SetDefaultServer(name); err != nil {
 		return err
 	}
@@ -197,3 +346,483 @@ func runServerSetDefault(cmd *cobra.Command, args []string) error {
 	printSuccess("Set %s as default server", name)
 	return nil
 }
+
+func runServerRename(cmd *cobra.Command, args []string) error {
+	oldName, newName := args[0], args[1]
+
+	noteHistory("", newName, shedCommand("server", "rename", newName, oldName))
+	if err := clientConfig.RenameServer(oldName, newName); err != nil {
+		return err
+	}
+
+	if err := clientConfig.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	printSuccess("Renamed server %s to %s", oldName, newName)
+
+	if _, err := refreshManagedEntries(newName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update SSH config: %v\n", err)
+	}
+
+	return nil
+}
+
+func runServerUpdate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	entry, err := clientConfig.GetServer(name)
+	if err != nil {
+		return err
+	}
+
+	port := entry.HTTPPort
+	if serverUpdatePort != 0 {
+		port = serverUpdatePort
+	}
+
+	if verboseFlag {
+		fmt.Printf("Connecting to %s...\n", config.HostPort(entry.Host, port))
+	}
+
+	token := entry.Token
+	if serverUpdateToken != "" {
+		token = serverUpdateToken
+	}
+
+	// Connect and get server info
+	client := NewAPIClient(entry.Host, port)
+	client.token = token
+	info, err := client.GetInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get server info: %w", err)
+	}
+
+	// Get SSH host key
+	hostKeyResp, err := client.GetSSHHostKey()
+	if err != nil {
+		return fmt.Errorf("failed to get SSH host key: %w", err)
+	}
+	newKeys := publishedHostKeys(hostKeyResp)
+
+	oldKeys, err := config.GetKnownHosts(entry.Host, entry.SSHPort)
+	if err != nil {
+		return err
+	}
+
+	if !serverUpdateYes && !confirmHostKeyChange(name, oldKeys, newKeys[0]) {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+
+	// Replace the known_hosts entries if the keys or SSH port changed
+	keysChanged := !slices.Equal(oldKeys, newKeys)
+	if keysChanged || entry.SSHPort != info.SSHPort {
+		if err := config.ReplaceKnownHosts(entry.Host, entry.SSHPort, info.SSHPort, newKeys); err != nil {
+			return fmt.Errorf("failed to update known_hosts: %w", err)
+		}
+	}
+
+	updated := config.ServerEntry{
+		Host:     entry.Host,
+		HTTPPort: info.HTTPPort,
+		SSHPort:  info.SSHPort,
+		Token:    token,
+		Timeout:  entry.Timeout,
+		Retries:  entry.Retries,
+		SSH:      entry.SSH,
+	}
+	noteHistory("", name, "")
+	if err := clientConfig.UpdateServer(name, updated); err != nil {
+		return err
+	}
+
+	if err := clientConfig.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	printSuccess("Updated server %s (%s)", name, config.HostPort(entry.Host, info.HTTPPort))
+	if entry.HTTPPort != info.HTTPPort {
+		fmt.Printf("  HTTP port: %d -> %d\n", entry.HTTPPort, info.HTTPPort)
+	}
+	if entry.SSHPort != info.SSHPort {
+		fmt.Printf("  SSH port: %d -> %d\n", entry.SSHPort, info.SSHPort)
+	}
+	if keysChanged {
+		fmt.Println("  Host key updated")
+	}
+	if token != entry.Token {
+		fmt.Println("  API token updated")
+	}
+
+	// Keep installed SSH config entries pointing at the right port
+	count, err := refreshManagedEntries(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update SSH config: %v\n", err)
+	} else if count > 0 {
+		fmt.Printf("  Updated %d SSH config entries\n", count)
+	}
+
+	return nil
+}
+
+func runServerRefresh(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	entry, err := clientConfig.GetServer(name)
+	if err != nil {
+		return err
+	}
+
+	client := NewAPIClient(entry.Host, entry.HTTPPort)
+	client.token = entry.Token
+	hostKeyResp, err := client.GetSSHHostKey()
+	if err != nil {
+		return fmt.Errorf("failed to get SSH host key: %w", err)
+	}
+	newKeys := publishedHostKeys(hostKeyResp)
+
+	oldKeys, err := config.GetKnownHosts(entry.Host, entry.SSHPort)
+	if err != nil {
+		return err
+	}
+
+	if slices.Equal(oldKeys, newKeys) {
+		fmt.Printf("Host keys for %s are up to date\n", name)
+		return nil
+	}
+
+	if !serverRefreshYes && !confirmHostKeyChange(name, oldKeys, newKeys[0]) {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+
+	if err := config.ReplaceKnownHosts(entry.Host, entry.SSHPort, entry.SSHPort, newKeys); err != nil {
+		return fmt.Errorf("failed to update known_hosts: %w", err)
+	}
+
+	printSuccess("Refreshed host keys for %s", name)
+	for _, key := range newKeys {
+		if !slices.Contains(oldKeys, key) {
+			fmt.Printf("  Trusted %s\n", keyFingerprint(key))
+		}
+	}
+	for _, key := range oldKeys {
+		if !slices.Contains(newKeys, key) {
+			fmt.Printf("  Dropped %s\n", keyFingerprint(key))
+		}
+	}
+
+	return nil
+}
+
+// Host key states reported by server verify.
+const (
+	hostKeyOK          = "ok"
+	hostKeyRotating    = "rotating"
+	hostKeyStale       = "stale"
+	hostKeyChanged     = "changed"
+	hostKeyMissing     = "missing"
+	hostKeyUnreachable = "unreachable"
+)
+
+// verifiedServer is a server as printed by server verify.
+type verifiedServer struct {
+	Name        string   `json:"name"`
+	Host        string   `json:"host"`
+	SSHPort     int      `json:"ssh_port"`
+	Status      string   `json:"status"`
+	HostKey     string   `json:"host_key,omitempty"`
+	NextHostKey string   `json:"next_host_key,omitempty"`
+	Trusted     []string `json:"trusted"`
+	Error       string   `json:"error,omitempty"`
+}
+
+func runServerVerify(cmd *cobra.Command, args []string) error {
+	names := args
+	if len(names) == 0 {
+		for name := range clientConfig.Servers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 && !structuredOutput() {
+		fmt.Println("No servers configured.")
+		return nil
+	}
+
+	results := make([]verifiedServer, 0, len(names))
+	for _, name := range names {
+		entry, err := clientConfig.GetServer(name)
+		if err != nil {
+			return err
+		}
+		results = append(results, verifyServer(name, entry))
+	}
+
+	failed := 0
+	for _, r := range results {
+		switch r.Status {
+		case hostKeyChanged, hostKeyMissing, hostKeyUnreachable:
+			failed++
+		}
+	}
+
+	if ok, err := printStructured(results); ok {
+		if err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tHOST\tSSH\tSTATUS\tFINGERPRINT")
+		for _, r := range results {
+			fingerprint := "-"
+			if r.HostKey != "" {
+				fingerprint = keyFingerprint(r.HostKey)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", r.Name, r.Host, r.SSHPort, r.Status, fingerprint)
+		}
+		w.Flush()
+
+		for _, r := range results {
+			switch r.Status {
+			case hostKeyRotating, hostKeyStale, hostKeyMissing:
+				fmt.Printf("\nRun 'shed server refresh %s' to update its trusted keys.\n", r.Name)
+			case hostKeyChanged:
+				fmt.Fprintf(os.Stderr, "\nWARNING: %s presents a key that isn't trusted. If the change is expected, run 'shed server refresh %s'.\n", r.Name, r.Name)
+			case hostKeyUnreachable:
+				fmt.Fprintf(os.Stderr, "\n%s: %s\n", r.Name, r.Error)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("host key verification failed for %d server(s)", failed)
+	}
+	return nil
+}
+
+// verifyServer compares the host keys a server publishes with those trusted
+// for it.
+func verifyServer(name string, entry *config.ServerEntry) verifiedServer {
+	result := verifiedServer{Name: name, Host: entry.Host, SSHPort: entry.SSHPort, Trusted: []string{}}
+
+	trusted, err := config.GetKnownHosts(entry.Host, entry.SSHPort)
+	if err != nil {
+		result.Status, result.Error = hostKeyUnreachable, err.Error()
+		return result
+	}
+	if trusted != nil {
+		result.Trusted = trusted
+	}
+
+	hostKeyResp, err := NewAPIClientFromEntry(entry).GetSSHHostKey()
+	if err != nil {
+		result.Status, result.Error = hostKeyUnreachable, err.Error()
+		return result
+	}
+	published := publishedHostKeys(hostKeyResp)
+	result.HostKey = published[0]
+	if len(published) > 1 {
+		result.NextHostKey = published[1]
+	}
+	result.Status = hostKeyStatus(trusted, published)
+
+	return result
+}
+
+// hostKeyStatus compares the trusted keys for a server with those it
+// publishes, the presented key first.
+func hostKeyStatus(trusted, published []string) string {
+	switch {
+	case len(trusted) == 0:
+		return hostKeyMissing
+	case !slices.Contains(trusted, published[0]):
+		return hostKeyChanged
+	case len(published) > 1 && !slices.Contains(trusted, published[1]):
+		return hostKeyRotating
+	}
+	for _, key := range trusted {
+		if !slices.Contains(published, key) {
+			return hostKeyStale
+		}
+	}
+	return hostKeyOK
+}
+
+// publishedHostKeys returns the host keys a server asks clients to trust:
+// the key it presents, then the key replacing it during a rotation.
+func publishedHostKeys(resp *config.SSHHostKeyResponse) []string {
+	keys := []string{strings.TrimSpace(resp.HostKey)}
+	if next := strings.TrimSpace(resp.NextHostKey); next != "" {
+		keys = append(keys, next)
+	}
+	return keys
+}
+
+// confirmHostKeyChange asks before trusting a server whose presented key
+// isn't among those already trusted. It reports whether to go ahead, which
+// it always does when nothing was trusted before.
+func confirmHostKeyChange(name string, oldKeys []string, newKey string) bool {
+	if len(oldKeys) == 0 || slices.Contains(oldKeys, newKey) {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "WARNING: the SSH host key for %s has changed.\n", name)
+	for _, oldKey := range oldKeys {
+		fmt.Fprintf(os.Stderr, "  Old: %s\n", keyFingerprint(oldKey))
+	}
+	fmt.Fprintf(os.Stderr, "  New: %s\n", keyFingerprint(newKey))
+	return confirm("Trust the new host key?")
+}
+
+// printCapacity prints a server's host resources and shed counts.
+// printImageGC summarizes image collection on the server.
+func printImageGC(gc *config.ImageGCStatus) {
+	summary := fmt.Sprintf("images unused for %d days", gc.UnusedDays)
+	switch {
+	case gc.Error != "":
+		summary += ", last run failed: " + gc.Error
+	case gc.LastRun != nil:
+		summary += fmt.Sprintf(", last run %s removed %d (%s)", formatAgo(*gc.LastRun), gc.LastRemoved, formatBytes(gc.LastReclaimed))
+	}
+	fmt.Printf("Image GC: %s; %d removed (%s) in total\n", summary, gc.TotalRemoved, formatBytes(gc.TotalReclaimed))
+}
+
+func printCapacity(c *config.ServerCapacity) {
+	engine := "Docker"
+	if c.Runtime == config.RuntimePodman {
+		engine = "Podman"
+	}
+	fmt.Printf("Host:     %s/%s, %s %s\n", c.OS, c.Architecture, engine, c.DockerVersion)
+
+	memory := formatBytes(int64(c.MemoryTotal))
+	if c.MemoryAvailable > 0 {
+		memory = formatBytes(int64(c.MemoryAvailable)) + " free of " + memory
+	}
+	fmt.Printf("CPU/Mem:  %d CPUs, %s\n", c.CPUs, memory)
+
+	if c.DiskTotal > 0 {
+		fmt.Printf("Disk:     %s free of %s (%s)\n", formatBytes(int64(c.DiskFree)), formatBytes(int64(c.DiskTotal)), c.DockerRootDir)
+	}
+
+	statuses := make([]string, 0, len(c.Sheds))
+	for status := range c.Sheds {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	counts := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		counts = append(counts, fmt.Sprintf("%d %s", c.Sheds[status], status))
+	}
+	summary := fmt.Sprintf("%d", c.TotalSheds)
+	if len(counts) > 0 {
+		summary += " (" + strings.Join(counts, ", ") + ")"
+	}
+	fmt.Printf("Sheds:    %s\n", summary)
+}
+
+// capabilityList describes the optional features a server supports.
+func capabilityList(caps config.ServerCapabilities) string {
+	var features []string
+	if caps.Checkpoint {
+		features = append(features, "checkpoint")
+	}
+	if caps.GPU {
+		features = append(features, "gpu")
+	}
+	if len(features) == 0 {
+		return "none"
+	}
+	return strings.Join(features, ", ")
+}
+
+// keyFingerprint returns the SHA256 fingerprint of an authorized_keys format key,
+// or the key itself if it cannot be parsed.
+func keyFingerprint(key string) string {
+	pubKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return key
+	}
+	return gossh.FingerprintSHA256(pubKey)
+}
+
+func runServerStatus(cmd *cobra.Command, args []string) error {
+	var entry *config.ServerEntry
+	var serverName string
+	if len(args) == 1 {
+		serverName = args[0]
+		e, ok := clientConfig.Servers[serverName]
+		if !ok {
+			return fmt.Errorf("server '%s' not found", serverName)
+		}
+		entry = &e
+	} else {
+		var err error
+		entry, serverName, err = getServerEntry()
+		if err != nil {
+			return err
+		}
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	info, err := client.GetInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get server info: %w", err)
+	}
+
+	if structuredOutput() {
+		status := struct {
+			*config.ServerInfo
+			Capacity *config.ServerCapacity `json:"capacity,omitempty"`
+		}{ServerInfo: info}
+		// Older servers have no capacity endpoint
+		status.Capacity, _ = client.GetCapacity()
+		_, err := printStructured(status)
+		return err
+	}
+
+	fmt.Printf("Server:   %s (%s)\n", serverName, entry.Host)
+	fmt.Printf("Name:     %s\n", info.Name)
+	fmt.Printf("Version:  %s (API %s, requires shed %s or newer)\n", info.Version, info.APIVersion, info.MinClientVersion)
+	fmt.Printf("Ports:    http %d, ssh %d\n", info.HTTPPort, info.SSHPort)
+	if info.DockerHost != "" {
+		fmt.Printf("Engine:   %s\n", info.DockerHost)
+	}
+	fmt.Printf("Features: %s\n", capabilityList(info.Capabilities))
+
+	// Older servers have no capacity endpoint
+	if capacity, err := client.GetCapacity(); err == nil {
+		printCapacity(capacity)
+	} else if verboseFlag {
+		fmt.Fprintf(os.Stderr, "Warning: failed to get server capacity: %v\n", err)
+	}
+	if info.ImageGC != nil {
+		printImageGC(info.ImageGC)
+	}
+
+	if len(info.Prepull) == 0 {
+		fmt.Println("\nImage pre-pull is not enabled.")
+		return nil
+	}
+
+	fmt.Println("\nPre-pulled images:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  IMAGE\tLAST PULLED\tDURATION\tSTATUS")
+	for _, p := range info.Prepull {
+		status := "ok"
+		switch {
+		case p.Error != "":
+			status = "error: " + p.Error
+		case p.LastAttempt.IsZero():
+			status = "pending"
+		}
+		duration := "-"
+		if !p.LastAttempt.IsZero() {
+			duration = fmt.Sprintf("%.1fs", p.Duration)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", p.Image, formatAgo(p.LastSuccess), duration, status)
+	}
+	w.Flush()
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage tmux or zellij sessions in a shed",
+	Long: `List, start, rename, and kill the sessions in a running shed.
+
+Sessions run in tmux, or in zellij for sheds whose image has zellij but not
+tmux. zellij doesn't report attached clients, windows, or activity.
+
+New sessions start detached, so long-running work such as a dev server or
+an agent can be left running without connecting. Attach to them later with
+shed attach:
+
+  shed sessions new dev server -- npm run dev
+  shed sessions rename dev server web
+  shed attach dev -S web`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list <name>",
+	Short: "List the sessions in a shed",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSessionsList,
+}
+
+var sessionsNewCmd = &cobra.Command{
+	Use:   "new <name> <session> [-- command...]",
+	Short: "Start a detached session, optionally running a command",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runSessionsNew,
+}
+
+var sessionsRenameCmd = &cobra.Command{
+	Use:   "rename <name> <session> <new-name>",
+	Short: "Rename a session",
+	Args:  cobra.ExactArgs(3),
+	RunE:  runSessionsRename,
+}
+
+var sessionsKillCmd = &cobra.Command{
+	Use:   "kill <name> <session>",
+	Short: "End a session and everything running in it",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSessionsKill,
+}
+
+var sessionsKillForce bool
+
+func init() {
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsNewCmd)
+	sessionsCmd.AddCommand(sessionsRenameCmd)
+	sessionsCmd.AddCommand(sessionsKillCmd)
+
+	sessionsKillCmd.Flags().BoolVarP(&sessionsKillForce, "force", "f", false, "Kill without confirmation")
+
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+func runSessionsList(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	_, entry, err := findRunningShed(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := NewAPIClientFromEntry(entry).ListSessions(name)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	if ok, err := printStructured(resp.Sessions); ok {
+		return err
+	}
+
+	if len(resp.Sessions) == 0 {
+		fmt.Printf("Shed %s has no sessions.\n", name)
+		fmt.Println("\nTo start one:")
+		fmt.Printf("  shed sessions new %s <session>\n", name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATE\tWINDOWS\tLAST ACTIVITY\tPANES")
+	for _, s := range resp.Sessions {
+		state := "detached"
+		if s.Attached {
+			state = "attached"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", s.Name, state, s.Windows, formatAgo(s.LastActivity), strings.Join(s.Panes, ", "))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runSessionsNew(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	req := &config.CreateSessionRequest{Name: args[1]}
+	if len(args) > 2 {
+		// Quote the arguments so the session's shell sees them as given
+		req.Command = shellJoin(args[2:])
+	}
+	if err := config.ValidateSessionName(req.Name); err != nil {
+		return err
+	}
+
+	_, entry, err := findRunningShed(name)
+	if err != nil {
+		return err
+	}
+
+	session, err := NewAPIClientFromEntry(entry).CreateSession(name, req)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	if ok, err := printStructured(session); ok {
+		return err
+	}
+
+	printSuccess("Started session %s in %s", session.Name, name)
+	fmt.Printf("\nAttach with: shed attach %s -S %s\n", name, session.Name)
+	return nil
+}
+
+func runSessionsRename(cmd *cobra.Command, args []string) error {
+	name, session, newName := args[0], args[1], args[2]
+	if err := config.ValidateSessionName(newName); err != nil {
+		return err
+	}
+
+	serverName, entry, err := findRunningShed(name)
+	if err != nil {
+		return err
+	}
+
+	noteHistory(name, serverName, shedCommand("sessions", "rename", name, newName, session))
+	renamed, err := NewAPIClientFromEntry(entry).RenameSession(name, session, newName)
+	if err != nil {
+		return fmt.Errorf("failed to rename session: %w", err)
+	}
+
+	// Keep shed attach reattaching to the session under its new name
+	if clientConfig.GetLastSession(name) == session {
+		clientConfig.SetLastSession(name, newName)
+		if err := clientConfig.Save(); err != nil && verboseFlag {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		}
+	}
+
+	if ok, err := printStructured(renamed); ok {
+		return err
+	}
+
+	printSuccess("Renamed session %s to %s in %s", session, newName, name)
+	return nil
+}
+
+func runSessionsKill(cmd *cobra.Command, args []string) error {
+	name, session := args[0], args[1]
+
+	serverName, entry, err := findRunningShed(name)
+	if err != nil {
+		return err
+	}
+
+	if !sessionsKillForce {
+		if !confirm(fmt.Sprintf("Kill session %q in %s? Anything running in it will be stopped.", session, name)) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	noteHistory(name, serverName, "")
+	if err := NewAPIClientFromEntry(entry).KillSession(name, session); err != nil {
+		return fmt.Errorf("failed to kill session: %w", err)
+	}
+
+	printSuccess("Killed session %s in %s", session, name)
+	return nil
+}
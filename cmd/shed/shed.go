@@ -1,12 +1,18 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -14,21 +20,66 @@ import (
 )
 
 var createCmd = &cobra.Command{
-	Use:   "create <name>",
+	Use:   "create [name]",
 	Short: "Create a new shed",
 	Long: `Create a new shed development environment.
 
-If a repository URL is provided, it will be cloned into the shed.`,
-	Args: cobra.ExactArgs(1),
+If a repository URL is provided, it will be cloned into the shed. The name
+may then be omitted, and the server names the shed after the repository
+(e.g. widget-factory for git@github.com:org/widget-factory.git), adding a
+numeric suffix if that name is taken. Use --branch to clone a branch or tag
+other than the default, and --depth for a shallow clone.
+
+With --worktree, sheds for the same repository share a single clone on the
+server and each shed gets its own worktree, which saves disk space and clone
+time for large repositories. Each worktree needs its own branch: pass one with
+--branch, or a shed/<name> branch is created from the default branch.
+
+With --mount, a host directory or named volume is shared with just this shed,
+e.g. --mount /srv/datasets:/data:ro. Sources that aren't absolute paths are
+volume names. The server only allows paths and volumes listed in its mounts
+config.
+
+With --detach, the shed is created in the background on the server and the
+job's ID is printed at once. Follow it with shed jobs <id>.
+
+With --exists-ok, an existing shed with the name is left as it is and
+reported instead of an error, as long as it has the requested --repo and
+--image. Without a name, the repository's name is used as is. This makes
+create safe to run repeatedly from scripts and editors.
+
+With --wait, create returns only once the shed is running.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runCreate,
 }
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List sheds",
-	Long:  "List all sheds on the configured servers.",
-	Args:  cobra.NoArgs,
-	RunE:  runList,
+	Long: `List all sheds on the configured servers.
+
+With --wide, the image, workspace disk usage, CPU and memory usage, tmux
+session count, and last session activity are shown for each shed. Usage
+columns are only available for running sheds.
+
+With --watch, the list stays open and is redrawn whenever a server reports
+a shed event, such as a shed starting or stopping, and every 10 seconds,
+until interrupted.`,
+	Args: cobra.NoArgs,
+	RunE: runList,
+}
+
+var findCmd = &cobra.Command{
+	Use:   "find <query>",
+	Short: "Find sheds by name, repository, or label",
+	Long: `Find sheds whose metadata contains the query, ignoring case. Labels
+match as key=value, so "team=payments", "team", or "payments" finds a shed
+labelled team=payments.
+
+Matching is done on the server. Use --fields to restrict which fields are
+searched and --all to search every configured server.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFind,
 }
 
 var deleteCmd = &cobra.Command{
@@ -40,41 +91,186 @@ var deleteCmd = &cobra.Command{
 }
 
 var startCmd = &cobra.Command{
-	Use:   "start <name>",
-	Short: "Start a stopped shed",
-	Long:  "Start a shed that was previously stopped.",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runStart,
+	Use:   "start <name>...",
+	Short: "Start stopped sheds",
+	Long: `Start one or more sheds that were previously stopped.
+
+When several sheds are given, they are started with one batch request per server.
+Use --from-checkpoint to restore a single shed's processes from a checkpoint
+(see shed checkpoint). With --wait, start returns only once every shed is
+running.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runStart,
 }
 
 var stopCmd = &cobra.Command{
-	Use:   "stop <name>",
-	Short: "Stop a running shed",
-	Long:  "Stop a running shed. The shed can be started again later.",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runStop,
+	Use:   "stop <name>...",
+	Short: "Stop running sheds",
+	Long: `Stop one or more running sheds. The sheds can be started again later.
+
+When several sheds are given, they are stopped with one batch request per server.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runStop,
+}
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <name>...",
+	Short: "Pause running sheds",
+	Long: `Pause one or more running sheds, freezing their processes in place.
+
+A paused shed uses no CPU but keeps its memory, so resuming is instant and
+nothing has to restart. Use stop instead to free memory as well. Connecting
+to a paused shed with console or SSH resumes it.
+
+When several sheds are given, they are paused with one batch request per server.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runPause,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <name>...",
+	Short: "Resume paused sheds",
+	Long: `Resume one or more paused sheds.
+
+When several sheds are given, they are resumed with one batch request per server.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runResume,
 }
 
 var (
-	createRepo  string
-	createImage string
-	listAll     bool
-	deleteKeep  bool
-	deleteForce bool
+	createRepo       string
+	createImage      string
+	createPlatform   string
+	createDeployKey  string
+	createWorktree   bool
+	createBranch     string
+	createDepth      int
+	createSafetyPush bool
+	createTimezone   string
+	createLocale     string
+	createFwdAllow   []string
+	createFwdDeny    []string
+	createResources  config.Resources
+	createNoIdleStop bool
+	createMounts     []string
+	createLabels     []string
+	createEnv        []string
+	createSecrets    []string
+	createNetwork    string
+	createPublish    []string
+	createHTTPPort   int
+	createServices   []string
+	createTTL        string
+	createTTLAction  string
+	createHealthCmd  string
+	createHealth     config.HealthCheck
+	createPreStop    config.PreStop
+	createDetach     bool
+	createExistsOK   bool
+	createWait       bool
+	listAll          bool
+	listWide         bool
+	listWatch        bool
+	listLabels       []string
+	findAll          bool
+	findFields       []string
+	deleteKeep       bool
+	deleteForce      bool
+	startCheckpoint  string
+	startWait        bool
 )
 
 func init() {
 	createCmd.Flags().StringVarP(&createRepo, "repo", "r", "", "Git repository URL to clone")
-	createCmd.Flags().StringVarP(&createImage, "image", "i", "", "Docker image to use")
+	createCmd.Flags().StringVarP(&createImage, "image", "i", "", "Docker image to use (see shed images)")
+	_ = createCmd.RegisterFlagCompletionFunc("image", completeImages)
+	createCmd.Flags().StringVar(&createPlatform, "platform", "", "Image platform to pull and run, e.g. linux/arm64 (default: the server's)")
+	createCmd.Flags().StringVar(&createDeployKey, "deploy-key", "", "Deploy key to use for cloning and fetching")
+	createCmd.Flags().BoolVar(&createWorktree, "worktree", false, "Check out as a worktree of a clone shared with other sheds for the repo")
+	createCmd.Flags().StringVarP(&createBranch, "branch", "b", "", "Branch or tag to clone (worktree mode: branch to check out, default new shed/<name> branch)")
+	createCmd.Flags().IntVar(&createDepth, "depth", 0, "Clone only this many recent commits")
+	createCmd.Flags().BoolVar(&createSafetyPush, "safety-push", false, "Back up uncommitted work before delete (default: server policy)")
+	createCmd.Flags().StringVar(&createTimezone, "timezone", "", "Timezone inside the shed, e.g. America/New_York (default: server setting)")
+	createCmd.Flags().StringVar(&createLocale, "locale", "", "Locale inside the shed, e.g. en_US.UTF-8 (default: server setting)")
+	createCmd.Flags().StringSliceVar(&createFwdAllow, "forward-allow", nil, "Only allow SSH port forwarding for these ports or ranges, e.g. 3000,8000-8099")
+	createCmd.Flags().StringSliceVar(&createFwdDeny, "forward-deny", nil, "Deny SSH port forwarding for these ports or ranges")
+	createCmd.Flags().BoolVar(&createNoIdleStop, "no-idle-stop", false, "Keep the shed running when idle, ignoring the server's idle timeout")
+	createCmd.Flags().Float64Var(&createResources.CPUs, "cpus", 0, "Number of CPUs the shed may use, e.g. 1.5 (default: server setting)")
+	createCmd.Flags().StringVar(&createResources.Memory, "memory", "", "Memory limit, e.g. 4g (default: server setting)")
+	createCmd.Flags().Int64Var(&createResources.PidsLimit, "pids-limit", 0, "Maximum number of processes (default: server setting)")
+	createCmd.Flags().StringArrayVar(&createMounts, "mount", nil, "Mount a host path or volume allowed by the server, as source:target[:ro] (repeatable)")
+	createCmd.Flags().StringArrayVarP(&createLabels, "label", "l", nil, "Add a label as key=value (repeatable)")
+	createCmd.Flags().StringArrayVarP(&createEnv, "env", "e", nil, "Set an environment variable as NAME=value (repeatable)")
+	createCmd.Flags().IntVar(&createHTTPPort, "http-port", 0, "Port in the shed to serve at its preview URL, if the server runs a preview proxy")
+	createCmd.Flags().StringArrayVarP(&createPublish, "publish", "p", nil, "Publish a port on the server as [hostIP:]hostPort:containerPort[/udp] (repeatable)")
+	createCmd.Flags().StringVar(&createNetwork, "network", "", "Attach to the shared shed network (\"shared\"), reachable from other shared sheds as <name>.shed.internal, or give the shed a network of its own (\"dedicated\"), reachable as <name>")
+	createCmd.Flags().StringArrayVar(&createServices, "service", nil, "Run a sidecar reachable on localhost, as name=image[,KEY=VALUE...] (repeatable)")
+	createCmd.Flags().StringVar(&createTTL, "ttl", "", "Delete the shed this long after creation, e.g. 2h or 30m")
+	createCmd.Flags().StringVar(&createTTLAction, "ttl-action", "", "What to do when the TTL runs out: delete or stop (default delete)")
+	createCmd.Flags().StringVar(&createHealth.Command, "health-cmd", "", "Command run in the shed to check it is usable, shown as healthy or unhealthy")
+	createCmd.Flags().StringVar(&createHealth.Interval, "health-interval", "", "Time between health checks, e.g. 30s (default 30s)")
+	createCmd.Flags().StringVar(&createHealth.Timeout, "health-timeout", "", "Time a health check may run before it fails (default 30s)")
+	createCmd.Flags().StringVar(&createHealth.StartPeriod, "health-start-period", "", "Time after start during which failed checks don't count, e.g. 2m")
+	createCmd.Flags().IntVar(&createHealth.Retries, "health-retries", 0, "Failed checks in a row before the shed is unhealthy (default 3)")
+	createCmd.Flags().StringVar(&createPreStop.Command, "pre-stop", "", "Command run in the shed before it is stopped, e.g. to save sessions")
+	createCmd.Flags().StringVar(&createPreStop.Timeout, "pre-stop-timeout", "", "Time the pre-stop command may run before the shed is stopped anyway (default 30s)")
+	createCmd.Flags().BoolVarP(&createDetach, "detach", "d", false, "Create the shed in the background and print the job ID")
+	createCmd.Flags().BoolVar(&createExistsOK, "exists-ok", false, "Succeed without changes if the shed already exists with the same repo and image")
+	createCmd.Flags().BoolVar(&createWait, "wait", false, "Wait until the shed is running before returning")
+	createCmd.MarkFlagsMutuallyExclusive("detach", "wait")
+	createCmd.Flags().StringArrayVar(&createSecrets, "secret", nil, "Give the shed a server secret, as a file in /run/secrets (name) or a variable (ENV_NAME=name) (repeatable)")
 
 	listCmd.Flags().BoolVarP(&listAll, "all", "a", false, "List sheds from all servers")
+	listCmd.Flags().BoolVarP(&listWide, "wide", "w", false, "Show image, resource usage, and session activity")
+	listCmd.Flags().BoolVar(&listWatch, "watch", false, "Keep the list open, redrawing it as sheds change")
+	listCmd.Flags().StringArrayVarP(&listLabels, "label", "l", nil, "Only list sheds with this label, as key=value (repeatable)")
+
+	findCmd.Flags().BoolVarP(&findAll, "all", "a", false, "Search sheds on all servers")
+	findCmd.Flags().StringSliceVar(&findFields, "fields", nil, "Fields to search: name, repo, labels (default: all)")
 
 	deleteCmd.Flags().BoolVar(&deleteKeep, "keep-volume", false, "Keep the data volume")
 	deleteCmd.Flags().BoolVarP(&deleteForce, "force", "f", false, "Delete without confirmation")
+
+	startCmd.Flags().StringVar(&startCheckpoint, "from-checkpoint", "", "Restore the shed from a checkpoint")
+	startCmd.Flags().BoolVar(&startWait, "wait", false, "Wait until the sheds are running before returning")
 }
 
 func runCreate(cmd *cobra.Command, args []string) error {
-	name := args[0]
+	var name string
+	if len(args) == 1 {
+		name = args[0]
+	} else if createRepo == "" {
+		return fmt.Errorf("a shed name is required unless --repo is given")
+	}
+
+	mounts := make([]config.ShedMount, 0, len(createMounts))
+	for _, spec := range createMounts {
+		m, err := config.ParseMount(spec)
+		if err != nil {
+			return err
+		}
+		mounts = append(mounts, m)
+	}
+	labels, err := parseLabelFlags(createLabels)
+	if err != nil {
+		return err
+	}
+	env, err := parseEnvFlags(createEnv)
+	if err != nil {
+		return err
+	}
+	secrets, err := parseSecretFlags(createSecrets)
+	if err != nil {
+		return err
+	}
+	services := make([]config.Service, 0, len(createServices))
+	for _, spec := range createServices {
+		svc, err := config.ParseService(spec)
+		if err != nil {
+			return err
+		}
+		services = append(services, svc)
+	}
 
 	entry, serverName, err := getServerEntry()
 	if err != nil {
@@ -84,33 +280,218 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	}
 
 	if verboseFlag {
-		fmt.Printf("Creating shed %s on %s...\n", name, serverName)
+		if name == "" {
+			fmt.Printf("Creating shed for %s on %s...\n", createRepo, serverName)
+		} else {
+			fmt.Printf("Creating shed %s on %s...\n", name, serverName)
+		}
 	}
 
 	client := NewAPIClientFromEntry(entry)
 	req := &config.CreateShedRequest{
-		Name:  name,
-		Repo:  createRepo,
-		Image: createImage,
+		Name:       name,
+		Repo:       createRepo,
+		Image:      createImage,
+		Platform:   createPlatform,
+		DeployKey:  createDeployKey,
+		Worktree:   createWorktree,
+		Depth:      createDepth,
+		Timezone:   createTimezone,
+		Locale:     createLocale,
+		Resources:  createResources,
+		NoIdleStop: createNoIdleStop,
+		Mounts:     mounts,
+		Labels:     labels,
+		Env:        env,
+		Secrets:    secrets,
+		Network:    createNetwork,
+		Ports:      createPublish,
+		HTTPPort:   createHTTPPort,
+		Services:   services,
+		TTL:        createTTL,
+		TTLAction:  createTTLAction,
+	}
+	// Worktrees check out a branch of the shared clone; otherwise the
+	// branch or tag is what gets cloned
+	if createWorktree {
+		req.Branch = createBranch
+	} else {
+		req.Ref = createBranch
+	}
+	if cmd.Flags().Changed("safety-push") {
+		req.SafetyPush = &createSafetyPush
+	}
+	if createHealth.Command != "" {
+		health := createHealth
+		req.HealthCheck = &health
+	}
+	if createPreStop.Command != "" {
+		preStop := createPreStop
+		req.PreStop = &preStop
+	}
+	if len(createFwdAllow) > 0 || len(createFwdDeny) > 0 {
+		req.Forwarding = &config.ForwardingRules{Allow: createFwdAllow, Deny: createFwdDeny}
+	}
+
+	if createExistsOK {
+		req.ExistsOK = true
+		if req.Name == "" {
+			req.Name = config.ShedNameFromRepo(req.Repo)
+		}
+		if req.Name != "" {
+			if done, err := reportExisting(client, req, serverName); done || err != nil {
+				return err
+			}
+		}
+	}
+
+	if createDetach {
+		return createDetached(client, req, serverName)
 	}
 
-	shed, err := client.CreateShed(req)
+	noteHistory(name, serverName, "")
+	shed, err := client.CreateShedWithProgress(req, createProgressPrinter())
 	if err != nil {
 		return fmt.Errorf("failed to create shed: %w", err)
 	}
 
+	// The server may have chosen the name, so record the undo once it is known
+	name = shed.Name
+	noteHistory(name, serverName, shedCommand("delete", name))
+
+	if createWait {
+		if shed, err = client.WaitForShed(name, config.StatusRunning, config.DefaultWaitTimeout); err != nil {
+			return fmt.Errorf("failed to wait for shed: %w", err)
+		}
+	}
+
 	// Cache the shed location
 	clientConfig.CacheShed(name, serverName, shed.Status)
 	if err := clientConfig.Save(); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
 	}
 
+	if ok, err := printStructured(shed); ok {
+		return err
+	}
+
 	printSuccess("Created shed %s on %s", name, serverName)
+	if shed.SetupError != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", shed.SetupError)
+		fmt.Fprintf(os.Stderr, "The shed is usable but its workspace may be incomplete.\n")
+	}
 	fmt.Printf("\nConnect with:\n  shed console %s\n", name)
+	if shed.PreviewURL != "" {
+		fmt.Printf("\nPreview port %d at:\n  %s\n", shed.HTTPPort, shed.PreviewURL)
+	}
+
+	return nil
+}
+
+// reportExisting reports an existing shed with the request's name, for
+// create --exists-ok. It returns false if there is no such shed, and an
+// error if the shed doesn't match the request.
+func reportExisting(client *APIClient, req *config.CreateShedRequest, serverName string) (bool, error) {
+	shed, err := client.GetShed(req.Name)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), config.ErrShedNotFound+":") {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check for shed %s: %w", req.Name, err)
+	}
+	if err := req.CheckExisting(shed); err != nil {
+		return true, err
+	}
+
+	clientConfig.CacheShed(shed.Name, serverName, shed.Status)
+	if err := clientConfig.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+	}
+
+	if ok, err := printStructured(shed); ok {
+		return true, err
+	}
+	printSuccess("Shed %s already exists on %s (%s)", shed.Name, serverName, shed.Status)
+	return true, nil
+}
+
+// createDetached starts creating a shed in the background and prints the
+// job tracking it.
+func createDetached(client *APIClient, req *config.CreateShedRequest, serverName string) error {
+	noteHistory(req.Name, serverName, "")
+	job, err := client.CreateShedAsync(req)
+	if err != nil {
+		return fmt.Errorf("failed to create shed: %w", err)
+	}
+	noteHistory(job.Shed, serverName, shedCommand("delete", job.Shed))
+
+	if ok, err := printStructured(job); ok {
+		return err
+	}
 
+	printSuccess("Creating shed %s on %s in the background (job %s)", job.Shed, serverName, job.ID)
+	follow := "shed jobs " + job.ID + " --wait"
+	if serverFlag != "" {
+		follow += " --server " + serverFlag
+	}
+	fmt.Printf("\nFollow it with:\n  %s\n", follow)
 	return nil
 }
 
+// createPhaseLabels describes each creation phase for progress output.
+var createPhaseLabels = map[string]string{
+	config.PhaseVolume:    "Create volume",
+	config.PhaseImage:     "Image",
+	config.PhaseContainer: "Create container",
+	config.PhaseStart:     "Start container",
+	config.PhaseServices:  "Start services",
+	config.PhaseClone:     "Clone repository",
+}
+
+// createProgressPrinter returns a function that prints a line for each
+// phase of shed creation as it starts and finishes. On a terminal, byte
+// progress within a phase, such as an image pull, updates a single line.
+func createProgressPrinter() func(config.CreateProgress) {
+	// Progress would corrupt JSON or YAML output
+	if structuredOutput() {
+		return func(config.CreateProgress) {}
+	}
+
+	tty := isTerminalOutput()
+	updating := false
+
+	return func(p config.CreateProgress) {
+		label := createPhaseLabels[p.Phase]
+		if label == "" {
+			label = p.Phase
+		}
+		if p.Message != "" {
+			label += ": " + p.Message
+		}
+
+		if p.Status == config.ProgressRunning {
+			if tty && p.Total > 0 {
+				fmt.Printf("\r\033[K  %s %s / %s (%d%%)", label, formatBytes(p.Current), formatBytes(p.Total), p.Current*100/p.Total)
+				updating = true
+			}
+			return
+		}
+		if updating {
+			fmt.Print("\r\033[K")
+			updating = false
+		}
+
+		switch p.Status {
+		case config.ProgressStarted:
+			fmt.Printf("  %s...\n", label)
+		case config.ProgressDone:
+			printSuccess("%s", label)
+		case config.ProgressFailed:
+			fmt.Fprintf(os.Stderr, "\u2717 %s\n", label)
+		}
+	}
+}
+
 func runList(cmd *cobra.Command, args []string) error {
 	entry, serverName, err := getServerEntry()
 	if err != nil && !listAll {
@@ -120,38 +501,227 @@ func runList(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	type shedWithServer struct {
-		shed   config.Shed
-		server string
+	if _, err := parseLabelFlags(listLabels); err != nil {
+		return err
 	}
 
-	var allSheds []shedWithServer
+	if listWatch {
+		if structuredOutput() {
+			return fmt.Errorf("--watch cannot be used with --output %s", outputFlag)
+		}
+		return watchList(entry, serverName)
+	}
+
+	allSheds, stats, failed, err := listSheds(entry, serverName)
+	if err != nil {
+		return err
+	}
+	writeServerErrors(os.Stderr, "reach", failed)
+
+	if structuredOutput() {
+		listed := make([]listedShed, len(allSheds))
+		for i, s := range allSheds {
+			listed[i] = listedShed{Shed: s.shed, Server: s.server}
+			if listWide {
+				listed[i].Stats = stats[i]
+			}
+		}
+		_, err := printStructured(listed)
+		return err
+	}
+
+	if len(allSheds) == 0 {
+		fmt.Println("No sheds found.")
+		fmt.Println("\nTo create a shed:")
+		fmt.Println("  shed create <name>")
+		return nil
+	}
+
+	writeShedTable(os.Stdout, allSheds, stats)
+	return nil
+}
+
+// listSheds collects the sheds shed list shows, sorted by name, with their
+// usage if --wide is set, and the servers that couldn't be reached.
+func listSheds(entry *config.ServerEntry, serverName string) ([]shedWithServer, []*config.ShedStats, []serverError, error) {
+	allSheds, failed, err := collectSheds(listAll, entry, serverName, listLabels...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Sort by name
+	sort.Slice(allSheds, func(i, j int) bool {
+		return allSheds[i].shed.Name < allSheds[j].shed.Name
+	})
+
+	var stats []*config.ShedStats
+	if listWide {
+		stats = fetchShedStats(allSheds, true)
+	}
+	return allSheds, stats, failed, nil
+}
 
+// writeShedTable writes the shed list table, followed by any setup errors.
+func writeShedTable(out io.Writer, allSheds []shedWithServer, stats []*config.ShedStats) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := []string{"NAME"}
 	if listAll {
-		// Query all servers
+		header = append(header, "SERVER")
+	}
+	header = append(header, "STATUS", "CREATED")
+	// Only show expiry when some shed has a TTL
+	expiring := slices.ContainsFunc(allSheds, func(s shedWithServer) bool {
+		return s.shed.ExpiresAt != nil
+	})
+	if expiring {
+		header = append(header, "EXPIRES")
+	}
+	if listWide {
+		header = append(header, "IMAGE", "DISK", "CPU", "MEM", "SESSIONS", "ACTIVITY")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for i, s := range allSheds {
+		row := []string{s.shed.Name}
+		if listAll {
+			row = append(row, s.server)
+		}
+		row = append(row, shedStatus(s.shed), s.shed.CreatedAt.Format("2006-01-02 15:04"))
+		if expiring {
+			row = append(row, formatExpiry(s.shed))
+		}
+		if listWide {
+			row = append(row, wideColumns(s.shed, stats[i])...)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	w.Flush()
+
+	var failed []string
+	for _, s := range allSheds {
+		if s.shed.SetupError != "" {
+			failed = append(failed, fmt.Sprintf("  %s: %s", s.shed.Name, s.shed.SetupError))
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintln(out, "\nSetup errors:")
+		fmt.Fprintln(out, strings.Join(failed, "\n"))
+	}
+}
+
+// Timing for shed list --watch.
+const (
+	// listWatchRefresh is how often the list is redrawn without events,
+	// keeping expiry countdowns and usage current.
+	listWatchRefresh = 10 * time.Second
+	// listWatchDebounce groups a burst of events into one redraw.
+	listWatchDebounce = 250 * time.Millisecond
+	// listWatchRetry is how long to wait before reconnecting a dropped
+	// event stream.
+	listWatchRetry = 5 * time.Second
+)
+
+// watchList redraws the shed list whenever a server sends a shed event,
+// and every listWatchRefresh, until interrupted.
+func watchList(entry *config.ServerEntry, serverName string) error {
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	servers := map[string]*config.ServerEntry{serverName: entry}
+	if listAll {
+		servers = make(map[string]*config.ServerEntry, len(clientConfig.Servers))
 		for name, e := range clientConfig.Servers {
-			client := NewAPIClientFromEntry(&e)
-			resp, err := client.ListSheds()
-			if err != nil {
-				if verboseFlag {
-					fmt.Fprintf(os.Stderr, "Warning: could not reach %s: %v\n", name, err)
-				}
-				continue
+			servers[name] = &e
+		}
+	}
+	for _, e := range servers {
+		go func() {
+			client := NewAPIClientFromEntry(e)
+			for {
+				_ = client.StreamEvents("", func(config.ShedEvent) error {
+					notify()
+					return nil
+				})
+				// Sheds may have changed while disconnected
+				notify()
+				time.Sleep(listWatchRetry)
 			}
-			for _, shed := range resp.Sheds {
-				allSheds = append(allSheds, shedWithServer{shed: shed, server: name})
+		}()
+	}
+
+	ticker := time.NewTicker(listWatchRefresh)
+	defer ticker.Stop()
+
+	terminal := isTerminalOutput()
+	for {
+		allSheds, stats, failed, err := listSheds(entry, serverName)
+
+		var buf bytes.Buffer
+		if terminal {
+			buf.WriteString("\033[H\033[2J")
+		}
+		fmt.Fprintf(&buf, "Updated %s, watching for changes. Press Ctrl-C to stop.\n\n", time.Now().Format("15:04:05"))
+		writeServerErrors(&buf, "reach", failed)
+		switch {
+		case err != nil:
+			fmt.Fprintf(&buf, "Error: %v\n", err)
+		case len(allSheds) == 0:
+			fmt.Fprintln(&buf, "No sheds found.")
+		default:
+			writeShedTable(&buf, allSheds, stats)
+		}
+		if !terminal {
+			buf.WriteString("\n")
+		}
+		os.Stdout.Write(buf.Bytes())
+
+		select {
+		case <-changed:
+			time.Sleep(listWatchDebounce)
+			select {
+			case <-changed:
+			default:
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectSheds lists the sheds on the given server, or on every configured
+// server if all is set, updating the shed cache as it goes. Only sheds with
+// every key=value label given are listed. When listing all, servers are
+// queried concurrently and unreachable ones are skipped and returned for
+// the caller to report.
+func collectSheds(all bool, entry *config.ServerEntry, serverName string, labels ...string) ([]shedWithServer, []serverError, error) {
+	var allSheds []shedWithServer
+	var failed []serverError
+
+	if all {
+		var results []serverResult[*config.ShedsResponse]
+		results, failed = queryServers(func(client *APIClient) (*config.ShedsResponse, error) {
+			return client.ListSheds(labels...)
+		})
+		for _, r := range results {
+			for _, shed := range r.value.Sheds {
+				allSheds = append(allSheds, shedWithServer{shed: shed, server: r.name, entry: r.entry})
 				// Update cache
-				clientConfig.CacheShed(shed.Name, name, shed.Status)
+				clientConfig.CacheShed(shed.Name, r.name, shed.Status)
 			}
 		}
 	} else {
 		client := NewAPIClientFromEntry(entry)
-		resp, err := client.ListSheds()
+		resp, err := client.ListSheds(labels...)
 		if err != nil {
-			return fmt.Errorf("failed to list sheds: %w", err)
+			return nil, nil, fmt.Errorf("failed to list sheds: %w", err)
 		}
 		for _, shed := range resp.Sheds {
-			allSheds = append(allSheds, shedWithServer{shed: shed, server: serverName})
+			allSheds = append(allSheds, shedWithServer{shed: shed, server: serverName, entry: entry})
 			// Update cache
 			clientConfig.CacheShed(shed.Name, serverName, shed.Status)
 		}
@@ -164,35 +734,193 @@ func runList(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if len(allSheds) == 0 {
-		fmt.Println("No sheds found.")
-		fmt.Println("\nTo create a shed:")
-		fmt.Println("  shed create <name>")
-		return nil
+	return allSheds, failed, nil
+}
+
+// shedStatus returns a shed's status for display, flagging failed setup,
+// why the server stopped it, and its health.
+func shedStatus(shed config.Shed) string {
+	if shed.SetupError != "" {
+		return shed.Status + " (setup failed)"
 	}
+	if shed.StoppedReason != "" {
+		return shed.Status + " (" + shed.StoppedReason + ")"
+	}
+	if shed.Health != "" {
+		return shed.Status + " (" + shed.Health + ")"
+	}
+	return shed.Status
+}
 
-	// Sort by name
-	sort.Slice(allSheds, func(i, j int) bool {
-		return allSheds[i].shed.Name < allSheds[j].shed.Name
-	})
+// formatExpiry describes when a shed with a TTL expires as a countdown and
+// what happens then, e.g. "in 1h20m (delete)", or "-" if it has no TTL.
+func formatExpiry(shed config.Shed) string {
+	if shed.ExpiresAt == nil {
+		return "-"
+	}
+	d := time.Until(*shed.ExpiresAt).Round(time.Minute)
+	switch {
+	case d <= 0:
+		return "expired (" + shed.TTLAction + ")"
+	case d < time.Hour:
+		return fmt.Sprintf("in %dm (%s)", int(d.Minutes()), shed.TTLAction)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("in %dh%02dm (%s)", int(d.Hours()), int(d.Minutes())%60, shed.TTLAction)
+	default:
+		return fmt.Sprintf("in %dd%dh (%s)", int(d.Hours()/24), int(d.Hours())%24, shed.TTLAction)
+	}
+}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	if listAll {
-		fmt.Fprintln(w, "NAME\tSERVER\tSTATUS\tCREATED")
+// shedWithServer is a shed along with the server it was listed from.
+type shedWithServer struct {
+	shed   config.Shed
+	server string
+	entry  *config.ServerEntry
+}
+
+// listedShed is a shed as printed by list and find with --output json or
+// yaml. Stats are only included with --wide.
+type listedShed struct {
+	config.Shed
+	Server string            `json:"server"`
+	Stats  *config.ShedStats `json:"stats,omitempty"`
+}
+
+// fetchShedStats retrieves stats for each running shed concurrently. The
+// result is indexed like sheds; stopped or unreachable sheds have nil stats.
+// Session activity and workspace size are only fetched if activity is set.
+func fetchShedStats(sheds []shedWithServer, activity bool) []*config.ShedStats {
+	stats := make([]*config.ShedStats, len(sheds))
+	var wg sync.WaitGroup
+
+	for i, s := range sheds {
+		if s.shed.Status != config.StatusRunning {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, s shedWithServer) {
+			defer wg.Done()
+			client := NewAPIClientFromEntry(s.entry)
+			st, err := client.GetShedStats(s.shed.Name, activity)
+			if err != nil {
+				if verboseFlag {
+					fmt.Fprintf(os.Stderr, "Warning: could not get stats for %s: %v\n", s.shed.Name, err)
+				}
+				return
+			}
+			stats[i] = st
+		}(i, s)
+	}
+
+	wg.Wait()
+	return stats
+}
+
+// wideColumns returns the extra columns shown by list --wide.
+func wideColumns(shed config.Shed, stats *config.ShedStats) []string {
+	image := shed.Image
+	if image == "" {
+		image = "-"
+	}
+
+	if stats == nil {
+		return []string{image, "-", "-", "-", "-", "-"}
+	}
+
+	mem := formatBytes(int64(stats.MemoryUsage))
+	if stats.MemoryLimit > 0 {
+		mem += " / " + formatBytes(int64(stats.MemoryLimit))
+	}
+
+	return []string{
+		image,
+		formatBytes(stats.WorkspaceSize),
+		fmt.Sprintf("%.1f%%", stats.CPUPercent),
+		mem,
+		strconv.Itoa(stats.Sessions),
+		formatAgo(stats.LastActivity),
+	}
+}
+
+// formatBytes formats a byte count using binary units (e.g. "1.5G").
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func runFind(cmd *cobra.Command, args []string) error {
+	query := args[0]
+
+	entry, serverName, err := getServerEntry()
+	if err != nil && !findAll {
+		printError("no server configured",
+			"shed server add <hostname>  # Add a server first",
+			"shed find --all <query>     # Search all servers")
+		return err
+	}
+
+	type shedWithServer struct {
+		shed   config.Shed
+		server string
+	}
+
+	var found []shedWithServer
+
+	if findAll {
+		results, failed := queryServers(func(client *APIClient) (*config.ShedsResponse, error) {
+			return client.SearchSheds(query, findFields)
+		})
+		writeServerErrors(os.Stderr, "search", failed)
+		for _, r := range results {
+			for _, shed := range r.value.Sheds {
+				found = append(found, shedWithServer{shed: shed, server: r.name})
+			}
+		}
 	} else {
-		fmt.Fprintln(w, "NAME\tSTATUS\tCREATED")
+		client := NewAPIClientFromEntry(entry)
+		resp, err := client.SearchSheds(query, findFields)
+		if err != nil {
+			return fmt.Errorf("failed to search sheds: %w", err)
+		}
+		for _, shed := range resp.Sheds {
+			found = append(found, shedWithServer{shed: shed, server: serverName})
+		}
 	}
 
-	for _, s := range allSheds {
-		created := s.shed.CreatedAt.Format("2006-01-02 15:04")
-		if listAll {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.shed.Name, s.server, s.shed.Status, created)
-		} else {
-			fmt.Fprintf(w, "%s\t%s\t%s\n", s.shed.Name, s.shed.Status, created)
+	if len(found) == 0 && !structuredOutput() {
+		fmt.Printf("No sheds matching %q.\n", query)
+		return nil
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].shed.Name < found[j].shed.Name
+	})
+
+	if structuredOutput() {
+		listed := make([]listedShed, len(found))
+		for i, s := range found {
+			listed[i] = listedShed{Shed: s.shed, Server: s.server}
 		}
+		_, err := printStructured(listed)
+		return err
 	}
 
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSERVER\tSTATUS\tREPO")
+	for _, s := range found {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.shed.Name, s.server, shedStatus(s.shed), s.shed.Repo)
+	}
 	w.Flush()
+
 	return nil
 }
 
@@ -207,22 +935,25 @@ func runDelete(cmd *cobra.Command, args []string) error {
 
 	// Confirm deletion unless --force
 	if !deleteForce {
-		fmt.Printf("Delete shed %q on %s? ", name, serverName)
+		prompt := fmt.Sprintf("Delete shed %q on %s?", name, serverName)
 		if !deleteKeep {
-			fmt.Print("This will also delete the data volume. ")
+			prompt += " This will also delete the data volume."
 		}
-		fmt.Print("[y/N] ")
-
-		reader := bufio.NewReader(os.Stdin)
-		response, _ := reader.ReadString('\n')
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
+		if !confirm(prompt) {
 			fmt.Println("Cancelled.")
 			return nil
 		}
 	}
 
 	client := NewAPIClientFromEntry(entry)
+
+	// Record how to recreate the shed before it is gone
+	undo := ""
+	if shed, err := client.GetShed(name); err == nil {
+		undo = recreateCommand(shed, serverName)
+	}
+	noteHistory(name, serverName, undo)
+
 	if err := client.DeleteShed(name, deleteKeep); err != nil {
 		return fmt.Errorf("failed to delete shed: %w", err)
 	}
@@ -240,6 +971,19 @@ func runDelete(cmd *cobra.Command, args []string) error {
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		if startCheckpoint != "" {
+			return fmt.Errorf("--from-checkpoint can only be used with a single shed")
+		}
+		if err := runBatchAction(config.BatchActionStart, args, "Started"); err != nil {
+			return err
+		}
+		if startWait {
+			return waitForRunning(args)
+		}
+		return nil
+	}
+
 	name := args[0]
 
 	serverName, entry, err := findShedServer(name)
@@ -252,10 +996,22 @@ func runStart(cmd *cobra.Command, args []string) error {
 	}
 
 	client := NewAPIClientFromEntry(entry)
-	shed, err := client.StartShed(name)
+	noteHistory(name, serverName, shedCommand("stop", name))
+
+	var shed *config.Shed
+	if startCheckpoint != "" {
+		shed, err = client.RestoreShed(name, startCheckpoint)
+	} else {
+		shed, err = client.StartShed(name)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to start shed: %w", err)
 	}
+	if startWait {
+		if shed, err = client.WaitForShed(name, config.StatusRunning, config.DefaultWaitTimeout); err != nil {
+			return fmt.Errorf("failed to wait for shed: %w", err)
+		}
+	}
 
 	// Update cache
 	clientConfig.CacheShed(name, serverName, shed.Status)
@@ -270,6 +1026,10 @@ func runStart(cmd *cobra.Command, args []string) error {
 }
 
 func runStop(cmd *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		return runBatchAction(config.BatchActionStop, args, "Stopped")
+	}
+
 	name := args[0]
 
 	serverName, entry, err := findShedServer(name)
@@ -282,6 +1042,7 @@ func runStop(cmd *cobra.Command, args []string) error {
 	}
 
 	client := NewAPIClientFromEntry(entry)
+	noteHistory(name, serverName, shedCommand("start", name))
 	shed, err := client.StopShed(name)
 	if err != nil {
 		return fmt.Errorf("failed to stop shed: %w", err)
@@ -299,6 +1060,175 @@ func runStop(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runPause(cmd *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		return runBatchAction(config.BatchActionPause, args, "Paused")
+	}
+
+	name := args[0]
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	if verboseFlag {
+		fmt.Printf("Pausing shed %s on %s...\n", name, serverName)
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	noteHistory(name, serverName, shedCommand("resume", name))
+	shed, err := client.PauseShed(name)
+	if err != nil {
+		return fmt.Errorf("failed to pause shed: %w", err)
+	}
+
+	// Update cache
+	clientConfig.CacheShed(name, serverName, shed.Status)
+	if err := clientConfig.Save(); err != nil {
+		if verboseFlag {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		}
+	}
+
+	printSuccess("Paused shed %s", name)
+	return nil
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		return runBatchAction(config.BatchActionResume, args, "Resumed")
+	}
+
+	name := args[0]
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	if verboseFlag {
+		fmt.Printf("Resuming shed %s on %s...\n", name, serverName)
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	noteHistory(name, serverName, shedCommand("pause", name))
+	shed, err := client.ResumeShed(name)
+	if err != nil {
+		return fmt.Errorf("failed to resume shed: %w", err)
+	}
+
+	// Update cache
+	clientConfig.CacheShed(name, serverName, shed.Status)
+	if err := clientConfig.Save(); err != nil {
+		if verboseFlag {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		}
+	}
+
+	printSuccess("Resumed shed %s", name)
+	return nil
+}
+
+// batchUndoActions maps each reversible batch action to the command that reverses it.
+var batchUndoActions = map[string]string{
+	config.BatchActionStart:  "stop",
+	config.BatchActionStop:   "start",
+	config.BatchActionPause:  "resume",
+	config.BatchActionResume: "pause",
+}
+
+// recreateCommand returns the create command for a shed's repo and image.
+func recreateCommand(shed *config.Shed, serverName string) string {
+	args := []string{"create", shed.Name}
+	if shed.Repo != "" {
+		args = append(args, "--repo", shed.Repo)
+	}
+	if shed.Image != "" {
+		args = append(args, "--image", shed.Image)
+	}
+	args = append(args, "--server", serverName)
+	return shedCommand(args...)
+}
+
+// runBatchAction applies an action to several sheds, sending one batch request
+// per server. Each shed's outcome is reported individually.
+func runBatchAction(action string, names []string, verb string) error {
+	byServer := make(map[string][]string)
+	entries := make(map[string]*config.ServerEntry)
+	// Every shed starts out failed and is crossed off when its result says
+	// it succeeded, so one the server leaves out still counts as failed
+	failed := make(map[string]bool, len(names))
+	for _, name := range names {
+		failed[name] = true
+	}
+	total := len(failed)
+
+	for _, name := range names {
+		serverName, entry, err := findShedServer(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", name, err)
+			continue
+		}
+		byServer[serverName] = append(byServer[serverName], name)
+		entries[serverName] = entry
+	}
+
+	serverNames := make([]string, 0, len(byServer))
+	for serverName := range byServer {
+		serverNames = append(serverNames, serverName)
+	}
+	sort.Strings(serverNames)
+
+	historyServer := ""
+	if len(serverNames) == 1 {
+		historyServer = serverNames[0]
+	}
+	noteHistory(strings.Join(names, " "), historyServer, shedCommand(append([]string{batchUndoActions[action]}, names...)...))
+
+	for _, serverName := range serverNames {
+		req := &config.BatchRequest{}
+		for _, name := range byServer[serverName] {
+			req.Operations = append(req.Operations, config.BatchOperation{Action: action, Name: name})
+		}
+
+		if verboseFlag {
+			fmt.Printf("Sending %d operations to %s...\n", len(req.Operations), serverName)
+		}
+
+		client := NewAPIClientFromEntry(entries[serverName])
+		resp, err := client.Batch(req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", serverName, err)
+			continue
+		}
+
+		for _, result := range resp.Results {
+			if result.Error != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %s: %s\n", result.Name, result.Error.Code, result.Error.Message)
+				continue
+			}
+			delete(failed, result.Name)
+			if result.Shed != nil {
+				clientConfig.CacheShed(result.Name, serverName, result.Shed.Status)
+			}
+			printSuccess("%s shed %s", verb, result.Name)
+		}
+	}
+
+	// Update cache
+	if err := clientConfig.Save(); err != nil {
+		if verboseFlag {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d sheds failed: %s", len(failed), total, strings.Join(slices.Sorted(maps.Keys(failed)), ", "))
+	}
+	return nil
+}
+
 // findShedServer finds which server hosts a shed.
 // It first checks the cache, then queries servers if not found.
 func findShedServer(name string) (string, *config.ServerEntry, error) {
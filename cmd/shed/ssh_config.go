@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -18,8 +20,12 @@ var sshConfigCmd = &cobra.Command{
 
 Without flags, prints the SSH config for a shed (or all sheds with --all).
 Use --install to add entries to ~/.ssh/config.
+Use --install --include to write them to ~/.ssh/shed_config instead and
+add a single "Include shed_config" line to ~/.ssh/config. Later installs
+keep using the include file.
 Use --dry-run to preview changes without applying them.
-Use --uninstall to remove all shed-managed entries.`,
+Use --uninstall to remove all shed-managed entries, the Include line, and
+the include file.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runSSHConfig,
 }
@@ -29,6 +35,7 @@ var (
 	sshConfigInstall   bool
 	sshConfigDryRun    bool
 	sshConfigUninstall bool
+	sshConfigInclude   bool
 )
 
 func init() {
@@ -36,6 +43,7 @@ func init() {
 	sshConfigCmd.Flags().BoolVar(&sshConfigInstall, "install", false, "Install entries to ~/.ssh/config")
 	sshConfigCmd.Flags().BoolVar(&sshConfigDryRun, "dry-run", false, "Show what would be changed without making changes")
 	sshConfigCmd.Flags().BoolVar(&sshConfigUninstall, "uninstall", false, "Remove all shed-managed entries from ~/.ssh/config")
+	sshConfigCmd.Flags().BoolVar(&sshConfigInclude, "include", false, "Install entries to ~/.ssh/shed_config and include it from ~/.ssh/config")
 
 	rootCmd.AddCommand(sshConfigCmd)
 }
@@ -110,25 +118,19 @@ func getAllShedsInfo() ([]shedInfo, error) {
 	var result []shedInfo
 
 	// Query all servers for their sheds
-	for serverName, entry := range clientConfig.Servers {
-		entryCopy := entry
-		client := NewAPIClientFromEntry(&entryCopy)
-		resp, err := client.ListSheds()
-		if err != nil {
-			if verboseFlag {
-				fmt.Fprintf(os.Stderr, "Warning: could not reach %s: %v\n", serverName, err)
-			}
-			continue
-		}
-
-		for _, shed := range resp.Sheds {
+	results, failed := queryServers(func(client *APIClient) (*config.ShedsResponse, error) {
+		return client.ListSheds()
+	})
+	writeServerErrors(os.Stderr, "reach", failed)
+	for _, r := range results {
+		for _, shed := range r.value.Sheds {
 			result = append(result, shedInfo{
 				name:       shed.Name,
-				serverName: serverName,
-				server:     &entryCopy,
+				serverName: r.name,
+				server:     r.entry,
 			})
 			// Update cache
-			clientConfig.CacheShed(shed.Name, serverName, shed.Status)
+			clientConfig.CacheShed(shed.Name, r.name, shed.Status)
 		}
 	}
 
@@ -153,18 +155,93 @@ func generateEntries(sheds []shedInfo) []sshconfig.Entry {
 
 	for _, shed := range sheds {
 		entry := sshconfig.Entry{
-			Name:           "shed-" + shed.name,
-			Host:           shed.server.Host,
-			Port:           shed.server.SSHPort,
+			Name:           sshHostAlias(shed.name),
 			User:           shed.name,
 			KnownHostsFile: knownHostsPath,
 		}
+		applyServerSSH(&entry, shed.server)
 		entries = append(entries, entry)
 	}
 
 	return entries
 }
 
+// applyServerSSH sets the parts of an entry that come from its server: the
+// address and any extra SSH options.
+func applyServerSSH(entry *sshconfig.Entry, server *config.ServerEntry) {
+	entry.Host = server.Host
+	entry.Port = server.SSHPort
+	entry.ProxyJump = server.SSH.ProxyJump
+	entry.IdentityFile = server.SSH.IdentityFile
+	entry.ForwardAgent = server.SSH.ForwardAgent
+	entry.ExtraOptions = maps.Clone(server.SSH.Options)
+}
+
+// sshHostAlias returns the SSH config host alias for a shed.
+func sshHostAlias(shedName string) string {
+	return "shed-" + shedName
+}
+
+// refreshManagedEntries rewrites installed SSH config entries for sheds cached
+// on serverName so they match the server's current host, port, and SSH
+// options, whether they are in ~/.ssh/config or the include file. It returns
+// the number of entries updated and is a no-op when no managed block exists.
+func refreshManagedEntries(serverName string) (int, error) {
+	server, err := clientConfig.GetServer(serverName)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, path := range []string{sshconfig.GetSSHConfigPath(), sshconfig.GetIncludePath()} {
+		n, err := refreshManagedEntriesIn(path, serverName, server)
+		if err != nil {
+			return updated, err
+		}
+		updated += n
+	}
+	return updated, nil
+}
+
+// refreshManagedEntriesIn refreshes the entries in the managed block of one
+// SSH config file.
+func refreshManagedEntriesIn(path, serverName string, server *config.ServerEntry) (int, error) {
+	content, err := readSSHConfig(path)
+	if err != nil {
+		return 0, err
+	}
+
+	parsed := sshconfig.Parse(content)
+	if !parsed.HasManagedBlock {
+		return 0, nil
+	}
+
+	updated := 0
+	entries := make([]sshconfig.Entry, 0, len(parsed.ManagedEntries))
+	for _, managed := range parsed.ManagedEntries {
+		entry := managed.Entry()
+		shedName := strings.TrimPrefix(entry.Name, sshHostAlias(""))
+		if cache, ok := clientConfig.Sheds[shedName]; ok && cache.Server == serverName {
+			before := sshconfig.GenerateEntry(entry)
+			applyServerSSH(&entry, server)
+			if sshconfig.GenerateEntry(entry) != before {
+				updated++
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if updated == 0 {
+		return 0, nil
+	}
+
+	if err := sshconfig.Write(path, parsed.BeforeBlock, entries, parsed.AfterBlock); err != nil {
+		return 0, fmt.Errorf("failed to write SSH config: %w", err)
+	}
+
+	return updated, nil
+}
+
 func printSSHConfig(entries []sshconfig.Entry) {
 	for i, entry := range entries {
 		fmt.Print(sshconfig.GenerateEntry(entry))
@@ -178,31 +255,37 @@ func runSSHConfigInstall(entries []sshconfig.Entry) error {
 	sshConfigPath := sshconfig.GetSSHConfigPath()
 
 	// Read existing config
-	var content string
-	data, err := os.ReadFile(sshConfigPath)
+	content, err := readSSHConfig(sshConfigPath)
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return fmt.Errorf("failed to read SSH config: %w", err)
+		return err
+	}
+	mainParsed := sshconfig.Parse(content)
+
+	// Once installed with an Include line, entries stay in their own file
+	useInclude := sshConfigInclude || sshconfig.HasInclude(content)
+	targetPath := sshConfigPath
+	parsed := mainParsed
+	if useInclude {
+		targetPath = sshconfig.GetIncludePath()
+		includeContent, err := readSSHConfig(targetPath)
+		if err != nil {
+			return err
 		}
-		// File doesn't exist, start fresh
-		content = ""
-	} else {
-		content = string(data)
+		parsed = sshconfig.Parse(includeContent)
 	}
-
-	// Parse existing config
-	parsed := sshconfig.Parse(content)
+	addInclude := useInclude && !sshconfig.HasInclude(content)
+	moveBlock := useInclude && mainParsed.HasManagedBlock
 
 	// Compute diff
 	diff := sshconfig.ComputeDiff(parsed.ManagedEntries, entries)
 
 	// Show diff
-	if !diff.HasChanges() && parsed.HasManagedBlock {
+	if !diff.HasChanges() && parsed.HasManagedBlock && !addInclude && !moveBlock {
 		fmt.Println("SSH config is already up to date.")
 		return nil
 	}
 
-	fmt.Printf("SSH config file: %s\n\n", sshConfigPath)
+	fmt.Printf("SSH config file: %s\n\n", targetPath)
 
 	if len(diff.Additions) > 0 {
 		fmt.Println("Entries to add:")
@@ -218,6 +301,13 @@ func runSSHConfigInstall(entries []sshconfig.Entry) error {
 		}
 	}
 
+	if len(diff.Updates) > 0 {
+		fmt.Println("Entries to update:")
+		for _, name := range diff.Updates {
+			fmt.Printf("  ~ %s\n", name)
+		}
+	}
+
 	if len(diff.Unchanged) > 0 && verboseFlag {
 		fmt.Println("Entries unchanged:")
 		for _, name := range diff.Unchanged {
@@ -228,6 +318,12 @@ func runSSHConfigInstall(entries []sshconfig.Entry) error {
 	if !parsed.HasManagedBlock && len(entries) > 0 {
 		fmt.Println("\nManaged block will be created.")
 	}
+	if addInclude {
+		fmt.Printf("\n%q will be added to %s.\n", sshconfig.IncludeLine, sshConfigPath)
+	}
+	if moveBlock {
+		fmt.Printf("The managed block in %s will be removed.\n", sshConfigPath)
+	}
 
 	// Dry run - stop here
 	if sshConfigDryRun {
@@ -236,12 +332,22 @@ func runSSHConfigInstall(entries []sshconfig.Entry) error {
 	}
 
 	// Write the config
-	err = sshconfig.Write(sshConfigPath, parsed.BeforeBlock, entries, parsed.AfterBlock)
+	err = sshconfig.Write(targetPath, parsed.BeforeBlock, entries, parsed.AfterBlock)
 	if err != nil {
 		return fmt.Errorf("failed to write SSH config: %w", err)
 	}
 
-	printSuccess("Updated SSH config at %s", sshConfigPath)
+	// The Include goes in after the file it names has been written
+	if addInclude || moveBlock {
+		if moveBlock {
+			content = sshconfig.Render(mainParsed.BeforeBlock, nil, mainParsed.AfterBlock)
+		}
+		if err := sshconfig.WriteFile(sshConfigPath, sshconfig.AddInclude(content)); err != nil {
+			return fmt.Errorf("failed to write SSH config: %w", err)
+		}
+	}
+
+	printSuccess("Updated SSH config at %s", targetPath)
 
 	// Print usage hint
 	if len(entries) > 0 {
@@ -256,21 +362,25 @@ func runSSHConfigInstall(entries []sshconfig.Entry) error {
 
 func runSSHConfigUninstall() error {
 	sshConfigPath := sshconfig.GetSSHConfigPath()
+	includePath := sshconfig.GetIncludePath()
 
 	// Read existing config
-	data, err := os.ReadFile(sshConfigPath)
+	content, err := readSSHConfig(sshConfigPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("No SSH config file found.")
-			return nil
-		}
-		return fmt.Errorf("failed to read SSH config: %w", err)
+		return err
+	}
+	includeContent, err := readSSHConfig(includePath)
+	if err != nil {
+		return err
 	}
 
 	// Parse existing config
-	parsed := sshconfig.Parse(string(data))
+	parsed := sshconfig.Parse(content)
+	hasInclude := sshconfig.HasInclude(content)
+	_, statErr := os.Stat(includePath)
+	hasIncludeFile := statErr == nil
 
-	if !parsed.HasManagedBlock {
+	if !parsed.HasManagedBlock && !hasInclude && !hasIncludeFile {
 		fmt.Println("No shed-managed entries found in SSH config.")
 		return nil
 	}
@@ -278,9 +388,15 @@ func runSSHConfigUninstall() error {
 	// Show what will be removed
 	fmt.Printf("SSH config file: %s\n\n", sshConfigPath)
 	fmt.Println("Entries to remove:")
-	for _, entry := range parsed.ManagedEntries {
+	for _, entry := range append(parsed.ManagedEntries, sshconfig.Parse(includeContent).ManagedEntries...) {
 		fmt.Printf("  - %s\n", entry.Name)
 	}
+	if hasInclude {
+		fmt.Printf("\n%q will be removed from %s.\n", sshconfig.IncludeLine, sshConfigPath)
+	}
+	if hasIncludeFile {
+		fmt.Printf("%s will be deleted.\n", includePath)
+	}
 
 	// Dry run - stop here
 	if sshConfigDryRun {
@@ -288,12 +404,75 @@ func runSSHConfigUninstall() error {
 		return nil
 	}
 
-	// Remove the managed block
-	err = sshconfig.Remove(sshConfigPath)
-	if err != nil {
-		return fmt.Errorf("failed to update SSH config: %w", err)
+	// Remove the managed block and Include line
+	if parsed.HasManagedBlock || hasInclude {
+		if parsed.HasManagedBlock {
+			content = sshconfig.Render(parsed.BeforeBlock, nil, parsed.AfterBlock)
+		}
+		if err := sshconfig.WriteFile(sshConfigPath, sshconfig.RemoveInclude(content)); err != nil {
+			return fmt.Errorf("failed to update SSH config: %w", err)
+		}
+	}
+	if hasIncludeFile {
+		if err := os.Remove(includePath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", includePath, err)
+		}
 	}
 
 	printSuccess("Removed shed-managed entries from %s", sshConfigPath)
 	return nil
 }
+
+// readSSHConfig returns the content of an SSH config file, or nothing if
+// it doesn't exist.
+func readSSHConfig(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read SSH config: %w", err)
+	}
+	return string(data), nil
+}
+
+// ensureSSHConfigEntry adds or updates a single managed entry, leaving the
+// other managed entries alone. The entry goes in the include file if
+// ~/.ssh/config already includes it. It returns the file written, or
+// nothing if the entry was already up to date.
+func ensureSSHConfigEntry(entry sshconfig.Entry) (string, error) {
+	path := sshconfig.GetSSHConfigPath()
+	content, err := readSSHConfig(path)
+	if err != nil {
+		return "", err
+	}
+	if sshconfig.HasInclude(content) {
+		path = sshconfig.GetIncludePath()
+		if content, err = readSSHConfig(path); err != nil {
+			return "", err
+		}
+	}
+
+	parsed := sshconfig.Parse(content)
+	entries := make([]sshconfig.Entry, 0, len(parsed.ManagedEntries)+1)
+	found := false
+	for _, managed := range parsed.ManagedEntries {
+		if managed.Name != entry.Name {
+			entries = append(entries, managed.Entry())
+			continue
+		}
+		if sshconfig.GenerateEntry(managed.Entry()) == sshconfig.GenerateEntry(entry) {
+			return "", nil
+		}
+		entries = append(entries, entry)
+		found = true
+	}
+	if !found {
+		entries = append(entries, entry)
+	}
+
+	if err := sshconfig.Write(path, parsed.BeforeBlock, entries, parsed.AfterBlock); err != nil {
+		return "", fmt.Errorf("failed to write SSH config: %w", err)
+	}
+	return path, nil
+}
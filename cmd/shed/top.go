@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top [name]",
+	Short: "Show live resource usage of running sheds",
+	Long: `Show CPU, memory, network, and disk usage of running sheds, busiest
+first, refreshing until interrupted.
+
+Network and block I/O are totals since each shed was started. Give a shed
+name to watch just that shed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTop,
+}
+
+var (
+	topAll      bool
+	topInterval time.Duration
+	topOnce     bool
+)
+
+func init() {
+	topCmd.Flags().BoolVarP(&topAll, "all", "a", false, "Show sheds from all servers")
+	topCmd.Flags().DurationVar(&topInterval, "interval", 2*time.Second, "How often to refresh")
+	topCmd.Flags().BoolVar(&topOnce, "once", false, "Print usage once and exit")
+
+	rootCmd.AddCommand(topCmd)
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	if topInterval < time.Second {
+		return fmt.Errorf("--interval must be at least 1s")
+	}
+
+	load, err := topSource(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for {
+		sheds, failed, err := load()
+		if err != nil {
+			return err
+		}
+
+		var running []shedWithServer
+		for _, s := range sheds {
+			if s.shed.Status == config.StatusRunning {
+				running = append(running, s)
+			}
+		}
+		stats := fetchShedStats(running, false)
+
+		// Render before clearing the screen so the refresh doesn't flicker
+		var buf bytes.Buffer
+		writeServerErrors(&buf, "reach", failed)
+		renderTop(&buf, running, stats, len(sheds)-len(running))
+		if !topOnce {
+			fmt.Print("\033[H\033[2J")
+			fmt.Printf("shed top - %s, every %s (Ctrl-C to quit)\n\n", time.Now().Format("15:04:05"), topInterval)
+		}
+		os.Stdout.Write(buf.Bytes())
+
+		if topOnce {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(topInterval):
+		}
+	}
+}
+
+// topSource returns a function listing the sheds to show on each refresh:
+// the named shed, or the sheds on the selected server or all servers,
+// along with the servers that couldn't be reached.
+func topSource(args []string) (func() ([]shedWithServer, []serverError, error), error) {
+	if len(args) == 1 {
+		name := args[0]
+		serverName, entry, err := findShedServer(name)
+		if err != nil {
+			return nil, err
+		}
+		client := NewAPIClientFromEntry(entry)
+		return func() ([]shedWithServer, []serverError, error) {
+			shed, err := client.GetShed(name)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get shed: %w", err)
+			}
+			return []shedWithServer{{shed: *shed, server: serverName, entry: entry}}, nil, nil
+		}, nil
+	}
+
+	entry, serverName, err := getServerEntry()
+	if err != nil && !topAll {
+		printError("no server configured",
+			"shed server add <hostname>  # Add a server first",
+			"shed top --all              # Show sheds from all servers")
+		return nil, err
+	}
+	return func() ([]shedWithServer, []serverError, error) {
+		return collectSheds(topAll, entry, serverName)
+	}, nil
+}
+
+// renderTop writes the usage table for running sheds, busiest first.
+// Sheds whose stats couldn't be fetched are listed last.
+func renderTop(buf *bytes.Buffer, sheds []shedWithServer, stats []*config.ShedStats, notRunning int) {
+	if len(sheds) == 0 {
+		fmt.Fprintln(buf, "No running sheds.")
+		return
+	}
+
+	order := make([]int, len(sheds))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := stats[order[a]], stats[order[b]]
+		if sa == nil || sb == nil {
+			return sb == nil && sa != nil
+		}
+		return sa.CPUPercent > sb.CPUPercent
+	})
+
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	header := []string{"NAME"}
+	if topAll {
+		header = append(header, "SERVER")
+	}
+	header = append(header, "CPU", "MEM", "NET RX/TX", "BLOCK R/W", "PIDS")
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, i := range order {
+		row := []string{sheds[i].shed.Name}
+		if topAll {
+			row = append(row, sheds[i].server)
+		}
+		row = append(row, topColumns(stats[i])...)
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	if notRunning > 0 {
+		fmt.Fprintf(buf, "\n%d shed(s) not running\n", notRunning)
+	}
+}
+
+// topColumns returns the usage columns shown by top.
+func topColumns(stats *config.ShedStats) []string {
+	if stats == nil {
+		return []string{"-", "-", "-", "-", "-"}
+	}
+
+	mem := formatBytes(int64(stats.MemoryUsage))
+	if stats.MemoryLimit > 0 {
+		mem += " / " + formatBytes(int64(stats.MemoryLimit))
+	}
+
+	return []string{
+		fmt.Sprintf("%.1f%%", stats.CPUPercent),
+		mem,
+		formatBytes(int64(stats.NetworkRx)) + " / " + formatBytes(int64(stats.NetworkTx)),
+		formatBytes(int64(stats.BlockRead)) + " / " + formatBytes(int64(stats.BlockWrite)),
+		fmt.Sprintf("%d", stats.PIDs),
+	}
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse and manage sheds interactively",
+	Long: `Open a full-screen view of the sheds on every configured server, or on
+--server only, refreshed as their status changes.
+
+Keys:
+  up/down, j/k   Select a shed
+  enter          Open a console in the shed
+  a              Attach to a tmux session in the shed
+  s              Start or stop the shed
+  p              Pause or resume the shed
+  d              Delete the shed (asks first)
+  r              Refresh now
+  q, ctrl-c      Quit
+
+Changes made here are recorded in shed history like the equivalent commands.`,
+	Args: cobra.NoArgs,
+	RunE: runUI,
+}
+
+var uiInterval time.Duration
+
+func init() {
+	uiCmd.Flags().DurationVar(&uiInterval, "interval", 5*time.Second, "How often to refresh")
+
+	rootCmd.AddCommand(uiCmd)
+}
+
+// uiState is what the ui shows: the sheds, the selected one, and a status
+// line for action results and prompts.
+type uiState struct {
+	sheds   []shedWithServer
+	cursor  int
+	offset  int
+	message string
+	loaded  time.Time
+	loading bool
+
+	// failed are the servers that couldn't be reached on the last load
+	failed []serverError
+
+	// confirmDelete is set while asking whether to delete the selected shed
+	confirmDelete bool
+}
+
+// selected returns the shed under the cursor, if any.
+func (s *uiState) selected() (shedWithServer, bool) {
+	if s.cursor < 0 || s.cursor >= len(s.sheds) {
+		return shedWithServer{}, false
+	}
+	return s.sheds[s.cursor], true
+}
+
+// setSheds replaces the list, keeping the same shed selected if it is
+// still there.
+func (s *uiState) setSheds(sheds []shedWithServer) {
+	sort.Slice(sheds, func(i, j int) bool {
+		if sheds[i].shed.Name != sheds[j].shed.Name {
+			return sheds[i].shed.Name < sheds[j].shed.Name
+		}
+		return sheds[i].server < sheds[j].server
+	})
+
+	cur, ok := s.selected()
+	s.sheds = sheds
+	if ok {
+		for i, sh := range sheds {
+			if sh.shed.Name == cur.shed.Name && sh.server == cur.server {
+				s.cursor = i
+				return
+			}
+		}
+	}
+	s.move(0)
+}
+
+// move shifts the cursor by delta, staying within the list.
+func (s *uiState) move(delta int) {
+	s.cursor += delta
+	if s.cursor >= len(s.sheds) {
+		s.cursor = len(s.sheds) - 1
+	}
+	if s.cursor < 0 {
+		s.cursor = 0
+	}
+}
+
+// uiExit is how the ui ended: quitting, or leaving to connect to a shed.
+type uiExit struct {
+	shed   shedWithServer
+	attach bool
+}
+
+func runUI(cmd *cobra.Command, args []string) error {
+	if !isInteractive() || !isTerminalOutput() {
+		return fmt.Errorf("shed ui needs a terminal; use shed list instead")
+	}
+	if uiInterval < time.Second {
+		return fmt.Errorf("--interval must be at least 1s")
+	}
+	if len(clientConfig.Servers) == 0 {
+		printError("no server configured",
+			"shed server add <hostname>  # Add a server first")
+		return fmt.Errorf("no server configured")
+	}
+
+	var entry *config.ServerEntry
+	var serverName string
+	if serverFlag != "" {
+		var err error
+		if entry, serverName, err = getServerEntry(); err != nil {
+			return err
+		}
+	}
+
+	exit, err := runUILoop(entry, serverName)
+	if err != nil || exit == nil {
+		return err
+	}
+
+	// Leave the ui for ssh, which replaces this process
+	sh := exit.shed
+	if exit.attach {
+		return attachShedOn(sh.shed.Name, sh.server, sh.entry, "")
+	}
+	return sshToShedOn(sh.shed.Name, sh.server, sh.entry, nil)
+}
+
+// runUILoop shows the ui until the user quits or picks a shed to connect
+// to. The terminal is restored before it returns.
+func runUILoop(entry *config.ServerEntry, serverName string) (*uiExit, error) {
+	restore, err := enterRawMode()
+	if err != nil {
+		return nil, err
+	}
+	// Switch to the alternate screen and hide the cursor
+	fmt.Print("\033[?1049h\033[?25l")
+	defer func() {
+		fmt.Print("\033[?25h\033[?1049l")
+		restore()
+	}()
+
+	rows, cols := terminalSize()
+	resized, stopResize := watchResize()
+	defer stopResize()
+
+	// Stop reading keys before returning, so nothing is left reading stdin
+	// to steal input from an SSH session started next
+	keys := make(chan []byte)
+	stopKeys := make(chan struct{})
+	keysDone := make(chan struct{})
+	go func() {
+		readKeys(keys, stopKeys)
+		close(keysDone)
+	}()
+	defer func() {
+		close(stopKeys)
+		<-keysDone
+	}()
+
+	// Loads and actions run one at a time off the ui goroutine, so they
+	// don't block drawing or race on the client config
+	jobs := make(chan func() func(*uiState), 16)
+	updates := make(chan func(*uiState), 16)
+	go func() {
+		for job := range jobs {
+			updates <- job()
+		}
+	}()
+	defer close(jobs)
+
+	state := &uiState{}
+	load := func() {
+		if state.loading {
+			return
+		}
+		state.loading = true
+		jobs <- func() func(*uiState) {
+			sheds, failed, err := collectSheds(entry == nil, entry, serverName)
+			return func(s *uiState) {
+				s.loading = false
+				if err != nil {
+					s.message = "Error: " + err.Error()
+					return
+				}
+				s.setSheds(sheds)
+				s.failed = failed
+				s.loaded = time.Now()
+			}
+		}
+	}
+	act := func(sh shedWithServer, action string) {
+		state.message = uiActionLabels[action].doing + " " + sh.shed.Name + "..."
+		jobs <- func() func(*uiState) {
+			msg := runUIAction(sh, action)
+			return func(s *uiState) { s.message = msg }
+		}
+		load()
+	}
+
+	ticker := time.NewTicker(uiInterval)
+	defer ticker.Stop()
+
+	load()
+	for {
+		drawUI(state, rows, cols, entry == nil)
+
+		select {
+		case update := <-updates:
+			update(state)
+		case <-ticker.C:
+			load()
+		case <-resized:
+			rows, cols = terminalSize()
+		case key, ok := <-keys:
+			if !ok {
+				return nil, nil
+			}
+
+			if state.confirmDelete {
+				state.confirmDelete = false
+				state.message = ""
+				if sh, ok := state.selected(); ok && string(key) == "y" {
+					act(sh, config.BatchActionDelete)
+				}
+				continue
+			}
+
+			sh, hasShed := state.selected()
+			switch string(key) {
+			case "q", "\x03", "\x1b":
+				return nil, nil
+			case "j", "\x1b[B", "\x1bOB":
+				state.move(1)
+			case "k", "\x1b[A", "\x1bOA":
+				state.move(-1)
+			case "g", "\x1b[H":
+				state.cursor = 0
+			case "G", "\x1b[F":
+				state.move(len(state.sheds))
+			case "r":
+				load()
+			case "\r", "\n", "a":
+				if !hasShed {
+					continue
+				}
+				if sh.shed.Status != config.StatusRunning {
+					state.message = fmt.Sprintf("%s is %s; press s to start it", sh.shed.Name, sh.shed.Status)
+					continue
+				}
+				return &uiExit{shed: sh, attach: string(key) == "a"}, nil
+			case "s":
+				if !hasShed {
+					continue
+				}
+				switch sh.shed.Status {
+				case config.StatusRunning:
+					act(sh, config.BatchActionStop)
+				case config.StatusPaused:
+					state.message = sh.shed.Name + " is paused; press p to resume it"
+				default:
+					act(sh, config.BatchActionStart)
+				}
+			case "p":
+				if !hasShed {
+					continue
+				}
+				switch sh.shed.Status {
+				case config.StatusRunning:
+					act(sh, config.BatchActionPause)
+				case config.StatusPaused:
+					act(sh, config.BatchActionResume)
+				default:
+					state.message = sh.shed.Name + " is not running"
+				}
+			case "d":
+				if hasShed {
+					state.confirmDelete = true
+					state.message = fmt.Sprintf("Delete %s on %s and its workspace? (y/N)", sh.shed.Name, sh.server)
+				}
+			}
+		}
+	}
+}
+
+// uiActionLabels describes each action for the status line.
+var uiActionLabels = map[string]struct{ doing, done string }{
+	config.BatchActionStart:  {"Starting", "Started"},
+	config.BatchActionStop:   {"Stopping", "Stopped"},
+	config.BatchActionPause:  {"Pausing", "Paused"},
+	config.BatchActionResume: {"Resuming", "Resumed"},
+	config.BatchActionDelete: {"Deleting", "Deleted"},
+}
+
+// uiActionUndo maps each action to the one that reverses it.
+var uiActionUndo = map[string]string{
+	config.BatchActionStart:  config.BatchActionStop,
+	config.BatchActionStop:   config.BatchActionStart,
+	config.BatchActionPause:  config.BatchActionResume,
+	config.BatchActionResume: config.BatchActionPause,
+}
+
+// runUIAction applies an action to a shed, records it in history, and
+// returns a status line describing the result.
+func runUIAction(sh shedWithServer, action string) string {
+	name := sh.shed.Name
+	client := NewAPIClientFromEntry(sh.entry)
+
+	var shed *config.Shed
+	var err error
+	switch action {
+	case config.BatchActionStart:
+		shed, err = client.StartShed(name)
+	case config.BatchActionStop:
+		shed, err = client.StopShed(name)
+	case config.BatchActionPause:
+		shed, err = client.PauseShed(name)
+	case config.BatchActionResume:
+		shed, err = client.ResumeShed(name)
+	case config.BatchActionDelete:
+		err = client.DeleteShed(name, false)
+	}
+
+	undo := ""
+	if reverse := uiActionUndo[action]; reverse != "" {
+		undo = shedCommand(reverse, name, "--server", sh.server)
+	}
+	appendHistory(shedCommand(action, name, "--server", sh.server), name, sh.server, undo, err)
+
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	if shed != nil {
+		clientConfig.CacheShed(name, sh.server, shed.Status)
+	} else {
+		clientConfig.RemoveShedCache(name)
+	}
+	_ = clientConfig.Save()
+
+	return "✓ " + uiActionLabels[action].done + " " + name
+}
+
+// drawUI redraws the screen. Lines end in \r\n since the terminal is in
+// raw mode.
+func drawUI(s *uiState, rows, cols int, allServers bool) {
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSERVER\tSTATUS\tIMAGE\tCREATED")
+	for _, sh := range s.sheds {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", sh.shed.Name, sh.server, shedStatus(sh.shed),
+			sh.shed.Image, sh.shed.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+
+	var warnings bytes.Buffer
+	writeServerErrors(&warnings, "reach", s.failed)
+	warningLines := strings.Split(strings.TrimSuffix(warnings.String(), "\n"), "\n")
+	if warnings.Len() == 0 {
+		warningLines = nil
+	}
+
+	// Title, any warnings, blank, header, then rows, leaving a blank,
+	// status, and help line
+	visible := rows - 6 - len(warningLines)
+	if visible < 1 {
+		visible = 1
+	}
+	if s.cursor < s.offset {
+		s.offset = s.cursor
+	} else if s.cursor >= s.offset+visible {
+		s.offset = s.cursor - visible + 1
+	}
+
+	var buf bytes.Buffer
+	line := func(text string, highlight bool) {
+		text = truncateRunes(text, cols)
+		if highlight {
+			buf.WriteString("\033[7m" + text + "\033[0m")
+		} else {
+			buf.WriteString(text)
+		}
+		buf.WriteString("\033[K\r\n")
+	}
+
+	buf.WriteString("\033[H")
+	where := "all servers"
+	if !allServers && len(s.sheds) > 0 {
+		where = s.sheds[0].server
+	}
+	title := fmt.Sprintf("shed ui - %d sheds on %s", len(s.sheds), where)
+	switch {
+	case s.loaded.IsZero():
+		title += ", loading..."
+	default:
+		title += ", updated " + s.loaded.Format("15:04:05")
+	}
+	line(title, false)
+	for _, w := range warningLines {
+		line(w, false)
+	}
+	line("", false)
+	line("  "+lines[0], false)
+
+	if len(s.sheds) == 0 && !s.loaded.IsZero() {
+		line("  No sheds found. Create one with shed create <name>.", false)
+	}
+	for i := s.offset; i < len(s.sheds) && i < s.offset+visible; i++ {
+		prefix := "  "
+		if i == s.cursor {
+			prefix = "> "
+		}
+		line(prefix+lines[i+1], i == s.cursor)
+	}
+
+	buf.WriteString("\033[J")
+	buf.WriteString(fmt.Sprintf("\033[%d;1H", rows-1))
+	line(s.message, false)
+	line("enter console  a attach  s start/stop  p pause/resume  d delete  r refresh  q quit", false)
+
+	os.Stdout.Write(buf.Bytes())
+}
+
+// truncateRunes cuts text to at most n characters, never inside one, as
+// tabwriter counts them when lining up the table.
+func truncateRunes(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	for i := range text {
+		if n == 0 {
+			return text[:i]
+		}
+		n--
+	}
+	return text
+}
+
+// keyPollInterval is how often readKeys checks whether it should stop
+// while no key is pressed.
+const keyPollInterval = 100 * time.Millisecond
+
+// readKeys sends each chunk read from stdin to keys, so escape sequences
+// such as arrow keys arrive whole, until stop is closed. It only reads
+// once input is waiting, so it never blocks in a read after stop. It closes
+// keys when stdin ends.
+func readKeys(keys chan<- []byte, stop <-chan struct{}) {
+	buf := make([]byte, 32)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		ready, err := stdinReady(keyPollInterval)
+		if err != nil {
+			close(keys)
+			return
+		}
+		if !ready {
+			continue
+		}
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		key := make([]byte, n)
+		copy(key, buf[:n])
+		select {
+		case keys <- key:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// enterRawMode switches the terminal attached to stdin to raw mode,
+// returning a function that restores its previous settings.
+func enterRawMode() (func(), error) {
+	fd := int(os.Stdin.Fd())
+	saved, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	return func() { _ = term.Restore(fd, saved) }, nil
+}
+
+// terminalSize returns the terminal's rows and columns, or 24x80 if they
+// can't be read. It asks stdout, since on Windows only the console's
+// output handle knows its size.
+func terminalSize() (int, int) {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/selfupdate"
+	"github.com/charliek/shed/internal/version"
+)
+
+var (
+	updateChannel string
+	updateCheck   bool
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update shed to the latest release",
+	Long: `Download the latest shed release from GitHub and replace this binary.
+
+The download is checked against the release's checksums before the binary
+is swapped, and the swap is atomic. Use --channel prerelease to include
+release candidates. To update the server, run 'shed-server update' on it.`,
+	Args: cobra.NoArgs,
+	RunE: runUpdate,
+}
+
+func init() {
+	updateCmd.Flags().StringVar(&updateChannel, "channel", selfupdate.ChannelStable, "release channel: stable or prerelease")
+	updateCmd.Flags().BoolVar(&updateCheck, "check", false, "only report whether an update is available")
+	rootCmd.AddCommand(updateCmd)
+}
+
+func runUpdate(cmd *cobra.Command, args []string) error {
+	if err := selfupdate.ValidateChannel(updateChannel); err != nil {
+		return err
+	}
+
+	u := &selfupdate.Updater{Binary: "shed"}
+	ctx := context.Background()
+	release, err := u.Latest(ctx, updateChannel)
+	if err != nil {
+		return err
+	}
+
+	if !selfupdate.Newer(release, version.Version) {
+		fmt.Printf("shed %s is up to date (latest %s release is %s)\n", version.Version, updateChannel, release.Tag)
+		return nil
+	}
+	if updateCheck {
+		fmt.Printf("shed %s is available (current %s); run 'shed update' to install it\n", release.Tag, version.Version)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the shed binary: %w", err)
+	}
+
+	fmt.Printf("Downloading shed %s...\n", release.Tag)
+	data, err := u.Download(ctx, release)
+	if err != nil {
+		return err
+	}
+	if err := selfupdate.Replace(exe, data); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("%w (try again with sudo)", err)
+		}
+		return err
+	}
+
+	printSuccess("Updated shed %s -> %s", version.Version, release.Tag)
+	return nil
+}
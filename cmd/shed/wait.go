@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait <name>",
+	Short: "Wait for a shed to reach a status",
+	Long: `Block until a shed reaches the given status, then exit.
+
+The server watches the shed's container for changes, so there is no polling
+delay. Exits with an error if the timeout passes first, which makes it useful
+in scripts:
+
+  shed start dev && shed wait dev --status running --timeout 2m`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWait,
+}
+
+var (
+	waitStatus  string
+	waitTimeout time.Duration
+)
+
+func init() {
+	waitCmd.Flags().StringVar(&waitStatus, "status", config.StatusRunning, "Status to wait for (running, stopped, paused)")
+	waitCmd.Flags().DurationVar(&waitTimeout, "timeout", config.DefaultWaitTimeout, "Maximum time to wait")
+
+	rootCmd.AddCommand(waitCmd)
+}
+
+func runWait(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if waitTimeout <= 0 || waitTimeout > config.MaxWaitTimeout {
+		return fmt.Errorf("--timeout must be positive and at most %s", config.MaxWaitTimeout)
+	}
+
+	serverName, entry, err := findShedServer(name)
+	if err != nil {
+		return err
+	}
+
+	if verboseFlag {
+		fmt.Printf("Waiting for shed %s on %s to be %s...\n", name, serverName, waitStatus)
+	}
+
+	client := NewAPIClientFromEntry(entry)
+	shed, err := client.WaitForShed(name, waitStatus, waitTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to wait for shed: %w", err)
+	}
+
+	printSuccess("Shed %s is %s", name, shed.Status)
+	return nil
+}
+
+// waitForRunning blocks until each shed is running, for start and create
+// --wait. A shed the server reports as already running returns at once.
+func waitForRunning(names []string) error {
+	for _, name := range names {
+		_, entry, err := findShedServer(name)
+		if err != nil {
+			return err
+		}
+		if _, err := NewAPIClientFromEntry(entry).WaitForShed(name, config.StatusRunning, config.DefaultWaitTimeout); err != nil {
+			return fmt.Errorf("failed to wait for shed %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package activity tracks when each shed was last used over SSH, so idle
+// sheds can be stopped.
+package activity
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracker records the last activity for each shed. A shed with an open
+// session counts as active until the session ends. Activity is kept in
+// memory; after a restart every shed is treated as active at startup.
+type Tracker struct {
+	mu      sync.Mutex
+	started time.Time
+	last    map[string]time.Time
+	open    map[string]int
+	now     func() time.Time
+	hook    func(name string, opened bool)
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		started: time.Now(),
+		last:    make(map[string]time.Time),
+		open:    make(map[string]int),
+		now:     time.Now,
+	}
+}
+
+// Begin marks a shed as in use until the returned function is called.
+// It is safe to call on a nil Tracker.
+func (t *Tracker) Begin(name string) (end func()) {
+	if t == nil {
+		return func() {}
+	}
+
+	t.mu.Lock()
+	t.open[name]++
+	t.last[name] = t.now()
+	hook := t.hook
+	t.mu.Unlock()
+	if hook != nil {
+		hook(name, true)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			t.open[name]--
+			if t.open[name] <= 0 {
+				delete(t.open, name)
+			}
+			t.last[name] = t.now()
+			t.mu.Unlock()
+			if hook != nil {
+				hook(name, false)
+			}
+		})
+	}
+}
+
+// OnSession sets a function to call whenever a session to a shed opens or
+// closes.
+func (t *Tracker) OnSession(fn func(name string, opened bool)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hook = fn
+}
+
+// Touch records activity for a shed. It is safe to call on a nil Tracker.
+func (t *Tracker) Touch(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last[name] = t.now()
+}
+
+// LastActive returns when a shed was last used: now if it has an open
+// session, otherwise the end of its last session, or when the tracker was
+// created if it hasn't been used since.
+func (t *Tracker) LastActive(name string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.open[name] > 0 {
+		return t.now()
+	}
+	if last, ok := t.last[name]; ok {
+		return last
+	}
+	return t.started
+}
+
+// Connections returns how many sessions to a shed are open. It is safe to
+// call on a nil Tracker.
+func (t *Tracker) Connections(name string) int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.open[name]
+}
+
+// LastConnection returns when a shed was last used: now if it has an open
+// session, otherwise the end of its last session, or zero if it hasn't been
+// used since the tracker was created. It is safe to call on a nil Tracker.
+func (t *Tracker) LastConnection(name string) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.open[name] > 0 {
+		return t.now()
+	}
+	return t.last[name]
+}
@@ -0,0 +1,91 @@
+package activity
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	now := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	tr := NewTracker()
+	tr.started = now
+	tr.now = func() time.Time { return now }
+
+	if got := tr.LastActive("widget"); !got.Equal(now) {
+		t.Errorf("LastActive() before use = %v, want tracker start", got)
+	}
+
+	end := tr.Begin("widget")
+	now = now.Add(time.Hour)
+	if got := tr.LastActive("widget"); !got.Equal(now) {
+		t.Errorf("LastActive() during session = %v, want now", got)
+	}
+
+	end()
+	end() // Ending twice must not miscount
+	sessionEnd := now
+	now = now.Add(time.Hour)
+	if got := tr.LastActive("widget"); !got.Equal(sessionEnd) {
+		t.Errorf("LastActive() after session = %v, want %v", got, sessionEnd)
+	}
+
+	tr.Touch("widget")
+	if got := tr.LastActive("widget"); !got.Equal(now) {
+		t.Errorf("LastActive() after Touch = %v, want now", got)
+	}
+
+	var nilTracker *Tracker
+	nilTracker.Begin("widget")()
+	nilTracker.Touch("widget")
+}
+
+func TestTrackerConnections(t *testing.T) {
+	now := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	tr := NewTracker()
+	tr.now = func() time.Time { return now }
+
+	if got := tr.LastConnection("widget"); !got.IsZero() {
+		t.Errorf("LastConnection() before use = %v, want zero", got)
+	}
+
+	end1 := tr.Begin("widget")
+	end2 := tr.Begin("widget")
+	if got := tr.Connections("widget"); got != 2 {
+		t.Errorf("Connections() = %d, want 2", got)
+	}
+
+	end1()
+	end2()
+	sessionEnd := now
+	now = now.Add(time.Hour)
+	if got := tr.Connections("widget"); got != 0 {
+		t.Errorf("Connections() after sessions end = %d, want 0", got)
+	}
+	if got := tr.LastConnection("widget"); !got.Equal(sessionEnd) {
+		t.Errorf("LastConnection() = %v, want %v", got, sessionEnd)
+	}
+
+	var nilTracker *Tracker
+	if nilTracker.Connections("widget") != 0 || !nilTracker.LastConnection("widget").IsZero() {
+		t.Error("nil tracker should report no connections")
+	}
+}
+
+func TestTrackerOnSession(t *testing.T) {
+	tr := NewTracker()
+	var got []string
+	tr.OnSession(func(name string, opened bool) {
+		got = append(got, fmt.Sprintf("%s:%v", name, opened))
+	})
+
+	end := tr.Begin("widget")
+	end()
+	end()
+
+	want := []string{"widget:true", "widget:false"}
+	if !slices.Equal(got, want) {
+		t.Errorf("sessions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/version"
+	"github.com/charliek/shed/internal/websocket"
+)
+
+// defaultAuditLimit is the number of entries GET /api/audit returns when no
+// limit is given.
+const defaultAuditLimit = 100
+
+// auditActions names the audited API routes by method and route pattern.
+// Requests that change something are audited even when they aren't listed
+// here; GET requests are only audited if they are.
+var auditActions = map[string]string{
+	"POST /sheds":                                   "create",
+	"POST /sheds/import":                            "import",
+	"DELETE /sheds/{name}":                          "delete",
+	"POST /sheds/{name}/start":                      "start",
+	"POST /sheds/{name}/stop":                       "stop",
+	"POST /sheds/{name}/pause":                      "pause",
+	"POST /sheds/{name}/resume":                     "resume",
+	"POST /sheds/{name}/rebuild":                    "rebuild",
+	"POST /sheds/{name}/export":                     "export",
+	"POST /sheds/{name}/clone":                      "clone",
+	"PATCH /sheds/{name}/labels":                    "label",
+	"PATCH /sheds/{name}/env":                       "env",
+	"POST /sheds/{name}/checkpoints":                "checkpoint",
+	"DELETE /sheds/{name}/checkpoints/{checkpoint}": "delete-checkpoint",
+	"POST /sheds/{name}/backups":                    "backup",
+	"POST /sheds/{name}/restore":                    "restore",
+	"PUT /sheds/{name}/schedules/{action}":          "schedule",
+	"DELETE /sheds/{name}/schedules/{action}":       "unschedule",
+	"POST /sheds/{name}/exec":                       "exec",
+	"POST /sheds/{name}/sessions":                   "create-session",
+	"PATCH /sheds/{name}/sessions/{session}":        "rename-session",
+	"DELETE /sheds/{name}/sessions/{session}":       "kill-session",
+	"POST /sheds/{name}/sessions/{session}/exec":    "session-exec",
+	"GET /sheds/{name}/terminal":                    "console",
+	"POST /batch":                                   "batch",
+	"POST /prune":                                   "prune",
+	"POST /images/build":                            "build-image",
+	"POST /deploy-keys":                             "create-deploy-key",
+	"DELETE /deploy-keys/{name}":                    "delete-deploy-key",
+	"GET /secrets/{name}":                           "get-secret",
+	"PUT /secrets/{name}":                           "set-secret",
+	"DELETE /secrets/{name}":                        "delete-secret",
+	"POST /keys":                                    "add-key",
+	"DELETE /keys/{name}":                           "remove-key",
+	"PUT /server/log-level":                         "set-log-level",
+}
+
+// auditEntryKey is the context key for the audit entry of a request.
+type auditEntryKey struct{}
+
+// auditRequests is middleware that records requests that change something,
+// and terminal connections, in the audit log once they complete.
+func (s *Server) auditRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := &config.AuditEntry{
+			Time:   time.Now().UTC(),
+			Via:    config.AuditViaAPI,
+			Actor:  tokenName(r.Context()),
+			Remote: r.RemoteAddr,
+		}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), auditEntryKey{}, entry)))
+
+		route := auditRoute(chi.RouteContext(r.Context()).RoutePattern())
+		action, ok := auditActions[r.Method+" "+route]
+		if !ok {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				return
+			}
+			action = r.Method + " " + route
+		}
+
+		entry.Action = action
+		if entry.Shed == "" && strings.HasPrefix(route, "/sheds/{name}") {
+			entry.Shed = chi.URLParam(r, "name")
+		}
+		entry.Status = ww.Status()
+		if entry.Status == 0 && websocket.IsUpgrade(r) {
+			// The connection was hijacked, so the 101 wasn't seen
+			entry.Status = http.StatusSwitchingProtocols
+		}
+		s.audit.Record(*entry)
+	})
+}
+
+// auditRoute strips the API prefix and any trailing slash from a route
+// pattern.
+func auditRoute(pattern string) string {
+	route, ok := strings.CutPrefix(pattern, "/api/"+version.APIVersion)
+	if !ok {
+		route = strings.TrimPrefix(pattern, "/api")
+	}
+	if len(route) > 1 {
+		route = strings.TrimSuffix(route, "/")
+	}
+	return route
+}
+
+// auditEntry returns the audit entry of a request so a handler can add to
+// it, or a throwaway entry if the request isn't being audited.
+func auditEntry(r *http.Request) *config.AuditEntry {
+	if entry, ok := r.Context().Value(auditEntryKey{}).(*config.AuditEntry); ok {
+		return entry
+	}
+	return &config.AuditEntry{}
+}
+
+// handleGetAudit returns the most recent audit log entries, oldest first,
+// optionally filtered by shed, actor, or action.
+// GET /api/audit?limit=...&shed=...&actor=...&action=...
+func (s *Server) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultAuditLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	shed, actor, action := query.Get("shed"), query.Get("actor"), query.Get("action")
+	entries, err := s.audit.Tail(limit, func(e config.AuditEntry) bool {
+		return (shed == "" || e.Shed == shed) &&
+			(actor == "" || e.Actor == actor) &&
+			(action == "" || e.Action == action)
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config.AuditResponse{Entries: entries})
+}
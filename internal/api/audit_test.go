@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestAuditRequests(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.APITokens = []config.APIToken{{Name: "laptop", Token: "s3cret"}}
+	srv := NewServer(newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning}), cfg, nil)
+
+	requests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/api/v1/sheds", `{"name":"widget"}`},
+		{http.MethodGet, "/api/sheds", ""},
+		{http.MethodGet, "/api/v1/sheds/dev", ""},
+		{http.MethodPost, "/api/sheds/dev/stop", ""},
+		{http.MethodDelete, "/api/v1/sheds/dev", ""},
+		{http.MethodPost, "/api/batch", `{"operations":[{"action":"start","name":"dev"}]}`},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		req.RemoteAddr = "10.0.0.5:4321"
+		srv.Router().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	entries, err := srv.audit.Tail(0, nil)
+	if err != nil {
+		t.Fatalf("Tail() error: %v", err)
+	}
+
+	want := []struct {
+		action, shed, detail string
+		status               int
+	}{
+		{"create", "widget", "", http.StatusCreated},
+		{"stop", "dev", "", http.StatusOK},
+		{"delete", "dev", "", http.StatusNoContent},
+		{"batch", "", "start dev", http.StatusOK},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Action != w.action || e.Shed != w.shed || e.Detail != w.detail || e.Status != w.status {
+			t.Errorf("entry %d = %s %q %q %d, want %s %q %q %d",
+				i, e.Action, e.Shed, e.Detail, e.Status, w.action, w.shed, w.detail, w.status)
+		}
+		if e.Via != config.AuditViaAPI || e.Actor != "laptop" || e.Remote != "10.0.0.5:4321" {
+			t.Errorf("entry %d via %q actor %q remote %q, want api laptop 10.0.0.5:4321", i, e.Via, e.Actor, e.Remote)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("entry %d has no time", i)
+		}
+	}
+}
+
+func TestHandleGetAudit(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+	for _, e := range []config.AuditEntry{
+		{Via: config.AuditViaAPI, Actor: "laptop", Action: "create", Shed: "dev"},
+		{Via: config.AuditViaSSH, Actor: "ci", Action: "exec", Shed: "dev", Detail: "make test"},
+		{Via: config.AuditViaAPI, Actor: "laptop", Action: "delete", Shed: "web"},
+	} {
+		srv.audit.Record(e)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantCode    int
+		wantActions []string
+	}{
+		{"all", "", http.StatusOK, []string{"create", "exec", "delete"}},
+		{"limit", "?limit=2", http.StatusOK, []string{"exec", "delete"}},
+		{"by shed", "?shed=dev", http.StatusOK, []string{"create", "exec"}},
+		{"by actor", "?actor=laptop&limit=1", http.StatusOK, []string{"delete"}},
+		{"by action", "?action=exec", http.StatusOK, []string{"exec"}},
+		{"bad limit", "?limit=0", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/audit"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp config.AuditResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var got []string
+			for _, e := range resp.Entries {
+				got = append(got, e.Action)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantActions, ",") {
+				t.Errorf("actions = %v, want %v", got, tt.wantActions)
+			}
+		})
+	}
+}
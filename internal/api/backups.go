@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleListBackups returns a shed's backups, newest first.
+// GET /api/sheds/{name}/backups
+func (s *Server) handleListBackups(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	backups, err := s.docker.ListBackups(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config.BackupsResponse{Backups: backups})
+}
+
+// handleCreateBackup backs up a shed's workspace now, waiting for the
+// upload to finish.
+// POST /api/sheds/{name}/backups
+func (s *Server) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	backup, err := s.docker.BackupShed(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, backup)
+}
+
+// handleRestoreBackup replaces a shed's workspace with one of its backups,
+// restarting the shed.
+// POST /api/sheds/{name}/restore
+func (s *Server) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req config.RestoreBackupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var errs config.ValidationErrors
+	if req.Backup == "" {
+		errs.Add("backup", config.FieldRequired, "backup is required")
+	} else {
+		errs.Check("backup", config.ValidateBackupName(req.Backup))
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	auditEntry(r).Detail = "from " + req.Backup
+
+	shed, err := s.docker.RestoreBackup(r.Context(), name, req.Backup)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shed)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestBackups(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds/dev/backups", nil)
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/sheds/dev/backups", nil)
+	rec = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+	var resp config.BackupsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Backups) != 1 || resp.Backups[0].Shed != "dev" {
+		t.Errorf("backups = %+v, want one backup of dev", resp.Backups)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/sheds/nope/backups", nil)
+	rec = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("create for missing shed: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusStopped})
+	docker.backups = map[string][]config.Backup{"dev": {{Shed: "dev", Name: "20260102-030405"}}}
+	srv := NewServer(docker, testConfig(t), nil)
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"backup": "20250101-000000"}`, http.StatusNotFound},
+		{`{"backup": "../other/20260102-030405"}`, http.StatusBadRequest},
+		{`{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/sheds/dev/restore", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("restore %s: status = %d, want %d: %s", tt.body, rec.Code, tt.status, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds/dev/restore", strings.NewReader(`{"backup": "20260102-030405"}`))
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var shed config.Shed
+	if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if shed.Status != config.StatusRunning {
+		t.Errorf("restored shed status = %q, want running", shed.Status)
+	}
+}
+
+func TestMapBackupErrors(t *testing.T) {
+	tests := []struct {
+		err    string
+		status int
+		code   string
+	}{
+		{"backups are not supported on this server: no backups bucket is configured", http.StatusNotImplemented, config.ErrNotSupported},
+		{"backup failed: object storage: AccessDenied: Access Denied", http.StatusBadGateway, config.ErrBackupFailed},
+		{`shed "dev" is already being backed up`, http.StatusConflict, config.ErrBackupInProgress},
+		{`backup "20260102-030405" not found for shed "dev"`, http.StatusNotFound, config.ErrBackupNotFound},
+	}
+	for _, tt := range tests {
+		status, code, _ := mapDockerError(errors.New(tt.err))
+		if status != tt.status || code != tt.code {
+			t.Errorf("mapDockerError(%q) = %d %s, want %d %s", tt.err, status, code, tt.status, tt.code)
+		}
+	}
+}
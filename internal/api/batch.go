@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// batchConcurrency is the maximum number of batch operations run at once.
+const batchConcurrency = 4
+
+// handleBatch runs start/stop/pause/resume/delete operations on multiple sheds.
+// POST /api/batch
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var req config.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	if len(req.Operations) == 0 {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "at least one operation is required")
+		return
+	}
+	if len(req.Operations) > config.MaxBatchOperations {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest,
+			fmt.Sprintf("batch cannot exceed %d operations", config.MaxBatchOperations))
+		return
+	}
+
+	ops := make([]string, len(req.Operations))
+	for i, op := range req.Operations {
+		ops[i] = op.Action + " " + op.Name
+	}
+	auditEntry(r).Detail = strings.Join(ops, ", ")
+
+	results := make([]config.BatchResult, len(req.Operations))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+
+	for i, op := range req.Operations {
+		wg.Add(1)
+		go func(i int, op config.BatchOperation) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = s.runBatchOperation(r.Context(), op)
+		}(i, op)
+	}
+
+	wg.Wait()
+
+	resp := config.BatchResponse{
+		Results: results,
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// runBatchOperation executes a single batch operation and records its outcome
+// using the same status and error codes as the equivalent single-shed endpoint.
+func (s *Server) runBatchOperation(ctx context.Context, op config.BatchOperation) config.BatchResult {
+	result := config.BatchResult{
+		Action: op.Action,
+		Name:   op.Name,
+	}
+
+	if err := config.ValidateShedName(op.Name); err != nil {
+		result.Status = http.StatusBadRequest
+		result.Error = &config.APIErrorDetail{Code: config.ErrInvalidShedName, Message: err.Error()}
+		return result
+	}
+
+	var shed *config.Shed
+	var err error
+	status := http.StatusOK
+
+	switch op.Action {
+	case config.BatchActionStart:
+		shed, err = s.docker.StartShed(ctx, op.Name)
+	case config.BatchActionStop:
+		shed, err = s.docker.StopShed(ctx, op.Name)
+	case config.BatchActionPause:
+		shed, err = s.docker.PauseShed(ctx, op.Name)
+	case config.BatchActionResume:
+		shed, err = s.docker.ResumeShed(ctx, op.Name)
+	case config.BatchActionDelete:
+		err = s.docker.DeleteShed(ctx, op.Name, op.KeepVolume)
+		status = http.StatusNoContent
+	default:
+		result.Status = http.StatusBadRequest
+		result.Error = &config.APIErrorDetail{
+			Code:    config.ErrInvalidRequest,
+			Message: fmt.Sprintf("unknown action %q: must be start, stop, pause, resume, or delete", op.Action),
+		}
+		return result
+	}
+
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		result.Status = code
+		result.Error = &config.APIErrorDetail{Code: errCode, Message: msg}
+		return result
+	}
+
+	result.Status = status
+	result.Shed = shed
+	return result
+}
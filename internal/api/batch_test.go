@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleBatch(t *testing.T) {
+	docker := newFakeDocker(
+		config.Shed{Name: "stopped", Status: config.StatusStopped},
+		config.Shed{Name: "running", Status: config.StatusRunning},
+	)
+	srv := NewServer(docker, testConfig(t), nil)
+
+	body, _ := json.Marshal(config.BatchRequest{Operations: []config.BatchOperation{
+		{Action: config.BatchActionStart, Name: "stopped"},
+		{Action: config.BatchActionStart, Name: "running"},
+		{Action: config.BatchActionStop, Name: "missing"},
+		{Action: "restart", Name: "running"},
+	}})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp config.BatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	want := []struct {
+		status int
+		code   string
+	}{
+		{http.StatusOK, ""},
+		{http.StatusConflict, config.ErrShedAlreadyRunning},
+		{http.StatusNotFound, config.ErrShedNotFound},
+		{http.StatusBadRequest, config.ErrInvalidRequest},
+	}
+
+	if len(resp.Results) != len(want) {
+		t.Fatalf("len(results) = %d, want %d", len(resp.Results), len(want))
+	}
+	for i, w := range want {
+		got := resp.Results[i]
+		if got.Status != w.status {
+			t.Errorf("results[%d].Status = %d, want %d", i, got.Status, w.status)
+		}
+		code := ""
+		if got.Error != nil {
+			code = got.Error.Code
+		}
+		if code != w.code {
+			t.Errorf("results[%d] error code = %q, want %q", i, code, w.code)
+		}
+	}
+}
+
+func TestHandleBatchEmpty(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", bytes.NewReader([]byte(`{"operations":[]}`)))
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleListCheckpoints returns the checkpoints saved for a shed.
+// GET /api/sheds/{name}/checkpoints
+func (s *Server) handleListCheckpoints(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	checkpoints, err := s.docker.ListCheckpoints(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	resp := config.CheckpointsResponse{
+		Checkpoints: checkpoints,
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleCreateCheckpoint saves a running shed's process state.
+// POST /api/sheds/{name}/checkpoints
+func (s *Server) handleCreateCheckpoint(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	// The body is optional; an empty one takes the defaults
+	var req config.CreateCheckpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	if req.Name != "" {
+		var errs config.ValidationErrors
+		errs.Check("name", config.ValidateCheckpointName(req.Name))
+		if len(errs) > 0 {
+			writeValidationError(w, errs)
+			return
+		}
+	}
+
+	cp, err := s.docker.CreateCheckpoint(r.Context(), name, req.Name, req.LeaveRunning)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, cp)
+}
+
+// handleDeleteCheckpoint removes a saved checkpoint.
+// DELETE /api/sheds/{name}/checkpoints/{checkpoint}
+func (s *Server) handleDeleteCheckpoint(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	checkpoint := chi.URLParam(r, "checkpoint")
+
+	if err := s.docker.DeleteCheckpoint(r.Context(), name, checkpoint); err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestCheckpointErrors(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"create unsupported", http.MethodPost, "/api/sheds/dev/checkpoints", "", http.StatusNotImplemented, config.ErrNotSupported},
+		{"create invalid name", http.MethodPost, "/api/sheds/dev/checkpoints", `{"name":"Bad_Name"}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"delete missing", http.MethodDelete, "/api/sheds/dev/checkpoints/cp1", "", http.StatusNotFound, config.ErrCheckpointNotFound},
+		{"restore unsupported", http.MethodPost, "/api/sheds/dev/start?checkpoint=cp1", "", http.StatusNotImplemented, config.ErrNotSupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			var resp config.APIError
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error.Code != tt.code {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.code)
+			}
+		})
+	}
+}
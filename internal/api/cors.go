@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// corsMaxAge is how long browsers may cache a preflight response.
+const corsMaxAge = 10 * time.Minute
+
+// CORS headers sent to allowed origins.
+var (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE"
+	corsAllowHeaders  = strings.Join([]string{"Authorization", "Content-Type", "Accept", config.HeaderClientVersion, config.HeaderIdempotencyKey}, ", ")
+	corsExposeHeaders = strings.Join([]string{"Retry-After", config.HeaderAPIVersion, config.HeaderMinClientVersion}, ", ")
+)
+
+// corsPolicy decides which browser origins may call the API.
+type corsPolicy struct {
+	// any is set by "*", which allows every origin without credentials
+	any     bool
+	origins map[string]bool
+}
+
+// newCORSPolicy returns the policy for the configured origins, or nil if
+// none are configured and only same-origin requests are allowed.
+func newCORSPolicy(origins []string) *corsPolicy {
+	if len(origins) == 0 {
+		return nil
+	}
+	p := &corsPolicy{origins: make(map[string]bool)}
+	for _, origin := range origins {
+		if origin == "*" {
+			p.any = true
+			continue
+		}
+		p.origins[normalizeOrigin(origin)] = true
+	}
+	return p
+}
+
+// listed reports whether origin is configured by name. Only listed origins
+// get credentials or may open terminals; "*" doesn't count.
+func (p *corsPolicy) listed(origin string) bool {
+	return p != nil && origin != "" && p.origins[normalizeOrigin(origin)]
+}
+
+// handler is middleware that adds CORS headers for allowed origins and
+// answers their preflight requests, which carry no API token.
+func (p *corsPolicy) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		switch {
+		case p.listed(origin):
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		case origin != "" && p.any:
+			h.Set("Access-Control-Allow-Origin", "*")
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// normalizeOrigin lowercases an origin and drops any trailing slash, as
+// browsers send it.
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(origin), "/")
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.APITokens = []config.APIToken{{Name: "laptop", Token: "s3cret"}}
+	cfg.CORSAllowedOrigins = []string{"https://shed.example.com"}
+	router := NewServer(newFakeDocker(), cfg, nil).Router()
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		preflight  bool
+		wantCode   int
+		wantOrigin string
+		wantCreds  bool
+	}{
+		{"preflight from allowed origin", http.MethodOptions, "https://shed.example.com", true, http.StatusNoContent, "https://shed.example.com", true},
+		{"request from allowed origin", http.MethodGet, "https://shed.example.com", false, http.StatusOK, "https://shed.example.com", true},
+		{"origin case differs", http.MethodGet, "https://SHED.example.com", false, http.StatusOK, "https://SHED.example.com", true},
+		{"request from other origin", http.MethodGet, "https://evil.example.com", false, http.StatusOK, "", false},
+		{"preflight from other origin", http.MethodOptions, "https://evil.example.com", true, http.StatusUnauthorized, "", false},
+		{"no origin", http.MethodGet, "", false, http.StatusOK, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/sheds", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				req.Header.Set("Access-Control-Request-Headers", "authorization")
+			} else {
+				req.Header.Set("Authorization", "Bearer s3cret")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCreds {
+				t.Errorf("Allow-Credentials = %v, want %v", got, tt.wantCreds)
+			}
+			if tt.preflight && tt.wantCode == http.StatusNoContent && rec.Header().Get("Access-Control-Allow-Headers") == "" {
+				t.Error("preflight response has no Allow-Headers")
+			}
+		})
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.CORSAllowedOrigins = []string{"*"}
+	router := NewServer(newFakeDocker(), cfg, nil).Router()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sheds", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Allow-Credentials = %q, want none for *", got)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// dashboardFiles holds the web UI served at /ui/. It is a static page that
+// drives the same /api endpoints as the CLI, so it needs no handlers of its
+// own and is subject to the same token checks.
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// mountDashboard serves the web UI at /ui/, redirecting / there.
+func mountDashboard(r chi.Router) {
+	r.Handle("/", http.RedirectHandler("/ui/", http.StatusFound))
+	r.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+	r.Handle("/ui/*", dashboardHandler())
+}
+
+// dashboardHandler serves the embedded web UI.
+func dashboardHandler() http.Handler {
+	files, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.StripPrefix("/ui", http.FileServer(http.FS(files)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Let the file server pick the type instead of the JSON default
+		w.Header().Del("Content-Type")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'")
+		w.Header().Set("X-Frame-Options", "DENY")
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,237 @@
+// Shed web dashboard. Everything here goes through the public /api/v1
+// endpoints, authenticated with a token kept in this browser's storage.
+"use strict";
+
+const tokenKey = "shed-api-token";
+const refreshInterval = 5000;
+
+const $ = (id) => document.getElementById(id);
+let refreshTimer = null;
+
+const actionLabels = {
+  start: "Starting",
+  stop: "Stopping",
+  resume: "Resuming",
+  delete: "Deleting",
+};
+
+async function api(method, path, body) {
+  const headers = { Accept: "application/json" };
+  const token = localStorage.getItem(tokenKey);
+  if (token) {
+    headers.Authorization = "Bearer " + token;
+  }
+  if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+  }
+
+  const resp = await fetch("/api/v1" + path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (resp.status === 401) {
+    showLogin(token ? "That token was not accepted." : "");
+    throw new Error("unauthorized");
+  }
+  if (resp.status === 204) {
+    return null;
+  }
+  const data = await resp.json().catch(() => null);
+  if (!resp.ok) {
+    throw new Error((data && data.error && data.error.message) || resp.statusText);
+  }
+  return data;
+}
+
+function showMessage(text, isError) {
+  const el = $("message");
+  el.textContent = text;
+  el.className = isError ? "error" : "";
+}
+
+function showLogin(text) {
+  clearInterval(refreshTimer);
+  refreshTimer = null;
+  $("dashboard").hidden = true;
+  $("sign-out").hidden = true;
+  $("login").hidden = false;
+  showMessage(text, true);
+}
+
+function formatBytes(n) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return n.toFixed(i === 0 ? 0 : 1) + " " + units[i];
+}
+
+function formatAgo(iso) {
+  const seconds = Math.max(0, (Date.now() - new Date(iso).getTime()) / 1000);
+  if (seconds < 60) return "just now";
+  if (seconds < 3600) return Math.floor(seconds / 60) + "m ago";
+  if (seconds < 86400) return Math.floor(seconds / 3600) + "h ago";
+  return Math.floor(seconds / 86400) + "d ago";
+}
+
+function cell(text, className) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  if (className) td.className = className;
+  return td;
+}
+
+function button(label, onClick) {
+  const b = document.createElement("button");
+  b.textContent = label;
+  b.addEventListener("click", onClick);
+  return b;
+}
+
+async function act(name, action) {
+  if (action === "delete" &&
+      !confirm("Delete " + name + " and its workspace? This cannot be undone.")) {
+    return;
+  }
+  showMessage(actionLabels[action] + " " + name + "...");
+  try {
+    if (action === "delete") {
+      await api("DELETE", "/sheds/" + encodeURIComponent(name));
+    } else {
+      await api("POST", "/sheds/" + encodeURIComponent(name) + "/" + action);
+    }
+    showMessage("");
+  } catch (err) {
+    if (err.message !== "unauthorized") showMessage(err.message, true);
+  }
+  refresh();
+}
+
+function renderShed(shed, stats) {
+  const tr = document.createElement("tr");
+  tr.append(
+    cell(shed.name),
+    cell(shed.status, "status status-" + shed.status),
+    cell(shed.repo || "-"),
+    cell(shed.image || "-"),
+    cell(stats ? stats.cpu_percent.toFixed(1) + "%" : "-", "muted"),
+    cell(stats ? formatBytes(stats.memory_usage) : "-", "muted"),
+    cell(stats ? String(stats.sessions) : "-", "muted"),
+    cell(formatAgo(shed.created_at)),
+  );
+  if (shed.setup_error) {
+    tr.title = "Setup error: " + shed.setup_error;
+  }
+
+  const actions = cell("", "actions");
+  if (shed.status === "running") {
+    actions.append(button("Stop", () => act(shed.name, "stop")));
+  } else if (shed.status === "paused") {
+    actions.append(button("Resume", () => act(shed.name, "resume")));
+  } else {
+    actions.append(button("Start", () => act(shed.name, "start")));
+  }
+  actions.append(button("Delete", () => act(shed.name, "delete")));
+  tr.append(actions);
+  return tr;
+}
+
+async function refresh() {
+  let sheds;
+  try {
+    sheds = (await api("GET", "/sheds")).sheds || [];
+  } catch (err) {
+    if (err.message !== "unauthorized") showMessage(err.message, true);
+    return;
+  }
+  sheds.sort((a, b) => a.name.localeCompare(b.name));
+
+  // Stats only exist for running sheds; a failure just leaves the row blank
+  const stats = await Promise.all(sheds.map((shed) =>
+    shed.status === "running"
+      ? api("GET", "/sheds/" + encodeURIComponent(shed.name) + "/stats").catch(() => null)
+      : null));
+
+  $("sheds").replaceChildren(...sheds.map((shed, i) => renderShed(shed, stats[i])));
+  $("empty").hidden = sheds.length > 0;
+}
+
+async function loadImages() {
+  const select = document.querySelector("#create-form select[name=image]");
+  try {
+    const images = (await api("GET", "/images")).images || [];
+    select.replaceChildren(select.options[0], ...images.map((image) => {
+      const option = document.createElement("option");
+      option.value = image.default ? "" : image.name;
+      option.textContent = image.name + (image.default ? " (default)" : "");
+      return option;
+    }).filter((option) => option.value !== ""));
+  } catch (err) {
+    // The default image is still offered
+  }
+}
+
+async function start() {
+  try {
+    const info = await api("GET", "/info");
+    $("server-name").textContent = info.name;
+    $("server-version").textContent = info.version;
+  } catch (err) {
+    if (err.message !== "unauthorized") showMessage(err.message, true);
+    return;
+  }
+
+  $("login").hidden = true;
+  $("dashboard").hidden = false;
+  $("sign-out").hidden = !localStorage.getItem(tokenKey);
+  showMessage("");
+
+  loadImages();
+  await refresh();
+  if (!refreshTimer) {
+    refreshTimer = setInterval(refresh, refreshInterval);
+  }
+}
+
+$("login-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  localStorage.setItem(tokenKey, $("token").value.trim());
+  $("token").value = "";
+  start();
+});
+
+$("sign-out").addEventListener("click", () => {
+  localStorage.removeItem(tokenKey);
+  showLogin("");
+});
+
+$("create-form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const form = e.target;
+  const req = {};
+  for (const [key, value] of new FormData(form)) {
+    if (value) req[key] = value;
+  }
+  if (!req.name && !req.repo) {
+    showMessage("Enter a name or a repository.", true);
+    return;
+  }
+
+  const submit = form.querySelector("button");
+  submit.disabled = true;
+  showMessage("Creating " + (req.name || req.repo) + ", this can take a minute...");
+  try {
+    const shed = await api("POST", "/sheds", req);
+    showMessage("Created " + shed.name + ". Connect with: shed console " + shed.name);
+    form.reset();
+  } catch (err) {
+    if (err.message !== "unauthorized") showMessage(err.message, true);
+  }
+  submit.disabled = false;
+  refresh();
+});
+
+start();
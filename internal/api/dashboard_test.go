@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestDashboard(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.APITokens = []config.APIToken{{Name: "laptop", Token: "s3cret"}}
+	router := NewServer(newFakeDocker(), cfg, nil).Router()
+
+	tests := []struct {
+		path        string
+		want        int
+		contentType string
+		location    string
+	}{
+		{"/", http.StatusFound, "", "/ui/"},
+		{"/ui", http.StatusMovedPermanently, "", "/ui/"},
+		{"/ui/", http.StatusOK, "text/html", ""},
+		{"/ui/app.js", http.StatusOK, "javascript", ""},
+		{"/ui/style.css", http.StatusOK, "text/css", ""},
+		{"/ui/missing.js", http.StatusNotFound, "", ""},
+		// The page itself is public; its API calls still need the token
+		{"/api/sheds", http.StatusUnauthorized, "application/json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.Contains(ct, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.contentType)
+			}
+			if loc := rec.Header().Get("Location"); loc != tt.location {
+				t.Errorf("Location = %q, want %q", loc, tt.location)
+			}
+		})
+	}
+}
+
+func TestDashboardDisabled(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Dashboard = false
+	router := NewServer(newFakeDocker(), cfg, nil).Router()
+
+	for _, path := range []string{"/", "/ui/"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s status = %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleListDeployKeys returns all deploy keys.
+// GET /api/deploy-keys
+func (s *Server) handleListDeployKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.deployKeys.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	resp := config.DeployKeysResponse{
+		DeployKeys: keys,
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleCreateDeployKey generates a new deploy key pair.
+// POST /api/deploy-keys
+func (s *Server) handleCreateDeployKey(w http.ResponseWriter, r *http.Request) {
+	var req config.CreateDeployKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var errs config.ValidationErrors
+	errs.Check("name", config.ValidateDeployKeyName(req.Name))
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	if s.deployKeys.Exists(req.Name) {
+		writeError(w, http.StatusConflict, config.ErrDeployKeyExists, "deploy key \""+req.Name+"\" already exists")
+		return
+	}
+
+	key, err := s.deployKeys.Generate(req.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, key)
+}
+
+// handleGetDeployKey returns a single deploy key's public details.
+// GET /api/deploy-keys/{name}
+func (s *Server) handleGetDeployKey(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if !s.deployKeys.Exists(name) {
+		writeError(w, http.StatusNotFound, config.ErrDeployKeyNotFound, "deploy key \""+name+"\" not found")
+		return
+	}
+
+	key, err := s.deployKeys.Get(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
+
+// handleDeleteDeployKey removes a deploy key pair. Keys still mounted in a
+// shed can't be deleted, since the shed would lose access to its repo.
+// DELETE /api/deploy-keys/{name}
+func (s *Server) handleDeleteDeployKey(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if !s.deployKeys.Exists(name) {
+		writeError(w, http.StatusNotFound, config.ErrDeployKeyNotFound, "deploy key \""+name+"\" not found")
+		return
+	}
+
+	sheds, err := s.docker.ListSheds(r.Context())
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+	var users []string
+	for _, shed := range sheds {
+		if shed.DeployKey == name {
+			users = append(users, shed.Name)
+		}
+	}
+	if len(users) > 0 {
+		sort.Strings(users)
+		writeError(w, http.StatusConflict, config.ErrDeployKeyInUse,
+			"deploy key \""+name+"\" is used by sheds: "+strings.Join(users, ", "))
+		return
+	}
+
+	if err := s.deployKeys.Delete(name); err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
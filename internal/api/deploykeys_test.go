@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleDeleteDeployKeyInUse(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DeployKeyDir = t.TempDir()
+	docker := newFakeDocker(
+		config.Shed{Name: "widget", Status: config.StatusRunning, DeployKey: "github"},
+		config.Shed{Name: "gadget", Status: config.StatusStopped, DeployKey: "github"},
+	)
+	srv := NewServer(docker, cfg, nil)
+	for _, name := range []string{"github", "gitlab"} {
+		if _, err := srv.deployKeys.Generate(name); err != nil {
+			t.Fatalf("Generate(%s) error = %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+		wantCode   string
+	}{
+		{"in use", "github", http.StatusConflict, config.ErrDeployKeyInUse},
+		{"unused", "gitlab", http.StatusNoContent, ""},
+		{"missing", "bitbucket", http.StatusNotFound, config.ErrDeployKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/deploy-keys/"+tt.key, nil)
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantCode != "" {
+				var resp config.APIError
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if resp.Error.Code != tt.wantCode {
+					t.Errorf("error code = %s, want %s", resp.Error.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+
+	if !srv.deployKeys.Exists("github") {
+		t.Error("deploy key used by sheds was deleted")
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleUpdateEnv sets and removes a shed's environment variables.
+// PATCH /api/sheds/{name}/env
+func (s *Server) handleUpdateEnv(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req config.UpdateEnvRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	shed, err := s.docker.UpdateEnv(r.Context(), name, req)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shed)
+}
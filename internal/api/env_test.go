@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleUpdateEnv(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Env: map[string]string{"NODE_ENV": "development", "DEBUG": "1"}})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	tests := []struct {
+		name     string
+		shed     string
+		body     string
+		wantCode int
+		want     string
+	}{
+		{"set and remove", "dev", `{"set":{"API_URL":"http://localhost:3000"},"remove":["DEBUG"]}`, http.StatusOK, "API_URL=http://localhost:3000,NODE_ENV=development"},
+		{"nothing to do", "dev", `{}`, http.StatusBadRequest, ""},
+		{"invalid name", "dev", `{"set":{"1BAD":"x"}}`, http.StatusBadRequest, ""},
+		{"reserved name", "dev", `{"set":{"TZ":"UTC"}}`, http.StatusBadRequest, ""},
+		{"unknown shed", "nope", `{"set":{"DEBUG":"1"}}`, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/api/sheds/"+tt.shed+"/env", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var shed config.Shed
+			if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got := strings.Join(config.EnvList(shed.Env), ","); got != tt.want {
+				t.Errorf("env = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
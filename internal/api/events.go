@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// wantsEventStream reports whether the client asked for a server-sent event stream.
+func wantsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// eventWriter writes server-sent events, flushing each one to the client.
+type eventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newEventWriter starts an event stream response.
+func newEventWriter(w http.ResponseWriter) *eventWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ew := &eventWriter{w: w}
+	ew.flusher, _ = w.(http.Flusher)
+	ew.flush()
+	return ew
+}
+
+// send writes a single event with a JSON-encoded payload.
+func (ew *eventWriter) send(event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(ew.w, "event: %s\ndata: %s\n\n", event, payload)
+	ew.flush()
+}
+
+// comment writes an SSE comment, which clients ignore, to keep idle
+// connections from being closed by proxies.
+func (ew *eventWriter) comment(text string) {
+	fmt.Fprintf(ew.w, ": %s\n\n", text)
+	ew.flush()
+}
+
+func (ew *eventWriter) flush() {
+	if ew.flusher != nil {
+		ew.flusher.Flush()
+	}
+}
+
+// streamCreateShed creates a shed, streaming a "progress" event for each
+// phase followed by a final "done" event with the shed or an "error" event.
+// The request has already been validated, so errors found before streaming
+// starts are still returned as normal JSON responses.
+func (s *Server) streamCreateShed(w http.ResponseWriter, r *http.Request, req config.CreateShedRequest) {
+	streamProgress(w, func(progress config.ProgressFunc) (*config.Shed, error) {
+		shed, _, err := s.createShed(r.Context(), req, progress)
+		return shed, err
+	})
+}
+
+// streamProgress runs a shed operation, streaming its progress events
+// followed by a "done" event with the shed or an "error" event.
+func streamProgress(w http.ResponseWriter, run func(config.ProgressFunc) (*config.Shed, error)) {
+	ew := newEventWriter(w)
+
+	shed, err := run(func(p config.CreateProgress) {
+		ew.send(config.EventProgress, p)
+	})
+	if err != nil {
+		_, errCode, msg := mapDockerError(err)
+		ew.send(config.EventError, config.NewAPIError(errCode, msg))
+		return
+	}
+
+	ew.send(config.EventDone, shed)
+}
+
+// eventKeepalive is how often an idle event stream gets a keepalive comment.
+const eventKeepalive = 30 * time.Second
+
+// handleEvents streams shed events until the client disconnects. Each event
+// is named after its type and carries a config.ShedEvent. With ?shed=name
+// only that shed's events are sent.
+// GET /api/events
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	shed := r.URL.Query().Get("shed")
+	if shed != "" {
+		if err := config.ValidateShedName(shed); err != nil {
+			var errs config.ValidationErrors
+			errs.Check("shed", err)
+			writeValidationError(w, errs)
+			return
+		}
+	}
+
+	events, cancel := s.docker.SubscribeEvents()
+	defer cancel()
+
+	ew := newEventWriter(w)
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if shed == "" || ev.Shed == shed {
+				ew.send(ev.Type, ev)
+			}
+		case <-keepalive.C:
+			ew.comment("keepalive")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
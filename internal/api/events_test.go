@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleEvents(t *testing.T) {
+	docker := newFakeDocker()
+	srv := httptest.NewServer(NewServer(docker, testConfig(t), nil).Router())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/events?shed=dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content type = %q, want text/event-stream", ct)
+	}
+
+	// The subscription exists once the stream has started
+	docker.events.Publish(config.ShedEvent{Type: config.ShedEventStarted, Shed: "other"})
+	docker.events.Publish(config.ShedEvent{Type: config.ShedEventCloneFailed, Shed: "dev", Message: "exit code 128"})
+
+	scanner := bufio.NewScanner(resp.Body)
+	var name, data string
+	for scanner.Scan() && data == "" {
+		line := scanner.Text()
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			name = v
+		} else if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+		}
+	}
+
+	if name != config.ShedEventCloneFailed {
+		t.Errorf("event = %q, want %q", name, config.ShedEventCloneFailed)
+	}
+	var ev config.ShedEvent
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if ev.Shed != "dev" || ev.Message != "exit code 128" || ev.Time.IsZero() {
+		t.Errorf("event = %+v, want clone failure for dev", ev)
+	}
+}
+
+func TestHandleEventsInvalidShed(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/events?shed=Not_Valid", nil)
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleExec runs a command in a shed and returns its stdout, stderr, and
+// exit code once it finishes. With Accept: text/event-stream, the output is
+// streamed as "output" events instead, followed by a "done" event with the
+// exit code or an "error" event. If the timeout passes, or the client goes
+// away, first, the command is killed and a 504 WAIT_TIMEOUT error returned.
+// POST /api/sheds/{name}/exec
+func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req config.ExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+	timeout, _ := req.TimeoutDuration()
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	if wantsEventStream(r) {
+		s.streamExec(ctx, w, name, req.Command, timeout)
+		return
+	}
+
+	stdout := &tailBuffer{max: config.MaxExecOutput}
+	stderr := &tailBuffer{max: config.MaxExecOutput}
+	code, err := s.docker.ExecCommand(ctx, name, req.Command, stdout, stderr)
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusGatewayTimeout, config.ErrWaitTimeout, execTimeoutMessage(timeout))
+		return
+	}
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config.ExecResult{
+		Stdout:    string(stdout.buf),
+		Stderr:    string(stderr.buf),
+		ExitCode:  code,
+		Truncated: stdout.truncated || stderr.truncated,
+	})
+}
+
+// streamExec runs a command, streaming its output as "output" events
+// followed by a "done" event with its exit code or an "error" event.
+func (s *Server) streamExec(ctx context.Context, w http.ResponseWriter, name, command string, timeout time.Duration) {
+	ew := newEventWriter(w)
+
+	stdout := &execStreamWriter{ew: ew, stream: config.StreamStdout}
+	stderr := &execStreamWriter{ew: ew, stream: config.StreamStderr}
+	code, err := s.docker.ExecCommand(ctx, name, command, stdout, stderr)
+	stdout.flush()
+	stderr.flush()
+	if errors.Is(err, context.DeadlineExceeded) {
+		ew.send(config.EventError, config.NewAPIError(config.ErrWaitTimeout, execTimeoutMessage(timeout)))
+		return
+	}
+	if err != nil {
+		_, errCode, msg := mapDockerError(err)
+		ew.send(config.EventError, config.NewAPIError(errCode, msg))
+		return
+	}
+
+	ew.send(config.EventDone, config.ExecResult{ExitCode: code})
+}
+
+// execTimeoutMessage describes an exec that didn't finish within timeout.
+func execTimeoutMessage(timeout time.Duration) string {
+	return fmt.Sprintf("timed out after %s waiting for the command; it was killed", timeout)
+}
+
+// execStreamWriter sends each write to an exec's stream as an "output" event.
+// Events carry text, so a UTF-8 sequence split across writes is held back
+// until the rest of it arrives rather than being mangled.
+type execStreamWriter struct {
+	ew      *eventWriter
+	stream  string
+	partial []byte
+}
+
+func (w *execStreamWriter) Write(p []byte) (int, error) {
+	data := append(w.partial, p...)
+	n := len(data) - incompleteRune(data)
+	w.partial = append([]byte(nil), data[n:]...)
+	if n > 0 {
+		w.ew.send(config.EventOutput, config.ExecOutput{Stream: w.stream, Data: string(data[:n])})
+	}
+	return len(p), nil
+}
+
+// flush sends any bytes held back once the output has ended.
+func (w *execStreamWriter) flush() {
+	if len(w.partial) > 0 {
+		w.ew.send(config.EventOutput, config.ExecOutput{Stream: w.stream, Data: string(w.partial)})
+		w.partial = nil
+	}
+}
+
+// incompleteRune returns the length of a UTF-8 sequence cut off at the end
+// of p, or 0 if p ends on a rune boundary.
+func incompleteRune(p []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(p); i++ {
+		c := p[len(p)-i]
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(p[len(p)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// tailBuffer keeps the last max bytes written to it, starting on a rune
+// boundary.
+type tailBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		start := len(b.buf) - b.max
+		for i := 0; i < utf8.UTFMax-1 && start < len(b.buf) && !utf8.RuneStart(b.buf[start]); i++ {
+			start++
+		}
+		b.buf = append(b.buf[:0], b.buf[start:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestExec(t *testing.T) {
+	docker := newFakeDocker(
+		config.Shed{Name: "dev", Status: config.StatusRunning},
+		config.Shed{Name: "off", Status: config.StatusStopped},
+	)
+	srv := NewServer(docker, testConfig(t), nil)
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/api/sheds/dev/exec", `{"command":"fail"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var result config.ExecResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.ExitCode != 3 || result.Stderr != "failed\n" || result.Stdout != "" {
+		t.Errorf("result = %+v, want exit code 3 with stderr only", result)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"no command", "/api/sheds/dev/exec", `{}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"bad timeout", "/api/sheds/dev/exec", `{"command":"ls","timeout":"2h"}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"timeout", "/api/sheds/dev/exec", `{"command":"sleep","timeout":"10ms"}`, http.StatusGatewayTimeout, config.ErrWaitTimeout},
+		{"missing shed", "/api/sheds/nope/exec", `{"command":"ls"}`, http.StatusNotFound, config.ErrShedNotFound},
+		{"stopped", "/api/sheds/off/exec", `{"command":"ls"}`, http.StatusConflict, config.ErrShedAlreadyStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			var resp config.APIError
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error.Code != tt.code {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestExecStream(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds/dev/exec", strings.NewReader(`{"command":"hello"}`))
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"event: output\ndata: {\"stream\":\"stdout\",\"data\":\"hello\\n\"}\n\n",
+		"event: done\ndata: {\"stdout\":\"\",\"stderr\":\"\",\"exit_code\":0}\n\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("stream = %q, want it to contain %q", body, want)
+		}
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 4}
+	b.Write([]byte("ab"))
+	if string(b.buf) != "ab" || b.truncated {
+		t.Fatalf("buf = %q, truncated = %v, want ab, false", b.buf, b.truncated)
+	}
+	b.Write([]byte("cdef"))
+	if string(b.buf) != "cdef" || !b.truncated {
+		t.Errorf("buf = %q, truncated = %v, want cdef, true", b.buf, b.truncated)
+	}
+
+	// The tail doesn't start partway through a rune
+	b = &tailBuffer{max: 3}
+	b.Write([]byte("a€b"))
+	if string(b.buf) != "b" {
+		t.Errorf("buf = %q, want b", b.buf)
+	}
+}
+
+func TestExecStreamWriterRunes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &execStreamWriter{ew: newEventWriter(rec), stream: config.StreamStdout}
+
+	// "€" split across writes, as reads from the command may split it
+	euro := []byte("€")
+	w.Write(append([]byte("price "), euro[:1]...))
+	w.Write(euro[1:2])
+	w.Write(append(euro[2:], '5'))
+	w.Write([]byte{0xe2})
+	w.flush()
+
+	var got []string
+	for _, event := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		var out config.ExecOutput
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.Split(event, "\n")[1], "data: ")), &out); err != nil {
+			t.Fatalf("failed to decode event %q: %v", event, err)
+		}
+		got = append(got, out.Data)
+	}
+	want := []string{"price ", "€5", "\ufffd"}
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
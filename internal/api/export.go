@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleExportShed streams a tar archive of a shed's workspace.
+// POST /api/sheds/{name}/export
+func (s *Server) handleExportShed(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	archive, err := s.docker.ExportWorkspace(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.tar"`)
+	w.WriteHeader(http.StatusOK)
+
+	// The status is already sent, so a failed copy just ends the stream
+	_, _ = io.Copy(w, archive)
+}
+
+// handleImportShed creates a shed from a workspace archive in the request
+// body, as produced by export and optionally gzipped. The shed's name and
+// image are given as query parameters.
+// POST /api/sheds/import?name=...&image=...
+func (s *Server) handleImportShed(w http.ResponseWriter, r *http.Request) {
+	req := config.CreateShedRequest{
+		Name:  r.URL.Query().Get("name"),
+		Image: r.URL.Query().Get("image"),
+		Owner: tokenName(r.Context()),
+	}
+
+	var errs config.ValidationErrors
+	if req.Name == "" {
+		errs.Add("name", config.FieldRequired, "shed name is required")
+	} else {
+		errs = req.Validate()
+	}
+	if len(errs) == 0 {
+		errs = s.applyResources(&req)
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	if req.Image == "" {
+		req.Image = s.cfg.DefaultImage
+	}
+	auditEntry(r).Shed = req.Name
+
+	done, ok := s.beginCreate(w)
+	if !ok {
+		return
+	}
+	defer done()
+
+	shed, err := s.docker.ImportShed(r.Context(), req, r.Body)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, shed)
+}
+
+// handleCloneShed creates a new shed from a copy of an existing shed's
+// workspace, image, and settings.
+// POST /api/sheds/{name}/clone
+func (s *Server) handleCloneShed(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req config.CloneShedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var errs config.ValidationErrors
+	if req.Name == "" {
+		errs.Add("name", config.FieldRequired, "shed name is required")
+	} else {
+		errs.Check("name", config.ValidateShedName(req.Name))
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	entry := auditEntry(r)
+	entry.Shed, entry.Detail = req.Name, "from "+name
+
+	done, ok := s.beginCreate(w)
+	if !ok {
+		return
+	}
+	defer done()
+
+	shed, err := s.docker.CloneShed(r.Context(), name, req.Name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, shed)
+}
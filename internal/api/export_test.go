@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleExportShed(t *testing.T) {
+	srv := NewServer(newFakeDocker(config.Shed{Name: "widget", Status: config.StatusStopped}), testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds/widget/export", nil)
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-tar" {
+		t.Errorf("Content-Type = %q, want application/x-tar", got)
+	}
+	if got := rec.Body.String(); got != "workspace of widget" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestHandleImportShed(t *testing.T) {
+	srv := NewServer(newFakeDocker(config.Shed{Name: "import", Status: config.StatusRunning}), testConfig(t), nil)
+
+	tests := []struct {
+		name  string
+		query string
+		body  string
+		want  int
+	}{
+		{"imported", "?name=restored", "archive", http.StatusCreated},
+		{"missing name", "", "archive", http.StatusBadRequest},
+		{"invalid name", "?name=Bad_Name", "archive", http.StatusBadRequest},
+		{"empty archive", "?name=empty", "", http.StatusBadRequest},
+		{"existing shed", "?name=restored", "archive", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/sheds/import"+tt.query, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	// A shed named import is still reachable
+	for _, path := range []string{"/api/sheds/import", "/api/sheds/import/sessions"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s status = %d, want %d", path, rec.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestHandleCloneShed(t *testing.T) {
+	srv := NewServer(newFakeDocker(config.Shed{Name: "widget", Status: config.StatusRunning}), testConfig(t), nil)
+
+	tests := []struct {
+		name string
+		src  string
+		body string
+		want int
+	}{
+		{"cloned", "widget", `{"name":"widget-copy"}`, http.StatusCreated},
+		{"missing name", "widget", `{}`, http.StatusBadRequest},
+		{"invalid name", "widget", `{"name":"Bad_Name"}`, http.StatusBadRequest},
+		{"invalid body", "widget", `{`, http.StatusBadRequest},
+		{"missing source", "gadget", `{"name":"gadget-copy"}`, http.StatusNotFound},
+		{"existing shed", "widget", `{"name":"widget-copy"}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/sheds/"+tt.src+"/clone", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
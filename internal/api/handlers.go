@@ -1,42 +1,103 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/logging"
 	"github.com/charliek/shed/internal/version"
 	"github.com/go-chi/chi/v5"
 )
 
 // handleGetInfo returns server information.
-// GET /api/info
+// GET /api/v1/info
 func (s *Server) handleGetInfo(w http.ResponseWriter, r *http.Request) {
 	info := config.ServerInfo{
-		Name:     s.cfg.Name,
-		Version:  version.Info(),
-		SSHPort:  s.cfg.SSHPort,
-		HTTPPort: s.cfg.HTTPPort,
+		Name:       s.cfg.Name,
+		Version:    version.Info(),
+		SSHPort:    s.cfg.SSHPort,
+		HTTPPort:   s.cfg.HTTPPort,
+		DockerHost: s.docker.DockerHost(),
+		Prepull:    s.docker.PrepullStatus(),
+		ImageGC:    s.docker.ImageGCStatus(),
+
+		APIVersion:       version.APIVersion,
+		MinClientVersion: version.MinClientVersion,
+
+		Capabilities: s.docker.Capabilities(r.Context()),
 	}
 
 	writeJSON(w, http.StatusOK, info)
 }
 
-// handleGetSSHHostKey returns the server's SSH host key.
+// handleGetCapacity returns the server's resources and current load.
+// GET /api/server/capacity
+func (s *Server) handleGetCapacity(w http.ResponseWriter, r *http.Request) {
+	capacity, err := s.docker.GetCapacity(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrDockerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, capacity)
+}
+
+// handleGetLogLevel returns the server's current log level.
+// GET /api/server/log-level
+func (s *Server) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, config.LogLevel{Level: logging.Level()})
+}
+
+// handleSetLogLevel changes the server's log level until it restarts.
+// PUT /api/server/log-level
+func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req config.LogLevel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := logging.SetLevel(req.Level); err != nil {
+		var errs config.ValidationErrors
+		errs.Add("level", config.FieldInvalid, "level must be debug, info, warn, or error")
+		writeValidationError(w, errs)
+		return
+	}
+
+	slog.Info("Log level changed", "level", logging.Level())
+	writeJSON(w, http.StatusOK, config.LogLevel{Level: logging.Level()})
+}
+
+// handleGetSSHHostKey returns the server's SSH host key, and the key
+// replacing it while a rotation is in progress.
 // GET /api/ssh-host-key
 func (s *Server) handleGetSSHHostKey(w http.ResponseWriter, r *http.Request) {
-	resp := config.SSHHostKeyResponse{
-		HostKey: s.sshHostKey,
+	var resp config.SSHHostKeyResponse
+	if s.hostKeys != nil {
+		resp.HostKey, resp.NextHostKey = s.hostKeys.HostPublicKeys()
 	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
-// handleListSheds returns all sheds.
+// handleListSheds returns all sheds, or those with every label given as
+// a label=key=value query parameter.
 // GET /api/sheds
 func (s *Server) handleListSheds(w http.ResponseWriter, r *http.Request) {
+	want, errs := parseLabelSelector(r)
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
 	sheds, err := s.docker.ListSheds(r.Context())
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, config.ErrDockerError, err.Error())
@@ -44,52 +105,186 @@ func (s *Server) handleListSheds(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := config.ShedsResponse{
-		Sheds: sheds,
+		Sheds: make([]config.Shed, 0, len(sheds)),
+	}
+	for _, shed := range sheds {
+		if config.MatchLabels(shed.Labels, want) {
+			resp.Sheds = append(resp.Sheds, shed)
+		}
 	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
-// handleCreateShed creates a new shed.
+// handleCreateShed creates a new shed. With ?async=true it replies at once
+// with a job tracking the create, which runs in the background.
 // POST /api/sheds
 func (s *Server) handleCreateShed(w http.ResponseWriter, r *http.Request) {
 	var req config.CreateShedRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, config.ErrInvalidShedName, "invalid request body: "+err.Error())
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	errs := req.Validate()
+
+	if len(errs) == 0 {
+		errs = s.applyResources(&req)
+		errs = append(errs, s.allowMounts(&req)...)
+	}
+
+	// Deploy key must exist before it can be mounted
+	if req.DeployKey != "" && config.ValidateDeployKeyName(req.DeployKey) == nil && !s.deployKeys.Exists(req.DeployKey) {
+		errs.Add("deploy_key", config.FieldNotFound, "deploy key \""+req.DeployKey+"\" not found")
+	}
+	errs = append(errs, s.checkSecretsExist(req.Secrets)...)
+
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
 		return
 	}
 
-	// Validate shed name
+	// A retried create should find the shed the first one made, so its
+	// name is taken from the idempotency key before deriving a new one
+	req.IdempotencyKey = r.Header.Get(config.HeaderIdempotencyKey)
+	if req.Name == "" && req.IdempotencyKey != "" {
+		req.Name, _ = s.docker.IdempotentShed(req.IdempotencyKey)
+	}
+
+	// A repeated create with exists_ok should find the shed the first one
+	// made, so the name isn't made unique
+	if req.Name == "" && req.ExistsOK {
+		req.Name = config.ShedNameFromRepo(req.Repo)
+	}
 	if req.Name == "" {
-		writeError(w, http.StatusBadRequest, config.ErrInvalidShedName, "shed name is required")
+		name, err := s.deriveShedName(r.Context(), req.Repo)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, config.ErrDockerError, err.Error())
+			return
+		}
+		req.Name = name
+	}
+	auditEntry(r).Shed = req.Name
+	req.Owner = tokenName(r.Context())
+
+	// Use default image if not specified. With exists_ok, an existing shed
+	// matches any image unless one is asked for, so the create fills it in.
+	if req.Image == "" && !req.ExistsOK {
+		req.Image = s.cfg.DefaultImage
+	}
+
+	done, ok := s.beginCreate(w)
+	if !ok {
 		return
 	}
-	if err := config.ValidateShedName(req.Name); err != nil {
-		writeError(w, http.StatusBadRequest, config.ErrInvalidShedName, err.Error())
+
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		job := s.startCreateJob(r.Context(), req, done)
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
 		return
 	}
+	defer done()
 
-	// Use default image if not specified
-	if req.Image == "" {
-		req.Image = s.cfg.DefaultImage
+	if wantsEventStream(r) {
+		s.streamCreateShed(w, r, req)
+		return
 	}
 
-	shed, err := s.docker.CreateShed(r.Context(), req)
+	shed, created, err := s.createShed(r.Context(), req, nil)
 	if err != nil {
 		code, errCode, msg := mapDockerError(err)
 		writeError(w, code, errCode, msg)
 		return
 	}
 
+	if !created {
+		writeJSON(w, http.StatusOK, shed)
+		return
+	}
 	writeJSON(w, http.StatusCreated, shed)
 }
 
-// handleGetShed returns a single shed by name.
+// createShed creates a shed. With exists_ok, a shed that already has the
+// name is returned instead if it matches the request, and created is false.
+func (s *Server) createShed(ctx context.Context, req config.CreateShedRequest, progress config.ProgressFunc) (shed *config.Shed, created bool, err error) {
+	shed, err = s.docker.CreateShed(ctx, req, progress)
+	if err == nil || !req.ExistsOK {
+		return shed, err == nil, err
+	}
+	if _, code, _ := mapDockerError(err); code != config.ErrShedAlreadyExists {
+		return nil, false, err
+	}
+
+	existing, getErr := s.docker.GetShed(ctx, req.Name)
+	if getErr != nil {
+		return nil, false, err
+	}
+	if err := req.CheckExisting(existing); err != nil {
+		return nil, false, &DockerError{Code: config.ErrShedAlreadyExists, Message: err.Error()}
+	}
+	return existing, false, nil
+}
+
+// applyResources fills in the server's default limits for a valid create
+// request and checks them against the maximums.
+func (s *Server) applyResources(req *config.CreateShedRequest) config.ValidationErrors {
+	req.Resources = s.cfg.Resources.Apply(req.Resources)
+	return req.Resources.CheckMax(s.cfg.Resources.Max)
+}
+
+// allowMounts checks a valid create request's extra mounts against the
+// server's allowlist, resolving bind sources to their real paths.
+func (s *Server) allowMounts(req *config.CreateShedRequest) config.ValidationErrors {
+	var errs config.ValidationErrors
+	for i, m := range req.Mounts {
+		allowed, err := s.cfg.Mounts.Allow(m)
+		if err != nil {
+			errs.Add(fmt.Sprintf("mounts[%d]", i), config.FieldInvalid, err.Error())
+			continue
+		}
+		req.Mounts[i] = allowed
+	}
+	return errs
+}
+
+// deriveShedName returns a name for a shed created from repo, adding a
+// numeric suffix if the name derived from the repo is already taken.
+func (s *Server) deriveShedName(ctx context.Context, repo string) (string, error) {
+	sheds, err := s.docker.ListSheds(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool, len(sheds))
+	for _, shed := range sheds {
+		taken[shed.Name] = true
+	}
+
+	base := config.ShedNameFromRepo(repo)
+	if !taken[base] {
+		return base, nil
+	}
+
+	for i := 2; ; i++ {
+		suffix := "-" + strconv.Itoa(i)
+		name := base
+		if len(name)+len(suffix) > config.MaxShedNameLength {
+			name = strings.TrimRight(name[:config.MaxShedNameLength-len(suffix)], "-")
+		}
+		name += suffix
+		if !taken[name] {
+			return name, nil
+		}
+	}
+}
+
+// handleGetShed returns a single shed by name, with its container details.
 // GET /api/sheds/{name}
 func (s *Server) handleGetShed(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
 
-	shed, err := s.docker.GetShed(r.Context(), name)
+	shed, err := s.docker.GetShedDetails(r.Context(), name)
 	if err != nil {
 		code, errCode, msg := mapDockerError(err)
 		writeError(w, code, errCode, msg)
@@ -114,12 +309,19 @@ func (s *Server) handleDeleteShed(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// handleStartShed starts a stopped shed.
+// handleStartShed starts a stopped shed, restoring it from a checkpoint if
+// the checkpoint query parameter is set.
 // POST /api/sheds/{name}/start
 func (s *Server) handleStartShed(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "name")
 
-	shed, err := s.docker.StartShed(r.Context(), name)
+	var shed *config.Shed
+	var err error
+	if checkpoint := r.URL.Query().Get("checkpoint"); checkpoint != "" {
+		shed, err = s.docker.RestoreShed(r.Context(), name, checkpoint)
+	} else {
+		shed, err = s.docker.StartShed(r.Context(), name)
+	}
 	if err != nil {
 		code, errCode, msg := mapDockerError(err)
 		writeError(w, code, errCode, msg)
@@ -144,6 +346,193 @@ func (s *Server) handleStopShed(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, shed)
 }
 
+// handlePauseShed freezes a running shed's processes.
+// POST /api/sheds/{name}/pause
+func (s *Server) handlePauseShed(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	shed, err := s.docker.PauseShed(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shed)
+}
+
+// handleResumeShed unfreezes a paused shed's processes.
+// POST /api/sheds/{name}/resume
+func (s *Server) handleResumeShed(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	shed, err := s.docker.ResumeShed(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shed)
+}
+
+// handleRebuildShed recreates a shed's container from its image, or a new
+// one, keeping its workspace, settings, and mounts. The body is optional.
+// POST /api/sheds/{name}/rebuild
+func (s *Server) handleRebuildShed(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req config.RebuildShedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if req.Image != "" {
+		if err := config.ValidateImageRef(req.Image); err != nil {
+			var errs config.ValidationErrors
+			errs.Check("image", err)
+			writeValidationError(w, errs)
+			return
+		}
+	}
+
+	if wantsEventStream(r) {
+		streamProgress(w, func(progress config.ProgressFunc) (*config.Shed, error) {
+			return s.docker.RebuildShed(r.Context(), name, req, progress)
+		})
+		return
+	}
+
+	shed, err := s.docker.RebuildShed(r.Context(), name, req, nil)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shed)
+}
+
+// handleWaitShed blocks until a shed reaches the requested status, then
+// returns it. If the timeout passes first, a WAIT_TIMEOUT error is returned.
+// GET /api/sheds/{name}/wait?status=running&timeout=60s
+func (s *Server) handleWaitShed(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = config.StatusRunning
+	}
+
+	var errs config.ValidationErrors
+	if !config.ValidStatus(status) {
+		errs.Add("status", config.FieldInvalid, fmt.Sprintf("unknown status %q", status))
+	}
+
+	timeout := config.DefaultWaitTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		switch {
+		case err != nil:
+			errs.Add("timeout", config.FieldInvalid, "timeout must be a duration such as 30s or 2m")
+		case d <= 0 || d > config.MaxWaitTimeout:
+			errs.Add("timeout", config.FieldInvalid, fmt.Sprintf("timeout must be positive and at most %s", config.MaxWaitTimeout))
+		default:
+			timeout = d
+		}
+	}
+
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	shed, err := s.docker.WaitForStatus(ctx, name, status)
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusGatewayTimeout, config.ErrWaitTimeout,
+			fmt.Sprintf("timed out after %s waiting for shed %q to be %s", timeout, name, status))
+		return
+	}
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shed)
+}
+
+// handleListSessions returns the sessions in a shed.
+// GET /api/sheds/{name}/sessions
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	sessions, err := s.docker.ListSessions(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	resp := config.SessionsResponse{
+		Sessions: sessions,
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleListPorts returns the TCP ports processes in a shed are listening on.
+// GET /api/sheds/{name}/ports
+func (s *Server) handleListPorts(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	ports, err := s.docker.ListPorts(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config.PortsResponse{Ports: ports})
+}
+
+// handleGetShedStats returns resource usage and activity for a shed.
+// Session activity and workspace size are skipped when the activity query
+// parameter is false, for callers polling usage.
+// GET /api/sheds/{name}/stats
+func (s *Server) handleGetShedStats(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	activity := r.URL.Query().Get("activity") != "false"
+
+	stats, err := s.docker.GetShedStats(r.Context(), name, activity)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleGetWorkspaceDiff reports uncommitted changes in a shed's workspace.
+// The full diff is included when the full query parameter is true.
+// GET /api/sheds/{name}/diff
+func (s *Server) handleGetWorkspaceDiff(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	full := r.URL.Query().Get("full") == "true"
+
+	diff, err := s.docker.GetWorkspaceDiff(r.Context(), name, full)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, diff)
+}
+
 // writeJSON writes a JSON response with the given status code.
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.WriteHeader(status)
@@ -161,6 +550,13 @@ func writeError(w http.ResponseWriter, status int, code, message string) {
 	writeJSON(w, status, apiErr)
 }
 
+// writeValidationError writes a 400 response listing each rejected field.
+func writeValidationError(w http.ResponseWriter, errs config.ValidationErrors) {
+	apiErr := config.NewAPIError(config.ErrValidationFailed, "invalid request: "+errs.Error())
+	apiErr.Error.Fields = errs
+	writeJSON(w, http.StatusBadRequest, apiErr)
+}
+
 // DockerError is an error type that can be returned by the docker client
 // to indicate specific error conditions.
 type DockerError struct {
@@ -185,6 +581,10 @@ func mapDockerError(err error) (int, string, string) {
 			return http.StatusConflict, config.ErrShedAlreadyRunning, dockerErr.Message
 		case config.ErrShedAlreadyStopped:
 			return http.StatusConflict, config.ErrShedAlreadyStopped, dockerErr.Message
+		case config.ErrShedPaused:
+			return http.StatusConflict, config.ErrShedPaused, dockerErr.Message
+		case config.ErrShedNotPaused:
+			return http.StatusConflict, config.ErrShedNotPaused, dockerErr.Message
 		case config.ErrInvalidShedName:
 			return http.StatusBadRequest, config.ErrInvalidShedName, dockerErr.Message
 		case config.ErrCloneFailed:
@@ -194,6 +594,57 @@ func mapDockerError(err error) (int, string, string) {
 
 	// Check for common error messages
 	errMsg := err.Error()
+	if strings.Contains(errMsg, "safety push failed") {
+		// Checked first since the wrapped cause may match the patterns below
+		msg, _, _ := strings.Cut(errMsg, ": ")
+		return http.StatusInternalServerError, config.ErrSafetyPushFailed, msg
+	}
+	if strings.HasPrefix(errMsg, "failed to pull image") {
+		// Checked before "not found", which registries often report
+		return http.StatusBadGateway, config.ErrImagePullFailed, errMsg
+	}
+	if strings.HasPrefix(errMsg, "image build failed") {
+		return http.StatusUnprocessableEntity, config.ErrImageBuildFailed, errMsg
+	}
+	if strings.HasPrefix(errMsg, "invalid workspace archive") || strings.HasPrefix(errMsg, "cannot clone") || strings.HasPrefix(errMsg, "cannot rebuild") || strings.HasPrefix(errMsg, "cannot update environment") {
+		return http.StatusBadRequest, config.ErrInvalidRequest, errMsg
+	}
+	if strings.Contains(errMsg, "not supported on this server") || strings.Contains(errMsg, "not supported in shed") {
+		return http.StatusNotImplemented, config.ErrNotSupported, errMsg
+	}
+	if strings.HasPrefix(errMsg, "checkpoint ") && strings.Contains(errMsg, "not found") {
+		return http.StatusNotFound, config.ErrCheckpointNotFound, errMsg
+	}
+	if strings.HasPrefix(errMsg, "schedule ") && strings.Contains(errMsg, "not found") {
+		return http.StatusNotFound, config.ErrScheduleNotFound, errMsg
+	}
+	if strings.HasPrefix(errMsg, "session ") && strings.Contains(errMsg, "not found") {
+		return http.StatusNotFound, config.ErrSessionNotFound, errMsg
+	}
+	if strings.HasPrefix(errMsg, "session ") && strings.Contains(errMsg, "already exists") {
+		return http.StatusConflict, config.ErrSessionExists, errMsg
+	}
+	if strings.HasPrefix(errMsg, "session ") && strings.Contains(errMsg, "is busy") {
+		return http.StatusConflict, config.ErrSessionBusy, errMsg
+	}
+	if strings.HasPrefix(errMsg, "backup ") && strings.Contains(errMsg, "not found") {
+		return http.StatusNotFound, config.ErrBackupNotFound, errMsg
+	}
+	if strings.HasPrefix(errMsg, "backup failed") {
+		return http.StatusBadGateway, config.ErrBackupFailed, errMsg
+	}
+	if strings.Contains(errMsg, "already being backed up") {
+		return http.StatusConflict, config.ErrBackupInProgress, errMsg
+	}
+	if strings.HasPrefix(errMsg, "no address pool left") {
+		return http.StatusServiceUnavailable, config.ErrNetworkPoolFull, errMsg
+	}
+	if strings.HasPrefix(errMsg, "idempotency key ") {
+		return http.StatusConflict, config.ErrIdempotencyKeyUsed, errMsg
+	}
+	if strings.HasPrefix(errMsg, "invalid cron expression") || strings.HasPrefix(errMsg, "invalid schedule action") {
+		return http.StatusBadRequest, config.ErrInvalidRequest, errMsg
+	}
 	if strings.Contains(errMsg, "not found") {
 		return http.StatusNotFound, config.ErrShedNotFound, sanitizeErrorMessage(errMsg, "not found")
 	}
@@ -203,6 +654,12 @@ func mapDockerError(err error) (int, string, string) {
 	if strings.Contains(errMsg, "already running") {
 		return http.StatusConflict, config.ErrShedAlreadyRunning, sanitizeErrorMessage(errMsg, "already running")
 	}
+	if strings.Contains(errMsg, "is paused") || strings.Contains(errMsg, "already paused") {
+		return http.StatusConflict, config.ErrShedPaused, sanitizeErrorMessage(errMsg, "paused")
+	}
+	if strings.Contains(errMsg, "not paused") {
+		return http.StatusConflict, config.ErrShedNotPaused, sanitizeErrorMessage(errMsg, "not paused")
+	}
 	if strings.Contains(errMsg, "already stopped") || strings.Contains(errMsg, "not running") {
 		return http.StatusConflict, config.ErrShedAlreadyStopped, sanitizeErrorMessage(errMsg, "not running")
 	}
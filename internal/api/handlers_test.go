@@ -0,0 +1,435 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/logging"
+)
+
+func TestHandleCreateShedValidation(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	body := `{"name":"Bad_Name","repo":"not-a-url","deploy_key":"missing","branch":"main","secrets":[{"name":"missing"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+
+	var resp config.APIError
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error.Code != config.ErrValidationFailed {
+		t.Errorf("error code = %q, want %q", resp.Error.Code, config.ErrValidationFailed)
+	}
+
+	want := map[string]string{
+		"name":            config.FieldInvalid,
+		"repo":            config.FieldInvalid,
+		"branch":          config.FieldInvalid,
+		"deploy_key":      config.FieldNotFound,
+		"secrets[0].name": config.FieldNotFound,
+	}
+	if len(resp.Error.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want %d entries", resp.Error.Fields, len(want))
+	}
+	for _, fe := range resp.Error.Fields {
+		if want[fe.Field] != fe.Code {
+			t.Errorf("field %q code = %q, want %q", fe.Field, fe.Code, want[fe.Field])
+		}
+	}
+}
+
+func TestHandleCreateShedDerivesName(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "widget-factory"})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	for _, want := range []string{"widget-factory-2", "widget-factory-3"} {
+		body := `{"repo":"git@github.com:org/widget-factory.git"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+		}
+
+		var shed config.Shed
+		if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if shed.Name != want {
+			t.Errorf("name = %q, want %q", shed.Name, want)
+		}
+	}
+}
+
+func TestHandleCreateShedRecordsOwner(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.APITokens = []config.APIToken{{Name: "laptop", Token: "s3cret"}}
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	body := `{"name":"widget","owner":"someone-else"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var shed config.Shed
+	if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if shed.Owner != "laptop" {
+		t.Errorf("owner = %q, want the token's name", shed.Owner)
+	}
+}
+
+func TestHandleCreateShedExistsOK(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "widget-factory", Status: config.StatusStopped, Repo: "git@github.com:org/widget-factory.git", Image: "shed-base:latest"})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantName   string
+	}{
+		{"existing by derived name", `{"repo":"git@github.com:org/widget-factory.git","exists_ok":true}`, http.StatusOK, "widget-factory"},
+		{"existing by name", `{"name":"widget-factory","exists_ok":true}`, http.StatusOK, "widget-factory"},
+		{"different image", `{"name":"widget-factory","image":"ubuntu:24.04","exists_ok":true}`, http.StatusConflict, ""},
+		{"without exists_ok", `{"name":"widget-factory"}`, http.StatusConflict, ""},
+		{"new shed", `{"name":"gadget","exists_ok":true}`, http.StatusCreated, "gadget"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantName == "" {
+				return
+			}
+			var shed config.Shed
+			if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if shed.Name != tt.wantName {
+				t.Errorf("name = %q, want %q", shed.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestHandleCreateShedIdempotentRetry(t *testing.T) {
+	docker := newFakeDocker()
+	srv := NewServer(docker, testConfig(t), nil)
+
+	// The retry must get the shed the first create made, not widget-factory-2
+	body := `{"repo":"git@github.com:org/widget-factory.git"}`
+	for i := range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(body))
+		req.Header.Set(config.HeaderIdempotencyKey, "retry-1")
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("attempt %d: status = %d, want %d: %s", i+1, rec.Code, http.StatusCreated, rec.Body.String())
+		}
+		var shed config.Shed
+		if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if shed.Name != "widget-factory" {
+			t.Errorf("attempt %d: name = %q, want widget-factory", i+1, shed.Name)
+		}
+	}
+	if sheds, _ := docker.ListSheds(context.Background()); len(sheds) != 1 {
+		t.Errorf("%d sheds created, want 1", len(sheds))
+	}
+}
+
+func TestHandleCreateShedResources(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Resources = config.ResourcesConfig{
+		Defaults: config.Resources{CPUs: 1, Memory: "2g"},
+		Max:      config.Resources{CPUs: 4, Memory: "8g"},
+	}
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+		res  config.Resources
+	}{
+		{"defaults", `{"name":"plain"}`, http.StatusCreated, config.Resources{CPUs: 1, Memory: "2g"}},
+		{"override", `{"name":"big","cpus":4,"memory":"8g","pids_limit":500}`, http.StatusCreated, config.Resources{CPUs: 4, Memory: "8g", PidsLimit: 500}},
+		{"over max", `{"name":"huge","cpus":8}`, http.StatusBadRequest, config.Resources{}},
+		{"bad memory", `{"name":"odd","memory":"lots"}`, http.StatusBadRequest, config.Resources{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want != http.StatusCreated {
+				return
+			}
+
+			var shed config.Shed
+			if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if shed.Resources == nil || *shed.Resources != tt.res {
+				t.Errorf("resources = %+v, want %+v", shed.Resources, tt.res)
+			}
+		})
+	}
+}
+
+func TestHandleCreateShedPullFailure(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(`{"name":"dev","image":"missing:latest"}`))
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadGateway, rec.Body.String())
+	}
+	var apiErr config.APIError
+	if err := json.NewDecoder(rec.Body).Decode(&apiErr); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if apiErr.Error.Code != config.ErrImagePullFailed {
+		t.Errorf("code = %q, want %q", apiErr.Error.Code, config.ErrImagePullFailed)
+	}
+}
+
+func TestMapNetworkPoolError(t *testing.T) {
+	err := errors.New("no address pool left for network shed-dev-net: Docker's default pools hold about 30 networks; delete unused sheds or configure default-address-pools with smaller subnets")
+	status, code, msg := mapDockerError(err)
+	if status != http.StatusServiceUnavailable || code != config.ErrNetworkPoolFull {
+		t.Errorf("mapDockerError() = %d %s, want %d %s", status, code, http.StatusServiceUnavailable, config.ErrNetworkPoolFull)
+	}
+	if msg != err.Error() {
+		t.Errorf("message = %q, want the error kept", msg)
+	}
+}
+
+func TestHandleCreateShedMounts(t *testing.T) {
+	allowed := t.TempDir()
+	if err := os.Mkdir(filepath.Join(allowed, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t)
+	cfg.Mounts = config.MountsConfig{AllowedPaths: []string{allowed}, AllowedVolumes: []string{"datasets"}}
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	dataMount := fmt.Sprintf(`{"type":"bind","source":%q,"target":"/data","readonly":true}`, filepath.Join(allowed, "data"))
+	tests := []struct {
+		name   string
+		mounts string
+		want   int
+	}{
+		{"allowed path", dataMount, http.StatusCreated},
+		{"allowed volume", `{"type":"volume","source":"datasets","target":"/datasets"}`, http.StatusCreated},
+		{"outside allowed paths", `{"type":"bind","source":"/etc","target":"/host-etc"}`, http.StatusBadRequest},
+		{"volume not allowed", `{"type":"volume","source":"shed-other-workspace","target":"/other"}`, http.StatusBadRequest},
+		{"over workspace", `{"type":"volume","source":"datasets","target":"/workspace"}`, http.StatusBadRequest},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"name":"mounts-%d","mounts":[%s]}`, i, tt.mounts)
+			req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want != http.StatusCreated {
+				return
+			}
+
+			var shed config.Shed
+			if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(shed.Mounts) != 1 {
+				t.Errorf("mounts = %+v, want one mount", shed.Mounts)
+			}
+		})
+	}
+}
+
+func TestHandleCreateShedStreamsProgress(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(`{"name":"streamed"}`))
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content type = %q, want text/event-stream", ct)
+	}
+
+	body := rec.Body.String()
+	progress := strings.Index(body, "event: progress\n")
+	done := strings.Index(body, "event: done\n")
+	if progress < 0 || done < 0 || progress > done {
+		t.Fatalf("expected progress events followed by done, got:\n%s", body)
+	}
+	if !strings.Contains(body[done:], `"name":"streamed"`) {
+		t.Errorf("done event missing shed: %s", body[done:])
+	}
+}
+
+func TestHandleGetShedDetails(t *testing.T) {
+	srv := NewServer(newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning}), testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sheds/dev", nil)
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var shed config.Shed
+	if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if shed.Details == nil || len(shed.Details.Mounts) != 1 || shed.Details.ImageID == "" {
+		t.Errorf("details = %+v, want container details", shed.Details)
+	}
+}
+
+func TestHandleRebuildShed(t *testing.T) {
+	tests := []struct {
+		name       string
+		shed       string
+		body       string
+		wantStatus int
+		wantImage  string
+	}{
+		{"keeps image", "dev", "", http.StatusOK, "shed-base:latest"},
+		{"new image", "dev", `{"image":"shed-base:v2"}`, http.StatusOK, "shed-base:v2"},
+		{"invalid image", "dev", `{"image":"Not An Image"}`, http.StatusBadRequest, ""},
+		{"missing shed", "nope", "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusStopped, Image: "shed-base:latest"})
+			srv := NewServer(fake, testConfig(t), nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/sheds/"+tt.shed+"/rebuild", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantImage == "" {
+				return
+			}
+
+			var shed config.Shed
+			if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if shed.Image != tt.wantImage || shed.Status != config.StatusRunning {
+				t.Errorf("shed = %s %s, want %s running", shed.Image, shed.Status, tt.wantImage)
+			}
+		})
+	}
+}
+
+func TestHandleRebuildShedStreamsProgress(t *testing.T) {
+	srv := NewServer(newFakeDocker(config.Shed{Name: "dev"}), testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sheds/dev/rebuild", strings.NewReader(`{"pull":true}`))
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	progress := strings.Index(body, "event: progress\n")
+	done := strings.Index(body, "event: done\n")
+	if progress < 0 || done < 0 || progress > done {
+		t.Fatalf("expected progress events followed by done, got:\n%s", body)
+	}
+}
+
+func TestHandleLogLevel(t *testing.T) {
+	if err := logging.Setup(io.Discard, "text", "info"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = logging.SetLevel("info") })
+
+	cfg := testConfig(t)
+	router := NewServer(newFakeDocker(), cfg, nil).Router()
+
+	tests := []struct {
+		method string
+		body   string
+		want   int
+		level  string
+	}{
+		{http.MethodGet, "", http.StatusOK, "info"},
+		{http.MethodPut, `{"level":"debug"}`, http.StatusOK, "debug"},
+		{http.MethodGet, "", http.StatusOK, "debug"},
+		{http.MethodPut, `{"level":"loud"}`, http.StatusBadRequest, ""},
+		{http.MethodPut, `not json`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/server/log-level", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Fatalf("%s %s: status = %d, want %d: %s", tt.method, tt.body, rec.Code, tt.want, rec.Body.String())
+		}
+		if tt.level == "" {
+			continue
+		}
+		var resp config.LogLevel
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Level != tt.level {
+			t.Errorf("%s %s: level = %q, want %q", tt.method, tt.body, resp.Level, tt.level)
+		}
+	}
+}
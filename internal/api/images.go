@@ -0,0 +1,121 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// maxDockerfileSize bounds a Dockerfile sent without a build context.
+const maxDockerfileSize = 1 << 20
+
+// handleListImages returns the images sheds can be created from.
+// GET /api/images
+func (s *Server) handleListImages(w http.ResponseWriter, r *http.Request) {
+	images, err := s.docker.ListImages(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrDockerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config.ImagesResponse{Images: images})
+}
+
+// handleBuildImage builds an image from the request body, streaming an
+// "output" event for each chunk of build output followed by a "done" event
+// with the image or an "error" event. A tar body, optionally gzipped, is
+// used as the build context; any other body is taken to be a Dockerfile.
+// POST /api/images/build?tag=...&dockerfile=...&pull=true
+func (s *Server) handleBuildImage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := config.BuildImageRequest{
+		Tag:        query.Get("tag"),
+		Dockerfile: query.Get("dockerfile"),
+	}
+
+	var errs config.ValidationErrors
+	if pull := query.Get("pull"); pull != "" {
+		var err error
+		if req.Pull, err = strconv.ParseBool(pull); err != nil {
+			errs.Add("pull", config.FieldInvalid, "pull must be true or false")
+		}
+	}
+	errs = append(errs, req.Validate(s.cfg.Images.Prefixes)...)
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	buildContext := io.Reader(r.Body)
+	if !isArchive(r.Header.Get("Content-Type")) {
+		dockerfile, err := io.ReadAll(io.LimitReader(r.Body, maxDockerfileSize+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "failed to read Dockerfile")
+			return
+		}
+		if len(dockerfile) == 0 {
+			writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "request body must be a Dockerfile or build context archive")
+			return
+		}
+		if len(dockerfile) > maxDockerfileSize {
+			writeError(w, http.StatusRequestEntityTooLarge, config.ErrInvalidRequest, "Dockerfile is too large")
+			return
+		}
+		if req.Dockerfile == "" {
+			req.Dockerfile = "Dockerfile"
+		}
+		if buildContext, err = dockerfileContext(req.Dockerfile, dockerfile); err != nil {
+			writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+			return
+		}
+	}
+
+	ew := newEventWriter(w)
+
+	image, err := s.docker.BuildImage(r.Context(), req, buildContext, func(text string) {
+		ew.send(config.EventOutput, config.BuildOutput{Stream: text})
+	})
+	if err != nil {
+		_, errCode, msg := mapDockerError(err)
+		ew.send(config.EventError, config.NewAPIError(errCode, msg))
+		return
+	}
+
+	ew.send(config.EventDone, image)
+}
+
+// isArchive reports whether a request body is a tar build context.
+func isArchive(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-tar", "application/tar", "application/gzip", "application/x-gzip":
+		return true
+	}
+	return false
+}
+
+// dockerfileContext returns a build context holding only a Dockerfile.
+func dockerfileContext(name string, dockerfile []byte) (io.Reader, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(dockerfile)),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write build context: %w", err)
+	}
+	if _, err := tw.Write(dockerfile); err != nil {
+		return nil, fmt.Errorf("failed to write build context: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write build context: %w", err)
+	}
+	return &buf, nil
+}
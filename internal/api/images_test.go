@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleListImages(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/images", nil)
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp config.ImagesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Images) != 2 || !resp.Images[0].Default {
+		t.Errorf("images = %+v, want the default image first of two", resp.Images)
+	}
+}
+
+func TestHandleBuildImage(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		body       string
+		prefixes   []string
+		wantStatus int
+		wantEvent  string
+		wantText   string
+	}{
+		{
+			name:       "dockerfile body",
+			query:      "tag=shed-go:latest",
+			body:       "FROM shed-base:latest\n",
+			wantStatus: http.StatusOK,
+			wantEvent:  "event: done\n",
+			wantText:   `"name":"shed-go:latest"`,
+		},
+		{
+			name:       "build failure",
+			query:      "tag=shed-go:latest",
+			body:       "FROM shed-base:latest\nRUN FAIL\n",
+			wantStatus: http.StatusOK,
+			wantEvent:  "event: error\n",
+			wantText:   config.ErrImageBuildFailed,
+		},
+		{
+			name:       "missing tag",
+			body:       "FROM shed-base:latest\n",
+			wantStatus: http.StatusBadRequest,
+			wantText:   `"field":"tag"`,
+		},
+		{
+			name:       "tag outside prefixes",
+			query:      "tag=other:latest",
+			body:       "FROM shed-base:latest\n",
+			prefixes:   []string{"shed-"},
+			wantStatus: http.StatusBadRequest,
+			wantText:   `"field":"tag"`,
+		},
+		{
+			name:       "dockerfile outside context",
+			query:      "tag=shed-go:latest&dockerfile=../Dockerfile",
+			body:       "FROM shed-base:latest\n",
+			wantStatus: http.StatusBadRequest,
+			wantText:   `"field":"dockerfile"`,
+		},
+		{
+			name:       "empty body",
+			query:      "tag=shed-go:latest",
+			wantStatus: http.StatusBadRequest,
+			wantText:   config.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.Images.Prefixes = tt.prefixes
+			srv := NewServer(newFakeDocker(), cfg, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/images/build?"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "text/plain")
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			body := rec.Body.String()
+			if tt.wantEvent != "" {
+				if !strings.Contains(body, "event: output\n") {
+					t.Errorf("expected build output events, got:\n%s", body)
+				}
+				idx := strings.Index(body, tt.wantEvent)
+				if idx < 0 {
+					t.Fatalf("expected %q, got:\n%s", tt.wantEvent, body)
+				}
+				body = body[idx:]
+			}
+			if !strings.Contains(body, tt.wantText) {
+				t.Errorf("body missing %q:\n%s", tt.wantText, body)
+			}
+		})
+	}
+}
+
+func TestHandleBuildImageArchive(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	buildContext, err := dockerfileContext("build/Dockerfile.dev", []byte("FROM shed-base:latest\n"))
+	if err != nil {
+		t.Fatalf("dockerfileContext() error = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/images/build?tag=shed-dev:latest&dockerfile=build/Dockerfile.dev", buildContext)
+	req.Header.Set("Content-Type", "application/x-tar")
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "event: done\n") || !strings.Contains(body, `"name":"shed-dev:latest"`) {
+		t.Errorf("expected done event with the image, got:\n%s", body)
+	}
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// jobRetention is how long finished jobs can still be looked up.
+const jobRetention = time.Hour
+
+// jobCancelGrace is how long canceled jobs get to clean up at shutdown.
+const jobCancelGrace = 5 * time.Second
+
+// jobStore tracks background jobs in memory; jobs don't survive a server
+// restart. Running jobs are counted in wg and canceled with cancel.
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*config.Job
+	now  func() time.Time
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newJobStore() *jobStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobStore{jobs: make(map[string]*config.Job), now: time.Now, ctx: ctx, cancel: cancel}
+}
+
+// wait waits for running jobs to finish. If ctx ends first, the jobs are
+// canceled and given jobCancelGrace to clean up, and ctx's error returned.
+func (js *jobStore) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		js.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	js.cancel()
+	select {
+	case <-done:
+	case <-time.After(jobCancelGrace):
+	}
+	return ctx.Err()
+}
+
+// start records a new running job.
+func (js *jobStore) start(jobType, shed string) config.Job {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.prune()
+
+	job := &config.Job{
+		ID:        hex.EncodeToString(id),
+		Type:      jobType,
+		Shed:      shed,
+		Status:    config.JobRunning,
+		CreatedAt: js.now().UTC(),
+	}
+	js.jobs[job.ID] = job
+	return *job
+}
+
+// progress records a job's latest progress event.
+func (js *jobStore) progress(id string, p config.CreateProgress) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if job, ok := js.jobs[id]; ok {
+		job.Progress = &p
+	}
+}
+
+// finish records a job's result, or why it failed.
+func (js *jobStore) finish(id string, shed *config.Shed, err error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	job, ok := js.jobs[id]
+	if !ok {
+		return
+	}
+
+	now := js.now().UTC()
+	job.FinishedAt = &now
+	if err != nil {
+		_, code, msg := mapDockerError(err)
+		job.Status = config.JobFailed
+		job.Error = &config.APIErrorDetail{Code: code, Message: msg}
+		return
+	}
+	job.Status = config.JobSucceeded
+	job.Result = shed
+}
+
+// get returns a job by ID.
+func (js *jobStore) get(id string) (config.Job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.prune()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return config.Job{}, false
+	}
+	return *job, true
+}
+
+// list returns the jobs, newest first.
+func (js *jobStore) list() []config.Job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.prune()
+
+	jobs := make([]config.Job, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// prune drops jobs that finished more than jobRetention ago. The caller
+// holds js.mu.
+func (js *jobStore) prune() {
+	cutoff := js.now().Add(-jobRetention)
+	for id, job := range js.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(js.jobs, id)
+		}
+	}
+}
+
+// startCreateJob creates a shed in the background, returning the job that
+// tracks it. done is called once the create finishes.
+func (s *Server) startCreateJob(ctx context.Context, req config.CreateShedRequest, done func()) config.Job {
+	job := s.jobs.start(config.JobTypeCreate, req.Name)
+
+	// The request's context ends with the response; the job's ends when
+	// the server gives up waiting for it at shutdown
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(s.jobs.ctx, cancel)
+	s.jobs.wg.Add(1)
+	go func() {
+		defer s.jobs.wg.Done()
+		defer stop()
+		defer cancel()
+		defer done()
+		shed, _, err := s.createShed(ctx, req, func(p config.CreateProgress) {
+			s.jobs.progress(job.ID, p)
+		})
+		s.jobs.finish(job.ID, shed, err)
+	}()
+
+	return job
+}
+
+// WaitForJobs waits for background jobs, such as async creates, to finish
+// during shutdown. Jobs still running when ctx ends are canceled so they
+// clean up what they created, and ctx's error is returned.
+func (s *Server) WaitForJobs(ctx context.Context) error {
+	return s.jobs.wait(ctx)
+}
+
+// handleListJobs returns the running and recently finished jobs.
+// GET /api/jobs
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, config.JobsResponse{Jobs: s.jobs.list()})
+}
+
+// handleGetJob returns a job's status, progress, and result.
+// GET /api/jobs/{id}
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	job, ok := s.jobs.get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, config.ErrJobNotFound, "job \""+id+"\" not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestAsyncCreate(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "taken", Status: config.StatusRunning})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// waitJob polls a job until it finishes
+	waitJob := func(id string) config.Job {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			rec := do(http.MethodGet, "/api/jobs/"+id, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("get job: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			var job config.Job
+			if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+				t.Fatalf("failed to decode job: %v", err)
+			}
+			if job.Status != config.JobRunning {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s still running", id)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	rec := do(http.MethodPost, "/api/sheds?async=true", `{"name":"dev"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("create: status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+	var job config.Job
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatalf("failed to decode job: %v", err)
+	}
+	if job.ID == "" || job.Type != config.JobTypeCreate || job.Shed != "dev" {
+		t.Fatalf("job = %+v, want a create job for dev", job)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/api/jobs/"+job.ID {
+		t.Errorf("Location = %q, want /api/jobs/%s", loc, job.ID)
+	}
+
+	done := waitJob(job.ID)
+	if done.Status != config.JobSucceeded || done.Result == nil || done.Result.Name != "dev" {
+		t.Errorf("finished job = %+v, want succeeded with shed dev", done)
+	}
+	if done.FinishedAt == nil {
+		t.Error("finished job has no finished_at")
+	}
+	if done.Progress == nil || done.Progress.Phase != config.PhaseVolume {
+		t.Errorf("progress = %+v, want last phase %s", done.Progress, config.PhaseVolume)
+	}
+
+	rec = do(http.MethodPost, "/api/sheds?async=true", `{"name":"taken"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("create: status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatalf("failed to decode job: %v", err)
+	}
+	failed := waitJob(job.ID)
+	if failed.Status != config.JobFailed || failed.Error == nil || failed.Error.Code != config.ErrShedAlreadyExists {
+		t.Errorf("failed job = %+v, want failed with %s", failed, config.ErrShedAlreadyExists)
+	}
+
+	// Invalid requests are rejected before a job starts
+	if rec := do(http.MethodPost, "/api/sheds?async=true", `{"name":"Bad Name"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid create: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = do(http.MethodGet, "/api/jobs", "")
+	var list config.JobsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode jobs: %v", err)
+	}
+	if len(list.Jobs) != 2 {
+		t.Errorf("len(jobs) = %d, want 2", len(list.Jobs))
+	}
+
+	if rec := do(http.MethodGet, "/api/jobs/nope", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing job: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestJobStorePrune(t *testing.T) {
+	js := newJobStore()
+	now := time.Now()
+	js.now = func() time.Time { return now }
+
+	old := js.start(config.JobTypeCreate, "old")
+	js.finish(old.ID, &config.Shed{Name: "old"}, nil)
+	running := js.start(config.JobTypeCreate, "running")
+
+	now = now.Add(jobRetention + time.Minute)
+	if _, ok := js.get(old.ID); ok {
+		t.Error("finished job was kept past its retention")
+	}
+	if _, ok := js.get(running.ID); !ok {
+		t.Error("running job was pruned")
+	}
+}
+
+func TestWaitForJobs(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	job := srv.startCreateJob(context.Background(), config.CreateShedRequest{Name: "dev"}, func() {})
+	if err := srv.WaitForJobs(context.Background()); err != nil {
+		t.Fatalf("WaitForJobs() error = %v", err)
+	}
+	if got, _ := srv.jobs.get(job.ID); got.Status != config.JobSucceeded {
+		t.Errorf("job status = %q, want %q", got.Status, config.JobSucceeded)
+	}
+
+	// A job that outlasts the shutdown is canceled
+	job = srv.startCreateJob(context.Background(), config.CreateShedRequest{Name: "slow", Image: "slow:latest"}, func() {})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := srv.WaitForJobs(ctx); err == nil {
+		t.Fatal("WaitForJobs() succeeded with a job still running")
+	}
+	if got, _ := srv.jobs.get(job.ID); got.Status != config.JobFailed {
+		t.Errorf("job status = %q, want %q", got.Status, config.JobFailed)
+	}
+}
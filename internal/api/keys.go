@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/charliek/shed/internal/authkeys"
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleListKeys returns the SSH keys registered through the API.
+// GET /api/keys
+func (s *Server) handleListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.keys.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config.AuthorizedKeysResponse{Keys: keys})
+}
+
+// handleAddKey registers a public key for SSH connections. Keys can only be
+// registered once API tokens are configured, or anyone who can reach the API
+// could let themselves in over SSH.
+// POST /api/keys
+func (s *Server) handleAddKey(w http.ResponseWriter, r *http.Request) {
+	if !s.AuthEnabled() {
+		writeError(w, http.StatusForbidden, config.ErrForbidden, "registering SSH keys requires an API token; create one with 'shed-server token create <name>'")
+		return
+	}
+
+	var req config.AddAuthorizedKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var errs config.ValidationErrors
+	errs.Check("name", config.ValidateKeyName(req.Name))
+	if req.Shed != "" {
+		errs.Check("shed", config.ValidateShedName(req.Shed))
+	}
+	if _, _, _, _, err := gossh.ParseAuthorizedKey([]byte(req.PublicKey)); err != nil {
+		errs.Check("public_key", errors.New("must be a public key in authorized_keys format"))
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	key, err := s.keys.Add(req.Name, req.Shed, req.PublicKey)
+	if err != nil {
+		if errors.Is(err, authkeys.ErrExists) {
+			writeError(w, http.StatusConflict, config.ErrKeyExists, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, key)
+}
+
+// handleRemoveKey unregisters a public key.
+// DELETE /api/keys/{name}
+func (s *Server) handleRemoveKey(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if err := s.keys.Remove(name); err != nil {
+		if errors.Is(err, authkeys.ErrNotFound) {
+			writeError(w, http.StatusNotFound, config.ErrKeyNotFound, "key \""+name+"\" not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+const testPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl me@laptop"
+
+func TestHandleKeys(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.APITokens = []config.APIToken{{Name: "laptop", Token: "s3cret"}}
+	router := NewServer(newFakeDocker(), cfg, nil).Router()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/keys", `{"name":"laptop","public_key":"`+testPublicKey+`"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var added config.AuthorizedKey
+	if err := json.NewDecoder(rec.Body).Decode(&added); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(added.Fingerprint, "SHA256:") {
+		t.Errorf("Fingerprint = %q, want SHA256 fingerprint", added.Fingerprint)
+	}
+
+	if rec := do(http.MethodPost, "/api/keys", `{"name":"laptop","public_key":"`+testPublicKey+`"}`); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate add status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if rec := do(http.MethodPost, "/api/keys", `{"name":"other","public_key":"garbage"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid key status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = do(http.MethodGet, "/api/keys", "")
+	var list config.AuthorizedKeysResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Keys) != 1 || list.Keys[0].Name != "laptop" {
+		t.Errorf("Keys = %+v, want [laptop]", list.Keys)
+	}
+
+	if rec := do(http.MethodDelete, "/api/keys/laptop", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("remove status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := do(http.MethodDelete, "/api/keys/laptop", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second remove status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleAddKeyWithoutTokens(t *testing.T) {
+	router := NewServer(newFakeDocker(), testConfig(t), nil).Router()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/keys", strings.NewReader(`{"name":"laptop","public_key":"`+testPublicKey+`"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("add status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/keys", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var list config.AuthorizedKeysResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Keys) != 0 {
+		t.Errorf("Keys = %+v, want none", list.Keys)
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleUpdateLabels sets and removes a shed's user-defined labels.
+// PATCH /api/sheds/{name}/labels
+func (s *Server) handleUpdateLabels(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req config.UpdateLabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	shed, err := s.docker.UpdateLabels(r.Context(), name, req)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shed)
+}
+
+// parseLabelSelector reads the labels a listing must match from repeated
+// label=key=value query parameters.
+func parseLabelSelector(r *http.Request) (map[string]string, config.ValidationErrors) {
+	var errs config.ValidationErrors
+	want := make(map[string]string)
+	for i, spec := range r.URL.Query()["label"] {
+		key, value, err := config.ParseLabel(spec)
+		if err != nil {
+			errs.Check(fmt.Sprintf("label[%d]", i), err)
+			continue
+		}
+		want[key] = value
+	}
+	return want, errs
+}
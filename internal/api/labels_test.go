@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleListShedsByLabel(t *testing.T) {
+	docker := newFakeDocker(
+		config.Shed{Name: "api", Labels: map[string]string{"team": "payments", "env": "dev"}},
+		config.Shed{Name: "web", Labels: map[string]string{"team": "payments", "env": "prod"}},
+		config.Shed{Name: "scratch"},
+	)
+	srv := NewServer(docker, testConfig(t), nil)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantCount int
+	}{
+		{"no selector", "", http.StatusOK, 3},
+		{"one label", "?label=team=payments", http.StatusOK, 2},
+		{"all labels", "?label=team=payments&label=env=prod", http.StatusOK, 1},
+		{"no match", "?label=team=billing", http.StatusOK, 0},
+		{"missing value", "?label=team", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/sheds"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp config.ShedsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Sheds) != tt.wantCount {
+				t.Errorf("len(sheds) = %d, want %d", len(resp.Sheds), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateLabels(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Labels: map[string]string{"team": "payments", "env": "dev"}})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	tests := []struct {
+		name     string
+		shed     string
+		body     string
+		wantCode int
+		want     string
+	}{
+		{"set and remove", "dev", `{"set":{"owner":"sam"},"remove":["env"]}`, http.StatusOK, "owner=sam,team=payments"},
+		{"nothing to do", "dev", `{}`, http.StatusBadRequest, ""},
+		{"invalid key", "dev", `{"set":{"bad key":"x"}}`, http.StatusBadRequest, ""},
+		{"unknown shed", "nope", `{"set":{"team":"x"}}`, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/api/sheds/"+tt.shed+"/labels", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var shed config.Shed
+			if err := json.NewDecoder(rec.Body).Decode(&shed); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got := config.FormatLabels(shed.Labels); got != tt.want {
+				t.Errorf("labels = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
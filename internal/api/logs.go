@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleGetShedLogs handles GET /api/sheds/{name}/logs.
+// Output is streamed as plain text; with follow=true the response stays
+// open until the client disconnects or the container stops.
+func (s *Server) handleGetShedLogs(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	opts := config.LogOptions{
+		Follow: r.URL.Query().Get("follow") == "true",
+		Tail:   -1,
+	}
+
+	if v := r.URL.Query().Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			var errs config.ValidationErrors
+			errs.Add("tail", config.FieldInvalid, "tail must be a non-negative number of lines")
+			writeValidationError(w, errs)
+			return
+		}
+		opts.Tail = n
+	}
+
+	logs, err := s.docker.ShedLogs(r.Context(), name, opts)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			// The status is already sent, so any error just ends the stream
+			return
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleGetShedLogs(t *testing.T) {
+	srv := NewServer(newFakeDocker(config.Shed{Name: "widget"}), testConfig(t), nil)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"all lines", "/api/sheds/widget/logs", http.StatusOK, "one\ntwo\nthree\n"},
+		{"tail", "/api/sheds/widget/logs?tail=2", http.StatusOK, "two\nthree\n"},
+		{"bad tail", "/api/sheds/widget/logs?tail=-1", http.StatusBadRequest, ""},
+		{"missing shed", "/api/sheds/nope/logs", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -1,7 +1,21 @@
 package api
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/charliek/shed/internal/apitoken"
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/version"
+	"github.com/charliek/shed/internal/websocket"
 )
 
 // ContentTypeJSON is middleware that sets the Content-Type header to application/json
@@ -12,3 +26,142 @@ func ContentTypeJSON(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequestLogger is middleware that logs each request once it completes.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		defer func() {
+			slog.Info("HTTP request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", ww.Status(),
+				"bytes", ww.BytesWritten(),
+				"duration", time.Since(start),
+				"remote", r.RemoteAddr,
+				"request_id", middleware.GetReqID(r.Context()),
+			)
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// realIP is middleware that replaces a request's remote address with the
+// client address a trusted proxy reports in X-Forwarded-For or X-Real-IP.
+// Requests from anywhere else keep their connection's address, so clients
+// can't choose the address they are rate limited and logged under.
+func (s *Server) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if client, ok := s.forwardedClient(r); ok {
+			r.RemoteAddr = net.JoinHostPort(client.String(), "0")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClient returns the client address forwarded by a trusted proxy.
+// X-Forwarded-For is read from the right, skipping the trusted proxies
+// that appended to it, since anything further left was sent by the client.
+func (s *Server) forwardedClient(r *http.Request) (netip.Addr, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !s.trustedProxy(peer.Addr()) {
+		return netip.Addr{}, false
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		if !s.trustedProxy(addr) {
+			return addr.Unmap(), true
+		}
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// trustedProxy reports whether addr is one of the configured proxies.
+func (s *Server) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range s.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIVersion is middleware that advertises the server's API version and
+// rejects CLIs older than version.MinClientVersion. Clients that don't send
+// a version, or send one that can't be compared such as a dev build, are
+// let through.
+func APIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(config.HeaderAPIVersion, version.APIVersion)
+		w.Header().Set(config.HeaderMinClientVersion, version.MinClientVersion)
+
+		client := r.Header.Get(config.HeaderClientVersion)
+		if cmp, ok := version.Compare(client, version.MinClientVersion); ok && cmp < 0 {
+			writeError(w, http.StatusUpgradeRequired, config.ErrClientTooOld,
+				fmt.Sprintf("shed %s is too old for this server; version %s or newer is required", client, version.MinClientVersion))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireToken is middleware that rejects requests without a valid bearer
+// token. Requests are let through unchecked until the store has a token, so
+// servers without tokens stay open as before. Browsers can't set headers on
+// WebSocket requests, so those may pass the token as ?access_token instead.
+func RequireToken(tokens *apitoken.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !tokens.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok && websocket.IsUpgrade(r) {
+				token = r.URL.Query().Get("access_token")
+				ok = token != ""
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, config.ErrUnauthorized, "missing API token")
+				return
+			}
+			name, ok := tokens.Verify(strings.TrimSpace(token))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, config.ErrUnauthorized, "invalid API token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenNameKey{}, name)))
+		})
+	}
+}
+
+// tokenNameKey is the context key for the name of a request's API token.
+type tokenNameKey struct{}
+
+// tokenName returns the name of the API token RequireToken accepted for a
+// request, or "" if the request wasn't checked.
+func tokenName(ctx context.Context) string {
+	name, _ := ctx.Value(tokenNameKey{}).(string)
+	return name
+}
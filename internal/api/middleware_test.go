@@ -0,0 +1,140 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/version"
+)
+
+func TestRequireToken(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.APITokens = []config.APIToken{{Name: "laptop", Token: "s3cret"}}
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized},
+		{"valid token", "Bearer s3cret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/sheds", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireTokenOpenWithoutTokens(t *testing.T) {
+	cfg := testConfig(t)
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sheds", nil)
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestAPIVersion(t *testing.T) {
+	cfg := testConfig(t)
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	orig := version.MinClientVersion
+	version.MinClientVersion = "0.5.0"
+	defer func() { version.MinClientVersion = orig }()
+
+	tests := []struct {
+		name   string
+		path   string
+		client string
+		want   int
+	}{
+		{"versioned path", "/api/v1/sheds", "", http.StatusOK},
+		{"unversioned alias", "/api/sheds", "", http.StatusOK},
+		{"current client", "/api/v1/sheds", "v0.5.0-2-gabc1234", http.StatusOK},
+		{"dev client", "/api/v1/sheds", "dev", http.StatusOK},
+		{"old client", "/api/v1/sheds", "v0.4.9", http.StatusUpgradeRequired},
+		{"unknown route", "/api/v1/nope", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.client != "" {
+				req.Header.Set(config.HeaderClientVersion, tt.client)
+			}
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			// Clients tell old servers apart by this header being missing
+			if got := rec.Header().Get(config.HeaderAPIVersion); got != version.APIVersion {
+				t.Errorf("%s = %q, want %q", config.HeaderAPIVersion, got, version.APIVersion)
+			}
+		})
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1"}
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{"direct client", "198.51.100.7:4000", "", "", "198.51.100.7:4000"},
+		{"untrusted forwarder", "198.51.100.7:4000", "203.0.113.9", "203.0.113.9", "198.51.100.7:4000"},
+		{"trusted proxy", "192.0.2.1:4000", "203.0.113.9", "", "203.0.113.9:0"},
+		{"spoofed hop before proxy", "192.0.2.1:4000", "1.2.3.4, 203.0.113.9", "", "203.0.113.9:0"},
+		{"proxy chain", "192.0.2.1:4000", "203.0.113.9, 10.1.2.3", "", "203.0.113.9:0"},
+		{"real ip header", "192.0.2.1:4000", "", "203.0.113.9", "203.0.113.9:0"},
+		{"garbage", "192.0.2.1:4000", "not-an-ip", "", "192.0.2.1:4000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			var got string
+			srv.realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handlePrune removes shed resources nothing uses, or reports them for a
+// dry run.
+// POST /api/prune
+func (s *Server) handlePrune(w http.ResponseWriter, r *http.Request) {
+	// The body is optional; an empty one prunes with the defaults
+	var req config.PruneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	resp, err := s.docker.Prune(r.Context(), req)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestPrune(t *testing.T) {
+	docker := newFakeDocker(
+		config.Shed{Name: "old", Status: config.StatusStopped},
+		config.Shed{Name: "dev", Status: config.StatusRunning},
+	)
+	srv := NewServer(docker, testConfig(t), nil)
+
+	prune := func(body string) (*httptest.ResponseRecorder, config.PruneResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/prune", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		var resp config.PruneResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return rec, resp
+	}
+
+	if rec, _ := prune(`{"stopped_days":-1}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("negative stopped_days: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec, resp := prune(`{"dry_run":true,"stopped_days":30}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if !resp.DryRun || len(resp.Resources) != 1 || resp.Resources[0].Name != "old" {
+		t.Errorf("dry run = %+v, want only old", resp)
+	}
+	if _, err := docker.GetShed(t.Context(), "old"); err != nil {
+		t.Errorf("dry run removed old: %v", err)
+	}
+
+	// An empty body prunes with the defaults, which keep stopped sheds
+	if rec, resp := prune(""); rec.Code != http.StatusOK || len(resp.Resources) != 0 {
+		t.Errorf("empty body: status = %d, resources = %+v", rec.Code, resp.Resources)
+	}
+
+	if _, resp := prune(`{"stopped_days":30}`); resp.DryRun || len(resp.Resources) != 1 {
+		t.Errorf("prune = %+v, want old removed", resp)
+	}
+	if _, err := docker.GetShed(t.Context(), "old"); err == nil {
+		t.Error("old should have been removed")
+	}
+}
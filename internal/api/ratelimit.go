@@ -0,0 +1,139 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// createRetryAfter is the Retry-After sent when too many creates are in
+// progress. Creates take a while, so there is no exact time to give.
+const createRetryAfter = 10 * time.Second
+
+// maxRateLimitClients is the number of clients tracked before idle ones
+// are dropped.
+const maxRateLimitClients = 10000
+
+// rateLimiter is a token bucket per client: each client may make up to
+// burst requests at once, refilled at rate requests per second.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing perMinute requests a minute
+// per client with the given burst.
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a request from key's bucket. If the bucket is empty it
+// returns false and how long until a request is allowed.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitClients {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// prune drops clients whose buckets have refilled, since they are
+// indistinguishable from new clients.
+func (l *rateLimiter) prune(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// limitRequests is middleware that rejects clients making requests faster
+// than the configured rate. Clients are told apart by API token, or by
+// address when the server has no tokens.
+func (s *Server) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := "token:" + tokenName(r.Context())
+		if !s.tokens.Enabled() {
+			key = "addr:" + clientHost(r)
+		}
+		if ok, wait := s.limiter.allow(key); !ok {
+			writeTooManyRequests(w, wait, "too many requests; slow down")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// beginCreate reserves one of the server's concurrent create slots. If all
+// are taken it writes a 429 and returns false; otherwise the caller must
+// call the returned function once the create finishes.
+func (s *Server) beginCreate(w http.ResponseWriter) (func(), bool) {
+	if s.creates == nil {
+		return func() {}, true
+	}
+	select {
+	case s.creates <- struct{}{}:
+		return func() { <-s.creates }, true
+	default:
+		writeTooManyRequests(w, createRetryAfter,
+			"too many sheds are being created; max_concurrent_creates is "+strconv.Itoa(cap(s.creates)))
+		return nil, false
+	}
+}
+
+// writeTooManyRequests writes a 429 telling the client when to retry.
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	writeError(w, http.StatusTooManyRequests, config.ErrRateLimited, message)
+}
+
+// clientHost returns the host part of a request's remote address.
+func clientHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
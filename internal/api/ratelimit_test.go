@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	l := newRateLimiter(60, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a"); !ok {
+			t.Fatalf("request %d within burst was rejected", i+1)
+		}
+	}
+	ok, wait := l.allow("a")
+	if ok {
+		t.Fatal("request past burst was allowed")
+	}
+	if wait != time.Second {
+		t.Errorf("wait = %s, want 1s", wait)
+	}
+	if ok, _ := l.allow("b"); !ok {
+		t.Error("other client was rejected")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.allow("a"); !ok {
+		t.Error("request after refill was rejected")
+	}
+}
+
+func TestLimitRequests(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.APITokens = []config.APIToken{{Name: "laptop", Token: "s3cret"}, {Name: "ci", Token: "t0ken"}}
+	cfg.RateLimit = config.RateLimitConfig{RequestsPerMinute: 1}
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/sheds", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("s3cret"); rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	rec := get("s3cret")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("429 has no Retry-After header")
+	}
+	if !strings.Contains(rec.Body.String(), config.ErrRateLimited) {
+		t.Errorf("body = %s, want %s", rec.Body.String(), config.ErrRateLimited)
+	}
+	if rec := get("t0ken"); rec.Code != http.StatusOK {
+		t.Errorf("other token status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestMaxConcurrentCreates(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RateLimit = config.RateLimitConfig{MaxConcurrentCreates: 1}
+	srv := NewServer(newFakeDocker(), cfg, nil)
+
+	create := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sheds", strings.NewReader(`{"name":"`+name+`"}`))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Hold the only slot as if another create were running
+	done, ok := srv.beginCreate(httptest.NewRecorder())
+	if !ok {
+		t.Fatal("beginCreate() = false with a free slot")
+	}
+	rec := create("widget")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusTooManyRequests, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") != "10" {
+		t.Errorf("Retry-After = %q, want 10", rec.Header().Get("Retry-After"))
+	}
+
+	done()
+	if rec := create("widget"); rec.Code != http.StatusCreated {
+		t.Errorf("status after slot freed = %d, want %d", rec.Code, http.StatusCreated)
+	}
+}
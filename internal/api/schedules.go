@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleListSchedules returns the schedules of every shed.
+// GET /api/schedules
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	s.listSchedules(w, r, "")
+}
+
+// handleListShedSchedules returns a shed's schedules.
+// GET /api/sheds/{name}/schedules
+func (s *Server) handleListShedSchedules(w http.ResponseWriter, r *http.Request) {
+	s.listSchedules(w, r, chi.URLParam(r, "name"))
+}
+
+// listSchedules writes the schedules of a shed, or of every shed if name is
+// empty.
+func (s *Server) listSchedules(w http.ResponseWriter, r *http.Request, name string) {
+	schedules, err := s.docker.ListSchedules(r.Context(), name)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config.SchedulesResponse{Schedules: schedules})
+}
+
+// handleSetSchedule schedules a shed to start, stop, or be backed up,
+// replacing any schedule it had for the action.
+// PUT /api/sheds/{name}/schedules/{action}
+func (s *Server) handleSetSchedule(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	action := chi.URLParam(r, "action")
+
+	var req config.SetScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	errs := req.Validate()
+	errs.Check("action", config.ValidateScheduleAction(action))
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	schedule, err := s.docker.SetSchedule(r.Context(), name, action, req.Cron)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, schedule)
+}
+
+// handleDeleteSchedule removes a shed's schedule for an action.
+// DELETE /api/sheds/{name}/schedules/{action}
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	action := chi.URLParam(r, "action")
+
+	if err := s.docker.DeleteSchedule(r.Context(), name, action); err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
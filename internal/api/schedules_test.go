@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestSchedules(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPut, "/api/sheds/dev/schedules/stop", `{"cron":"0 19 * * 1-5"}`); rec.Code != http.StatusOK {
+		t.Fatalf("set: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec := do(http.MethodGet, "/api/schedules", "")
+	var resp config.SchedulesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Schedules) != 1 || resp.Schedules[0].Cron != "0 19 * * 1-5" || resp.Schedules[0].Shed != "dev" {
+		t.Errorf("schedules = %+v, want dev's stop schedule", resp.Schedules)
+	}
+
+	if rec := do(http.MethodDelete, "/api/sheds/dev/schedules/stop", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"bad cron", http.MethodPut, "/api/sheds/dev/schedules/stop", `{"cron":"every evening"}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"no cron", http.MethodPut, "/api/sheds/dev/schedules/stop", `{}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"bad action", http.MethodPut, "/api/sheds/dev/schedules/pause", `{"cron":"@daily"}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"missing shed", http.MethodPut, "/api/sheds/nope/schedules/stop", `{"cron":"@daily"}`, http.StatusNotFound, config.ErrShedNotFound},
+		{"delete missing", http.MethodDelete, "/api/sheds/dev/schedules/start", "", http.StatusNotFound, config.ErrScheduleNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			var resp config.APIError
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error.Code != tt.code {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// searchFields maps searchable field names to accessors on a shed.
+var searchFields = map[string]func(config.Shed) []string{
+	config.SearchFieldName: func(s config.Shed) []string { return []string{s.Name} },
+	config.SearchFieldRepo: func(s config.Shed) []string { return []string{s.Repo} },
+	// Labels match as key=value, so a query can name the key, the value,
+	// or both
+	config.SearchFieldLabels: func(s config.Shed) []string {
+		labels := make([]string, 0, len(s.Labels))
+		for _, k := range config.SortedLabelKeys(s.Labels) {
+			labels = append(labels, k+"="+s.Labels[k])
+		}
+		return labels
+	},
+}
+
+// handleSearch returns sheds whose metadata matches a query.
+// GET /api/search?q=string&fields=name,repo,labels
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "query parameter q is required")
+		return
+	}
+
+	fields, err := parseSearchFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, err.Error())
+		return
+	}
+
+	sheds, err := s.docker.ListSheds(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrDockerError, err.Error())
+		return
+	}
+
+	matches := make([]config.Shed, 0)
+	for _, shed := range sheds {
+		if matchShed(shed, query, fields) {
+			matches = append(matches, shed)
+		}
+	}
+
+	resp := config.ShedsResponse{
+		Sheds: matches,
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseSearchFields parses a comma-separated field list. A list with no
+// fields in it, such as "" or ",", searches all fields.
+func parseSearchFields(raw string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if _, ok := searchFields[field]; !ok {
+			return nil, fmt.Errorf("unknown search field %q: must be one of %s",
+				field, strings.Join(config.SearchFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return config.SearchFields, nil
+	}
+	return fields, nil
+}
+
+// matchShed reports whether any of the given fields of a shed contains the
+// query, ignoring case.
+func matchShed(shed config.Shed, query string, fields []string) bool {
+	query = strings.ToLower(query)
+	for _, field := range fields {
+		for _, value := range searchFields[field](shed) {
+			if strings.Contains(strings.ToLower(value), query) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleSearch(t *testing.T) {
+	docker := newFakeDocker(
+		config.Shed{Name: "payments-api", Repo: "git@github.com:acme/payments.git"},
+		config.Shed{Name: "billing", Repo: "git@github.com:acme/Payments-UI.git"},
+		config.Shed{Name: "scratch", Labels: map[string]string{"team": "payments", "tier": "dev"}},
+	)
+	srv := NewServer(docker, testConfig(t), nil)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantCount int
+	}{
+		{"all fields", "?q=payments", http.StatusOK, 3},
+		{"empty field list", "?q=payments&fields=,%20", http.StatusOK, 3},
+		{"labels only", "?q=payments&fields=labels", http.StatusOK, 1},
+		{"label key and value", "?q=tier=dev&fields=labels", http.StatusOK, 1},
+		{"label key", "?q=TEAM&fields=labels", http.StatusOK, 1},
+		{"name only", "?q=payments&fields=name", http.StatusOK, 1},
+		{"repo only", "?q=PAYMENTS&fields=repo", http.StatusOK, 2},
+		{"no match", "?q=nothing", http.StatusOK, 0},
+		{"missing query", "", http.StatusBadRequest, 0},
+		{"unknown field", "?q=x&fields=owner", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/search"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp config.ShedsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Sheds) != tt.wantCount {
+				t.Errorf("len(sheds) = %d, want %d", len(resp.Sheds), tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleListSecrets returns the names of all secrets, without their values.
+// GET /api/secrets
+func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request) {
+	list, err := s.secrets.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config.SecretsResponse{Secrets: list})
+}
+
+// handleGetSecret returns a secret with its value.
+// GET /api/secrets/{name}
+func (s *Server) handleGetSecret(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if !s.secrets.Exists(name) {
+		writeError(w, http.StatusNotFound, config.ErrSecretNotFound, "secret \""+name+"\" not found")
+		return
+	}
+
+	secret, err := s.secrets.Get(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, secret)
+}
+
+// handleSetSecret creates or replaces a secret.
+// PUT /api/secrets/{name}
+func (s *Server) handleSetSecret(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req config.SetSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var errs config.ValidationErrors
+	errs.Check("name", config.ValidateSecretName(name))
+	errs.Check("value", config.ValidateSecretValue(req.Value))
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	secret, err := s.secrets.Set(name, req.Value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, secret)
+}
+
+// handleDeleteSecret removes a secret.
+// DELETE /api/secrets/{name}
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if !s.secrets.Exists(name) {
+		writeError(w, http.StatusNotFound, config.ErrSecretNotFound, "secret \""+name+"\" not found")
+		return
+	}
+
+	if err := s.secrets.Delete(name); err != nil {
+		writeError(w, http.StatusInternalServerError, config.ErrInternalError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkSecretsExist reports a validation error for each secret a shed
+// refers to that doesn't exist.
+func (s *Server) checkSecretsExist(refs []config.SecretRef) config.ValidationErrors {
+	var errs config.ValidationErrors
+	for i, ref := range refs {
+		if config.ValidateSecretName(ref.Name) == nil && !s.secrets.Exists(ref.Name) {
+			errs.Add(fmt.Sprintf("secrets[%d].name", i), config.FieldNotFound, "secret \""+ref.Name+"\" not found")
+		}
+	}
+	return errs
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestSecretsLifecycle(t *testing.T) {
+	srv := NewServer(newFakeDocker(), testConfig(t), nil)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPut, "/api/secrets/github-token", `{"value":"ghp_example"}`); rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPut, "/api/secrets/bad%20name", `{"value":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT invalid name status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec := do(http.MethodGet, "/api/secrets", "")
+	if strings.Contains(rec.Body.String(), "ghp_example") {
+		t.Errorf("list includes secret values: %s", rec.Body.String())
+	}
+	var list config.SecretsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Secrets) != 1 || list.Secrets[0].Name != "github-token" {
+		t.Errorf("secrets = %+v, want github-token", list.Secrets)
+	}
+
+	rec = do(http.MethodGet, "/api/secrets/github-token", "")
+	var secret config.Secret
+	if err := json.NewDecoder(rec.Body).Decode(&secret); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if secret.Value != "ghp_example" {
+		t.Errorf("value = %q, want ghp_example", secret.Value)
+	}
+
+	if rec := do(http.MethodDelete, "/api/secrets/github-token", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := do(http.MethodGet, "/api/secrets/github-token", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET after delete status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
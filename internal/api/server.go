@@ -3,8 +3,16 @@ package api
 
 import (
 	"context"
+	"io"
+	"net/netip"
 
+	"github.com/charliek/shed/internal/apitoken"
+	"github.com/charliek/shed/internal/audit"
+	"github.com/charliek/shed/internal/authkeys"
 	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/deploykey"
+	"github.com/charliek/shed/internal/secrets"
+	"github.com/charliek/shed/internal/version"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -18,8 +26,16 @@ type DockerClient interface {
 	// GetShed returns a single shed by name.
 	GetShed(ctx context.Context, name string) (*config.Shed, error)
 
-	// CreateShed creates a new shed container.
-	CreateShed(ctx context.Context, req config.CreateShedRequest) (*config.Shed, error)
+	// GetShedDetails returns a shed with its container details filled in.
+	GetShedDetails(ctx context.Context, name string) (*config.Shed, error)
+
+	// CreateShed creates a new shed container, reporting each phase to
+	// progress if it is non-nil.
+	CreateShed(ctx context.Context, req config.CreateShedRequest, progress config.ProgressFunc) (*config.Shed, error)
+
+	// IdempotentShed returns the name of the shed a create with the
+	// idempotency key made, if the key is remembered.
+	IdempotentShed(key string) (string, bool)
 
 	// DeleteShed removes a shed container and optionally its volume.
 	DeleteShed(ctx context.Context, name string, keepVolume bool) error
@@ -29,22 +45,213 @@ type DockerClient interface {
 
 	// StopShed stops a running shed container.
 	StopShed(ctx context.Context, name string) (*config.Shed, error)
+
+	// PauseShed freezes a running shed's processes.
+	PauseShed(ctx context.Context, name string) (*config.Shed, error)
+
+	// ResumeShed unfreezes a paused shed's processes.
+	ResumeShed(ctx context.Context, name string) (*config.Shed, error)
+
+	// WaitForStatus blocks until a shed reaches the given status or ctx is done.
+	WaitForStatus(ctx context.Context, name, status string) (*config.Shed, error)
+
+	// ListSessions returns the sessions running inside a shed.
+	ListSessions(ctx context.Context, name string) ([]config.Session, error)
+
+	// CreateSession starts a detached session in a running shed.
+	CreateSession(ctx context.Context, name string, req config.CreateSessionRequest) (*config.Session, error)
+
+	// RenameSession renames a session in a running shed.
+	RenameSession(ctx context.Context, name, session string, req config.RenameSessionRequest) (*config.Session, error)
+
+	// ListPorts returns the TCP ports processes in a running shed are
+	// listening on.
+	ListPorts(ctx context.Context, name string) ([]config.ListeningPort, error)
+
+	// ExecCommand runs a shell command in a running shed, copying its
+	// output to stdout and stderr, and returns its exit code. The command
+	// is killed if ctx ends first.
+	ExecCommand(ctx context.Context, name, command string, stdout, stderr io.Writer) (int, error)
+
+	// KillSession ends a session in a running shed.
+	KillSession(ctx context.Context, name, session string) error
+
+	// ExecInSession runs a command in a tmux session, creating it if
+	// needed, and waits for it to finish.
+	ExecInSession(ctx context.Context, name, session, command string) (*config.SessionExecResult, error)
+
+	// OpenTerminal starts an interactive shell with a TTY in a running shed.
+	OpenTerminal(ctx context.Context, name string, req config.TerminalRequest) (Terminal, error)
+
+	// GetShedStats returns resource usage and activity for a running shed.
+	// Session activity and workspace size are skipped unless activity is true.
+	GetShedStats(ctx context.Context, name string, activity bool) (*config.ShedStats, error)
+
+	// GetWorkspaceDiff returns uncommitted changes in a shed's workspace.
+	GetWorkspaceDiff(ctx context.Context, name string, full bool) (*config.WorkspaceDiff, error)
+
+	// ShedLogs returns a shed's container output. The caller must close it.
+	ShedLogs(ctx context.Context, name string, opts config.LogOptions) (io.ReadCloser, error)
+
+	// ExportWorkspace returns a tar archive of a shed's workspace. The
+	// caller must close it.
+	ExportWorkspace(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// ImportShed creates a shed with its workspace restored from a tar
+	// archive produced by ExportWorkspace.
+	ImportShed(ctx context.Context, req config.CreateShedRequest, archive io.Reader) (*config.Shed, error)
+
+	// CloneShed creates dst as a copy of src's workspace and settings.
+	CloneShed(ctx context.Context, src, dst string) (*config.Shed, error)
+
+	// UpdateLabels sets and removes a shed's user-defined labels.
+	UpdateLabels(ctx context.Context, name string, req config.UpdateLabelsRequest) (*config.Shed, error)
+
+	// UpdateEnv sets and removes a shed's environment variables.
+	UpdateEnv(ctx context.Context, name string, req config.UpdateEnvRequest) (*config.Shed, error)
+
+	// SubscribeEvents returns a channel of shed events published from now
+	// on, and a function that ends the subscription.
+	SubscribeEvents() (<-chan config.ShedEvent, func())
+
+	// ListImages returns the images sheds can be created from.
+	ListImages(ctx context.Context) ([]config.Image, error)
+	// RebuildShed recreates a shed's container, keeping its workspace.
+	RebuildShed(ctx context.Context, name string, req config.RebuildShedRequest, progress config.ProgressFunc) (*config.Shed, error)
+	// BuildImage builds an image from a tar build context, passing build
+	// output to output as it arrives.
+	BuildImage(ctx context.Context, req config.BuildImageRequest, buildContext io.Reader, output func(string)) (*config.Image, error)
+
+	// PrepullStatus returns the results of scheduled image pulls, or nil
+	// if pre-pulling is disabled.
+	PrepullStatus() []config.ImagePullStatus
+
+	// ImageGCStatus returns the results of image collection, or nil if
+	// collection is disabled.
+	ImageGCStatus() *config.ImageGCStatus
+
+	// DockerHost returns the address of the engine that runs sheds.
+	DockerHost() string
+
+	// Capabilities reports optional features the runtime supports.
+	Capabilities(ctx context.Context) config.ServerCapabilities
+
+	// GetCapacity reports the host's resources, shed counts, and features.
+	GetCapacity(ctx context.Context) (*config.ServerCapacity, error)
+
+	// CreateCheckpoint saves a running shed's process state.
+	CreateCheckpoint(ctx context.Context, shedName, name string, leaveRunning bool) (*config.Checkpoint, error)
+
+	// ListCheckpoints returns the checkpoints saved for a shed.
+	ListCheckpoints(ctx context.Context, shedName string) ([]config.Checkpoint, error)
+
+	// DeleteCheckpoint removes a saved checkpoint.
+	DeleteCheckpoint(ctx context.Context, shedName, name string) error
+
+	// RestoreShed starts a stopped shed from a checkpoint.
+	RestoreShed(ctx context.Context, shedName, name string) (*config.Shed, error)
+
+	// Prune removes shed resources nothing uses, or only reports them if
+	// req.DryRun is set.
+	Prune(ctx context.Context, req config.PruneRequest) (*config.PruneResponse, error)
+
+	// ListSchedules returns the schedules of a shed, or of every shed if
+	// name is empty.
+	ListSchedules(ctx context.Context, name string) ([]config.Schedule, error)
+
+	// SetSchedule schedules a shed to start, stop, or be backed up at the
+	// times a cron expression matches.
+	SetSchedule(ctx context.Context, name, action, expr string) (*config.Schedule, error)
+
+	// DeleteSchedule removes a shed's schedule for an action.
+	DeleteSchedule(ctx context.Context, name, action string) error
+
+	// BackupShed uploads an archive of a shed's workspace to the backup
+	// bucket.
+	BackupShed(ctx context.Context, name string) (*config.Backup, error)
+
+	// ListBackups returns a shed's backups, newest first.
+	ListBackups(ctx context.Context, name string) ([]config.Backup, error)
+
+	// RestoreBackup replaces a shed's workspace with one of its backups,
+	// stopping the shed first and starting it afterward.
+	RestoreBackup(ctx context.Context, name, backup string) (*config.Shed, error)
+}
+
+// Terminal is an interactive shell in a shed. Reads return its output,
+// ending with io.EOF when the shell exits, and writes are its input.
+type Terminal interface {
+	io.ReadWriteCloser
+
+	// Resize changes the terminal size.
+	Resize(ctx context.Context, cols, rows uint) error
+
+	// ExitCode returns the shell's exit code once its output has ended.
+	ExitCode(ctx context.Context) (int, error)
+}
+
+// HostKeys reports the SSH server's host keys.
+type HostKeys interface {
+	// HostPublicKeys returns the host key the SSH server presents and,
+	// while a rotation is in progress, the key that will replace it, both
+	// in authorized_keys format.
+	HostPublicKeys() (current, next string)
 }
 
 // Server is the HTTP API server for shed.
 type Server struct {
 	docker     DockerClient
 	cfg        *config.ServerConfig
-	sshHostKey string
+	hostKeys   HostKeys
+	deployKeys *deploykey.Store
+	secrets    *secrets.Store
+	tokens     *apitoken.Store
+	keys       *authkeys.Store
+	audit      *audit.Log
+
+	// limiter is nil when requests aren't rate limited, and creates is nil
+	// when concurrent creates aren't capped.
+	limiter *rateLimiter
+	creates chan struct{}
+
+	// cors is nil when only same-origin browser requests are allowed.
+	cors *corsPolicy
+
+	// trustedProxies are the peers whose forwarded client addresses are
+	// believed.
+	trustedProxies []netip.Prefix
+
+	jobs *jobStore
 }
 
 // NewServer creates a new API server.
-func NewServer(dockerClient DockerClient, cfg *config.ServerConfig, sshHostKey string) *Server {
-	return &Server{
+func NewServer(dockerClient DockerClient, cfg *config.ServerConfig, hostKeys HostKeys) *Server {
+	s := &Server{
 		docker:     dockerClient,
 		cfg:        cfg,
-		sshHostKey: sshHostKey,
+		hostKeys:   hostKeys,
+		deployKeys: deploykey.NewStore(cfg.DeployKeyDir),
+		secrets:    secrets.NewStore(cfg.SecretsDir, cfg.SecretsKeyFile),
+		tokens:     apitoken.NewStore(cfg.StateDir, cfg.APITokens),
+		keys:       authkeys.NewStore(cfg.StateDir, cfg.AuthorizedKeys),
+		audit:      audit.Open(cfg.StateDir),
+		cors:       newCORSPolicy(cfg.CORSAllowedOrigins),
+		jobs:       newJobStore(),
+	}
+	// Validate has rejected invalid ranges
+	s.trustedProxies, _ = cfg.TrustedProxyPrefixes()
+	if cfg.RateLimit.RequestsPerMinute > 0 {
+		s.limiter = newRateLimiter(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.BurstSize())
 	}
+	if cfg.RateLimit.MaxConcurrentCreates > 0 {
+		s.creates = make(chan struct{}, cfg.RateLimit.MaxConcurrentCreates)
+	}
+	return s
+}
+
+// AuthEnabled reports whether requests must carry an API token.
+func (s *Server) AuthEnabled() bool {
+	return s.tokens.Enabled()
 }
 
 // Router returns a configured chi router with all API routes.
@@ -53,29 +260,134 @@ func (s *Server) Router() chi.Router {
 
 	// Middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(s.realIP)
+	r.Use(RequestLogger)
 	r.Use(middleware.Recoverer)
+	if s.cors != nil {
+		r.Use(s.cors.handler)
+	}
 	r.Use(ContentTypeJSON)
 
-	// API routes
-	r.Route("/api", func(r chi.Router) {
-		// Server info
-		r.Get("/info", s.handleGetInfo)
-		r.Get("/ssh-host-key", s.handleGetSSHHostKey)
-
-		// Sheds
-		r.Route("/sheds", func(r chi.Router) {
-			r.Get("/", s.handleListSheds)
-			r.Post("/", s.handleCreateShed)
-			r.Route("/{name}", func(r chi.Router) {
-				r.Get("/", s.handleGetShed)
-				r.Delete("/", s.handleDeleteShed)
-				r.Post("/start", s.handleStartShed)
-				r.Post("/stop", s.handleStopShed)
+	// Web UI
+	if s.cfg.Dashboard {
+		mountDashboard(r)
+	}
+
+	// API routes. The unversioned paths are kept as aliases for clients
+	// that predate versioning.
+	r.Route("/api/"+version.APIVersion, s.apiRoutes)
+	r.Route("/api", s.apiRoutes)
+
+	return r
+}
+
+// apiRoutes registers the API's routes on r.
+func (s *Server) apiRoutes(r chi.Router) {
+	r.Use(APIVersion)
+	r.Use(RequireToken(s.tokens))
+	r.Use(s.limitRequests)
+	r.Use(s.auditRequests)
+
+	// Server info
+	r.Get("/info", s.handleGetInfo)
+	r.Get("/ssh-host-key", s.handleGetSSHHostKey)
+	r.Get("/server/capacity", s.handleGetCapacity)
+	r.Get("/server/log-level", s.handleGetLogLevel)
+	r.Put("/server/log-level", s.handleSetLogLevel)
+
+	// Images
+	r.Get("/images", s.handleListImages)
+	r.Post("/images/build", s.handleBuildImage)
+
+	// Deploy keys
+	r.Route("/deploy-keys", func(r chi.Router) {
+		r.Get("/", s.handleListDeployKeys)
+		r.Post("/", s.handleCreateDeployKey)
+		r.Get("/{name}", s.handleGetDeployKey)
+		r.Delete("/{name}", s.handleDeleteDeployKey)
+	})
+
+	// Secrets
+	r.Route("/secrets", func(r chi.Router) {
+		r.Get("/", s.handleListSecrets)
+		r.Get("/{name}", s.handleGetSecret)
+		r.Put("/{name}", s.handleSetSecret)
+		r.Delete("/{name}", s.handleDeleteSecret)
+	})
+
+	// Authorized SSH keys
+	r.Route("/keys", func(r chi.Router) {
+		r.Get("/", s.handleListKeys)
+		r.Post("/", s.handleAddKey)
+		r.Delete("/{name}", s.handleRemoveKey)
+	})
+
+	// Search
+	r.Get("/search", s.handleSearch)
+
+	// Shed events
+	r.Get("/events", s.handleEvents)
+
+	// Audit log
+	r.Get("/audit", s.handleGetAudit)
+
+	// Batch operations
+	r.Post("/batch", s.handleBatch)
+
+	// Cleanup
+	r.Post("/prune", s.handlePrune)
+
+	// Scheduled starts and stops
+	r.Get("/schedules", s.handleListSchedules)
+
+	// Background jobs
+	r.Get("/jobs", s.handleListJobs)
+	r.Get("/jobs/{id}", s.handleGetJob)
+
+	// Sheds
+	r.Route("/sheds", func(r chi.Router) {
+		r.Get("/", s.handleListSheds)
+		r.Post("/", s.handleCreateShed)
+		r.Post("/import", s.handleImportShed)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Get("/", s.handleGetShed)
+			r.Delete("/", s.handleDeleteShed)
+			r.Post("/start", s.handleStartShed)
+			r.Post("/stop", s.handleStopShed)
+			r.Post("/pause", s.handlePauseShed)
+			r.Post("/resume", s.handleResumeShed)
+			r.Post("/rebuild", s.handleRebuildShed)
+			r.Get("/wait", s.handleWaitShed)
+			r.Post("/exec", s.handleExec)
+			r.Get("/sessions", s.handleListSessions)
+			r.Post("/sessions", s.handleCreateSession)
+			r.Patch("/sessions/{session}", s.handleRenameSession)
+			r.Delete("/sessions/{session}", s.handleKillSession)
+			r.Post("/sessions/{session}/exec", s.handleSessionExec)
+			r.Get("/ports", s.handleListPorts)
+			r.Get("/terminal", s.handleTerminal)
+			r.Get("/stats", s.handleGetShedStats)
+			r.Get("/diff", s.handleGetWorkspaceDiff)
+			r.Get("/logs", s.handleGetShedLogs)
+			r.Post("/export", s.handleExportShed)
+			r.Post("/clone", s.handleCloneShed)
+			r.Patch("/labels", s.handleUpdateLabels)
+			r.Patch("/env", s.handleUpdateEnv)
+			r.Route("/checkpoints", func(r chi.Router) {
+				r.Get("/", s.handleListCheckpoints)
+				r.Post("/", s.handleCreateCheckpoint)
+				r.Delete("/{checkpoint}", s.handleDeleteCheckpoint)
+			})
+			r.Route("/backups", func(r chi.Router) {
+				r.Get("/", s.handleListBackups)
+				r.Post("/", s.handleCreateBackup)
+			})
+			r.Post("/restore", s.handleRestoreBackup)
+			r.Route("/schedules", func(r chi.Router) {
+				r.Get("/", s.handleListShedSchedules)
+				r.Put("/{action}", s.handleSetSchedule)
+				r.Delete("/{action}", s.handleDeleteSchedule)
 			})
 		})
 	})
-
-	return r
 }
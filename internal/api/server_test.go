@@ -0,0 +1,630 @@
+package api
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/events"
+)
+
+// fakeDocker is an in-memory DockerClient for handler tests.
+type fakeDocker struct {
+	mu        sync.Mutex
+	sheds     map[string]*config.Shed
+	schedules map[string]map[string]string
+	backups   map[string][]config.Backup
+	sessions  map[string][]string
+	keys      map[string]string
+	events    *events.Bus
+}
+
+// testConfig returns the default server config with state kept in a temp
+// dir, so tests don't write to the real state dir.
+func testConfig(t *testing.T) *config.ServerConfig {
+	t.Helper()
+	cfg := config.DefaultServerConfig()
+	cfg.StateDir = t.TempDir()
+	cfg.SecretsDir = filepath.Join(cfg.StateDir, "secrets")
+	cfg.SecretsKeyFile = filepath.Join(cfg.StateDir, "secrets.key")
+	return cfg
+}
+
+func newFakeDocker(sheds ...config.Shed) *fakeDocker {
+	f := &fakeDocker{sheds: make(map[string]*config.Shed), events: events.NewBus()}
+	for i := range sheds {
+		f.sheds[sheds[i].Name] = &sheds[i]
+	}
+	return f
+}
+
+func (f *fakeDocker) ListSheds(ctx context.Context) ([]config.Shed, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sheds := make([]config.Shed, 0, len(f.sheds))
+	for _, shed := range f.sheds {
+		sheds = append(sheds, *shed)
+	}
+	return sheds, nil
+}
+
+func (f *fakeDocker) GetShed(ctx context.Context, name string) (*config.Shed, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	shed, ok := f.sheds[name]
+	if !ok {
+		return nil, fmt.Errorf("shed %q not found", name)
+	}
+	return shed, nil
+}
+
+func (f *fakeDocker) CreateShed(ctx context.Context, req config.CreateShedRequest, progress config.ProgressFunc) (*config.Shed, error) {
+	if req.Image == "missing:latest" {
+		return nil, fmt.Errorf("failed to pull image %s: it does not exist or the registry requires a login (manifest unknown: not found)", req.Image)
+	}
+	if req.Image == "slow:latest" {
+		// Never finishes on its own
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	progress.Report(config.PhaseVolume, config.ProgressStarted, "")
+	progress.Report(config.PhaseVolume, config.ProgressDone, "")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if name, ok := f.keys[req.IdempotencyKey]; ok {
+		if name != req.Name {
+			return nil, fmt.Errorf("idempotency key %q was already used to create shed %q", req.IdempotencyKey, name)
+		}
+		return f.sheds[name], nil
+	}
+	if _, ok := f.sheds[req.Name]; ok {
+		return nil, fmt.Errorf("shed %q already exists", req.Name)
+	}
+	shed := &config.Shed{Name: req.Name, Status: config.StatusRunning, Repo: req.Repo, Image: req.Image, Owner: req.Owner}
+	if !req.Resources.IsZero() {
+		shed.Resources = &req.Resources
+	}
+	shed.Mounts = req.Mounts
+	shed.Labels = req.Labels
+	f.sheds[req.Name] = shed
+	if req.IdempotencyKey != "" {
+		if f.keys == nil {
+			f.keys = make(map[string]string)
+		}
+		f.keys[req.IdempotencyKey] = req.Name
+	}
+	return shed, nil
+}
+
+func (f *fakeDocker) IdempotentShed(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name, ok := f.keys[key]
+	return name, ok
+}
+
+func (f *fakeDocker) DeleteShed(ctx context.Context, name string, keepVolume bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.sheds, name)
+	return nil
+}
+
+func (f *fakeDocker) StartShed(ctx context.Context, name string) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if shed.Status == config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is already running", name)
+	}
+	shed.Status = config.StatusRunning
+	return shed, nil
+}
+
+func (f *fakeDocker) StopShed(ctx context.Context, name string) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	shed.Status = config.StatusStopped
+	return shed, nil
+}
+
+func (f *fakeDocker) GetShedDetails(ctx context.Context, name string) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	details := *shed
+	details.Details = &config.ShedDetails{
+		ImageID: "sha256:abc",
+		Mounts:  []config.ShedMount{{Type: config.MountTypeVolume, Source: config.VolumeName(name), Target: config.WorkspacePath}},
+		Env:     []string{"PATH", "TZ"},
+	}
+	return &details, nil
+}
+
+func (f *fakeDocker) RebuildShed(ctx context.Context, name string, req config.RebuildShedRequest, progress config.ProgressFunc) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	progress.Report(config.PhaseContainer, config.ProgressDone, config.ContainerName(name))
+	if req.Image != "" {
+		shed.Image = req.Image
+	}
+	shed.Status = config.StatusRunning
+	return shed, nil
+}
+
+func (f *fakeDocker) PauseShed(ctx context.Context, name string) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+	shed.Status = config.StatusPaused
+	return shed, nil
+}
+
+func (f *fakeDocker) ResumeShed(ctx context.Context, name string) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if shed.Status != config.StatusPaused {
+		return nil, fmt.Errorf("shed %q is not paused", name)
+	}
+	shed.Status = config.StatusRunning
+	return shed, nil
+}
+
+func (f *fakeDocker) WaitForStatus(ctx context.Context, name, status string) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if shed.Status == status {
+		return shed, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (f *fakeDocker) ListSessions(ctx context.Context, name string) ([]config.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sessions := []config.Session{}
+	for _, s := range f.sessions[name] {
+		sessions = append(sessions, config.Session{Name: s})
+	}
+	return sessions, nil
+}
+
+func (f *fakeDocker) ListPorts(ctx context.Context, name string) ([]config.ListeningPort, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+	return []config.ListeningPort{{Port: 3000, Address: "0.0.0.0", Process: "node", PID: 42}}, nil
+}
+
+func (f *fakeDocker) CreateSession(ctx context.Context, name string, req config.CreateSessionRequest) (*config.Session, error) {
+	if _, err := f.GetShed(ctx, name); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slices.Contains(f.sessions[name], req.Name) {
+		return nil, fmt.Errorf("session %q already exists in shed %q", req.Name, name)
+	}
+	if f.sessions == nil {
+		f.sessions = make(map[string][]string)
+	}
+	f.sessions[name] = append(f.sessions[name], req.Name)
+	return &config.Session{Name: req.Name, Windows: 1}, nil
+}
+
+func (f *fakeDocker) ExecInSession(ctx context.Context, name, session, command string) (*config.SessionExecResult, error) {
+	if _, err := f.GetShed(ctx, name); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !slices.Contains(f.sessions[name], session) {
+		if f.sessions == nil {
+			f.sessions = make(map[string][]string)
+		}
+		f.sessions[name] = append(f.sessions[name], session)
+	}
+
+	// The fake session runs "sleep" until the request times out and echoes
+	// anything else
+	if command == "sleep" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &config.SessionExecResult{Session: session, Output: command + "\n"}, nil
+}
+
+func (f *fakeDocker) RenameSession(ctx context.Context, name, session string, req config.RenameSessionRequest) (*config.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := slices.Index(f.sessions[name], session)
+	if i < 0 {
+		return nil, fmt.Errorf("session %q not found in shed %q", session, name)
+	}
+	if slices.Contains(f.sessions[name], req.Name) {
+		return nil, fmt.Errorf("session %q already exists in shed %q", req.Name, name)
+	}
+	f.sessions[name][i] = req.Name
+	return &config.Session{Name: req.Name, Windows: 1}, nil
+}
+
+func (f *fakeDocker) ExecCommand(ctx context.Context, name, command string, stdout, stderr io.Writer) (int, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	if shed.Status != config.StatusRunning {
+		return 0, fmt.Errorf("shed %q is not running", name)
+	}
+
+	// The fake runs "sleep" until the request times out, "fail" writes to
+	// stderr and exits 3, and anything else is echoed to stdout
+	switch command {
+	case "sleep":
+		<-ctx.Done()
+		return 0, ctx.Err()
+	case "fail":
+		fmt.Fprintln(stderr, "failed")
+		return 3, nil
+	}
+	fmt.Fprintln(stdout, command)
+	return 0, nil
+}
+
+func (f *fakeDocker) KillSession(ctx context.Context, name, session string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := slices.Index(f.sessions[name], session)
+	if i < 0 {
+		return fmt.Errorf("session %q not found in shed %q", session, name)
+	}
+	f.sessions[name] = slices.Delete(f.sessions[name], i, i+1)
+	return nil
+}
+
+func (f *fakeDocker) OpenTerminal(ctx context.Context, name string, req config.TerminalRequest) (Terminal, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+
+	// The fake shell greets with its session and size, then echoes input
+	// until it reads "exit"
+	pr, pw := io.Pipe()
+	go fmt.Fprintf(pw, "session=%s size=%dx%d\n", req.Session, req.Cols, req.Rows)
+	return &fakeTerminal{pr: pr, pw: pw}, nil
+}
+
+// fakeTerminal is an echoing shell for terminal handler tests.
+type fakeTerminal struct {
+	pr *io.PipeReader
+	pw *io.PipeWriter
+}
+
+func (t *fakeTerminal) Read(p []byte) (int, error) {
+	return t.pr.Read(p)
+}
+
+func (t *fakeTerminal) Write(p []byte) (int, error) {
+	if string(p) == "exit" {
+		t.pw.Close()
+		return len(p), nil
+	}
+	return t.pw.Write(p)
+}
+
+func (t *fakeTerminal) Resize(ctx context.Context, cols, rows uint) error {
+	_, err := fmt.Fprintf(t.pw, "resized=%dx%d\n", cols, rows)
+	return err
+}
+
+func (t *fakeTerminal) ExitCode(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (t *fakeTerminal) Close() error {
+	t.pw.Close()
+	return t.pr.Close()
+}
+
+func (f *fakeDocker) GetShedStats(ctx context.Context, name string, activity bool) (*config.ShedStats, error) {
+	if _, err := f.GetShed(ctx, name); err != nil {
+		return nil, err
+	}
+	return &config.ShedStats{Name: name}, nil
+}
+
+func (f *fakeDocker) ExportWorkspace(ctx context.Context, name string) (io.ReadCloser, error) {
+	if _, err := f.GetShed(ctx, name); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader("workspace of " + name)), nil
+}
+
+func (f *fakeDocker) ImportShed(ctx context.Context, req config.CreateShedRequest, archive io.Reader) (*config.Shed, error) {
+	data, err := io.ReadAll(archive)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("invalid workspace archive: no files under workspace/")
+	}
+	return f.CreateShed(ctx, req, nil)
+}
+
+func (f *fakeDocker) CloneShed(ctx context.Context, src, dst string) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return f.CreateShed(ctx, config.CreateShedRequest{Name: dst, Repo: shed.Repo, Image: shed.Image}, nil)
+}
+
+func (f *fakeDocker) UpdateLabels(ctx context.Context, name string, req config.UpdateLabelsRequest) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if shed.Labels == nil {
+		shed.Labels = make(map[string]string)
+	}
+	for k, v := range req.Set {
+		shed.Labels[k] = v
+	}
+	for _, k := range req.Remove {
+		delete(shed.Labels, k)
+	}
+	return shed, nil
+}
+
+func (f *fakeDocker) UpdateEnv(ctx context.Context, name string, req config.UpdateEnvRequest) (*config.Shed, error) {
+	shed, err := f.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if shed.Env == nil {
+		shed.Env = make(map[string]string)
+	}
+	for k, v := range req.Set {
+		shed.Env[k] = v
+	}
+	for _, k := range req.Remove {
+		delete(shed.Env, k)
+	}
+	return shed, nil
+}
+
+func (f *fakeDocker) SubscribeEvents() (<-chan config.ShedEvent, func()) {
+	return f.events.Subscribe()
+}
+
+func (f *fakeDocker) GetWorkspaceDiff(ctx context.Context, name string, full bool) (*config.WorkspaceDiff, error) {
+	if _, err := f.GetShed(ctx, name); err != nil {
+		return nil, err
+	}
+	return &config.WorkspaceDiff{Name: name, Repos: []config.RepoStatus{}}, nil
+}
+
+func (f *fakeDocker) ShedLogs(ctx context.Context, name string, opts config.LogOptions) (io.ReadCloser, error) {
+	if _, err := f.GetShed(ctx, name); err != nil {
+		return nil, err
+	}
+	lines := []string{"one\n", "two\n", "three\n"}
+	if opts.Tail >= 0 && opts.Tail < len(lines) {
+		lines = lines[len(lines)-opts.Tail:]
+	}
+	return io.NopCloser(strings.NewReader(strings.Join(lines, ""))), nil
+}
+
+func (f *fakeDocker) PrepullStatus() []config.ImagePullStatus {
+	return nil
+}
+
+func (f *fakeDocker) ImageGCStatus() *config.ImageGCStatus {
+	return nil
+}
+
+func (f *fakeDocker) ListImages(ctx context.Context) ([]config.Image, error) {
+	return []config.Image{
+		{Name: "shed-base:latest", ID: "sha256:abc", Size: 1 << 30, Default: true},
+		{Name: "shed-go:1.24", ID: "sha256:def", Size: 2 << 30},
+	}, nil
+}
+
+// BuildImage echoes the Dockerfile from the build context as build output.
+// Dockerfiles containing FAIL fail the build.
+func (f *fakeDocker) BuildImage(ctx context.Context, req config.BuildImageRequest, buildContext io.Reader, output func(string)) (*config.Image, error) {
+	tr := tar.NewReader(buildContext)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return nil, fmt.Errorf("image build failed: no Dockerfile in build context")
+		}
+		if hdr.Name != req.Dockerfile {
+			continue
+		}
+		dockerfile, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		output(string(dockerfile))
+		if strings.Contains(string(dockerfile), "FAIL") {
+			return nil, fmt.Errorf("image build failed: The command returned a non-zero code: 1")
+		}
+		return &config.Image{Name: req.Tag, ID: "sha256:built"}, nil
+	}
+}
+
+func (f *fakeDocker) DockerHost() string {
+	return "unix:///var/run/docker.sock"
+}
+
+func (f *fakeDocker) Capabilities(ctx context.Context) config.ServerCapabilities {
+	return config.ServerCapabilities{}
+}
+
+func (f *fakeDocker) GetCapacity(ctx context.Context) (*config.ServerCapacity, error) {
+	sheds, _ := f.ListSheds(ctx)
+	counts := make(map[string]int)
+	for _, shed := range sheds {
+		counts[shed.Status]++
+	}
+	return &config.ServerCapacity{Sheds: counts, TotalSheds: len(sheds)}, nil
+}
+
+func (f *fakeDocker) CreateCheckpoint(ctx context.Context, shedName, name string, leaveRunning bool) (*config.Checkpoint, error) {
+	return nil, fmt.Errorf("checkpoint is not supported on this server")
+}
+
+func (f *fakeDocker) ListCheckpoints(ctx context.Context, shedName string) ([]config.Checkpoint, error) {
+	if _, err := f.GetShed(ctx, shedName); err != nil {
+		return nil, err
+	}
+	return []config.Checkpoint{}, nil
+}
+
+func (f *fakeDocker) DeleteCheckpoint(ctx context.Context, shedName, name string) error {
+	return fmt.Errorf("checkpoint %q not found for shed %q", name, shedName)
+}
+
+func (f *fakeDocker) RestoreShed(ctx context.Context, shedName, name string) (*config.Shed, error) {
+	return nil, fmt.Errorf("checkpoint is not supported on this server")
+}
+
+func (f *fakeDocker) ListSchedules(ctx context.Context, name string) ([]config.Schedule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sheds[name]; name != "" && !ok {
+		return nil, fmt.Errorf("shed %q not found", name)
+	}
+	schedules := []config.Schedule{}
+	for shed, actions := range f.schedules {
+		if name != "" && shed != name {
+			continue
+		}
+		for action, expr := range actions {
+			schedules = append(schedules, config.Schedule{Shed: shed, Action: action, Cron: expr})
+		}
+	}
+	return schedules, nil
+}
+
+func (f *fakeDocker) SetSchedule(ctx context.Context, name, action, expr string) (*config.Schedule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sheds[name]; !ok {
+		return nil, fmt.Errorf("shed %q not found", name)
+	}
+	if f.schedules == nil {
+		f.schedules = make(map[string]map[string]string)
+	}
+	if f.schedules[name] == nil {
+		f.schedules[name] = make(map[string]string)
+	}
+	f.schedules[name][action] = expr
+	return &config.Schedule{Shed: name, Action: action, Cron: expr}, nil
+}
+
+func (f *fakeDocker) DeleteSchedule(ctx context.Context, name, action string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.schedules[name][action]; !ok {
+		return fmt.Errorf("schedule %q not found for shed %q", action, name)
+	}
+	delete(f.schedules[name], action)
+	return nil
+}
+
+func (f *fakeDocker) BackupShed(ctx context.Context, name string) (*config.Backup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sheds[name]; !ok {
+		return nil, fmt.Errorf("shed %q not found", name)
+	}
+	if f.backups == nil {
+		f.backups = make(map[string][]config.Backup)
+	}
+	backup := config.Backup{Shed: name, Name: fmt.Sprintf("backup-%d", len(f.backups[name])+1), Size: 42}
+	f.backups[name] = append([]config.Backup{backup}, f.backups[name]...)
+	return &backup, nil
+}
+
+func (f *fakeDocker) ListBackups(ctx context.Context, name string) ([]config.Backup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]config.Backup{}, f.backups[name]...), nil
+}
+
+func (f *fakeDocker) RestoreBackup(ctx context.Context, name, backup string) (*config.Shed, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	shed, ok := f.sheds[name]
+	if !ok {
+		return nil, fmt.Errorf("shed %q not found", name)
+	}
+	for _, b := range f.backups[name] {
+		if b.Name == backup {
+			shed.Status = config.StatusRunning
+			return shed, nil
+		}
+	}
+	return nil, fmt.Errorf("backup %q not found for shed %q", backup, name)
+}
+
+func (f *fakeDocker) Prune(ctx context.Context, req config.PruneRequest) (*config.PruneResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := &config.PruneResponse{DryRun: req.DryRun}
+	for name, shed := range f.sheds {
+		if req.StoppedDays > 0 && shed.Status == config.StatusStopped {
+			resp.Resources = append(resp.Resources, config.PrunedResource{Kind: config.PruneShed, Name: name, Reason: "stopped"})
+			if !req.DryRun {
+				delete(f.sheds, name)
+			}
+		}
+	}
+	return resp, nil
+}
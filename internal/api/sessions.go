@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// handleCreateSession starts a detached session in a shed, optionally
+// running a command.
+// POST /api/sheds/{name}/sessions
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req config.CreateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	session, err := s.docker.CreateSession(r.Context(), name, req)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, session)
+}
+
+// handleRenameSession renames a session in a shed.
+// PATCH /api/sheds/{name}/sessions/{session}
+func (s *Server) handleRenameSession(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	session := chi.URLParam(r, "session")
+
+	var req config.RenameSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if errs := req.Validate(); len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	renamed, err := s.docker.RenameSession(r.Context(), name, session, req)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, renamed)
+}
+
+// handleKillSession ends a session in a shed.
+// DELETE /api/sheds/{name}/sessions/{session}
+func (s *Server) handleKillSession(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	session := chi.URLParam(r, "session")
+
+	if err := s.docker.KillSession(r.Context(), name, session); err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSessionExec runs a command in a tmux session, creating the session
+// if needed, and returns its output and exit code once it finishes. If the
+// timeout passes first, a WAIT_TIMEOUT error is returned and the command is
+// left running.
+// POST /api/sheds/{name}/sessions/{session}/exec
+func (s *Server) handleSessionExec(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	session := chi.URLParam(r, "session")
+
+	var req config.SessionExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "invalid request body: "+err.Error())
+		return
+	}
+	errs := req.Validate()
+	errs.Check("session", config.ValidateSessionName(session))
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+	timeout, _ := req.TimeoutDuration()
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	result, err := s.docker.ExecInSession(ctx, name, session, req.Command)
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusGatewayTimeout, config.ErrWaitTimeout,
+			fmt.Sprintf("timed out after %s waiting for the command in session %q; it is still running", timeout, session))
+		return
+	}
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
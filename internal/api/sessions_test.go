@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestSessions(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/api/sheds/dev/sessions", `{"name":"build","command":"make watch"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	rec := do(http.MethodPatch, "/api/sheds/dev/sessions/build", `{"name":"watch"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rename: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var session config.Session
+	if err := json.NewDecoder(rec.Body).Decode(&session); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if session.Name != "watch" {
+		t.Errorf("renamed session = %q, want watch", session.Name)
+	}
+
+	if rec := do(http.MethodPost, "/api/sheds/dev/sessions", `{"name":"scratch"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	if rec := do(http.MethodDelete, "/api/sheds/dev/sessions/scratch", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("kill: status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"bad name", http.MethodPost, "/api/sheds/dev/sessions", `{"name":"my session"}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"no name", http.MethodPost, "/api/sheds/dev/sessions", `{}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"exists", http.MethodPost, "/api/sheds/dev/sessions", `{"name":"watch"}`, http.StatusConflict, config.ErrSessionExists},
+		{"missing shed", http.MethodPost, "/api/sheds/nope/sessions", `{"name":"x"}`, http.StatusNotFound, config.ErrShedNotFound},
+		{"rename missing", http.MethodPatch, "/api/sheds/dev/sessions/build", `{"name":"other"}`, http.StatusNotFound, config.ErrSessionNotFound},
+		{"kill missing", http.MethodDelete, "/api/sheds/dev/sessions/scratch", "", http.StatusNotFound, config.ErrSessionNotFound},
+		{"rename bad name", http.MethodPatch, "/api/sheds/dev/sessions/watch", `{"name":"a.b"}`, http.StatusBadRequest, config.ErrValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			var resp config.APIError
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error.Code != tt.code {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestSessionExec(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/api/sheds/dev/sessions/build/exec", `{"command":"make test"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exec: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var result config.SessionExecResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Session != "build" || result.Output != "make test\n" || result.ExitCode != 0 {
+		t.Errorf("result = %+v, want make test's output from build", result)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"no command", "/api/sheds/dev/sessions/build/exec", `{}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"bad timeout", "/api/sheds/dev/sessions/build/exec", `{"command":"ls","timeout":"2d"}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"bad session", "/api/sheds/dev/sessions/a.b/exec", `{"command":"ls"}`, http.StatusBadRequest, config.ErrValidationFailed},
+		{"timeout", "/api/sheds/dev/sessions/build/exec", `{"command":"sleep","timeout":"10ms"}`, http.StatusGatewayTimeout, config.ErrWaitTimeout},
+		{"missing shed", "/api/sheds/nope/sessions/build/exec", `{"command":"ls"}`, http.StatusNotFound, config.ErrShedNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			var resp config.APIError
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error.Code != tt.code {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.code)
+			}
+		})
+	}
+}
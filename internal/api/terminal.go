@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/websocket"
+)
+
+// handleTerminal handles GET /api/sheds/{name}/terminal.
+// The request is upgraded to a WebSocket bridged to a shell in the shed.
+// Binary messages carry terminal data both ways; text messages carry
+// config.TerminalMessage control messages such as resizes. When the shell
+// exits an exit message is sent and the connection is closed.
+func (s *Server) handleTerminal(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	// Browsers let any page open a WebSocket, so only the dashboard's own
+	// origin, origins listed in cors_allowed_origins, or non-browser clients
+	// may connect
+	if !websocket.SameOrigin(r) && !s.cors.listed(r.Header.Get("Origin")) {
+		writeError(w, http.StatusForbidden, config.ErrInvalidRequest, "cross-origin terminal connections are not allowed")
+		return
+	}
+	if !websocket.IsUpgrade(r) {
+		writeError(w, http.StatusBadRequest, config.ErrInvalidRequest, "terminal requires a WebSocket upgrade")
+		return
+	}
+
+	req, errs := parseTerminalRequest(r)
+	if len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	term, err := s.docker.OpenTerminal(r.Context(), name, req)
+	if err != nil {
+		code, errCode, msg := mapDockerError(err)
+		writeError(w, code, errCode, msg)
+		return
+	}
+	defer term.Close()
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close(websocket.CloseNormal, "")
+
+	ctx := r.Context()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := term.Read(buf)
+			if n > 0 {
+				if werr := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		if code, err := term.ExitCode(ctx); err == nil {
+			msg, _ := json.Marshal(config.TerminalMessage{Type: config.TerminalExit, Code: &code})
+			_ = conn.WriteMessage(websocket.TextMessage, msg)
+		}
+		_ = conn.Close(websocket.CloseNormal, "")
+	}()
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		input := data
+		if msgType == websocket.TextMessage {
+			var msg config.TerminalMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				_ = conn.Close(websocket.CloseUnsupportedData, "invalid control message")
+				break
+			}
+			// Unknown message types are ignored so clients can send newer ones
+			input = nil
+			switch msg.Type {
+			case config.TerminalResize:
+				if msg.Cols > 0 && msg.Rows > 0 {
+					_ = term.Resize(ctx, msg.Cols, msg.Rows)
+				}
+			case config.TerminalInput:
+				input = []byte(msg.Data)
+			}
+		}
+
+		if len(input) > 0 {
+			if _, err := term.Write(input); err != nil {
+				break
+			}
+		}
+	}
+
+	// Ending the terminal stops the output copy
+	_ = term.Close()
+	<-done
+}
+
+// parseTerminalRequest reads a terminal request from query parameters.
+func parseTerminalRequest(r *http.Request) (config.TerminalRequest, config.ValidationErrors) {
+	q := r.URL.Query()
+	req := config.TerminalRequest{
+		Session: q.Get("session"),
+		Term:    q.Get("term"),
+	}
+
+	var errs config.ValidationErrors
+	sizes := []struct {
+		field string
+		dst   *uint
+	}{{"cols", &req.Cols}, {"rows", &req.Rows}}
+	for _, size := range sizes {
+		if v := q.Get(size.field); v != "" {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				errs.Add(size.field, config.FieldInvalid, size.field+" must be a positive number")
+				continue
+			}
+			*size.dst = uint(n)
+		}
+	}
+	errs = append(errs, req.Validate()...)
+
+	return req, errs
+}
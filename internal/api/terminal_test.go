@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// dialTerminal opens a WebSocket to path on srv and returns the connection
+// and a reader positioned after the handshake.
+func dialTerminal(t *testing.T, srv *httptest.Server, path string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req := "GET " + path + " HTTP/1.1\r\nHost: " + host + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	return conn, br
+}
+
+// sendFrame writes a short masked client frame.
+func sendFrame(t *testing.T, conn net.Conn, opcode byte, payload string) {
+	t.Helper()
+	mask := []byte{7, 7, 7, 7}
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("write frame: %v", err)
+	}
+}
+
+// readFrame reads a short server frame.
+func readFrame(t *testing.T, br *bufio.Reader) (byte, string) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return header[0] & 0x0f, string(payload)
+}
+
+func TestHandleTerminal(t *testing.T) {
+	cfg := testConfig(t)
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusRunning})
+	srv := httptest.NewServer(NewServer(docker, cfg, nil).Router())
+	defer srv.Close()
+
+	conn, br := dialTerminal(t, srv, "/api/sheds/dev/terminal?session=work&cols=80&rows=24")
+
+	const (
+		opText   = 1
+		opBinary = 2
+		opClose  = 8
+	)
+	expect := func(wantOp byte, want string) {
+		t.Helper()
+		op, got := readFrame(t, br)
+		if op != wantOp || got != want {
+			t.Fatalf("got opcode %d %q, want opcode %d %q", op, got, wantOp, want)
+		}
+	}
+
+	expect(opBinary, "session=work size=80x24\n")
+
+	sendFrame(t, conn, opBinary, "ls\r")
+	expect(opBinary, "ls\r")
+
+	sendFrame(t, conn, opText, `{"type":"resize","cols":100,"rows":30}`)
+	expect(opBinary, "resized=100x30\n")
+
+	sendFrame(t, conn, opText, `{"type":"input","data":"exit"}`)
+	op, got := readFrame(t, br)
+	var msg config.TerminalMessage
+	if op != opText || json.Unmarshal([]byte(got), &msg) != nil || msg.Type != config.TerminalExit || msg.Code == nil || *msg.Code != 0 {
+		t.Fatalf("got opcode %d %q, want exit message with code 0", op, got)
+	}
+
+	if op, _ := readFrame(t, br); op != opClose {
+		t.Errorf("got opcode %d, want close", op)
+	}
+}
+
+func TestHandleTerminalErrors(t *testing.T) {
+	cfg := testConfig(t)
+	docker := newFakeDocker(
+		config.Shed{Name: "dev", Status: config.StatusRunning},
+		config.Shed{Name: "idle", Status: config.StatusStopped},
+	)
+	router := NewServer(docker, cfg, nil).Router()
+
+	tests := []struct {
+		name    string
+		path    string
+		upgrade bool
+		origin  string
+		want    int
+	}{
+		{"not an upgrade", "/api/sheds/dev/terminal", false, "", http.StatusBadRequest},
+		{"cross origin", "/api/sheds/dev/terminal", true, "http://evil.example", http.StatusForbidden},
+		{"invalid session", "/api/sheds/dev/terminal?session=a.b", true, "", http.StatusBadRequest},
+		{"invalid size", "/api/sheds/dev/terminal?cols=wide", true, "", http.StatusBadRequest},
+		{"stopped shed", "/api/sheds/idle/terminal", true, "", http.StatusConflict},
+		{"unknown shed", "/api/sheds/nope/terminal", true, "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireTokenWebSocketQuery(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.APITokens = []config.APIToken{{Name: "browser", Token: "s3cret"}}
+	docker := newFakeDocker(config.Shed{Name: "idle", Status: config.StatusStopped})
+	router := NewServer(docker, cfg, nil).Router()
+
+	tests := []struct {
+		name    string
+		query   string
+		upgrade bool
+		want    int
+	}{
+		{"valid token", "?access_token=s3cret", true, http.StatusConflict},
+		{"wrong token", "?access_token=nope", true, http.StatusUnauthorized},
+		{"no token", "", true, http.StatusUnauthorized},
+		// Plain requests must still use the header
+		{"not an upgrade", "?access_token=s3cret", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/sheds/idle/terminal"+tt.query, nil)
+			if tt.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
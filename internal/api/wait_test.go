@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHandleWaitShed(t *testing.T) {
+	docker := newFakeDocker(config.Shed{Name: "dev", Status: config.StatusStopped})
+	srv := NewServer(docker, testConfig(t), nil)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantErr  string
+	}{
+		{"already reached", "?status=stopped", http.StatusOK, ""},
+		{"times out", "?status=running&timeout=10ms", http.StatusGatewayTimeout, config.ErrWaitTimeout},
+		{"unknown status", "?status=sleeping", http.StatusBadRequest, config.ErrValidationFailed},
+		{"bad timeout", "?timeout=forever", http.StatusBadRequest, config.ErrValidationFailed},
+		{"timeout too long", "?timeout=1h", http.StatusBadRequest, config.ErrValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/sheds/dev/wait"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantErr == "" {
+				return
+			}
+
+			var resp config.APIError
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Error.Code != tt.wantErr {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+// Package apitoken manages bearer tokens for authenticating to the shed-server HTTP API.
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// tokensFile is the name of the minted token file in the state dir.
+const tokensFile = "tokens.json"
+
+// tokenPrefix marks shed tokens so they are easy to recognize in configs and logs.
+const tokenPrefix = "shed_"
+
+// record is a minted token as stored on disk. Only a hash of the token is
+// kept, so the file can't be used to recover it.
+type record struct {
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store manages minted tokens in a JSON file alongside tokens configured
+// in server.yaml. The file is re-read on each check so tokens created or
+// revoked with the shed-server CLI take effect without a restart.
+type Store struct {
+	path   string
+	static []config.APIToken
+
+	// mu serializes changes so concurrent creates and revokes don't drop
+	// each other's writes.
+	mu sync.Mutex
+}
+
+// NewStore creates a store for tokens minted in dir and the configured static tokens.
+func NewStore(dir string, static []config.APIToken) *Store {
+	return &Store{
+		path:   filepath.Join(dir, tokensFile),
+		static: static,
+	}
+}
+
+// Create mints a new token. The returned token is not stored and can't be
+// shown again.
+func (s *Store) Create(name string) (string, error) {
+	if err := config.ValidateTokenName(name); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	for _, r := range records {
+		if r.Name == name {
+			return "", fmt.Errorf("token %q already exists", name)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := tokenPrefix + hex.EncodeToString(secret)
+
+	records = append(records, record{
+		Name:      name,
+		Hash:      hash(token),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err := s.save(records); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// List returns the minted tokens sorted by name.
+func (s *Store) List() ([]config.APITokenInfo, error) {
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]config.APITokenInfo, 0, len(records))
+	for _, r := range records {
+		tokens = append(tokens, config.APITokenInfo{Name: r.Name, CreatedAt: r.CreatedAt})
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Name < tokens[j].Name
+	})
+	return tokens, nil
+}
+
+// Revoke removes a minted token.
+func (s *Store) Revoke(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	for i, r := range records {
+		if r.Name == name {
+			return s.save(append(records[:i], records[i+1:]...))
+		}
+	}
+	return fmt.Errorf("token %q not found", name)
+}
+
+// Enabled reports whether any tokens exist. Authentication is only
+// enforced once a token has been configured or minted.
+func (s *Store) Enabled() bool {
+	if len(s.static) > 0 {
+		return true
+	}
+	records, err := s.load()
+	// Fail closed if the token file exists but can't be read
+	return err != nil || len(records) > 0
+}
+
+// Verify returns the name of the token matching token, or false if none does.
+func (s *Store) Verify(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+
+	for _, t := range s.static {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return t.Name, true
+		}
+	}
+
+	records, err := s.load()
+	if err != nil {
+		return "", false
+	}
+	h := hash(token)
+	for _, r := range records {
+		if subtle.ConstantTimeCompare([]byte(r.Hash), []byte(h)) == 1 {
+			return r.Name, true
+		}
+	}
+	return "", false
+}
+
+func (s *Store) load() ([]record, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read tokens: %w", err)
+	}
+
+	var records []record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens %s: %w", s.path, err)
+	}
+	return records, nil
+}
+
+func (s *Store) save(records []record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tokens: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write atomically via temp file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write tokens: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp) // Clean up on failure
+		return fmt.Errorf("failed to save tokens: %w", err)
+	}
+	return nil
+}
+
+// hash returns the hex-encoded SHA-256 of a token.
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
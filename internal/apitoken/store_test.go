@@ -0,0 +1,110 @@
+package apitoken
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestStoreLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir, nil)
+
+	if store.Enabled() {
+		t.Error("Enabled() should be false with no tokens")
+	}
+
+	token, err := store.Create("laptop")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if !strings.HasPrefix(token, tokenPrefix) {
+		t.Errorf("token = %q, want %q prefix", token, tokenPrefix)
+	}
+	if !store.Enabled() {
+		t.Error("Enabled() should be true once a token is minted")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, tokensFile))
+	if err != nil {
+		t.Fatalf("token file not written: %v", err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Error("token file should not contain the plaintext token")
+	}
+
+	if _, err := store.Create("laptop"); err == nil {
+		t.Error("Create() should fail for an existing name")
+	}
+	if _, err := store.Create("Bad_Name"); err == nil {
+		t.Error("Create() should fail for an invalid name")
+	}
+
+	if name, ok := store.Verify(token); !ok || name != "laptop" {
+		t.Errorf("Verify() = %q, %v, want laptop, true", name, ok)
+	}
+	if _, ok := store.Verify(token + "x"); ok {
+		t.Error("Verify() accepted a wrong token")
+	}
+
+	tokens, err := store.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].Name != "laptop" {
+		t.Errorf("List() = %+v, want [laptop]", tokens)
+	}
+
+	if err := store.Revoke("laptop"); err != nil {
+		t.Fatalf("Revoke() failed: %v", err)
+	}
+	if _, ok := store.Verify(token); ok {
+		t.Error("Verify() accepted a revoked token")
+	}
+	if err := store.Revoke("laptop"); err == nil {
+		t.Error("Revoke() should fail for a missing token")
+	}
+}
+
+func TestStoreConcurrentCreates(t *testing.T) {
+	store := NewStore(t.TempDir(), nil)
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Create(fmt.Sprintf("token-%d", i)); err != nil {
+				t.Errorf("Create() failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	tokens, err := store.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(tokens) != 10 {
+		t.Errorf("List() returned %d tokens, want all 10 created", len(tokens))
+	}
+}
+
+func TestStoreStaticTokens(t *testing.T) {
+	store := NewStore(t.TempDir(), []config.APIToken{{Name: "ci", Token: "s3cret"}})
+
+	if !store.Enabled() {
+		t.Error("Enabled() should be true with a configured token")
+	}
+	if name, ok := store.Verify("s3cret"); !ok || name != "ci" {
+		t.Errorf("Verify() = %q, %v, want ci, true", name, ok)
+	}
+	if _, ok := store.Verify(""); ok {
+		t.Error("Verify() accepted an empty token")
+	}
+}
@@ -0,0 +1,113 @@
+// Package audit keeps an append-only log of the actions taken through
+// shed-server's API and SSH server.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// FileName is the name of the audit log in the server's state dir.
+const FileName = "audit.jsonl"
+
+// Log appends audit entries to a file as JSON lines. Entries are only ever
+// appended; the file is never rewritten.
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Open returns the audit log in dir. The file is created on first write.
+func Open(dir string) *Log {
+	return &Log{path: filepath.Join(dir, FileName)}
+}
+
+// Path returns the log file's path.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends an entry, stamping it with the current time if it has
+// none. Failures are logged rather than returned so an unwritable log
+// doesn't block the action being audited. It is safe to call on a nil Log.
+func (l *Log) Record(e config.AuditEntry) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if err := l.append(e); err != nil {
+		slog.Error("Failed to write audit log", "path", l.path, "action", e.Action, "err", err)
+	}
+}
+
+func (l *Log) append(e config.AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state dir: %w", err)
+	}
+	// The log may record who connected from where, so keep it private
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Tail returns the last n entries that match, oldest first. All matching
+// entries are returned if n is zero or less, and every entry matches if
+// match is nil. Lines that can't be parsed are skipped.
+func (l *Log) Tail(n int, match func(config.AuditEntry) bool) ([]config.AuditEntry, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []config.AuditEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	entries := []config.AuditEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e config.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if match != nil && !match(e) {
+			continue
+		}
+		entries = append(entries, e)
+		if n > 0 && len(entries) > 2*n {
+			entries = append(entries[:0], entries[len(entries)-n:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	if n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries, nil
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"os"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestLogTail(t *testing.T) {
+	log := Open(t.TempDir())
+
+	entries, err := log.Tail(10, nil)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Tail() on missing log = %v, %v; want empty", entries, err)
+	}
+
+	for _, shed := range []string{"a", "b", "a", "c", "a"} {
+		log.Record(config.AuditEntry{Via: config.AuditViaAPI, Action: "start", Shed: shed})
+	}
+
+	entries, err = log.Tail(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 || entries[0].Time.IsZero() {
+		t.Fatalf("Tail(0) = %+v, want 5 timestamped entries", entries)
+	}
+
+	entries, err = log.Tail(2, func(e config.AuditEntry) bool { return e.Shed != "a" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Shed != "b" || entries[1].Shed != "c" {
+		t.Errorf("Tail(2, not a) = %+v, want b then c", entries)
+	}
+
+	info, err := os.Stat(log.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("log permissions = %o, want 600", perm)
+	}
+}
+
+func TestLogTailSkipsBadLines(t *testing.T) {
+	log := Open(t.TempDir())
+	log.Record(config.AuditEntry{Action: "create", Shed: "a"})
+
+	f, err := os.OpenFile(log.Path(), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n")
+	f.Close()
+	log.Record(config.AuditEntry{Action: "delete", Shed: "a"})
+
+	entries, err := log.Tail(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Action != "delete" {
+		t.Errorf("Tail() = %+v, want create and delete", entries)
+	}
+
+	var nilLog *Log
+	nilLog.Record(config.AuditEntry{}) // Must not panic
+}
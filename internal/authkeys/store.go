@@ -0,0 +1,208 @@
+// Package authkeys manages the SSH public keys allowed to connect to sheds.
+package authkeys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// keysFile is the name of the registered key file in the state dir.
+const keysFile = "authorized_keys.json"
+
+var (
+	// ErrExists is returned when adding a key whose name or public key is
+	// already registered.
+	ErrExists = errors.New("key already exists")
+
+	// ErrNotFound is returned when removing a key that isn't registered.
+	ErrNotFound = errors.New("key not found")
+)
+
+// Store manages keys registered through the API in a JSON file alongside
+// keys configured in server.yaml. The file is re-read on each check so keys
+// added or removed through the API take effect for the next connection.
+type Store struct {
+	path   string
+	static []gossh.PublicKey
+
+	// mu serializes changes so concurrent adds and removes don't drop
+	// each other's writes.
+	mu sync.Mutex
+}
+
+// NewStore creates a store for keys registered in dir and the configured
+// authorized_keys lines, which are valid for every shed. Lines that don't
+// parse are skipped; server config validation reports them.
+func NewStore(dir string, static []string) *Store {
+	s := &Store{path: filepath.Join(dir, keysFile)}
+	for _, line := range static {
+		key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			continue
+		}
+		s.static = append(s.static, key)
+	}
+	return s
+}
+
+// Add registers a public key in authorized_keys format. If shed is set the
+// key may only connect to that shed.
+func (s *Store) Add(name, shed, publicKey string) (*config.AuthorizedKey, error) {
+	if err := config.ValidateKeyName(name); err != nil {
+		return nil, err
+	}
+	if shed != "" {
+		if err := config.ValidateShedName(shed); err != nil {
+			return nil, err
+		}
+	}
+
+	key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := gossh.FingerprintSHA256(key)
+	for _, k := range keys {
+		if k.Name == name {
+			return nil, fmt.Errorf("%w: %q", ErrExists, name)
+		}
+		if k.Fingerprint == fingerprint && k.Shed == shed {
+			return nil, fmt.Errorf("%w: same public key as %q", ErrExists, k.Name)
+		}
+	}
+
+	added := config.AuthorizedKey{
+		Name:        name,
+		PublicKey:   strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))),
+		Fingerprint: fingerprint,
+		Shed:        shed,
+		AddedAt:     time.Now().UTC(),
+	}
+	if err := s.save(append(keys, added)); err != nil {
+		return nil, err
+	}
+	return &added, nil
+}
+
+// List returns the registered keys sorted by name.
+func (s *Store) List() ([]config.AuthorizedKey, error) {
+	keys, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys, nil
+}
+
+// Remove unregisters a key.
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	for i, k := range keys {
+		if k.Name == name {
+			return s.save(append(keys[:i], keys[i+1:]...))
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrNotFound, name)
+}
+
+// Enabled reports whether any keys exist. Keys are only checked once one
+// has been configured or registered.
+func (s *Store) Enabled() bool {
+	if len(s.static) > 0 {
+		return true
+	}
+	keys, err := s.load()
+	// Fail closed if the key file exists but can't be read
+	return err != nil || len(keys) > 0
+}
+
+// Authorized returns the name of a key matching key that may connect to
+// shed, or false if there is none.
+func (s *Store) Authorized(shed string, key gossh.PublicKey) (string, bool) {
+	fingerprint := gossh.FingerprintSHA256(key)
+
+	for _, k := range s.static {
+		if gossh.FingerprintSHA256(k) == fingerprint {
+			return "server.yaml", true
+		}
+	}
+
+	keys, err := s.load()
+	if err != nil {
+		slog.Warn("Failed to load registered keys", "err", err)
+		return "", false
+	}
+	for _, k := range keys {
+		if k.Fingerprint == fingerprint && (k.Shed == "" || k.Shed == shed) {
+			return k.Name, true
+		}
+	}
+	return "", false
+}
+
+func (s *Store) load() ([]config.AuthorizedKey, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []config.AuthorizedKey{}, nil
+		}
+		return nil, fmt.Errorf("failed to read authorized keys: %w", err)
+	}
+
+	var keys []config.AuthorizedKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse authorized keys %s: %w", s.path, err)
+	}
+	return keys, nil
+}
+
+func (s *Store) save(keys []config.AuthorizedKey) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode authorized keys: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write atomically via temp file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write authorized keys: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp) // Clean up on failure
+		return fmt.Errorf("failed to save authorized keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package authkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// newKey returns a fresh public key and its authorized_keys line.
+func newKey(t *testing.T) (gossh.PublicKey, string) {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("NewPublicKey() failed: %v", err)
+	}
+	return key, strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))) + " me@laptop"
+}
+
+func TestStoreLifecycle(t *testing.T) {
+	store := NewStore(t.TempDir(), nil)
+	laptop, laptopLine := newKey(t)
+	ci, ciLine := newKey(t)
+
+	if store.Enabled() {
+		t.Error("Enabled() should be false with no keys")
+	}
+
+	if _, err := store.Add("laptop", "", laptopLine); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if _, err := store.Add("ci", "widget", ciLine); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if !store.Enabled() {
+		t.Error("Enabled() should be true once a key is added")
+	}
+
+	if _, err := store.Add("laptop", "", ciLine); err == nil {
+		t.Error("Add() should fail for an existing name")
+	}
+	if _, err := store.Add("again", "", laptopLine); err == nil {
+		t.Error("Add() should fail for an existing key")
+	}
+	if _, err := store.Add("bad", "", "not a key"); err == nil {
+		t.Error("Add() should fail for an invalid key")
+	}
+
+	if name, ok := store.Authorized("anything", laptop); !ok || name != "laptop" {
+		t.Errorf("Authorized(laptop) = %q, %v, want laptop, true", name, ok)
+	}
+	if _, ok := store.Authorized("widget", ci); !ok {
+		t.Error("shed key should be authorized for its shed")
+	}
+	if _, ok := store.Authorized("other", ci); ok {
+		t.Error("shed key should not be authorized for other sheds")
+	}
+
+	if err := store.Remove("laptop"); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if _, ok := store.Authorized("anything", laptop); ok {
+		t.Error("removed key should not be authorized")
+	}
+	if err := store.Remove("laptop"); err == nil {
+		t.Error("Remove() should fail for a missing key")
+	}
+}
+
+func TestStoreConcurrentAdds(t *testing.T) {
+	store := NewStore(t.TempDir(), nil)
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		_, line := newKey(t)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Add(fmt.Sprintf("key-%d", i), "", line); err != nil {
+				t.Errorf("Add() failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	keys, err := store.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(keys) != 10 {
+		t.Errorf("List() returned %d keys, want all 10 added", len(keys))
+	}
+}
+
+func TestStoreStaticKeys(t *testing.T) {
+	key, line := newKey(t)
+	other, _ := newKey(t)
+	store := NewStore(t.TempDir(), []string{line})
+
+	if !store.Enabled() {
+		t.Error("Enabled() should be true with a configured key")
+	}
+	if _, ok := store.Authorized("widget", key); !ok {
+		t.Error("configured key should be authorized")
+	}
+	if _, ok := store.Authorized("widget", other); ok {
+		t.Error("unknown key should not be authorized")
+	}
+}
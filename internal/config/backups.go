@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charliek/shed/internal/cron"
+	"github.com/charliek/shed/internal/s3"
+)
+
+// DefaultBackupKeep is how many backups of each shed are kept when
+// backups.keep isn't set.
+const DefaultBackupKeep = 7
+
+// BackupsConfig backs up shed workspaces to S3-compatible object storage.
+// Backups are enabled when S3.Bucket is set.
+type BackupsConfig struct {
+	// Schedule is a cron expression, in the server's timezone, at which
+	// every shed without its own backup schedule is backed up. Empty backs
+	// up only sheds with a schedule and on request.
+	Schedule string `yaml:"schedule"`
+
+	// Keep is how many backups of each shed to keep, newest first.
+	Keep int `yaml:"keep"`
+
+	// MaxAge removes backups older than this, though a shed's newest
+	// backup is always kept. Zero keeps backups regardless of age.
+	MaxAge time.Duration `yaml:"max_age"`
+
+	S3 s3.Config `yaml:"s3"`
+}
+
+// Enabled reports whether backups are configured.
+func (c BackupsConfig) Enabled() bool {
+	return c.S3.Bucket != ""
+}
+
+// Validate checks the schedule, retention, and storage settings.
+func (c BackupsConfig) Validate() error {
+	if !c.Enabled() {
+		if c.Schedule != "" {
+			return fmt.Errorf("schedule requires an s3 bucket")
+		}
+		return nil
+	}
+	if c.Schedule != "" {
+		if _, err := cron.Parse(c.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	if c.Keep < 0 {
+		return fmt.Errorf("invalid keep: %d", c.Keep)
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("invalid max_age: %s", c.MaxAge)
+	}
+	if err := c.S3.Validate(); err != nil {
+		return fmt.Errorf("invalid s3: %w", err)
+	}
+	return nil
+}
+
+// Backup is a copy of a shed's workspace in object storage.
+type Backup struct {
+	Shed string `json:"shed"`
+
+	// Name identifies the backup among the shed's, e.g. 20260102-030405.
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackupStatus is the outcome of a shed's most recent backups.
+type BackupStatus struct {
+	// Last is the newest successful backup, if any.
+	Last *Backup `json:"last,omitempty"`
+
+	// Error is why the latest attempt failed, at FailedAt. Both are
+	// cleared by a successful backup.
+	Error    string     `json:"error,omitempty"`
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+}
+
+// BackupsResponse is returned by GET /api/sheds/{name}/backups.
+type BackupsResponse struct {
+	Backups []Backup `json:"backups"`
+}
+
+// RestoreBackupRequest is the body of POST /api/sheds/{name}/restore.
+type RestoreBackupRequest struct {
+	// Backup names the backup to restore, as listed by
+	// GET /api/sheds/{name}/backups.
+	Backup string `json:"backup"`
+}
+
+// ValidateBackupName checks that a backup name can name one of a shed's
+// backups.
+func ValidateBackupName(name string) error {
+	if name == "" {
+		return fmt.Errorf("backup name cannot be empty")
+	}
+	if strings.Contains(name, "/") || name == "." || name == ".." {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/charliek/shed/internal/s3"
+)
+
+func TestBackupsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     BackupsConfig
+		wantErr bool
+	}{
+		{"disabled", BackupsConfig{}, false},
+		{"enabled", BackupsConfig{Schedule: "0 3 * * *", Keep: 7, S3: s3.Config{Bucket: "backups"}}, false},
+		{"custom endpoint", BackupsConfig{S3: s3.Config{Bucket: "backups", Endpoint: "http://minio.lan:9000"}}, false},
+		{"schedule without bucket", BackupsConfig{Schedule: "0 3 * * *"}, true},
+		{"bad schedule", BackupsConfig{Schedule: "nightly", S3: s3.Config{Bucket: "backups"}}, true},
+		{"bad endpoint", BackupsConfig{S3: s3.Config{Bucket: "backups", Endpoint: "minio.lan"}}, true},
+		{"negative keep", BackupsConfig{Keep: -1, S3: s3.Config{Bucket: "backups"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateBackupName(t *testing.T) {
+	for _, name := range []string{"20260102-030405", "manual"} {
+		if err := ValidateBackupName(name); err != nil {
+			t.Errorf("ValidateBackupName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../other/20260102-030405"} {
+		if err := ValidateBackupName(name); err == nil {
+			t.Errorf("ValidateBackupName(%q) = nil, want error", name)
+		}
+	}
+}
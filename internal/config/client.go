@@ -4,9 +4,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/charliek/shed/internal/terminal"
+)
+
+// SSH clients the CLI can connect to sheds with. Auto uses OpenSSH when
+// an ssh binary is installed and the built-in client otherwise.
+const (
+	SSHClientAuto    = "auto"
+	SSHClientOpenSSH = "openssh"
+	SSHClientNative  = "native"
 )
 
 // ClientConfig represents the CLI-side configuration.
@@ -15,27 +26,109 @@ type ClientConfig struct {
 	DefaultServer string                 `yaml:"default_server"`
 	Sheds         map[string]ShedCache   `yaml:"sheds"`
 
+	// SSHClient picks the SSH client for console, exec, and attach;
+	// empty means SSHClientAuto
+	SSHClient string `yaml:"ssh_client,omitempty"`
+
+	// SendEnv lists the local environment variables sent to sheds by
+	// console, exec, and attach, by name or pattern; unset sends the
+	// variables servers accept by default
+	SendEnv []string `yaml:"send_env,omitempty"`
+
 	// Path to config file (not serialized)
 	path string `yaml:"-"`
 }
 
+// Defaults for a server's API requests.
+const (
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultRequestRetries = 2
+)
+
 // ServerEntry represents a configured server.
 type ServerEntry struct {
 	Host     string    `yaml:"host"`
 	HTTPPort int       `yaml:"http_port"`
 	SSHPort  int       `yaml:"ssh_port"`
+	Token    string    `yaml:"token,omitempty"`
 	AddedAt  time.Time `yaml:"added_at"`
+
+	// Timeout bounds each API request; zero uses DefaultRequestTimeout
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Retries is how many times a failed idempotent request is retried;
+	// unset uses DefaultRequestRetries
+	Retries *int `yaml:"retries,omitempty"`
+
+	// SSH holds extra options for SSH connections to the server's sheds
+	SSH ServerSSHOptions `yaml:"ssh,omitempty"`
+}
+
+// SendEnvPatterns returns the patterns of environment variables to send to
+// sheds.
+func (c *ClientConfig) SendEnvPatterns() []string {
+	if c.SendEnv == nil {
+		return terminal.DefaultAcceptEnv
+	}
+	return c.SendEnv
+}
+
+// ValidateSSHClient checks that client names an SSH client. Empty is
+// allowed and means SSHClientAuto.
+func ValidateSSHClient(client string) error {
+	switch client {
+	case "", SSHClientAuto, SSHClientOpenSSH, SSHClientNative:
+		return nil
+	}
+	return fmt.Errorf("unknown SSH client %q (must be %s, %s, or %s)", client, SSHClientAuto, SSHClientOpenSSH, SSHClientNative)
+}
+
+// RequestTimeout returns how long an API request to the server may take.
+func (e *ServerEntry) RequestTimeout() time.Duration {
+	if e.Timeout == 0 {
+		return DefaultRequestTimeout
+	}
+	return e.Timeout
+}
+
+// RequestRetries returns how many times a failed idempotent request to the
+// server is retried.
+func (e *ServerEntry) RequestRetries() int {
+	if e.Retries == nil {
+		return DefaultRequestRetries
+	}
+	return *e.Retries
+}
+
+// Validate checks the server's request and SSH settings.
+func (e *ServerEntry) Validate() error {
+	if e.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if e.Retries != nil && *e.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	if err := e.SSH.Validate(); err != nil {
+		return fmt.Errorf("invalid ssh: %w", err)
+	}
+	return nil
 }
 
 // ShedCache caches the location of a shed.
 type ShedCache struct {
-	Server    string    `yaml:"server"`
-	Status    string    `yaml:"status"`
-	UpdatedAt time.Time `yaml:"updated_at"`
+	Server      string    `yaml:"server"`
+	Status      string    `yaml:"status"`
+	UpdatedAt   time.Time `yaml:"updated_at"`
+	LastSession string    `yaml:"last_session,omitempty"`
 }
 
-// GetClientConfigDir returns the path to the shed config directory.
+// GetClientConfigDir returns the path to the shed config directory:
+// ~/.shed, or shed in the roaming AppData folder on Windows.
 func GetClientConfigDir() string {
+	if runtime.GOOS == "windows" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, "shed")
+		}
+	}
 	return expandPath("~/.shed")
 }
 
@@ -83,6 +176,16 @@ func LoadClientConfigFromPath(path string) (*ClientConfig, error) {
 		cfg.Sheds = make(map[string]ShedCache)
 	}
 
+	if err := ValidateSSHClient(cfg.SSHClient); err != nil {
+		return nil, fmt.Errorf("invalid ssh_client in config file: %w", err)
+	}
+
+	for name, entry := range cfg.Servers {
+		if err := entry.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid server %q in config file: %w", name, err)
+		}
+	}
+
 	cfg.path = path
 	return cfg, nil
 }
@@ -105,6 +208,10 @@ func (c *ClientConfig) SaveToPath(path string) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
 	// Write atomically via temp file
 	tmpPath := path + ".tmp"
 	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
@@ -165,6 +272,47 @@ func (c *ClientConfig) RemoveServer(name string) error {
 	return nil
 }
 
+// RenameServer renames a server, updating the default server and any cached
+// sheds that point at it.
+func (c *ClientConfig) RenameServer(oldName, newName string) error {
+	entry, exists := c.Servers[oldName]
+	if !exists {
+		return fmt.Errorf("server '%s' not found", oldName)
+	}
+	if _, exists := c.Servers[newName]; exists {
+		return fmt.Errorf("server '%s' already exists", newName)
+	}
+
+	delete(c.Servers, oldName)
+	c.Servers[newName] = entry
+
+	if c.DefaultServer == oldName {
+		c.DefaultServer = newName
+	}
+
+	for shedName, cache := range c.Sheds {
+		if cache.Server == oldName {
+			cache.Server = newName
+			c.Sheds[shedName] = cache
+		}
+	}
+
+	return nil
+}
+
+// UpdateServer replaces the connection details of an existing server,
+// preserving when it was added.
+func (c *ClientConfig) UpdateServer(name string, entry ServerEntry) error {
+	existing, exists := c.Servers[name]
+	if !exists {
+		return fmt.Errorf("server '%s' not found", name)
+	}
+
+	entry.AddedAt = existing.AddedAt
+	c.Servers[name] = entry
+	return nil
+}
+
 // GetServer returns a server by name.
 func (c *ClientConfig) GetServer(name string) (*ServerEntry, error) {
 	entry, exists := c.Servers[name]
@@ -196,12 +344,35 @@ func (c *ClientConfig) SetDefaultServer(name string) error {
 }
 
 // CacheShed caches a shed's location.
+// The last attached session is preserved if the shed stays on the same server.
 func (c *ClientConfig) CacheShed(name string, server string, status string) {
+	var lastSession string
+	if existing, ok := c.Sheds[name]; ok && existing.Server == server {
+		lastSession = existing.LastSession
+	}
+
 	c.Sheds[name] = ShedCache{
-		Server:    server,
-		Status:    status,
-		UpdatedAt: time.Now(),
+		Server:      server,
+		Status:      status,
+		UpdatedAt:   time.Now(),
+		LastSession: lastSession,
+	}
+}
+
+// SetLastSession records the last tmux session attached to in a cached shed.
+func (c *ClientConfig) SetLastSession(name string, session string) {
+	cache, exists := c.Sheds[name]
+	if !exists {
+		return
 	}
+	cache.LastSession = session
+	c.Sheds[name] = cache
+}
+
+// GetLastSession returns the last tmux session attached to in a shed, or
+// an empty string if none has been recorded.
+func (c *ClientConfig) GetLastSession(name string) string {
+	return c.Sheds[name].LastSession
 }
 
 // GetShedServer returns the server that hosts a shed.
@@ -218,38 +389,6 @@ func (c *ClientConfig) RemoveShedCache(name string) {
 	delete(c.Sheds, name)
 }
 
-// AddKnownHost adds an SSH host key to the known_hosts file.
-func AddKnownHost(host string, port int, hostKey string) error {
-	knownHostsPath := GetKnownHostsPath()
-
-	// Ensure directory exists
-	dir := filepath.Dir(knownHostsPath)
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	// Format the entry
-	var entry string
-	if port == 22 {
-		entry = fmt.Sprintf("%s %s\n", host, hostKey)
-	} else {
-		entry = fmt.Sprintf("[%s]:%d %s\n", host, port, hostKey)
-	}
-
-	// Append to file
-	f, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to open known_hosts: %w", err)
-	}
-	defer f.Close()
-
-	if _, err := f.WriteString(entry); err != nil {
-		return fmt.Errorf("failed to write to known_hosts: %w", err)
-	}
-
-	return nil
-}
-
 // EnsureConfigDir ensures the config directory exists.
 func EnsureConfigDir() error {
 	dir := GetClientConfigDir()
@@ -3,7 +3,12 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/charliek/shed/internal/terminal"
 )
 
 func TestContainerName(t *testing.T) {
@@ -47,6 +52,22 @@ func TestVolumeName(t *testing.T) {
 	}
 }
 
+func TestRepoCacheVolumeName(t *testing.T) {
+	a := RepoCacheVolumeName("git@github.com:org/widget.git")
+	b := RepoCacheVolumeName("git@github.com:org/widget")
+	c := RepoCacheVolumeName("git@github.com:org/gadget.git")
+
+	if a != b {
+		t.Errorf("equivalent URLs should share a cache: %q != %q", a, b)
+	}
+	if a == c {
+		t.Errorf("different repos should not share a cache: %q", a)
+	}
+	if len(a) != len(RepoCacheVolumePrefix)+12 {
+		t.Errorf("RepoCacheVolumeName() = %q, want prefix plus 12 hex characters", a)
+	}
+}
+
 func TestNewAPIError(t *testing.T) {
 	err := NewAPIError(ErrShedNotFound, "Shed 'test' not found")
 
@@ -58,6 +79,139 @@ func TestNewAPIError(t *testing.T) {
 	}
 }
 
+func TestShedNameFromRepo(t *testing.T) {
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{"git@github.com:org/widget-factory.git", "widget-factory"},
+		{"https://github.com/org/Widget_Factory/", "widget-factory"},
+		{"https://gitlab.com/group/sub/api.v2.git", "api-v2"},
+		{"git@github.com:org/42-things.git", "things"},
+		{"https://github.com/org/" + strings.Repeat("a", 80), strings.Repeat("a", MaxShedNameLength)},
+		{"https://github.com/org/___", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			if got := ShedNameFromRepo(tt.repo); got != tt.want {
+				t.Errorf("ShedNameFromRepo(%q) = %q, want %q", tt.repo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateShedRequestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    CreateShedRequest
+		fields []string
+	}{
+		{"valid", CreateShedRequest{Name: "dev", Repo: "git@github.com:user/repo.git"}, nil},
+		{"missing name", CreateShedRequest{}, []string{"name"}},
+		{"name from repo", CreateShedRequest{Repo: "https://github.com/org/widget.git"}, nil},
+		{"bad name and repo", CreateShedRequest{Name: "Dev", Repo: "ftp://host/repo"}, []string{"name", "repo"}},
+		{"worktree without repo", CreateShedRequest{Name: "dev", Worktree: true}, []string{"repo"}},
+		{"branch without worktree", CreateShedRequest{Name: "dev", Branch: "main"}, []string{"branch"}},
+		{"shallow clone of tag", CreateShedRequest{Name: "dev", Repo: "git@github.com:user/repo.git", Ref: "v1.2.0", Depth: 1}, nil},
+		{"ref without repo", CreateShedRequest{Name: "dev", Ref: "main"}, []string{"ref"}},
+		{"shared network", CreateShedRequest{Name: "dev", Network: NetworkShared}, nil},
+		{"dedicated network", CreateShedRequest{Name: "dev", Network: NetworkDedicated}, nil},
+		{"unknown network", CreateShedRequest{Name: "dev", Network: "host"}, []string{"network"}},
+		{"service", CreateShedRequest{Name: "dev", Services: []Service{{Name: "db", Image: "postgres:16"}}}, nil},
+		{"service without image", CreateShedRequest{Name: "dev", Services: []Service{{Name: "db"}}}, []string{"services[0]"}},
+		{"ttl", CreateShedRequest{Name: "dev", TTL: "2h", TTLAction: TTLStop}, nil},
+		{"ttl too short", CreateShedRequest{Name: "dev", TTL: "30s"}, []string{"ttl"}},
+		{"ttl not a duration", CreateShedRequest{Name: "dev", TTL: "2 days"}, []string{"ttl"}},
+		{"ttl action without ttl", CreateShedRequest{Name: "dev", TTLAction: TTLDelete}, []string{"ttl_action"}},
+		{"invalid ttl action", CreateShedRequest{Name: "dev", TTL: "1h", TTLAction: "pause"}, []string{"ttl_action"}},
+		{"bad ref and depth", CreateShedRequest{Name: "dev", Repo: "git@github.com:user/repo.git", Ref: "--upload-pack=x", Depth: -1}, []string{"ref", "depth"}},
+		{"ref and depth in worktree", CreateShedRequest{Name: "dev", Repo: "git@github.com:user/repo.git", Worktree: true, Ref: "main", Depth: 1}, []string{"ref", "depth"}},
+		{"timezone and locale", CreateShedRequest{Name: "dev", Timezone: "Europe/Berlin", Locale: "de_DE.UTF-8"}, nil},
+		{"bad timezone and locale", CreateShedRequest{Name: "dev", Timezone: "Mars/Olympus", Locale: "english"}, []string{"timezone", "locale"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.req.Validate()
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("Validate() = %v, want errors for %v", errs, tt.fields)
+			}
+			for i, field := range tt.fields {
+				if errs[i].Field != field {
+					t.Errorf("errs[%d].Field = %q, want %q", i, errs[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateShedRequestCheckExisting(t *testing.T) {
+	shed := &Shed{Name: "api", Repo: "git@github.com:org/api.git", Image: "shed-base:latest"}
+
+	tests := []struct {
+		name    string
+		req     CreateShedRequest
+		wantErr bool
+	}{
+		{"nothing asked for", CreateShedRequest{Name: "api"}, false},
+		{"same repo and image", CreateShedRequest{Name: "api", Repo: shed.Repo, Image: shed.Image}, false},
+		{"different repo", CreateShedRequest{Name: "api", Repo: "git@github.com:org/web.git"}, true},
+		{"different image", CreateShedRequest{Name: "api", Image: "ubuntu:24.04"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.CheckExisting(shed); (err != nil) != tt.wantErr {
+				t.Errorf("CheckExisting() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildImageRequestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      BuildImageRequest
+		prefixes []string
+		fields   []string
+	}{
+		{"valid", BuildImageRequest{Tag: "shed-go:latest"}, nil, nil},
+		{"registry tag", BuildImageRequest{Tag: "ghcr.io/acme/shed-dev:1.2", Dockerfile: "docker/Dockerfile.dev"}, nil, nil},
+		{"missing tag", BuildImageRequest{}, nil, []string{"tag"}},
+		{"bad tag", BuildImageRequest{Tag: "Shed Go"}, nil, []string{"tag"}},
+		{"tag outside prefixes", BuildImageRequest{Tag: "other:latest"}, []string{"shed-"}, []string{"tag"}},
+		{"dockerfile outside context", BuildImageRequest{Tag: "shed-go:latest", Dockerfile: "../Dockerfile"}, nil, []string{"dockerfile"}},
+		{"absolute dockerfile", BuildImageRequest{Tag: "shed-go:latest", Dockerfile: "/etc/Dockerfile"}, nil, []string{"dockerfile"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.req.Validate(tt.prefixes)
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("Validate() = %v, want errors for %v", errs, tt.fields)
+			}
+			for i, field := range tt.fields {
+				if errs[i].Field != field {
+					t.Errorf("errs[%d].Field = %q, want %q", i, errs[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateLocale(t *testing.T) {
+	for _, locale := range []string{"en_US.UTF-8", "de_DE", "C.UTF-8", "POSIX", "sr_RS@latin"} {
+		if err := ValidateLocale(locale); err != nil {
+			t.Errorf("ValidateLocale(%q) = %v, want nil", locale, err)
+		}
+	}
+	for _, locale := range []string{"english", "en-US", "EN_us", "en_US.UTF-8; rm -rf /"} {
+		if err := ValidateLocale(locale); err == nil {
+			t.Errorf("ValidateLocale(%q) = nil, want error", locale)
+		}
+	}
+}
+
 func TestServerConfigDefaults(t *testing.T) {
 	cfg := DefaultServerConfig()
 
@@ -73,6 +227,32 @@ func TestServerConfigDefaults(t *testing.T) {
 	if cfg.LogLevel != "info" {
 		t.Errorf("LogLevel = %q, want %q", cfg.LogLevel, "info")
 	}
+	if cfg.Runtime != RuntimeDocker {
+		t.Errorf("Runtime = %q, want %q", cfg.Runtime, RuntimeDocker)
+	}
+}
+
+func TestServerConfigSafetyPushDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	data := "name: test\nsafety_push:\n  enabled: true\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := LoadServerConfigFromPath(path)
+	if err != nil {
+		t.Fatalf("LoadServerConfigFromPath() error = %v", err)
+	}
+
+	if !cfg.SafetyPush.Enabled {
+		t.Error("SafetyPush.Enabled = false, want true")
+	}
+	if cfg.SafetyPush.Remote != DefaultSafetyPushRemote {
+		t.Errorf("SafetyPush.Remote = %q, want %q", cfg.SafetyPush.Remote, DefaultSafetyPushRemote)
+	}
+	if cfg.SafetyPush.BackupDir != DefaultSafetyPushBackupDir {
+		t.Errorf("SafetyPush.BackupDir = %q, want %q", cfg.SafetyPush.BackupDir, DefaultSafetyPushBackupDir)
+	}
 }
 
 func TestServerConfigValidation(t *testing.T) {
@@ -101,6 +281,146 @@ func TestServerConfigValidation(t *testing.T) {
 			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "invalid"},
 			wantErr: true,
 		},
+		{
+			name:    "json log format",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "debug", LogFormat: LogFormatJSON},
+			wantErr: false,
+		},
+		{
+			name:    "invalid log format",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", LogFormat: "xml"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid timezone",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", Timezone: "Nowhere/City"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid locale",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", Locale: "utf8"},
+			wantErr: true,
+		},
+		{
+			name:    "podman runtime",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", Runtime: RuntimePodman},
+			wantErr: false,
+		},
+		{
+			name:    "invalid runtime",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", Runtime: "containerd"},
+			wantErr: true,
+		},
+		{
+			name:    "remote docker host",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", DockerHost: "tcp://build-box:2376", DockerTLS: DockerTLSConfig{CACert: "/etc/shed/ca.pem", Cert: "/etc/shed/cert.pem", Key: "/etc/shed/key.pem"}},
+			wantErr: false,
+		},
+		{
+			name:    "remote docker host with allowed paths",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", DockerHost: "tcp://build-box:2376", Mounts: MountsConfig{AllowedPaths: []string{"/srv/data"}}},
+			wantErr: true,
+		},
+		{
+			name:    "remote docker host with allowed volumes",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", DockerHost: "tcp://build-box:2376", Mounts: MountsConfig{AllowedVolumes: []string{"datasets"}}},
+			wantErr: false,
+		},
+		{
+			name:    "invalid docker host",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", DockerHost: "ssh://build-box"},
+			wantErr: true,
+		},
+		{
+			name:    "docker tls without tcp host",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", DockerTLS: DockerTLSConfig{CACert: "/etc/shed/ca.pem"}},
+			wantErr: true,
+		},
+		{
+			name:    "docker tls cert without key",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", DockerHost: "tcp://build-box:2376", DockerTLS: DockerTLSConfig{Cert: "/etc/shed/cert.pem"}},
+			wantErr: true,
+		},
+		{
+			name:    "idle timeout",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", IdleTimeout: 2 * time.Hour},
+			wantErr: false,
+		},
+		{
+			name:    "idle timeout too short",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", IdleTimeout: time.Minute},
+			wantErr: true,
+		},
+		{
+			name:    "rate limit",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", RateLimit: RateLimitConfig{RequestsPerMinute: 120, MaxConcurrentCreates: 2}},
+			wantErr: false,
+		},
+		{
+			name:    "negative rate limit",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", RateLimit: RateLimitConfig{MaxConcurrentCreates: -1}},
+			wantErr: true,
+		},
+		{
+			name:    "ssh keepalive and idle timeout",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", SSH: SSHConfig{KeepaliveInterval: 15 * time.Second, KeepaliveCountMax: 4, IdleTimeout: 24 * time.Hour}},
+			wantErr: false,
+		},
+		{
+			name:    "negative ssh idle timeout",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", SSH: SSHConfig{IdleTimeout: -time.Hour}},
+			wantErr: true,
+		},
+		{
+			name:    "bad accept_env pattern",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", Terminal: &terminal.Config{AcceptEnv: []string{"LC_["}}},
+			wantErr: true,
+		},
+		{
+			name:    "cors origins",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", CORSAllowedOrigins: []string{"https://shed.example.com", "http://localhost:5173", "*"}},
+			wantErr: false,
+		},
+		{
+			name:    "cors origin with path",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", CORSAllowedOrigins: []string{"https://shed.example.com/app"}},
+			wantErr: true,
+		},
+		{
+			name:    "cors origin without scheme",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", CORSAllowedOrigins: []string{"shed.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "trusted proxies",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8", "fd00::/8"}},
+			wantErr: false,
+		},
+		{
+			name:    "invalid trusted proxy",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", TrustedProxies: []string{"proxy.lan"}},
+			wantErr: true,
+		},
+		{
+			name:    "bind addresses",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", BindAddresses: []string{"100.64.0.5", "fd7a:115c::5", "[::1]"}},
+			wantErr: false,
+		},
+		{
+			name:    "bind address hostname",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", BindAddresses: []string{"mini-desktop.local"}},
+			wantErr: true,
+		},
+		{
+			name:    "publish address",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", PublishAddress: "0.0.0.0"},
+			wantErr: false,
+		},
+		{
+			name:    "publish address hostname",
+			cfg:     &ServerConfig{Name: "test", HTTPPort: 8080, SSHPort: 2222, LogLevel: "info", PublishAddress: "localhost"},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -159,6 +479,66 @@ func TestClientConfigSaveLoad(t *testing.T) {
 	}
 }
 
+func TestServerEntryRequestSettings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	data := `servers:
+  fast:
+    host: fast.local
+    http_port: 8080
+    ssh_port: 2222
+  slow:
+    host: slow.example.com
+    http_port: 8080
+    ssh_port: 2222
+    timeout: 2m
+    retries: 0
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadClientConfigFromPath(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	fast := cfg.Servers["fast"]
+	if got := fast.RequestTimeout(); got != DefaultRequestTimeout {
+		t.Errorf("fast RequestTimeout() = %v, want %v", got, DefaultRequestTimeout)
+	}
+	if got := fast.RequestRetries(); got != DefaultRequestRetries {
+		t.Errorf("fast RequestRetries() = %d, want %d", got, DefaultRequestRetries)
+	}
+
+	slow := cfg.Servers["slow"]
+	if got := slow.RequestTimeout(); got != 2*time.Minute {
+		t.Errorf("slow RequestTimeout() = %v, want 2m", got)
+	}
+	if got := slow.RequestRetries(); got != 0 {
+		t.Errorf("slow RequestRetries() = %d, want 0", got)
+	}
+
+	// Settings survive a save
+	if err := cfg.SaveToPath(configPath); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	saved, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), "timeout: 2m0s") || !strings.Contains(string(saved), "retries: 0") {
+		t.Errorf("saved config lost request settings:\n%s", saved)
+	}
+
+	bad := strings.Replace(data, "retries: 0", "retries: -1", 1)
+	if err := os.WriteFile(configPath, []byte(bad), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadClientConfigFromPath(configPath); err == nil {
+		t.Error("Load() should reject negative retries")
+	}
+}
+
 func TestClientConfigServerOperations(t *testing.T) {
 	cfg := &ClientConfig{
 		Servers: make(map[string]ServerEntry),
@@ -239,3 +619,245 @@ func TestClientConfigShedCache(t *testing.T) {
 		t.Error("GetShedServer() should fail for removed shed")
 	}
 }
+
+func TestClientConfigLastSession(t *testing.T) {
+	cfg := &ClientConfig{
+		Servers: make(map[string]ServerEntry),
+		Sheds:   make(map[string]ShedCache),
+	}
+
+	// Not recorded for uncached sheds
+	cfg.SetLastSession("myshed", "debug")
+	if got := cfg.GetLastSession("myshed"); got != "" {
+		t.Errorf("GetLastSession() = %q, want empty", got)
+	}
+
+	cfg.CacheShed("myshed", "server1", StatusRunning)
+	cfg.SetLastSession("myshed", "debug")
+	if got := cfg.GetLastSession("myshed"); got != "debug" {
+		t.Errorf("GetLastSession() = %q, want %q", got, "debug")
+	}
+
+	// Refreshing the cache on the same server keeps the session
+	cfg.CacheShed("myshed", "server1", StatusStopped)
+	if got := cfg.GetLastSession("myshed"); got != "debug" {
+		t.Errorf("GetLastSession() after refresh = %q, want %q", got, "debug")
+	}
+
+	// Moving to another server forgets it
+	cfg.CacheShed("myshed", "server2", StatusRunning)
+	if got := cfg.GetLastSession("myshed"); got != "" {
+		t.Errorf("GetLastSession() after move = %q, want empty", got)
+	}
+}
+
+func TestClientConfigRenameServer(t *testing.T) {
+	cfg := &ClientConfig{
+		Servers: make(map[string]ServerEntry),
+		Sheds:   make(map[string]ShedCache),
+	}
+
+	_ = cfg.AddServer("old", ServerEntry{Host: "host1", HTTPPort: 8080, SSHPort: 2222})
+	_ = cfg.AddServer("other", ServerEntry{Host: "host2", HTTPPort: 8080, SSHPort: 2222})
+	cfg.CacheShed("shed1", "old", StatusRunning)
+	cfg.SetLastSession("shed1", "debug")
+	cfg.CacheShed("shed2", "other", StatusRunning)
+
+	if err := cfg.RenameServer("old", "other"); err == nil {
+		t.Error("RenameServer() should fail when the new name exists")
+	}
+	if err := cfg.RenameServer("missing", "new"); err == nil {
+		t.Error("RenameServer() should fail for unknown server")
+	}
+
+	if err := cfg.RenameServer("old", "new"); err != nil {
+		t.Fatalf("RenameServer() failed: %v", err)
+	}
+
+	if _, err := cfg.GetServer("old"); err == nil {
+		t.Error("GetServer() should fail for the old name")
+	}
+	if server, err := cfg.GetServer("new"); err != nil || server.Host != "host1" {
+		t.Errorf("GetServer(new) = %v, %v; want host1", server, err)
+	}
+	if cfg.DefaultServer != "new" {
+		t.Errorf("DefaultServer = %q, want %q", cfg.DefaultServer, "new")
+	}
+	if server, _ := cfg.GetShedServer("shed1"); server != "new" {
+		t.Errorf("shed1 server = %q, want %q", server, "new")
+	}
+	if got := cfg.GetLastSession("shed1"); got != "debug" {
+		t.Errorf("shed1 last session = %q, want %q", got, "debug")
+	}
+	if server, _ := cfg.GetShedServer("shed2"); server != "other" {
+		t.Errorf("shed2 server = %q, want %q", server, "other")
+	}
+}
+
+func TestServerConfigPrepullImages(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []string
+		want   []string
+	}{
+		{"default image when none listed", nil, []string{"shed-base:latest"}},
+		{"listed images only", []string{"a:1", "b:2"}, []string{"a:1", "b:2"}},
+		{"duplicates and blanks dropped", []string{"a:1", "", "a:1", "b:2"}, []string{"a:1", "b:2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultServerConfig()
+			cfg.Prepull.Images = tt.images
+
+			got := cfg.PrepullImages()
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("PrepullImages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerConfigListenAddrs(t *testing.T) {
+	tests := []struct {
+		name     string
+		bind     []string
+		wantHTTP []string
+		wantSSH  []string
+	}{
+		{"every address when none listed", nil, []string{":8080"}, []string{":2222"}},
+		{"listed addresses", []string{"100.64.0.5", "fd7a:115c::5"}, []string{"100.64.0.5:8080", "[fd7a:115c::5]:8080"}, []string{"100.64.0.5:2222", "[fd7a:115c::5]:2222"}},
+		{"bracketed ipv6", []string{"[::1]"}, []string{"[::1]:8080"}, []string{"[::1]:2222"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultServerConfig()
+			cfg.BindAddresses = tt.bind
+
+			if got := cfg.HTTPAddrs(); strings.Join(got, ",") != strings.Join(tt.wantHTTP, ",") {
+				t.Errorf("HTTPAddrs() = %v, want %v", got, tt.wantHTTP)
+			}
+			if got := cfg.SSHAddrs(); strings.Join(got, ",") != strings.Join(tt.wantSSH, ",") {
+				t.Errorf("SSHAddrs() = %v, want %v", got, tt.wantSSH)
+			}
+		})
+	}
+}
+
+func TestHistoryAppendLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	entries, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("loadHistory() on missing file error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("len(entries) = %d, want 0", len(entries))
+	}
+
+	first := HistoryEntry{Command: "shed create foo", Shed: "foo", Result: HistoryResultOK, Undo: "shed delete foo"}
+	second := HistoryEntry{Command: "shed delete bar", Shed: "bar", Result: HistoryResultError, Error: "not found"}
+	for _, e := range []HistoryEntry{first, second} {
+		if err := appendHistory(path, e); err != nil {
+			t.Fatalf("appendHistory() error = %v", err)
+		}
+	}
+
+	entries, err = loadHistory(path)
+	if err != nil {
+		t.Fatalf("loadHistory() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+	if entries[0].Undo != "shed delete foo" {
+		t.Errorf("entries[0].Undo = %q, want %q", entries[0].Undo, "shed delete foo")
+	}
+	if entries[1].Error != "not found" {
+		t.Errorf("entries[1].Error = %q, want %q", entries[1].Error, "not found")
+	}
+}
+
+func TestHistoryCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	full := make([]HistoryEntry, 2*MaxHistoryEntries)
+	for i := range full {
+		full[i] = HistoryEntry{Command: "shed start foo", Result: HistoryResultOK}
+	}
+	if err := writeHistory(path, full); err != nil {
+		t.Fatalf("writeHistory() error = %v", err)
+	}
+
+	if err := appendHistory(path, HistoryEntry{Command: "shed stop foo", Result: HistoryResultOK}); err != nil {
+		t.Fatalf("appendHistory() error = %v", err)
+	}
+
+	entries, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("loadHistory() error = %v", err)
+	}
+	if len(entries) != MaxHistoryEntries {
+		t.Fatalf("len(entries) = %d, want %d", len(entries), MaxHistoryEntries)
+	}
+	if last := entries[len(entries)-1].Command; last != "shed stop foo" {
+		t.Errorf("last entry = %q, want %q", last, "shed stop foo")
+	}
+}
+
+func TestForwardsSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forwards.yaml")
+
+	forwards, err := loadForwards(path)
+	if err != nil {
+		t.Fatalf("loadForwards() on missing file error = %v", err)
+	}
+	if len(forwards) != 0 {
+		t.Fatalf("len(forwards) = %d, want 0", len(forwards))
+	}
+
+	want := []Forward{
+		{Shed: "web", Server: "mini", LocalPort: 3000, RemotePort: 3000},
+		{Shed: "api", Server: "cloud", LocalPort: 18080, RemotePort: 8080},
+	}
+	if err := saveForwards(path, want); err != nil {
+		t.Fatalf("saveForwards() error = %v", err)
+	}
+
+	forwards, err = loadForwards(path)
+	if err != nil {
+		t.Fatalf("loadForwards() error = %v", err)
+	}
+	if !reflect.DeepEqual(forwards, want) {
+		t.Errorf("loadForwards() = %+v, want %+v", forwards, want)
+	}
+}
+
+func TestForwardValidate(t *testing.T) {
+	valid := Forward{Shed: "web", Server: "mini", LocalPort: 3000, RemotePort: 3000}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	invalid := []Forward{
+		{Shed: "Web", Server: "mini", LocalPort: 3000, RemotePort: 3000},
+		{Shed: "web", LocalPort: 3000, RemotePort: 3000},
+		{Shed: "web", Server: "mini", LocalPort: 0, RemotePort: 3000},
+		{Shed: "web", Server: "mini", LocalPort: 3000, RemotePort: 70000},
+	}
+	for _, f := range invalid {
+		if err := f.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", f)
+		}
+	}
+}
+
+func TestSSHConfigKeepaliveCount(t *testing.T) {
+	if got := (SSHConfig{}).KeepaliveCount(); got != DefaultSSHKeepaliveCountMax {
+		t.Errorf("KeepaliveCount() unset = %d, want %d", got, DefaultSSHKeepaliveCountMax)
+	}
+	if got := (SSHConfig{KeepaliveCountMax: 5}).KeepaliveCount(); got != 5 {
+		t.Errorf("KeepaliveCount() = %d, want 5", got)
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxEnvValueLength limits the value of a per-shed environment variable.
+const MaxEnvValueLength = 4096
+
+// envNameRegex matches environment variable names such as NODE_ENV.
+var envNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedEnv are variables shed sets itself, mapped to the setting to use
+// instead where there is one.
+var reservedEnv = map[string]string{
+	"TERM":            "",
+	"SHED_NAME":       "",
+	"TZ":              "timezone",
+	"LANG":            "locale",
+	"GIT_SSH_COMMAND": "deploy_key",
+}
+
+// UpdateEnvRequest is the request body for PATCH /api/sheds/{name}/env.
+// Variables in Set are added or replaced, then variables in Remove are
+// deleted.
+type UpdateEnvRequest struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// ValidateEnvName checks that name can be set as a shed environment
+// variable.
+func ValidateEnvName(name string) error {
+	if !envNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name %q: must be letters, digits, and '_', not starting with a digit", name)
+	}
+	if setting, ok := reservedEnv[name]; ok {
+		if setting != "" {
+			return fmt.Errorf("environment variable %s is set by shed; use the %s setting instead", name, setting)
+		}
+		return fmt.Errorf("environment variable %s is set by shed", name)
+	}
+	return nil
+}
+
+// ValidateEnv checks the names and values of a set of environment
+// variables.
+func ValidateEnv(env map[string]string) error {
+	for _, name := range SortedLabelKeys(env) {
+		if err := ValidateEnvName(name); err != nil {
+			return err
+		}
+		value := env[name]
+		if len(value) > MaxEnvValueLength {
+			return fmt.Errorf("environment variable %s value is longer than %d characters", name, MaxEnvValueLength)
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("environment variable %s value contains a NUL character", name)
+		}
+	}
+	return nil
+}
+
+// ParseEnv parses an environment variable written as NAME=value.
+func ParseEnv(spec string) (name, value string, err error) {
+	name, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid environment variable %q: must be NAME=value", spec)
+	}
+	if err := ValidateEnv(map[string]string{name: value}); err != nil {
+		return "", "", err
+	}
+	return name, value, nil
+}
+
+// EnvList returns env as NAME=value pairs in name order.
+func EnvList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for _, name := range SortedLabelKeys(env) {
+		list = append(list, name+"="+env[name])
+	}
+	return list
+}
+
+// Validate checks the variables being set and removed.
+func (r *UpdateEnvRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if len(r.Set) == 0 && len(r.Remove) == 0 {
+		errs.Add("set", FieldRequired, "no environment variables to set or remove")
+	}
+	errs.Check("set", ValidateEnv(r.Set))
+	for i, name := range r.Remove {
+		errs.Check(fmt.Sprintf("remove[%d]", i), ValidateEnvName(name))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	tests := []struct {
+		spec        string
+		name, value string
+		wantErr     bool
+	}{
+		{"NODE_ENV=development", "NODE_ENV", "development", false},
+		{"DEBUG=", "DEBUG", "", false},
+		{"_OPTS=a=b", "_OPTS", "a=b", false},
+		{"DEBUG", "", "", true},
+		{"=1", "", "", true},
+		{"1DEBUG=1", "", "", true},
+		{"MY-VAR=1", "", "", true},
+		{"TZ=UTC", "", "", true},
+		{"SHED_NAME=x", "", "", true},
+		{"BIG=" + strings.Repeat("x", MaxEnvValueLength+1), "", "", true},
+		{"NUL=a\x00b", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			name, value, err := ParseEnv(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if name != tt.name || value != tt.value {
+				t.Errorf("ParseEnv() = %q, %q; want %q, %q", name, value, tt.name, tt.value)
+			}
+		})
+	}
+}
+
+func TestEnvList(t *testing.T) {
+	got := EnvList(map[string]string{"B": "2", "A": "1=1"})
+	if want := "A=1=1,B=2"; strings.Join(got, ",") != want {
+		t.Errorf("EnvList() = %q, want %q", got, want)
+	}
+}
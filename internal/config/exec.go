@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limits for POST /api/sheds/{name}/exec.
+const (
+	DefaultExecTimeout = 10 * time.Minute
+	MaxExecTimeout     = time.Hour
+
+	// MaxExecOutput is how much of each of a command's stdout and stderr
+	// is returned; only the end of longer output is kept. Streamed output
+	// isn't limited.
+	MaxExecOutput = 1 << 20
+)
+
+// Streams of ExecOutput.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// ExecRequest is the request body for POST /api/sheds/{name}/exec.
+type ExecRequest struct {
+	// Command is run by sh -c in the workspace.
+	Command string `json:"command"`
+
+	// Timeout is how long to wait for the command, as a duration such as
+	// "30m". Empty waits DefaultExecTimeout.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Validate checks the command and timeout.
+func (r *ExecRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if strings.TrimSpace(r.Command) == "" {
+		errs.Add("command", FieldRequired, "command is required")
+	}
+	if r.Timeout != "" {
+		if _, err := r.TimeoutDuration(); err != nil {
+			errs.Check("timeout", err)
+		}
+	}
+	return errs
+}
+
+// TimeoutDuration returns how long to wait for the command.
+func (r *ExecRequest) TimeoutDuration() (time.Duration, error) {
+	if r.Timeout == "" {
+		return DefaultExecTimeout, nil
+	}
+	d, err := time.ParseDuration(r.Timeout)
+	if err != nil || d <= 0 || d > MaxExecTimeout {
+		return 0, fmt.Errorf("timeout must be a positive duration of at most %s", MaxExecTimeout)
+	}
+	return d, nil
+}
+
+// ExecResult is the outcome of a command run with POST
+// /api/sheds/{name}/exec. When the output was streamed, Stdout and Stderr
+// are empty.
+type ExecResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+
+	// Truncated is set when only the last MaxExecOutput bytes of stdout or
+	// stderr are included.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ExecOutput is a chunk of a command's output in an "output" event of a
+// streamed exec.
+type ExecOutput struct {
+	Stream string `json:"stream"`
+	Data   string `json:"data"`
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ForwardingRules limit which ports SSH clients may forward to (ssh -L) or
+// from (ssh -R) a shed. Each rule is a port such as "3000" or a range such
+// as "8000-8099". Deny wins over Allow, and an empty Allow permits any port.
+type ForwardingRules struct {
+	Allow []string `yaml:"allow" json:"allow,omitempty"`
+	Deny  []string `yaml:"deny" json:"deny,omitempty"`
+}
+
+// ForwardingConfig is the server's SSH port forwarding policy.
+type ForwardingConfig struct {
+	// Local allows ssh -L connections into sheds
+	Local bool `yaml:"local"`
+	// Remote allows ssh -R listeners that sheds can connect out to
+	Remote bool `yaml:"remote"`
+
+	ForwardingRules `yaml:",inline"`
+}
+
+// Permits reports whether port may be forwarded under the rules.
+func (r ForwardingRules) Permits(port uint32) bool {
+	for _, rule := range r.Deny {
+		if portRuleMatches(rule, port) {
+			return false
+		}
+	}
+	if len(r.Allow) == 0 {
+		return true
+	}
+	for _, rule := range r.Allow {
+		if portRuleMatches(rule, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsZero reports whether no rules are set.
+func (r ForwardingRules) IsZero() bool {
+	return len(r.Allow) == 0 && len(r.Deny) == 0
+}
+
+// Validate checks that every rule is a valid port or port range.
+func (r ForwardingRules) Validate() error {
+	for _, rule := range append(append([]string{}, r.Allow...), r.Deny...) {
+		if _, _, err := parsePortRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func portRuleMatches(rule string, port uint32) bool {
+	lo, hi, err := parsePortRule(rule)
+	return err == nil && port >= lo && port <= hi
+}
+
+// parsePortRule parses "N" or "N-M" into an inclusive port range.
+func parsePortRule(rule string) (uint32, uint32, error) {
+	loStr, hiStr, isRange := strings.Cut(strings.TrimSpace(rule), "-")
+	if !isRange {
+		hiStr = loStr
+	}
+
+	lo, err := strconv.ParseUint(loStr, 10, 16)
+	if err != nil || lo == 0 {
+		return 0, 0, fmt.Errorf("invalid port rule %q: must be a port or range like 8000-8099", rule)
+	}
+	hi, err := strconv.ParseUint(hiStr, 10, 16)
+	if err != nil || hi < lo {
+		return 0, 0, fmt.Errorf("invalid port rule %q: must be a port or range like 8000-8099", rule)
+	}
+	return uint32(lo), uint32(hi), nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import "testing"
+
+func TestForwardingRulesPermits(t *testing.T) {
+	rules := ForwardingRules{
+		Allow: []string{"3000", "8000-8099"},
+		Deny:  []string{"8022"},
+	}
+
+	tests := []struct {
+		port uint32
+		want bool
+	}{
+		{3000, true},
+		{3001, false},
+		{8000, true},
+		{8099, true},
+		{8022, false},
+		{22, false},
+	}
+	for _, tt := range tests {
+		if got := rules.Permits(tt.port); got != tt.want {
+			t.Errorf("Permits(%d) = %v, want %v", tt.port, got, tt.want)
+		}
+	}
+
+	if !(ForwardingRules{}).Permits(22) {
+		t.Error("empty rules should permit any port")
+	}
+	if (ForwardingRules{Deny: []string{"1-65535"}}).Permits(80) {
+		t.Error("deny should win with no allow rules")
+	}
+}
+
+func TestForwardingRulesValidate(t *testing.T) {
+	valid := ForwardingRules{Allow: []string{"22", "1000-2000"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+
+	for _, rule := range []string{"", "0", "abc", "70000", "2000-1000", "1-"} {
+		r := ForwardingRules{Deny: []string{rule}}
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%q) should fail", rule)
+		}
+	}
+}
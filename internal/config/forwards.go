@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Forward is a persistent port forward from a local port to a port in a
+// shed, kept up by the forward daemon.
+type Forward struct {
+	Shed       string `yaml:"shed" json:"shed"`
+	Server     string `yaml:"server" json:"server"`
+	LocalPort  int    `yaml:"local_port" json:"local_port"`
+	RemotePort int    `yaml:"remote_port" json:"remote_port"`
+}
+
+// Validate checks that a forward names a shed and valid ports.
+func (f Forward) Validate() error {
+	if err := ValidateShedName(f.Shed); err != nil {
+		return err
+	}
+	if f.Server == "" {
+		return fmt.Errorf("forward to %s has no server", f.Shed)
+	}
+	if f.LocalPort < 1 || f.LocalPort > 65535 {
+		return fmt.Errorf("invalid local port %d", f.LocalPort)
+	}
+	if f.RemotePort < 1 || f.RemotePort > 65535 {
+		return fmt.Errorf("invalid remote port %d", f.RemotePort)
+	}
+	return nil
+}
+
+// forwardsFile is the on-disk form of the forwards list.
+type forwardsFile struct {
+	Forwards []Forward `yaml:"forwards"`
+}
+
+// GetForwardsPath returns the path to the list of persistent forwards.
+func GetForwardsPath() string {
+	return filepath.Join(GetClientConfigDir(), "forwards.yaml")
+}
+
+// GetForwardDaemonPIDPath returns the path to the forward daemon's PID file.
+func GetForwardDaemonPIDPath() string {
+	return filepath.Join(GetClientConfigDir(), "forward.pid")
+}
+
+// GetForwardDaemonLogPath returns the path to the forward daemon's log.
+func GetForwardDaemonLogPath() string {
+	return filepath.Join(GetClientConfigDir(), "forward.log")
+}
+
+// LoadForwards returns the persistent forwards. A missing list has none.
+func LoadForwards() ([]Forward, error) {
+	return loadForwards(GetForwardsPath())
+}
+
+// SaveForwards replaces the persistent forwards.
+func SaveForwards(forwards []Forward) error {
+	return saveForwards(GetForwardsPath(), forwards)
+}
+
+// loadForwards reads the forwards list at path.
+func loadForwards(path string) ([]Forward, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read forwards: %w", err)
+	}
+
+	var file forwardsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse forwards: %w", err)
+	}
+	return file.Forwards, nil
+}
+
+// saveForwards writes the forwards list to path atomically.
+func saveForwards(path string, forwards []Forward) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(forwardsFile{Forwards: forwards})
+	if err != nil {
+		return fmt.Errorf("failed to encode forwards: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write forwards: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write forwards: %w", err)
+	}
+	return nil
+}
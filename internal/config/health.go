@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Health of a shed with a health check, from its image or its create
+// request. Sheds without one have no health.
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// MinHealthInterval is the shortest interval between health checks Docker
+// accepts.
+const MinHealthInterval = time.Millisecond
+
+// HealthCheck is a command run periodically in a shed to tell whether its
+// environment is usable, not just whether the container is running. It
+// replaces any health check defined by the shed's image.
+type HealthCheck struct {
+	// Command is run with the container's shell. It must exit 0 when the
+	// shed is healthy.
+	Command string `json:"command" yaml:"command"`
+
+	// Interval, Timeout, and StartPeriod are Go durations, such as "30s".
+	// Unset values use Docker's defaults. Failures during StartPeriod
+	// don't count against Retries.
+	Interval    string `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout     string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	StartPeriod string `json:"start_period,omitempty" yaml:"start_period,omitempty"`
+
+	// Retries is how many failures in a row make the shed unhealthy.
+	Retries int `json:"retries,omitempty" yaml:"retries,omitempty"`
+}
+
+// Validate checks the command, durations, and retries.
+func (h HealthCheck) Validate() error {
+	if strings.TrimSpace(h.Command) == "" {
+		return fmt.Errorf("health check needs a command")
+	}
+	for _, d := range []struct{ name, value string }{
+		{"interval", h.Interval},
+		{"timeout", h.Timeout},
+		{"start_period", h.StartPeriod},
+	} {
+		if _, err := parseHealthDuration(d.name, d.value); err != nil {
+			return err
+		}
+	}
+	if h.Retries < 0 {
+		return fmt.Errorf("invalid health check retries %d: must not be negative", h.Retries)
+	}
+	return nil
+}
+
+// Durations returns the check's interval, timeout, and start period, zero
+// for unset values.
+func (h HealthCheck) Durations() (interval, timeout, startPeriod time.Duration, err error) {
+	if interval, err = parseHealthDuration("interval", h.Interval); err != nil {
+		return
+	}
+	if timeout, err = parseHealthDuration("timeout", h.Timeout); err != nil {
+		return
+	}
+	startPeriod, err = parseHealthDuration("start_period", h.StartPeriod)
+	return
+}
+
+func parseHealthDuration(name, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid health check %s %q: expected a duration such as 30s", name, s)
+	}
+	if d < MinHealthInterval {
+		return 0, fmt.Errorf("invalid health check %s %q: must be at least %s", name, s, MinHealthInterval)
+	}
+	return d, nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import "testing"
+
+func TestHealthCheckValidate(t *testing.T) {
+	tests := []struct {
+		check   HealthCheck
+		wantErr bool
+	}{
+		{HealthCheck{Command: "test -f /workspace/.ready"}, false},
+		{HealthCheck{Command: "curl -fs localhost:3000", Interval: "10s", Timeout: "5s", StartPeriod: "2m", Retries: 5}, false},
+		{HealthCheck{}, true},
+		{HealthCheck{Command: "  "}, true},
+		{HealthCheck{Command: "true", Interval: "often"}, true},
+		{HealthCheck{Command: "true", Timeout: "-1s"}, true},
+		{HealthCheck{Command: "true", Retries: -1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.check.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.check, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxHistoryEntries is the number of history entries kept; older entries are
+// dropped when the log grows past twice this size.
+const MaxHistoryEntries = 1000
+
+// Results recorded in history entries.
+const (
+	HistoryResultOK    = "ok"
+	HistoryResultError = "error"
+)
+
+// HistoryEntry records one CLI command that changed a shed or server.
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Shed    string    `json:"shed,omitempty"`
+	Server  string    `json:"server,omitempty"`
+	Result  string    `json:"result"`
+	Error   string    `json:"error,omitempty"`
+
+	// Undo is a command that reverses this one, if there is one
+	Undo string `json:"undo,omitempty"`
+}
+
+// GetHistoryPath returns the path to the command history log.
+func GetHistoryPath() string {
+	return filepath.Join(GetClientConfigDir(), "history.jsonl")
+}
+
+// AppendHistory adds an entry to the command history log.
+func AppendHistory(entry HistoryEntry) error {
+	return appendHistory(GetHistoryPath(), entry)
+}
+
+// LoadHistory returns the entries in the command history log, oldest first.
+// A missing log has no entries.
+func LoadHistory() ([]HistoryEntry, error) {
+	return loadHistory(GetHistoryPath())
+}
+
+// appendHistory appends an entry to the log at path, compacting the log to
+// the most recent MaxHistoryEntries once it holds twice that many.
+func appendHistory(path string, entry HistoryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	entries, err := loadHistory(path)
+	if err != nil || len(entries) <= 2*MaxHistoryEntries {
+		return err
+	}
+	return writeHistory(path, entries[len(entries)-MaxHistoryEntries:])
+}
+
+// loadHistory reads the log at path. Lines that cannot be parsed are skipped.
+func loadHistory(path string) ([]HistoryEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// writeHistory replaces the log at path with entries.
+func writeHistory(path string, entries []HistoryEntry) error {
+	var b strings.Builder
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NormalizeHost strips the brackets from an IPv6 address written as in a
+// URL, such as [fd7a:115c::5], so a host compares and formats the same
+// however it was typed. Other hosts are returned unchanged.
+func NormalizeHost(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// HostPort joins a host and port into an address such as example.com:8080,
+// bracketing IPv6 addresses as in [fd7a:115c::5]:8080.
+func HostPort(host string, port int) string {
+	return net.JoinHostPort(NormalizeHost(host), strconv.Itoa(port))
+}
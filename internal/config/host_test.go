@@ -0,0 +1,35 @@
+package config
+
+import "testing"
+
+func TestNormalizeHost(t *testing.T) {
+	tests := map[string]string{
+		"mini-desktop.local": "mini-desktop.local",
+		"100.64.0.5":         "100.64.0.5",
+		"fd7a:115c::5":       "fd7a:115c::5",
+		"[fd7a:115c::5]":     "fd7a:115c::5",
+		"[fe80::1%eth0]":     "fe80::1%eth0",
+	}
+	for host, want := range tests {
+		if got := NormalizeHost(host); got != want {
+			t.Errorf("NormalizeHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"mini-desktop.local", "mini-desktop.local:8080"},
+		{"100.64.0.5", "100.64.0.5:8080"},
+		{"fd7a:115c::5", "[fd7a:115c::5]:8080"},
+		{"[fd7a:115c::5]", "[fd7a:115c::5]:8080"},
+	}
+	for _, tt := range tests {
+		if got := HostPort(tt.host, 8080); got != tt.want {
+			t.Errorf("HostPort(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// DefaultImageGCInterval is how often images are collected when
+// image_gc.interval isn't set.
+const DefaultImageGCInterval = time.Hour
+
+// ImageGCConfig removes images sheds have stopped using, so long-lived hosts
+// don't fill their disks with old base images. Collection is enabled when
+// UnusedDays is set.
+//
+// Only images a shed has used since collection was enabled are candidates:
+// the server notes which images shed and sidecar containers use each time it
+// collects, and an image is removed once no container has used it for
+// UnusedDays. Images any container still uses are never removed.
+type ImageGCConfig struct {
+	// UnusedDays is how long an image must go unused by every shed before
+	// it is removed.
+	UnusedDays int `yaml:"unused_days"`
+
+	// Interval is how often the server collects images.
+	Interval time.Duration `yaml:"interval"`
+
+	// Keep lists image names never to remove, as patterns such as
+	// shed-base:* or ghcr.io/acme/*. The default and pre-pulled images are
+	// always kept.
+	Keep []string `yaml:"keep"`
+}
+
+// Enabled reports whether image collection is configured.
+func (c ImageGCConfig) Enabled() bool {
+	return c.UnusedDays > 0
+}
+
+// Validate checks the age, interval, and keep patterns.
+func (c ImageGCConfig) Validate() error {
+	if c.UnusedDays < 0 {
+		return fmt.Errorf("invalid unused_days: %d", c.UnusedDays)
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("invalid interval: %s", c.Interval)
+	}
+	for i, pattern := range c.Keep {
+		if pattern == "" {
+			return fmt.Errorf("keep[%d]: pattern cannot be empty", i)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("keep[%d]: invalid pattern %q", i, pattern)
+		}
+	}
+	return nil
+}
+
+// Keeps reports whether ref, such as shed-base:latest, matches a keep
+// pattern.
+func (c ImageGCConfig) Keeps(ref string) bool {
+	for _, pattern := range c.Keep {
+		if ok, _ := path.Match(pattern, ref); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageGCStatus reports image collection on GET /api/info.
+type ImageGCStatus struct {
+	UnusedDays int `json:"unused_days"`
+
+	// LastRun is when images were last collected, and LastRemoved and
+	// LastReclaimed what that run removed and freed in bytes.
+	LastRun       *time.Time `json:"last_run,omitempty"`
+	LastRemoved   int        `json:"last_removed"`
+	LastReclaimed int64      `json:"last_reclaimed"`
+
+	// TotalRemoved and TotalReclaimed count every image collected since
+	// collection was enabled, including by shed-server gc.
+	TotalRemoved   int   `json:"total_removed"`
+	TotalReclaimed int64 `json:"total_reclaimed"`
+
+	// Error is why the last run failed, if it did.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestImageGCConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ImageGCConfig
+		wantErr bool
+	}{
+		{"disabled", ImageGCConfig{}, false},
+		{"enabled", ImageGCConfig{UnusedDays: 14, Interval: time.Hour, Keep: []string{"shed-base:*", "ghcr.io/acme/*"}}, false},
+		{"negative days", ImageGCConfig{UnusedDays: -1}, true},
+		{"negative interval", ImageGCConfig{UnusedDays: 14, Interval: -time.Minute}, true},
+		{"empty pattern", ImageGCConfig{UnusedDays: 14, Keep: []string{""}}, true},
+		{"bad pattern", ImageGCConfig{UnusedDays: 14, Keep: []string{"shed-[base"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestImageGCConfigKeeps(t *testing.T) {
+	cfg := ImageGCConfig{Keep: []string{"shed-base:*", "ghcr.io/acme/*"}}
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"shed-base:latest", true},
+		{"shed-base:2026-01", true},
+		{"ghcr.io/acme/dev:1.2", true},
+		{"ghcr.io/acme/team/dev:1.2", false},
+		{"shed-go:latest", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.Keeps(tt.ref); got != tt.want {
+			t.Errorf("Keeps(%q) = %v, want %v", tt.ref, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GetKnownHosts returns the host keys recorded for a host and port in the
+// known_hosts file, in file order. A server rotating its host key has two.
+func GetKnownHosts(host string, port int) ([]string, error) {
+	return findKnownHosts(GetKnownHostsPath(), host, port)
+}
+
+// RemoveKnownHost removes all entries for a host and port from the known_hosts file.
+func RemoveKnownHost(host string, port int) error {
+	return replaceKnownHosts(GetKnownHostsPath(), host, port, port, nil)
+}
+
+// ReplaceKnownHosts replaces the entries for a host and oldPort in the
+// known_hosts file with one entry for host and port per key, in a single
+// write so no connection sees a file without them.
+func ReplaceKnownHosts(host string, oldPort, port int, keys []string) error {
+	return replaceKnownHosts(GetKnownHostsPath(), host, oldPort, port, keys)
+}
+
+// knownHostPattern returns the known_hosts host pattern for a host and
+// port, as OpenSSH writes it: bare on port 22 and bracketed with the port
+// otherwise, for IPv6 addresses too.
+func knownHostPattern(host string, port int) string {
+	host = NormalizeHost(host)
+	if port == 22 {
+		return host
+	}
+	return fmt.Sprintf("[%s]:%d", host, port)
+}
+
+// knownHostLine is a line of a known_hosts file. Lines that aren't a plain
+// entry, such as comments and @cert-authority or @revoked entries, have no
+// patterns and are kept as they are.
+type knownHostLine struct {
+	patterns []string
+	key      string
+	raw      string
+}
+
+// parseKnownHostLine parses a known_hosts line. The key keeps only its type
+// and data, dropping any comment, so equal keys compare equal.
+func parseKnownHostLine(line string) knownHostLine {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "@") {
+		return knownHostLine{raw: line}
+	}
+	return knownHostLine{
+		patterns: strings.Split(fields[0], ","),
+		key:      fields[1] + " " + fields[2],
+		raw:      line,
+	}
+}
+
+// normalizeKnownHostKey returns a key's type and data without its comment.
+func normalizeKnownHostKey(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return strings.TrimSpace(key)
+	}
+	return fields[0] + " " + fields[1]
+}
+
+// String returns the line as it should be written.
+func (l knownHostLine) String() string {
+	if l.patterns == nil {
+		return l.raw
+	}
+	return strings.Join(l.patterns, ",") + " " + l.key
+}
+
+// readKnownHosts returns the lines of a known_hosts file, without blank
+// lines. A missing file has none.
+func readKnownHosts(path string) ([]knownHostLine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+
+	var lines []knownHostLine
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, parseKnownHostLine(line))
+	}
+	return lines, nil
+}
+
+// findKnownHosts returns the distinct keys of the entries matching host and port.
+func findKnownHosts(path, host string, port int) ([]string, error) {
+	lines, err := readKnownHosts(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := knownHostPattern(host, port)
+	var keys []string
+	for _, line := range lines {
+		if slices.Contains(line.patterns, pattern) && !slices.Contains(keys, line.key) {
+			keys = append(keys, line.key)
+		}
+	}
+
+	return keys, nil
+}
+
+// replaceKnownHosts rewrites the known_hosts file without entries matching
+// host and oldPort, adding an entry for host and port per key. Duplicate
+// entries are dropped along the way, so the file doesn't grow as servers
+// are re-added or change ports.
+func replaceKnownHosts(path, host string, oldPort, port int, keys []string) error {
+	lines, err := readKnownHosts(path)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 && len(keys) == 0 {
+		return nil // Nothing to remove
+	}
+
+	oldPattern := knownHostPattern(host, oldPort)
+	var kept []string
+	for _, line := range lines {
+		if line.patterns != nil {
+			// Entries shared with other hosts keep those hosts
+			line.patterns = slices.DeleteFunc(line.patterns, func(p string) bool { return p == oldPattern })
+			if len(line.patterns) == 0 {
+				continue
+			}
+		}
+		if s := line.String(); line.patterns == nil || !slices.Contains(kept, s) {
+			kept = append(kept, s)
+		}
+	}
+	for _, key := range keys {
+		entry := knownHostPattern(host, port) + " " + normalizeKnownHostKey(key)
+		if !slices.Contains(kept, entry) {
+			kept = append(kept, entry)
+		}
+	}
+
+	content := strings.Join(kept, "\n")
+	if content != "" {
+		content += "\n"
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write atomically via temp file
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write known_hosts: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath) // Clean up on failure
+		return fmt.Errorf("failed to save known_hosts: %w", err)
+	}
+
+	return nil
+}
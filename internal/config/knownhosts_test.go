@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKnownHostsFindReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := "[host1]:2222 ssh-ed25519 AAAAkey1\n" +
+		"[host2]:2222 ssh-ed25519 AAAAkey2\n" +
+		"host1 ssh-ed25519 AAAAkey3\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+
+	keys, err := findKnownHosts(path, "host1", 2222)
+	if err != nil {
+		t.Fatalf("findKnownHosts() failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "ssh-ed25519 AAAAkey1" {
+		t.Errorf("findKnownHosts() = %q, want [ssh-ed25519 AAAAkey1]", keys)
+	}
+
+	// Replacing with two keys trusts both, as during a rotation
+	if err := replaceKnownHosts(path, "host1", 2222, 2222, []string{"ssh-ed25519 AAAAkey4", "ssh-ed25519 AAAAkey5\n"}); err != nil {
+		t.Fatalf("replaceKnownHosts() failed: %v", err)
+	}
+	keys, _ = findKnownHosts(path, "host1", 2222)
+	if len(keys) != 2 || keys[0] != "ssh-ed25519 AAAAkey4" || keys[1] != "ssh-ed25519 AAAAkey5" {
+		t.Errorf("findKnownHosts() after replace = %q, want key4 and key5", keys)
+	}
+
+	// Replacing can move the entries to another port
+	if err := replaceKnownHosts(path, "host1", 2222, 2223, []string{"ssh-ed25519 AAAAkey5"}); err != nil {
+		t.Fatalf("replaceKnownHosts() failed: %v", err)
+	}
+	if keys, _ := findKnownHosts(path, "host1", 2222); len(keys) != 0 {
+		t.Errorf("findKnownHosts() on old port = %q, want none", keys)
+	}
+	if keys, _ := findKnownHosts(path, "host1", 2223); len(keys) != 1 {
+		t.Errorf("findKnownHosts() on new port = %q, want one key", keys)
+	}
+
+	// Replacing with no keys removes the entries
+	if err := replaceKnownHosts(path, "host1", 2223, 2223, nil); err != nil {
+		t.Fatalf("replaceKnownHosts() failed: %v", err)
+	}
+	if keys, _ := findKnownHosts(path, "host1", 2223); len(keys) != 0 {
+		t.Errorf("findKnownHosts() after remove = %q, want none", keys)
+	}
+
+	// Other hosts and ports are untouched
+	if keys, _ := findKnownHosts(path, "host2", 2222); len(keys) == 0 {
+		t.Error("host2 entry should be kept")
+	}
+	if keys, _ := findKnownHosts(path, "host1", 22); len(keys) == 0 {
+		t.Error("host1 port 22 entry should be kept")
+	}
+}
+
+func TestKnownHostsDedup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := "# shed servers\n" +
+		"[host1]:2222 ssh-ed25519 AAAAkey1\n" +
+		"[host1]:2222 ssh-ed25519 AAAAkey1 comment\n" +
+		"[host2]:2222 ssh-ed25519 AAAAkey2\n" +
+		"[host2]:2222  ssh-ed25519 AAAAkey2\n" +
+		"[host1]:2222,[10.0.0.1]:2222 ssh-ed25519 AAAAkey1\n" +
+		"@revoked [host3]:2222 ssh-ed25519 AAAAkey3\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+
+	// Keys are compared without comments and reported once
+	keys, err := findKnownHosts(path, "host1", 2222)
+	if err != nil {
+		t.Fatalf("findKnownHosts() failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "ssh-ed25519 AAAAkey1" {
+		t.Errorf("findKnownHosts() = %q, want [ssh-ed25519 AAAAkey1]", keys)
+	}
+
+	// Re-adding a server leaves one entry per key
+	if err := replaceKnownHosts(path, "host1", 2222, 2222, []string{"ssh-ed25519 AAAAkey1"}); err != nil {
+		t.Fatalf("replaceKnownHosts() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# shed servers\n" +
+		"[host2]:2222 ssh-ed25519 AAAAkey2\n" +
+		"[10.0.0.1]:2222 ssh-ed25519 AAAAkey1\n" +
+		"@revoked [host3]:2222 ssh-ed25519 AAAAkey3\n" +
+		"[host1]:2222 ssh-ed25519 AAAAkey1\n"
+	if string(data) != want {
+		t.Errorf("known_hosts after replace =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestKnownHostPattern(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"host1", 22, "host1"},
+		{"host1", 2222, "[host1]:2222"},
+		{"fd7a:115c::5", 22, "fd7a:115c::5"},
+		{"fd7a:115c::5", 2222, "[fd7a:115c::5]:2222"},
+		{"[fd7a:115c::5]", 2222, "[fd7a:115c::5]:2222"},
+	}
+	for _, tt := range tests {
+		if got := knownHostPattern(tt.host, tt.port); got != tt.want {
+			t.Errorf("knownHostPattern(%q, %d) = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits on user-defined shed labels.
+const (
+	MaxLabelKeyLength   = 63
+	MaxLabelValueLength = 255
+)
+
+// labelKeyRegex matches label keys such as team, env, or app.kubernetes.io.
+var labelKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+
+// UpdateLabelsRequest is the request body for PATCH /api/sheds/{name}/labels.
+// Labels in Set are added or replaced, then labels in Remove are deleted.
+type UpdateLabelsRequest struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// ValidateLabelKey checks that key can be used as a label key.
+func ValidateLabelKey(key string) error {
+	if len(key) > MaxLabelKeyLength || !labelKeyRegex.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: must be 1-%d letters, digits, '.', '_', or '-', starting and ending with a letter or digit", key, MaxLabelKeyLength)
+	}
+	return nil
+}
+
+// ValidateLabels checks the keys and values of a label set.
+func ValidateLabels(labels map[string]string) error {
+	for _, key := range SortedLabelKeys(labels) {
+		if err := ValidateLabelKey(key); err != nil {
+			return err
+		}
+		if len(labels[key]) > MaxLabelValueLength {
+			return fmt.Errorf("label %q value is longer than %d characters", key, MaxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// ParseLabel parses a label written as key=value.
+func ParseLabel(spec string) (key, value string, err error) {
+	key, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid label %q: must be key=value", spec)
+	}
+	if err := ValidateLabels(map[string]string{key: value}); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// MatchLabels reports whether labels has every key and value in want.
+func MatchLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SortedLabelKeys returns the keys of labels in order.
+func SortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FormatLabels formats labels as comma-separated key=value pairs in key
+// order.
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, k := range SortedLabelKeys(labels) {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Validate checks the labels being set and removed.
+func (r *UpdateLabelsRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if len(r.Set) == 0 && len(r.Remove) == 0 {
+		errs.Add("set", FieldRequired, "no labels to set or remove")
+	}
+	errs.Check("set", ValidateLabels(r.Set))
+	for i, key := range r.Remove {
+		errs.Check(fmt.Sprintf("remove[%d]", i), ValidateLabelKey(key))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLabel(t *testing.T) {
+	tests := []struct {
+		spec     string
+		key, val string
+		wantErr  bool
+	}{
+		{"team=payments", "team", "payments", false},
+		{"env=", "env", "", false},
+		{"app.kind=web=1", "app.kind", "web=1", false},
+		{"team", "", "", true},
+		{"=payments", "", "", true},
+		{"-team=x", "", "", true},
+		{"team=" + strings.Repeat("x", MaxLabelValueLength+1), "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			key, val, err := ParseLabel(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLabel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if key != tt.key || val != tt.val {
+				t.Errorf("ParseLabel() = %q, %q; want %q, %q", key, val, tt.key, tt.val)
+			}
+		})
+	}
+}
+
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{"team": "payments", "env": "dev"}
+
+	if !MatchLabels(labels, nil) {
+		t.Error("empty selector should match")
+	}
+	if !MatchLabels(labels, map[string]string{"team": "payments", "env": "dev"}) {
+		t.Error("expected match on all labels")
+	}
+	if MatchLabels(labels, map[string]string{"team": "billing"}) {
+		t.Error("unexpected match on different value")
+	}
+	if MatchLabels(nil, map[string]string{"env": ""}) {
+		t.Error("unexpected match on missing label")
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Types of extra mounts a shed can request.
+const (
+	MountTypeBind   = "bind"
+	MountTypeVolume = "volume"
+)
+
+// volumeNameRegex matches Docker volume names.
+var volumeNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ShedMount is an extra bind or volume mount for a single shed.
+type ShedMount struct {
+	Type     string `json:"type" yaml:"type"`
+	Source   string `json:"source" yaml:"source"`
+	Target   string `json:"target" yaml:"target"`
+	ReadOnly bool   `json:"readonly,omitempty" yaml:"readonly,omitempty"`
+}
+
+// MountsConfig lists the host paths and volumes sheds may mount. Nothing
+// can be mounted unless it is allowed here.
+type MountsConfig struct {
+	// AllowedPaths are host directories that may be bind-mounted, along
+	// with anything beneath them.
+	AllowedPaths []string `yaml:"allowed_paths"`
+	// AllowedVolumes are named volumes that may be mounted.
+	AllowedVolumes []string `yaml:"allowed_volumes"`
+}
+
+// ParseMount parses a mount written as source:target[:ro]. Sources that are
+// absolute paths are bind mounts; anything else names a volume.
+func ParseMount(spec string) (ShedMount, error) {
+	parts := strings.Split(spec, ":")
+	var m ShedMount
+	switch {
+	case len(parts) == 3 && parts[2] == "ro":
+		m.ReadOnly = true
+	case len(parts) == 3 && parts[2] == "rw":
+	case len(parts) == 2:
+	default:
+		return ShedMount{}, fmt.Errorf("invalid mount %q: must be source:target[:ro]", spec)
+	}
+
+	m.Source, m.Target = parts[0], parts[1]
+	m.Type = MountTypeVolume
+	if filepath.IsAbs(m.Source) {
+		m.Type = MountTypeBind
+	}
+	return m, m.Validate()
+}
+
+// String formats m as accepted by ParseMount.
+func (m ShedMount) String() string {
+	s := m.Source + ":" + m.Target
+	if m.ReadOnly {
+		s += ":ro"
+	}
+	return s
+}
+
+// Validate checks that the mount is well-formed, without consulting the
+// server's allowlist.
+func (m ShedMount) Validate() error {
+	switch m.Type {
+	case MountTypeBind:
+		if !filepath.IsAbs(m.Source) {
+			return fmt.Errorf("bind mount source must be an absolute path: %s", m.Source)
+		}
+	case MountTypeVolume:
+		if !volumeNameRegex.MatchString(m.Source) {
+			return fmt.Errorf("invalid volume name %q", m.Source)
+		}
+	default:
+		return fmt.Errorf("invalid mount type %q: must be bind or volume", m.Type)
+	}
+
+	if !filepath.IsAbs(m.Target) {
+		return fmt.Errorf("mount target must be an absolute path: %s", m.Target)
+	}
+	target := filepath.Clean(m.Target)
+	if target == "/" || target == WorkspacePath {
+		return fmt.Errorf("cannot mount over %s", target)
+	}
+	return nil
+}
+
+// Allow checks m against the allowlist and returns it with a bind source
+// resolved to the real path, so a symlink can't lead outside an allowed
+// directory.
+func (c MountsConfig) Allow(m ShedMount) (ShedMount, error) {
+	if m.Type == MountTypeVolume {
+		if !slices.Contains(c.AllowedVolumes, m.Source) {
+			return m, fmt.Errorf("volume %q is not allowed on this server", m.Source)
+		}
+		return m, nil
+	}
+
+	source, err := filepath.EvalSymlinks(m.Source)
+	if err != nil {
+		return m, fmt.Errorf("mount source %s does not exist", m.Source)
+	}
+	for _, allowed := range c.AllowedPaths {
+		if real, err := filepath.EvalSymlinks(allowed); err == nil && pathWithin(source, real) {
+			m.Source = source
+			return m, nil
+		}
+	}
+	return m, fmt.Errorf("%s is not under an allowed path on this server", m.Source)
+}
+
+// Validate checks that the allowed paths are absolute.
+func (c MountsConfig) Validate() error {
+	for _, p := range c.AllowedPaths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("allowed path must be absolute: %s", p)
+		}
+		if filepath.Clean(p) == "/" {
+			return fmt.Errorf("allowed path cannot be /")
+		}
+	}
+	for _, v := range c.AllowedVolumes {
+		if !volumeNameRegex.MatchString(v) {
+			return fmt.Errorf("invalid volume name %q", v)
+		}
+	}
+	return nil
+}
+
+// pathWithin reports whether path is dir or beneath it.
+func pathWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMount(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    ShedMount
+		wantErr bool
+	}{
+		{"/srv/data:/data", ShedMount{Type: MountTypeBind, Source: "/srv/data", Target: "/data"}, false},
+		{"/srv/data:/data:ro", ShedMount{Type: MountTypeBind, Source: "/srv/data", Target: "/data", ReadOnly: true}, false},
+		{"datasets:/datasets:rw", ShedMount{Type: MountTypeVolume, Source: "datasets", Target: "/datasets"}, false},
+		{"/srv/data", ShedMount{}, true},
+		{"/srv/data:/data:rx", ShedMount{}, true},
+		{"/srv/data:data", ShedMount{}, true},
+		{"/srv/data:/workspace", ShedMount{}, true},
+		{"bad volume:/data", ShedMount{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseMount(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseMount() = %+v, want %+v", got, tt.want)
+			}
+			if !tt.wantErr && got.String() != tt.spec && got.String()+":rw" != tt.spec {
+				t.Errorf("String() = %q, want %q", got.String(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestMountsConfigAllow(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(allowed, "data"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A link inside an allowed path must not reach outside it
+	if err := os.Symlink(outside, filepath.Join(allowed, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := MountsConfig{AllowedPaths: []string{allowed}, AllowedVolumes: []string{"datasets"}}
+
+	tests := []struct {
+		name    string
+		mount   ShedMount
+		wantErr bool
+	}{
+		{"allowed dir", ShedMount{Type: MountTypeBind, Source: allowed, Target: "/data"}, false},
+		{"beneath allowed dir", ShedMount{Type: MountTypeBind, Source: filepath.Join(allowed, "data"), Target: "/data"}, false},
+		{"outside", ShedMount{Type: MountTypeBind, Source: outside, Target: "/data"}, true},
+		{"dot dot", ShedMount{Type: MountTypeBind, Source: allowed + "/../outside", Target: "/data"}, true},
+		{"symlink escape", ShedMount{Type: MountTypeBind, Source: filepath.Join(allowed, "escape"), Target: "/data"}, true},
+		{"missing", ShedMount{Type: MountTypeBind, Source: filepath.Join(allowed, "missing"), Target: "/data"}, true},
+		{"allowed volume", ShedMount{Type: MountTypeVolume, Source: "datasets", Target: "/datasets"}, false},
+		{"other volume", ShedMount{Type: MountTypeVolume, Source: "shed-other-workspace", Target: "/other"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cfg.Allow(tt.mount); (err != nil) != tt.wantErr {
+				t.Errorf("Allow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := (MountsConfig{}).Allow(ShedMount{Type: MountTypeBind, Source: allowed, Target: "/data"}); err == nil {
+		t.Error("Allow() with no allowed paths succeeded, want error")
+	}
+}
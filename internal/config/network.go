@@ -0,0 +1,56 @@
+package config
+
+import "fmt"
+
+// NetworkShared puts a shed on SharedNetworkName instead of Docker's
+// default bridge, where other sheds on it can reach it by name.
+const NetworkShared = "shared"
+
+// SharedNetworkName is the Docker network shared sheds are attached to. The
+// server creates it the first time a shared shed is created.
+const SharedNetworkName = "shed-internal"
+
+// SharedNetworkDomain is the DNS domain sheds on the shared network are
+// reachable under, e.g. api.shed.internal.
+const SharedNetworkDomain = "shed.internal"
+
+// SharedNetworkHostname returns the name a shed on the shared network is
+// reachable at from other sheds on it.
+func SharedNetworkHostname(name string) string {
+	return name + "." + SharedNetworkDomain
+}
+
+// NetworkDedicated puts a shed on a Docker network of its own, named by
+// ShedNetworkName, with the shed's name as its hostname. Tools in the shed
+// and its sidecars reach it by that name.
+const NetworkDedicated = "dedicated"
+
+// NetworkSuffix is appended to shed names for dedicated networks.
+const NetworkSuffix = "-net"
+
+// ShedNetworkName returns the Docker network of a shed with a dedicated
+// network.
+func ShedNetworkName(shedName string) string {
+	return VolumePrefix + shedName + NetworkSuffix
+}
+
+// NetworkHostname returns the name a shed on network is reachable at, or
+// empty for sheds on Docker's default bridge.
+func NetworkHostname(name, network string) string {
+	switch network {
+	case NetworkShared:
+		return SharedNetworkHostname(name)
+	case NetworkDedicated:
+		return name
+	}
+	return ""
+}
+
+// ValidateNetwork checks a shed's network mode. Empty leaves the shed on
+// Docker's default bridge.
+func ValidateNetwork(network string) error {
+	if network != "" && network != NetworkShared && network != NetworkDedicated {
+		return fmt.Errorf("network must be %q, %q, or empty", NetworkShared, NetworkDedicated)
+	}
+	return nil
+}
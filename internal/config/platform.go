@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// platformPartRegex matches one part of a platform, such as linux, arm64,
+// or v8.
+var platformPartRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// ParsePlatform splits an image platform such as linux/amd64 or
+// linux/arm/v7 into its OS, architecture, and optional variant.
+func ParsePlatform(platform string) (os, arch, variant string, err error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", "", "", fmt.Errorf("invalid platform %q: must look like linux/amd64", platform)
+	}
+	for _, part := range parts {
+		if !platformPartRegex.MatchString(part) {
+			return "", "", "", fmt.Errorf("invalid platform %q: must look like linux/amd64", platform)
+		}
+	}
+	os, arch = parts[0], parts[1]
+	if len(parts) == 3 {
+		variant = parts[2]
+	}
+	return os, arch, variant, nil
+}
+
+// ValidatePlatform checks that platform is an image platform such as
+// linux/arm64.
+func ValidatePlatform(platform string) error {
+	_, _, _, err := ParsePlatform(platform)
+	return err
+}
+
+// FormatPlatform joins an OS, architecture, and optional variant into a
+// platform such as linux/arm/v7.
+func FormatPlatform(os, arch, variant string) string {
+	platform := os + "/" + arch
+	if variant != "" {
+		platform += "/" + variant
+	}
+	return platform
+}
@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		platform          string
+		os, arch, variant string
+		wantErr           bool
+	}{
+		{"linux/amd64", "linux", "amd64", "", false},
+		{"linux/arm/v7", "linux", "arm", "v7", false},
+		{"linux", "", "", "", true},
+		{"linux/arm64/v8/extra", "", "", "", true},
+		{"Linux/AMD64", "", "", "", true},
+		{"linux//v8", "", "", "", true},
+	}
+	for _, tt := range tests {
+		os, arch, variant, err := ParsePlatform(tt.platform)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePlatform(%q) error = %v, wantErr %v", tt.platform, err, tt.wantErr)
+			continue
+		}
+		if os != tt.os || arch != tt.arch || variant != tt.variant {
+			t.Errorf("ParsePlatform(%q) = %s %s %s, want %s %s %s", tt.platform, os, arch, variant, tt.os, tt.arch, tt.variant)
+		}
+		if !tt.wantErr {
+			if got := FormatPlatform(os, arch, variant); got != tt.platform {
+				t.Errorf("FormatPlatform(%s, %s, %s) = %q, want %q", os, arch, variant, got, tt.platform)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PortMapping publishes a container port on the Docker host. It is written
+// as "[hostIP:]hostPort:containerPort[/protocol]", e.g. "8080:80" or
+// "127.0.0.1:5432:5432/tcp".
+type PortMapping struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+	Protocol      string
+}
+
+// ParsePortMapping parses a port mapping such as "8080:80". The protocol
+// defaults to tcp. IPv6 host addresses are written in brackets.
+func ParsePortMapping(spec string) (PortMapping, error) {
+	p := PortMapping{Protocol: "tcp"}
+
+	rest := spec
+	if i := strings.LastIndex(rest, "/"); i >= 0 {
+		p.Protocol = rest[i+1:]
+		rest = rest[:i]
+		if p.Protocol != "tcp" && p.Protocol != "udp" {
+			return p, fmt.Errorf("invalid port mapping %q: protocol must be tcp or udp", spec)
+		}
+	}
+
+	i := strings.LastIndex(rest, ":")
+	if i < 0 {
+		return p, fmt.Errorf("invalid port mapping %q: expected hostPort:containerPort", spec)
+	}
+	host, container := rest[:i], rest[i+1:]
+	if j := strings.LastIndex(host, ":"); j >= 0 {
+		p.HostIP = strings.TrimSuffix(strings.TrimPrefix(host[:j], "["), "]")
+		host = host[j+1:]
+		if net.ParseIP(p.HostIP) == nil {
+			return p, fmt.Errorf("invalid port mapping %q: bad host address %q", spec, p.HostIP)
+		}
+	}
+
+	var err error
+	if p.HostPort, err = parsePort(host); err != nil {
+		return p, fmt.Errorf("invalid port mapping %q: host %w", spec, err)
+	}
+	if p.ContainerPort, err = parsePort(container); err != nil {
+		return p, fmt.Errorf("invalid port mapping %q: container %w", spec, err)
+	}
+	return p, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port must be 1-65535, got %q", s)
+	}
+	return port, nil
+}
+
+// validatePorts checks a shed's port mappings, flagging any host port
+// published more than once.
+func validatePorts(ports []string) ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[string]bool, len(ports))
+	for i, spec := range ports {
+		field := fmt.Sprintf("ports[%d]", i)
+		p, err := ParsePortMapping(spec)
+		if err != nil {
+			errs.Check(field, err)
+			continue
+		}
+		key := strconv.Itoa(p.HostPort) + "/" + p.Protocol
+		if seen[key] {
+			errs.Add(field, FieldConflict, "host port "+key+" is published more than once")
+		}
+		seen[key] = true
+	}
+	return errs
+}
@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestParsePortMapping(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    PortMapping
+		wantErr bool
+	}{
+		{"8080:80", PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}, false},
+		{"127.0.0.1:5432:5432", PortMapping{HostIP: "127.0.0.1", HostPort: 5432, ContainerPort: 5432, Protocol: "tcp"}, false},
+		{"5353:53/udp", PortMapping{HostPort: 5353, ContainerPort: 53, Protocol: "udp"}, false},
+		{"[::1]:8080:80", PortMapping{HostIP: "::1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}, false},
+		{"80", PortMapping{}, true},
+		{"8080:0", PortMapping{}, true},
+		{"70000:80", PortMapping{}, true},
+		{"8080:80/sctp", PortMapping{}, true},
+		{"localhost:8080:80", PortMapping{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePortMapping(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePortMapping(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParsePortMapping(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	errs := validatePorts([]string{"8080:80", "3000:3000", "8080:8080", "8080:80/udp", "bad"})
+	if len(errs) != 2 || errs[0].Field != "ports[2]" || errs[0].Code != FieldConflict || errs[1].Field != "ports[4]" {
+		t.Errorf("validatePorts() = %+v, want a conflict for ports[2] and an error for ports[4]", errs)
+	}
+}
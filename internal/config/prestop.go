@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultPreStopTimeout is how long a pre-stop hook may run when its
+// timeout isn't set.
+const DefaultPreStopTimeout = 30 * time.Second
+
+// MaxPreStopTimeout bounds how long a pre-stop hook can hold up a stop.
+const MaxPreStopTimeout = 10 * time.Minute
+
+// PreStop is a command run in a shed before it is stopped, to flush
+// caches, save editor sessions, or stop services cleanly. The shed is
+// stopped when the command exits, fails, or runs out of time.
+//
+// Images can give the sheds made from them a pre-stop hook with a
+// LabelPreStop label holding it as JSON; one given at creation replaces
+// it.
+type PreStop struct {
+	// Command is run with the shell in the shed's workspace, with the
+	// environment SSH sessions get.
+	Command string `json:"command" yaml:"command"`
+
+	// Timeout is a Go duration, such as "1m", defaulting to
+	// DefaultPreStopTimeout.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Validate checks the command and timeout.
+func (p PreStop) Validate() error {
+	if strings.TrimSpace(p.Command) == "" {
+		return fmt.Errorf("pre-stop hook needs a command")
+	}
+	_, err := p.TimeoutDuration()
+	return err
+}
+
+// TimeoutDuration returns how long the hook may run.
+func (p PreStop) TimeoutDuration() (time.Duration, error) {
+	if p.Timeout == "" {
+		return DefaultPreStopTimeout, nil
+	}
+	d, err := time.ParseDuration(p.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pre-stop timeout %q: expected a duration such as 30s", p.Timeout)
+	}
+	if d <= 0 || d > MaxPreStopTimeout {
+		return 0, fmt.Errorf("invalid pre-stop timeout %q: must be more than 0 and at most %s", p.Timeout, MaxPreStopTimeout)
+	}
+	return d, nil
+}
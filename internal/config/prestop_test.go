@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreStopTimeoutDuration(t *testing.T) {
+	tests := []struct {
+		hook    PreStop
+		want    time.Duration
+		wantErr bool
+	}{
+		{PreStop{Command: "tmux kill-server"}, DefaultPreStopTimeout, false},
+		{PreStop{Command: "make stop", Timeout: "2m"}, 2 * time.Minute, false},
+		{PreStop{Command: "make stop", Timeout: "soon"}, 0, true},
+		{PreStop{Command: "make stop", Timeout: "0s"}, 0, true},
+		{PreStop{Command: "make stop", Timeout: "1h"}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.hook.TimeoutDuration()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("TimeoutDuration(%q) error = %v, wantErr %v", tt.hook.Timeout, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("TimeoutDuration(%q) = %s, want %s", tt.hook.Timeout, got, tt.want)
+		}
+	}
+
+	if err := (PreStop{Command: " "}).Validate(); err == nil {
+		t.Error("Validate() without a command succeeded")
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ProxyConfig runs an HTTP reverse proxy that gives sheds with an HTTP port
+// preview URLs. It is off unless Listen is set.
+type ProxyConfig struct {
+	// Listen is the address the proxy listens on, e.g. ":8000".
+	Listen string `yaml:"listen"`
+
+	// URL is where clients reach the proxy, e.g. https://preview.example.com.
+	// It is used to build preview URLs and must be set with Listen.
+	URL string `yaml:"url"`
+
+	// Subdomains routes <shed>.<host> to each shed instead of
+	// <url>/<shed>/, which needs a wildcard DNS record but works with apps
+	// that don't expect to be served under a path.
+	Subdomains bool `yaml:"subdomains"`
+
+	// TLSCert and TLSKey serve the proxy over HTTPS. Without them it
+	// serves plain HTTP, e.g. behind a load balancer that terminates TLS.
+	TLSCert string `yaml:"tls_cert"`
+	TLSKey  string `yaml:"tls_key"`
+}
+
+// Enabled reports whether the proxy should run.
+func (c ProxyConfig) Enabled() bool {
+	return c.Listen != ""
+}
+
+// Validate checks the proxy's addresses and TLS settings.
+func (c ProxyConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", c.Listen, err)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http:// or https:// URL, got %q", c.URL)
+	}
+	if c.Subdomains && strings.Trim(u.Path, "/") != "" {
+		return fmt.Errorf("url can't have a path with subdomains")
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	return nil
+}
+
+// PreviewURL returns the URL the proxy serves a shed at, or "" if the proxy
+// is disabled.
+func (c ProxyConfig) PreviewURL(name string) string {
+	if !c.Enabled() {
+		return ""
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return ""
+	}
+	if c.Subdomains {
+		u.Host = name + "." + u.Host
+		u.Path = "/"
+	} else {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name + "/"
+	}
+	return u.String()
+}
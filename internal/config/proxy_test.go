@@ -0,0 +1,40 @@
+package config
+
+import "testing"
+
+func TestProxyConfigPreviewURL(t *testing.T) {
+	tests := []struct {
+		cfg  ProxyConfig
+		want string
+	}{
+		{ProxyConfig{}, ""},
+		{ProxyConfig{Listen: ":8000", URL: "https://example.com"}, "https://example.com/api/"},
+		{ProxyConfig{Listen: ":8000", URL: "http://box:8000/preview/"}, "http://box:8000/preview/api/"},
+		{ProxyConfig{Listen: ":8000", URL: "https://preview.example.com", Subdomains: true}, "https://api.preview.example.com/"},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.PreviewURL("api"); got != tt.want {
+			t.Errorf("PreviewURL() with %+v = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestProxyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ProxyConfig
+		wantErr bool
+	}{
+		{"disabled", ProxyConfig{}, false},
+		{"path routing", ProxyConfig{Listen: ":8000", URL: "https://example.com/preview"}, false},
+		{"missing url", ProxyConfig{Listen: ":8000"}, true},
+		{"bad listen", ProxyConfig{Listen: "8000", URL: "https://example.com"}, true},
+		{"subdomains with path", ProxyConfig{Listen: ":8000", URL: "https://example.com/preview", Subdomains: true}, true},
+		{"cert without key", ProxyConfig{Listen: ":8443", URL: "https://example.com", TLSCert: "cert.pem"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
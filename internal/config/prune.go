@@ -0,0 +1,55 @@
+package config
+
+// Kinds of resources POST /api/prune removes.
+const (
+	// PruneVolume is a workspace or shared clone volume no shed uses.
+	PruneVolume = "volume"
+	// PruneContainer is a shed container whose workspace volume is gone.
+	PruneContainer = "container"
+	// PruneShed is a shed stopped for longer than the requested age.
+	PruneShed = "shed"
+	// PruneImage is an untagged image no container uses.
+	PruneImage = "image"
+	// PruneNetwork is a dedicated shed network whose shed is gone.
+	PruneNetwork = "network"
+)
+
+// PruneRequest is the request body for POST /api/prune.
+type PruneRequest struct {
+	// DryRun reports what would be removed without removing anything.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// StoppedDays also removes sheds, with their workspaces, that have been
+	// stopped for at least this many days. Zero keeps stopped sheds.
+	StoppedDays int `json:"stopped_days,omitempty"`
+}
+
+// Validate checks the request.
+func (r *PruneRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if r.StoppedDays < 0 {
+		errs.Add("stopped_days", FieldInvalid, "stopped_days must not be negative")
+	}
+	return errs
+}
+
+// PrunedResource is a resource found by prune.
+type PrunedResource struct {
+	Kind string `json:"kind"`
+	// Name is the volume, shed, network, or image ID.
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	// Size is the space removing it frees in bytes, or zero if unknown.
+	Size int64 `json:"size,omitempty"`
+	// Error says why it couldn't be removed.
+	Error string `json:"error,omitempty"`
+}
+
+// PruneResponse is the response for POST /api/prune.
+type PruneResponse struct {
+	DryRun    bool             `json:"dry_run"`
+	Resources []PrunedResource `json:"resources"`
+	// SpaceReclaimed totals the sizes of the resources removed, or that
+	// would be removed in a dry run.
+	SpaceReclaimed int64 `json:"space_reclaimed"`
+}
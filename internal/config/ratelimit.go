@@ -0,0 +1,41 @@
+package config
+
+import "fmt"
+
+// RateLimitConfig protects the server from clients that send too many
+// requests or start too many heavy operations at once. Zero values disable
+// each limit.
+type RateLimitConfig struct {
+	// RequestsPerMinute is how many API requests each token may make per
+	// minute, or each client address when the API has no tokens.
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	// Burst is how many requests a client may make at once before the rate
+	// applies. It defaults to RequestsPerMinute.
+	Burst int `yaml:"burst"`
+
+	// MaxConcurrentCreates caps the creates, imports, and clones in
+	// progress at once, since each may pull an image or clone a repository.
+	MaxConcurrentCreates int `yaml:"max_concurrent_creates"`
+}
+
+// Validate checks that no limit is negative.
+func (c RateLimitConfig) Validate() error {
+	if c.RequestsPerMinute < 0 {
+		return fmt.Errorf("requests_per_minute must not be negative")
+	}
+	if c.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	if c.MaxConcurrentCreates < 0 {
+		return fmt.Errorf("max_concurrent_creates must not be negative")
+	}
+	return nil
+}
+
+// BurstSize returns the configured burst, or RequestsPerMinute if unset.
+func (c RateLimitConfig) BurstSize() int {
+	if c.Burst > 0 {
+		return c.Burst
+	}
+	return c.RequestsPerMinute
+}
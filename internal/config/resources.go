@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+
+	units "github.com/docker/go-units"
+)
+
+// Resources limits the CPU, memory, and processes available to a shed.
+// Zero values mean no limit.
+type Resources struct {
+	// CPUs is the number of CPUs the shed may use, e.g. 1.5.
+	CPUs float64 `yaml:"cpus" json:"cpus,omitempty"`
+	// Memory is a size such as "512m" or "4g".
+	Memory string `yaml:"memory" json:"memory,omitempty"`
+	// PidsLimit caps the number of processes in the shed.
+	PidsLimit int64 `yaml:"pids_limit" json:"pids_limit,omitempty"`
+}
+
+// ResourcesConfig holds the limits applied to sheds that don't set their
+// own, and the largest limits a shed may request.
+type ResourcesConfig struct {
+	Defaults Resources `yaml:"defaults"`
+	Max      Resources `yaml:"max"`
+}
+
+// IsZero reports whether no limits are set.
+func (r Resources) IsZero() bool {
+	return r.CPUs == 0 && r.Memory == "" && r.PidsLimit == 0
+}
+
+// MemoryBytes returns the memory limit in bytes, or 0 if none is set.
+func (r Resources) MemoryBytes() (int64, error) {
+	if r.Memory == "" {
+		return 0, nil
+	}
+	n, err := units.RAMInBytes(r.Memory)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory %q: must look like 512m or 4g", r.Memory)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid memory %q: must be positive", r.Memory)
+	}
+	return n, nil
+}
+
+// Validate checks that each limit is well-formed.
+func (r Resources) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if r.CPUs < 0 {
+		errs.Add("cpus", FieldInvalid, "cpus cannot be negative")
+	}
+	if _, err := r.MemoryBytes(); err != nil {
+		errs.Check("memory", err)
+	}
+	if r.PidsLimit < 0 {
+		errs.Add("pids_limit", FieldInvalid, "pids_limit cannot be negative")
+	}
+	return errs
+}
+
+// WithDefaults returns r with any unset limits taken from defaults.
+func (r Resources) WithDefaults(defaults Resources) Resources {
+	if r.CPUs == 0 {
+		r.CPUs = defaults.CPUs
+	}
+	if r.Memory == "" {
+		r.Memory = defaults.Memory
+	}
+	if r.PidsLimit == 0 {
+		r.PidsLimit = defaults.PidsLimit
+	}
+	return r
+}
+
+// CheckMax reports each limit in r that is unset (unlimited) or larger than
+// the corresponding limit in max. Limits not set in max are unrestricted.
+// Both r and max must already be valid.
+func (r Resources) CheckMax(max Resources) ValidationErrors {
+	var errs ValidationErrors
+	if max.CPUs > 0 && (r.CPUs == 0 || r.CPUs > max.CPUs) {
+		errs.Add("cpus", FieldInvalid, fmt.Sprintf("cpus must be at most %s", strconv.FormatFloat(max.CPUs, 'f', -1, 64)))
+	}
+	if maxMem, _ := max.MemoryBytes(); maxMem > 0 {
+		if mem, _ := r.MemoryBytes(); mem == 0 || mem > maxMem {
+			errs.Add("memory", FieldInvalid, "memory must be at most "+max.Memory)
+		}
+	}
+	if max.PidsLimit > 0 && (r.PidsLimit == 0 || r.PidsLimit > max.PidsLimit) {
+		errs.Add("pids_limit", FieldInvalid, fmt.Sprintf("pids_limit must be at most %d", max.PidsLimit))
+	}
+	return errs
+}
+
+// Apply returns the limits for a shed requesting r: unset limits come from
+// the defaults, or failing that the maximums.
+func (c ResourcesConfig) Apply(r Resources) Resources {
+	return r.WithDefaults(c.Defaults).WithDefaults(c.Max)
+}
+
+// Validate checks the limits and that the defaults are within the maximums.
+func (c ResourcesConfig) Validate() error {
+	if errs := c.Defaults.Validate(); len(errs) > 0 {
+		return fmt.Errorf("defaults: %w", errs)
+	}
+	if errs := c.Max.Validate(); len(errs) > 0 {
+		return fmt.Errorf("max: %w", errs)
+	}
+	if errs := c.Apply(Resources{}).CheckMax(c.Max); len(errs) > 0 {
+		return fmt.Errorf("defaults exceed max: %w", errs)
+	}
+	return nil
+}
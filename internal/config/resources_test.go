@@ -0,0 +1,54 @@
+package config
+
+import "testing"
+
+func TestResourcesValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		res   Resources
+		valid bool
+	}{
+		{"empty", Resources{}, true},
+		{"all set", Resources{CPUs: 1.5, Memory: "512m", PidsLimit: 100}, true},
+		{"negative cpus", Resources{CPUs: -1}, false},
+		{"bad memory", Resources{Memory: "lots"}, false},
+		{"negative pids", Resources{PidsLimit: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.res.Validate()
+			if (len(errs) == 0) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", errs, tt.valid)
+			}
+		})
+	}
+}
+
+func TestResourcesConfigApply(t *testing.T) {
+	cfg := ResourcesConfig{
+		Defaults: Resources{Memory: "2g"},
+		Max:      Resources{CPUs: 4, Memory: "8g"},
+	}
+
+	got := cfg.Apply(Resources{PidsLimit: 50})
+	want := Resources{CPUs: 4, Memory: "2g", PidsLimit: 50}
+	if got != want {
+		t.Errorf("Apply() = %+v, want %+v", got, want)
+	}
+
+	if errs := (Resources{CPUs: 2, Memory: "16g"}).CheckMax(cfg.Max); len(errs) != 1 || errs[0].Field != "memory" {
+		t.Errorf("CheckMax() = %v, want one memory error", errs)
+	}
+	if errs := (Resources{Memory: "1g"}).CheckMax(cfg.Max); len(errs) != 1 || errs[0].Field != "cpus" {
+		t.Errorf("CheckMax() = %v, want one cpus error for an unlimited request", errs)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+	cfg.Defaults.CPUs = 8
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should fail when defaults exceed max")
+	}
+}
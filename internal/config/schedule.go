@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charliek/shed/internal/cron"
+)
+
+// Scheduled actions. A shed has at most one schedule for each.
+const (
+	ScheduleStart  = "start"
+	ScheduleStop   = "stop"
+	ScheduleBackup = "backup"
+)
+
+// StopReasonSchedule is the stopped_reason of sheds stopped by their
+// schedule.
+const StopReasonSchedule = "schedule"
+
+// Schedule starts, stops, or backs up a shed at the times a cron
+// expression matches, in the server's timezone.
+type Schedule struct {
+	Shed   string `json:"shed"`
+	Action string `json:"action"`
+	Cron   string `json:"cron"`
+
+	// Next is when the schedule next runs, or zero if it never will.
+	Next time.Time `json:"next"`
+}
+
+// SchedulesResponse is returned by GET /api/schedules and
+// GET /api/sheds/{name}/schedules.
+type SchedulesResponse struct {
+	Schedules []Schedule `json:"schedules"`
+}
+
+// SetScheduleRequest is the request body for
+// PUT /api/sheds/{name}/schedules/{action}.
+type SetScheduleRequest struct {
+	Cron string `json:"cron"`
+}
+
+// Validate checks the cron expression.
+func (r *SetScheduleRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if r.Cron == "" {
+		errs.Add("cron", FieldRequired, "cron expression is required")
+	} else if _, err := cron.Parse(r.Cron); err != nil {
+		errs.Check("cron", err)
+	}
+	return errs
+}
+
+// ValidateScheduleAction checks that action can be scheduled.
+func ValidateScheduleAction(action string) error {
+	if action != ScheduleStart && action != ScheduleStop && action != ScheduleBackup {
+		return fmt.Errorf("invalid schedule action %q: must be %s, %s, or %s", action, ScheduleStart, ScheduleStop, ScheduleBackup)
+	}
+	return nil
+}
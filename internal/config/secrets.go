@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Default locations of the server's secrets and the master key that
+// encrypts them.
+const (
+	DefaultSecretsDir     = "/etc/shed/secrets"
+	DefaultSecretsKeyFile = "/etc/shed/secrets.key"
+)
+
+// SecretsPath is the in-memory directory secret files are written to in
+// sheds.
+const SecretsPath = "/run/secrets"
+
+// MaxSecretSize limits the size of a secret's value.
+const MaxSecretSize = 64 * 1024
+
+// Secret is a value stored encrypted on the server. Value is only filled
+// in by GET /api/secrets/{name}.
+type Secret struct {
+	Name      string    `json:"name"`
+	Value     string    `json:"value,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SecretsResponse is returned by GET /api/secrets.
+type SecretsResponse struct {
+	Secrets []Secret `json:"secrets"`
+}
+
+// SetSecretRequest is the request body for PUT /api/secrets/{name}.
+type SetSecretRequest struct {
+	Value string `json:"value"`
+}
+
+// SecretRef gives a shed a server secret, as an environment variable named
+// Env, a file named File in SecretsPath, or both. A reference with neither
+// is a file named after the secret.
+type SecretRef struct {
+	Name string `json:"name"`
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// FileName returns the name of the file in SecretsPath the secret is
+// written to, or "" if it is only given as an environment variable.
+func (r SecretRef) FileName() string {
+	if r.File == "" && r.Env == "" {
+		return r.Name
+	}
+	return r.File
+}
+
+// Validate checks the secret name and where it is injected.
+func (r SecretRef) Validate() error {
+	if err := ValidateSecretName(r.Name); err != nil {
+		return err
+	}
+	if r.Env != "" {
+		if err := ValidateEnvName(r.Env); err != nil {
+			return err
+		}
+	}
+	if r.File != "" && (len(r.File) > MaxLabelKeyLength || !labelKeyRegex.MatchString(r.File)) {
+		return fmt.Errorf("invalid secret file name %q: must be 1-%d letters, digits, '.', '_', or '-', starting and ending with a letter or digit", r.File, MaxLabelKeyLength)
+	}
+	return nil
+}
+
+// ValidateSecretName checks that name can be used for a secret. Secret
+// names follow the rules for label keys, so they are also safe file names.
+func ValidateSecretName(name string) error {
+	if len(name) > MaxLabelKeyLength || !labelKeyRegex.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: must be 1-%d letters, digits, '.', '_', or '-', starting and ending with a letter or digit", name, MaxLabelKeyLength)
+	}
+	return nil
+}
+
+// ValidateSecretValue checks that value can be stored as a secret.
+func ValidateSecretValue(value string) error {
+	if len(value) > MaxSecretSize {
+		return fmt.Errorf("secret is larger than %d bytes", MaxSecretSize)
+	}
+	return nil
+}
+
+// ParseSecretRef parses a secret reference written as name, for a file
+// named after the secret, or ENV_NAME=name, for an environment variable.
+func ParseSecretRef(spec string) (SecretRef, error) {
+	ref := SecretRef{Name: spec}
+	if env, name, ok := strings.Cut(spec, "="); ok {
+		ref = SecretRef{Name: name, Env: env}
+	}
+	if err := ref.Validate(); err != nil {
+		return SecretRef{}, err
+	}
+	return ref, nil
+}
+
+// validateSecretRefs checks a shed's secret references, including that no
+// two set the same environment variable or file, nor one of env.
+func validateSecretRefs(refs []SecretRef, env map[string]string) ValidationErrors {
+	var errs ValidationErrors
+	envs := make(map[string]bool, len(refs))
+	files := make(map[string]bool, len(refs))
+	for i, ref := range refs {
+		field := fmt.Sprintf("secrets[%d]", i)
+		if err := ref.Validate(); err != nil {
+			errs.Check(field, err)
+			continue
+		}
+		if ref.Env != "" {
+			if _, ok := env[ref.Env]; ok || envs[ref.Env] {
+				errs.Add(field, FieldConflict, "environment variable "+ref.Env+" is set more than once")
+			}
+			envs[ref.Env] = true
+		}
+		if file := ref.FileName(); file != "" {
+			if files[file] {
+				errs.Add(field, FieldConflict, "more than one secret is written to "+SecretsPath+"/"+file)
+			}
+			files[file] = true
+		}
+	}
+	return errs
+}
@@ -0,0 +1,50 @@
+package config
+
+import "testing"
+
+func TestParseSecretRef(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    SecretRef
+		wantErr bool
+	}{
+		{"npmrc", SecretRef{Name: "npmrc"}, false},
+		{"GITHUB_TOKEN=github-token", SecretRef{Name: "github-token", Env: "GITHUB_TOKEN"}, false},
+		{"../etc", SecretRef{}, true},
+		{"GITHUB_TOKEN=", SecretRef{}, true},
+		{"TERM=token", SecretRef{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseSecretRef(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSecretRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSecretRef() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSecretRefsConflicts(t *testing.T) {
+	refs := []SecretRef{
+		{Name: "token", Env: "TOKEN"},
+		{Name: "other", Env: "TOKEN"},
+		{Name: "npmrc"},
+		{Name: "backup", File: "npmrc"},
+		{Name: "api", Env: "API_KEY"},
+	}
+	errs := validateSecretRefs(refs, map[string]string{"API_KEY": "x"})
+
+	want := map[string]bool{"secrets[1]": true, "secrets[3]": true, "secrets[4]": true}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %+v, want %d", errs, len(want))
+	}
+	for _, fe := range errs {
+		if !want[fe.Field] || fe.Code != FieldConflict {
+			t.Errorf("unexpected error %+v", fe)
+		}
+	}
+}
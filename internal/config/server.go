@@ -2,30 +2,239 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	gossh "golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v3"
 
 	"github.com/charliek/shed/internal/terminal"
 )
 
+// DefaultDeployKeyDir is the default directory for server-managed deploy keys.
+const DefaultDeployKeyDir = "/etc/shed/deploy_keys"
+
+// DefaultStateDir is the default directory for server-side shed state.
+const DefaultStateDir = "/var/lib/shed"
+
+// MinIdleTimeout is the shortest idle_timeout allowed, since idle sheds are
+// only checked once a minute.
+const MinIdleTimeout = 5 * time.Minute
+
+// Safety push defaults.
+const (
+	DefaultSafetyPushRemote    = "origin"
+	DefaultSafetyPushBackupDir = "/var/lib/shed/backups"
+)
+
 // ServerConfig represents the server-side configuration.
 type ServerConfig struct {
 	Name         string                 `yaml:"name"`
+	Runtime      string                 `yaml:"runtime"`
 	HTTPPort     int                    `yaml:"http_port"`
 	SSHPort      int                    `yaml:"ssh_port"`
 	DefaultImage string                 `yaml:"default_image"`
+	Platform     string                 `yaml:"platform"`
 	Credentials  map[string]MountConfig `yaml:"credentials"`
 	EnvFile      string                 `yaml:"env_file"`
 	LogLevel     string                 `yaml:"log_level"`
+	LogFormat    string                 `yaml:"log_format"`
 	Terminal     *terminal.Config       `yaml:"terminal"`
+	DeployKeyDir string                 `yaml:"deploy_key_dir"`
+	StateDir     string                 `yaml:"state_dir"`
+	APITokens    []APIToken             `yaml:"api_tokens"`
+	SafetyPush   SafetyPushConfig       `yaml:"safety_push"`
+	Prepull      PrepullConfig          `yaml:"prepull"`
+	Images       ImagesConfig           `yaml:"images"`
+	Forwarding   ForwardingConfig       `yaml:"forwarding"`
+	SSH          SSHConfig              `yaml:"ssh"`
+	Resources    ResourcesConfig        `yaml:"resources"`
+	Mounts       MountsConfig           `yaml:"mounts"`
+	RateLimit    RateLimitConfig        `yaml:"rate_limit"`
+	Proxy        ProxyConfig            `yaml:"proxy"`
+	Backups      BackupsConfig          `yaml:"backups"`
+	ImageGC      ImageGCConfig          `yaml:"image_gc"`
+
+	// BindAddresses are the IP addresses the HTTP API and SSH server
+	// listen on, e.g. a Tailscale address and its IPv6 counterpart. Empty
+	// listens on every address, IPv4 and IPv6.
+	BindAddresses []string `yaml:"bind_addresses"`
+
+	// PublishAddress is the host address that published ports without
+	// one are bound to. Empty binds them to 127.0.0.1; 0.0.0.0 publishes
+	// them on every interface.
+	PublishAddress string `yaml:"publish_address"`
+
+	// Timezone and Locale are the defaults for sheds that don't set their
+	// own, e.g. America/New_York and en_US.UTF-8. Empty keeps the image's.
+	Timezone string `yaml:"timezone"`
+	Locale   string `yaml:"locale"`
+
+	// IdleTimeout stops running sheds with no SSH sessions, execs, or
+	// forwards for this long. Zero disables idle stops.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+
+	// DockerHost is the engine that runs sheds, e.g. tcp://build-box:2376.
+	// Empty uses DOCKER_HOST or the runtime's local socket.
+	DockerHost string          `yaml:"docker_host"`
+	DockerTLS  DockerTLSConfig `yaml:"docker_tls"`
+
+	// AuthorizedKeys are authorized_keys lines allowed to connect to every
+	// shed. SSH accepts any key until a key is configured or registered.
+	AuthorizedKeys []string `yaml:"authorized_keys"`
+
+	// CORSAllowedOrigins are the browser origins, such as
+	// https://shed.example.com, allowed to call the API from another site.
+	// "*" allows any origin but never with credentials.
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins"`
+
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies in
+	// front of the API. Only requests from them have their client address
+	// taken from X-Forwarded-For or X-Real-IP, for rate limits and logs.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// Dashboard serves the web UI at /ui/. It is on by default; its API
+	// calls need a token like any other client once tokens are configured.
+	Dashboard bool `yaml:"dashboard"`
+
+	// MDNS advertises the server on the local network as a _shed._tcp
+	// service, for `shed server discover`. It is on by default.
+	MDNS bool `yaml:"mdns"`
+
+	// SecretsDir holds secrets encrypted with the master key in
+	// SecretsKeyFile, which is generated when the first secret is set.
+	SecretsDir     string `yaml:"secrets_dir"`
+	SecretsKeyFile string `yaml:"secrets_key_file"`
 
 	// Loaded environment variables (not from YAML)
 	EnvVars map[string]string `yaml:"-"`
 }
 
+// Log formats for log_format.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Container runtimes that can run sheds. Podman is driven through its
+// Docker-compatible API socket.
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
+// DockerTLSConfig holds the certificates for a TLS-protected docker_host.
+// CACert verifies the engine; Cert and Key authenticate shed-server to it.
+type DockerTLSConfig struct {
+	CACert string `yaml:"ca_cert"`
+	Cert   string `yaml:"cert"`
+	Key    string `yaml:"key"`
+}
+
+// IsZero reports whether no certificates are configured.
+func (t DockerTLSConfig) IsZero() bool {
+	return t.CACert == "" && t.Cert == "" && t.Key == ""
+}
+
+// DefaultPublishAddress returns the host address for published ports that
+// don't give one.
+func (c *ServerConfig) DefaultPublishAddress() string {
+	if c.PublishAddress == "" {
+		return "127.0.0.1"
+	}
+	return NormalizeHost(c.PublishAddress)
+}
+
+// TrustedProxyPrefixes parses TrustedProxies, treating a bare address as a
+// range of one.
+func (c *ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for i, p := range c.TrustedProxies {
+		if addr, err := netip.ParseAddr(p); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("trusted_proxies[%d]: %q is not an address or CIDR range", i, p)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// RemoteEngine reports whether docker_host names an engine on another
+// host. Sheds there can't be reached over the network from the server, and
+// bind mount sources are on that host's filesystem.
+func (c *ServerConfig) RemoteEngine() bool {
+	return strings.HasPrefix(c.DockerHost, "tcp://")
+}
+
+// validateDockerHost checks the engine address and its TLS settings.
+func (c *ServerConfig) validateDockerHost() error {
+	if c.DockerHost != "" {
+		u, err := url.Parse(c.DockerHost)
+		if err != nil {
+			return fmt.Errorf("invalid docker_host: %w", err)
+		}
+		switch u.Scheme {
+		case "tcp":
+			if u.Host == "" {
+				return fmt.Errorf("invalid docker_host: %s (missing host)", c.DockerHost)
+			}
+		case "unix", "npipe":
+		default:
+			return fmt.Errorf("invalid docker_host: %s (must be tcp://, unix://, or npipe://)", c.DockerHost)
+		}
+	}
+
+	if c.RemoteEngine() && len(c.Mounts.AllowedPaths) > 0 {
+		return fmt.Errorf("mounts.allowed_paths can't be used with a tcp:// docker_host, since bind mount sources are checked on this host")
+	}
+
+	if c.DockerTLS.IsZero() {
+		return nil
+	}
+	if !c.RemoteEngine() {
+		return fmt.Errorf("docker_tls requires a tcp:// docker_host")
+	}
+	if (c.DockerTLS.Cert == "") != (c.DockerTLS.Key == "") {
+		return fmt.Errorf("docker_tls cert and key must be set together")
+	}
+	return nil
+}
+
+// validateOrigin checks that origin is "*" or a scheme and host such as
+// https://shed.example.com:8443, as browsers send in the Origin header.
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid origin %q: must be http:// or https:// and a host", origin)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("invalid origin %q: must not have a path", origin)
+	}
+	return nil
+}
+
+// APIToken is a bearer token accepted by the HTTP API. When no tokens are
+// configured or minted with `shed-server token create`, the API is open.
+type APIToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+}
+
 // MountConfig represents a bind mount configuration.
 type MountConfig struct {
 	Source   string `yaml:"source"`
@@ -33,17 +242,128 @@ type MountConfig struct {
 	ReadOnly bool   `yaml:"readonly"`
 }
 
+// SafetyPushConfig controls backing up uncommitted work before a shed is
+// deleted. Changes in each git repository are committed to a
+// shed-backup/<timestamp> branch and pushed to Remote; if that fails the
+// workspace is archived as a tarball in BackupDir instead.
+type SafetyPushConfig struct {
+	// Enabled is the server policy; individual sheds can override it
+	Enabled   bool   `yaml:"enabled"`
+	Remote    string `yaml:"remote"`
+	BackupDir string `yaml:"backup_dir"`
+}
+
+// PrepullConfig schedules periodic image pulls so creating a shed doesn't
+// wait on a slow pull. Pre-pulling is enabled when Interval is set.
+type PrepullConfig struct {
+	// Images to keep pulled; defaults to the default image
+	Images   []string      `yaml:"images"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// ImagesConfig selects the local images offered for new sheds. An image is
+// listed if it matches a prefix and has the label; unset filters match
+// everything. The default image is always listed.
+type ImagesConfig struct {
+	// Prefixes match the start of an image's repository, e.g. shed- or
+	// ghcr.io/acme/.
+	Prefixes []string `yaml:"prefixes"`
+	// Label is a label the image must have, e.g. dev.shed.image.
+	Label string `yaml:"label"`
+}
+
+// Matches reports whether the image named ref, such as shed-base:latest,
+// passes the prefix filter. The label filter is applied when listing.
+func (c ImagesConfig) Matches(ref string) bool {
+	if len(c.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range c.Prefixes {
+		if strings.HasPrefix(ref, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPAddrs returns the addresses the HTTP API listens on.
+func (c *ServerConfig) HTTPAddrs() []string {
+	return c.listenAddrs(c.HTTPPort)
+}
+
+// SSHAddrs returns the addresses the SSH server listens on.
+func (c *ServerConfig) SSHAddrs() []string {
+	return c.listenAddrs(c.SSHPort)
+}
+
+// listenAddrs returns port on each bind address, or on every address if
+// none are configured.
+func (c *ServerConfig) listenAddrs(port int) []string {
+	if len(c.BindAddresses) == 0 {
+		return []string{HostPort("", port)}
+	}
+	addrs := make([]string, 0, len(c.BindAddresses))
+	for _, addr := range c.BindAddresses {
+		addrs = append(addrs, HostPort(addr, port))
+	}
+	return addrs
+}
+
+// PrepullImages returns the configured images to pre-pull without
+// duplicates, or the default image if none are configured.
+func (c *ServerConfig) PrepullImages() []string {
+	if len(c.Prepull.Images) == 0 {
+		return []string{c.DefaultImage}
+	}
+
+	seen := make(map[string]bool)
+	var images []string
+	for _, image := range c.Prepull.Images {
+		if image == "" || seen[image] {
+			continue
+		}
+		seen[image] = true
+		images = append(images, image)
+	}
+	return images
+}
+
 // DefaultServerConfig returns a ServerConfig with default values.
 func DefaultServerConfig() *ServerConfig {
 	return &ServerConfig{
-		Name:         "shed-server",
-		HTTPPort:     8080,
-		SSHPort:      2222,
-		DefaultImage: "shed-base:latest",
-		Credentials:  make(map[string]MountConfig),
-		LogLevel:     "info",
-		Terminal:     terminal.DefaultConfig(),
-		EnvVars:      make(map[string]string),
+		Name:           "shed-server",
+		Runtime:        RuntimeDocker,
+		HTTPPort:       8080,
+		SSHPort:        2222,
+		DefaultImage:   "shed-base:latest",
+		Credentials:    make(map[string]MountConfig),
+		LogLevel:       "info",
+		LogFormat:      LogFormatText,
+		Terminal:       terminal.DefaultConfig(),
+		DeployKeyDir:   DefaultDeployKeyDir,
+		StateDir:       DefaultStateDir,
+		SecretsDir:     DefaultSecretsDir,
+		SecretsKeyFile: DefaultSecretsKeyFile,
+		SafetyPush: SafetyPushConfig{
+			Remote:    DefaultSafetyPushRemote,
+			BackupDir: DefaultSafetyPushBackupDir,
+		},
+		Forwarding: ForwardingConfig{
+			Local: true,
+		},
+		SSH: SSHConfig{
+			KeepaliveInterval: DefaultSSHKeepaliveInterval,
+			KeepaliveCountMax: DefaultSSHKeepaliveCountMax,
+		},
+		Backups: BackupsConfig{
+			Keep: DefaultBackupKeep,
+		},
+		ImageGC: ImageGCConfig{
+			Interval: DefaultImageGCInterval,
+		},
+		Dashboard: true,
+		MDNS:      true,
+		EnvVars:   make(map[string]string),
 	}
 }
 
@@ -91,21 +411,69 @@ func LoadServerConfigFromPath(path string) (*ServerConfig, error) {
 	}
 
 	// Apply defaults for zero values
+	if cfg.Runtime == "" {
+		cfg.Runtime = RuntimeDocker
+	}
 	if cfg.HTTPPort == 0 {
 		cfg.HTTPPort = 8080
 	}
 	if cfg.SSHPort == 0 {
 		cfg.SSHPort = 2222
 	}
+	if cfg.SSH.KeepaliveCountMax == 0 {
+		cfg.SSH.KeepaliveCountMax = DefaultSSHKeepaliveCountMax
+	}
 	if cfg.DefaultImage == "" {
 		cfg.DefaultImage = "shed-base:latest"
 	}
 	if cfg.LogLevel == "" {
 		cfg.LogLevel = "info"
 	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = LogFormatText
+	}
 	if cfg.Terminal == nil {
 		cfg.Terminal = terminal.DefaultConfig()
 	}
+	if cfg.DeployKeyDir == "" {
+		cfg.DeployKeyDir = DefaultDeployKeyDir
+	}
+	cfg.DeployKeyDir = expandPath(cfg.DeployKeyDir)
+	if cfg.SecretsDir == "" {
+		cfg.SecretsDir = DefaultSecretsDir
+	}
+	cfg.SecretsDir = expandPath(cfg.SecretsDir)
+	if cfg.SecretsKeyFile == "" {
+		cfg.SecretsKeyFile = DefaultSecretsKeyFile
+	}
+	cfg.SecretsKeyFile = expandPath(cfg.SecretsKeyFile)
+	if cfg.Proxy.TLSCert != "" {
+		cfg.Proxy.TLSCert = expandPath(cfg.Proxy.TLSCert)
+		cfg.Proxy.TLSKey = expandPath(cfg.Proxy.TLSKey)
+	}
+	if cfg.StateDir == "" {
+		cfg.StateDir = DefaultStateDir
+	}
+	cfg.StateDir = expandPath(cfg.StateDir)
+	if cfg.SafetyPush.Remote == "" {
+		cfg.SafetyPush.Remote = DefaultSafetyPushRemote
+	}
+	if cfg.SafetyPush.BackupDir == "" {
+		cfg.SafetyPush.BackupDir = DefaultSafetyPushBackupDir
+	}
+	cfg.SafetyPush.BackupDir = expandPath(cfg.SafetyPush.BackupDir)
+	if cfg.Backups.Keep == 0 {
+		cfg.Backups.Keep = DefaultBackupKeep
+	}
+	if cfg.ImageGC.Interval == 0 {
+		cfg.ImageGC.Interval = DefaultImageGCInterval
+	}
+	for i, p := range cfg.Mounts.AllowedPaths {
+		cfg.Mounts.AllowedPaths[i] = expandPath(p)
+	}
+	cfg.DockerTLS.CACert = expandPath(cfg.DockerTLS.CACert)
+	cfg.DockerTLS.Cert = expandPath(cfg.DockerTLS.Cert)
+	cfg.DockerTLS.Key = expandPath(cfg.DockerTLS.Key)
 
 	// Expand and validate paths in credentials
 	for name, mount := range cfg.Credentials {
@@ -151,17 +519,121 @@ func (c *ServerConfig) Validate() error {
 	if c.Name == "" {
 		return fmt.Errorf("server name is required")
 	}
+	if c.Runtime != "" && c.Runtime != RuntimeDocker && c.Runtime != RuntimePodman {
+		return fmt.Errorf("invalid runtime: %s (must be docker or podman)", c.Runtime)
+	}
 	if c.HTTPPort < 1 || c.HTTPPort > 65535 {
 		return fmt.Errorf("invalid http_port: %d", c.HTTPPort)
 	}
 	if c.SSHPort < 1 || c.SSHPort > 65535 {
 		return fmt.Errorf("invalid ssh_port: %d", c.SSHPort)
 	}
+	for i, addr := range c.BindAddresses {
+		if _, err := netip.ParseAddr(NormalizeHost(addr)); err != nil {
+			return fmt.Errorf("invalid bind_addresses[%d]: %q is not an IP address", i, addr)
+		}
+	}
+
+	if c.PublishAddress != "" {
+		if _, err := netip.ParseAddr(NormalizeHost(c.PublishAddress)); err != nil {
+			return fmt.Errorf("invalid publish_address: %q is not an IP address", c.PublishAddress)
+		}
+	}
+
+	if err := c.validateDockerHost(); err != nil {
+		return err
+	}
+
+	if c.Platform != "" {
+		if err := ValidatePlatform(c.Platform); err != nil {
+			return fmt.Errorf("invalid platform: %w", err)
+		}
+	}
+
+	if c.Timezone != "" {
+		if err := ValidateTimezone(c.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+	if c.Locale != "" {
+		if err := ValidateLocale(c.Locale); err != nil {
+			return fmt.Errorf("invalid locale: %w", err)
+		}
+	}
+
+	if err := c.Forwarding.Validate(); err != nil {
+		return fmt.Errorf("invalid forwarding: %w", err)
+	}
+
+	if err := c.SSH.Validate(); err != nil {
+		return fmt.Errorf("invalid ssh: %w", err)
+	}
+
+	if err := c.Resources.Validate(); err != nil {
+		return fmt.Errorf("invalid resources: %w", err)
+	}
+
+	if err := c.Mounts.Validate(); err != nil {
+		return fmt.Errorf("invalid mounts: %w", err)
+	}
+
+	if err := c.RateLimit.Validate(); err != nil {
+		return fmt.Errorf("invalid rate_limit: %w", err)
+	}
+
+	if err := c.Proxy.Validate(); err != nil {
+		return fmt.Errorf("invalid proxy: %w", err)
+	}
+
+	if err := c.Backups.Validate(); err != nil {
+		return fmt.Errorf("invalid backups: %w", err)
+	}
+
+	if err := c.ImageGC.Validate(); err != nil {
+		return fmt.Errorf("invalid image_gc: %w", err)
+	}
+
+	if err := c.Terminal.Validate(); err != nil {
+		return fmt.Errorf("invalid terminal: %w", err)
+	}
+
+	for i, line := range c.AuthorizedKeys {
+		if _, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line)); err != nil {
+			return fmt.Errorf("authorized_keys[%d]: %w", i, err)
+		}
+	}
+
+	if _, err := c.TrustedProxyPrefixes(); err != nil {
+		return err
+	}
+
+	for i, origin := range c.CORSAllowedOrigins {
+		if err := validateOrigin(origin); err != nil {
+			return fmt.Errorf("cors_allowed_origins[%d]: %w", i, err)
+		}
+	}
+
+	for i, t := range c.APITokens {
+		if t.Token == "" {
+			return fmt.Errorf("api_tokens[%d] (%s): token is required", i, t.Name)
+		}
+	}
+
+	if c.IdleTimeout != 0 && c.IdleTimeout < MinIdleTimeout {
+		return fmt.Errorf("invalid idle_timeout: %s (must be at least %s)", c.IdleTimeout, MinIdleTimeout)
+	}
+
+	if c.Prepull.Interval < 0 {
+		return fmt.Errorf("invalid prepull interval: %s", c.Prepull.Interval)
+	}
 
 	validLogLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	if !validLogLevels[c.LogLevel] {
 		return fmt.Errorf("invalid log_level: %s (must be debug, info, warn, or error)", c.LogLevel)
 	}
+	if c.LogFormat != "" && c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		return fmt.Errorf("invalid log_format: %s (must be %s or %s)", c.LogFormat, LogFormatText, LogFormatJSON)
+	}
 
 	return nil
 }
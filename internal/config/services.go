@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxServiceNameLength limits service names so sidecar container names stay
+// short.
+const MaxServiceNameLength = 32
+
+// MaxServices limits the sidecars one shed may run.
+const MaxServices = 8
+
+// ServiceMissing is the status of a sidecar whose container is gone.
+const ServiceMissing = "missing"
+
+// Service is a sidecar container run alongside a shed, such as a database.
+// Sidecars share the shed's network namespace, so the shed reaches them on
+// localhost at the ports they listen on. They are started, stopped, and
+// deleted with the shed.
+type Service struct {
+	Name  string `json:"name" yaml:"name"`
+	Image string `json:"image" yaml:"image"`
+
+	// Env sets environment variables in the sidecar.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// Command replaces the image's default command if set.
+	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
+
+	// Status is the sidecar container's status, or ServiceMissing. It is
+	// only filled in by GET /api/sheds/{name}.
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// Validate checks the service's name, image, and environment.
+func (s Service) Validate() error {
+	if err := ValidateServiceName(s.Name); err != nil {
+		return err
+	}
+	if s.Image == "" {
+		return fmt.Errorf("service %q needs an image", s.Name)
+	}
+	if strings.ContainsAny(s.Image, " \t\n") {
+		return fmt.Errorf("invalid image %q for service %q", s.Image, s.Name)
+	}
+	return ValidateEnv(s.Env)
+}
+
+// ValidateServiceName checks that name can be used for a sidecar. Service
+// names follow the rules for shed names.
+func ValidateServiceName(name string) error {
+	if len(name) > MaxServiceNameLength || !shedNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid service name %q: must be 1-%d lowercase letters, digits, or hyphens, starting with a letter", name, MaxServiceNameLength)
+	}
+	return nil
+}
+
+// ServiceContainerName returns the name of a shed's sidecar container.
+// Shed names can't contain dots, so it can't collide with a shed.
+func ServiceContainerName(shedName, service string) string {
+	return ContainerName(shedName) + "." + service
+}
+
+// ParseService parses a service written as name=image, optionally followed
+// by comma-separated KEY=VALUE environment variables, e.g.
+// "db=postgres:16,POSTGRES_PASSWORD=dev".
+func ParseService(spec string) (Service, error) {
+	parts := strings.Split(spec, ",")
+	name, image, ok := strings.Cut(parts[0], "=")
+	if !ok {
+		return Service{}, fmt.Errorf("invalid service %q: expected name=image", spec)
+	}
+	svc := Service{Name: name, Image: image}
+	for _, kv := range parts[1:] {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return Service{}, fmt.Errorf("invalid service %q: expected KEY=VALUE, got %q", spec, kv)
+		}
+		if svc.Env == nil {
+			svc.Env = make(map[string]string)
+		}
+		svc.Env[k] = v
+	}
+	if err := svc.Validate(); err != nil {
+		return Service{}, err
+	}
+	return svc, nil
+}
+
+// validateServices checks a shed's sidecars, including that no two share a
+// name.
+func validateServices(services []Service) ValidationErrors {
+	var errs ValidationErrors
+	if len(services) > MaxServices {
+		errs.Add("services", FieldInvalid, fmt.Sprintf("a shed can have at most %d services", MaxServices))
+	}
+	names := make(map[string]bool, len(services))
+	for i, svc := range services {
+		field := fmt.Sprintf("services[%d]", i)
+		if err := svc.Validate(); err != nil {
+			errs.Check(field, err)
+			continue
+		}
+		if names[svc.Name] {
+			errs.Add(field, FieldConflict, "more than one service is named "+svc.Name)
+		}
+		names[svc.Name] = true
+	}
+	return errs
+}
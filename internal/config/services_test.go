@@ -0,0 +1,53 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseService(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Service
+		wantErr bool
+	}{
+		{"db=postgres:16", Service{Name: "db", Image: "postgres:16"}, false},
+		{"db=postgres:16,POSTGRES_PASSWORD=dev,POSTGRES_DB=app", Service{
+			Name:  "db",
+			Image: "postgres:16",
+			Env:   map[string]string{"POSTGRES_PASSWORD": "dev", "POSTGRES_DB": "app"},
+		}, false},
+		{"cache=registry.local:5000/redis:7", Service{Name: "cache", Image: "registry.local:5000/redis:7"}, false},
+		{"postgres:16", Service{}, true},
+		{"db=", Service{}, true},
+		{"DB=postgres:16", Service{}, true},
+		{"db=postgres:16,PASSWORD", Service{}, true},
+		{"db=postgres:16,1BAD=x", Service{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseService(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseService(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseService(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	errs := validateServices([]Service{
+		{Name: "db", Image: "postgres:16"},
+		{Name: "cache", Image: "redis:7"},
+		{Name: "db", Image: "mysql:8"},
+		{Name: "web"},
+	})
+	if len(errs) != 2 || errs[0].Field != "services[2]" || errs[0].Code != FieldConflict || errs[1].Field != "services[3]" {
+		t.Errorf("validateServices() = %+v, want a conflict for services[2] and an error for services[3]", errs)
+	}
+
+	if errs := validateServices(make([]Service, MaxServices+1)); len(errs) == 0 || errs[0].Field != "services" {
+		t.Errorf("validateServices() with %d services = %+v, want a services error", MaxServices+1, errs)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults for SSHConfig.
+const (
+	DefaultSSHKeepaliveInterval = 30 * time.Second
+	DefaultSSHKeepaliveCountMax = 3
+)
+
+// SSHConfig holds connection settings for the SSH server.
+type SSHConfig struct {
+	// KeepaliveInterval is how often an idle connection is probed, so
+	// connections dropped by NATs or sleeping laptops are noticed. Zero
+	// disables keepalives.
+	KeepaliveInterval time.Duration `yaml:"keepalive_interval"`
+	// KeepaliveCountMax is how many probes may go unanswered before the
+	// connection is closed. Zero means DefaultSSHKeepaliveCountMax.
+	KeepaliveCountMax int `yaml:"keepalive_count_max"`
+
+	// IdleTimeout closes shells and commands with no input or output for
+	// this long, killing what they run. Zero leaves them open.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+}
+
+// KeepaliveCount returns how many probes may go unanswered before a
+// connection is closed.
+func (c SSHConfig) KeepaliveCount() int {
+	if c.KeepaliveCountMax <= 0 {
+		return DefaultSSHKeepaliveCountMax
+	}
+	return c.KeepaliveCountMax
+}
+
+// Validate checks that no setting is negative.
+func (c SSHConfig) Validate() error {
+	if c.KeepaliveInterval < 0 {
+		return fmt.Errorf("keepalive_interval must not be negative")
+	}
+	if c.KeepaliveCountMax < 0 {
+		return fmt.Errorf("keepalive_count_max must not be negative")
+	}
+	if c.IdleTimeout < 0 {
+		return fmt.Errorf("idle_timeout must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ServerSSHOptions are extra ssh_config options for connecting to a
+// server's sheds, such as reaching a server behind a bastion.
+type ServerSSHOptions struct {
+	// ProxyJump is the jump host to connect through, as for ssh -J.
+	ProxyJump string `yaml:"proxy_jump,omitempty"`
+	// IdentityFile is the private key to authenticate with.
+	IdentityFile string `yaml:"identity_file,omitempty"`
+	// ForwardAgent forwards the local SSH agent into the shed.
+	ForwardAgent bool `yaml:"forward_agent,omitempty"`
+	// Options are any other ssh_config options, by name.
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+// sshOptionNamePattern matches an ssh_config option name.
+var sshOptionNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// managedSSHOptions are the options shed sets itself, which extra options
+// may not override.
+var managedSSHOptions = []string{"host", "match", "hostname", "port", "user", "userknownhostsfile", "proxyjump", "identityfile", "forwardagent"}
+
+// IsZero reports whether no options are set.
+func (o ServerSSHOptions) IsZero() bool {
+	return o.ProxyJump == "" && o.IdentityFile == "" && !o.ForwardAgent && len(o.Options) == 0
+}
+
+// Validate checks that the options can be written to an SSH config file.
+func (o ServerSSHOptions) Validate() error {
+	if strings.ContainsAny(o.ProxyJump, "\r\n") {
+		return fmt.Errorf("proxy_jump must be a single line")
+	}
+	if strings.ContainsAny(o.IdentityFile, "\r\n") {
+		return fmt.Errorf("identity_file must be a single line")
+	}
+	for name, value := range o.Options {
+		if !sshOptionNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid SSH option name %q", name)
+		}
+		for _, managed := range managedSSHOptions {
+			if strings.EqualFold(name, managed) {
+				return fmt.Errorf("SSH option %s is set by shed; use the server's host, ports, proxy_jump, identity_file, or forward_agent instead", name)
+			}
+		}
+		if value == "" || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("SSH option %s must have a single-line value", name)
+		}
+	}
+	return nil
+}
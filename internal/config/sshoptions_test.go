@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerSSHOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ServerSSHOptions
+		wantErr bool
+	}{
+		{name: "empty", opts: ServerSSHOptions{}},
+		{name: "bastion", opts: ServerSSHOptions{ProxyJump: "me@bastion.example.com", IdentityFile: "~/.ssh/id_work", ForwardAgent: true}},
+		{name: "extra options", opts: ServerSSHOptions{Options: map[string]string{"ServerAliveInterval": "30", "Compression": "yes"}}},
+		{name: "multi-line proxy jump", opts: ServerSSHOptions{ProxyJump: "bastion\nHost *"}, wantErr: true},
+		{name: "invalid option name", opts: ServerSSHOptions{Options: map[string]string{"Server Alive": "30"}}, wantErr: true},
+		{name: "managed option", opts: ServerSSHOptions{Options: map[string]string{"hostname": "elsewhere"}}, wantErr: true},
+		{name: "empty option value", opts: ServerSSHOptions{Options: map[string]string{"Compression": ""}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerSSHOptionsSaveLoad(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	data := `servers:
+  plain:
+    host: plain.local
+    http_port: 8080
+    ssh_port: 2222
+  behind-bastion:
+    host: 10.0.0.5
+    http_port: 8080
+    ssh_port: 2222
+    ssh:
+      proxy_jump: me@bastion.example.com
+      forward_agent: true
+      options:
+        ServerAliveInterval: "30"
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadClientConfigFromPath(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	ssh := cfg.Servers["behind-bastion"].SSH
+	if ssh.ProxyJump != "me@bastion.example.com" || !ssh.ForwardAgent || ssh.Options["ServerAliveInterval"] != "30" {
+		t.Errorf("SSH = %+v, want the configured options", ssh)
+	}
+
+	if err := cfg.SaveToPath(configPath); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	saved, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Servers without options don't gain an empty ssh section
+	if strings.Count(string(saved), "ssh:") != 1 || !strings.Contains(string(saved), "proxy_jump: me@bastion.example.com") {
+		t.Errorf("saved config has wrong ssh sections:\n%s", saved)
+	}
+
+	bad := strings.Replace(data, "ServerAliveInterval", "Port", 1)
+	if err := os.WriteFile(configPath, []byte(bad), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadClientConfigFromPath(configPath); err == nil {
+		t.Error("Load() should reject an option shed sets itself")
+	}
+}
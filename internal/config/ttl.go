@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// What happens to a shed when its TTL runs out.
+const (
+	TTLDelete = "delete"
+	TTLStop   = "stop"
+)
+
+// MinTTL is the shortest TTL a shed may have. Expired sheds are found once
+// a minute, so shorter TTLs wouldn't be honored.
+const MinTTL = time.Minute
+
+// StopReasonExpired is the stopped_reason of sheds stopped when their TTL
+// ran out.
+const StopReasonExpired = "expired"
+
+// ParseTTL parses a TTL written as a Go duration, such as "2h" or "90m".
+func ParseTTL(s string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl %q: expected a duration such as 2h or 90m", s)
+	}
+	if ttl < MinTTL {
+		return 0, fmt.Errorf("invalid ttl %q: must be at least %s", s, MinTTL)
+	}
+	return ttl, nil
+}
+
+// validateTTL checks a create request's TTL and what to do when it runs out.
+func validateTTL(ttl, action string) ValidationErrors {
+	var errs ValidationErrors
+	if ttl != "" {
+		_, err := ParseTTL(ttl)
+		errs.Check("ttl", err)
+	}
+	switch {
+	case action == "":
+	case ttl == "":
+		errs.Add("ttl_action", FieldInvalid, "ttl_action requires a ttl")
+	case action != TTLDelete && action != TTLStop:
+		errs.Add("ttl_action", FieldInvalid, fmt.Sprintf("ttl_action must be %s or %s", TTLDelete, TTLStop))
+	}
+	return errs
+}
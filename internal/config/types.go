@@ -2,8 +2,12 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -32,13 +36,252 @@ func ValidateShedName(name string) error {
 	return nil
 }
 
+// invalidNameChars matches runs of characters not allowed in shed names.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ShedNameFromRepo derives a valid shed name from a repository URL, using
+// the last path segment without its ".git" suffix (e.g. widget-factory for
+// git@github.com:org/widget-factory.git). It returns "" if no usable name
+// can be derived.
+func ShedNameFromRepo(repo string) string {
+	repo = strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	if i := strings.LastIndexAny(repo, "/:"); i >= 0 {
+		repo = repo[i+1:]
+	}
+
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(repo), "-")
+	name = strings.TrimLeft(name, "-0123456789")
+	if len(name) > MaxShedNameLength {
+		name = name[:MaxShedNameLength]
+	}
+	return strings.TrimRight(name, "-")
+}
+
 // Shed represents a development environment container.
 type Shed struct {
 	Name        string    `json:"name" yaml:"name"`
 	Status      string    `json:"status" yaml:"status"`
 	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
 	Repo        string    `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Image       string    `json:"image,omitempty" yaml:"image,omitempty"`
 	ContainerID string    `json:"container_id" yaml:"container_id"`
+
+	// Platform is the image platform the shed was created for, if one was
+	// chosen.
+	Platform string `json:"platform,omitempty" yaml:"platform,omitempty"`
+
+	// DeployKey is the name of the server deploy key mounted in the shed,
+	// if any.
+	DeployKey string `json:"deploy_key,omitempty" yaml:"deploy_key,omitempty"`
+
+	// SetupError describes a failed setup step, such as cloning the repo.
+	// The shed is still usable but its workspace may be incomplete.
+	SetupError string `json:"setup_error,omitempty" yaml:"setup_error,omitempty"`
+
+	// Owner is the name of the API token that created the shed. It is
+	// empty for sheds created without authentication.
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+
+	// LastActivity is when the shed was last used over SSH or the API,
+	// as remembered across server restarts.
+	LastActivity *time.Time `json:"last_activity,omitempty" yaml:"last_activity,omitempty"`
+
+	// Forwarding holds the shed's own SSH port forwarding rules, if any.
+	Forwarding *ForwardingRules `json:"forwarding,omitempty" yaml:"forwarding,omitempty"`
+
+	// Resources holds the shed's resource limits, if any.
+	Resources *Resources `json:"resources,omitempty" yaml:"resources,omitempty"`
+
+	// Mounts holds the shed's extra mounts, if any.
+	Mounts []ShedMount `json:"mounts,omitempty" yaml:"mounts,omitempty"`
+
+	// Labels holds the shed's user-defined labels, if any.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+
+	// Env holds the shed's own environment variables, if any.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// Secrets lists the server secrets given to the shed, if any. Their
+	// values are never included.
+	Secrets []SecretRef `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+
+	// Network is NetworkShared for sheds on the shared shed network and
+	// NetworkDedicated for sheds with a network of their own.
+	Network string `json:"network,omitempty" yaml:"network,omitempty"`
+
+	// IPAddress is a running shed's address on its network.
+	IPAddress string `json:"ip_address,omitempty" yaml:"ip_address,omitempty"`
+
+	// Ports lists the shed's published ports, if any: the bindings Docker
+	// made, with their host address, or as given at creation while the
+	// shed has none.
+	Ports []string `json:"ports,omitempty" yaml:"ports,omitempty"`
+
+	// HTTPPort is the port in the shed the server's reverse proxy sends
+	// PreviewURL to. PreviewURL is empty when the proxy is disabled.
+	HTTPPort   int    `json:"http_port,omitempty" yaml:"http_port,omitempty"`
+	PreviewURL string `json:"preview_url,omitempty" yaml:"preview_url,omitempty"`
+
+	// Services lists the shed's sidecar containers, if any.
+	Services []Service `json:"services,omitempty" yaml:"services,omitempty"`
+
+	// HealthCheck is the health check given when the shed was created, if
+	// any. Health is the result of the shed's health check, from its image
+	// or HealthCheck: HealthStarting, HealthHealthy, or HealthUnhealthy. It
+	// is empty for stopped sheds and sheds without a health check.
+	HealthCheck *HealthCheck `json:"health_check,omitempty" yaml:"health_check,omitempty"`
+	Health      string       `json:"health,omitempty" yaml:"health,omitempty"`
+
+	// PreStop is the command run in the shed before it is stopped, if any,
+	// from its image or its create request.
+	PreStop *PreStop `json:"pre_stop,omitempty" yaml:"pre_stop,omitempty"`
+
+	// Backup reports the shed's latest backups when the server has backups
+	// configured and the shed has been backed up or tried to be.
+	Backup *BackupStatus `json:"backup,omitempty" yaml:"backup,omitempty"`
+
+	// NoIdleStop is set on sheds exempt from the server's idle timeout.
+	NoIdleStop bool `json:"no_idle_stop,omitempty" yaml:"no_idle_stop,omitempty"`
+
+	// ExpiresAt is when a shed created with a TTL expires, and TTLAction
+	// whether it is then deleted or stopped.
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	TTLAction string     `json:"ttl_action,omitempty" yaml:"ttl_action,omitempty"`
+
+	// StoppedReason says why the server stopped the shed, such as
+	// StopReasonIdle, StopReasonSchedule, or StopReasonExpired. It is empty for running sheds
+	// and manual stops.
+	StoppedReason string `json:"stopped_reason,omitempty" yaml:"stopped_reason,omitempty"`
+
+	// Details holds debugging information about the shed's container. It
+	// is only filled in by GET /api/sheds/{name}.
+	Details *ShedDetails `json:"details,omitempty" yaml:"-"`
+}
+
+// ShedDetails describes a shed's container for debugging without access to
+// the Docker host.
+type ShedDetails struct {
+	ImageID string `json:"image_id"`
+
+	// ImagePlatform is the platform of the shed's image, such as
+	// linux/arm64. Emulated is set when it isn't the engine's own
+	// architecture, so the shed runs under emulation.
+	ImagePlatform string `json:"image_platform,omitempty"`
+	Emulated      bool   `json:"emulated,omitempty"`
+
+	// Mounts lists every mount, including the workspace and credentials.
+	Mounts []ShedMount `json:"mounts"`
+
+	// Env lists the names of the container's environment variables. Values
+	// are left out since they may hold secrets.
+	Env []string `json:"env"`
+
+	IPAddress string `json:"ip_address,omitempty"`
+
+	// Networks maps each network the container is attached to to its
+	// address on it.
+	Networks map[string]string `json:"networks,omitempty"`
+
+	// Ports lists exposed ports, with any host binding, e.g.
+	// "8080/tcp -> 0.0.0.0:8080".
+	Ports []string `json:"ports,omitempty"`
+
+	// StartedAt is when the container was started; zero unless running.
+	StartedAt    time.Time `json:"started_at"`
+	RestartCount int       `json:"restart_count"`
+
+	// Connections counts open SSH sessions, sftp sessions, and forwards.
+	Connections int `json:"connections"`
+	// LastConnection is when the shed was last used over SSH since the
+	// server started, or zero if it hasn't been.
+	LastConnection time.Time `json:"last_connection"`
+	// Sessions counts tmux sessions; zero unless running.
+	Sessions int `json:"sessions"`
+}
+
+// ShedStats reports resource usage and activity for a running shed.
+// It is returned by GET /api/sheds/{name}/stats. Network and block I/O are
+// totals since the container started. WorkspaceSize, Sessions, and
+// LastActivity are left zero when activity=false is requested.
+type ShedStats struct {
+	Name          string    `json:"name"`
+	CPUPercent    float64   `json:"cpu_percent"`
+	MemoryUsage   uint64    `json:"memory_usage"`
+	MemoryLimit   uint64    `json:"memory_limit"`
+	NetworkRx     uint64    `json:"network_rx"`
+	NetworkTx     uint64    `json:"network_tx"`
+	BlockRead     uint64    `json:"block_read"`
+	BlockWrite    uint64    `json:"block_write"`
+	PIDs          uint64    `json:"pids"`
+	WorkspaceSize int64     `json:"workspace_size"`
+	Sessions      int       `json:"sessions"`
+	LastActivity  time.Time `json:"last_activity"`
+}
+
+// WorkspaceDiff summarizes uncommitted work in a shed's workspace.
+// It is returned by GET /api/sheds/{name}/diff.
+type WorkspaceDiff struct {
+	Name  string       `json:"name"`
+	Repos []RepoStatus `json:"repos"`
+}
+
+// RepoStatus describes the state of one git repository in a workspace.
+// Path is relative to the workspace root. Diff is only populated when the
+// full diff is requested, and is cut off at MaxDiffBytes.
+type RepoStatus struct {
+	Path      string       `json:"path"`
+	Branch    string       `json:"branch,omitempty"`
+	Ahead     int          `json:"ahead,omitempty"`
+	Behind    int          `json:"behind,omitempty"`
+	Changes   []FileChange `json:"changes"`
+	Diff      string       `json:"diff,omitempty"`
+	Truncated bool         `json:"truncated,omitempty"`
+}
+
+// FileChange is a single entry from git status. Status is the two-letter
+// porcelain code, e.g. " M" for a modified file or "??" for an untracked one.
+type FileChange struct {
+	Status string `json:"status"`
+	Path   string `json:"path"`
+}
+
+// Clean reports whether the repository has no uncommitted or unpushed changes.
+func (r RepoStatus) Clean() bool {
+	return len(r.Changes) == 0 && r.Ahead == 0
+}
+
+// MaxDiffBytes is the largest diff returned for a single repository.
+const MaxDiffBytes = 1 << 20
+
+// Checkpoint is a saved snapshot of a shed's process state.
+type Checkpoint struct {
+	Name string `json:"name"`
+}
+
+// CheckpointsResponse is returned by GET /api/sheds/{name}/checkpoints.
+type CheckpointsResponse struct {
+	Checkpoints []Checkpoint `json:"checkpoints"`
+}
+
+// CreateCheckpointRequest is the request body for POST /api/sheds/{name}/checkpoints.
+// The shed is stopped once checkpointed unless LeaveRunning is set.
+type CreateCheckpointRequest struct {
+	Name         string `json:"name,omitempty"`
+	LeaveRunning bool   `json:"leave_running,omitempty"`
+}
+
+// CloneShedRequest is the request body for POST /api/sheds/{name}/clone.
+type CloneShedRequest struct {
+	// Name is the name of the new shed.
+	Name string `json:"name"`
+}
+
+// RebuildShedRequest is the request body for POST /api/sheds/{name}/rebuild.
+type RebuildShedRequest struct {
+	// Image replaces the shed's image; empty keeps the current one.
+	Image string `json:"image,omitempty"`
+	// Pull fetches the image even if it is present, to pick up updates.
+	Pull bool `json:"pull,omitempty"`
 }
 
 // Shed status constants.
@@ -46,20 +289,152 @@ const (
 	StatusRunning  = "running"
 	StatusStopped  = "stopped"
 	StatusStarting = "starting"
+	StatusPaused   = "paused"
 	StatusError    = "error"
 )
 
+// StopReasonIdle is the stopped_reason of sheds stopped by the server for
+// being idle longer than its idle_timeout.
+const StopReasonIdle = "idle"
+
+// ValidStatus reports whether status is a known shed status.
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusRunning, StatusStopped, StatusStarting, StatusPaused, StatusError:
+		return true
+	}
+	return false
+}
+
+// Limits for GET /api/sheds/{name}/wait.
+const (
+	DefaultWaitTimeout = 60 * time.Second
+	MaxWaitTimeout     = 5 * time.Minute
+)
+
+// LogOptions selects container output for GET /api/sheds/{name}/logs.
+type LogOptions struct {
+	// Follow keeps the stream open for new output
+	Follow bool
+	// Tail limits output to the last Tail lines; negative returns all lines
+	Tail int
+}
+
 // ServerInfo is returned by GET /api/info.
 type ServerInfo struct {
 	Name     string `json:"name"`
 	Version  string `json:"version"`
 	SSHPort  int    `json:"ssh_port"`
 	HTTPPort int    `json:"http_port"`
+
+	// APIVersion is the newest API version the server serves, and
+	// MinClientVersion the oldest CLI version it works with.
+	APIVersion       string `json:"api_version"`
+	MinClientVersion string `json:"min_client_version"`
+
+	// DockerHost is the address of the engine that runs sheds.
+	DockerHost string `json:"docker_host,omitempty"`
+
+	// Prepull reports scheduled image pulls; empty when pre-pulling is disabled.
+	Prepull []ImagePullStatus `json:"prepull,omitempty"`
+
+	// ImageGC reports image collection; nil when it is disabled.
+	ImageGC *ImageGCStatus `json:"image_gc,omitempty"`
+
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
+
+// ServerCapabilities reports optional features the server's runtime supports.
+type ServerCapabilities struct {
+	// Checkpoint is true when running sheds can be checkpointed with CRIU.
+	Checkpoint bool `json:"checkpoint"`
+
+	// GPU is true when the NVIDIA container runtime is installed.
+	GPU bool `json:"gpu"`
+}
+
+// LogLevel is returned by GET /api/server/log-level and is the request body
+// for PUT, which changes the server's log level until it restarts.
+type LogLevel struct {
+	// Level is debug, info, warn, or error.
+	Level string `json:"level"`
+}
+
+// ServerCapacity is returned by GET /api/server/capacity. It describes the
+// host's resources and current load for placing new sheds. Memory and disk
+// sizes are in bytes; free values are zero when they cannot be determined.
+type ServerCapacity struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	// Runtime is docker or podman; DockerVersion is its engine version.
+	Runtime       string `json:"runtime,omitempty"`
+	DockerVersion string `json:"docker_version"`
+	CPUs          int    `json:"cpus"`
+
+	MemoryTotal     uint64 `json:"memory_total"`
+	MemoryAvailable uint64 `json:"memory_available"`
+
+	// DiskTotal and DiskFree are for the filesystem holding DockerRootDir,
+	// where images, containers, and volumes are stored.
+	DockerRootDir string `json:"docker_root_dir"`
+	DiskTotal     uint64 `json:"disk_total"`
+	DiskFree      uint64 `json:"disk_free"`
+
+	// Sheds counts sheds by status.
+	Sheds      map[string]int `json:"sheds"`
+	TotalSheds int            `json:"total_sheds"`
+
+	Features ServerCapabilities `json:"features"`
+}
+
+// Image is a local image that sheds can be created from.
+type Image struct {
+	// Name is the image reference, e.g. shed-base:latest.
+	Name      string    `json:"name"`
+	ID        string    `json:"id"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	// Default is set on the server's default image.
+	Default bool `json:"default,omitempty"`
+}
+
+// ImagesResponse is returned by GET /api/images.
+type ImagesResponse struct {
+	Images []Image `json:"images"`
+}
+
+// BuildImageRequest describes an image build for POST /api/images/build.
+// It is sent as query parameters; the body is the build context.
+type BuildImageRequest struct {
+	// Tag names the built image, e.g. shed-go:latest.
+	Tag string
+	// Dockerfile is the Dockerfile's path within the build context.
+	Dockerfile string
+	// Pull fetches newer versions of base images before building.
+	Pull bool
+}
+
+// BuildOutput is a chunk of build output, sent as an SSE "output" event by
+// POST /api/images/build.
+type BuildOutput struct {
+	Stream string `json:"stream"`
+}
+
+// ImagePullStatus is the outcome of the most recent scheduled pull of an image.
+type ImagePullStatus struct {
+	Image       string    `json:"image"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success"`
+	Duration    float64   `json:"duration_seconds,omitempty"`
+	Error       string    `json:"error,omitempty"`
 }
 
 // SSHHostKeyResponse is returned by GET /api/ssh-host-key.
 type SSHHostKeyResponse struct {
 	HostKey string `json:"host_key"`
+	// NextHostKey is the key that will replace HostKey, set while a
+	// rotation is in progress.
+	NextHostKey string `json:"next_host_key,omitempty"`
 }
 
 // ShedsResponse is returned by GET /api/sheds.
@@ -67,11 +442,631 @@ type ShedsResponse struct {
 	Sheds []Shed `json:"sheds"`
 }
 
+// Session represents a tmux or zellij session running inside a shed.
+type Session struct {
+	Name         string    `json:"name"`
+	Attached     bool      `json:"attached"`
+	Windows      int       `json:"windows"`
+	LastActivity time.Time `json:"last_activity"`
+	Panes        []string  `json:"panes,omitempty"`
+}
+
+// SessionsResponse is returned by GET /api/sheds/{name}/sessions.
+type SessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+// ListeningPort is a TCP port a process inside a shed is listening on.
+type ListeningPort struct {
+	Port    int    `json:"port"`
+	Address string `json:"address"`
+	Process string `json:"process,omitempty"`
+	PID     int    `json:"pid,omitempty"`
+}
+
+// Loopback reports whether the port only accepts connections from inside
+// the shed. SSH port forwarding connects to the shed's container address,
+// so loopback ports can't be forwarded.
+func (p ListeningPort) Loopback() bool {
+	ip := net.ParseIP(p.Address)
+	return ip != nil && ip.IsLoopback()
+}
+
+// PortsResponse is returned by GET /api/sheds/{name}/ports.
+type PortsResponse struct {
+	Ports []ListeningPort `json:"ports"`
+}
+
+// CreateSessionRequest is the request body for
+// POST /api/sheds/{name}/sessions.
+type CreateSessionRequest struct {
+	Name string `json:"name"`
+
+	// Command is a shell command to run in the session instead of a login
+	// shell. The session ends when it exits.
+	Command string `json:"command,omitempty"`
+}
+
+// Validate checks the session name.
+func (r *CreateSessionRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	errs.Check("name", ValidateSessionName(r.Name))
+	return errs
+}
+
+// Limits for POST /api/sheds/{name}/sessions/{session}/exec.
+const (
+	DefaultSessionExecTimeout = 10 * time.Minute
+	MaxSessionExecTimeout     = time.Hour
+
+	// MaxSessionExecOutput is how much of a command's output is returned;
+	// only the end of longer output is kept.
+	MaxSessionExecOutput = 1 << 20
+)
+
+// SessionExecRequest is the request body for
+// POST /api/sheds/{name}/sessions/{session}/exec.
+type SessionExecRequest struct {
+	Command string `json:"command"`
+
+	// Timeout is how long to wait for the command, as a duration such as
+	// "30m". Empty waits DefaultSessionExecTimeout.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Validate checks the command and timeout.
+func (r *SessionExecRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	if strings.TrimSpace(r.Command) == "" {
+		errs.Add("command", FieldRequired, "command is required")
+	}
+	if r.Timeout != "" {
+		if _, err := r.TimeoutDuration(); err != nil {
+			errs.Check("timeout", err)
+		}
+	}
+	return errs
+}
+
+// TimeoutDuration returns how long to wait for the command.
+func (r *SessionExecRequest) TimeoutDuration() (time.Duration, error) {
+	if r.Timeout == "" {
+		return DefaultSessionExecTimeout, nil
+	}
+	d, err := time.ParseDuration(r.Timeout)
+	if err != nil || d <= 0 || d > MaxSessionExecTimeout {
+		return 0, fmt.Errorf("timeout must be a positive duration of at most %s", MaxSessionExecTimeout)
+	}
+	return d, nil
+}
+
+// SessionExecResult is the outcome of a command run in a tmux session.
+type SessionExecResult struct {
+	Session  string `json:"session"`
+	Output   string `json:"output"`
+	ExitCode int    `json:"exit_code"`
+
+	// Truncated is set when only the last MaxSessionExecOutput bytes of
+	// the output are included.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// RenameSessionRequest is the request body for
+// PATCH /api/sheds/{name}/sessions/{session}.
+type RenameSessionRequest struct {
+	Name string `json:"name"`
+}
+
+// Validate checks the new session name.
+func (r *RenameSessionRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+	errs.Check("name", ValidateSessionName(r.Name))
+	return errs
+}
+
+// TerminalRequest describes a shell for GET /api/sheds/{name}/terminal. It
+// is sent as query parameters before the WebSocket upgrade.
+type TerminalRequest struct {
+	// Session attaches to (or creates) a tmux session; empty opens a plain
+	// login shell.
+	Session string
+	// Cols and Rows are the initial terminal size; zero keeps the default.
+	Cols uint
+	Rows uint
+	// Term is the TERM value in the shed, e.g. xterm-256color.
+	Term string
+}
+
+// DefaultTerminalTerm is the TERM used when a terminal request sets none.
+const DefaultTerminalTerm = "xterm-256color"
+
+// TerminalMessage is a JSON control message sent as a WebSocket text
+// message on the terminal endpoint. Terminal data travels in binary
+// messages in both directions.
+type TerminalMessage struct {
+	Type string `json:"type"`
+	// Cols and Rows are set on resize messages.
+	Cols uint `json:"cols,omitempty"`
+	Rows uint `json:"rows,omitempty"`
+	// Data is set on input messages, for clients that only send text.
+	Data string `json:"data,omitempty"`
+	// Code is set on exit messages.
+	Code *int `json:"code,omitempty"`
+}
+
+// Terminal message types.
+const (
+	TerminalResize = "resize"
+	TerminalInput  = "input"
+	TerminalExit   = "exit"
+)
+
+// DefaultSessionName is the tmux session used when none is specified.
+const DefaultSessionName = "default"
+
+// Terminal multiplexers that can run sessions. A shed uses the first one
+// its image has, in this order.
+const (
+	MultiplexerTmux   = "tmux"
+	MultiplexerZellij = "zellij"
+)
+
+// SessionAttachCommand returns the command that attaches to a session in a
+// shed, creating it if needed, with whichever multiplexer the shed uses.
+func SessionAttachCommand(session string) []string {
+	return []string{"sh", "-c", `command -v tmux >/dev/null 2>&1 && exec tmux new-session -A -s "$1"; exec zellij attach --create "$1"`, "sh", session}
+}
+
+// sessionNameRegex validates tmux session names: alphanumeric, hyphens, and underscores.
+var sessionNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// MaxSessionNameLength is the maximum allowed length for a session name.
+const MaxSessionNameLength = 64
+
+// ValidateSessionName validates that a tmux session name is valid.
+// Names may contain letters, digits, hyphens, and underscores, and must be at most 64 characters.
+func ValidateSessionName(name string) error {
+	if name == "" {
+		return fmt.Errorf("session name cannot be empty")
+	}
+
+	if len(name) > MaxSessionNameLength {
+		return fmt.Errorf("session name cannot exceed %d characters", MaxSessionNameLength)
+	}
+
+	if !sessionNameRegex.MatchString(name) {
+		return fmt.Errorf("session name must contain only letters, digits, hyphens, and underscores")
+	}
+
+	return nil
+}
+
+// Search field names accepted by GET /api/search.
+const (
+	SearchFieldName   = "name"
+	SearchFieldRepo   = "repo"
+	SearchFieldLabels = "labels"
+)
+
+// SearchFields lists all searchable fields, used when none are specified.
+var SearchFields = []string{SearchFieldName, SearchFieldRepo, SearchFieldLabels}
+
 // CreateShedRequest is the request body for POST /api/sheds.
 type CreateShedRequest struct {
-	Name  string `json:"name"`
-	Repo  string `json:"repo,omitempty"`
-	Image string `json:"image,omitempty"`
+	// Name is the shed name. If empty, a name is derived from Repo.
+	Name      string `json:"name,omitempty"`
+	Repo      string `json:"repo,omitempty"`
+	Image     string `json:"image,omitempty"`
+	DeployKey string `json:"deploy_key,omitempty"`
+
+	// Platform selects the image variant to pull and run, such as
+	// linux/arm64. Empty uses the server's platform setting, or the
+	// engine's own platform.
+	Platform string `json:"platform,omitempty"`
+
+	// ExistsOK returns a shed that already has the name instead of failing,
+	// as long as it has the requested repo and image, if any.
+	ExistsOK bool `json:"exists_ok,omitempty"`
+
+	// Worktree checks the repo out as a git worktree of a base clone shared
+	// by every shed for the same repo, instead of a full clone.
+	Worktree bool `json:"worktree,omitempty"`
+
+	// Branch is the branch to check out in worktree mode. If empty, a
+	// branch named shed/<name> is created from the remote's default branch.
+	Branch string `json:"branch,omitempty"`
+
+	// Ref is the branch or tag to clone instead of the remote's default
+	// branch. Worktree sheds use Branch instead.
+	Ref string `json:"ref,omitempty"`
+
+	// Depth makes a shallow clone of this many commits. Zero clones the
+	// full history.
+	Depth int `json:"depth,omitempty"`
+
+	// SafetyPush overrides the server's safety push policy for this shed.
+	SafetyPush *bool `json:"safety_push,omitempty"`
+
+	// Timezone (e.g. Europe/Berlin) and Locale (e.g. de_DE.UTF-8) override
+	// the server defaults for this shed.
+	Timezone string `json:"timezone,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+
+	// Forwarding further limits the ports SSH clients may forward for this
+	// shed, on top of the server's rules.
+	Forwarding *ForwardingRules `json:"forwarding,omitempty"`
+
+	// NoIdleStop exempts the shed from the server's idle timeout.
+	NoIdleStop bool `json:"no_idle_stop,omitempty"`
+
+	// TTL makes the shed expire this long after it is created, given as a
+	// duration such as "2h". TTLAction is what happens then: TTLDelete, the
+	// default, or TTLStop.
+	TTL       string `json:"ttl,omitempty"`
+	TTLAction string `json:"ttl_action,omitempty"`
+
+	// Mounts are extra bind or volume mounts, which must be allowed by the
+	// server's mounts config.
+	Mounts []ShedMount `json:"mounts,omitempty"`
+
+	// Labels are user-defined key/value pairs for organizing sheds.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Env sets environment variables in the shed, on top of the server's
+	// env file.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Secrets gives the shed server secrets as environment variables or
+	// files. Each secret must exist.
+	Secrets []SecretRef `json:"secrets,omitempty"`
+
+	// Network is NetworkShared to attach the shed to the shared shed
+	// network, where other shared sheds reach it as <name>.shed.internal,
+	// or NetworkDedicated to give it a network of its own where it is
+	// reachable as <name>.
+	Network string `json:"network,omitempty"`
+
+	// Ports publishes container ports on the Docker host, each as
+	// "[hostIP:]hostPort:containerPort[/protocol]", e.g. "8080:80".
+	Ports []string `json:"ports,omitempty"`
+
+	// HTTPPort is a port in the shed for the server's reverse proxy to
+	// serve at the shed's preview URL.
+	HTTPPort int `json:"http_port,omitempty"`
+
+	// Services are sidecar containers, such as databases, run alongside the
+	// shed and reachable from it on localhost.
+	Services []Service `json:"services,omitempty"`
+
+	// HealthCheck is run in the shed to report whether it is usable. It
+	// replaces any health check defined by the image.
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+
+	// PreStop is run in the shed before it is stopped. It replaces any
+	// pre-stop hook defined by the image.
+	PreStop *PreStop `json:"pre_stop,omitempty"`
+
+	// Resources limits the shed's CPU, memory, and processes. Unset limits
+	// fall back to the server's defaults.
+	Resources
+
+	// Owner is set by the server to the name of the API token making the
+	// request. It is never read from the request body.
+	Owner string `json:"-"`
+
+	// IdempotencyKey is set by the server from the request's
+	// HeaderIdempotencyKey. A create retried with the same key returns the
+	// shed the first one created instead of failing.
+	IdempotencyKey string `json:"-"`
+}
+
+// Phases of shed creation, reported in order by streaming creates.
+const (
+	PhaseVolume    = "volume"
+	PhaseImage     = "image"
+	PhaseContainer = "container"
+	PhaseStart     = "start"
+	PhaseServices  = "services"
+	PhaseClone     = "clone"
+)
+
+// Progress statuses for a creation phase.
+const (
+	ProgressStarted = "started"
+	ProgressRunning = "running"
+	ProgressDone    = "done"
+	ProgressFailed  = "failed"
+)
+
+// AuditEntry records an action taken through the API or SSH: who did it,
+// from where, and when.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+
+	// Via is AuditViaAPI or AuditViaSSH.
+	Via string `json:"via"`
+
+	// Actor names the API token or SSH key used. It is empty when the
+	// server accepts requests without one.
+	Actor string `json:"actor,omitempty"`
+	// Fingerprint is the SSH key's SHA256 fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Remote      string `json:"remote"`
+
+	// Action is what was done, such as create, delete, exec, or console.
+	Action string `json:"action"`
+	Shed   string `json:"shed,omitempty"`
+	// Detail holds extra information, such as the command run by exec.
+	Detail string `json:"detail,omitempty"`
+
+	// Status is the HTTP status of an API request.
+	Status int `json:"status,omitempty"`
+}
+
+// Sources of audited actions.
+const (
+	AuditViaAPI = "api"
+	AuditViaSSH = "ssh"
+)
+
+// AuditResponse is the response for GET /api/audit.
+type AuditResponse struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// ShedEvent is a change to a shed, sent by GET /api/events.
+type ShedEvent struct {
+	Type string    `json:"type"`
+	Shed string    `json:"shed"`
+	Time time.Time `json:"time"`
+
+	// Message gives details for some events, such as why a clone failed.
+	Message string `json:"message,omitempty"`
+}
+
+// Types of shed events.
+const (
+	ShedEventCreated       = "created"
+	ShedEventStarted       = "started"
+	ShedEventStopped       = "stopped"
+	ShedEventPaused        = "paused"
+	ShedEventResumed       = "resumed"
+	ShedEventDeleted       = "deleted"
+	ShedEventCloneFailed   = "clone-failed"
+	ShedEventSessionOpened = "session-opened"
+	ShedEventSessionClosed = "session-closed"
+)
+
+// Server-sent event names used by streaming endpoints.
+const (
+	EventProgress = "progress"
+	EventOutput   = "output"
+	EventDone     = "done"
+	EventError    = "error"
+)
+
+// CreateProgress is a progress event for one phase of shed creation. It is
+// sent as an SSE "progress" event when POST /api/sheds is requested with
+// Accept: text/event-stream; the stream ends with a "done" event carrying
+// the Shed or an "error" event carrying an APIError.
+type CreateProgress struct {
+	Phase   string `json:"phase"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+
+	// Current and Total are bytes done so far in a running phase, such as
+	// the layers downloaded by an image pull.
+	Current int64 `json:"current,omitempty"`
+	Total   int64 `json:"total,omitempty"`
+}
+
+// ProgressFunc receives creation progress events. A nil ProgressFunc
+// discards them.
+type ProgressFunc func(CreateProgress)
+
+// Report sends a progress event if f is non-nil.
+func (f ProgressFunc) Report(phase, status, message string) {
+	if f != nil {
+		f(CreateProgress{Phase: phase, Status: status, Message: message})
+	}
+}
+
+// Job is an operation running in the background on the server, started by
+// an asynchronous request such as POST /api/sheds?async=true.
+type Job struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Shed   string `json:"shed"`
+	Status string `json:"status"`
+
+	// Progress is the most recent progress event, whose phase is the one
+	// the job is in.
+	Progress *CreateProgress `json:"progress,omitempty"`
+
+	// Result is the shed once the job has succeeded, and Error says why it
+	// failed.
+	Result *Shed           `json:"result,omitempty"`
+	Error  *APIErrorDetail `json:"error,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Types of jobs.
+const (
+	JobTypeCreate = "create"
+)
+
+// Job statuses.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobsResponse is returned by GET /api/jobs.
+type JobsResponse struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// DeployKey describes a server-managed SSH deploy key. The private key never
+// leaves the server.
+type DeployKey struct {
+	Name        string    `json:"name"`
+	PublicKey   string    `json:"public_key"`
+	Fingerprint string    `json:"fingerprint"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DeployKeysResponse is returned by GET /api/deploy-keys.
+type DeployKeysResponse struct {
+	DeployKeys []DeployKey `json:"deploy_keys"`
+}
+
+// CreateDeployKeyRequest is the request body for POST /api/deploy-keys.
+type CreateDeployKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// AuthorizedKey is an SSH public key allowed to connect to sheds.
+type AuthorizedKey struct {
+	Name        string `json:"name"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+	// Shed limits the key to one shed; empty allows every shed
+	Shed    string    `json:"shed,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// AuthorizedKeysResponse is returned by GET /api/keys.
+type AuthorizedKeysResponse struct {
+	Keys []AuthorizedKey `json:"keys"`
+}
+
+// AddAuthorizedKeyRequest is the request body for POST /api/keys.
+type AddAuthorizedKeyRequest struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+	Shed      string `json:"shed,omitempty"`
+}
+
+// ValidateKeyName validates that an authorized key name is valid.
+// Keys are typically named after the machine they belong to.
+func ValidateKeyName(name string) error {
+	if name == "" {
+		return fmt.Errorf("key name cannot be empty")
+	}
+
+	if len(name) > MaxShedNameLength {
+		return fmt.Errorf("key name cannot exceed %d characters", MaxShedNameLength)
+	}
+
+	if !shedNameRegex.MatchString(name) {
+		return fmt.Errorf("key name must be lowercase alphanumeric with hyphens (not at start/end), starting with a letter")
+	}
+
+	return nil
+}
+
+// ValidateCheckpointName validates that a checkpoint name is valid.
+// Checkpoint names follow the same rules as shed names.
+func ValidateCheckpointName(name string) error {
+	if name == "" {
+		return fmt.Errorf("checkpoint name cannot be empty")
+	}
+
+	if len(name) > MaxShedNameLength {
+		return fmt.Errorf("checkpoint name cannot exceed %d characters", MaxShedNameLength)
+	}
+
+	if !shedNameRegex.MatchString(name) {
+		return fmt.Errorf("checkpoint name must be lowercase alphanumeric with hyphens (not at start/end), starting with a letter")
+	}
+
+	return nil
+}
+
+// APITokenInfo describes a minted API token. The token itself is only
+// shown when it is created.
+type APITokenInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidateTokenName validates that an API token name is valid.
+// Tokens are typically named after the person or machine using them.
+func ValidateTokenName(name string) error {
+	if name == "" {
+		return fmt.Errorf("token name cannot be empty")
+	}
+
+	if len(name) > MaxShedNameLength {
+		return fmt.Errorf("token name cannot exceed %d characters", MaxShedNameLength)
+	}
+
+	if !shedNameRegex.MatchString(name) {
+		return fmt.Errorf("token name must be lowercase alphanumeric with hyphens (not at start/end), starting with a letter")
+	}
+
+	return nil
+}
+
+// ValidateDeployKeyName validates that a deploy key name is valid.
+// Deploy keys are typically named after a shed or repository and follow
+// the same rules as shed names.
+func ValidateDeployKeyName(name string) error {
+	if name == "" {
+		return fmt.Errorf("deploy key name cannot be empty")
+	}
+
+	if len(name) > MaxShedNameLength {
+		return fmt.Errorf("deploy key name cannot exceed %d characters", MaxShedNameLength)
+	}
+
+	if !shedNameRegex.MatchString(name) {
+		return fmt.Errorf("deploy key name must be lowercase alphanumeric with hyphens (not at start/end), starting with a letter")
+	}
+
+	return nil
+}
+
+// Batch action constants.
+const (
+	BatchActionStart  = "start"
+	BatchActionStop   = "stop"
+	BatchActionPause  = "pause"
+	BatchActionResume = "resume"
+	BatchActionDelete = "delete"
+)
+
+// MaxBatchOperations is the maximum number of operations in a single batch request.
+const MaxBatchOperations = 100
+
+// BatchRequest is the request body for POST /api/batch.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is a single action on a named shed within a batch.
+type BatchOperation struct {
+	Action     string `json:"action"`
+	Name       string `json:"name"`
+	KeepVolume bool   `json:"keep_volume,omitempty"`
+}
+
+// BatchResponse is returned by POST /api/batch.
+// Results are in the same order as the requested operations.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of a single batch operation.
+type BatchResult struct {
+	Action string          `json:"action"`
+	Name   string          `json:"name"`
+	Status int             `json:"status"`
+	Shed   *Shed           `json:"shed,omitempty"`
+	Error  *APIErrorDetail `json:"error,omitempty"`
 }
 
 // APIError represents an error response from the API.
@@ -79,10 +1074,12 @@ type APIError struct {
 	Error APIErrorDetail `json:"error"`
 }
 
-// APIErrorDetail contains the error code and message.
+// APIErrorDetail contains the error code and message. Validation errors
+// also list each rejected field.
 type APIErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
 }
 
 // NewAPIError creates a new APIError with the given code and message.
@@ -101,20 +1098,123 @@ const (
 	ErrShedAlreadyExists  = "SHED_ALREADY_EXISTS"
 	ErrShedAlreadyRunning = "SHED_ALREADY_RUNNING"
 	ErrShedAlreadyStopped = "SHED_ALREADY_STOPPED"
+	ErrShedPaused         = "SHED_PAUSED"
+	ErrShedNotPaused      = "SHED_NOT_PAUSED"
 	ErrInvalidShedName    = "INVALID_SHED_NAME"
 	ErrCloneFailed        = "CLONE_FAILED"
 	ErrDockerError        = "DOCKER_ERROR"
 	ErrInternalError      = "INTERNAL_ERROR"
+	ErrInvalidRequest     = "INVALID_REQUEST"
+	ErrValidationFailed   = "VALIDATION_FAILED"
+	ErrDeployKeyNotFound  = "DEPLOY_KEY_NOT_FOUND"
+	ErrDeployKeyExists    = "DEPLOY_KEY_ALREADY_EXISTS"
+	ErrDeployKeyInUse     = "DEPLOY_KEY_IN_USE"
+	ErrSecretNotFound     = "SECRET_NOT_FOUND"
+	ErrSafetyPushFailed   = "SAFETY_PUSH_FAILED"
+	ErrCheckpointNotFound = "CHECKPOINT_NOT_FOUND"
+	ErrScheduleNotFound   = "SCHEDULE_NOT_FOUND"
+	ErrBackupFailed       = "BACKUP_FAILED"
+	ErrBackupInProgress   = "BACKUP_IN_PROGRESS"
+	ErrBackupNotFound     = "BACKUP_NOT_FOUND"
+	ErrSessionNotFound    = "SESSION_NOT_FOUND"
+	ErrSessionExists      = "SESSION_ALREADY_EXISTS"
+	ErrSessionBusy        = "SESSION_BUSY"
+	ErrNotSupported       = "NOT_SUPPORTED"
+	ErrWaitTimeout        = "WAIT_TIMEOUT"
+	ErrUnauthorized       = "UNAUTHORIZED"
+	ErrForbidden          = "FORBIDDEN"
+	ErrKeyNotFound        = "KEY_NOT_FOUND"
+	ErrKeyExists          = "KEY_ALREADY_EXISTS"
+	ErrImagePullFailed    = "IMAGE_PULL_FAILED"
+	ErrImageBuildFailed   = "IMAGE_BUILD_FAILED"
+	ErrClientTooOld       = "CLIENT_TOO_OLD"
+	ErrRateLimited        = "RATE_LIMITED"
+	ErrJobNotFound        = "JOB_NOT_FOUND"
+	ErrIdempotencyKeyUsed = "IDEMPOTENCY_KEY_REUSED"
+	ErrNetworkPoolFull    = "NETWORK_POOL_EXHAUSTED"
+)
+
+// HTTP headers used to negotiate API compatibility. The CLI sends its
+// version with every request; the server answers with its API version and
+// the oldest CLI version it supports.
+const (
+	HeaderClientVersion    = "X-Shed-Client-Version"
+	HeaderAPIVersion       = "X-Shed-API-Version"
+	HeaderMinClientVersion = "X-Shed-Min-Client-Version"
 )
 
+// HeaderIdempotencyKey makes a create safe to retry: creates with the same
+// key return the same shed.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
 // Docker label keys for shed containers.
 const (
 	LabelShed        = "shed"
 	LabelShedName    = "shed.name"
 	LabelShedCreated = "shed.created"
 	LabelShedRepo    = "shed.repo"
+	LabelDeployKey   = "shed.deploy-key"
+	// LabelRepoCache marks worktree sheds with the name of the shared clone
+	// cache volume they use, and cache volumes with their repository URL.
+	LabelRepoCache = "shed.repo-cache"
+	// LabelSafetyPush is "true" or "false" on sheds that override the
+	// server's safety push policy.
+	LabelSafetyPush = "shed.safety-push"
+	// LabelForwardAllow and LabelForwardDeny hold a shed's comma-separated
+	// SSH port forwarding rules.
+	LabelForwardAllow = "shed.forward.allow"
+	LabelForwardDeny  = "shed.forward.deny"
+	// LabelIdleStop is "false" on sheds exempt from the idle timeout.
+	LabelIdleStop = "shed.idle-stop"
+
+	// LabelTTL holds a shed's TTL, which runs from its creation time, and
+	// LabelTTLAction what happens when it runs out.
+	LabelTTL       = "shed.ttl"
+	LabelTTLAction = "shed.ttl-action"
+	// LabelCPUs, LabelMemory, and LabelPidsLimit record a shed's resource
+	// limits, including server defaults.
+	LabelCPUs      = "shed.resources.cpus"
+	LabelMemory    = "shed.resources.memory"
+	LabelPidsLimit = "shed.resources.pids-limit"
+	// LabelMounts holds a shed's extra mounts as a JSON array.
+	LabelMounts = "shed.mounts"
+	// LabelUserPrefix is prepended to the keys of a shed's user-defined
+	// labels.
+	LabelUserPrefix = "shed.label."
+	// LabelEnvPrefix is prepended to the names of a shed's own environment
+	// variables.
+	LabelEnvPrefix = "shed.env."
+	// LabelSecrets holds a shed's secret references, without their values,
+	// as a JSON array.
+	LabelSecrets = "shed.secrets"
+	// LabelNetwork is NetworkShared on sheds attached to the shared network
+	// and NetworkDedicated on sheds with a network of their own.
+	LabelNetwork = "shed.network"
+	// LabelPorts holds a shed's published ports, comma-separated.
+	LabelPorts = "shed.ports"
+	// LabelHTTPPort is the port the reverse proxy sends a shed's preview
+	// URL to.
+	LabelHTTPPort = "shed.http-port"
+	// LabelServices holds a shed's sidecars as JSON.
+	LabelServices = "shed.services"
+	// LabelServiceOf marks a sidecar container with the shed it belongs to.
+	// Sidecars don't carry LabelShed, so they aren't taken for sheds.
+	LabelServiceOf = "shed.service-of"
+	// LabelServiceName is a sidecar container's service name.
+	LabelServiceName = "shed.service"
+	// LabelHealthCheck holds the health check given at creation as JSON.
+	LabelHealthCheck = "shed.health-check"
+	// LabelPreStop holds a shed's pre-stop hook as JSON. Images may set it
+	// to give their sheds a default hook.
+	LabelPreStop = "shed.pre-stop"
+	// LabelPlatform is the image platform a shed was created for.
+	LabelPlatform = "shed.platform"
 )
 
+// ForwardHostName resolves to the Docker host inside a shed, where ports
+// forwarded with ssh -R are listening.
+const ForwardHostName = "host.docker.internal"
+
 // ContainerPrefix is prepended to shed names for Docker containers.
 const ContainerPrefix = "shed-"
 
@@ -124,6 +1224,12 @@ const VolumePrefix = "shed-"
 // VolumeSuffix is appended to shed names for Docker volumes.
 const VolumeSuffix = "-workspace"
 
+// RepoCacheVolumePrefix is prepended to a repo hash for shared clone cache volumes.
+const RepoCacheVolumePrefix = "shed-cache-"
+
+// RepoCachePath is the path where a shared clone cache volume is mounted in containers.
+const RepoCachePath = "/var/cache/shed"
+
 // ContainerName returns the Docker container name for a shed.
 func ContainerName(shedName string) string {
 	return ContainerPrefix + shedName
@@ -134,5 +1240,17 @@ func VolumeName(shedName string) string {
 	return VolumePrefix + shedName + VolumeSuffix
 }
 
+// RepoCacheVolumeName returns the Docker volume name for the shared clone
+// cache of a repository. The name is derived from a hash of the repo URL
+// with any trailing ".git" or "/" removed, so equivalent URLs share a cache.
+func RepoCacheVolumeName(repo string) string {
+	normalized := strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	sum := sha256.Sum256([]byte(normalized))
+	return RepoCacheVolumePrefix + hex.EncodeToString(sum[:])[:12]
+}
+
 // WorkspacePath is the path where the workspace volume is mounted in containers.
 const WorkspacePath = "/workspace"
+
+// DeployKeyPath is the path where a shed's deploy key is mounted in containers.
+const DeployKeyPath = "/etc/shed/deploy_key"
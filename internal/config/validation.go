@@ -0,0 +1,326 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// Field error codes describing why a request field was rejected.
+const (
+	FieldRequired = "required"
+	FieldInvalid  = "invalid"
+	FieldNotFound = "not_found"
+	FieldConflict = "conflict"
+)
+
+// FieldError describes a problem with a single field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects the field errors found in a request.
+type ValidationErrors []FieldError
+
+// Add records a field error.
+func (v *ValidationErrors) Add(field, code, message string) {
+	*v = append(*v, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Check records err as an invalid field error if it is non-nil.
+func (v *ValidationErrors) Check(field string, err error) {
+	if err != nil {
+		v.Add(field, FieldInvalid, err.Error())
+	}
+}
+
+// Error summarizes the field errors in a single line.
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, fe := range v {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// CheckExisting returns an error if shed, which already has the request's
+// name, wasn't created from the repo and image the request asks for. Fields
+// the request leaves unset match anything.
+func (r *CreateShedRequest) CheckExisting(shed *Shed) error {
+	if r.Repo != "" && r.Repo != shed.Repo {
+		return fmt.Errorf("shed %q already exists with a different repo (%s)", shed.Name, orNone(shed.Repo))
+	}
+	if r.Image != "" && r.Image != shed.Image {
+		return fmt.Errorf("shed %q already exists with a different image (%s)", shed.Name, shed.Image)
+	}
+	return nil
+}
+
+// orNone returns s, or "none" if it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// Validate checks the fields of a create request that can be validated
+// without server state. It returns nil if the request is valid.
+func (r *CreateShedRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+
+	// Without a name, the server derives one from the repo
+	if r.Name == "" {
+		if r.Repo == "" {
+			errs.Add("name", FieldRequired, "shed name is required when no repo is given")
+		} else if ShedNameFromRepo(r.Repo) == "" {
+			errs.Add("name", FieldRequired, "shed name is required: none can be derived from the repo")
+		}
+	} else {
+		errs.Check("name", ValidateShedName(r.Name))
+	}
+
+	errs.Check("repo", ValidateGitRepoURL(r.Repo))
+
+	if r.DeployKey != "" {
+		errs.Check("deploy_key", ValidateDeployKeyName(r.DeployKey))
+	}
+
+	if r.Platform != "" {
+		errs.Check("platform", ValidatePlatform(r.Platform))
+	}
+
+	if r.Timezone != "" {
+		errs.Check("timezone", ValidateTimezone(r.Timezone))
+	}
+	if r.Locale != "" {
+		errs.Check("locale", ValidateLocale(r.Locale))
+	}
+
+	if r.Forwarding != nil {
+		errs.Check("forwarding", r.Forwarding.Validate())
+	}
+
+	errs = append(errs, r.Resources.Validate()...)
+	errs.Check("labels", ValidateLabels(r.Labels))
+	errs.Check("env", ValidateEnv(r.Env))
+	errs = append(errs, validateSecretRefs(r.Secrets, r.Env)...)
+	errs.Check("network", ValidateNetwork(r.Network))
+	errs = append(errs, validatePorts(r.Ports)...)
+	errs = append(errs, validateServices(r.Services)...)
+	if r.HealthCheck != nil {
+		errs.Check("health_check", r.HealthCheck.Validate())
+	}
+	if r.PreStop != nil {
+		errs.Check("pre_stop", r.PreStop.Validate())
+	}
+	errs = append(errs, validateTTL(r.TTL, r.TTLAction)...)
+	if r.HTTPPort < 0 || r.HTTPPort > 65535 {
+		errs.Add("http_port", FieldInvalid, "http_port must be 1-65535")
+	}
+
+	targets := make(map[string]bool, len(r.Mounts))
+	for i, m := range r.Mounts {
+		field := fmt.Sprintf("mounts[%d]", i)
+		if err := m.Validate(); err != nil {
+			errs.Check(field, err)
+			continue
+		}
+		target := filepath.Clean(m.Target)
+		if targets[target] {
+			errs.Add(field, FieldConflict, "more than one mount targets "+target)
+		}
+		targets[target] = true
+	}
+
+	if r.Worktree && r.Repo == "" {
+		errs.Add("repo", FieldRequired, "worktree mode requires a repo")
+	}
+	if r.Branch != "" && !r.Worktree {
+		errs.Add("branch", FieldInvalid, "branch is only supported in worktree mode")
+	}
+	if r.Ref != "" {
+		switch {
+		case r.Repo == "":
+			errs.Add("ref", FieldInvalid, "ref requires a repo")
+		case r.Worktree:
+			errs.Add("ref", FieldInvalid, "ref is not supported in worktree mode; use branch")
+		default:
+			errs.Check("ref", ValidateGitRef(r.Ref))
+		}
+	}
+	if r.Depth != 0 {
+		switch {
+		case r.Depth < 0:
+			errs.Add("depth", FieldInvalid, "depth cannot be negative")
+		case r.Repo == "":
+			errs.Add("depth", FieldInvalid, "depth requires a repo")
+		case r.Worktree:
+			errs.Add("depth", FieldInvalid, "depth is not supported in worktree mode")
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Validate checks the tag and Dockerfile path of a build request. If
+// prefixes are given, the tag must start with one so the image is listed.
+func (r *BuildImageRequest) Validate(prefixes []string) ValidationErrors {
+	var errs ValidationErrors
+
+	if r.Tag == "" {
+		errs.Add("tag", FieldRequired, "tag is required")
+	} else if err := ValidateImageRef(r.Tag); err != nil {
+		errs.Check("tag", err)
+	} else if !(ImagesConfig{Prefixes: prefixes}).Matches(r.Tag) {
+		errs.Add("tag", FieldInvalid, "tag must start with one of: "+strings.Join(prefixes, ", "))
+	}
+
+	if r.Dockerfile != "" {
+		clean := path.Clean(r.Dockerfile)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			errs.Add("dockerfile", FieldInvalid, "dockerfile must be a path inside the build context")
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// termRegex matches TERM values such as xterm-256color or screen.xterm.
+var termRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._+-]*$`)
+
+// Validate checks a terminal request and returns every problem found.
+func (r *TerminalRequest) Validate() ValidationErrors {
+	var errs ValidationErrors
+
+	if r.Session != "" {
+		errs.Check("session", ValidateSessionName(r.Session))
+	}
+	if r.Cols > 1000 || r.Rows > 1000 {
+		errs.Add("size", FieldInvalid, "cols and rows must be at most 1000")
+	}
+	if r.Term != "" && (len(r.Term) > 64 || !termRegex.MatchString(r.Term)) {
+		errs.Add("term", FieldInvalid, "term must be a terminal type such as xterm-256color")
+	}
+
+	return errs
+}
+
+// ValidateImageRef checks that ref is a valid image reference such as
+// shed-base:latest or ghcr.io/acme/dev:1.2.
+func ValidateImageRef(ref string) error {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return fmt.Errorf("invalid image reference %q: %v", ref, err)
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return fmt.Errorf("invalid image reference %q: digests cannot be used as tags", ref)
+	}
+	return nil
+}
+
+// ValidateGitRef checks that ref is usable as a branch or tag name for
+// git clone --branch.
+func ValidateGitRef(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("ref cannot be empty")
+	}
+	if strings.HasPrefix(ref, "-") || strings.HasPrefix(ref, "/") || strings.HasSuffix(ref, "/") ||
+		strings.HasSuffix(ref, ".") || strings.HasSuffix(ref, ".lock") ||
+		strings.Contains(ref, "..") || strings.Contains(ref, "//") || strings.Contains(ref, "@{") {
+		return fmt.Errorf("invalid ref %q", ref)
+	}
+	for _, r := range ref {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("invalid ref %q", ref)
+		}
+	}
+	return nil
+}
+
+// ValidateTimezone checks that tz is an IANA timezone name such as
+// America/New_York.
+func ValidateTimezone(tz string) error {
+	// LoadLocation also accepts "Local", which means nothing inside a shed
+	if tz == "Local" {
+		return fmt.Errorf("unknown timezone %q", tz)
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("unknown timezone %q", tz)
+	}
+	return nil
+}
+
+// localeRegex matches POSIX locale names such as en_US.UTF-8, de_DE, or
+// sr_RS@latin.
+var localeRegex = regexp.MustCompile(`^([a-z]{2,3}(_[A-Z]{2})?|C)(\.[A-Za-z0-9-]+)?(@[a-z]+)?$|^POSIX$`)
+
+// ValidateLocale checks that locale is a well-formed locale name.
+func ValidateLocale(locale string) error {
+	if !localeRegex.MatchString(locale) {
+		return fmt.Errorf("invalid locale %q: must look like en_US.UTF-8", locale)
+	}
+	return nil
+}
+
+// gitSSHRegex matches git@host:path format (e.g., git@github.com:user/repo.git)
+var gitSSHRegex = regexp.MustCompile(`^git@[a-zA-Z0-9][a-zA-Z0-9.-]*:[a-zA-Z0-9_.-]+/[a-zA-Z0-9_.-]+(?:\.git)?$`)
+
+// ValidateGitRepoURL validates that a git repository URL is well-formed.
+// Accepts https://, git://, ssh://, and git@host:path formats.
+func ValidateGitRepoURL(repoURL string) error {
+	if repoURL == "" {
+		return nil // Empty is valid (no repo to clone)
+	}
+
+	// Check for git@host:path format first (SCP-like syntax)
+	if strings.HasPrefix(repoURL, "git@") {
+		if !gitSSHRegex.MatchString(repoURL) {
+			return fmt.Errorf("invalid git SSH URL format: %s", repoURL)
+		}
+		return nil
+	}
+
+	// Parse as standard URL
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL: %w", err)
+	}
+
+	// Validate scheme
+	validSchemes := map[string]bool{
+		"https": true,
+		"http":  true,
+		"git":   true,
+		"ssh":   true,
+	}
+	if !validSchemes[parsed.Scheme] {
+		return fmt.Errorf("unsupported URL scheme %q: must be https, http, git, or ssh", parsed.Scheme)
+	}
+
+	// Validate host is present
+	if parsed.Host == "" {
+		return fmt.Errorf("repository URL must have a host")
+	}
+
+	// Validate path is present (should have at least /user/repo or /repo)
+	if parsed.Path == "" || parsed.Path == "/" {
+		return fmt.Errorf("repository URL must have a path")
+	}
+
+	return nil
+}
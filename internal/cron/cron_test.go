@@ -0,0 +1,73 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@often",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) should fail", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, 4, 15, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 19 * * 1-5", time.Date(2026, 4, 15, 19, 0, 0, 0, time.UTC)},
+		{"30 8 * * mon-fri", time.Date(2026, 4, 16, 8, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 4, 15, 18, 45, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2026, 4, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 4, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Either day field may match when both are restricted
+		{"0 12 20 * fri", time.Date(2026, 4, 17, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+		if !tt.want.IsZero() && !s.Matches(tt.want) {
+			t.Errorf("%q should match %v", tt.expr, tt.want)
+		}
+	}
+}
+
+func TestNextHalfHourZone(t *testing.T) {
+	kolkata := time.FixedZone("IST", 5*3600+1800)
+	s, err := Parse("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 4, 15, 7, 10, 0, 0, kolkata)
+	if got, want := s.Next(from), time.Date(2026, 4, 15, 9, 0, 0, 0, kolkata); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+}
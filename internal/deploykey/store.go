@@ -0,0 +1,178 @@
+// Package deploykey manages SSH deploy keys used to clone private repositories into sheds.
+package deploykey
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// publicKeySuffix is appended to a key's name for its public key file.
+const publicKeySuffix = ".pub"
+
+// Store manages deploy key pairs on disk. Each key is stored as a private key
+// file named after the key with a matching ".pub" public key file.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store rooted at dir. The directory is created on first write.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// PrivateKeyPath returns the path of a key's private key file.
+func (s *Store) PrivateKeyPath(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// publicKeyPath returns the path of a key's public key file.
+func (s *Store) publicKeyPath(name string) string {
+	return filepath.Join(s.dir, name+publicKeySuffix)
+}
+
+// Generate creates a new ED25519 key pair. It fails if a key with the name already exists.
+func (s *Store) Generate(name string) (*config.DeployKey, error) {
+	if err := config.ValidateDeployKeyName(name); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(s.PrivateKeyPath(name)); err == nil {
+		return nil, fmt.Errorf("deploy key %q already exists", name)
+	}
+
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ED25519 key: %w", err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %w", err)
+	}
+
+	// The comment identifies the key when it is listed on the git host
+	pemBlock, err := gossh.MarshalPrivateKey(privKey, "shed-"+name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create deploy key directory: %w", err)
+	}
+
+	if err := os.WriteFile(s.PrivateKeyPath(name), pem.EncodeToMemory(pemBlock), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	pubKeyData := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(signer.PublicKey()))) + " shed-" + name + "\n"
+	if err := os.WriteFile(s.publicKeyPath(name), []byte(pubKeyData), 0644); err != nil {
+		os.Remove(s.PrivateKeyPath(name)) // Clean up on failure
+		return nil, fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	return s.Get(name)
+}
+
+// Get returns the public details of a key.
+func (s *Store) Get(name string) (*config.DeployKey, error) {
+	if err := config.ValidateDeployKeyName(name); err != nil {
+		return nil, err
+	}
+
+	path := s.publicKeyPath(name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("deploy key %q not found", name)
+		}
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	pubKey, _, _, _, err := gossh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+
+	var createdAt time.Time
+	if info, err := os.Stat(path); err == nil {
+		createdAt = info.ModTime().UTC()
+	}
+
+	return &config.DeployKey{
+		Name:        name,
+		PublicKey:   strings.TrimSpace(string(data)),
+		Fingerprint: gossh.FingerprintSHA256(pubKey),
+		CreatedAt:   createdAt,
+	}, nil
+}
+
+// Exists reports whether a key with the name exists.
+func (s *Store) Exists(name string) bool {
+	if config.ValidateDeployKeyName(name) != nil {
+		return false
+	}
+	_, err := os.Stat(s.PrivateKeyPath(name))
+	return err == nil
+}
+
+// List returns all keys sorted by name.
+func (s *Store) List() ([]config.DeployKey, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []config.DeployKey{}, nil
+		}
+		return nil, fmt.Errorf("failed to read deploy key directory: %w", err)
+	}
+
+	keys := []config.DeployKey{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), publicKeySuffix)
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		key, err := s.Get(name)
+		if err != nil {
+			continue // Skip unreadable or foreign files
+		}
+		keys = append(keys, *key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+
+	return keys, nil
+}
+
+// Delete removes a key pair.
+func (s *Store) Delete(name string) error {
+	if err := config.ValidateDeployKeyName(name); err != nil {
+		return err
+	}
+
+	if !s.Exists(name) {
+		return fmt.Errorf("deploy key %q not found", name)
+	}
+
+	if err := os.Remove(s.PrivateKeyPath(name)); err != nil {
+		return fmt.Errorf("failed to remove private key: %w", err)
+	}
+	if err := os.Remove(s.publicKeyPath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove public key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package deploykey
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStoreLifecycle(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	key, err := store.Generate("widget")
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if !strings.HasPrefix(key.PublicKey, "ssh-ed25519 ") {
+		t.Errorf("PublicKey = %q, want ssh-ed25519 key", key.PublicKey)
+	}
+	if !strings.HasPrefix(key.Fingerprint, "SHA256:") {
+		t.Errorf("Fingerprint = %q, want SHA256 fingerprint", key.Fingerprint)
+	}
+
+	info, err := os.Stat(store.PrivateKeyPath("widget"))
+	if err != nil {
+		t.Fatalf("private key not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("private key mode = %o, want 600", perm)
+	}
+
+	if _, err := store.Generate("widget"); err == nil {
+		t.Error("Generate() should fail for an existing key")
+	}
+	if _, err := store.Generate("Bad_Name"); err == nil {
+		t.Error("Generate() should fail for an invalid name")
+	}
+
+	keys, err := store.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Name != "widget" {
+		t.Errorf("List() = %v, want [widget]", keys)
+	}
+
+	if err := store.Delete("widget"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if store.Exists("widget") {
+		t.Error("Exists() = true after Delete()")
+	}
+	if err := store.Delete("widget"); err == nil {
+		t.Error("Delete() should fail for a missing key")
+	}
+}
+
+func TestStoreListMissingDir(t *testing.T) {
+	store := NewStore(t.TempDir() + "/missing")
+
+	keys, err := store.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("len(keys) = %d, want 0", len(keys))
+	}
+}
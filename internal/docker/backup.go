@@ -0,0 +1,162 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// BackupBranchPrefix is prepended to the timestamp of safety push branches.
+const BackupBranchPrefix = "shed-backup/"
+
+// backupPushScript commits everything in a repository, including untracked
+// files, on top of HEAD using a scratch index so the working tree, index, and
+// current branch are left untouched, then pushes the commit to a new branch.
+const backupPushScript = `set -e
+cd "$SHED_REPO_DIR"
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+export GIT_INDEX_FILE="$tmp/index"
+if parent=$(git rev-parse --verify --quiet HEAD); then
+	git read-tree HEAD
+	git add -A
+	commit=$(git commit-tree "$(git write-tree)" -p "$parent" -m "$SHED_MESSAGE")
+else
+	git add -A
+	commit=$(git commit-tree "$(git write-tree)" -m "$SHED_MESSAGE")
+fi
+git push --quiet "$SHED_REMOTE" "$commit:refs/heads/$SHED_BRANCH"
+`
+
+// safetyPushEnabled reports whether a shed's work should be backed up before
+// it is deleted. A per-shed label overrides the server policy.
+func (c *Client) safetyPushEnabled(labels map[string]string) bool {
+	if v, ok := labels[config.LabelSafetyPush]; ok {
+		return v == "true"
+	}
+	return c.config.SafetyPush.Enabled
+}
+
+// backupWorkspace saves uncommitted and unpushed work in a shed before it is
+// deleted. Each repository with changes is pushed to a backup branch; if any
+// push fails, or git cannot be run, the whole workspace is archived on the
+// server instead. A stopped shed is started so its repositories can be
+// inspected. An error means nothing was saved and the delete should not
+// proceed.
+func (c *Client) backupWorkspace(ctx context.Context, name string, ctr container.InspectResponse) error {
+	timestamp := time.Now().UTC().Format("20060102-150405")
+
+	if ctr.State == nil || !ctr.State.Running {
+		if err := c.docker.ContainerStart(ctx, ctr.ID, container.StartOptions{}); err != nil {
+			slog.Warn("Safety push: failed to start shed, archiving workspace", "shed", name, "err", err)
+			return c.archiveWorkspace(ctx, name, ctr.ID, timestamp)
+		}
+	}
+
+	if err := c.pushBackups(ctx, name, ctr.ID, timestamp); err != nil {
+		slog.Warn("Safety push failed, archiving workspace", "shed", name, "err", err)
+		return c.archiveWorkspace(ctx, name, ctr.ID, timestamp)
+	}
+
+	return nil
+}
+
+// pushBackups pushes a backup branch for every repository in the workspace
+// that has uncommitted or unpushed changes.
+func (c *Client) pushBackups(ctx context.Context, name, containerID, timestamp string) error {
+	repos, err := c.findRepos(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	branch := BackupBranchPrefix + timestamp
+	remote := c.config.SafetyPush.Remote
+	var failed []string
+
+	for _, dir := range repos {
+		status, err := c.repoStatus(ctx, containerID, dir, false)
+		if err != nil {
+			failed = append(failed, relativeWorkspacePath(dir))
+			continue
+		}
+		if status.Clean() {
+			continue
+		}
+
+		env := []string{
+			"SHED_REPO_DIR=" + dir,
+			"SHED_REMOTE=" + remote,
+			"SHED_BRANCH=" + branch,
+			"SHED_MESSAGE=shed: backup of " + name + " before delete",
+			"GIT_AUTHOR_NAME=shed",
+			"GIT_AUTHOR_EMAIL=shed@localhost",
+			"GIT_COMMITTER_NAME=shed",
+			"GIT_COMMITTER_EMAIL=shed@localhost",
+		}
+
+		result, err := c.execOutput(ctx, containerID, []string{"/bin/sh", "-c", backupPushScript}, env)
+		if err != nil || result.ExitCode != 0 {
+			detail := ""
+			if err != nil {
+				detail = err.Error()
+			} else {
+				detail = strings.TrimSpace(result.Stderr)
+			}
+			slog.Warn("Safety push: push failed", "shed", name, "repo", status.Path, "remote", remote, "detail", detail)
+			failed = append(failed, status.Path)
+			continue
+		}
+
+		slog.Info("Safety push: pushed changes", "shed", name, "repo", status.Path,
+			"files", len(status.Changes), "remote", remote, "branch", branch)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("shed %s: could not back up %s", name, strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// archiveWorkspace copies the shed's workspace out of the container into a
+// tarball in the server's backup directory.
+func (c *Client) archiveWorkspace(ctx context.Context, name, containerID, timestamp string) error {
+	backupDir := c.config.SafetyPush.BackupDir
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	reader, _, err := c.docker.CopyFromContainer(ctx, containerID, config.WorkspacePath)
+	if err != nil {
+		return fmt.Errorf("failed to read workspace: %w", err)
+	}
+	defer reader.Close()
+
+	archivePath := filepath.Join(backupDir, fmt.Sprintf("%s-%s.tar", name, timestamp))
+	f, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create workspace archive: %w", err)
+	}
+
+	if _, err := io.Copy(f, reader); err != nil {
+		f.Close()
+		os.Remove(archivePath)
+		return fmt.Errorf("failed to write workspace archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(archivePath)
+		return fmt.Errorf("failed to write workspace archive: %w", err)
+	}
+
+	slog.Info("Safety push: archived workspace", "shed", name, "path", archivePath)
+	return nil
+}
@@ -0,0 +1,348 @@
+package docker
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/s3"
+)
+
+// backupsBucket holds each shed's config.BackupStatus.
+const backupsBucket = "backups"
+
+// backupNameFormat names backups by when they were taken, in UTC.
+const backupNameFormat = "20060102-150405"
+
+// backupSuffix ends the key of every backup; backups are gzipped tar
+// archives of the workspace, as produced by ExportWorkspace.
+const backupSuffix = ".tar.gz"
+
+// errBackupsDisabled is returned by backup operations when the server has
+// no bucket configured.
+var errBackupsDisabled = errors.New("backups are not supported on this server: no backups bucket is configured")
+
+// backupStore returns the client for the configured backup bucket.
+func (c *Client) backupStore() (*s3.Client, error) {
+	if !c.config.Backups.Enabled() {
+		return nil, errBackupsDisabled
+	}
+	return s3.New(c.config.Backups.S3)
+}
+
+// backupKey returns the object key of a shed's backup.
+func backupKey(shed, name string) string {
+	return shed + "/" + name + backupSuffix
+}
+
+// BackupShed uploads an archive of a shed's workspace to the backup bucket,
+// then removes the shed's backups that retention no longer keeps. The shed
+// may be running or stopped.
+func (c *Client) BackupShed(ctx context.Context, name string) (*config.Backup, error) {
+	store, err := c.backupStore()
+	if err != nil {
+		return nil, err
+	}
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, busy := c.backingUp.LoadOrStore(name, true); busy {
+		return nil, fmt.Errorf("shed %q is already being backed up", name)
+	}
+	defer c.backingUp.Delete(name)
+
+	backup, err := c.uploadBackup(ctx, store, shed)
+	if err != nil {
+		c.recordBackupFailure(name, err)
+		return nil, fmt.Errorf("backup failed: %w", err)
+	}
+	if err := c.state.Put(backupsBucket, name, config.BackupStatus{Last: backup}); err != nil {
+		slog.Warn("Failed to record backup", "shed", name, "err", err)
+	}
+	slog.Info("Backed up shed", "shed", name, "backup", backup.Name, "size", backup.Size)
+
+	if err := c.pruneBackups(ctx, store, name); err != nil {
+		slog.Warn("Failed to remove old backups", "shed", name, "err", err)
+	}
+	return backup, nil
+}
+
+// uploadBackup archives a shed's workspace to a temporary file, since the
+// upload's size must be known up front and its parts may be sent more than
+// once, and uploads it.
+func (c *Client) uploadBackup(ctx context.Context, store *s3.Client, shed *config.Shed) (*config.Backup, error) {
+	now := time.Now().UTC()
+	backup := &config.Backup{
+		Shed:      shed.Name,
+		Name:      now.Format(backupNameFormat),
+		CreatedAt: now.Truncate(time.Second),
+	}
+
+	reader, _, err := c.docker.CopyFromContainer(ctx, shed.ContainerID, config.WorkspacePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	defer reader.Close()
+
+	f, err := os.CreateTemp("", "shed-backup-*"+backupSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if _, err := io.Copy(gz, reader); err != nil {
+		return nil, fmt.Errorf("failed to archive workspace: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to archive workspace: %w", err)
+	}
+
+	if backup.Size, err = f.Seek(0, io.SeekCurrent); err != nil {
+		return nil, fmt.Errorf("failed to read backup file: %w", err)
+	}
+	if err := store.Put(ctx, backupKey(shed.Name, backup.Name), f, backup.Size); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// recordBackupFailure notes why a shed's latest backup failed, keeping its
+// last successful one.
+func (c *Client) recordBackupFailure(name string, cause error) {
+	var status config.BackupStatus
+	if _, err := c.state.Get(backupsBucket, name, &status); err != nil {
+		slog.Warn("Failed to read backup status", "shed", name, "err", err)
+	}
+	now := time.Now().UTC()
+	status.Error = cause.Error()
+	status.FailedAt = &now
+	if err := c.state.Put(backupsBucket, name, status); err != nil {
+		slog.Warn("Failed to record backup failure", "shed", name, "err", err)
+	}
+}
+
+// backupStatus returns a shed's latest backup results, or nil if it has
+// none.
+func (c *Client) backupStatus(name string) *config.BackupStatus {
+	var status config.BackupStatus
+	ok, err := c.state.Get(backupsBucket, name, &status)
+	if err != nil {
+		slog.Warn("Failed to read backup status", "shed", name, "err", err)
+	}
+	if !ok {
+		return nil
+	}
+	return &status
+}
+
+// ListBackups returns the backups of a shed, newest first. Backups outlive
+// their sheds, so the shed need not exist.
+func (c *Client) ListBackups(ctx context.Context, name string) ([]config.Backup, error) {
+	if err := config.ValidateShedName(name); err != nil {
+		return nil, err
+	}
+	store, err := c.backupStore()
+	if err != nil {
+		return nil, err
+	}
+	objects, err := store.List(ctx, name+"/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	return backupsFromObjects(name, objects), nil
+}
+
+// RestoreBackup replaces a shed's workspace with one of its backups. A
+// running shed is stopped first, and the shed is started once the
+// workspace is restored.
+func (c *Client) RestoreBackup(ctx context.Context, name, backup string) (*config.Shed, error) {
+	if err := config.ValidateBackupName(backup); err != nil {
+		return nil, err
+	}
+	store, err := c.backupStore()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if shed.Status == config.StatusPaused {
+		return nil, fmt.Errorf("shed %q is paused, resume it first", name)
+	}
+
+	// Fetch and check the backup before touching the shed, so a missing or
+	// damaged one leaves the workspace as it was
+	archive, err := downloadBackup(ctx, store, name, backup)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	if shed.Status == config.StatusRunning {
+		if err := c.stopShed(ctx, shed); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.clearWorkspace(ctx, shed); err != nil {
+		return nil, err
+	}
+	if err := c.restoreWorkspace(ctx, shed.ContainerID, archive); err != nil {
+		return nil, err
+	}
+	slog.Info("Restored shed from backup", "shed", name, "backup", backup)
+
+	if err := c.startShed(ctx, shed); err != nil {
+		return nil, err
+	}
+	return c.GetShed(ctx, name)
+}
+
+// downloadBackup saves one of a shed's backups to a temporary file,
+// checked to be a workspace archive and rewound. The caller must close and
+// remove it.
+func downloadBackup(ctx context.Context, store *s3.Client, name, backup string) (*os.File, error) {
+	body, err := store.Get(ctx, backupKey(name, backup))
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, fmt.Errorf("backup %q not found for shed %q", backup, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	defer body.Close()
+
+	f, err := os.CreateTemp("", "shed-restore-*"+backupSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	if err := saveBackup(f, body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// saveBackup copies a downloaded backup to f, checks it, and rewinds f.
+func saveBackup(f *os.File, body io.Reader) error {
+	if _, err := io.Copy(f, body); err != nil {
+		return fmt.Errorf("failed to download backup: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	if err := workspaceEntries(f, io.Discard); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	return nil
+}
+
+// clearWorkspace removes everything in a stopped shed's workspace volume.
+func (c *Client) clearWorkspace(ctx context.Context, shed *config.Shed) error {
+	cmd := []string{"find", config.WorkspacePath, "-mindepth", "1", "-delete"}
+	mounts := []mount.Mount{{
+		Type:   mount.TypeVolume,
+		Source: config.VolumeName(shed.Name),
+		Target: config.WorkspacePath,
+	}}
+	if err := c.runHelper(ctx, shed.Image, cmd, mounts); err != nil {
+		return fmt.Errorf("failed to clear workspace: %w", err)
+	}
+	return nil
+}
+
+// backupsFromObjects describes a shed's backups from the objects under its
+// prefix, newest first, skipping objects that aren't backups.
+func backupsFromObjects(shed string, objects []s3.Object) []config.Backup {
+	backups := []config.Backup{}
+	for _, o := range objects {
+		name, ok := strings.CutPrefix(o.Key, shed+"/")
+		if !ok {
+			continue
+		}
+		name, ok = strings.CutSuffix(name, backupSuffix)
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		createdAt, err := time.Parse(backupNameFormat, name)
+		if err != nil {
+			createdAt = o.LastModified
+		}
+		backups = append(backups, config.Backup{Shed: shed, Name: name, Size: o.Size, CreatedAt: createdAt})
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups
+}
+
+// pruneBackups removes the backups of a shed that retention doesn't keep.
+func (c *Client) pruneBackups(ctx context.Context, store *s3.Client, name string) error {
+	objects, err := store.List(ctx, name+"/")
+	if err != nil {
+		return err
+	}
+	backups := backupsFromObjects(name, objects)
+	for _, b := range expiredBackups(backups, c.config.Backups.Keep, c.config.Backups.MaxAge, time.Now()) {
+		if err := store.Delete(ctx, backupKey(name, b.Name)); err != nil {
+			return err
+		}
+		slog.Info("Removed old backup", "shed", name, "backup", b.Name)
+	}
+	return nil
+}
+
+// expiredBackups returns the backups, sorted newest first, that are past
+// the newest keep or older than maxAge. The newest backup never expires.
+func expiredBackups(backups []config.Backup, keep int, maxAge time.Duration, now time.Time) []config.Backup {
+	if keep < 1 {
+		keep = config.DefaultBackupKeep
+	}
+	var expired []config.Backup
+	for i, b := range backups {
+		if i == 0 {
+			continue
+		}
+		if i >= keep || (maxAge > 0 && now.Sub(b.CreatedAt) > maxAge) {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
+
+// backupSheds backs up sheds one at a time, logging failures. Scheduled
+// backups run this in the background so they don't hold up other
+// schedules.
+func (c *Client) backupSheds(ctx context.Context, names []string) {
+	for _, name := range names {
+		if _, err := c.BackupShed(ctx, name); err != nil {
+			slog.Warn("Scheduled backup failed", "shed", name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package docker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/s3"
+)
+
+func TestBackupsFromObjects(t *testing.T) {
+	modified := time.Date(2026, 4, 15, 12, 0, 0, 0, time.UTC)
+	objects := []s3.Object{
+		{Key: "dev/20260414-030000.tar.gz", Size: 10},
+		{Key: "dev/20260415-030000.tar.gz", Size: 20},
+		{Key: "dev/manual.tar.gz", Size: 30, LastModified: modified},
+		{Key: "dev/notes.txt", Size: 1},
+		{Key: "dev/nested/20260415-030000.tar.gz", Size: 1},
+	}
+
+	got := backupsFromObjects("dev", objects)
+	want := []config.Backup{
+		{Shed: "dev", Name: "manual", Size: 30, CreatedAt: modified},
+		{Shed: "dev", Name: "20260415-030000", Size: 20, CreatedAt: time.Date(2026, 4, 15, 3, 0, 0, 0, time.UTC)},
+		{Shed: "dev", Name: "20260414-030000", Size: 10, CreatedAt: time.Date(2026, 4, 14, 3, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("backupsFromObjects() = %+v, want %+v", got, want)
+	}
+}
+
+func TestExpiredBackups(t *testing.T) {
+	now := time.Date(2026, 4, 15, 12, 0, 0, 0, time.UTC)
+	var backups []config.Backup
+	for i := range 5 {
+		backups = append(backups, config.Backup{Name: string(rune('a' + i)), CreatedAt: now.Add(-time.Duration(i) * 24 * time.Hour)})
+	}
+
+	names := func(bs []config.Backup) string {
+		s := ""
+		for _, b := range bs {
+			s += b.Name
+		}
+		return s
+	}
+
+	if got := names(expiredBackups(backups, 3, 0, now)); got != "de" {
+		t.Errorf("expiredBackups(keep 3) = %q, want de", got)
+	}
+	if got := names(expiredBackups(backups, 10, 36*time.Hour, now)); got != "cde" {
+		t.Errorf("expiredBackups(max age 36h) = %q, want cde", got)
+	}
+	// The newest backup is kept however old it is
+	if got := names(expiredBackups(backups[4:], 3, time.Hour, now)); got != "" {
+		t.Errorf("expiredBackups(only backup) = %q, want none", got)
+	}
+}
+
+func TestSaveBackup(t *testing.T) {
+	archive := buildArchive(t, "workspace/", "workspace/main.go")
+	f, err := os.Create(filepath.Join(t.TempDir(), "backup.tar.gz"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer f.Close()
+
+	if err := saveBackup(f, bytes.NewReader(archive)); err != nil {
+		t.Fatalf("saveBackup() error = %v", err)
+	}
+	// The file is rewound for restoring
+	got, _ := io.ReadAll(f)
+	if !bytes.Equal(got, archive) {
+		t.Errorf("saveBackup() left %d bytes to read, want %d", len(got), len(archive))
+	}
+
+	g, err := os.Create(filepath.Join(t.TempDir(), "bad.tar.gz"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer g.Close()
+
+	err = saveBackup(g, strings.NewReader("not an archive"))
+	var archiveErr *workspaceArchiveError
+	if !errors.As(err, &archiveErr) {
+		t.Errorf("saveBackup(garbage) error = %v, want an invalid archive", err)
+	}
+}
@@ -0,0 +1,86 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/build"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// BuildImage builds an image from a tar build context, which may be
+// compressed, passing each line of build output to output. The image is
+// labelled so it is listed by ListImages.
+func (c *Client) BuildImage(ctx context.Context, req config.BuildImageRequest, buildContext io.Reader, output func(string)) (*config.Image, error) {
+	opts := build.ImageBuildOptions{
+		Tags:        []string{req.Tag},
+		Dockerfile:  req.Dockerfile,
+		Remove:      true,
+		ForceRemove: true,
+		PullParent:  req.Pull,
+	}
+	if label := c.config.Images.Label; label != "" {
+		opts.Labels = map[string]string{label: "true"}
+	}
+
+	resp, err := c.docker.ImageBuild(ctx, buildContext, opts)
+	if err != nil {
+		return nil, fmt.Errorf("image build failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := readBuildOutput(resp.Body, output); err != nil {
+		return nil, err
+	}
+
+	inspect, err := c.docker.ImageInspect(ctx, req.Tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect built image: %w", err)
+	}
+	created, _ := time.Parse(time.RFC3339Nano, inspect.Created)
+	return &config.Image{
+		Name:      req.Tag,
+		ID:        inspect.ID,
+		Size:      inspect.Size,
+		CreatedAt: created,
+		Default:   req.Tag == c.config.DefaultImage,
+	}, nil
+}
+
+// buildMessage is one line of Docker's image build output stream.
+type buildMessage struct {
+	Stream string `json:"stream"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// readBuildOutput drains a build's output stream, passing its text to
+// output. Build failures are only reported in the stream.
+func readBuildOutput(r io.Reader, output func(string)) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var msg buildMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read build output: %w", err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("image build failed: %s", msg.Error)
+		}
+
+		text := msg.Stream
+		if text == "" && msg.Status != "" {
+			text = msg.Status + "\n"
+		}
+		if text != "" && output != nil {
+			output(text)
+		}
+	}
+}
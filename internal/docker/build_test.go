@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadBuildOutput(t *testing.T) {
+	stream := `{"stream":"Step 1/2 : FROM shed-base:latest\n"}
+{"status":"Pulling from library/shed-base"}
+{"stream":" ---> 4f1c\n"}
+{"aux":{"ID":"sha256:4f1c"}}
+{"stream":"Successfully tagged shed-go:latest\n"}
+`
+	var out strings.Builder
+	if err := readBuildOutput(strings.NewReader(stream), func(s string) { out.WriteString(s) }); err != nil {
+		t.Fatalf("readBuildOutput() error = %v", err)
+	}
+	want := "Step 1/2 : FROM shed-base:latest\nPulling from library/shed-base\n ---> 4f1c\nSuccessfully tagged shed-go:latest\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	failed := `{"stream":"Step 2/2 : RUN false\n"}
+{"errorDetail":{"code":1,"message":"exit code 1"},"error":"The command '/bin/sh -c false' returned a non-zero code: 1"}
+`
+	err := readBuildOutput(strings.NewReader(failed), nil)
+	if err == nil || !strings.Contains(err.Error(), "returned a non-zero code") {
+		t.Errorf("readBuildOutput() error = %v, want the build failure", err)
+	}
+}
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/system"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// Capabilities reports the optional features supported by the server's runtime.
+func (c *Client) Capabilities(ctx context.Context) config.ServerCapabilities {
+	info, err := c.docker.Info(ctx)
+	if err != nil {
+		return config.ServerCapabilities{}
+	}
+	return capabilitiesFromInfo(c.runtime, info)
+}
+
+// capabilitiesFromInfo derives the optional features from the runtime's info.
+func capabilitiesFromInfo(runtime string, info system.Info) config.ServerCapabilities {
+	_, gpu := info.Runtimes["nvidia"]
+	return config.ServerCapabilities{
+		Checkpoint: checkpointSupportedByInfo(runtime, info),
+		GPU:        gpu,
+	}
+}
+
+// checkpointSupportedByInfo reports whether containers can be checkpointed,
+// which needs an experimental Docker daemon: only then does it serve its
+// checkpoint endpoints. Whether CRIU is installed on the engine's host, which
+// may not be this one, can't be told from its info, so a daemon without it
+// is found out by the first checkpoint. Podman's Docker-compatible API has
+// no checkpoint endpoints.
+func checkpointSupportedByInfo(runtime string, info system.Info) bool {
+	return runtime != config.RuntimePodman && info.ExperimentalBuild
+}
+
+// GetCapacity reports the host's resources, the sheds on it, and the
+// features it supports. Free memory and disk are best effort and left zero
+// if they cannot be determined, as for a remote engine.
+func (c *Client) GetCapacity(ctx context.Context) (*config.ServerCapacity, error) {
+	info, err := c.docker.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get docker info: %w", err)
+	}
+
+	sheds, err := c.ListSheds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, shed := range sheds {
+		counts[shed.Status]++
+	}
+
+	capacity := &config.ServerCapacity{
+		Architecture:  normalizeArch(info.Architecture),
+		OS:            info.OperatingSystem,
+		Runtime:       c.runtime,
+		DockerVersion: info.ServerVersion,
+		CPUs:          info.NCPU,
+		MemoryTotal:   uint64(info.MemTotal),
+		DockerRootDir: info.DockerRootDir,
+		Sheds:         counts,
+		TotalSheds:    len(sheds),
+		Features:      capabilitiesFromInfo(c.runtime, info),
+	}
+
+	// Free memory and disk are measured on this host, so they're unknown
+	// for an engine on another one
+	if !c.config.RemoteEngine() {
+		capacity.MemoryAvailable = memAvailable()
+		if info.DockerRootDir != "" {
+			capacity.DiskTotal, capacity.DiskFree = diskUsage(info.DockerRootDir)
+		}
+	}
+
+	return capacity, nil
+}
+
+// memAvailable returns the host's available memory in bytes from
+// /proc/meminfo, or 0 if it cannot be read.
+func memAvailable() uint64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	return parseMemAvailable(bufio.NewScanner(f))
+}
+
+// parseMemAvailable extracts MemAvailable from /proc/meminfo output.
+func parseMemAvailable(scanner *bufio.Scanner) uint64 {
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package docker
+
+// diskUsage is not supported on this platform.
+func diskUsage(path string) (total, free uint64) {
+	return 0, 0
+}
@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestParseMemAvailable(t *testing.T) {
+	meminfo := `MemTotal:       16318060 kB
+MemFree:          812344 kB
+MemAvailable:    9034488 kB
+Buffers:          402148 kB
+`
+	if got, want := parseMemAvailable(bufio.NewScanner(strings.NewReader(meminfo))), uint64(9034488*1024); got != want {
+		t.Errorf("parseMemAvailable() = %d, want %d", got, want)
+	}
+
+	if got := parseMemAvailable(bufio.NewScanner(strings.NewReader("MemTotal: 1 kB\n"))); got != 0 {
+		t.Errorf("parseMemAvailable() without MemAvailable = %d, want 0", got)
+	}
+}
+
+func TestGetCapacityArchitecture(t *testing.T) {
+	// The engine is on another host, so its architecture is what matters,
+	// not the server's
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/info"):
+			_ = json.NewEncoder(w).Encode(system.Info{Architecture: "s390x", NCPU: 4})
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			_, _ = w.Write([]byte("[]"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	docker, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatalf("NewClientWithOpts() error = %v", err)
+	}
+	defer docker.Close()
+
+	c := &Client{docker: docker, config: &config.ServerConfig{DockerHost: "tcp://" + srv.Listener.Addr().String()}}
+	capacity, err := c.GetCapacity(context.Background())
+	if err != nil {
+		t.Fatalf("GetCapacity() error = %v", err)
+	}
+	if capacity.Architecture != "s390x" || capacity.CPUs != 4 {
+		t.Errorf("GetCapacity() = %s with %d CPUs, want s390x with 4", capacity.Architecture, capacity.CPUs)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package docker
+
+import "syscall"
+
+// diskUsage returns the total and free bytes of the filesystem holding path,
+// or zeros if it cannot be determined.
+func diskUsage(path string) (total, free uint64) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0
+	}
+	return uint64(st.Blocks) * uint64(st.Bsize), uint64(st.Bavail) * uint64(st.Bsize)
+}
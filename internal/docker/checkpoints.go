@@ -0,0 +1,177 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/checkpoint"
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// errCheckpointUnsupported is returned when the Docker daemon cannot checkpoint containers.
+var errCheckpointUnsupported = fmt.Errorf("checkpoint is not supported on this server: docker must run with experimental features enabled and CRIU installed")
+
+// errCheckpointPodman is returned when sheds run on Podman, whose
+// Docker-compatible API can't checkpoint containers.
+var errCheckpointPodman = fmt.Errorf("checkpoint is not supported on this server: podman's Docker-compatible API has no checkpoint support")
+
+// checkpointSupported reports whether containers can be checkpointed.
+func (c *Client) checkpointSupported(ctx context.Context) bool {
+	info, err := c.docker.Info(ctx)
+	if err != nil {
+		return false
+	}
+	return checkpointSupportedByInfo(c.runtime, info)
+}
+
+// criuMissing reports whether the engine failed to checkpoint because it
+// couldn't find CRIU, which its info doesn't reveal beforehand.
+func criuMissing(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "criu") && strings.Contains(msg, "executable file not found")
+}
+
+// CreateCheckpoint saves the process state of a running shed. The shed is
+// stopped afterwards unless leaveRunning is set. If name is empty a
+// timestamped name is generated.
+func (c *Client) CreateCheckpoint(ctx context.Context, shedName, name string, leaveRunning bool) (*config.Checkpoint, error) {
+	if c.runtime == config.RuntimePodman {
+		return nil, errCheckpointPodman
+	}
+	if !c.checkpointSupported(ctx) {
+		return nil, errCheckpointUnsupported
+	}
+
+	shed, err := c.GetShed(ctx, shedName)
+	if err != nil {
+		return nil, err
+	}
+
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", shedName)
+	}
+
+	if name == "" {
+		name = time.Now().UTC().Format("cp-20060102-150405")
+	}
+
+	if err := c.docker.CheckpointCreate(ctx, shed.ContainerID, checkpoint.CreateOptions{
+		CheckpointID: name,
+		Exit:         !leaveRunning,
+	}); err != nil {
+		if criuMissing(err) {
+			return nil, fmt.Errorf("checkpoint is not supported on this server: CRIU is not installed on the engine's host: %w", err)
+		}
+		return nil, fmt.Errorf("failed to checkpoint shed: %w", err)
+	}
+	if !leaveRunning && len(shed.Services) > 0 {
+		if err := c.stopServices(ctx, shedName); err != nil {
+			slog.Warn("Failed to stop services", "shed", shedName, "err", err)
+		}
+	}
+
+	return &config.Checkpoint{Name: name}, nil
+}
+
+// ListCheckpoints returns the checkpoints saved for a shed.
+func (c *Client) ListCheckpoints(ctx context.Context, shedName string) ([]config.Checkpoint, error) {
+	shed, err := c.GetShed(ctx, shedName)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := c.docker.CheckpointList(ctx, shed.ContainerID, checkpoint.ListOptions{})
+	if err != nil {
+		// Daemons without checkpoint support have no checkpoints to list
+		if !c.checkpointSupported(ctx) {
+			return []config.Checkpoint{}, nil
+		}
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	checkpoints := make([]config.Checkpoint, 0, len(summaries))
+	for _, s := range summaries {
+		checkpoints = append(checkpoints, config.Checkpoint{Name: s.Name})
+	}
+
+	return checkpoints, nil
+}
+
+// DeleteCheckpoint removes a saved checkpoint from a shed.
+func (c *Client) DeleteCheckpoint(ctx context.Context, shedName, name string) error {
+	shed, err := c.findCheckpoint(ctx, shedName, name)
+	if err != nil {
+		return err
+	}
+
+	if err := c.docker.CheckpointDelete(ctx, shed.ContainerID, checkpoint.DeleteOptions{
+		CheckpointID: name,
+	}); err != nil {
+		return fmt.Errorf("failed to delete checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreShed starts a stopped shed from a checkpoint, restoring the
+// processes that were running when it was taken.
+func (c *Client) RestoreShed(ctx context.Context, shedName, name string) (*config.Shed, error) {
+	unlock, err := c.lockShed(ctx, shedName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if !c.checkpointSupported(ctx) {
+		return nil, errCheckpointUnsupported
+	}
+
+	shed, err := c.findCheckpoint(ctx, shedName, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if shed.Status == config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is already running", shedName)
+	}
+
+	if err := c.docker.ContainerStart(ctx, shed.ContainerID, container.StartOptions{
+		CheckpointID: name,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to restore shed from checkpoint: %w", err)
+	}
+	c.stopReasons.clear(shedName)
+	if len(shed.Services) > 0 {
+		if err := c.startServices(ctx, shedName); err != nil {
+			slog.Warn("Failed to start services", "shed", shedName, "err", err)
+		}
+	}
+
+	return c.GetShed(ctx, shedName)
+}
+
+// findCheckpoint returns the shed if it has a checkpoint with the given name.
+func (c *Client) findCheckpoint(ctx context.Context, shedName, name string) (*config.Shed, error) {
+	shed, err := c.GetShed(ctx, shedName)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := c.ListCheckpoints(ctx, shedName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cp := range checkpoints {
+		if cp.Name == name {
+			return shed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("checkpoint %q not found for shed %q", name, shedName)
+}
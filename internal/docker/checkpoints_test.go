@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/checkpoint"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// checkpointEngine serves an experimental Docker daemon running one shed,
+// dev, that it checkpoints and restores. With noCRIU set, checkpointing
+// fails as it does on a host without CRIU.
+type checkpointEngine struct {
+	mu          sync.Mutex
+	running     bool
+	checkpoints []string
+	starts      []string
+	noCRIU      bool
+}
+
+func (e *checkpointEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/info"):
+		_ = json.NewEncoder(w).Encode(system.Info{ExperimentalBuild: true})
+	case strings.HasSuffix(r.URL.Path, "/containers/json"):
+		// The shed has no sidecars
+		_, _ = w.Write([]byte("[]"))
+	case strings.HasSuffix(r.URL.Path, "/containers/shed-dev/json"):
+		_ = json.NewEncoder(w).Encode(container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{ID: "c1", State: &container.State{Running: e.running}},
+			Config:            &container.Config{Labels: map[string]string{config.LabelShed: "true", config.LabelShedName: "dev"}},
+		})
+	case strings.HasSuffix(r.URL.Path, "/containers/c1/checkpoints") && r.Method == http.MethodPost:
+		if e.noCRIU {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"message":"failed to checkpoint: exec: \"criu\": executable file not found in $PATH"}`))
+			return
+		}
+		var opts checkpoint.CreateOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		e.checkpoints = append(e.checkpoints, opts.CheckpointID)
+		e.running = !opts.Exit
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasSuffix(r.URL.Path, "/containers/c1/checkpoints"):
+		summaries := []checkpoint.Summary{}
+		for _, name := range e.checkpoints {
+			summaries = append(summaries, checkpoint.Summary{Name: name})
+		}
+		_ = json.NewEncoder(w).Encode(summaries)
+	case strings.HasSuffix(r.URL.Path, "/containers/c1/start"):
+		e.starts = append(e.starts, r.URL.Query().Get("checkpoint"))
+		e.running = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newEngineClient returns a client with its own state directory for a fake
+// engine.
+func newEngineClient(t *testing.T, engine http.Handler) *Client {
+	t.Helper()
+	srv := httptest.NewServer(engine)
+	t.Cleanup(srv.Close)
+	docker, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatalf("NewClientWithOpts() error = %v", err)
+	}
+	t.Cleanup(func() { docker.Close() })
+
+	dir := t.TempDir()
+	return newClient(docker, config.RuntimeDocker, &config.ServerConfig{StateDir: dir}, openState(t, dir))
+}
+
+func TestCheckpointAndRestore(t *testing.T) {
+	engine := &checkpointEngine{running: true}
+	c := newEngineClient(t, engine)
+	ctx := context.Background()
+
+	if !c.Capabilities(ctx).Checkpoint {
+		t.Error("Capabilities().Checkpoint = false on an experimental daemon")
+	}
+
+	cp, err := c.CreateCheckpoint(ctx, "dev", "before", false)
+	if err != nil {
+		t.Fatalf("CreateCheckpoint() error = %v", err)
+	}
+	if cp.Name != "before" || engine.running {
+		t.Errorf("CreateCheckpoint() = %q, running = %v; want before and the shed stopped", cp.Name, engine.running)
+	}
+
+	checkpoints, err := c.ListCheckpoints(ctx, "dev")
+	if err != nil {
+		t.Fatalf("ListCheckpoints() error = %v", err)
+	}
+	if len(checkpoints) != 1 || checkpoints[0].Name != "before" {
+		t.Errorf("ListCheckpoints() = %+v, want before", checkpoints)
+	}
+
+	shed, err := c.RestoreShed(ctx, "dev", "before")
+	if err != nil {
+		t.Fatalf("RestoreShed() error = %v", err)
+	}
+	if shed.Status != config.StatusRunning || !slices.Equal(engine.starts, []string{"before"}) {
+		t.Errorf("RestoreShed() status = %s, starts = %q; want running from before", shed.Status, engine.starts)
+	}
+
+	if _, err := c.RestoreShed(ctx, "dev", "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("RestoreShed(missing) error = %v, want not found", err)
+	}
+}
+
+func TestCheckpointWithoutCRIU(t *testing.T) {
+	c := newEngineClient(t, &checkpointEngine{running: true, noCRIU: true})
+
+	_, err := c.CreateCheckpoint(context.Background(), "dev", "before", true)
+	if err == nil || !strings.Contains(err.Error(), "not supported on this server") {
+		t.Errorf("CreateCheckpoint() error = %v, want not supported", err)
+	}
+}
@@ -3,29 +3,80 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"regexp"
+	"sync"
+	"sync/atomic"
 
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 
 	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/secrets"
+	"github.com/charliek/shed/internal/state"
 )
 
+// ErrShedNotFound is wrapped by the errors returned for sheds that don't
+// exist.
+var ErrShedNotFound = errors.New("not found")
+
+// shedNotFound returns the error for a shed that doesn't exist, e.g.
+// `shed "dev" not found`.
+func shedNotFound(name string) error {
+	return fmt.Errorf("shed %q %w", name, ErrShedNotFound)
+}
+
 // envVarNameRegex validates environment variable names.
 // Must start with a letter or underscore, followed by letters, digits, or underscores.
 var envVarNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
 // Client wraps the Docker client with shed-specific configuration.
 type Client struct {
-	docker *client.Client
-	config *config.ServerConfig
+	docker      *client.Client
+	config      *config.ServerConfig
+	state       *state.Store
+	setupErrors *noteStore
+	stopReasons *noteStore
+	expired     *noteStore
+	labels      *mapStore
+	env         *mapStore
+	schedules   *mapStore
+	owners      *noteStore
+	activity    *noteStore
+	secrets     *secrets.Store
+
+	// multiplexers caches the multiplexer found in each container, by ID,
+	// until the shed is deleted or rebuilt.
+	multiplexers sync.Map
+
+	// backingUp holds the names of sheds being backed up.
+	backingUp sync.Map
+
+	// arch caches the engine's architecture once engineArch finds it.
+	arch atomic.Value
+
+	// locks serializes operations on each shed, and idempotency remembers
+	// the sheds created with idempotency keys.
+	locks       shedLocks
+	idempotency idempotencyKeys
+
+	// runtime is the container engine actually serving the API, which may
+	// differ from the configured one when DOCKER_HOST points elsewhere.
+	runtime string
 }
 
-// NewClient creates a new Docker client wrapper with the given server configuration.
-func NewClient(cfg *config.ServerConfig) (*Client, error) {
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// Connect connects to the container engine the server configuration
+// names and checks that it answers. NewClient uses it; commands that only
+// need the engine, not the server's state, can use it directly.
+func Connect(cfg *config.ServerConfig) (*client.Client, error) {
+	runtime := cfg.Runtime
+	if runtime == "" {
+		runtime = config.RuntimeDocker
+	}
+
+	dockerClient, err := client.NewClientWithOpts(clientOpts(cfg, runtime)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
@@ -33,17 +84,66 @@ func NewClient(cfg *config.ServerConfig) (*Client, error) {
 	// Verify connection by pinging Docker
 	if _, err := dockerClient.Ping(context.Background()); err != nil {
 		dockerClient.Close()
-		return nil, fmt.Errorf("failed to connect to docker: %w", err)
+		return nil, fmt.Errorf("failed to connect to %s at %s: %w", runtime, dockerClient.DaemonHost(), err)
+	}
+	return dockerClient, nil
+}
+
+// NewClient creates a new Docker client wrapper with the given server configuration.
+func NewClient(cfg *config.ServerConfig) (*Client, error) {
+	runtime := cfg.Runtime
+	if runtime == "" {
+		runtime = config.RuntimeDocker
+	}
+
+	dockerClient, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// DOCKER_HOST can point at a different engine than configured; trust
+	// what answers so features are detected correctly
+	if version, err := dockerClient.ServerVersion(context.Background()); err == nil {
+		if actual := runtimeFromVersion(version); actual != runtime {
+			slog.Warn("Configured runtime does not match the API", "runtime", runtime, "actual", actual)
+			runtime = actual
+		}
+	}
+
+	db, err := state.Open(cfg.StateDir)
+	if err != nil {
+		dockerClient.Close()
+		return nil, err
 	}
 
+	return newClient(dockerClient, runtime, cfg, db), nil
+}
+
+// newClient wraps a connected engine client, loading the server's notes
+// from its state store.
+func newClient(dockerClient *client.Client, runtime string, cfg *config.ServerConfig, db *state.Store) *Client {
 	return &Client{
-		docker: dockerClient,
-		config: cfg,
-	}, nil
+		docker:      dockerClient,
+		runtime:     runtime,
+		config:      cfg,
+		state:       db,
+		setupErrors: loadNotes(db, cfg.StateDir, setupErrorsFile, "setup errors"),
+		stopReasons: loadNotes(db, cfg.StateDir, stopReasonsFile, "stop reasons"),
+		expired:     loadNotes(db, cfg.StateDir, expiredFile, "expired sheds"),
+		labels:      loadMaps(db, cfg.StateDir, labelsFile, "shed labels"),
+		env:         loadMaps(db, cfg.StateDir, envFile, "shed env"),
+		schedules:   loadMaps(db, cfg.StateDir, schedulesFile, "schedules"),
+		owners:      newNotes(db, ownersBucket, "shed owners"),
+		activity:    newNotes(db, activityBucket, "shed activity"),
+		secrets:     secrets.NewStore(cfg.SecretsDir, cfg.SecretsKeyFile),
+	}
 }
 
-// Close closes the Docker client connection.
+// Close closes the Docker client connection and the state store.
 func (c *Client) Close() error {
+	if err := c.state.Close(); err != nil {
+		slog.Warn("Failed to close state store", "err", err)
+	}
 	return c.docker.Close()
 }
 
@@ -52,6 +152,16 @@ func (c *Client) Docker() *client.Client {
 	return c.docker
 }
 
+// Runtime returns the container engine serving the API, docker or podman.
+func (c *Client) Runtime() string {
+	return c.runtime
+}
+
+// DockerHost returns the address of the engine that runs sheds.
+func (c *Client) DockerHost() string {
+	return c.docker.DaemonHost()
+}
+
 // Config returns the server configuration.
 func (c *Client) Config() *config.ServerConfig {
 	return c.config
@@ -81,16 +191,20 @@ func (c *Client) buildMounts(shedName string) []mount.Mount {
 	return mounts
 }
 
-// buildEnvList creates environment variable list for containers.
-// Invalid environment variable names are logged and skipped.
-func (c *Client) buildEnvList() []string {
-	envList := make([]string, 0, len(c.config.EnvVars))
+// buildEnvList creates environment variable list for containers, with a
+// shed's own variables overriding the server's. Invalid environment
+// variable names are logged and skipped.
+func (c *Client) buildEnvList(shedEnv map[string]string) []string {
+	envList := make([]string, 0, len(c.config.EnvVars)+len(shedEnv))
 	for key, value := range c.config.EnvVars {
 		if !envVarNameRegex.MatchString(key) {
-			log.Printf("Warning: skipping invalid environment variable name %q", key)
+			slog.Warn("Skipping invalid environment variable name", "name", key)
+			continue
+		}
+		if _, ok := shedEnv[key]; ok {
 			continue
 		}
 		envList = append(envList, fmt.Sprintf("%s=%s", key, value))
 	}
-	return envList
+	return append(envList, config.EnvList(shedEnv)...)
 }
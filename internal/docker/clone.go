@@ -0,0 +1,106 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// CloneShed creates dst as a copy of src, with the same image, settings,
+// and workspace contents. The source may be running or stopped.
+func (c *Client) CloneShed(ctx context.Context, src, dst string) (*config.Shed, error) {
+	unlock, err := c.lockShed(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := config.ValidateShedName(dst); err != nil {
+		return nil, err
+	}
+
+	ctr, err := c.docker.ContainerInspect(ctx, config.ContainerName(src))
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, shedNotFound(src)
+		}
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	labels := ctr.Config.Labels
+	if labels[config.LabelShed] != "true" {
+		return nil, shedNotFound(src)
+	}
+	// A copied worktree would still point at the source's branch in the
+	// shared clone
+	if labels[config.LabelRepoCache] != "" {
+		return nil, fmt.Errorf("cannot clone shed %q: worktree sheds share a clone and can't be copied", src)
+	}
+
+	req := cloneRequest(dst, ctr.Config.Image, labels, ctr.Config.Env)
+	req.Labels = c.shedLabels(src, labels)
+	req.Env = c.shedEnv(src, labels)
+	// The source's host ports are still taken
+	req.Ports = nil
+
+	// The allowlist may have changed since the source was created
+	for i, m := range req.Mounts {
+		allowed, err := c.config.Mounts.Allow(m)
+		if err != nil {
+			return nil, fmt.Errorf("cannot clone shed %q: %w", src, err)
+		}
+		req.Mounts[i] = allowed
+	}
+
+	reader, _, err := c.docker.CopyFromContainer(ctx, ctr.ID, config.WorkspacePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	defer reader.Close()
+
+	return c.restoreShed(ctx, req, reader)
+}
+
+// cloneRequest rebuilds the create request for a copy of a shed from the
+// source container's image, labels, and environment.
+func cloneRequest(name, image string, labels map[string]string, env []string) config.CreateShedRequest {
+	req := config.CreateShedRequest{
+		Name:        name,
+		Repo:        labels[config.LabelShedRepo],
+		Image:       image,
+		DeployKey:   labels[config.LabelDeployKey],
+		Platform:    labels[config.LabelPlatform],
+		Forwarding:  forwardingFromLabels(labels),
+		Mounts:      mountsFromLabels(labels),
+		Labels:      userLabels(labels),
+		Env:         envFromLabels(labels),
+		Secrets:     secretsFromLabels(labels),
+		Network:     labels[config.LabelNetwork],
+		Ports:       portsFromLabels(labels),
+		HTTPPort:    httpPortFromLabels(labels),
+		Services:    servicesFromLabels(labels),
+		HealthCheck: healthCheckFromLabels(labels),
+		PreStop:     preStopFromLabels(labels),
+		NoIdleStop:  labels[config.LabelIdleStop] == "false",
+		TTL:         labels[config.LabelTTL],
+		TTLAction:   labels[config.LabelTTLAction],
+	}
+	if v := labels[config.LabelSafetyPush]; v != "" {
+		push := v == "true"
+		req.SafetyPush = &push
+	}
+	if res := resourcesFromLabels(labels); res != nil {
+		req.Resources = *res
+	}
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "TZ="); ok {
+			req.Timezone = v
+		} else if v, ok := strings.CutPrefix(kv, "LANG="); ok {
+			req.Locale = v
+		}
+	}
+	return req
+}
@@ -0,0 +1,72 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestCloneRequest(t *testing.T) {
+	labels := map[string]string{
+		config.LabelShed:                   "true",
+		config.LabelShedName:               "src",
+		config.LabelShedRepo:               "git@github.com:user/repo.git",
+		config.LabelDeployKey:              "repo-key",
+		config.LabelPlatform:               "linux/arm64",
+		config.LabelSafetyPush:             "false",
+		config.LabelForwardAllow:           "3000",
+		config.LabelIdleStop:               "false",
+		config.LabelMemory:                 "4g",
+		config.LabelEnvPrefix + "NODE_ENV": "development",
+		config.LabelSecrets:                `[{"name":"npmrc"},{"name":"github-token","env":"GITHUB_TOKEN"}]`,
+		config.LabelNetwork:                config.NetworkShared,
+		config.LabelPorts:                  "8080:80,127.0.0.1:5432:5432",
+		config.LabelHTTPPort:               "3000",
+		config.LabelServices:               `[{"name":"db","image":"postgres:16","env":{"POSTGRES_PASSWORD":"dev"}}]`,
+		config.LabelHealthCheck:            `{"command":"test -f /ready","interval":"10s"}`,
+		config.LabelPreStop:                `{"command":"make stop"}`,
+		config.LabelTTL:                    "2h",
+		config.LabelTTLAction:              config.TTLStop,
+	}
+	env := []string{"PATH=/usr/bin", "TZ=Europe/Berlin", "LANG=de_DE.UTF-8"}
+
+	got := cloneRequest("dst", "shed-base:latest", labels, env)
+
+	push := false
+	want := config.CreateShedRequest{
+		Name:        "dst",
+		Repo:        "git@github.com:user/repo.git",
+		Image:       "shed-base:latest",
+		DeployKey:   "repo-key",
+		Platform:    "linux/arm64",
+		SafetyPush:  &push,
+		Timezone:    "Europe/Berlin",
+		Locale:      "de_DE.UTF-8",
+		Forwarding:  &config.ForwardingRules{Allow: []string{"3000"}},
+		NoIdleStop:  true,
+		Resources:   config.Resources{Memory: "4g"},
+		Env:         map[string]string{"NODE_ENV": "development"},
+		Secrets:     []config.SecretRef{{Name: "npmrc"}, {Name: "github-token", Env: "GITHUB_TOKEN"}},
+		Network:     config.NetworkShared,
+		Ports:       []string{"8080:80", "127.0.0.1:5432:5432"},
+		HTTPPort:    3000,
+		Services:    []config.Service{{Name: "db", Image: "postgres:16", Env: map[string]string{"POSTGRES_PASSWORD": "dev"}}},
+		HealthCheck: &config.HealthCheck{Command: "test -f /ready", Interval: "10s"},
+		PreStop:     &config.PreStop{Command: "make stop"},
+		TTL:         "2h",
+		TTLAction:   config.TTLStop,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cloneRequest() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCloneRequestDefaults(t *testing.T) {
+	got := cloneRequest("dst", "shed-base:latest", map[string]string{config.LabelShed: "true"}, nil)
+
+	want := config.CreateShedRequest{Name: "dst", Image: "shed-base:latest"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cloneRequest() = %+v, want %+v", got, want)
+	}
+}
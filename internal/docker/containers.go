@@ -2,11 +2,11 @@ package docker
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"net/url"
-	"regexp"
+	"log/slog"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,67 +14,71 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 
 	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/deploykey"
 )
 
-// gitSSHRegex matches git@host:path format (e.g., git@github.com:user/repo.git)
-var gitSSHRegex = regexp.MustCompile(`^git@[a-zA-Z0-9][a-zA-Z0-9.-]*:[a-zA-Z0-9_.-]+/[a-zA-Z0-9_.-]+(?:\.git)?$`)
-
-// ValidateGitRepoURL validates that a git repository URL is well-formed.
-// Accepts https://, git://, ssh://, and git@host:path formats.
-func ValidateGitRepoURL(repoURL string) error {
-	if repoURL == "" {
-		return nil // Empty is valid (no repo to clone)
+// CreateShed creates a new shed with a volume, container, and optionally clones a repository.
+// Each phase is reported to progress, which may be nil.
+// A create retried with the same IdempotencyKey returns the shed the first
+// one created.
+func (c *Client) CreateShed(ctx context.Context, req config.CreateShedRequest, progress config.ProgressFunc) (*config.Shed, error) {
+	unlock, err := c.lockShed(ctx, req.Name)
+	if err != nil {
+		return nil, err
 	}
-
-	// Check for git@host:path format first (SCP-like syntax)
-	if strings.HasPrefix(repoURL, "git@") {
-		if !gitSSHRegex.MatchString(repoURL) {
-			return fmt.Errorf("invalid git SSH URL format: %s", repoURL)
+	defer unlock()
+
+	if req.IdempotencyKey != "" {
+		if name, ok := c.idempotency.get(req.IdempotencyKey); ok {
+			if name != req.Name {
+				return nil, fmt.Errorf("idempotency key %q was already used to create shed %q", req.IdempotencyKey, name)
+			}
+			return c.GetShed(ctx, name)
 		}
-		return nil
 	}
 
-	// Parse as standard URL
-	parsed, err := url.Parse(repoURL)
+	shed, err := c.createShed(ctx, req, progress, createOptions{checkout: true})
 	if err != nil {
-		return fmt.Errorf("invalid repository URL: %w", err)
-	}
-
-	// Validate scheme
-	validSchemes := map[string]bool{
-		"https": true,
-		"http":  true,
-		"git":   true,
-		"ssh":   true,
-	}
-	if !validSchemes[parsed.Scheme] {
-		return fmt.Errorf("unsupported URL scheme %q: must be https, http, git, or ssh", parsed.Scheme)
+		return nil, err
 	}
-
-	// Validate host is present
-	if parsed.Host == "" {
-		return fmt.Errorf("repository URL must have a host")
+	if req.IdempotencyKey != "" {
+		c.idempotency.set(req.IdempotencyKey, shed.Name)
 	}
+	return shed, nil
+}
 
-	// Validate path is present (should have at least /user/repo or /repo)
-	if parsed.Path == "" || parsed.Path == "/" {
-		return fmt.Errorf("repository URL must have a path")
-	}
+// IdempotentShed returns the name of the shed a create with the
+// idempotency key made, if the key is remembered.
+func (c *Client) IdempotentShed(key string) (string, bool) {
+	return c.idempotency.get(key)
+}
 
-	return nil
+// createOptions controls how createShed sets up a shed's workspace.
+type createOptions struct {
+	// checkout clones the repository or adds the worktree. Sheds whose
+	// workspace is filled another way, such as from an archive, still
+	// record their repository.
+	checkout bool
+
+	// createdAt is the creation time of a shed whose container is being
+	// rebuilt, zero for a new shed. A rebuilt shed's volume already holds
+	// its workspace, so it is kept if creation fails.
+	createdAt time.Time
 }
 
-// CreateShed creates a new shed with a volume, container, and optionally clones a repository.
-func (c *Client) CreateShed(ctx context.Context, req config.CreateShedRequest) (*config.Shed, error) {
+// createShed creates a shed's volume and container and starts it.
+func (c *Client) createShed(ctx context.Context, req config.CreateShedRequest, progress config.ProgressFunc, opts createOptions) (*config.Shed, error) {
 	// Validate shed name
 	if err := config.ValidateShedName(req.Name); err != nil {
 		return nil, err
 	}
 
 	// Validate repository URL if provided
-	if err := ValidateGitRepoURL(req.Repo); err != nil {
+	if err := config.ValidateGitRepoURL(req.Repo); err != nil {
 		return nil, err
 	}
 
@@ -86,13 +90,50 @@ func (c *Client) CreateShed(ctx context.Context, req config.CreateShedRequest) (
 
 	containerName := config.ContainerName(req.Name)
 
+	// Check for an existing shed before creating anything, so a failed
+	// create can't remove a volume that belongs to it
+	rebuild := !opts.createdAt.IsZero()
+	if !rebuild {
+		if _, err := c.docker.ContainerInspect(ctx, containerName); err == nil {
+			return nil, fmt.Errorf("shed %q already exists", req.Name)
+		} else if !cerrdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to inspect container: %w", err)
+		}
+	}
+
+	// Pull the image first if needed so a failed pull leaves nothing behind
+	platform := c.shedPlatform(req)
+	if err := c.ensureImage(ctx, image, platform, progress); err != nil {
+		return nil, err
+	}
+	for _, svc := range req.Services {
+		if err := c.ensureImage(ctx, svc.Image, "", progress); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create the workspace volume
+	progress.Report(config.PhaseVolume, config.ProgressStarted, "")
 	if err := c.CreateVolume(ctx, req.Name); err != nil {
+		progress.Report(config.PhaseVolume, config.ProgressFailed, err.Error())
 		return nil, fmt.Errorf("failed to create volume: %w", err)
 	}
+	progress.Report(config.PhaseVolume, config.ProgressDone, config.VolumeName(req.Name))
+
+	// Only clean up a volume this call created. Cleanup runs even when ctx
+	// is canceled, as it is when the server shuts down mid-create
+	cleanupCtx := context.WithoutCancel(ctx)
+	deleteVolume := func() {
+		if !rebuild {
+			_ = c.DeleteVolume(cleanupCtx, req.Name)
+		}
+	}
 
 	// Build container configuration
 	createdAt := time.Now().UTC()
+	if rebuild {
+		createdAt = opts.createdAt.UTC()
+	}
 	labels := map[string]string{
 		config.LabelShed:        "true",
 		config.LabelShedName:    req.Name,
@@ -101,17 +142,115 @@ func (c *Client) CreateShed(ctx context.Context, req config.CreateShedRequest) (
 	if req.Repo != "" {
 		labels[config.LabelShedRepo] = req.Repo
 	}
+	if platform != "" {
+		labels[config.LabelPlatform] = platform
+	}
+	if req.SafetyPush != nil {
+		labels[config.LabelSafetyPush] = strconv.FormatBool(*req.SafetyPush)
+	}
+	if req.NoIdleStop {
+		labels[config.LabelIdleStop] = "false"
+	}
+	if req.TTL != "" {
+		labels[config.LabelTTL] = req.TTL
+		labels[config.LabelTTLAction] = req.TTLAction
+		if req.TTLAction == "" {
+			labels[config.LabelTTLAction] = config.TTLDelete
+		}
+	}
+	for k, v := range req.Labels {
+		labels[config.LabelUserPrefix+k] = v
+	}
+	for k, v := range req.Env {
+		labels[config.LabelEnvPrefix+k] = v
+	}
+	if req.CPUs > 0 {
+		labels[config.LabelCPUs] = strconv.FormatFloat(req.CPUs, 'f', -1, 64)
+	}
+	if req.Memory != "" {
+		labels[config.LabelMemory] = req.Memory
+	}
+	if req.PidsLimit > 0 {
+		labels[config.LabelPidsLimit] = strconv.FormatInt(req.PidsLimit, 10)
+	}
+	if req.Forwarding != nil {
+		if len(req.Forwarding.Allow) > 0 {
+			labels[config.LabelForwardAllow] = strings.Join(req.Forwarding.Allow, ",")
+		}
+		if len(req.Forwarding.Deny) > 0 {
+			labels[config.LabelForwardDeny] = strings.Join(req.Forwarding.Deny, ",")
+		}
+	}
+
+	mounts := c.buildMounts(req.Name)
+	env := c.buildEnvList(req.Env)
+
+	// Only the names of secrets are recorded; their values are written to
+	// memory once the container starts
+	if len(req.Secrets) > 0 {
+		data, _ := json.Marshal(req.Secrets)
+		labels[config.LabelSecrets] = string(data)
+	}
+
+	// Extra mounts were checked against the allowlist by the API
+	if len(req.Mounts) > 0 {
+		data, _ := json.Marshal(req.Mounts)
+		labels[config.LabelMounts] = string(data)
+		for _, m := range req.Mounts {
+			mounts = append(mounts, mount.Mount{
+				Type:     mount.Type(m.Type),
+				Source:   m.Source,
+				Target:   m.Target,
+				ReadOnly: m.ReadOnly,
+			})
+		}
+	}
+
+	// Per-shed timezone and locale override the server defaults
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = c.config.Timezone
+	}
+	locale := req.Locale
+	if locale == "" {
+		locale = c.config.Locale
+	}
+	env = append(env, localeEnv(timezone, locale)...)
+
+	// Mount the deploy key and point git at it for clone and fetch
+	if req.DeployKey != "" {
+		labels[config.LabelDeployKey] = req.DeployKey
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   deploykey.NewStore(c.config.DeployKeyDir).PrivateKeyPath(req.DeployKey),
+			Target:   config.DeployKeyPath,
+			ReadOnly: true,
+		})
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+config.DeployKeyPath+" -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new")
+	}
+
+	// Share one clone of the repo between worktree sheds
+	if req.Worktree {
+		if err := c.createRepoCacheVolume(ctx, req.Repo); err != nil {
+			deleteVolume()
+			return nil, err
+		}
+		labels[config.LabelRepoCache] = config.RepoCacheVolumeName(req.Repo)
+		mounts = append(mounts, repoCacheMount(req.Repo))
+	}
 
 	containerConfig := &container.Config{
 		Image:  image,
 		Cmd:    []string{"sleep", "infinity"},
 		Labels: labels,
-		Env:    c.buildEnvList(),
+		Env:    env,
 	}
 
 	hostConfig := &container.HostConfig{
-		Mounts:      c.buildMounts(req.Name),
+		Mounts:      mounts,
 		NetworkMode: "bridge",
+		// Lets the shed reach ports forwarded with ssh -R
+		ExtraHosts: []string{config.ForwardHostName + ":host-gateway"},
 		RestartPolicy: container.RestartPolicy{
 			Name: container.RestartPolicyUnlessStopped,
 		},
@@ -120,45 +259,233 @@ func (c *Client) CreateShed(ctx context.Context, req config.CreateShedRequest) (
 		CapDrop: []string{"ALL"},
 		CapAdd:  []string{"CHOWN", "SETUID", "SETGID", "DAC_OVERRIDE", "FOWNER"},
 	}
+	if len(req.Secrets) > 0 {
+		hostConfig.Tmpfs = map[string]string{config.SecretsPath: secretsTmpfsOptions}
+	}
+
+	if len(req.Ports) > 0 {
+		exposed, bindings, err := portBindings(req.Ports, c.config.DefaultPublishAddress())
+		if err != nil {
+			deleteVolume()
+			return nil, err
+		}
+		labels[config.LabelPorts] = strings.Join(req.Ports, ",")
+		containerConfig.ExposedPorts = exposed
+		hostConfig.PortBindings = bindings
+	}
+
+	if req.HTTPPort > 0 {
+		labels[config.LabelHTTPPort] = strconv.Itoa(req.HTTPPort)
+	}
+
+	if len(req.Services) > 0 {
+		data, _ := json.Marshal(req.Services)
+		labels[config.LabelServices] = string(data)
+	}
+
+	if req.HealthCheck != nil {
+		healthcheck, err := healthConfig(*req.HealthCheck)
+		if err != nil {
+			deleteVolume()
+			return nil, err
+		}
+		containerConfig.Healthcheck = healthcheck
+		data, _ := json.Marshal(req.HealthCheck)
+		labels[config.LabelHealthCheck] = string(data)
+	}
+
+	if req.PreStop != nil {
+		data, _ := json.Marshal(req.PreStop)
+		labels[config.LabelPreStop] = string(data)
+	}
+
+	var networkingConfig *network.NetworkingConfig
+	switch req.Network {
+	case config.NetworkShared:
+		if err := c.ensureSharedNetwork(ctx); err != nil {
+			deleteVolume()
+			return nil, err
+		}
+		labels[config.LabelNetwork] = req.Network
+		hostConfig.NetworkMode = container.NetworkMode(config.SharedNetworkName)
+		networkingConfig = sharedEndpoint(req.Name)
+	case config.NetworkDedicated:
+		// The network outlives a failed create; deleting or pruning the
+		// shed removes it
+		if err := c.ensureShedNetwork(ctx, req.Name); err != nil {
+			deleteVolume()
+			return nil, err
+		}
+		labels[config.LabelNetwork] = req.Network
+		hostConfig.NetworkMode = container.NetworkMode(config.ShedNetworkName(req.Name))
+		networkingConfig = dedicatedEndpoint(req.Name)
+		// Sidecars share the shed's hosts file, so they resolve the name too
+		containerConfig.Hostname = req.Name
+	}
+	if err := applyResources(&hostConfig.Resources, req.Resources); err != nil {
+		deleteVolume()
+		return nil, err
+	}
 
 	// Create the container
-	resp, err := c.docker.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
+	progress.Report(config.PhaseContainer, config.ProgressStarted, "")
+	resp, err := c.docker.ContainerCreate(ctx, containerConfig, hostConfig, networkingConfig, platformSpec(platform), containerName)
 	if err != nil {
+		progress.Report(config.PhaseContainer, config.ProgressFailed, err.Error())
 		// Clean up volume on failure
-		_ = c.DeleteVolume(ctx, req.Name)
+		deleteVolume()
 		return nil, fmt.Errorf("failed to create container: %w", err)
 	}
+	progress.Report(config.PhaseContainer, config.ProgressDone, containerName)
 
 	// Start the container
+	progress.Report(config.PhaseStart, config.ProgressStarted, "")
 	if err := c.docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		progress.Report(config.PhaseStart, config.ProgressFailed, err.Error())
 		// Clean up on failure
-		_ = c.docker.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
-		_ = c.DeleteVolume(ctx, req.Name)
+		_ = c.docker.ContainerRemove(cleanupCtx, resp.ID, container.RemoveOptions{Force: true})
+		deleteVolume()
 		return nil, fmt.Errorf("failed to start container: %w", err)
 	}
+	progress.Report(config.PhaseStart, config.ProgressDone, "")
 
-	// Clone repository if specified
-	if req.Repo != "" {
-		if err := c.cloneRepo(ctx, resp.ID, req.Repo); err != nil {
+	if err := c.writeSecretFiles(ctx, resp.ID, req.Secrets); err != nil {
+		// Log warning but don't fail - secrets are written again on start
+		slog.Warn("Failed to write secrets", "shed", req.Name, "err", err)
+	}
+
+	if err := c.provisionLocale(ctx, resp.ID, timezone, locale); err != nil {
+		// Log warning but don't fail - TZ and LANG are still set
+		slog.Warn("Failed to set up timezone and locale", "shed", req.Name, "err", err)
+	}
+
+	// Forget any failure left by an earlier shed with this name; a rebuilt
+	// shed's workspace is unchanged, so its failure still applies
+	if !rebuild {
+		c.forget(req.Name)
+		if req.Owner != "" {
+			c.owners.set(req.Name, req.Owner)
+		}
+	}
+
+	// Sidecars join the shed's network, so it must be running first
+	var setupErrs []string
+	if len(req.Services) > 0 {
+		progress.Report(config.PhaseServices, config.ProgressStarted, "")
+		if err := c.createServices(ctx, req.Name, resp.ID, req.Services, req.Resources); err != nil {
+			// Log warning but don't fail - the shed itself is usable
+			slog.Warn("Failed to start services", "shed", req.Name, "err", err)
+			progress.Report(config.PhaseServices, config.ProgressFailed, err.Error())
+			setupErrs = append(setupErrs, fmt.Sprintf("failed to start services: %v", err))
+		} else {
+			progress.Report(config.PhaseServices, config.ProgressDone, "")
+		}
+	}
+
+	// Check out the repository if specified
+	if opts.checkout && req.Worktree {
+		progress.Report(config.PhaseClone, config.ProgressStarted, req.Repo)
+		if err := c.addWorktree(ctx, resp.ID, req.Name, req.Repo, req.Branch); err != nil {
+			// Log warning but don't fail - container is still usable
+			slog.Warn("Failed to add worktree", "shed", req.Name, "err", err)
+			progress.Report(config.PhaseClone, config.ProgressFailed, err.Error())
+			setupErrs = append(setupErrs, fmt.Sprintf("failed to add worktree: %v", err))
+		} else {
+			progress.Report(config.PhaseClone, config.ProgressDone, "")
+		}
+	} else if opts.checkout && req.Repo != "" {
+		progress.Report(config.PhaseClone, config.ProgressStarted, req.Repo)
+		if err := c.cloneRepo(ctx, resp.ID, req.Repo, req.Ref, req.Depth); err != nil {
 			// Log warning but don't fail - container is still usable
-			// The error will be noted in the shed status
-			log.Printf("Warning: failed to clone repository: %v", err)
+			slog.Warn("Failed to clone repository", "shed", req.Name, "err", err)
+			progress.Report(config.PhaseClone, config.ProgressFailed, err.Error())
+			setupErrs = append(setupErrs, fmt.Sprintf("failed to clone repository: %v", err))
+		} else {
+			progress.Report(config.PhaseClone, config.ProgressDone, "")
 		}
 	}
 
+	// Record the failure so it is reported with the shed from now on
+	setupErr := strings.Join(setupErrs, "; ")
+	if setupErr != "" {
+		c.setupErrors.set(req.Name, setupErr)
+	}
+
+	if rebuild {
+		setupErr = c.setupErrors.get(req.Name)
+	}
+
+	previewURL := ""
+	if req.HTTPPort > 0 {
+		previewURL = c.config.Proxy.PreviewURL(req.Name)
+	}
+
 	return &config.Shed{
 		Name:        req.Name,
 		Status:      config.StatusRunning,
 		CreatedAt:   createdAt,
 		Repo:        req.Repo,
+		Image:       image,
 		ContainerID: resp.ID,
+		Platform:    labels[config.LabelPlatform],
+		DeployKey:   labels[config.LabelDeployKey],
+		SetupError:  setupErr,
+		Owner:       c.owners.get(req.Name),
+		Forwarding:  forwardingFromLabels(labels),
+		Resources:   resourcesFromLabels(labels),
+		Mounts:      mountsFromLabels(labels),
+		Labels:      userLabels(labels),
+		Env:         envFromLabels(labels),
+		Secrets:     secretsFromLabels(labels),
+		Network:     labels[config.LabelNetwork],
+		Ports:       publishedPorts(labels, portsFromBindings(hostConfig.PortBindings)),
+		HTTPPort:    httpPortFromLabels(labels),
+		PreviewURL:  previewURL,
+		Services:    servicesFromLabels(labels),
+		HealthCheck: healthCheckFromLabels(labels),
+		PreStop:     preStopFromLabels(labels),
+		NoIdleStop:  labels[config.LabelIdleStop] == "false",
+		ExpiresAt:   expiresAtFromLabels(labels),
+		TTLAction:   labels[config.LabelTTLAction],
 	}, nil
 }
 
-// cloneRepo clones a git repository into the container's workspace.
-func (c *Client) cloneRepo(ctx context.Context, containerID, repo string) error {
+// ensureImage pulls an image unless it is already present locally, for
+// platform if one is given, else for the engine's architecture.
+func (c *Client) ensureImage(ctx context.Context, ref, platform string, progress config.ProgressFunc) error {
+	inspect, err := c.docker.ImageInspect(ctx, ref)
+	present := err == nil
+	if present {
+		if platform != "" && imageMatchesPlatform(inspect, platform) ||
+			platform == "" && c.isNativeImage(ctx, inspect) {
+			progress.Report(config.PhaseImage, config.ProgressDone, ref+" is present")
+			return nil
+		}
+	} else if !cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+
+	progress.Report(config.PhaseImage, config.ProgressStarted, "pulling "+ref)
+	if err := c.pullImage(ctx, ref, platform, progress); err != nil {
+		// An image for another architecture, say one built locally, still
+		// runs under emulation when no platform was asked for
+		if present && platform == "" {
+			slog.Warn("Failed to pull native image, using the emulated one", "image", ref, "arch", inspect.Architecture, "err", err)
+			progress.Report(config.PhaseImage, config.ProgressDone, ref+" is present for "+inspect.Architecture)
+			return nil
+		}
+		progress.Report(config.PhaseImage, config.ProgressFailed, err.Error())
+		return err
+	}
+	progress.Report(config.PhaseImage, config.ProgressDone, "pulled "+ref)
+	return nil
+}
+
+// cloneRepo clones a git repository into the container's workspace,
+// checking out ref if set and keeping depth commits if depth is positive.
+func (c *Client) cloneRepo(ctx context.Context, containerID, repo, ref string, depth int) error {
 	execConfig := container.ExecOptions{
-		Cmd:          []string{"git", "clone", repo, "."},
+		Cmd:          cloneCommand(repo, ref, depth),
 		WorkingDir:   config.WorkspacePath,
 		AttachStdout: true,
 		AttachStderr: true,
@@ -191,6 +518,18 @@ func (c *Client) cloneRepo(ctx context.Context, containerID, repo string) error
 	return nil
 }
 
+// cloneCommand returns the git clone command for a shed's workspace.
+func cloneCommand(repo, ref string, depth int) []string {
+	cmd := []string{"git", "clone"}
+	if ref != "" {
+		cmd = append(cmd, "--branch", ref)
+	}
+	if depth > 0 {
+		cmd = append(cmd, "--depth", strconv.Itoa(depth))
+	}
+	return append(cmd, "--", repo, ".")
+}
+
 // ListSheds returns all shed containers.
 func (c *Client) ListSheds(ctx context.Context) ([]config.Shed, error) {
 	// Filter containers by shed label
@@ -208,6 +547,7 @@ func (c *Client) ListSheds(ctx context.Context) ([]config.Shed, error) {
 	sheds := make([]config.Shed, 0, len(containers))
 	for _, ctr := range containers {
 		shed := containerToShed(ctr)
+		c.addNotes(&shed)
 		sheds = append(sheds, shed)
 	}
 
@@ -223,23 +563,72 @@ func (c *Client) GetShed(ctx context.Context, name string) (*config.Shed, error)
 	if err != nil {
 		// Check if it's a not found error
 		if cerrdefs.IsNotFound(err) {
-			return nil, fmt.Errorf("shed %q not found", name)
+			return nil, shedNotFound(name)
 		}
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
 	// Verify it's a shed container
 	if ctr.Config.Labels[config.LabelShed] != "true" {
-		return nil, fmt.Errorf("shed %q not found", name)
+		return nil, shedNotFound(name)
 	}
 
-	return inspectToShed(ctr), nil
+	shed := inspectToShed(ctr)
+	c.addNotes(shed)
+	return shed, nil
+}
+
+// addNotes fills in the state kept outside a shed's container labels.
+func (c *Client) addNotes(shed *config.Shed) {
+	shed.SetupError = c.setupErrors.get(shed.Name)
+	if labels, ok := c.labels.get(shed.Name); ok {
+		shed.Labels = nil
+		if len(labels) > 0 {
+			shed.Labels = labels
+		}
+	}
+	if env, ok := c.env.get(shed.Name); ok {
+		shed.Env = nil
+		if len(env) > 0 {
+			shed.Env = env
+		}
+	}
+	if shed.Status == config.StatusStopped {
+		shed.StoppedReason = c.stopReasons.get(shed.Name)
+	}
+	shed.Owner = c.owners.get(shed.Name)
+	shed.LastActivity = c.lastActivity(shed.Name)
+	shed.Backup = c.backupStatus(shed.Name)
+	if shed.HTTPPort > 0 {
+		shed.PreviewURL = c.config.Proxy.PreviewURL(shed.Name)
+	}
 }
 
 // DeleteShed deletes a shed container and optionally its volume.
 func (c *Client) DeleteShed(ctx context.Context, name string, keepVolume bool) error {
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	containerName := config.ContainerName(name)
 
+	// Note any shared clone cache before the container and its labels are gone
+	var cacheVolume, image, containerID string
+	if ctr, err := c.docker.ContainerInspect(ctx, containerName); err == nil && ctr.Config != nil {
+		cacheVolume = ctr.Config.Labels[config.LabelRepoCache]
+		image = ctr.Config.Image
+		containerID = ctr.ID
+
+		// Save work that would be lost with the volume before removing anything
+		if !keepVolume && c.safetyPushEnabled(ctr.Config.Labels) {
+			if err := c.backupWorkspace(ctx, name, ctr); err != nil {
+				return fmt.Errorf("safety push failed, shed %q was not deleted (use --keep-volume to delete the container only): %w", name, err)
+			}
+		}
+	}
+
 	// Remove container (force removal if running)
 	if err := c.docker.ContainerRemove(ctx, containerName, container.RemoveOptions{
 		Force:         true,
@@ -250,11 +639,29 @@ func (c *Client) DeleteShed(ctx context.Context, name string, keepVolume bool) e
 		}
 	}
 
+	if err := c.removeServices(ctx, name); err != nil {
+		slog.Warn("Failed to remove services", "shed", name, "err", err)
+	}
+	if err := c.removeShedNetwork(ctx, name); err != nil {
+		slog.Warn("Failed to remove network", "shed", name, "err", err)
+	}
+
+	c.forget(name)
+	c.multiplexers.Delete(containerID)
+
 	// Remove volume unless keepVolume is true
 	if !keepVolume {
 		if err := c.DeleteVolume(ctx, name); err != nil {
 			// Log warning but don't fail if volume doesn't exist
-			log.Printf("Warning: failed to delete volume: %v", err)
+			slog.Warn("Failed to delete volume", "shed", name, "err", err)
+		}
+
+		// The worktree is gone, so release it from the shared clone.
+		// A kept volume still references its worktree metadata.
+		if cacheVolume != "" {
+			if err := c.releaseWorktree(ctx, name, cacheVolume, image); err != nil {
+				slog.Warn("Failed to release worktree", "shed", name, "err", err)
+			}
 		}
 	}
 
@@ -263,7 +670,11 @@ func (c *Client) DeleteShed(ctx context.Context, name string, keepVolume bool) e
 
 // StartShed starts a stopped shed container.
 func (c *Client) StartShed(ctx context.Context, name string) (*config.Shed, error) {
-	containerName := config.ContainerName(name)
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	// Check current state
 	shed, err := c.GetShed(ctx, name)
@@ -274,19 +685,45 @@ func (c *Client) StartShed(ctx context.Context, name string) (*config.Shed, erro
 	if shed.Status == config.StatusRunning {
 		return nil, fmt.Errorf("shed %q is already running", name)
 	}
+	if shed.Status == config.StatusPaused {
+		return nil, fmt.Errorf("shed %q is paused, resume it instead", name)
+	}
 
-	// Start the container
-	if err := c.docker.ContainerStart(ctx, containerName, container.StartOptions{}); err != nil {
-		return nil, fmt.Errorf("failed to start container: %w", err)
+	if err := c.startShed(ctx, shed); err != nil {
+		return nil, err
 	}
 
 	// Return updated shed info
 	return c.GetShed(ctx, name)
 }
 
+// startShed starts a stopped shed's container, then writes its secrets and
+// starts its services. The caller holds the shed's lock.
+func (c *Client) startShed(ctx context.Context, shed *config.Shed) error {
+	if err := c.docker.ContainerStart(ctx, config.ContainerName(shed.Name), container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	c.stopReasons.clear(shed.Name)
+	if len(shed.Secrets) > 0 {
+		if err := c.WriteSecretFiles(ctx, shed.Name); err != nil {
+			slog.Warn("Failed to write secrets", "shed", shed.Name, "err", err)
+		}
+	}
+	if len(shed.Services) > 0 {
+		if err := c.startServices(ctx, shed.Name); err != nil {
+			slog.Warn("Failed to start services", "shed", shed.Name, "err", err)
+		}
+	}
+	return nil
+}
+
 // StopShed stops a running shed container.
 func (c *Client) StopShed(ctx context.Context, name string) (*config.Shed, error) {
-	containerName := config.ContainerName(name)
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	// Check current state
 	shed, err := c.GetShed(ctx, name)
@@ -298,12 +735,98 @@ func (c *Client) StopShed(ctx context.Context, name string) (*config.Shed, error
 		return nil, fmt.Errorf("shed %q is already stopped", name)
 	}
 
+	if err := c.stopShed(ctx, shed); err != nil {
+		return nil, err
+	}
+
+	// Return updated shed info
+	return c.GetShed(ctx, name)
+}
+
+// stopShed runs a shed's pre-stop hook, stops its container, and stops its
+// services. The caller holds the shed's lock.
+func (c *Client) stopShed(ctx context.Context, shed *config.Shed) error {
+	c.runPreStop(ctx, shed)
+
 	// Stop the container with a timeout
 	timeout := 10
-	if err := c.docker.ContainerStop(ctx, containerName, container.StopOptions{
+	if err := c.docker.ContainerStop(ctx, config.ContainerName(shed.Name), container.StopOptions{
 		Timeout: &timeout,
 	}); err != nil {
-		return nil, fmt.Errorf("failed to stop container: %w", err)
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+	c.stopReasons.clear(shed.Name)
+	if len(shed.Services) > 0 {
+		if err := c.stopServices(ctx, shed.Name); err != nil {
+			slog.Warn("Failed to stop services", "shed", shed.Name, "err", err)
+		}
+	}
+	return nil
+}
+
+// PauseShed freezes the processes of a running shed container.
+func (c *Client) PauseShed(ctx context.Context, name string) (*config.Shed, error) {
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	containerName := config.ContainerName(name)
+
+	// Check current state
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if shed.Status == config.StatusPaused {
+		return nil, fmt.Errorf("shed %q is already paused", name)
+	}
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+
+	if err := c.docker.ContainerPause(ctx, containerName); err != nil {
+		return nil, fmt.Errorf("failed to pause container: %w", err)
+	}
+	if len(shed.Services) > 0 {
+		if err := c.pauseServices(ctx, name); err != nil {
+			slog.Warn("Failed to pause services", "shed", name, "err", err)
+		}
+	}
+
+	// Return updated shed info
+	return c.GetShed(ctx, name)
+}
+
+// ResumeShed unfreezes the processes of a paused shed container.
+func (c *Client) ResumeShed(ctx context.Context, name string) (*config.Shed, error) {
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	containerName := config.ContainerName(name)
+
+	// Check current state
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if shed.Status != config.StatusPaused {
+		return nil, fmt.Errorf("shed %q is not paused", name)
+	}
+
+	if err := c.docker.ContainerUnpause(ctx, containerName); err != nil {
+		return nil, fmt.Errorf("failed to resume container: %w", err)
+	}
+	if len(shed.Services) > 0 {
+		if err := c.unpauseServices(ctx, name); err != nil {
+			slog.Warn("Failed to resume services", "shed", name, "err", err)
+		}
 	}
 
 	// Return updated shed info
@@ -364,12 +887,37 @@ func containerToShed(ctr container.Summary) config.Shed {
 
 	status := containerStateToStatus(ctr.State)
 
+	var networks map[string]*network.EndpointSettings
+	if ctr.NetworkSettings != nil {
+		networks = ctr.NetworkSettings.Networks
+	}
+
 	return config.Shed{
 		Name:        name,
 		Status:      status,
 		CreatedAt:   createdAt,
 		Repo:        repo,
+		Image:       ctr.Image,
 		ContainerID: ctr.ID,
+		Platform:    labels[config.LabelPlatform],
+		DeployKey:   labels[config.LabelDeployKey],
+		Forwarding:  forwardingFromLabels(labels),
+		Resources:   resourcesFromLabels(labels),
+		Mounts:      mountsFromLabels(labels),
+		Labels:      userLabels(labels),
+		Env:         envFromLabels(labels),
+		Secrets:     secretsFromLabels(labels),
+		Network:     labels[config.LabelNetwork],
+		IPAddress:   shedIPAddress(name, labels[config.LabelNetwork], networks),
+		Ports:       publishedPorts(labels, portsFromSummary(ctr.Ports)),
+		HTTPPort:    httpPortFromLabels(labels),
+		Services:    servicesFromLabels(labels),
+		HealthCheck: healthCheckFromLabels(labels),
+		PreStop:     preStopFromLabels(labels),
+		Health:      healthFromStatus(ctr.Status),
+		NoIdleStop:  labels[config.LabelIdleStop] == "false",
+		ExpiresAt:   expiresAtFromLabels(labels),
+		TTLAction:   labels[config.LabelTTLAction],
 	}
 }
 
@@ -387,13 +935,98 @@ func inspectToShed(ctr container.InspectResponse) *config.Shed {
 
 	status := inspectStateToStatus(ctr.State)
 
+	var networks map[string]*network.EndpointSettings
+	var bound []string
+	if ctr.NetworkSettings != nil {
+		networks = ctr.NetworkSettings.Networks
+		bound = portsFromBindings(ctr.NetworkSettings.Ports)
+	}
+
 	return &config.Shed{
 		Name:        name,
 		Status:      status,
 		CreatedAt:   createdAt,
 		Repo:        repo,
+		Image:       ctr.Config.Image,
 		ContainerID: ctr.ID,
+		Platform:    labels[config.LabelPlatform],
+		DeployKey:   labels[config.LabelDeployKey],
+		Forwarding:  forwardingFromLabels(labels),
+		Resources:   resourcesFromLabels(labels),
+		Mounts:      mountsFromLabels(labels),
+		Labels:      userLabels(labels),
+		Env:         envFromLabels(labels),
+		Secrets:     secretsFromLabels(labels),
+		Network:     labels[config.LabelNetwork],
+		IPAddress:   shedIPAddress(name, labels[config.LabelNetwork], networks),
+		Ports:       publishedPorts(labels, bound),
+		HTTPPort:    httpPortFromLabels(labels),
+		Services:    servicesFromLabels(labels),
+		HealthCheck: healthCheckFromLabels(labels),
+		PreStop:     preStopFromLabels(labels),
+		Health:      healthFromState(ctr.State),
+		NoIdleStop:  labels[config.LabelIdleStop] == "false",
+		ExpiresAt:   expiresAtFromLabels(labels),
+		TTLAction:   labels[config.LabelTTLAction],
+	}
+}
+
+// applyResources sets the container limits for a shed's resources.
+func applyResources(res *container.Resources, limits config.Resources) error {
+	memory, err := limits.MemoryBytes()
+	if err != nil {
+		return err
+	}
+	res.Memory = memory
+	res.NanoCPUs = int64(limits.CPUs * 1e9)
+	if limits.PidsLimit > 0 {
+		pids := limits.PidsLimit
+		res.PidsLimit = &pids
+	}
+	return nil
+}
+
+// resourcesFromLabels returns a shed's resource limits, or nil if it has
+// none.
+func resourcesFromLabels(labels map[string]string) *config.Resources {
+	var res config.Resources
+	res.CPUs, _ = strconv.ParseFloat(labels[config.LabelCPUs], 64)
+	res.Memory = labels[config.LabelMemory]
+	res.PidsLimit, _ = strconv.ParseInt(labels[config.LabelPidsLimit], 10, 64)
+	if res.IsZero() {
+		return nil
+	}
+	return &res
+}
+
+// mountsFromLabels returns a shed's extra mounts, or nil if it has none.
+func mountsFromLabels(labels map[string]string) []config.ShedMount {
+	v := labels[config.LabelMounts]
+	if v == "" {
+		return nil
+	}
+	var mounts []config.ShedMount
+	if err := json.Unmarshal([]byte(v), &mounts); err != nil {
+		slog.Warn("Ignoring invalid label", "label", config.LabelMounts, "err", err)
+		return nil
 	}
+	return mounts
+}
+
+// forwardingFromLabels returns a shed's port forwarding rules, or nil if
+// it has none.
+func forwardingFromLabels(labels map[string]string) *config.ForwardingRules {
+	var rules config.ForwardingRules
+	if v := labels[config.LabelForwardAllow]; v != "" {
+		rules.Allow = strings.Split(v, ",")
+	}
+	if v := labels[config.LabelForwardDeny]; v != "" {
+		rules.Deny = strings.Split(v, ",")
+	}
+	if rules.IsZero() {
+		return nil
+	}
+	return &rules
 }
 
 // containerStateToStatus converts Docker container state to shed status.
@@ -403,7 +1036,9 @@ func containerStateToStatus(state string) string {
 		return config.StatusRunning
 	case "created", "exited", "dead":
 		return config.StatusStopped
-	case "restarting", "paused":
+	case "paused":
+		return config.StatusPaused
+	case "restarting":
 		return config.StatusStarting
 	default:
 		return config.StatusError
@@ -416,10 +1051,14 @@ func inspectStateToStatus(state *container.State) string {
 		return config.StatusError
 	}
 
+	// Docker reports paused containers as running too
+	if state.Paused {
+		return config.StatusPaused
+	}
 	if state.Running {
 		return config.StatusRunning
 	}
-	if state.Paused || state.Restarting {
+	if state.Restarting {
 		return config.StatusStarting
 	}
 	return config.StatusStopped
@@ -0,0 +1,56 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestCloneCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		ref   string
+		depth int
+		want  []string
+	}{
+		{"default branch", "", 0, []string{"git", "clone", "--", "git@github.com:user/repo.git", "."}},
+		{"ref", "v1.2.0", 0, []string{"git", "clone", "--branch", "v1.2.0", "--", "git@github.com:user/repo.git", "."}},
+		{"shallow", "", 1, []string{"git", "clone", "--depth", "1", "--", "git@github.com:user/repo.git", "."}},
+		{"shallow ref", "main", 10, []string{"git", "clone", "--branch", "main", "--depth", "10", "--", "git@github.com:user/repo.git", "."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cloneCommand("git@github.com:user/repo.git", tt.ref, tt.depth)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cloneCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShedNotFound(t *testing.T) {
+	err := fmt.Errorf("rebuild: %w", shedNotFound("dev"))
+	if !errors.Is(err, ErrShedNotFound) {
+		t.Errorf("errors.Is(%v, ErrShedNotFound) = false", err)
+	}
+	if got, want := shedNotFound("dev").Error(), `shed "dev" not found`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestDeleteShedForgetsMultiplexer(t *testing.T) {
+	c := newEngineClient(t, &checkpointEngine{})
+	c.multiplexers.Store("c1", config.MultiplexerTmux)
+
+	if err := c.DeleteShed(context.Background(), "dev", true); err != nil {
+		t.Fatalf("DeleteShed() error = %v", err)
+	}
+	if _, ok := c.multiplexers.Load("c1"); ok {
+		t.Error("multiplexer of the deleted container is still cached")
+	}
+}
@@ -0,0 +1,117 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// GetShedDetails returns a shed with details of its container filled in.
+// The tmux session count is best effort and left zero if it can't be read.
+func (c *Client) GetShedDetails(ctx context.Context, name string) (*config.Shed, error) {
+	ctr, err := c.docker.ContainerInspect(ctx, config.ContainerName(name))
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, shedNotFound(name)
+		}
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if ctr.Config.Labels[config.LabelShed] != "true" {
+		return nil, shedNotFound(name)
+	}
+
+	shed := inspectToShed(ctr)
+	c.addNotes(shed)
+	shed.Details = shedDetails(ctr)
+	c.addImagePlatform(ctx, shed.Details)
+	c.addServiceStatus(ctx, shed)
+
+	if shed.Status == config.StatusRunning {
+		if sessions, err := c.ListSessions(ctx, name); err == nil {
+			shed.Details.Sessions = len(sessions)
+		}
+	}
+
+	return shed, nil
+}
+
+// shedDetails collects the debugging details of a shed's container.
+func shedDetails(ctr container.InspectResponse) *config.ShedDetails {
+	details := &config.ShedDetails{
+		Mounts: make([]config.ShedMount, 0, len(ctr.Mounts)),
+		Env:    make([]string, 0, len(ctr.Config.Env)),
+	}
+	if ctr.ContainerJSONBase != nil {
+		details.ImageID = ctr.Image
+		details.RestartCount = ctr.RestartCount
+		if ctr.State != nil && ctr.State.Running {
+			details.StartedAt, _ = time.Parse(time.RFC3339Nano, ctr.State.StartedAt)
+		}
+	}
+
+	for _, m := range ctr.Mounts {
+		source := m.Source
+		if m.Type == mount.TypeVolume {
+			source = m.Name
+		}
+		details.Mounts = append(details.Mounts, config.ShedMount{
+			Type:     string(m.Type),
+			Source:   source,
+			Target:   m.Destination,
+			ReadOnly: !m.RW,
+		})
+	}
+	sort.Slice(details.Mounts, func(i, j int) bool {
+		return details.Mounts[i].Target < details.Mounts[j].Target
+	})
+
+	for _, kv := range ctr.Config.Env {
+		name, _, _ := strings.Cut(kv, "=")
+		details.Env = append(details.Env, name)
+	}
+	sort.Strings(details.Env)
+
+	if ns := ctr.NetworkSettings; ns != nil {
+		// Prefer the network the shed was created on, but report any
+		// network's address if it has been moved
+		labels := ctr.Config.Labels
+		details.IPAddress = shedIPAddress(labels[config.LabelShedName], labels[config.LabelNetwork], ns.Networks)
+		if details.IPAddress == "" {
+			for _, ep := range ns.Networks {
+				if ep != nil && ep.IPAddress != "" {
+					details.IPAddress = ep.IPAddress
+					break
+				}
+			}
+		}
+
+		for name, ep := range ns.Networks {
+			if ep != nil && ep.IPAddress != "" {
+				if details.Networks == nil {
+					details.Networks = make(map[string]string)
+				}
+				details.Networks[name] = ep.IPAddress
+			}
+		}
+
+		for port, bindings := range ns.Ports {
+			if len(bindings) == 0 {
+				details.Ports = append(details.Ports, string(port))
+			}
+			for _, b := range bindings {
+				details.Ports = append(details.Ports, fmt.Sprintf("%s -> %s:%s", port, b.HostIP, b.HostPort))
+			}
+		}
+		sort.Strings(details.Ports)
+	}
+
+	return details
+}
@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestShedDetails(t *testing.T) {
+	started := time.Date(2026, 1, 20, 9, 30, 0, 0, time.UTC)
+	ctr := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			Image:        "sha256:abc",
+			RestartCount: 2,
+			State:        &container.State{Running: true, StartedAt: started.Format(time.RFC3339Nano)},
+		},
+		Mounts: []container.MountPoint{
+			{Type: mount.TypeVolume, Name: "shed-dev-workspace", Source: "/var/lib/docker/volumes/shed-dev-workspace/_data", Destination: "/workspace", RW: true},
+			{Type: mount.TypeBind, Source: "/home/me/.ssh", Destination: "/root/.ssh"},
+		},
+		Config: &container.Config{
+			Env: []string{"TZ=UTC", "GITHUB_TOKEN=secret", "PATH=/usr/bin"},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			NetworkSettingsBase: container.NetworkSettingsBase{
+				Ports: nat.PortMap{
+					"3000/tcp": nil,
+					"8080/tcp": {{HostIP: "127.0.0.1", HostPort: "18080"}},
+				},
+			},
+			Networks: map[string]*network.EndpointSettings{
+				"bridge": {IPAddress: "172.17.0.5"},
+			},
+		},
+	}
+
+	got := shedDetails(ctr)
+	want := &config.ShedDetails{
+		ImageID: "sha256:abc",
+		Mounts: []config.ShedMount{
+			{Type: config.MountTypeBind, Source: "/home/me/.ssh", Target: "/root/.ssh", ReadOnly: true},
+			{Type: config.MountTypeVolume, Source: "shed-dev-workspace", Target: "/workspace"},
+		},
+		Env:          []string{"GITHUB_TOKEN", "PATH", "TZ"},
+		IPAddress:    "172.17.0.5",
+		Networks:     map[string]string{"bridge": "172.17.0.5"},
+		Ports:        []string{"3000/tcp", "8080/tcp -> 127.0.0.1:18080"},
+		StartedAt:    started,
+		RestartCount: 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shedDetails() =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// repoSearchDepth is how deep below the workspace root to look for git
+// repositories, so sheds holding several checkouts are reported per repo.
+const repoSearchDepth = 3
+
+// GetWorkspaceDiff reports uncommitted and untracked changes for each git
+// repository in a shed's workspace. When full is set, the diff of tracked
+// files against HEAD is included for each repository.
+func (c *Client) GetWorkspaceDiff(ctx context.Context, name string, full bool) (*config.WorkspaceDiff, error) {
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+
+	repos, err := c.findRepos(ctx, shed.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &config.WorkspaceDiff{
+		Name:  name,
+		Repos: []config.RepoStatus{},
+	}
+
+	for _, dir := range repos {
+		status, err := c.repoStatus(ctx, shed.ContainerID, dir, full)
+		if err != nil {
+			return nil, err
+		}
+		diff.Repos = append(diff.Repos, *status)
+	}
+
+	return diff, nil
+}
+
+// findRepos returns the directories of git repositories in the workspace,
+// including worktrees whose .git is a file.
+func (c *Client) findRepos(ctx context.Context, containerID string) ([]string, error) {
+	cmd := []string{"find", config.WorkspacePath, "-maxdepth", strconv.Itoa(repoSearchDepth), "-name", ".git", "-print", "-prune"}
+
+	result, err := c.execOutput(ctx, containerID, cmd, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find repositories: %w", err)
+	}
+
+	// find exits non-zero on unreadable directories but still prints what it found
+	var repos []string
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		repos = append(repos, path.Dir(line))
+	}
+	sort.Strings(repos)
+
+	return repos, nil
+}
+
+// repoStatus collects git status, and optionally the full diff, for one repository.
+func (c *Client) repoStatus(ctx context.Context, containerID, dir string, full bool) (*config.RepoStatus, error) {
+	git := []string{"git", "-c", "core.quotePath=false", "-C", dir}
+
+	result, err := c.execOutput(ctx, containerID, append(git, "status", "--porcelain=v1", "--branch"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git status: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("git status in %s failed with exit code %d: %s", dir, result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	status := parseGitStatus(result.Stdout)
+	status.Path = relativeWorkspacePath(dir)
+
+	if full {
+		// Diffing against HEAD fails in a repository with no commits; the
+		// status still lists every file in that case
+		diffResult, err := c.execOutput(ctx, containerID, append(git, "diff", "HEAD"), nil)
+		if err == nil && diffResult.ExitCode == 0 {
+			status.Diff = diffResult.Stdout
+			if len(status.Diff) > config.MaxDiffBytes {
+				status.Diff = status.Diff[:config.MaxDiffBytes]
+				status.Truncated = true
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// relativeWorkspacePath returns dir relative to the workspace root, or "."
+// for the root itself.
+func relativeWorkspacePath(dir string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(dir, config.WorkspacePath), "/")
+	if rel == "" {
+		return "."
+	}
+	return rel
+}
+
+// parseGitStatus parses `git status --porcelain=v1 --branch` output.
+func parseGitStatus(output string) *config.RepoStatus {
+	status := &config.RepoStatus{
+		Changes: []config.FileChange{},
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			parseGitBranch(status, strings.TrimPrefix(line, "## "))
+			continue
+		}
+		if len(line) < 4 {
+			continue
+		}
+		status.Changes = append(status.Changes, config.FileChange{
+			Status: line[:2],
+			Path:   line[3:],
+		})
+	}
+
+	return status
+}
+
+// parseGitBranch parses the branch header of porcelain status output, e.g.
+// "main...origin/main [ahead 1, behind 2]".
+func parseGitBranch(status *config.RepoStatus, header string) {
+	if branch, ok := strings.CutPrefix(header, "No commits yet on "); ok {
+		status.Branch = branch
+		return
+	}
+	if strings.HasPrefix(header, "HEAD (no branch)") {
+		status.Branch = "HEAD"
+		return
+	}
+
+	branch, tracking, hasUpstream := strings.Cut(header, "...")
+	status.Branch = branch
+	if !hasUpstream {
+		return
+	}
+
+	_, counts, ok := strings.Cut(tracking, " [")
+	if !ok {
+		return
+	}
+	for _, part := range strings.Split(strings.TrimSuffix(counts, "]"), ", ") {
+		if n, ok := strings.CutPrefix(part, "ahead "); ok {
+			status.Ahead, _ = strconv.Atoi(n)
+		} else if n, ok := strings.CutPrefix(part, "behind "); ok {
+			status.Behind, _ = strconv.Atoi(n)
+		}
+	}
+}
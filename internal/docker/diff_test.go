@@ -0,0 +1,70 @@
+package docker
+
+import "testing"
+
+func TestParseGitStatus(t *testing.T) {
+	output := "## main...origin/main [ahead 2, behind 1]\n" +
+		" M src/main.go\n" +
+		"A  docs/new.md\n" +
+		"?? scratch.txt\n"
+
+	status := parseGitStatus(output)
+	if status.Branch != "main" {
+		t.Errorf("Branch = %q, want %q", status.Branch, "main")
+	}
+	if status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("Ahead, Behind = %d, %d, want 2, 1", status.Ahead, status.Behind)
+	}
+	if len(status.Changes) != 3 {
+		t.Fatalf("len(Changes) = %d, want 3", len(status.Changes))
+	}
+	if c := status.Changes[0]; c.Status != " M" || c.Path != "src/main.go" {
+		t.Errorf("Changes[0] = %+v, want { M src/main.go}", c)
+	}
+	if c := status.Changes[2]; c.Status != "??" || c.Path != "scratch.txt" {
+		t.Errorf("Changes[2] = %+v, want {?? scratch.txt}", c)
+	}
+	if status.Clean() {
+		t.Error("Clean() = true, want false")
+	}
+}
+
+func TestParseGitStatusBranchHeaders(t *testing.T) {
+	tests := []struct {
+		header string
+		branch string
+		ahead  int
+		clean  bool
+	}{
+		{"## main", "main", 0, true},
+		{"## main...origin/main", "main", 0, true},
+		{"## shed/foo...origin/shed/foo [ahead 3]", "shed/foo", 3, false},
+		{"## feature...origin/feature [gone]", "feature", 0, true},
+		{"## No commits yet on main", "main", 0, true},
+		{"## HEAD (no branch)", "HEAD", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			status := parseGitStatus(tt.header + "\n")
+			if status.Branch != tt.branch {
+				t.Errorf("Branch = %q, want %q", status.Branch, tt.branch)
+			}
+			if status.Ahead != tt.ahead {
+				t.Errorf("Ahead = %d, want %d", status.Ahead, tt.ahead)
+			}
+			if status.Clean() != tt.clean {
+				t.Errorf("Clean() = %v, want %v", status.Clean(), tt.clean)
+			}
+		})
+	}
+}
+
+func TestRelativeWorkspacePath(t *testing.T) {
+	if got := relativeWorkspacePath("/workspace"); got != "." {
+		t.Errorf("relativeWorkspacePath(/workspace) = %q, want %q", got, ".")
+	}
+	if got := relativeWorkspacePath("/workspace/api"); got != "api" {
+		t.Errorf("relativeWorkspacePath(/workspace/api) = %q, want %q", got, "api")
+	}
+}
@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// envFile is the legacy state file for shed environment variables changed after
+// creation. Like labels, a shed's stored variables replace those its
+// container was created with until it is rebuilt.
+const envFile = "env.json"
+
+// envFromLabels returns a shed's own environment variables recorded in its
+// container labels, or nil if it has none.
+func envFromLabels(labels map[string]string) map[string]string {
+	var env map[string]string
+	for k, v := range labels {
+		if name, ok := strings.CutPrefix(k, config.LabelEnvPrefix); ok {
+			if env == nil {
+				env = make(map[string]string)
+			}
+			env[name] = v
+		}
+	}
+	return env
+}
+
+// shedEnv returns a shed's current environment variables given its
+// container labels.
+func (c *Client) shedEnv(name string, labels map[string]string) map[string]string {
+	if stored, ok := c.env.get(name); ok {
+		if len(stored) == 0 {
+			return nil
+		}
+		return stored
+	}
+	return envFromLabels(labels)
+}
+
+// UpdateEnv sets and removes a shed's environment variables, returning the
+// updated shed. The changes reach new sessions right away and the
+// container itself when the shed is rebuilt.
+func (c *Client) UpdateEnv(ctx context.Context, name string, req config.UpdateEnvRequest) (*config.Shed, error) {
+	// Another update between reading and saving the environment would
+	// otherwise be lost
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ref := range shed.Secrets {
+		if _, ok := req.Set[ref.Env]; ok && ref.Env != "" {
+			return nil, fmt.Errorf("cannot update environment: %s is set by secret %s", ref.Env, ref.Name)
+		}
+	}
+
+	env := maps.Clone(shed.Env)
+	if env == nil {
+		env = make(map[string]string)
+	}
+	maps.Copy(env, req.Set)
+	for _, key := range req.Remove {
+		delete(env, key)
+	}
+
+	if err := c.env.set(name, env); err != nil {
+		return nil, fmt.Errorf("failed to save environment: %w", err)
+	}
+
+	shed.Env = nil
+	if len(env) > 0 {
+		shed.Env = env
+	}
+	return shed, nil
+}
+
+// SessionEnv returns the environment variables to start new sessions in a
+// shed with: its environment variable secrets, and changes to its own
+// variables made since its container was created. A variable removed since
+// then is given by name alone, which unsets it, or with the server's value
+// if it has one.
+func (c *Client) SessionEnv(ctx context.Context, name string) ([]string, error) {
+	ctr, err := c.docker.ContainerInspect(ctx, config.ContainerName(name))
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, shedNotFound(name)
+		}
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	labels := ctr.Config.Labels
+
+	var env []string
+	if stored, ok := c.env.get(name); ok {
+		var removed []string
+		for key := range envFromLabels(labels) {
+			if _, ok := stored[key]; !ok {
+				removed = append(removed, key)
+			}
+		}
+		sort.Strings(removed)
+
+		for _, key := range removed {
+			if value, ok := c.config.EnvVars[key]; ok {
+				env = append(env, key+"="+value)
+			} else {
+				env = append(env, key)
+			}
+		}
+		env = append(env, config.EnvList(stored)...)
+	}
+
+	// Secrets are kept out of the container's configuration, where
+	// docker inspect would show them
+	return append(env, c.secretEnv(name, secretsFromLabels(labels))...), nil
+}
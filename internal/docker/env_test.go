@@ -0,0 +1,96 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestEnvFromLabels(t *testing.T) {
+	labels := map[string]string{
+		config.LabelShed:                    "true",
+		config.LabelUserPrefix + "team":     "payments",
+		config.LabelEnvPrefix + "NODE_ENV":  "development",
+		config.LabelEnvPrefix + "API_TOKEN": "",
+	}
+	want := map[string]string{"NODE_ENV": "development", "API_TOKEN": ""}
+	if got := envFromLabels(labels); !maps.Equal(got, want) {
+		t.Errorf("envFromLabels() = %v, want %v", got, want)
+	}
+	if got := envFromLabels(map[string]string{config.LabelShed: "true"}); got != nil {
+		t.Errorf("envFromLabels() = %v, want nil", got)
+	}
+}
+
+func TestBuildEnvListShedOverrides(t *testing.T) {
+	c := &Client{config: &config.ServerConfig{EnvVars: map[string]string{
+		"GITHUB_TOKEN": "server",
+		"NODE_ENV":     "production",
+		"bad-name":     "x",
+	}}}
+
+	got := c.buildEnvList(map[string]string{"NODE_ENV": "development", "DEBUG": "1"})
+	slices.Sort(got)
+	want := []string{"DEBUG=1", "GITHUB_TOKEN=server", "NODE_ENV=development"}
+	if !slices.Equal(got, want) {
+		t.Errorf("buildEnvList() = %q, want %q", got, want)
+	}
+}
+
+func TestEnvStoreOverridesContainer(t *testing.T) {
+	dir := t.TempDir()
+	db := openState(t, dir)
+	c := &Client{env: loadMaps(db, dir, envFile, "shed env")}
+	container := map[string]string{config.LabelEnvPrefix + "DEBUG": "1"}
+
+	if got := c.shedEnv("dev", container); got["DEBUG"] != "1" {
+		t.Errorf("shedEnv() = %v, want container env", got)
+	}
+
+	if err := c.env.set("dev", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.shedEnv("dev", container); got != nil {
+		t.Errorf("shedEnv() = %v, want all removed", got)
+	}
+
+	if err := c.env.set("dev", map[string]string{"DEBUG": "0"}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	reloaded := &Client{env: loadMaps(openState(t, dir), dir, envFile, "shed env")}
+	if got := reloaded.shedEnv("dev", container); got["DEBUG"] != "0" {
+		t.Errorf("shedEnv() after reload = %v, want stored env", got)
+	}
+
+	reloaded.env.clear("dev")
+	if got := reloaded.shedEnv("dev", container); got["DEBUG"] != "1" {
+		t.Errorf("shedEnv() after clear = %v, want container env", got)
+	}
+}
+
+func TestUpdateEnvConcurrently(t *testing.T) {
+	c := newEngineClient(t, &checkpointEngine{running: true})
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := config.UpdateEnvRequest{Set: map[string]string{fmt.Sprintf("VAR_%d", i): "1"}}
+			if _, err := c.UpdateEnv(context.Background(), "dev", req); err != nil {
+				t.Errorf("UpdateEnv() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if env, _ := c.env.get("dev"); len(env) != 10 {
+		t.Errorf("env = %v, want all 10 variables", env)
+	}
+}
@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// eventsRetryDelay is how long WatchEvents waits before reconnecting to a
+// dropped Docker event stream.
+const eventsRetryDelay = 5 * time.Second
+
+// WatchEvents passes shed containers starting, stopping, pausing, and
+// resuming to publish until ctx is done. Changes made outside shed, such as
+// with docker stop or by a restart policy, are included. The Docker event
+// stream is reconnected if it drops.
+func (c *Client) WatchEvents(ctx context.Context, publish func(config.ShedEvent)) {
+	filterArgs := filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("label", config.LabelShed+"=true"),
+	)
+
+	for {
+		msgs, errs := c.docker.Events(ctx, events.ListOptions{Filters: filterArgs})
+	watch:
+		for {
+			select {
+			case msg := <-msgs:
+				if ev, ok := shedEvent(msg); ok {
+					publish(ev)
+				}
+			case err := <-errs:
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("Docker event stream ended, reconnecting", "err", err)
+				break watch
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(eventsRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// shedEvent converts a Docker container event to a shed event, reporting
+// false for actions that aren't lifecycle changes.
+func shedEvent(msg events.Message) (config.ShedEvent, bool) {
+	var typ string
+	switch msg.Action {
+	case events.ActionStart:
+		typ = config.ShedEventStarted
+	case events.ActionDie:
+		typ = config.ShedEventStopped
+	case events.ActionPause:
+		typ = config.ShedEventPaused
+	case events.ActionUnPause:
+		typ = config.ShedEventResumed
+	default:
+		return config.ShedEvent{}, false
+	}
+
+	name := msg.Actor.Attributes[config.LabelShedName]
+	if name == "" {
+		return config.ShedEvent{}, false
+	}
+
+	ev := config.ShedEvent{Type: typ, Shed: name}
+	if msg.TimeNano != 0 {
+		ev.Time = time.Unix(0, msg.TimeNano).UTC()
+	}
+	return ev, true
+}
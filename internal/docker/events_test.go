@@ -0,0 +1,40 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/events"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestShedEvent(t *testing.T) {
+	attrs := map[string]string{config.LabelShedName: "dev", "name": "shed-dev"}
+
+	tests := []struct {
+		action events.Action
+		attrs  map[string]string
+		want   string
+		ok     bool
+	}{
+		{events.ActionStart, attrs, config.ShedEventStarted, true},
+		{events.ActionDie, attrs, config.ShedEventStopped, true},
+		{events.ActionPause, attrs, config.ShedEventPaused, true},
+		{events.ActionUnPause, attrs, config.ShedEventResumed, true},
+		{events.ActionExecStart, attrs, "", false},
+		{events.ActionStart, map[string]string{"name": "other"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			msg := events.Message{Action: tt.action, Actor: events.Actor{Attributes: tt.attrs}, TimeNano: 1e18}
+			ev, ok := shedEvent(msg)
+			if ok != tt.ok || ev.Type != tt.want {
+				t.Fatalf("shedEvent() = %+v, %v; want type %q, %v", ev, ok, tt.want, tt.ok)
+			}
+			if ok && (ev.Shed != "dev" || ev.Time.IsZero()) {
+				t.Errorf("shedEvent() = %+v, want shed dev with time", ev)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// execResult holds the captured output of a non-interactive exec.
+type execResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// execOutput runs a non-interactive command in a container with optional
+// extra environment variables and captures its output and exit code.
+func (c *Client) execOutput(ctx context.Context, containerID string, cmd []string, env []string) (*execResult, error) {
+	execConfig := container.ExecOptions{
+		Cmd:          cmd,
+		Env:          env,
+		WorkingDir:   config.WorkspacePath,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+
+	execResp, err := c.docker.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	attachResp, err := c.docker.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer attachResp.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, attachResp.Reader); err != nil {
+		return nil, fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	inspectResp, err := c.docker.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+
+	return &execResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: inspectResp.ExitCode,
+	}, nil
+}
+
+// trackedExecScript runs a command ($1 onward) after recording the shell's
+// PID in a file ($0), so the command and everything it started can be
+// killed, and removes the file once it exits.
+const trackedExecScript = `echo $$ > "$0"; "$@"; status=$?; rm -f "$0"; exit $status`
+
+// killTreeScript sends TERM to the process whose PID is in a file ($0) and
+// to all its descendants, then KILL to any still running after a grace
+// period in seconds ($1), and removes the file.
+const killTreeScript = `tree() { for c in $(cat /proc/$1/task/*/children 2>/dev/null); do tree "$c"; done; echo "$1"; }
+alive() { for p in $pids; do kill -0 "$p" 2>/dev/null && return 0; done; return 1; }
+[ -f "$0" ] || exit 0
+pids=$(tree "$(cat "$0")")
+kill -TERM $pids 2>/dev/null
+i=0
+while [ "$i" -lt "$1" ] && alive; do sleep 1; i=$((i+1)); done
+kill -KILL $pids 2>/dev/null
+rm -f "$0"`
+
+// killExecGrace is how long a killed exec has to exit after TERM before
+// it is sent KILL.
+const killExecGrace = 5 * time.Second
+
+// killExecTimeout bounds killing a cancelled exec, which runs after the
+// exec's own context has ended.
+const killExecTimeout = killExecGrace + 10*time.Second
+
+// CopyExecOutput copies an attached exec's output to stdout and stderr,
+// either of which may be nil to discard it. Without a TTY, Docker sends
+// both streams over one connection as frames, each with an 8-byte header
+// naming its stream, so they are split back out; with one, the output is a
+// single stream and goes to stdout as is.
+func CopyExecOutput(r io.Reader, tty bool, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if tty {
+		_, err := io.Copy(stdout, r)
+		return err
+	}
+	_, err := stdcopy.StdCopy(stdout, stderr, r)
+	return err
+}
+
+// TrackExec wraps cmd so that it can later be stopped with KillExec, which
+// Docker's API has no call for. It returns the wrapped command and the
+// file in the container holding its PID.
+func TrackExec(cmd []string) ([]string, string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate exec id: %w", err)
+	}
+	pidFile := "/tmp/shed-exec-" + hex.EncodeToString(id) + ".pid"
+	return append([]string{"sh", "-c", trackedExecScript, pidFile}, cmd...), pidFile, nil
+}
+
+// KillExec stops a command started with TrackExec, and everything it
+// started, if it's still running: with TERM, then KILL if they haven't
+// exited after killExecGrace.
+func (c *Client) KillExec(containerID, pidFile string) {
+	ctx, cancel := context.WithTimeout(context.Background(), killExecTimeout)
+	defer cancel()
+
+	grace := strconv.Itoa(int(killExecGrace / time.Second))
+	if _, err := c.execOutput(ctx, containerID, []string{"sh", "-c", killTreeScript, pidFile, grace}, nil); err != nil {
+		slog.Warn("Failed to kill exec", "container", containerID, "err", err)
+	}
+}
+
+// ExecCommand runs a shell command in a running shed's workspace with the
+// environment SSH sessions get, copying its stdout and stderr to the given
+// writers, and returns its exit code. If ctx ends first, the command and
+// everything it started are killed and ctx.Err() is returned.
+func (c *Client) ExecCommand(ctx context.Context, name, command string, stdout, stderr io.Writer) (int, error) {
+	shed, err := c.runningShed(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	env, err := c.SessionEnv(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd, pidFile, err := TrackExec([]string{"sh", "-c", command})
+	if err != nil {
+		return 0, err
+	}
+
+	execResp, err := c.docker.ContainerExecCreate(ctx, shed.ContainerID, container.ExecOptions{
+		Cmd:          cmd,
+		Env:          append(env, "SHED_NAME="+name),
+		WorkingDir:   config.WorkspacePath,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	attachResp, err := c.docker.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer attachResp.Close()
+
+	// The hijacked connection ignores ctx, so close it to stop reading, and
+	// kill the command rather than leave it running unwatched
+	stop := context.AfterFunc(ctx, func() {
+		attachResp.Close()
+		c.KillExec(shed.ContainerID, pidFile)
+	})
+	defer stop()
+
+	if _, err := stdcopy.StdCopy(stdout, stderr, attachResp.Reader); err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, fmt.Errorf("failed to read exec output: %w", err)
+	}
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	inspectResp, err := c.docker.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return inspectResp.ExitCode, nil
+}
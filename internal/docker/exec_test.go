@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestTrackExec(t *testing.T) {
+	cmd, pidFile, err := TrackExec([]string{"make", "test"})
+	if err != nil {
+		t.Fatalf("TrackExec() error = %v", err)
+	}
+	if !strings.HasPrefix(pidFile, "/tmp/shed-exec-") || !strings.HasSuffix(pidFile, ".pid") {
+		t.Errorf("pidFile = %q, want /tmp/shed-exec-*.pid", pidFile)
+	}
+	want := []string{"sh", "-c", trackedExecScript, pidFile, "make", "test"}
+	if !slices.Equal(cmd, want) {
+		t.Errorf("cmd = %q, want %q", cmd, want)
+	}
+
+	_, other, _ := TrackExec([]string{"make"})
+	if other == pidFile {
+		t.Errorf("TrackExec() reused pid file %q", pidFile)
+	}
+}
+
+// The kill script runs inside containers, but any Linux host's sh can run
+// it too.
+func TestKillTreeScript(t *testing.T) {
+	if _, err := os.Stat("/proc/self/task"); err != nil {
+		t.Skip("needs /proc")
+	}
+	dir := t.TempDir()
+	innerFile := filepath.Join(dir, "inner.pid")
+	cmd, pidFile, err := TrackExec([]string{"sh", "-c", `trap "" TERM; echo $$ > "$0"; sleep 60`, innerFile})
+	if err != nil {
+		t.Fatalf("TrackExec() error = %v", err)
+	}
+	pidFile = filepath.Join(dir, filepath.Base(pidFile))
+	cmd[3] = pidFile
+
+	tracked := exec.Command(cmd[0], cmd[1:]...)
+	if err := tracked.Start(); err != nil {
+		t.Fatalf("failed to start command: %v", err)
+	}
+	go func() { _ = tracked.Wait() }()
+
+	var inner int
+	for i := 0; i < 100 && inner == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		data, _ := os.ReadFile(innerFile)
+		inner, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+	if inner == 0 {
+		t.Fatal("command didn't start")
+	}
+	// Give sleep time to start, ignoring TERM as its shell does
+	time.Sleep(100 * time.Millisecond)
+
+	if out, err := exec.Command("sh", "-c", killTreeScript, pidFile, "1").CombinedOutput(); err != nil {
+		t.Fatalf("kill script error = %v: %s", err, out)
+	}
+	if processRunning(inner) {
+		if p, err := os.FindProcess(inner); err == nil {
+			_ = p.Kill()
+		}
+		t.Error("command ignoring TERM still running after the grace period")
+	}
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Errorf("pid file not removed: %v", err)
+	}
+}
+
+// processRunning reports whether a process exists and hasn't exited.
+func processRunning(pid int) bool {
+	for i := 0; i < 50; i++ {
+		data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil {
+			return false
+		}
+		// The state follows the parenthesized command name
+		if fields := strings.Fields(string(data[strings.LastIndex(string(data), ")")+1:])); len(fields) > 0 && fields[0] == "Z" {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func TestCopyExecOutput(t *testing.T) {
+	// Docker frames each write without a TTY with a header naming its stream
+	var stream bytes.Buffer
+	_, _ = stdcopy.NewStdWriter(&stream, stdcopy.Stdout).Write([]byte("out 1\n"))
+	_, _ = stdcopy.NewStdWriter(&stream, stdcopy.Stderr).Write([]byte("err\n"))
+	_, _ = stdcopy.NewStdWriter(&stream, stdcopy.Stdout).Write([]byte("out 2\n"))
+	framed := stream.Bytes()
+
+	var stdout, stderr bytes.Buffer
+	if err := CopyExecOutput(bytes.NewReader(framed), false, &stdout, &stderr); err != nil {
+		t.Fatalf("CopyExecOutput() error = %v", err)
+	}
+	if stdout.String() != "out 1\nout 2\n" || stderr.String() != "err\n" {
+		t.Errorf("stdout = %q, stderr = %q; want the streams split without headers", stdout.String(), stderr.String())
+	}
+
+	// Without a stderr writer its frames are dropped, not sent to stdout
+	stdout.Reset()
+	if err := CopyExecOutput(bytes.NewReader(framed), false, &stdout, nil); err != nil {
+		t.Fatalf("CopyExecOutput() error = %v", err)
+	}
+	if stdout.String() != "out 1\nout 2\n" {
+		t.Errorf("stdout = %q without stderr, want only stdout's frames", stdout.String())
+	}
+
+	// With a TTY the output is one unframed stream
+	stdout.Reset()
+	if err := CopyExecOutput(strings.NewReader("\x1b[1mbold\x1b[0m"), true, &stdout, &stderr); err != nil {
+		t.Fatalf("CopyExecOutput() error = %v", err)
+	}
+	if stdout.String() != "\x1b[1mbold\x1b[0m" {
+		t.Errorf("stdout = %q with a TTY, want the output as is", stdout.String())
+	}
+}
@@ -0,0 +1,179 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// workspacePrefix is the directory workspace archives hold their entries
+// under, matching what Docker produces when copying WorkspacePath.
+var workspacePrefix = path.Base(config.WorkspacePath) + "/"
+
+// ExportWorkspace returns a tar archive of a shed's workspace, with entries
+// under workspace/. The shed may be stopped. The caller must close it.
+func (c *Client) ExportWorkspace(ctx context.Context, name string) (io.ReadCloser, error) {
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, _, err := c.docker.CopyFromContainer(ctx, shed.ContainerID, config.WorkspacePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	return reader, nil
+}
+
+// ImportShed creates a shed and fills its workspace from a tar archive as
+// produced by ExportWorkspace, optionally gzipped.
+func (c *Client) ImportShed(ctx context.Context, req config.CreateShedRequest, archive io.Reader) (*config.Shed, error) {
+	unlock, err := c.lockShed(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Worktrees need a shared clone, which the archive doesn't have
+	req.Worktree = false
+	return c.restoreShed(ctx, req, archive)
+}
+
+// restoreShed creates a shed with its workspace extracted from archive
+// instead of checked out. If the archive can't be restored, the new shed is
+// removed again.
+func (c *Client) restoreShed(ctx context.Context, req config.CreateShedRequest, archive io.Reader) (*config.Shed, error) {
+	shed, err := c.createShed(ctx, req, nil, createOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.restoreWorkspace(ctx, shed.ContainerID, archive); err != nil {
+		// Nothing in the new workspace is worth a safety push, so remove
+		// the container and volume directly
+		if rmErr := c.docker.ContainerRemove(ctx, shed.ContainerID, container.RemoveOptions{Force: true}); rmErr != nil {
+			slog.Warn("Failed to remove shed after failed restore", "shed", req.Name, "err", rmErr)
+		}
+		if rmErr := c.DeleteVolume(ctx, req.Name); rmErr != nil {
+			slog.Warn("Failed to remove volume after failed restore", "shed", req.Name, "err", rmErr)
+		}
+		return nil, err
+	}
+
+	return shed, nil
+}
+
+// restoreWorkspace extracts the workspace entries of archive into the
+// container's workspace, keeping their owners.
+func (c *Client) restoreWorkspace(ctx context.Context, containerID string, archive io.Reader) error {
+	pr, pw := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		err := workspaceEntries(archive, pw)
+		pw.CloseWithError(err)
+		errc <- err
+	}()
+
+	err := c.docker.CopyToContainer(ctx, containerID, config.WorkspacePath, pr, container.CopyToContainerOptions{
+		CopyUIDGID: true,
+	})
+	// Unblock the writer if Docker stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+
+	// A bad archive is the real cause of any copy failure it led to
+	var archiveErr *workspaceArchiveError
+	if errors.As(<-errc, &archiveErr) {
+		return archiveErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore workspace: %w", err)
+	}
+	return nil
+}
+
+// workspaceArchiveError reports an archive that isn't a workspace export.
+type workspaceArchiveError struct {
+	msg string
+}
+
+func (e *workspaceArchiveError) Error() string {
+	return "invalid workspace archive: " + e.msg
+}
+
+// workspaceEntries rewrites archive, which may be gzipped, to w as a tar
+// stream of its entries under workspace/ with that prefix removed, so they
+// can only be extracted into the workspace.
+func workspaceEntries(archive io.Reader, w io.Writer) error {
+	br := bufio.NewReader(archive)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return &workspaceArchiveError{msg: err.Error()}
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	entries := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &workspaceArchiveError{msg: err.Error()}
+		}
+
+		name, ok := workspaceRelative(hdr.Name)
+		if !ok {
+			return &workspaceArchiveError{msg: fmt.Sprintf("entry %q is not under %s", hdr.Name, workspacePrefix)}
+		}
+		if name == "" {
+			continue // The workspace directory itself
+		}
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeLink {
+			if hdr.Linkname, ok = workspaceRelative(hdr.Linkname); !ok || hdr.Linkname == "" {
+				return &workspaceArchiveError{msg: fmt.Sprintf("entry %q links outside %s", hdr.Name, workspacePrefix)}
+			}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+		entries++
+	}
+	if entries == 0 {
+		return &workspaceArchiveError{msg: "no files under " + workspacePrefix}
+	}
+	return tw.Close()
+}
+
+// workspaceRelative returns name relative to the workspace directory, or
+// false if it falls outside it.
+func workspaceRelative(name string) (string, bool) {
+	cleaned := path.Clean("/" + name)
+	dir := "/" + strings.TrimSuffix(workspacePrefix, "/")
+	if cleaned == dir {
+		return "", true
+	}
+	rel, ok := strings.CutPrefix(cleaned, dir+"/")
+	return rel, ok
+}
@@ -0,0 +1,94 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+)
+
+// buildArchive returns a tar archive of the given files, which are written
+// as directories if their name ends in a slash.
+func buildArchive(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(name))}
+		if name[len(name)-1] == '/' {
+			hdr = &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader() failed: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(name)); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// entryNames lists the entries in a tar stream.
+func entryNames(t *testing.T, data []byte) []string {
+	t.Helper()
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestWorkspaceEntries(t *testing.T) {
+	archive := buildArchive(t, "workspace/", "workspace/repo/", "workspace/repo/main.go")
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(archive)
+	gz.Close()
+
+	for name, input := range map[string][]byte{"tar": archive, "gzip": gzipped.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := workspaceEntries(bytes.NewReader(input), &out); err != nil {
+				t.Fatalf("workspaceEntries() failed: %v", err)
+			}
+			got := entryNames(t, out.Bytes())
+			if len(got) != 2 || got[0] != "repo" || got[1] != "repo/main.go" {
+				t.Errorf("entries = %v, want [repo repo/main.go]", got)
+			}
+		})
+	}
+}
+
+func TestWorkspaceEntriesRejectsOutsidePaths(t *testing.T) {
+	tests := map[string][]byte{
+		"other dir": buildArchive(t, "etc/passwd"),
+		"escape":    buildArchive(t, "workspace/../etc/passwd"),
+		"empty":     buildArchive(t, "workspace/"),
+		"not tar":   []byte("definitely not a tarball, just some text padding it out past a block"),
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := workspaceEntries(bytes.NewReader(input), io.Discard)
+			var archiveErr *workspaceArchiveError
+			if !errors.As(err, &archiveErr) {
+				t.Errorf("workspaceEntries() = %v, want workspaceArchiveError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package docker
+
+// lockFile is not supported on this platform; only collections in the same
+// process are serialized.
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,44 @@
+//go:build linux || darwin
+
+package docker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", imageUsageLockFile)
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile() error = %v", err)
+	}
+
+	// A second holder, as another process would be, waits for the first
+	locked := make(chan func())
+	go func() {
+		unlock, err := lockFile(path)
+		if err != nil {
+			t.Errorf("second lockFile() error = %v", err)
+			close(locked)
+			return
+		}
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("second lockFile() returned while the lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case unlock, ok := <-locked:
+		if ok {
+			unlock()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lockFile() still waiting after the lock was released")
+	}
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/docker/docker/api/types/container"
+)
+
+// healthCheckFromLabels parses the health check given at creation from a
+// container's labels.
+func healthCheckFromLabels(labels map[string]string) *config.HealthCheck {
+	v := labels[config.LabelHealthCheck]
+	if v == "" {
+		return nil
+	}
+	var check config.HealthCheck
+	if err := json.Unmarshal([]byte(v), &check); err != nil {
+		slog.Warn("Ignoring invalid label", "label", config.LabelHealthCheck, "err", err)
+		return nil
+	}
+	return &check
+}
+
+// healthConfig returns the Docker health check for a shed's health check.
+func healthConfig(check config.HealthCheck) (*container.HealthConfig, error) {
+	interval, timeout, startPeriod, err := check.Durations()
+	if err != nil {
+		return nil, err
+	}
+	return &container.HealthConfig{
+		Test:        []string{"CMD-SHELL", check.Command},
+		Interval:    interval,
+		Timeout:     timeout,
+		StartPeriod: startPeriod,
+		Retries:     check.Retries,
+	}, nil
+}
+
+// healthFromStatus returns a running container's health from the status
+// text in a container list, such as "Up 5 minutes (healthy)". Listing
+// containers doesn't report health any other way.
+func healthFromStatus(status string) string {
+	switch {
+	case strings.HasSuffix(status, "(healthy)"):
+		return config.HealthHealthy
+	case strings.HasSuffix(status, "(unhealthy)"):
+		return config.HealthUnhealthy
+	case strings.HasSuffix(status, "(health: starting)"):
+		return config.HealthStarting
+	default:
+		return ""
+	}
+}
+
+// healthFromState returns a container's health from inspect.
+func healthFromState(state *container.State) string {
+	if state == nil || state.Health == nil || !state.Running {
+		return ""
+	}
+	switch state.Health.Status {
+	case container.Healthy:
+		return config.HealthHealthy
+	case container.Unhealthy:
+		return config.HealthUnhealthy
+	case container.Starting:
+		return config.HealthStarting
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestHealthConfig(t *testing.T) {
+	cfg, err := healthConfig(config.HealthCheck{Command: "test -f /ready", Interval: "10s", StartPeriod: "1m", Retries: 2})
+	if err != nil {
+		t.Fatalf("healthConfig() error = %v", err)
+	}
+	want := &container.HealthConfig{
+		Test:        []string{"CMD-SHELL", "test -f /ready"},
+		Interval:    10 * time.Second,
+		StartPeriod: time.Minute,
+		Retries:     2,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("healthConfig() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestHealthFromStatus(t *testing.T) {
+	tests := map[string]string{
+		"Up 5 minutes (healthy)":          config.HealthHealthy,
+		"Up 2 hours (unhealthy)":          config.HealthUnhealthy,
+		"Up 3 seconds (health: starting)": config.HealthStarting,
+		"Up 5 minutes":                    "",
+		"Exited (0) 2 minutes ago":        "",
+		"Up 1 minute (Paused)":            "",
+	}
+	for status, want := range tests {
+		if got := healthFromStatus(status); got != want {
+			t.Errorf("healthFromStatus(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestHealthFromState(t *testing.T) {
+	running := &container.State{Running: true, Health: &container.Health{Status: container.Unhealthy}}
+	if got := healthFromState(running); got != config.HealthUnhealthy {
+		t.Errorf("healthFromState(running, unhealthy) = %q, want %q", got, config.HealthUnhealthy)
+	}
+	// A stopped container keeps its last health
+	stopped := &container.State{Health: &container.Health{Status: container.Healthy}}
+	if got := healthFromState(stopped); got != "" {
+		t.Errorf("healthFromState(stopped) = %q, want none", got)
+	}
+	if got := healthFromState(&container.State{Running: true}); got != "" {
+		t.Errorf("healthFromState(no health check) = %q, want none", got)
+	}
+}
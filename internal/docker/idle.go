@@ -0,0 +1,99 @@
+package docker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/charliek/shed/internal/activity"
+	"github.com/charliek/shed/internal/config"
+)
+
+// idleCheckInterval is how often the reaper looks for idle sheds.
+const idleCheckInterval = time.Minute
+
+// IdleReaper stops running sheds that have been idle longer than the
+// server's idle timeout, recording StopReasonIdle as the stopped reason.
+type IdleReaper struct {
+	client  *Client
+	tracker *activity.Tracker
+	timeout time.Duration
+}
+
+// NewIdleReaper creates an IdleReaper using the idle timeout in the server
+// configuration and SSH activity from tracker.
+func NewIdleReaper(c *Client, cfg *config.ServerConfig, tracker *activity.Tracker) *IdleReaper {
+	return &IdleReaper{
+		client:  c,
+		tracker: tracker,
+		timeout: cfg.IdleTimeout,
+	}
+}
+
+// Run checks for idle sheds once per interval until the context is
+// cancelled.
+func (r *IdleReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.reap(ctx)
+	}
+}
+
+// reap stops each running shed that has been idle for too long.
+func (r *IdleReaper) reap(ctx context.Context) {
+	sheds, err := r.client.ListSheds(ctx)
+	if err != nil {
+		slog.Warn("Idle check failed", "err", err)
+		return
+	}
+
+	for _, shed := range sheds {
+		if shed.Status != config.StatusRunning || shed.NoIdleStop {
+			continue
+		}
+
+		idle := time.Since(r.lastActive(ctx, shed))
+		if idle < r.timeout {
+			continue
+		}
+
+		if _, err := r.client.StopShed(ctx, shed.Name); err != nil {
+			slog.Warn("Failed to stop idle shed", "shed", shed.Name, "err", err)
+			continue
+		}
+		r.client.stopReasons.set(shed.Name, config.StopReasonIdle)
+		slog.Info("Stopped idle shed", "shed", shed.Name, "idle", idle.Round(time.Minute))
+	}
+}
+
+// lastActive returns the latest of the shed's last SSH activity, when its
+// container was started, and its last tmux activity, so sheds that were
+// just started or are running detached work aren't stopped.
+func (r *IdleReaper) lastActive(ctx context.Context, shed config.Shed) time.Time {
+	last := r.tracker.LastActive(shed.Name)
+	if time.Since(last) < r.timeout {
+		return last
+	}
+
+	if ctr, err := r.client.docker.ContainerInspect(ctx, shed.ContainerID); err == nil && ctr.State != nil {
+		if started, err := time.Parse(time.RFC3339Nano, ctr.State.StartedAt); err == nil && started.After(last) {
+			last = started
+		}
+	}
+
+	// Sessions are ordered most recently active first
+	if sessions, err := r.client.ListSessions(ctx, shed.Name); err == nil && len(sessions) > 0 {
+		if sessions[0].LastActivity.After(last) {
+			last = sessions[0].LastActivity
+		}
+	}
+
+	return last
+}
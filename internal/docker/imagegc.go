@@ -0,0 +1,371 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// imageUsageFile, in the state directory, records when sheds last used
+// each image. It is a file rather than part of the state store so
+// shed-server gc can collect images while the server holds the store.
+// Each reads and writes it holding a lock on imageUsageLockFile, so neither
+// loses the other's changes.
+const (
+	imageUsageFile     = "image-usage.json"
+	imageUsageLockFile = "image-usage.lock"
+)
+
+// imageUsage is the content of imageUsageFile.
+type imageUsage struct {
+	// Images maps the IDs of images sheds have used to their last use.
+	Images map[string]imageUse  `json:"images"`
+	Stats  config.ImageGCStatus `json:"stats"`
+}
+
+type imageUse struct {
+	// Refs are the image's tags when it was last used, kept to describe it
+	// once it has lost them.
+	Refs     []string  `json:"refs,omitempty"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// ImageCollector removes images no shed has used for the configured number
+// of days, on a schedule or on request.
+type ImageCollector struct {
+	docker   *client.Client
+	cfg      *config.ServerConfig
+	path     string
+	lockPath string
+
+	// mu serializes collections in this process; the lock file serializes
+	// them with other processes.
+	mu sync.Mutex
+}
+
+// NewImageCollector creates an ImageCollector for the engine and the
+// image_gc settings in the server configuration.
+func NewImageCollector(docker *client.Client, cfg *config.ServerConfig) *ImageCollector {
+	return &ImageCollector{
+		docker:   docker,
+		cfg:      cfg,
+		path:     filepath.Join(cfg.StateDir, imageUsageFile),
+		lockPath: filepath.Join(cfg.StateDir, imageUsageLockFile),
+	}
+}
+
+// Run collects images immediately and then once per interval until the
+// context is cancelled.
+func (g *ImageCollector) Run(ctx context.Context) {
+	interval := g.cfg.ImageGC.Interval
+	if interval <= 0 {
+		interval = config.DefaultImageGCInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := g.Collect(ctx, false, g.cfg.ImageGC.UnusedDays)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Image collection failed", "err", err)
+			g.recordFailure(err)
+		} else if len(resp.Resources) > 0 {
+			slog.Info("Collected unused images", "images", len(resp.Resources), "reclaimed", resp.SpaceReclaimed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect records which images sheds use now, then removes the images no
+// shed has used for unusedDays. With dryRun it reports what would be
+// removed without removing anything. Removal failures are reported on each
+// image.
+func (g *ImageCollector) Collect(ctx context.Context, dryRun bool, unusedDays int) (*config.PruneResponse, error) {
+	if unusedDays < 1 {
+		return nil, fmt.Errorf("invalid unused days: %d", unusedDays)
+	}
+	unlock, err := g.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	usage, err := g.load()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := g.docker.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	summaries, err := g.docker.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	now := time.Now().UTC()
+	markUsedImages(usage, summaries, containers, now)
+	maxAge := time.Duration(unusedDays) * 24 * time.Hour
+	found := collectableImages(usage, summaries, containers, g.keeps, maxAge, now)
+
+	tags := make(map[string][]string, len(summaries))
+	for _, s := range summaries {
+		tags[s.ID] = s.RepoTags
+	}
+	resp := &config.PruneResponse{DryRun: dryRun, Resources: found}
+	removed := 0
+	for i := range resp.Resources {
+		r := &resp.Resources[i]
+		if !dryRun {
+			if err := g.remove(ctx, r.Name, tags[r.Name]); err != nil {
+				r.Error = err.Error()
+				continue
+			}
+			delete(usage.Images, r.Name)
+			removed++
+		}
+		resp.SpaceReclaimed += r.Size
+	}
+
+	if !dryRun {
+		usage.Stats.LastRun = &now
+		usage.Stats.LastRemoved = removed
+		usage.Stats.LastReclaimed = resp.SpaceReclaimed
+		usage.Stats.TotalRemoved += removed
+		usage.Stats.TotalReclaimed += resp.SpaceReclaimed
+		usage.Stats.Error = ""
+	}
+	if err := g.save(usage); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Status returns the results of collections so far.
+func (g *ImageCollector) Status() *config.ImageGCStatus {
+	status := config.ImageGCStatus{}
+	unlock, err := g.lock()
+	if err != nil {
+		status.Error = err.Error()
+		status.UnusedDays = g.cfg.ImageGC.UnusedDays
+		return &status
+	}
+	defer unlock()
+
+	usage, err := g.load()
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status = usage.Stats
+	}
+	status.UnusedDays = g.cfg.ImageGC.UnusedDays
+	return &status
+}
+
+// recordFailure notes why the latest collection failed.
+func (g *ImageCollector) recordFailure(cause error) {
+	unlock, err := g.lock()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	usage, err := g.load()
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	usage.Stats.LastRun = &now
+	usage.Stats.Error = cause.Error()
+	if err := g.save(usage); err != nil {
+		slog.Warn("Failed to record image collection failure", "err", err)
+	}
+}
+
+// lock serializes use of the image usage file with this process and with
+// other processes, such as shed-server gc run beside the server.
+func (g *ImageCollector) lock() (unlock func(), err error) {
+	g.mu.Lock()
+	unlockFile, err := lockFile(g.lockPath)
+	if err != nil {
+		g.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile()
+		g.mu.Unlock()
+	}, nil
+}
+
+// keeps reports whether an image named ref must never be collected: the
+// default image, pre-pulled images, and those matching image_gc.keep.
+func (g *ImageCollector) keeps(ref string) bool {
+	if g.cfg.ImageGC.Keeps(ref) {
+		return true
+	}
+	for _, kept := range append([]string{g.cfg.DefaultImage}, g.cfg.PrepullImages()...) {
+		if ref == kept || ref == withDefaultTag(kept) {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaultTag adds the latest tag to an image reference without a tag or
+// digest, as the engine lists it.
+func withDefaultTag(ref string) string {
+	name := ref[strings.LastIndex(ref, "/")+1:]
+	if strings.ContainsAny(name, ":@") {
+		return ref
+	}
+	return ref + ":latest"
+}
+
+// remove removes an image. A tagged image is removed by untagging it, since
+// the engine won't remove an image with several tags by ID.
+func (g *ImageCollector) remove(ctx context.Context, id string, tags []string) error {
+	if len(tags) == 0 {
+		_, err := g.docker.ImageRemove(ctx, id, image.RemoveOptions{PruneChildren: true})
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := g.docker.ImageRemove(ctx, tag, image.RemoveOptions{PruneChildren: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the image usage file, which is empty until the first
+// collection.
+func (g *ImageCollector) load() (*imageUsage, error) {
+	usage := &imageUsage{Images: make(map[string]imageUse)}
+	data, err := os.ReadFile(g.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return usage, nil
+		}
+		return nil, fmt.Errorf("failed to read image usage: %w", err)
+	}
+	if err := json.Unmarshal(data, usage); err != nil {
+		return nil, fmt.Errorf("failed to parse image usage %s: %w", g.path, err)
+	}
+	if usage.Images == nil {
+		usage.Images = make(map[string]imageUse)
+	}
+	return usage, nil
+}
+
+func (g *ImageCollector) save(usage *imageUsage) error {
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode image usage: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(g.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write atomically via temp file
+	tmp := g.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write image usage: %w", err)
+	}
+	if err := os.Rename(tmp, g.path); err != nil {
+		os.Remove(tmp) // Clean up on failure
+		return fmt.Errorf("failed to save image usage: %w", err)
+	}
+	return nil
+}
+
+// markUsedImages records the images used by shed and sidecar containers as
+// used at now, and forgets images that no longer exist.
+func markUsedImages(usage *imageUsage, summaries []image.Summary, containers []container.Summary, now time.Time) {
+	tags := make(map[string][]string, len(summaries))
+	for _, s := range summaries {
+		tags[s.ID] = s.RepoTags
+	}
+	for id := range usage.Images {
+		if _, ok := tags[id]; !ok {
+			delete(usage.Images, id)
+		}
+	}
+
+	for _, ctr := range containers {
+		if ctr.Labels[config.LabelShed] != "true" && ctr.Labels[config.LabelServiceOf] == "" {
+			continue
+		}
+		refs, ok := tags[ctr.ImageID]
+		if !ok {
+			continue
+		}
+		if len(refs) == 0 {
+			// Keep the name the image had while tagged
+			refs = usage.Images[ctr.ImageID].Refs
+		}
+		usage.Images[ctr.ImageID] = imageUse{Refs: refs, LastUsed: now}
+	}
+}
+
+// collectableImages returns the images sheds have used that no container
+// uses now, no shed has used for maxAge, and keep doesn't protect.
+func collectableImages(usage *imageUsage, summaries []image.Summary, containers []container.Summary, keep func(ref string) bool, maxAge time.Duration, now time.Time) []config.PrunedResource {
+	used := make(map[string]bool, len(containers))
+	for _, ctr := range containers {
+		used[ctr.ImageID] = true
+	}
+
+	var found []config.PrunedResource
+	for _, s := range summaries {
+		use, ok := usage.Images[s.ID]
+		if !ok || used[s.ID] || now.Sub(use.LastUsed) < maxAge {
+			continue
+		}
+		kept := false
+		for _, ref := range s.RepoTags {
+			if keep(ref) {
+				kept = true
+				break
+			}
+		}
+		if kept {
+			continue
+		}
+
+		name := "untagged image"
+		if len(s.RepoTags) > 0 {
+			name = strings.Join(s.RepoTags, ", ")
+		} else if len(use.Refs) > 0 {
+			name = "untagged image (formerly " + strings.Join(use.Refs, ", ") + ")"
+		}
+		days := int(now.Sub(use.LastUsed).Hours() / 24)
+		found = append(found, config.PrunedResource{
+			Kind:   config.PruneImage,
+			Name:   s.ID,
+			Reason: fmt.Sprintf("%s unused by any shed for %d days", name, days),
+			Size:   s.Size,
+		})
+	}
+
+	sortPruned(found)
+	return found
+}
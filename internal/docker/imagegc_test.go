@@ -0,0 +1,88 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestMarkUsedImages(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-48 * time.Hour)
+	usage := &imageUsage{Images: map[string]imageUse{
+		"sha256:old":  {Refs: []string{"shed-go:1"}, LastUsed: earlier},
+		"sha256:gone": {Refs: []string{"shed-go:0"}, LastUsed: earlier},
+	}}
+	summaries := []image.Summary{
+		{ID: "sha256:base", RepoTags: []string{"shed-base:latest"}},
+		{ID: "sha256:old"},
+		{ID: "sha256:db", RepoTags: []string{"postgres:16"}},
+		{ID: "sha256:other", RepoTags: []string{"nginx:latest"}},
+	}
+	containers := []container.Summary{
+		{ImageID: "sha256:base", Labels: map[string]string{config.LabelShed: "true"}},
+		{ImageID: "sha256:old", Labels: map[string]string{config.LabelShed: "true"}},
+		{ImageID: "sha256:db", Labels: map[string]string{config.LabelServiceOf: "dev"}},
+		{ImageID: "sha256:other", Labels: map[string]string{"app": "web"}},
+	}
+
+	markUsedImages(usage, summaries, containers, now)
+	want := map[string]imageUse{
+		"sha256:base": {Refs: []string{"shed-base:latest"}, LastUsed: now},
+		"sha256:old":  {Refs: []string{"shed-go:1"}, LastUsed: now},
+		"sha256:db":   {Refs: []string{"postgres:16"}, LastUsed: now},
+	}
+	if !reflect.DeepEqual(usage.Images, want) {
+		t.Errorf("markUsedImages() =\n%+v\nwant\n%+v", usage.Images, want)
+	}
+}
+
+func TestCollectableImages(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	stale := now.Add(-10 * 24 * time.Hour)
+	usage := &imageUsage{Images: map[string]imageUse{
+		"sha256:stale":    {Refs: []string{"shed-go:1"}, LastUsed: stale},
+		"sha256:untagged": {Refs: []string{"shed-go:0"}, LastUsed: stale},
+		"sha256:recent":   {Refs: []string{"shed-go:2"}, LastUsed: now.Add(-24 * time.Hour)},
+		"sha256:running":  {Refs: []string{"shed-go:3"}, LastUsed: stale},
+		"sha256:kept":     {Refs: []string{"shed-base:latest"}, LastUsed: stale},
+	}}
+	summaries := []image.Summary{
+		{ID: "sha256:stale", RepoTags: []string{"shed-go:1"}, Size: 100},
+		{ID: "sha256:untagged", Size: 200},
+		{ID: "sha256:recent", RepoTags: []string{"shed-go:2"}, Size: 300},
+		{ID: "sha256:running", RepoTags: []string{"shed-go:3"}, Size: 400},
+		{ID: "sha256:kept", RepoTags: []string{"shed-base:latest"}, Size: 500},
+		{ID: "sha256:unknown", RepoTags: []string{"nginx:latest"}, Size: 600},
+	}
+	containers := []container.Summary{{ImageID: "sha256:running"}}
+	keep := config.ImageGCConfig{Keep: []string{"shed-base:*"}}.Keeps
+
+	got := collectableImages(usage, summaries, containers, keep, 7*24*time.Hour, now)
+	want := []config.PrunedResource{
+		{Kind: config.PruneImage, Name: "sha256:stale", Reason: "shed-go:1 unused by any shed for 10 days", Size: 100},
+		{Kind: config.PruneImage, Name: "sha256:untagged", Reason: "untagged image (formerly shed-go:0) unused by any shed for 10 days", Size: 200},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectableImages() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestWithDefaultTag(t *testing.T) {
+	tests := map[string]string{
+		"shed-base":                    "shed-base:latest",
+		"shed-base:v2":                 "shed-base:v2",
+		"registry.lan:5000/shed-base":  "registry.lan:5000/shed-base:latest",
+		"ghcr.io/acme/dev@sha256:abcd": "ghcr.io/acme/dev@sha256:abcd",
+	}
+	for ref, want := range tests {
+		if got := withDefaultTag(ref); got != want {
+			t.Errorf("withDefaultTag(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
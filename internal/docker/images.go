@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// ListImages returns the tagged local images selected by the server's
+// images config, plus the default image if it is present, sorted by name.
+func (c *Client) ListImages(ctx context.Context) ([]config.Image, error) {
+	opts := image.ListOptions{}
+	if label := c.config.Images.Label; label != "" {
+		opts.Filters = filters.NewArgs(filters.Arg("label", label))
+	}
+
+	summaries, err := c.docker.ImageList(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	images := imagesFromSummaries(summaries, c.config.Images, c.config.DefaultImage)
+
+	// The default image is offered even when the filters leave it out
+	if !containsImage(images, c.config.DefaultImage) {
+		if inspect, err := c.docker.ImageInspect(ctx, c.config.DefaultImage); err == nil {
+			created, _ := time.Parse(time.RFC3339Nano, inspect.Created)
+			images = append(images, config.Image{
+				Name:      c.config.DefaultImage,
+				ID:        inspect.ID,
+				Size:      inspect.Size,
+				CreatedAt: created,
+				Default:   true,
+			})
+			sortImages(images)
+		}
+	}
+
+	return images, nil
+}
+
+// imagesFromSummaries returns an entry for each tag of the images that
+// match cfg's prefixes.
+func imagesFromSummaries(summaries []image.Summary, cfg config.ImagesConfig, defaultImage string) []config.Image {
+	images := make([]config.Image, 0, len(summaries))
+	for _, s := range summaries {
+		for _, tag := range s.RepoTags {
+			if tag == "<none>:<none>" || !cfg.Matches(tag) {
+				continue
+			}
+			images = append(images, config.Image{
+				Name:      tag,
+				ID:        s.ID,
+				Size:      s.Size,
+				CreatedAt: time.Unix(s.Created, 0).UTC(),
+				Default:   tag == defaultImage,
+			})
+		}
+	}
+	sortImages(images)
+	return images
+}
+
+// containsImage reports whether images includes one named name.
+func containsImage(images []config.Image, name string) bool {
+	for _, img := range images {
+		if img.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortImages(images []config.Image) {
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Name < images[j].Name
+	})
+}
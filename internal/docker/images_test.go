@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestImagesFromSummaries(t *testing.T) {
+	summaries := []image.Summary{
+		{ID: "sha256:1", RepoTags: []string{"shed-go:1.24", "shed-go:latest"}, Size: 100, Created: 1700000000},
+		{ID: "sha256:2", RepoTags: []string{"postgres:16"}, Size: 200},
+		{ID: "sha256:3", RepoTags: []string{"<none>:<none>"}},
+		{ID: "sha256:4", RepoTags: []string{"shed-base:latest"}, Size: 300},
+	}
+
+	images := imagesFromSummaries(summaries, config.ImagesConfig{Prefixes: []string{"shed-"}}, "shed-base:latest")
+
+	var names []string
+	for _, img := range images {
+		names = append(names, img.Name)
+	}
+	want := []string{"shed-base:latest", "shed-go:1.24", "shed-go:latest"}
+	if len(names) != len(want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("names = %v, want %v", names, want)
+			break
+		}
+	}
+	if !images[0].Default || images[1].Default {
+		t.Errorf("only shed-base:latest should be the default: %+v", images)
+	}
+	if images[1].CreatedAt.Unix() != 1700000000 {
+		t.Errorf("CreatedAt = %v, want unix 1700000000", images[1].CreatedAt)
+	}
+
+	if got := imagesFromSummaries(summaries, config.ImagesConfig{}, ""); len(got) != 4 {
+		t.Errorf("without prefixes got %d images, want 4", len(got))
+	}
+}
@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/state"
+)
+
+// labelsFile is the legacy state file for labels changed after creation.
+// Container labels are fixed at creation, so a shed's stored labels
+// replace those on its container until it is rebuilt.
+const labelsFile = "labels.json"
+
+// mapStore holds a map of strings for each shed, such as labels changed
+// after creation, in a bucket of the server's state store.
+type mapStore struct {
+	db     *state.Store
+	bucket string
+	what   string
+}
+
+// loadMaps returns the map store for file, importing the file from dir if
+// an older server left one there. what names the maps in log messages.
+func loadMaps(db *state.Store, dir, file, what string) *mapStore {
+	s := &mapStore{db: db, bucket: stateBucket(file), what: what}
+	importState(db, s.bucket, dir, file, what)
+	return s
+}
+
+// get returns a shed's stored map, and whether it has one stored.
+func (s *mapStore) get(name string) (map[string]string, bool) {
+	var m map[string]string
+	ok, err := s.db.Get(s.bucket, name, &m)
+	if err != nil {
+		slog.Warn("Failed to read state", "what", s.what, "shed", name, "err", err)
+	}
+	if ok && m == nil {
+		m = map[string]string{}
+	}
+	return m, ok
+}
+
+// all returns every shed's map.
+func (s *mapStore) all() map[string]map[string]string {
+	all := make(map[string]map[string]string)
+	raw, err := s.db.All(s.bucket)
+	if err != nil {
+		slog.Warn("Failed to read state", "what", s.what, "err", err)
+		return all
+	}
+	for name, data := range raw {
+		var m map[string]string
+		if err := json.Unmarshal(data, &m); err != nil {
+			slog.Warn("Failed to parse state", "what", s.what, "shed", name, "err", err)
+			continue
+		}
+		all[name] = m
+	}
+	return all
+}
+
+// set stores a shed's complete map.
+func (s *mapStore) set(name string, m map[string]string) error {
+	if m == nil {
+		m = map[string]string{}
+	}
+	return s.db.Put(s.bucket, name, m)
+}
+
+// clear forgets a shed's stored map.
+func (s *mapStore) clear(name string) {
+	if err := s.db.Delete(s.bucket, name); err != nil {
+		slog.Warn("Failed to save state", "what", s.what, "shed", name, "err", err)
+	}
+}
+
+// userLabels returns the user-defined labels among a container's labels,
+// or nil if it has none.
+func userLabels(labels map[string]string) map[string]string {
+	var user map[string]string
+	for k, v := range labels {
+		if key, ok := strings.CutPrefix(k, config.LabelUserPrefix); ok {
+			if user == nil {
+				user = make(map[string]string)
+			}
+			user[key] = v
+		}
+	}
+	return user
+}
+
+// shedLabels returns a shed's current user-defined labels given its
+// container labels.
+func (c *Client) shedLabels(name string, labels map[string]string) map[string]string {
+	if stored, ok := c.labels.get(name); ok {
+		if len(stored) == 0 {
+			return nil
+		}
+		return stored
+	}
+	return userLabels(labels)
+}
+
+// UpdateLabels sets and removes user-defined labels on a shed, returning
+// the updated shed.
+func (c *Client) UpdateLabels(ctx context.Context, name string, req config.UpdateLabelsRequest) (*config.Shed, error) {
+	// Hold the shed so concurrent updates each see the other's changes
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := maps.Clone(shed.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	maps.Copy(labels, req.Set)
+	for _, key := range req.Remove {
+		delete(labels, key)
+	}
+
+	if err := c.labels.set(name, labels); err != nil {
+		return nil, fmt.Errorf("failed to save labels: %w", err)
+	}
+
+	shed.Labels = nil
+	if len(labels) > 0 {
+		shed.Labels = labels
+	}
+	return shed, nil
+}
@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestUserLabels(t *testing.T) {
+	labels := map[string]string{
+		config.LabelShed:                "true",
+		config.LabelShedName:            "dev",
+		config.LabelUserPrefix + "team": "payments",
+		config.LabelUserPrefix + "env":  "dev",
+	}
+	want := map[string]string{"team": "payments", "env": "dev"}
+	if got := userLabels(labels); !maps.Equal(got, want) {
+		t.Errorf("userLabels() = %v, want %v", got, want)
+	}
+	if got := userLabels(map[string]string{config.LabelShed: "true"}); got != nil {
+		t.Errorf("userLabels() = %v, want nil", got)
+	}
+}
+
+func TestLabelStoreOverridesContainer(t *testing.T) {
+	dir := t.TempDir()
+	db := openState(t, dir)
+	c := &Client{labels: loadMaps(db, dir, labelsFile, "shed labels")}
+	container := map[string]string{config.LabelUserPrefix + "team": "payments"}
+
+	if got := c.shedLabels("dev", container); got["team"] != "payments" {
+		t.Errorf("shedLabels() = %v, want container labels", got)
+	}
+
+	if err := c.labels.set("dev", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.shedLabels("dev", container); got != nil {
+		t.Errorf("shedLabels() = %v, want all removed", got)
+	}
+
+	if err := c.labels.set("dev", map[string]string{"team": "billing"}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	reloaded := &Client{labels: loadMaps(openState(t, dir), dir, labelsFile, "shed labels")}
+	if got := reloaded.shedLabels("dev", container); got["team"] != "billing" {
+		t.Errorf("shedLabels() after reload = %v, want stored labels", got)
+	}
+
+	reloaded.labels.clear("dev")
+	if got := reloaded.shedLabels("dev", container); got["team"] != "payments" {
+		t.Errorf("shedLabels() after clear = %v, want container labels", got)
+	}
+}
+
+func TestUpdateLabelsConcurrently(t *testing.T) {
+	c := newEngineClient(t, &checkpointEngine{running: true})
+
+	// Each update adds its own label; none may be lost to another
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := config.UpdateLabelsRequest{Set: map[string]string{fmt.Sprintf("k%d", i): "v"}}
+			if _, err := c.UpdateLabels(context.Background(), "dev", req); err != nil {
+				t.Errorf("UpdateLabels() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	shed, err := c.GetShed(context.Background(), "dev")
+	if err != nil {
+		t.Fatalf("GetShed() error = %v", err)
+	}
+	if len(shed.Labels) != 10 {
+		t.Errorf("labels = %v, want all 10", shed.Labels)
+	}
+}
@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// provisionLocaleScript points /etc/localtime at the timezone and generates
+// the locale if the image can. Images without zoneinfo or locale-gen still
+// get TZ and LANG from the environment, which most programs honor.
+const provisionLocaleScript = `
+tz="$1"; locale="$2"
+if [ -n "$tz" ] && [ -f "/usr/share/zoneinfo/$tz" ]; then
+	ln -sf "/usr/share/zoneinfo/$tz" /etc/localtime && echo "$tz" > /etc/timezone
+fi
+if [ -n "$locale" ] && command -v locale-gen >/dev/null 2>&1; then
+	locale-gen "$locale" >/dev/null
+fi
+`
+
+// localeEnv returns the environment variables that apply a timezone and
+// locale inside a shed. Empty values are omitted.
+func localeEnv(timezone, locale string) []string {
+	var env []string
+	if timezone != "" {
+		env = append(env, "TZ="+timezone)
+	}
+	if locale != "" {
+		env = append(env, "LANG="+locale)
+	}
+	return env
+}
+
+// provisionLocale configures the timezone and locale inside a running
+// container so tools that ignore TZ and LANG pick them up too.
+func (c *Client) provisionLocale(ctx context.Context, containerID, timezone, locale string) error {
+	// C and POSIX are built in and never need generating
+	if locale == "C" || locale == "POSIX" || strings.HasPrefix(locale, "C.") {
+		locale = ""
+	}
+	if timezone == "" && locale == "" {
+		return nil
+	}
+
+	result, err := c.execOutput(ctx, containerID, []string{"sh", "-c", provisionLocaleScript, "sh", timezone, locale}, nil)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("locale setup exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// idempotencyKeyTTL is how long a create's idempotency key is remembered.
+const idempotencyKeyTTL = 24 * time.Hour
+
+// shedLocks serializes operations on the same shed name, so concurrent
+// creates and deletes can't interleave and leave a volume without its
+// container. Operations on different sheds don't wait on each other.
+type shedLocks struct {
+	mu    sync.Mutex
+	locks map[string]*shedLock
+}
+
+// shedLock is held by at most one operation on a shed at a time. refs
+// counts the operations holding or waiting for it, so it can be dropped
+// once none are.
+type shedLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// lock waits until no other operation holds name, then holds it until
+// unlock is called. It gives up if ctx is done first.
+func (l *shedLocks) lock(ctx context.Context, name string) (unlock func(), err error) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*shedLock)
+	}
+	sl, ok := l.locks[name]
+	if !ok {
+		sl = &shedLock{ch: make(chan struct{}, 1)}
+		l.locks[name] = sl
+	}
+	sl.refs++
+	l.mu.Unlock()
+
+	select {
+	case sl.ch <- struct{}{}:
+	case <-ctx.Done():
+		l.release(name, sl)
+		return nil, fmt.Errorf("gave up waiting for another operation on shed %q: %w", name, ctx.Err())
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-sl.ch
+			l.release(name, sl)
+		})
+	}, nil
+}
+
+// release drops a reference to a shed's lock, forgetting the lock once
+// nothing holds or waits for it.
+func (l *shedLocks) release(name string, sl *shedLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sl.refs--
+	if sl.refs == 0 {
+		delete(l.locks, name)
+	}
+}
+
+// idempotencyKeys remembers which shed each create's idempotency key
+// created, so a retried create returns the shed instead of failing
+// because it already exists. Keys are kept in memory for
+// idempotencyKeyTTL.
+type idempotencyKeys struct {
+	mu   sync.Mutex
+	keys map[string]idempotentCreate
+	now  func() time.Time
+}
+
+// idempotentCreate is a create made with an idempotency key.
+type idempotentCreate struct {
+	shed string
+	at   time.Time
+}
+
+// get returns the shed created with key, if it is remembered.
+func (k *idempotencyKeys) get(key string) (string, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.expire()
+	c, ok := k.keys[key]
+	return c.shed, ok
+}
+
+// set remembers that key created shed.
+func (k *idempotencyKeys) set(key, shed string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys == nil {
+		k.keys = make(map[string]idempotentCreate)
+	}
+	k.expire()
+	k.keys[key] = idempotentCreate{shed: shed, at: k.clock()}
+}
+
+// expire forgets keys older than idempotencyKeyTTL. Callers must hold k.mu.
+func (k *idempotencyKeys) expire() {
+	cutoff := k.clock().Add(-idempotencyKeyTTL)
+	for key, c := range k.keys {
+		if c.at.Before(cutoff) {
+			delete(k.keys, key)
+		}
+	}
+}
+
+// clock returns the current time.
+func (k *idempotencyKeys) clock() time.Time {
+	if k.now != nil {
+		return k.now()
+	}
+	return time.Now()
+}
+
+// lockShed holds name until the returned function is called. See
+// shedLocks.lock.
+func (c *Client) lockShed(ctx context.Context, name string) (func(), error) {
+	return c.locks.lock(ctx, name)
+}
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShedLocksSerializeSameName(t *testing.T) {
+	var locks shedLocks
+	ctx := context.Background()
+
+	unlock, err := locks.lock(ctx, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another shed isn't held up
+	other, err := locks.lock(ctx, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock, err := locks.lock(ctx, "api")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		close(acquired)
+		unlock()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while the first was held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlock()
+	unlock() // Safe to call twice
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second lock not acquired after unlock")
+	}
+
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+	if len(locks.locks) != 0 {
+		t.Errorf("locks = %v, want none left", locks.locks)
+	}
+}
+
+func TestShedLocksGiveUpOnContext(t *testing.T) {
+	var locks shedLocks
+	unlock, err := locks.lock(context.Background(), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := locks.lock(ctx, "api"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("lock() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestShedLocksConcurrent(t *testing.T) {
+	var locks shedLocks
+	var wg sync.WaitGroup
+	holders := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := locks.lock(context.Background(), "api")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer unlock()
+			holders++
+			if holders != 1 {
+				t.Errorf("%d holders of the lock", holders)
+			}
+			holders--
+		}()
+	}
+	wg.Wait()
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	now := time.Date(2026, 4, 16, 12, 0, 0, 0, time.UTC)
+	keys := idempotencyKeys{now: func() time.Time { return now }}
+
+	if _, ok := keys.get("k1"); ok {
+		t.Fatal("get(k1) found a key that was never set")
+	}
+	keys.set("k1", "api")
+	if shed, ok := keys.get("k1"); !ok || shed != "api" {
+		t.Errorf("get(k1) = %q, %v, want api", shed, ok)
+	}
+
+	now = now.Add(idempotencyKeyTTL + time.Minute)
+	if _, ok := keys.get("k1"); ok {
+		t.Error("get(k1) found a key older than the TTL")
+	}
+}
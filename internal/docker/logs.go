@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// ShedLogs returns a shed's container output with stdout and stderr
+// interleaved. With opts.Follow the stream stays open until ctx is done or
+// the container stops. The caller must close the returned reader.
+func (c *Client) ShedLogs(ctx context.Context, name string, opts config.LogOptions) (io.ReadCloser, error) {
+	tail := "all"
+	if opts.Tail >= 0 {
+		tail = strconv.Itoa(opts.Tail)
+	}
+
+	logs, err := c.docker.ContainerLogs(ctx, config.ContainerName(name), container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Tail:       tail,
+	})
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, shedNotFound(name)
+		}
+		return nil, fmt.Errorf("failed to get logs: %w", err)
+	}
+
+	// Shed containers run without a TTY, so the stream is multiplexed
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, logs)
+		logs.Close()
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
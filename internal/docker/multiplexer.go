@@ -0,0 +1,234 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// multiplexer runs the persistent terminal sessions of a container.
+type multiplexer interface {
+	// name returns the multiplexer's name, such as config.MultiplexerTmux.
+	name() string
+
+	// listSessions returns the running sessions, most recently active first
+	// if the multiplexer tracks activity.
+	listSessions(ctx context.Context) ([]config.Session, error)
+
+	// hasSession reports whether a session with exactly this name is running.
+	hasSession(ctx context.Context, session string) (bool, error)
+
+	// newSession starts a detached session in the workspace with env,
+	// running command if set or else a shell.
+	newSession(ctx context.Context, session, command string, env []string) error
+
+	// renameSession renames a running session.
+	renameSession(ctx context.Context, session, newName string) error
+
+	// killSession ends a running session and everything running in it.
+	killSession(ctx context.Context, session string) error
+
+	// attachCommand returns the command that attaches a terminal to a
+	// session, creating it if it doesn't exist.
+	attachCommand(session string) []string
+}
+
+// detectMultiplexerScript prints the first multiplexer installed in a
+// container, in order of preference.
+const detectMultiplexerScript = `for m in tmux zellij; do command -v "$m" >/dev/null 2>&1 && echo "$m" && exit; done; true`
+
+// multiplexer returns the multiplexer of a running container, or nil if it
+// has none. Detection is cached for the life of the container.
+func (c *Client) multiplexer(ctx context.Context, containerID string) (multiplexer, error) {
+	kind, ok := c.multiplexers.Load(containerID)
+	if !ok {
+		result, err := c.execOutput(ctx, containerID, []string{"sh", "-c", detectMultiplexerScript}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect terminal multiplexer: %w", err)
+		}
+		kind = strings.TrimSpace(result.Stdout)
+		// A shed without one may have one installed later
+		if kind != "" {
+			c.multiplexers.Store(containerID, kind)
+		}
+	}
+
+	switch kind {
+	case config.MultiplexerTmux:
+		return &tmux{client: c, containerID: containerID}, nil
+	case config.MultiplexerZellij:
+		return &zellij{client: c, containerID: containerID}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// requireMultiplexer returns the multiplexer of a running shed, failing if
+// its image has none.
+func (c *Client) requireMultiplexer(ctx context.Context, shed *config.Shed) (multiplexer, error) {
+	mux, err := c.multiplexer(ctx, shed.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+	if mux == nil {
+		return nil, fmt.Errorf("sessions are not supported in shed %q: its image has neither tmux nor zellij", shed.Name)
+	}
+	return mux, nil
+}
+
+// run runs a multiplexer command in a container, failing with its stderr if
+// it exits non-zero.
+func (c *Client) run(ctx context.Context, containerID, action string, cmd []string, env []string) (*execResult, error) {
+	result, err := c.execOutput(ctx, containerID, cmd, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s: %w", action, err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to %s: %s", action, strings.TrimSpace(result.Stderr))
+	}
+	return result, nil
+}
+
+// tmux is the multiplexer of containers with tmux installed.
+type tmux struct {
+	client      *Client
+	containerID string
+}
+
+func (t *tmux) name() string { return config.MultiplexerTmux }
+
+func (t *tmux) listSessions(ctx context.Context) ([]config.Session, error) {
+	result, err := t.client.execOutput(ctx, t.containerID, []string{"tmux", "list-sessions", "-F", tmuxSessionFormat}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if result.ExitCode != 0 {
+		// tmux exits non-zero when no server is running
+		return []config.Session{}, nil
+	}
+
+	sessions := parseTmuxSessions(result.Stdout)
+
+	// Pane listing is best effort; sessions are still useful without it
+	paneResult, err := t.client.execOutput(ctx, t.containerID, []string{"tmux", "list-panes", "-a", "-F", tmuxPaneFormat}, nil)
+	if err == nil && paneResult.ExitCode == 0 {
+		panes := parseTmuxPanes(paneResult.Stdout)
+		for i := range sessions {
+			sessions[i].Panes = panes[sessions[i].Name]
+		}
+	}
+
+	return sessions, nil
+}
+
+func (t *tmux) hasSession(ctx context.Context, session string) (bool, error) {
+	// A leading = makes tmux match the name exactly rather than as a prefix
+	result, err := t.client.execOutput(ctx, t.containerID, []string{"tmux", "has-session", "-t", "=" + session}, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+func (t *tmux) newSession(ctx context.Context, session, command string, env []string) error {
+	cmd := []string{"tmux", "new-session", "-d", "-s", session, "-c", config.WorkspacePath}
+	if command != "" {
+		cmd = append(cmd, command)
+	}
+	_, err := t.client.run(ctx, t.containerID, "create session", cmd, env)
+	return err
+}
+
+func (t *tmux) renameSession(ctx context.Context, session, newName string) error {
+	_, err := t.client.run(ctx, t.containerID, "rename session", []string{"tmux", "rename-session", "-t", "=" + session, newName}, nil)
+	return err
+}
+
+func (t *tmux) killSession(ctx context.Context, session string) error {
+	_, err := t.client.run(ctx, t.containerID, "kill session", []string{"tmux", "kill-session", "-t", "=" + session}, nil)
+	return err
+}
+
+func (t *tmux) attachCommand(session string) []string {
+	return []string{"tmux", "new-session", "-A", "-s", session}
+}
+
+// zellij is the multiplexer of containers with zellij but not tmux. It
+// doesn't report attached clients, windows, or activity, so its sessions
+// have only names and never hold off the idle reaper.
+type zellij struct {
+	client      *Client
+	containerID string
+}
+
+func (z *zellij) name() string { return config.MultiplexerZellij }
+
+func (z *zellij) listSessions(ctx context.Context) ([]config.Session, error) {
+	result, err := z.client.execOutput(ctx, z.containerID, []string{"zellij", "list-sessions", "--no-formatting"}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if result.ExitCode != 0 {
+		// zellij exits non-zero when there are no sessions
+		return []config.Session{}, nil
+	}
+	return parseZellijSessions(result.Stdout), nil
+}
+
+func (z *zellij) hasSession(ctx context.Context, session string) (bool, error) {
+	sessions, err := z.listSessions(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range sessions {
+		if s.Name == session {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (z *zellij) newSession(ctx context.Context, session, command string, env []string) error {
+	if _, err := z.client.run(ctx, z.containerID, "create session", []string{"zellij", "attach", "--create-background", session}, env); err != nil {
+		return err
+	}
+	if command == "" {
+		return nil
+	}
+	// Commands run in a pane of their own that closes when they exit
+	_, err := z.client.run(ctx, z.containerID, "create session", []string{"zellij", "--session", session, "run", "--close-on-exit", "--cwd", config.WorkspacePath, "--", "sh", "-c", command}, env)
+	return err
+}
+
+func (z *zellij) renameSession(ctx context.Context, session, newName string) error {
+	_, err := z.client.run(ctx, z.containerID, "rename session", []string{"zellij", "--session", session, "action", "rename-session", newName}, nil)
+	return err
+}
+
+func (z *zellij) killSession(ctx context.Context, session string) error {
+	_, err := z.client.run(ctx, z.containerID, "kill session", []string{"zellij", "kill-session", session}, nil)
+	return err
+}
+
+func (z *zellij) attachCommand(session string) []string {
+	return []string{"zellij", "attach", "--create", session}
+}
+
+// parseZellijSessions parses list-sessions output produced with
+// --no-formatting, one session per line as its name followed by details.
+// Exited sessions kept for resurrection are skipped.
+func parseZellijSessions(output string) []config.Session {
+	sessions := []config.Session{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.Contains(line, "EXITED") {
+			continue
+		}
+		sessions = append(sessions, config.Session{Name: fields[0]})
+	}
+
+	return sessions
+}
@@ -0,0 +1,236 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// ShedAddress returns a running shed's IP address and the gateway of its
+// network, which is the Docker host as seen from inside the shed.
+func (c *Client) ShedAddress(ctx context.Context, name string) (ip, gateway string, err error) {
+	ctr, err := c.docker.ContainerInspect(ctx, config.ContainerName(name))
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return "", "", shedNotFound(name)
+		}
+		return "", "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if ctr.NetworkSettings == nil {
+		return "", "", fmt.Errorf("shed %q has no network address", name)
+	}
+
+	// Prefer the default bridge, then any other network in a stable order
+	networks := ctr.NetworkSettings.Networks
+	names := make([]string, 0, len(networks))
+	for n := range networks {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if _, ok := networks["bridge"]; ok {
+		names = append([]string{"bridge"}, names...)
+	}
+
+	for _, n := range names {
+		ep := networks[n]
+		if ep != nil && ep.IPAddress != "" {
+			return ep.IPAddress, ep.Gateway, nil
+		}
+	}
+	return "", "", fmt.Errorf("shed %q has no network address", name)
+}
+
+// ensureSharedNetwork creates the network shared sheds are attached to
+// unless it already exists.
+func (c *Client) ensureSharedNetwork(ctx context.Context) error {
+	return c.ensureNetwork(ctx, config.SharedNetworkName, map[string]string{config.LabelShed: "true"})
+}
+
+// ensureShedNetwork creates a shed's dedicated network unless it already
+// exists, as it does while the shed is rebuilt.
+func (c *Client) ensureShedNetwork(ctx context.Context, name string) error {
+	return c.ensureNetwork(ctx, config.ShedNetworkName(name), map[string]string{
+		config.LabelShed:     "true",
+		config.LabelShedName: name,
+	})
+}
+
+// ensureNetwork creates a bridge network with labels unless it already
+// exists.
+func (c *Client) ensureNetwork(ctx context.Context, name string, labels map[string]string) error {
+	if _, err := c.docker.NetworkInspect(ctx, name, network.InspectOptions{}); err == nil {
+		return nil
+	} else if !cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to inspect network %s: %w", name, err)
+	}
+
+	_, err := c.docker.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver: "bridge",
+		Labels: labels,
+	})
+	// Another shed may have created it in the meantime
+	if err != nil && !cerrdefs.IsConflict(err) {
+		if poolExhausted(err) {
+			return fmt.Errorf("no address pool left for network %s: Docker's default pools hold about 30 networks; delete unused sheds or configure default-address-pools with smaller subnets", name)
+		}
+		return fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+	return nil
+}
+
+// poolExhausted reports whether a network create failed because the
+// engine has no subnet left to give it.
+func poolExhausted(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "non-overlapping IPv4 address pool") || // Docker
+		strings.Contains(msg, "address pools have been fully subnetted") || // Docker 27+
+		strings.Contains(msg, "could not find free subnet") // Podman
+}
+
+// removeShedNetwork removes a shed's dedicated network, if it has one.
+func (c *Client) removeShedNetwork(ctx context.Context, name string) error {
+	if err := c.docker.NetworkRemove(ctx, config.ShedNetworkName(name)); err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// sharedEndpoint returns the settings attaching a shed to the shared
+// network, reachable by its name alone or under SharedNetworkDomain.
+func sharedEndpoint(name string) *network.NetworkingConfig {
+	return &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			config.SharedNetworkName: {
+				Aliases: []string{name, config.SharedNetworkHostname(name)},
+			},
+		},
+	}
+}
+
+// dedicatedEndpoint returns the settings attaching a shed to its dedicated
+// network, reachable by its name.
+func dedicatedEndpoint(name string) *network.NetworkingConfig {
+	return &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			config.ShedNetworkName(name): {
+				Aliases: []string{name},
+			},
+		},
+	}
+}
+
+// shedIPAddress returns a shed's address on the network its mode puts it
+// on, or empty if it isn't attached, as when it isn't running.
+func shedIPAddress(name, mode string, networks map[string]*network.EndpointSettings) string {
+	primary := "bridge"
+	switch mode {
+	case config.NetworkShared:
+		primary = config.SharedNetworkName
+	case config.NetworkDedicated:
+		primary = config.ShedNetworkName(name)
+	}
+	if ep := networks[primary]; ep != nil {
+		return ep.IPAddress
+	}
+	return ""
+}
+
+// portsFromLabels returns a shed's published ports as given at creation,
+// or nil if it has none.
+func portsFromLabels(labels map[string]string) []string {
+	if v := labels[config.LabelPorts]; v != "" {
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
+// publishedPorts returns the ports a shed reports: the bindings Docker
+// made while it has any, and otherwise the ports given at creation.
+func publishedPorts(labels map[string]string, bound []string) []string {
+	if len(bound) > 0 {
+		return bound
+	}
+	return portsFromLabels(labels)
+}
+
+// portsFromBindings returns a container's host bindings as port mappings,
+// e.g. "127.0.0.1:8080:80/tcp", sorted.
+func portsFromBindings(bindings nat.PortMap) []string {
+	var ports []string
+	for port, binds := range bindings {
+		for _, b := range binds {
+			if b.HostPort != "" {
+				ports = append(ports, formatPortMapping(b.HostIP, b.HostPort, port.Port(), port.Proto()))
+			}
+		}
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports)
+}
+
+// portsFromSummary returns the host bindings in a container listing as
+// port mappings, sorted.
+func portsFromSummary(summary []container.Port) []string {
+	var ports []string
+	for _, p := range summary {
+		if p.PublicPort != 0 {
+			ports = append(ports, formatPortMapping(p.IP, strconv.Itoa(int(p.PublicPort)), strconv.Itoa(int(p.PrivatePort)), p.Type))
+		}
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports)
+}
+
+// formatPortMapping writes a binding the way ParsePortMapping reads it.
+func formatPortMapping(hostIP, hostPort, containerPort, proto string) string {
+	host := hostPort
+	if hostIP != "" {
+		host = net.JoinHostPort(hostIP, hostPort)
+	}
+	return host + ":" + containerPort + "/" + proto
+}
+
+// httpPortFromLabels returns the port a shed's preview URL is sent to, or
+// zero if it has none.
+func httpPortFromLabels(labels map[string]string) int {
+	port, _ := strconv.Atoi(labels[config.LabelHTTPPort])
+	return port
+}
+
+// portBindings returns the exposed ports and host bindings for a shed's
+// published ports. Ports without a host address are bound to defaultIP.
+func portBindings(specs []string, defaultIP string) (nat.PortSet, nat.PortMap, error) {
+	exposed := make(nat.PortSet, len(specs))
+	bindings := make(nat.PortMap, len(specs))
+	for _, spec := range specs {
+		p, err := config.ParsePortMapping(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		port, err := nat.NewPort(p.Protocol, strconv.Itoa(p.ContainerPort))
+		if err != nil {
+			return nil, nil, err
+		}
+		exposed[port] = struct{}{}
+		hostIP := p.HostIP
+		if hostIP == "" {
+			hostIP = defaultIP
+		}
+		bindings[port] = append(bindings[port], nat.PortBinding{
+			HostIP:   hostIP,
+			HostPort: strconv.Itoa(p.HostPort),
+		})
+	}
+	return exposed, bindings, nil
+}
@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestPortBindings(t *testing.T) {
+	exposed, bindings, err := portBindings([]string{"8080:80", "0.0.0.0:8443:80", "5353:53/udp"}, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("portBindings() failed: %v", err)
+	}
+
+	wantExposed := nat.PortSet{"80/tcp": {}, "53/udp": {}}
+	if !reflect.DeepEqual(exposed, wantExposed) {
+		t.Errorf("exposed = %v, want %v", exposed, wantExposed)
+	}
+	wantBindings := nat.PortMap{
+		"80/tcp": {{HostIP: "127.0.0.1", HostPort: "8080"}, {HostIP: "0.0.0.0", HostPort: "8443"}},
+		"53/udp": {{HostIP: "127.0.0.1", HostPort: "5353"}},
+	}
+	if !reflect.DeepEqual(bindings, wantBindings) {
+		t.Errorf("bindings = %v, want %v", bindings, wantBindings)
+	}
+}
+
+func TestPublishedPorts(t *testing.T) {
+	labels := map[string]string{config.LabelPorts: "8080:80,5353:53/udp"}
+
+	bindings := nat.PortMap{
+		"80/tcp": {{HostIP: "127.0.0.1", HostPort: "8080"}, {HostIP: "::1", HostPort: "8080"}},
+		"53/udp": {{HostIP: "127.0.0.1", HostPort: "5353"}},
+		"22/tcp": nil,
+	}
+	want := []string{"127.0.0.1:5353:53/udp", "127.0.0.1:8080:80/tcp", "[::1]:8080:80/tcp"}
+	if got := publishedPorts(labels, portsFromBindings(bindings)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ports from bindings = %q, want %q", got, want)
+	}
+
+	summary := []container.Port{
+		{IP: "127.0.0.1", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+		{IP: "127.0.0.1", PrivatePort: 53, PublicPort: 5353, Type: "udp"},
+		{PrivatePort: 22, Type: "tcp"},
+	}
+	want = []string{"127.0.0.1:5353:53/udp", "127.0.0.1:8080:80/tcp"}
+	if got := publishedPorts(labels, portsFromSummary(summary)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ports from summary = %q, want %q", got, want)
+	}
+
+	// Every reported port parses back as a mapping
+	for _, spec := range portsFromBindings(bindings) {
+		if _, err := config.ParsePortMapping(spec); err != nil {
+			t.Errorf("ParsePortMapping(%q) error = %v", spec, err)
+		}
+	}
+
+	// A stopped shed has no bindings and reports the ports as given
+	if got, want := publishedPorts(labels, nil), []string{"8080:80", "5353:53/udp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ports without bindings = %q, want %q", got, want)
+	}
+}
+
+func TestEnsureNetworkPoolExhausted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/networks/create") {
+			http.Error(w, `{"message":"could not find an available, non-overlapping IPv4 address pool among the defaults to assign to the network"}`, http.StatusForbidden)
+			return
+		}
+		http.Error(w, `{"message":"network not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+	docker, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatalf("NewClientWithOpts() error = %v", err)
+	}
+	defer docker.Close()
+	c := &Client{docker: docker}
+
+	err = c.ensureShedNetwork(context.Background(), "dev")
+	if err == nil || !strings.HasPrefix(err.Error(), "no address pool left for network shed-dev-net") {
+		t.Errorf("ensureShedNetwork() error = %v, want no address pool left", err)
+	}
+}
+
+func TestShedIPAddress(t *testing.T) {
+	networks := map[string]*network.EndpointSettings{
+		"bridge":                      {IPAddress: "172.17.0.5"},
+		config.SharedNetworkName:      {IPAddress: "172.20.0.3"},
+		config.ShedNetworkName("dev"): {IPAddress: "172.21.0.2"},
+	}
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", "172.17.0.5"},
+		{config.NetworkShared, "172.20.0.3"},
+		{config.NetworkDedicated, "172.21.0.2"},
+	}
+	for _, tt := range tests {
+		if got := shedIPAddress("dev", tt.mode, networks); got != tt.want {
+			t.Errorf("shedIPAddress(%q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+	if got := shedIPAddress("dev", config.NetworkDedicated, nil); got != "" {
+		t.Errorf("shedIPAddress() without networks = %q, want empty", got)
+	}
+}
@@ -0,0 +1,88 @@
+package docker
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/charliek/shed/internal/state"
+)
+
+// State files for per-shed notes, as written to the state dir by older
+// servers. Each is imported into the state store on startup.
+const (
+	setupErrorsFile = "setup_errors.json"
+	stopReasonsFile = "stop_reasons.json"
+)
+
+// State store buckets for notes that older servers didn't keep.
+const (
+	ownersBucket   = "owners"
+	activityBucket = "activity"
+)
+
+// noteStore records a short message per shed, such as a setup step that
+// failed after creation or why a shed was stopped. Container labels are
+// fixed at creation, so notes are kept in a bucket of the server's state
+// store instead.
+type noteStore struct {
+	db     *state.Store
+	bucket string
+	what   string
+}
+
+// newNotes returns a note store kept in bucket. what names the notes in
+// log messages.
+func newNotes(db *state.Store, bucket, what string) *noteStore {
+	return &noteStore{db: db, bucket: bucket, what: what}
+}
+
+// loadNotes returns the note store for file, importing the file from dir
+// if an older server left one there.
+func loadNotes(db *state.Store, dir, file, what string) *noteStore {
+	s := newNotes(db, stateBucket(file), what)
+	importState(db, s.bucket, dir, file, what)
+	return s
+}
+
+// get returns the note recorded for a shed, if any.
+func (s *noteStore) get(name string) string {
+	var msg string
+	if _, err := s.db.Get(s.bucket, name, &msg); err != nil {
+		slog.Warn("Failed to read state", "what", s.what, "shed", name, "err", err)
+	}
+	return msg
+}
+
+// set records a note for a shed. Failures are only logged since notes are
+// informational.
+func (s *noteStore) set(name, msg string) {
+	if err := s.db.Put(s.bucket, name, msg); err != nil {
+		slog.Warn("Failed to save state", "what", s.what, "shed", name, "err", err)
+	}
+}
+
+// clear forgets any note for a shed.
+func (s *noteStore) clear(name string) {
+	if err := s.db.Delete(s.bucket, name); err != nil {
+		slog.Warn("Failed to save state", "what", s.what, "shed", name, "err", err)
+	}
+}
+
+// stateBucket returns the state store bucket for a legacy state file.
+func stateBucket(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file))
+}
+
+// importState moves a legacy state file in dir into bucket. Failures are
+// only logged, leaving the file to be imported on the next start.
+func importState(db *state.Store, bucket, dir, file, what string) {
+	n, err := db.Import(bucket, filepath.Join(dir, file))
+	if err != nil {
+		slog.Warn("Failed to import state", "what", what, "err", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("Imported state", "what", what, "sheds", n)
+	}
+}
@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charliek/shed/internal/state"
+)
+
+// openState opens a state store in dir that is closed when the test ends.
+func openState(t *testing.T, dir string) *state.Store {
+	t.Helper()
+	db, err := state.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestNoteStorePersists(t *testing.T) {
+	dir := t.TempDir()
+
+	db := openState(t, dir)
+	s := loadNotes(db, dir, setupErrorsFile, "setup errors")
+	s.set("broken", "failed to clone repository: exit code 128")
+	s.set("fixed", "failed to add worktree: exit code 1")
+	s.clear("fixed")
+	db.Close()
+
+	db = openState(t, dir)
+	reloaded := loadNotes(db, dir, setupErrorsFile, "setup errors")
+	if got := reloaded.get("broken"); got != "failed to clone repository: exit code 128" {
+		t.Errorf("get(broken) = %q", got)
+	}
+	if got := reloaded.get("fixed"); got != "" {
+		t.Errorf("get(fixed) = %q, want cleared", got)
+	}
+
+	if got := loadNotes(db, dir, stopReasonsFile, "stop reasons").get("broken"); got != "" {
+		t.Errorf("stop reasons get(broken) = %q, want separate store", got)
+	}
+}
+
+func TestNoteStoreImportsStateFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, stopReasonsFile)
+	if err := os.WriteFile(path, []byte(`{"api": "idle"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := loadNotes(openState(t, dir), dir, stopReasonsFile, "stop reasons")
+	if got := s.get("api"); got != "idle" {
+		t.Errorf("get(api) = %q, want imported note", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state file still present after import: %v", err)
+	}
+}
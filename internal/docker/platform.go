@@ -0,0 +1,107 @@
+package docker
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/docker/docker/api/types/image"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// shedPlatform returns the image platform to create a shed for: its own,
+// else the server's. Empty leaves the choice to the engine.
+func (c *Client) shedPlatform(req config.CreateShedRequest) string {
+	if req.Platform != "" {
+		return req.Platform
+	}
+	return c.config.Platform
+}
+
+// platformSpec returns the engine's form of a platform, or nil if none is
+// set. The platform must be valid.
+func platformSpec(platform string) *ocispec.Platform {
+	if platform == "" {
+		return nil
+	}
+	os, arch, variant, err := config.ParsePlatform(platform)
+	if err != nil {
+		return nil
+	}
+	return &ocispec.Platform{OS: os, Architecture: arch, Variant: variant}
+}
+
+// imageMatchesPlatform reports whether a local image was built for
+// platform. An image without a variant matches any variant.
+func imageMatchesPlatform(inspect image.InspectResponse, platform string) bool {
+	os, arch, variant, err := config.ParsePlatform(platform)
+	if err != nil {
+		return false
+	}
+	if inspect.Os != os || inspect.Architecture != arch {
+		return false
+	}
+	return variant == "" || inspect.Variant == "" || inspect.Variant == variant
+}
+
+// isNativeImage reports whether a local image is built for the engine's
+// architecture, or whether that can't be told.
+func (c *Client) isNativeImage(ctx context.Context, inspect image.InspectResponse) bool {
+	arch := c.engineArch(ctx)
+	return arch == "" || inspect.Architecture == "" || inspect.Architecture == arch
+}
+
+// normalizeArch maps the kernel's name for an architecture, as the engine
+// reports it, to the name images use.
+func normalizeArch(arch string) string {
+	switch arch {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "armv7l", "armv6l":
+		return "arm"
+	case "i386", "i686":
+		return "386"
+	default:
+		return arch
+	}
+}
+
+// engineArch returns the architecture of the engine running sheds, or
+// empty if it can't be found. It is looked up once, since the engine may
+// be on another host than the server.
+func (c *Client) engineArch(ctx context.Context) string {
+	if arch, ok := c.arch.Load().(string); ok {
+		return arch
+	}
+	info, err := c.docker.Info(ctx)
+	if err != nil {
+		slog.Debug("Failed to get engine architecture", "err", err)
+		return ""
+	}
+	arch := normalizeArch(info.Architecture)
+	c.arch.Store(arch)
+	return arch
+}
+
+// addImagePlatform fills in the platform of a shed's image and whether the
+// engine emulates it. Both are best effort and left empty on failure.
+func (c *Client) addImagePlatform(ctx context.Context, details *config.ShedDetails) {
+	if details.ImageID == "" {
+		return
+	}
+	inspect, err := c.docker.ImageInspect(ctx, details.ImageID)
+	if err != nil {
+		slog.Debug("Failed to inspect shed image", "image", details.ImageID, "err", err)
+		return
+	}
+	if inspect.Os == "" || inspect.Architecture == "" {
+		return
+	}
+	details.ImagePlatform = config.FormatPlatform(inspect.Os, inspect.Architecture, inspect.Variant)
+	if arch := c.engineArch(ctx); arch != "" {
+		details.Emulated = inspect.Architecture != arch
+	}
+}
@@ -0,0 +1,126 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+)
+
+func TestImageMatchesPlatform(t *testing.T) {
+	arm := image.InspectResponse{Os: "linux", Architecture: "arm64", Variant: "v8"}
+	amd := image.InspectResponse{Os: "linux", Architecture: "amd64"}
+
+	tests := []struct {
+		inspect  image.InspectResponse
+		platform string
+		want     bool
+	}{
+		{arm, "linux/arm64", true},
+		{arm, "linux/arm64/v8", true},
+		{arm, "linux/arm64/v9", false},
+		{arm, "linux/amd64", false},
+		{amd, "linux/amd64", true},
+		{amd, "linux/amd64/v3", true},
+		{amd, "windows/amd64", false},
+	}
+	for _, tt := range tests {
+		if got := imageMatchesPlatform(tt.inspect, tt.platform); got != tt.want {
+			t.Errorf("imageMatchesPlatform(%s/%s, %q) = %v, want %v", tt.inspect.Os, tt.inspect.Architecture, tt.platform, got, tt.want)
+		}
+	}
+}
+
+func TestPlatformSpec(t *testing.T) {
+	if spec := platformSpec(""); spec != nil {
+		t.Errorf("platformSpec(\"\") = %+v, want nil", spec)
+	}
+	spec := platformSpec("linux/arm/v7")
+	if spec == nil || spec.OS != "linux" || spec.Architecture != "arm" || spec.Variant != "v7" {
+		t.Errorf("platformSpec(linux/arm/v7) = %+v", spec)
+	}
+}
+
+func TestNormalizeArch(t *testing.T) {
+	for arch, want := range map[string]string{"x86_64": "amd64", "aarch64": "arm64", "armv7l": "arm", "s390x": "s390x"} {
+		if got := normalizeArch(arch); got != want {
+			t.Errorf("normalizeArch(%q) = %q, want %q", arch, got, want)
+		}
+	}
+}
+
+// fakeEngine serves the image API of an arm64 engine holding one image,
+// recording the pulls made.
+type fakeEngine struct {
+	mu       sync.Mutex
+	image    image.InspectResponse
+	pulls    []string
+	failPull bool
+}
+
+func (e *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/info"):
+		_ = json.NewEncoder(w).Encode(system.Info{Architecture: "aarch64"})
+	case strings.HasSuffix(r.URL.Path, "/images/create"):
+		e.pulls = append(e.pulls, r.URL.Query().Get("fromImage")+" "+r.URL.Query().Get("platform"))
+		if e.failPull {
+			io.WriteString(w, `{"error":"no matching manifest for linux/arm64"}`)
+			return
+		}
+		e.image.Architecture = "arm64"
+		io.WriteString(w, `{"status":"Pull complete"}`)
+	case strings.HasSuffix(r.URL.Path, "/json"):
+		_ = json.NewEncoder(w).Encode(e.image)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestEnsureImageArchitecture(t *testing.T) {
+	tests := []struct {
+		name     string
+		arch     string
+		platform string
+		failPull bool
+		want     []string
+	}{
+		{"native image", "arm64", "", false, nil},
+		{"foreign image", "amd64", "", false, []string{"docker.io/library/shed-base "}},
+		{"foreign image not in registry", "amd64", "", true, []string{"docker.io/library/shed-base "}},
+		{"requested platform", "amd64", "linux/amd64", false, nil},
+		{"foreign to requested platform", "arm64", "linux/amd64", false, []string{"docker.io/library/shed-base linux/amd64"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &fakeEngine{image: image.InspectResponse{Os: "linux", Architecture: tt.arch}, failPull: tt.failPull}
+			srv := httptest.NewServer(engine)
+			defer srv.Close()
+			docker, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+			if err != nil {
+				t.Fatalf("NewClientWithOpts() error = %v", err)
+			}
+			defer docker.Close()
+
+			c := &Client{docker: docker}
+			if err := c.ensureImage(context.Background(), "shed-base:latest", tt.platform, nil); err != nil {
+				t.Fatalf("ensureImage() error = %v", err)
+			}
+			if !slices.Equal(engine.pulls, tt.want) {
+				t.Errorf("pulls = %q, want %q", engine.pulls, tt.want)
+			}
+		})
+	}
+}
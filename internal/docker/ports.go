@@ -0,0 +1,147 @@
+package docker
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// portsScript prints the kernel's TCP socket tables, the open files of
+// every process, and every process's name, separated by "--" lines. It
+// only reads procfs, so it works in images without ss or netstat.
+const portsScript = `cat /proc/net/tcp /proc/net/tcp6 2>/dev/null; echo --; ls -l /proc/[0-9]*/fd 2>/dev/null; echo --; head -n 1 /proc/[0-9]*/comm 2>/dev/null`
+
+// tcpListen is the socket state of a listening socket in /proc/net/tcp.
+const tcpListen = "0A"
+
+// ListPorts returns the TCP ports processes in a running shed are
+// listening on, in port order.
+func (c *Client) ListPorts(ctx context.Context, name string) ([]config.ListeningPort, error) {
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+
+	result, err := c.execOutput(ctx, shed.ContainerID, []string{"sh", "-c", portsScript}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePorts(result.Stdout), nil
+}
+
+// parsePorts reads the output of portsScript.
+func parsePorts(output string) []config.ListeningPort {
+	sections := strings.SplitN(output, "\n--\n", 3)
+	for len(sections) < 3 {
+		sections = append(sections, "")
+	}
+	owners := parseSocketOwners(sections[1])
+	names := parseProcessNames(sections[2])
+
+	seen := make(map[string]bool)
+	ports := []config.ListeningPort{}
+	for _, line := range strings.Split(sections[0], "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[3] != tcpListen {
+			continue
+		}
+		addr, port, ok := parseSocketAddress(fields[1])
+		if !ok {
+			continue
+		}
+		key := net.JoinHostPort(addr, strconv.Itoa(port))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		p := config.ListeningPort{Port: port, Address: addr}
+		if pid, ok := owners[fields[9]]; ok {
+			p.PID = pid
+			p.Process = names[pid]
+		}
+		ports = append(ports, p)
+	}
+
+	sort.SliceStable(ports, func(i, j int) bool {
+		if ports[i].Port != ports[j].Port {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].Address < ports[j].Address
+	})
+	return ports
+}
+
+// parseSocketAddress decodes a /proc/net/tcp address such as
+// "0100007F:1F90". The address is hex in host byte order, a 32-bit word at
+// a time; the port is big-endian hex.
+func parseSocketAddress(s string) (string, int, bool) {
+	hexAddr, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, false
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", 0, false
+	}
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, false
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return ip.String(), int(port), true
+}
+
+// parseSocketOwners maps socket inodes to the PID holding them, from
+// "ls -l /proc/[0-9]*/fd" output.
+func parseSocketOwners(output string) map[string]int {
+	owners := make(map[string]int)
+	pid := 0
+	for _, line := range strings.Split(output, "\n") {
+		if dir, ok := strings.CutSuffix(line, "/fd:"); ok {
+			pid, _ = strconv.Atoi(strings.TrimPrefix(dir, "/proc/"))
+			continue
+		}
+		_, target, ok := strings.Cut(line, "-> socket:[")
+		if !ok || pid == 0 {
+			continue
+		}
+		inode := strings.TrimSuffix(target, "]")
+		if _, ok := owners[inode]; !ok {
+			owners[inode] = pid
+		}
+	}
+	return owners
+}
+
+// parseProcessNames maps PIDs to process names, from
+// "head -n 1 /proc/[0-9]*/comm" output.
+func parseProcessNames(output string) map[int]string {
+	names := make(map[int]string)
+	pid := 0
+	for _, line := range strings.Split(output, "\n") {
+		if header, ok := strings.CutPrefix(line, "==> /proc/"); ok {
+			pid, _ = strconv.Atoi(strings.TrimSuffix(header, "/comm <=="))
+			continue
+		}
+		if line = strings.TrimSpace(line); line != "" && pid != 0 {
+			names[pid] = line
+			pid = 0
+		}
+	}
+	return names
+}
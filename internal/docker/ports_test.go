@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestParsePorts(t *testing.T) {
+	output := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1538 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 100 0 0 10 0
+   2: 0100007F:1538 0100007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 20 4 30 10 -1
+  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:1F90 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1004 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000001000000:0BB8 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1005 1 0000000000000000 100 0 0 10 0
+--
+/proc/1/fd:
+total 0
+lrwx------ 1 root root 64 Jan  1 00:00 0 -> /dev/null
+
+/proc/42/fd:
+total 0
+lrwx------ 1 root root 64 Jan  1 00:00 3 -> socket:[1001]
+lrwx------ 1 root root 64 Jan  1 00:00 4 -> socket:[1005]
+
+/proc/77/fd:
+total 0
+lrwx------ 1 root root 64 Jan  1 00:00 5 -> socket:[1002]
+--
+==> /proc/1/comm <==
+sleep
+
+==> /proc/42/comm <==
+node
+
+==> /proc/77/comm <==
+postgres
+`
+
+	want := []config.ListeningPort{
+		{Port: 3000, Address: "0.0.0.0", Process: "node", PID: 42},
+		{Port: 3000, Address: "::1", Process: "node", PID: 42},
+		{Port: 5432, Address: "127.0.0.1", Process: "postgres", PID: 77},
+		{Port: 8080, Address: "::"},
+	}
+	if got := parsePorts(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePorts() = %+v, want %+v", got, want)
+	}
+
+	if got := parsePorts(""); len(got) != 0 {
+		t.Errorf("parsePorts(\"\") = %+v, want none", got)
+	}
+}
+
+func TestListeningPortLoopback(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"0.0.0.0", false},
+		{"::", false},
+		{"172.17.0.2", false},
+		{"127.0.0.1", true},
+		{"::1", true},
+	}
+	for _, tt := range tests {
+		if got := (config.ListeningPort{Address: tt.address}).Loopback(); got != tt.want {
+			t.Errorf("Loopback(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
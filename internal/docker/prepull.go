@@ -0,0 +1,218 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// pullProgressInterval limits how often layer progress is reported.
+const pullProgressInterval = 500 * time.Millisecond
+
+// PullImage pulls an image from its registry, for the server's platform if
+// one is set, and waits for the pull to finish.
+func (c *Client) PullImage(ctx context.Context, ref string) error {
+	return c.pullImage(ctx, ref, c.config.Platform, nil)
+}
+
+// pullImage pulls an image, for platform if one is given, reporting the
+// bytes downloaded across all layers to progress as the image phase runs.
+func (c *Client) pullImage(ctx context.Context, ref, platform string, progress config.ProgressFunc) error {
+	reader, err := c.docker.ImagePull(ctx, ref, image.PullOptions{Platform: platform})
+	if err != nil {
+		return pullError(ref, err.Error())
+	}
+	defer reader.Close()
+
+	// The pull runs until its progress stream is drained, and failures
+	// partway through are only reported in the stream
+	var layers pullLayers
+	var lastReport time.Time
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg pullMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read pull progress for %s: %w", ref, err)
+		}
+		if msg.Error != "" {
+			return pullError(ref, msg.Error)
+		}
+
+		layers.update(msg)
+		if progress != nil && time.Since(lastReport) >= pullProgressInterval {
+			if current, total := layers.bytes(); total > 0 {
+				progress(config.CreateProgress{
+					Phase:   config.PhaseImage,
+					Status:  config.ProgressRunning,
+					Message: "pulling " + ref,
+					Current: current,
+					Total:   total,
+				})
+				lastReport = time.Now()
+			}
+		}
+	}
+}
+
+// pullError describes a failed pull, pointing out the usual cause when the
+// registry doesn't have the image or won't share it.
+func pullError(ref, reason string) error {
+	lower := strings.ToLower(reason)
+	if strings.Contains(lower, "not found") || strings.Contains(lower, "manifest unknown") ||
+		strings.Contains(lower, "access denied") || strings.Contains(lower, "unauthorized") {
+		return fmt.Errorf("failed to pull image %s: it does not exist or the registry requires a login (%s)", ref, reason)
+	}
+	return fmt.Errorf("failed to pull image %s: %s", ref, reason)
+}
+
+// pullMessage is one line of Docker's image pull progress stream.
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Error          string `json:"error"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// pullLayers tracks the download progress of each layer in a pull.
+type pullLayers map[string]*layerProgress
+
+type layerProgress struct {
+	current, total int64
+}
+
+// update records a progress message for its layer.
+func (l *pullLayers) update(msg pullMessage) {
+	if msg.ID == "" {
+		return
+	}
+	if *l == nil {
+		*l = make(pullLayers)
+	}
+	layer := (*l)[msg.ID]
+	if layer == nil {
+		layer = &layerProgress{}
+		(*l)[msg.ID] = layer
+	}
+
+	switch msg.Status {
+	case "Downloading":
+		layer.current = msg.ProgressDetail.Current
+		if msg.ProgressDetail.Total > 0 {
+			layer.total = msg.ProgressDetail.Total
+		}
+	case "Download complete", "Pull complete":
+		layer.current = layer.total
+	}
+}
+
+// bytes returns the downloaded and total sizes of the layers whose size is
+// known so far.
+func (l pullLayers) bytes() (current, total int64) {
+	for _, layer := range l {
+		current += layer.current
+		total += layer.total
+	}
+	return current, total
+}
+
+// Prepuller pulls a set of images on a schedule so sheds can be created
+// without waiting for a pull, and records the outcome of each pull.
+type Prepuller struct {
+	client   *Client
+	images   []string
+	interval time.Duration
+
+	mu       sync.Mutex
+	statuses map[string]config.ImagePullStatus
+}
+
+// NewPrepuller creates a Prepuller for the images and interval in the
+// server configuration.
+func NewPrepuller(c *Client, cfg *config.ServerConfig) *Prepuller {
+	images := cfg.PrepullImages()
+	statuses := make(map[string]config.ImagePullStatus, len(images))
+	for _, ref := range images {
+		statuses[ref] = config.ImagePullStatus{Image: ref}
+	}
+
+	return &Prepuller{
+		client:   c,
+		images:   images,
+		interval: cfg.Prepull.Interval,
+		statuses: statuses,
+	}
+}
+
+// Run pulls every image immediately and then once per interval until the
+// context is cancelled.
+func (p *Prepuller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.pullAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pullAll pulls each image in turn, recording the result.
+func (p *Prepuller) pullAll(ctx context.Context) {
+	for _, ref := range p.images {
+		if ctx.Err() != nil {
+			return
+		}
+
+		start := time.Now()
+		err := p.client.PullImage(ctx, ref)
+		elapsed := time.Since(start)
+
+		p.mu.Lock()
+		status := p.statuses[ref]
+		status.LastAttempt = start.UTC()
+		status.Duration = elapsed.Seconds()
+		if err != nil {
+			status.Error = err.Error()
+			slog.Warn("Pre-pull failed", "image", ref, "err", err)
+		} else {
+			status.Error = ""
+			status.LastSuccess = start.UTC()
+			slog.Info("Pre-pulled image", "image", ref, "elapsed", elapsed.Round(time.Millisecond))
+		}
+		p.statuses[ref] = status
+		p.mu.Unlock()
+	}
+}
+
+// Status returns the outcome of the most recent pull of each image, in
+// configuration order.
+func (p *Prepuller) Status() []config.ImagePullStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]config.ImagePullStatus, 0, len(p.images))
+	for _, ref := range p.images {
+		statuses = append(statuses, p.statuses[ref])
+	}
+	return statuses
+}
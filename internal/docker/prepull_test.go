@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPullLayers(t *testing.T) {
+	stream := `{"status":"Pulling from library/ubuntu","id":"latest"}
+{"status":"Pulling fs layer","id":"a"}
+{"status":"Pulling fs layer","id":"b"}
+{"status":"Already exists","id":"c"}
+{"status":"Downloading","progressDetail":{"current":100,"total":1000},"id":"a"}
+{"status":"Downloading","progressDetail":{"current":50,"total":500},"id":"b"}
+{"status":"Downloading","progressDetail":{"current":400,"total":1000},"id":"a"}
+{"status":"Download complete","id":"b"}
+{"status":"Extracting","progressDetail":{"current":200,"total":500},"id":"b"}
+`
+
+	var layers pullLayers
+	decoder := json.NewDecoder(strings.NewReader(stream))
+	for decoder.More() {
+		var msg pullMessage
+		if err := decoder.Decode(&msg); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		layers.update(msg)
+	}
+
+	current, total := layers.bytes()
+	if current != 900 || total != 1500 {
+		t.Errorf("bytes() = %d, %d, want 900, 1500", current, total)
+	}
+}
+
+func TestPullError(t *testing.T) {
+	err := pullError("ghcr.io/acme/private:latest", "pull access denied for ghcr.io/acme/private")
+	if !strings.Contains(err.Error(), "does not exist or the registry requires a login") {
+		t.Errorf("pullError() = %q, want a hint about missing images", err)
+	}
+
+	err = pullError("shed-base:latest", "connection reset by peer")
+	if got, want := err.Error(), "failed to pull image shed-base:latest: connection reset by peer"; got != want {
+		t.Errorf("pullError() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// preStopFromLabels parses a shed's pre-stop hook from its container's
+// labels, which include those of its image.
+func preStopFromLabels(labels map[string]string) *config.PreStop {
+	v := labels[config.LabelPreStop]
+	if v == "" {
+		return nil
+	}
+	var hook config.PreStop
+	if err := json.Unmarshal([]byte(v), &hook); err != nil {
+		slog.Warn("Ignoring invalid label", "label", config.LabelPreStop, "err", err)
+		return nil
+	}
+	if err := hook.Validate(); err != nil {
+		slog.Warn("Ignoring invalid label", "label", config.LabelPreStop, "err", err)
+		return nil
+	}
+	return &hook
+}
+
+// runPreStop runs a running shed's pre-stop hook, if it has one, and waits
+// for it to finish or time out. Failures are logged rather than returned:
+// the shed is stopped either way.
+func (c *Client) runPreStop(ctx context.Context, shed *config.Shed) {
+	hook := shed.PreStop
+	if hook == nil || shed.Status != config.StatusRunning {
+		return
+	}
+	timeout, err := hook.TimeoutDuration()
+	if err != nil {
+		slog.Warn("Skipping pre-stop hook", "shed", shed.Name, "err", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	exitCode, err := c.ExecCommand(ctx, shed.Name, hook.Command, &output, &output)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.Warn("Pre-stop hook timed out", "shed", shed.Name, "timeout", timeout)
+	case err != nil:
+		slog.Warn("Pre-stop hook failed", "shed", shed.Name, "err", err)
+	case exitCode != 0:
+		slog.Warn("Pre-stop hook failed", "shed", shed.Name, "exit_code", exitCode, "output", strings.TrimSpace(output.String()))
+	default:
+		slog.Debug("Ran pre-stop hook", "shed", shed.Name)
+	}
+}
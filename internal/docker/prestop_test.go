@@ -0,0 +1,22 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestPreStopFromLabels(t *testing.T) {
+	labels := map[string]string{config.LabelPreStop: `{"command":"tmux kill-server","timeout":"1m"}`}
+	want := &config.PreStop{Command: "tmux kill-server", Timeout: "1m"}
+	if got := preStopFromLabels(labels); !reflect.DeepEqual(got, want) {
+		t.Errorf("preStopFromLabels() = %+v, want %+v", got, want)
+	}
+
+	for _, v := range []string{"", "not json", `{"command":""}`, `{"command":"true","timeout":"1d"}`} {
+		if got := preStopFromLabels(map[string]string{config.LabelPreStop: v}); got != nil {
+			t.Errorf("preStopFromLabels(%q) = %+v, want nil", v, got)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// pruneGracePeriod spares volumes this new, since a shed being created has
+// its volume before its container.
+const pruneGracePeriod = 10 * time.Minute
+
+// Prune finds shed resources nothing uses: volumes and dedicated networks
+// whose shed is gone, shed containers whose workspace volume is gone,
+// untagged images, and optionally sheds stopped for a while. Unless req.DryRun is set they are
+// removed, with failures reported on each resource.
+func (c *Client) Prune(ctx context.Context, req config.PruneRequest) (*config.PruneResponse, error) {
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	volumes, err := c.docker.VolumeList(ctx, volume.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	networks, err := c.docker.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", config.LabelShedName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+
+	found := orphanedResources(containers, volumes.Volumes, time.Now())
+	found = append(found, orphanedNetworks(containers, networks, time.Now())...)
+	if req.StoppedDays > 0 {
+		stale, err := c.staleSheds(ctx, containers, found, time.Duration(req.StoppedDays)*24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, stale...)
+	}
+	images, err := c.unusedImages(ctx, containers)
+	if err != nil {
+		return nil, err
+	}
+	found = append(found, images...)
+
+	// Sizes are only for reporting, so go without if they can't be had
+	sizes := c.volumeSizes(ctx)
+	for i := range found {
+		switch found[i].Kind {
+		case config.PruneVolume:
+			found[i].Size = sizes[found[i].Name]
+		case config.PruneShed:
+			found[i].Size = sizes[config.VolumeName(found[i].Name)]
+		}
+	}
+
+	resp := &config.PruneResponse{DryRun: req.DryRun, Resources: found}
+	for i := range resp.Resources {
+		r := &resp.Resources[i]
+		if !req.DryRun {
+			if err := c.removePruned(ctx, *r); err != nil {
+				r.Error = err.Error()
+				continue
+			}
+		}
+		resp.SpaceReclaimed += r.Size
+	}
+	return resp, nil
+}
+
+// orphanedResources returns the shed volumes no shed uses and the shed
+// containers whose workspace volume is gone. Volumes created within
+// pruneGracePeriod of now are left alone.
+func orphanedResources(containers []container.Summary, volumes []*volume.Volume, now time.Time) []config.PrunedResource {
+	sheds := make(map[string]bool)
+	cacheUsers := make(map[string]bool)
+	for _, ctr := range containers {
+		if ctr.Labels[config.LabelShed] != "true" {
+			continue
+		}
+		sheds[ctr.Labels[config.LabelShedName]] = true
+		if cache := ctr.Labels[config.LabelRepoCache]; cache != "" {
+			cacheUsers[cache] = true
+		}
+	}
+
+	var found []config.PrunedResource
+	names := make(map[string]bool, len(volumes))
+	for _, v := range volumes {
+		names[v.Name] = true
+		if v.Labels[config.LabelShed] != "true" {
+			continue
+		}
+		if created, err := time.Parse(time.RFC3339, v.CreatedAt); err == nil && now.Sub(created) < pruneGracePeriod {
+			continue
+		}
+
+		if repo := v.Labels[config.LabelRepoCache]; repo != "" {
+			if !cacheUsers[v.Name] {
+				found = append(found, config.PrunedResource{
+					Kind:   config.PruneVolume,
+					Name:   v.Name,
+					Reason: "shared clone of " + repo + " used by no shed",
+				})
+			}
+		} else if name := v.Labels[config.LabelShedName]; name != "" && !sheds[name] {
+			found = append(found, config.PrunedResource{
+				Kind:   config.PruneVolume,
+				Name:   v.Name,
+				Reason: "workspace of deleted shed " + name,
+			})
+		}
+	}
+
+	for name := range sheds {
+		if volume := config.VolumeName(name); !names[volume] {
+			found = append(found, config.PrunedResource{
+				Kind:   config.PruneContainer,
+				Name:   name,
+				Reason: "workspace volume " + volume + " is gone",
+			})
+		}
+	}
+
+	sortPruned(found)
+	return found
+}
+
+// orphanedNetworks returns the dedicated shed networks whose shed is gone.
+// Networks are spared for pruneGracePeriod, since a shed being created has
+// its network before its container.
+func orphanedNetworks(containers []container.Summary, networks []network.Summary, now time.Time) []config.PrunedResource {
+	sheds := make(map[string]bool)
+	for _, ctr := range containers {
+		if ctr.Labels[config.LabelShed] == "true" {
+			sheds[ctr.Labels[config.LabelShedName]] = true
+		}
+	}
+
+	var found []config.PrunedResource
+	for _, n := range networks {
+		name := n.Labels[config.LabelShedName]
+		if n.Labels[config.LabelShed] != "true" || name == "" || sheds[name] || now.Sub(n.Created) < pruneGracePeriod {
+			continue
+		}
+		found = append(found, config.PrunedResource{
+			Kind:   config.PruneNetwork,
+			Name:   n.Name,
+			Reason: "network of deleted shed " + name,
+		})
+	}
+
+	sortPruned(found)
+	return found
+}
+
+// staleSheds returns the sheds stopped for at least age, other than those
+// already in found.
+func (c *Client) staleSheds(ctx context.Context, containers []container.Summary, found []config.PrunedResource, age time.Duration) ([]config.PrunedResource, error) {
+	skip := make(map[string]bool)
+	for _, r := range found {
+		if r.Kind == config.PruneContainer {
+			skip[r.Name] = true
+		}
+	}
+
+	var stale []config.PrunedResource
+	for _, ctr := range containers {
+		name := ctr.Labels[config.LabelShedName]
+		if ctr.Labels[config.LabelShed] != "true" || skip[name] || containerStateToStatus(ctr.State) != config.StatusStopped {
+			continue
+		}
+
+		inspect, err := c.docker.ContainerInspect(ctx, ctr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container: %w", err)
+		}
+		// Containers that never ran count from when they were created
+		stopped, err := time.Parse(time.RFC3339Nano, inspect.State.FinishedAt)
+		if err != nil || stopped.IsZero() || stopped.Year() < 2000 {
+			stopped = time.Unix(ctr.Created, 0)
+		}
+		if since := time.Since(stopped); since >= age {
+			stale = append(stale, config.PrunedResource{
+				Kind:   config.PruneShed,
+				Name:   name,
+				Reason: fmt.Sprintf("stopped for %d days", int(since.Hours()/24)),
+			})
+		}
+	}
+
+	sortPruned(stale)
+	return stale, nil
+}
+
+// unusedImages returns the untagged images no container uses, among those
+// the server's images config selects.
+func (c *Client) unusedImages(ctx context.Context, containers []container.Summary) ([]config.PrunedResource, error) {
+	args := filters.NewArgs(filters.Arg("dangling", "true"))
+	if label := c.config.Images.Label; label != "" {
+		args.Add("label", label)
+	}
+	summaries, err := c.docker.ImageList(ctx, image.ListOptions{Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	return unusedImages(summaries, containers, c.config.Images), nil
+}
+
+// unusedImages returns the images among summaries that no container uses
+// and cfg matches. An untagged image has lost its name, so it is matched by
+// the repository it was pulled from.
+func unusedImages(summaries []image.Summary, containers []container.Summary, cfg config.ImagesConfig) []config.PrunedResource {
+	used := make(map[string]bool, len(containers))
+	for _, ctr := range containers {
+		used[ctr.ImageID] = true
+	}
+
+	var found []config.PrunedResource
+	for _, s := range summaries {
+		if used[s.ID] {
+			continue
+		}
+		repo := ""
+		if len(s.RepoDigests) > 0 {
+			repo, _, _ = strings.Cut(s.RepoDigests[0], "@")
+		}
+		if !cfg.Matches(repo) {
+			continue
+		}
+		reason := "untagged image"
+		if repo != "" && repo != "<none>" {
+			reason = "untagged image from " + repo
+		}
+		found = append(found, config.PrunedResource{
+			Kind:   config.PruneImage,
+			Name:   s.ID,
+			Reason: reason,
+			Size:   s.Size,
+		})
+	}
+
+	sortPruned(found)
+	return found
+}
+
+// volumeSizes returns the disk space each volume uses, as far as the
+// engine reports it.
+func (c *Client) volumeSizes(ctx context.Context) map[string]int64 {
+	sizes := make(map[string]int64)
+	du, err := c.docker.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return sizes
+	}
+	for _, v := range du.Volumes {
+		if v.UsageData != nil && v.UsageData.Size > 0 {
+			sizes[v.Name] = v.UsageData.Size
+		}
+	}
+	return sizes
+}
+
+// removePruned removes a resource found by Prune.
+func (c *Client) removePruned(ctx context.Context, r config.PrunedResource) error {
+	switch r.Kind {
+	case config.PruneVolume:
+		return c.docker.VolumeRemove(ctx, r.Name, false)
+	case config.PruneNetwork:
+		return c.docker.NetworkRemove(ctx, r.Name)
+	case config.PruneContainer:
+		// There's no workspace left to back up or remove
+		return c.DeleteShed(ctx, r.Name, true)
+	case config.PruneShed:
+		return c.DeleteShed(ctx, r.Name, false)
+	case config.PruneImage:
+		_, err := c.docker.ImageRemove(ctx, r.Name, image.RemoveOptions{PruneChildren: true})
+		return err
+	}
+	return fmt.Errorf("unknown resource kind %q", r.Kind)
+}
+
+// sortPruned orders resources by kind, then name.
+func sortPruned(resources []config.PrunedResource) {
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Kind != resources[j].Kind {
+			return resources[i].Kind < resources[j].Kind
+		}
+		return resources[i].Name < resources[j].Name
+	})
+}
@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestOrphanedResources(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-time.Hour).Format(time.RFC3339)
+
+	shedContainer := func(name, cache string) container.Summary {
+		labels := map[string]string{config.LabelShed: "true", config.LabelShedName: name}
+		if cache != "" {
+			labels[config.LabelRepoCache] = cache
+		}
+		return container.Summary{Labels: labels}
+	}
+	shedVolume := func(name string, labels map[string]string, created string) *volume.Volume {
+		labels[config.LabelShed] = "true"
+		return &volume.Volume{Name: name, Labels: labels, CreatedAt: created}
+	}
+
+	containers := []container.Summary{
+		shedContainer("alive", "shed-cache-used"),
+		shedContainer("novolume", ""),
+		{Labels: map[string]string{"app": "other"}},
+	}
+	volumes := []*volume.Volume{
+		shedVolume(config.VolumeName("alive"), map[string]string{config.LabelShedName: "alive"}, old),
+		shedVolume(config.VolumeName("gone"), map[string]string{config.LabelShedName: "gone"}, old),
+		shedVolume(config.VolumeName("creating"), map[string]string{config.LabelShedName: "creating"}, now.Add(-time.Minute).Format(time.RFC3339)),
+		shedVolume("shed-cache-used", map[string]string{config.LabelRepoCache: "github.com/a/b"}, old),
+		shedVolume("shed-cache-unused", map[string]string{config.LabelRepoCache: "github.com/c/d"}, old),
+		{Name: "postgres-data", Labels: map[string]string{config.LabelShedName: "gone"}},
+	}
+
+	got := orphanedResources(containers, volumes, now)
+	want := []config.PrunedResource{
+		{Kind: config.PruneContainer, Name: "novolume", Reason: "workspace volume " + config.VolumeName("novolume") + " is gone"},
+		{Kind: config.PruneVolume, Name: "shed-cache-unused", Reason: "shared clone of github.com/c/d used by no shed"},
+		{Kind: config.PruneVolume, Name: config.VolumeName("gone"), Reason: "workspace of deleted shed gone"},
+	}
+	sortPruned(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orphanedResources() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestOrphanedNetworks(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	shedNetwork := func(name string, created time.Time) network.Summary {
+		return network.Summary{
+			Name:    config.ShedNetworkName(name),
+			Created: created,
+			Labels:  map[string]string{config.LabelShed: "true", config.LabelShedName: name},
+		}
+	}
+
+	containers := []container.Summary{
+		{Labels: map[string]string{config.LabelShed: "true", config.LabelShedName: "alive"}},
+	}
+	networks := []network.Summary{
+		shedNetwork("alive", now.Add(-time.Hour)),
+		shedNetwork("gone", now.Add(-time.Hour)),
+		shedNetwork("creating", now.Add(-time.Minute)),
+		{Name: "other", Labels: map[string]string{config.LabelShedName: "gone"}},
+	}
+
+	got := orphanedNetworks(containers, networks, now)
+	want := []config.PrunedResource{
+		{Kind: config.PruneNetwork, Name: config.ShedNetworkName("gone"), Reason: "network of deleted shed gone"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orphanedNetworks() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnusedImages(t *testing.T) {
+	summaries := []image.Summary{
+		{ID: "sha256:1", RepoDigests: []string{"shed-go@sha256:aa"}, Size: 100},
+		{ID: "sha256:2", RepoDigests: []string{"shed-go@sha256:bb"}, Size: 200},
+		{ID: "sha256:3", RepoDigests: []string{"postgres@sha256:cc"}, Size: 300},
+		{ID: "sha256:4", Size: 400},
+	}
+	containers := []container.Summary{{ImageID: "sha256:2"}}
+
+	got := unusedImages(summaries, containers, config.ImagesConfig{Prefixes: []string{"shed-"}})
+	want := []config.PrunedResource{
+		{Kind: config.PruneImage, Name: "sha256:1", Reason: "untagged image from shed-go", Size: 100},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unusedImages() = %+v, want %+v", got, want)
+	}
+
+	// Without prefixes every unused image is a candidate
+	got = unusedImages(summaries, containers, config.ImagesConfig{})
+	if len(got) != 3 || got[2].Reason != "untagged image" {
+		t.Errorf("unusedImages() without prefixes = %+v, want 3 images", got)
+	}
+}
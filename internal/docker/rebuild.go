@@ -0,0 +1,133 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// RebuildShed replaces a shed's container with a new one from req.Image,
+// or its current image, keeping its workspace volume, settings, and mounts.
+// The old container is set aside until the new one starts, and restored if
+// it can't. The rebuilt shed is left running.
+func (c *Client) RebuildShed(ctx context.Context, name string, req config.RebuildShedRequest, progress config.ProgressFunc) (*config.Shed, error) {
+	unlock, err := c.lockShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	containerName := config.ContainerName(name)
+
+	ctr, err := c.docker.ContainerInspect(ctx, containerName)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, shedNotFound(name)
+		}
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	labels := ctr.Config.Labels
+	if labels[config.LabelShed] != "true" {
+		return nil, shedNotFound(name)
+	}
+
+	image := req.Image
+	if image == "" {
+		image = ctr.Config.Image
+	}
+	create := cloneRequest(name, image, labels, ctr.Config.Env)
+	create.Worktree = labels[config.LabelRepoCache] != ""
+	create.Labels = c.shedLabels(name, labels)
+	create.Env = c.shedEnv(name, labels)
+
+	// The allowlist may have changed since the shed was created
+	for i, m := range create.Mounts {
+		allowed, err := c.config.Mounts.Allow(m)
+		if err != nil {
+			return nil, fmt.Errorf("cannot rebuild shed %q: %w", name, err)
+		}
+		create.Mounts[i] = allowed
+	}
+
+	// Get the image before touching the old container so a failed pull
+	// leaves the shed as it was
+	if req.Pull {
+		progress.Report(config.PhaseImage, config.ProgressStarted, "pulling "+image)
+		if err := c.pullImage(ctx, image, c.shedPlatform(create), progress); err != nil {
+			progress.Report(config.PhaseImage, config.ProgressFailed, err.Error())
+			return nil, err
+		}
+		progress.Report(config.PhaseImage, config.ProgressDone, "pulled "+image)
+	} else if err := c.ensureImage(ctx, image, c.shedPlatform(create), progress); err != nil {
+		return nil, err
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, labels[config.LabelShedCreated])
+	if err != nil {
+		createdAt = time.Now()
+	}
+
+	running := ctr.State != nil && (ctr.State.Running || ctr.State.Paused)
+	if running {
+		if err := c.docker.ContainerStop(ctx, ctr.ID, container.StopOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to stop container: %w", err)
+		}
+	}
+	// Container names can contain dots but shed names can't, so this can't
+	// collide with another shed
+	if err := c.docker.ContainerRename(ctx, ctr.ID, containerName+".rebuild"); err != nil {
+		c.restartAfterRebuild(ctx, ctr.ID, running)
+		return nil, fmt.Errorf("failed to rename container: %w", err)
+	}
+
+	// The image was reported above
+	shed, err := c.createShed(ctx, create, skipPhase(progress, config.PhaseImage), createOptions{createdAt: createdAt})
+	if err != nil {
+		if renameErr := c.docker.ContainerRename(ctx, ctr.ID, containerName); renameErr != nil {
+			slog.Warn("Failed to restore container after rebuild", "shed", name, "err", renameErr)
+		} else {
+			c.restartAfterRebuild(ctx, ctr.ID, running)
+		}
+		return nil, err
+	}
+
+	if err := c.docker.ContainerRemove(ctx, ctr.ID, container.RemoveOptions{Force: true}); err != nil {
+		slog.Warn("Failed to remove old container after rebuild", "shed", name, "err", err)
+	}
+	c.multiplexers.Delete(ctr.ID)
+	c.stopReasons.clear(name)
+	// The new container carries any labels and env changed since creation
+	c.labels.clear(name)
+	c.env.clear(name)
+
+	return shed, nil
+}
+
+// restartAfterRebuild starts a shed's old container again after a failed
+// rebuild if it was running before.
+func (c *Client) restartAfterRebuild(ctx context.Context, id string, running bool) {
+	if !running {
+		return
+	}
+	if err := c.docker.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		slog.Warn("Failed to restart container", "container", id, "err", err)
+	}
+}
+
+// skipPhase returns a progress func that drops events for one phase.
+func skipPhase(progress config.ProgressFunc, phase string) config.ProgressFunc {
+	if progress == nil {
+		return nil
+	}
+	return func(p config.CreateProgress) {
+		if p.Phase != phase {
+			progress(p)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestSkipPhase(t *testing.T) {
+	if skipPhase(nil, config.PhaseImage) != nil {
+		t.Error("skipPhase(nil) should stay nil so reports are dropped")
+	}
+
+	var phases []string
+	progress := skipPhase(func(p config.CreateProgress) {
+		phases = append(phases, p.Phase)
+	}, config.PhaseImage)
+
+	progress.Report(config.PhaseImage, config.ProgressDone, "")
+	progress.Report(config.PhaseContainer, config.ProgressDone, "")
+	progress.Report(config.PhaseStart, config.ProgressDone, "")
+
+	if len(phases) != 2 || phases[0] != config.PhaseContainer || phases[1] != config.PhaseStart {
+		t.Errorf("phases = %v, want container and start only", phases)
+	}
+}
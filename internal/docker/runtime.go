@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// podmanEngine is the component name Podman reports in its version.
+const podmanEngine = "Podman Engine"
+
+// podmanSystemSocket is the API socket of rootful Podman.
+const podmanSystemSocket = "/run/podman/podman.sock"
+
+// clientOpts returns the options for connecting to the configured engine.
+// A configured docker_host wins over DOCKER_HOST; without either, Podman is
+// reached through the Docker-compatible API on its socket.
+func clientOpts(cfg *config.ServerConfig, runtime string) []client.Opt {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	switch {
+	case cfg.DockerHost != "":
+		opts = append(opts, client.WithHost(cfg.DockerHost))
+	case runtime == config.RuntimePodman && os.Getenv(client.EnvOverrideHost) == "":
+		opts = append(opts, client.WithHost("unix://"+podmanSocket(os.Geteuid(), os.Getenv("XDG_RUNTIME_DIR"))))
+	}
+	if !cfg.DockerTLS.IsZero() {
+		opts = append(opts, client.WithTLSClientConfig(cfg.DockerTLS.CACert, cfg.DockerTLS.Cert, cfg.DockerTLS.Key))
+	}
+	return opts
+}
+
+// podmanSocket returns the path of Podman's API socket: the user's socket
+// for rootless Podman, or the system socket when running as root.
+func podmanSocket(euid int, runtimeDir string) string {
+	if euid != 0 && runtimeDir != "" {
+		return filepath.Join(runtimeDir, "podman", "podman.sock")
+	}
+	return podmanSystemSocket
+}
+
+// runtimeFromVersion returns the runtime that answered a version request.
+func runtimeFromVersion(v types.Version) string {
+	for _, c := range v.Components {
+		if c.Name == podmanEngine {
+			return config.RuntimePodman
+		}
+	}
+	return config.RuntimeDocker
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestPodmanSocket(t *testing.T) {
+	tests := []struct {
+		name       string
+		euid       int
+		runtimeDir string
+		want       string
+	}{
+		{"rootless", 1000, "/run/user/1000", "/run/user/1000/podman/podman.sock"},
+		{"root", 0, "/run/user/0", podmanSystemSocket},
+		{"no runtime dir", 1000, "", podmanSystemSocket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podmanSocket(tt.euid, tt.runtimeDir); got != tt.want {
+				t.Errorf("podmanSocket() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeFromVersion(t *testing.T) {
+	podman := types.Version{Components: []types.ComponentVersion{{Name: podmanEngine, Version: "5.2.0"}}}
+	if got := runtimeFromVersion(podman); got != config.RuntimePodman {
+		t.Errorf("runtimeFromVersion(podman) = %q, want %q", got, config.RuntimePodman)
+	}
+
+	docker := types.Version{Components: []types.ComponentVersion{{Name: "Engine", Version: "28.5.2"}, {Name: "containerd"}}}
+	if got := runtimeFromVersion(docker); got != config.RuntimeDocker {
+		t.Errorf("runtimeFromVersion(docker) = %q, want %q", got, config.RuntimeDocker)
+	}
+}
+
+func TestClientOptsDockerHost(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix:///from/env.sock")
+
+	tests := []struct {
+		name    string
+		cfg     config.ServerConfig
+		runtime string
+		want    string
+	}{
+		{"configured host", config.ServerConfig{DockerHost: "tcp://build-box:2376"}, config.RuntimeDocker, "tcp://build-box:2376"},
+		{"configured host on podman", config.ServerConfig{DockerHost: "tcp://build-box:2376"}, config.RuntimePodman, "tcp://build-box:2376"},
+		{"environment", config.ServerConfig{}, config.RuntimeDocker, "unix:///from/env.sock"},
+		{"environment on podman", config.ServerConfig{}, config.RuntimePodman, "unix:///from/env.sock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := client.NewClientWithOpts(clientOpts(&tt.cfg, tt.runtime)...)
+			if err != nil {
+				t.Fatalf("NewClientWithOpts() error = %v", err)
+			}
+			defer c.Close()
+
+			if got := c.DaemonHost(); got != tt.want {
+				t.Errorf("DaemonHost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
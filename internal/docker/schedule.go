@@ -0,0 +1,253 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+	"github.com/charliek/shed/internal/cron"
+)
+
+// schedulesFile is the legacy state file for each shed's schedules, as
+// cron expressions keyed by action.
+const schedulesFile = "schedules.json"
+
+// maxMissedMinutes is how far back the scheduler catches up after a late
+// tick. Longer gaps, such as the server being down, are skipped rather
+// than replayed.
+const maxMissedMinutes = 5
+
+// ListSchedules returns the schedules of a shed, or of every shed if name
+// is empty, sorted by shed and action.
+func (c *Client) ListSchedules(ctx context.Context, name string) ([]config.Schedule, error) {
+	all := c.schedules.all()
+	if name != "" {
+		if _, err := c.GetShed(ctx, name); err != nil {
+			return nil, err
+		}
+		all = map[string]map[string]string{name: all[name]}
+	}
+
+	now := time.Now().In(c.scheduleLocation())
+	schedules := []config.Schedule{}
+	for shed, actions := range all {
+		for action, expr := range actions {
+			schedules = append(schedules, newSchedule(shed, action, expr, now))
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		if schedules[i].Shed != schedules[j].Shed {
+			return schedules[i].Shed < schedules[j].Shed
+		}
+		return schedules[i].Action < schedules[j].Action
+	})
+	return schedules, nil
+}
+
+// SetSchedule schedules a shed to start, stop, or be backed up at the
+// times expr matches, replacing any schedule it had for the action.
+func (c *Client) SetSchedule(ctx context.Context, name, action, expr string) (*config.Schedule, error) {
+	if err := config.ValidateScheduleAction(action); err != nil {
+		return nil, err
+	}
+	if action == config.ScheduleBackup && !c.config.Backups.Enabled() {
+		return nil, errBackupsDisabled
+	}
+	if _, err := cron.Parse(expr); err != nil {
+		return nil, err
+	}
+	if _, err := c.GetShed(ctx, name); err != nil {
+		return nil, err
+	}
+
+	actions, _ := c.schedules.get(name)
+	if actions == nil {
+		actions = make(map[string]string)
+	}
+	actions[action] = expr
+	if err := c.schedules.set(name, actions); err != nil {
+		return nil, fmt.Errorf("failed to save schedule: %w", err)
+	}
+
+	schedule := newSchedule(name, action, expr, time.Now().In(c.scheduleLocation()))
+	return &schedule, nil
+}
+
+// DeleteSchedule removes a shed's schedule for an action.
+func (c *Client) DeleteSchedule(ctx context.Context, name, action string) error {
+	actions, _ := c.schedules.get(name)
+	if _, ok := actions[action]; !ok {
+		return fmt.Errorf("schedule %q not found for shed %q", action, name)
+	}
+	delete(actions, action)
+	if len(actions) == 0 {
+		c.schedules.clear(name)
+		return nil
+	}
+	if err := c.schedules.set(name, actions); err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// newSchedule describes a stored schedule with when it next runs.
+func newSchedule(shed, action, expr string, now time.Time) config.Schedule {
+	s := config.Schedule{Shed: shed, Action: action, Cron: expr}
+	if spec, err := cron.Parse(expr); err == nil {
+		s.Next = spec.Next(now)
+	}
+	return s
+}
+
+// scheduleLocation returns the timezone schedules are evaluated in: the
+// server's timezone setting, or its local time.
+func (c *Client) scheduleLocation() *time.Location {
+	if c.config.Timezone != "" {
+		if loc, err := time.LoadLocation(c.config.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// Scheduler starts, stops, and backs up sheds on their schedules, and backs
+// up every other shed on the server's backup schedule.
+type Scheduler struct {
+	client *Client
+}
+
+// NewScheduler creates a Scheduler for the sheds of c.
+func NewScheduler(c *Client) *Scheduler {
+	return &Scheduler{client: c}
+}
+
+// Run runs the schedules due each minute until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	last := time.Now().Truncate(time.Minute)
+	for {
+		// Wake just after the next minute starts
+		next := last.Add(time.Minute)
+		timer := time.NewTimer(time.Until(next) + time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now().Truncate(time.Minute)
+		if now.Sub(last) > maxMissedMinutes*time.Minute {
+			last = now.Add(-time.Minute)
+		}
+		for last.Before(now) {
+			last = last.Add(time.Minute)
+			s.runDue(ctx, last)
+		}
+	}
+}
+
+// runDue runs the schedules that match minute.
+func (s *Scheduler) runDue(ctx context.Context, minute time.Time) {
+	minute = minute.In(s.client.scheduleLocation())
+	all := s.client.schedules.all()
+	for _, d := range dueSchedules(all, minute) {
+		s.run(ctx, d.shed, d.action)
+	}
+	s.runServerBackups(ctx, all, minute)
+}
+
+// runServerBackups backs up the sheds without their own backup schedule
+// if the server's backup schedule matches minute.
+func (s *Scheduler) runServerBackups(ctx context.Context, all map[string]map[string]string, minute time.Time) {
+	backups := s.client.config.Backups
+	if !backups.Enabled() || backups.Schedule == "" {
+		return
+	}
+	spec, err := cron.Parse(backups.Schedule)
+	if err != nil || !spec.Matches(minute) {
+		return
+	}
+
+	sheds, err := s.client.ListSheds(ctx)
+	if err != nil {
+		slog.Warn("Failed to list sheds for scheduled backups", "err", err)
+		return
+	}
+	var names []string
+	for _, shed := range sheds {
+		if _, ok := all[shed.Name][config.ScheduleBackup]; !ok {
+			names = append(names, shed.Name)
+		}
+	}
+	sort.Strings(names)
+	go s.client.backupSheds(ctx, names)
+}
+
+// dueAction is a scheduled action to run.
+type dueAction struct {
+	shed, action string
+}
+
+// dueSchedules returns the actions whose schedules match minute, sorted by
+// shed. A shed's stop runs before its start, so a schedule that does both
+// at once leaves it running, and backups run last.
+func dueSchedules(all map[string]map[string]string, minute time.Time) []dueAction {
+	var due []dueAction
+	for shed, actions := range all {
+		for _, action := range []string{config.ScheduleStop, config.ScheduleStart, config.ScheduleBackup} {
+			expr, ok := actions[action]
+			if !ok {
+				continue
+			}
+			spec, err := cron.Parse(expr)
+			if err != nil {
+				slog.Warn("Ignoring invalid schedule", "shed", shed, "action", action, "err", err)
+				continue
+			}
+			if spec.Matches(minute) {
+				due = append(due, dueAction{shed: shed, action: action})
+			}
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].shed < due[j].shed
+	})
+	return due
+}
+
+// run starts or stops a shed for its schedule, unless it is already in
+// that state, or starts backing it up.
+func (s *Scheduler) run(ctx context.Context, name, action string) {
+	shed, err := s.client.GetShed(ctx, name)
+	if err != nil {
+		slog.Warn("Scheduled action failed", "shed", name, "action", action, "err", err)
+		return
+	}
+
+	switch action {
+	case config.ScheduleStart:
+		if shed.Status != config.StatusStopped {
+			return
+		}
+		if _, err := s.client.StartShed(ctx, name); err != nil {
+			slog.Warn("Failed to start shed on schedule", "shed", name, "err", err)
+			return
+		}
+		slog.Info("Started shed on schedule", "shed", name)
+	case config.ScheduleStop:
+		if shed.Status == config.StatusStopped {
+			return
+		}
+		if _, err := s.client.StopShed(ctx, name); err != nil {
+			slog.Warn("Failed to stop shed on schedule", "shed", name, "err", err)
+			return
+		}
+		s.client.stopReasons.set(name, config.StopReasonSchedule)
+		slog.Info("Stopped shed on schedule", "shed", name)
+	case config.ScheduleBackup:
+		go s.client.backupSheds(ctx, []string{name})
+	}
+}
@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestDueSchedules(t *testing.T) {
+	all := map[string]map[string]string{
+		"api":     {config.ScheduleStart: "30 8 * * 1-5", config.ScheduleStop: "0 19 * * 1-5"},
+		"web":     {config.ScheduleStop: "0 19 * * *"},
+		"nightly": {config.ScheduleStop: "0 19 * * *", config.ScheduleStart: "0 19 * * *", config.ScheduleBackup: "0 19 * * *"},
+		"broken":  {config.ScheduleStop: "not cron"},
+	}
+	// A Wednesday
+	minute := time.Date(2026, 4, 15, 19, 0, 0, 0, time.UTC)
+
+	got := dueSchedules(all, minute)
+	want := []dueAction{
+		{shed: "api", action: config.ScheduleStop},
+		{shed: "nightly", action: config.ScheduleStop},
+		{shed: "nightly", action: config.ScheduleStart},
+		{shed: "nightly", action: config.ScheduleBackup},
+		{shed: "web", action: config.ScheduleStop},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dueSchedules() = %+v, want %+v", got, want)
+	}
+
+	// Saturday morning runs nothing
+	if got := dueSchedules(all, time.Date(2026, 4, 18, 8, 30, 0, 0, time.UTC)); len(got) != 0 {
+		t.Errorf("dueSchedules() on Saturday = %+v, want none", got)
+	}
+}
+
+func TestNewSchedule(t *testing.T) {
+	now := time.Date(2026, 4, 15, 19, 0, 30, 0, time.UTC)
+	s := newSchedule("api", config.ScheduleStop, "0 19 * * 1-5", now)
+	if want := time.Date(2026, 4, 16, 19, 0, 0, 0, time.UTC); !s.Next.Equal(want) {
+		t.Errorf("Next = %v, want %v", s.Next, want)
+	}
+	if s := newSchedule("api", config.ScheduleStop, "bad", now); !s.Next.IsZero() {
+		t.Errorf("Next of an invalid schedule = %v, want zero", s.Next)
+	}
+}
+
+func TestMapStoreAll(t *testing.T) {
+	dir := t.TempDir()
+	store := loadMaps(openState(t, dir), dir, schedulesFile, "schedules")
+	if err := store.set("api", map[string]string{config.ScheduleStop: "0 19 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+
+	all := store.all()
+	all["api"][config.ScheduleStop] = "changed"
+	if got, _ := store.get("api"); got[config.ScheduleStop] != "0 19 * * *" {
+		t.Errorf("all() returned the stored map instead of a copy: %v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// secretsTmpfsOptions mount the in-memory directory secret files are
+// written to, so their values never reach the container's disk.
+const secretsTmpfsOptions = "mode=0755,size=16m"
+
+// secretsFromLabels returns a shed's secret references, or nil if it has
+// none.
+func secretsFromLabels(labels map[string]string) []config.SecretRef {
+	v := labels[config.LabelSecrets]
+	if v == "" {
+		return nil
+	}
+	var refs []config.SecretRef
+	if err := json.Unmarshal([]byte(v), &refs); err != nil {
+		slog.Warn("Ignoring invalid label", "label", config.LabelSecrets, "err", err)
+		return nil
+	}
+	return refs
+}
+
+// WriteSecretFiles writes a running shed's file secrets to its secrets
+// directory. The directory is in memory, so this is needed each time the
+// container starts.
+func (c *Client) WriteSecretFiles(ctx context.Context, name string) error {
+	ctr, err := c.docker.ContainerInspect(ctx, config.ContainerName(name))
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return shedNotFound(name)
+		}
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return c.writeSecretFiles(ctx, ctr.ID, secretsFromLabels(ctr.Config.Labels))
+}
+
+// writeSecretFileScript writes stdin to a file ($0) in the secrets
+// directory, readable only by the user the container's main process runs
+// as.
+const writeSecretFileScript = `umask 077; cat > "$0" && chmod 0400 "$0" && chown "$(stat -c %u:%g /proc/1)" "$0"`
+
+// writeSecretFiles writes the file secrets among refs into a container,
+// readable only by the container's user. Secrets that can't be read are
+// skipped and reported in the error.
+func (c *Client) writeSecretFiles(ctx context.Context, containerID string, refs []config.SecretRef) error {
+	var errs []error
+	for _, ref := range refs {
+		file := ref.FileName()
+		if file == "" {
+			continue
+		}
+		secret, err := c.secrets.Get(ref.Name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := c.writeSecretFile(ctx, containerID, file, secret.Value); err != nil {
+			return fmt.Errorf("failed to write secret %s: %w", ref.Name, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeSecretFile writes one file to a container's secrets directory. The
+// value is piped to a shell in the container rather than copied in with the
+// archive API, which writes beneath tmpfs mounts to the container's disk.
+func (c *Client) writeSecretFile(ctx context.Context, containerID, file, value string) error {
+	execResp, err := c.docker.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		User:         "root",
+		Cmd:          []string{"sh", "-c", writeSecretFileScript, path.Join(config.SecretsPath, file)},
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	attachResp, err := c.docker.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer attachResp.Close()
+
+	if _, err := io.WriteString(attachResp.Conn, value); err != nil {
+		return fmt.Errorf("failed to send secret: %w", err)
+	}
+	if err := attachResp.CloseWrite(); err != nil {
+		return fmt.Errorf("failed to send secret: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(io.Discard, &stderr, attachResp.Reader); err != nil {
+		return fmt.Errorf("failed to read exec output: %w", err)
+	}
+	inspectResp, err := c.docker.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	if inspectResp.ExitCode != 0 {
+		return fmt.Errorf("exit status %d: %s", inspectResp.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// secretEnv returns the environment variable secrets among refs as
+// NAME=value pairs. Secrets that can't be read are logged and skipped.
+func (c *Client) secretEnv(name string, refs []config.SecretRef) []string {
+	var env []string
+	for _, ref := range refs {
+		if ref.Env == "" {
+			continue
+		}
+		secret, err := c.secrets.Get(ref.Name)
+		if err != nil {
+			slog.Warn("Skipping secret", "shed", name, "secret", ref.Name, "err", err)
+			continue
+		}
+		env = append(env, ref.Env+"="+secret.Value)
+	}
+	return env
+}
@@ -0,0 +1,90 @@
+//go:build integration
+
+package docker
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// testEngine connects to the container engine named by DOCKER_HOST.
+func testEngine(t *testing.T) *client.Client {
+	t.Helper()
+	engine, err := Connect(&config.ServerConfig{})
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+// testContainer starts a busybox container running as an unprivileged user
+// with a secrets directory like a shed's.
+func testContainer(t *testing.T, engine *client.Client) string {
+	t.Helper()
+	ctx := context.Background()
+	const img = "busybox:1.36"
+
+	if _, err := engine.ImageInspect(ctx, img); err != nil {
+		rc, err := engine.ImagePull(ctx, img, image.PullOptions{})
+		if err != nil {
+			t.Fatalf("failed to pull %s: %v", img, err)
+		}
+		_, _ = io.Copy(io.Discard, rc)
+		rc.Close()
+	}
+
+	resp, err := engine.ContainerCreate(ctx,
+		&container.Config{Image: img, Cmd: []string{"sleep", "300"}, User: "1000:1000", WorkingDir: config.WorkspacePath},
+		&container.HostConfig{Tmpfs: map[string]string{config.SecretsPath: secretsTmpfsOptions}},
+		nil, nil, "")
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = engine.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+	})
+	if err := engine.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		t.Fatalf("failed to start container: %v", err)
+	}
+	return resp.ID
+}
+
+func TestWriteSecretFileEngine(t *testing.T) {
+	engine := testEngine(t)
+	id := testContainer(t, engine)
+	c := &Client{docker: engine}
+	ctx := context.Background()
+
+	if err := c.writeSecretFile(ctx, id, "token", "s3cret\n"); err != nil {
+		t.Fatalf("writeSecretFile() error = %v", err)
+	}
+
+	result, err := c.execOutput(ctx, id, []string{"sh", "-c", `cat /run/secrets/token; stat -c '%a %u' /run/secrets/token`}, nil)
+	if err != nil {
+		t.Fatalf("execOutput() error = %v", err)
+	}
+	if want := "s3cret\n400 1000\n"; result.Stdout != want {
+		t.Errorf("secret file = %q, want %q (stderr %q)", result.Stdout, want, result.Stderr)
+	}
+
+	// The value must stay in memory, not reach the container's writable
+	// layer
+	changes, err := engine.ContainerDiff(ctx, id)
+	if err != nil {
+		t.Fatalf("ContainerDiff() error = %v", err)
+	}
+	for _, change := range changes {
+		if strings.HasPrefix(change.Path, config.SecretsPath) {
+			t.Errorf("secret written to the container's disk: %s", change.Path)
+		}
+	}
+}
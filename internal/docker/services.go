@@ -0,0 +1,229 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// servicesFromLabels returns a shed's sidecars, or nil if it has none.
+func servicesFromLabels(labels map[string]string) []config.Service {
+	v := labels[config.LabelServices]
+	if v == "" {
+		return nil
+	}
+	var services []config.Service
+	if err := json.Unmarshal([]byte(v), &services); err != nil {
+		slog.Warn("Ignoring invalid label", "label", config.LabelServices, "err", err)
+		return nil
+	}
+	return services
+}
+
+// serviceConfig returns the container and host config for a sidecar of the
+// shed whose container is shedID. The sidecar gets the shed's resource
+// limits, so it can't use more of the host than the shed itself.
+func serviceConfig(shedName, shedID string, svc config.Service, limits config.Resources) (*container.Config, *container.HostConfig, error) {
+	env := make([]string, 0, len(svc.Env))
+	for _, k := range config.SortedLabelKeys(svc.Env) {
+		env = append(env, k+"="+svc.Env[k])
+	}
+
+	hostConfig := &container.HostConfig{
+		// Joining the shed's network namespace puts the sidecar on
+		// localhost for the shed, whatever network the shed is on
+		NetworkMode: container.NetworkMode("container:" + shedID),
+		RestartPolicy: container.RestartPolicy{
+			Name: container.RestartPolicyUnlessStopped,
+		},
+	}
+	if err := applyResources(&hostConfig.Resources, limits); err != nil {
+		return nil, nil, err
+	}
+
+	return &container.Config{
+		Image: svc.Image,
+		Cmd:   svc.Command,
+		Env:   env,
+		Labels: map[string]string{
+			config.LabelServiceOf:   shedName,
+			config.LabelServiceName: svc.Name,
+		},
+	}, hostConfig, nil
+}
+
+// createServices creates and starts a shed's sidecars, replacing any left
+// from an earlier container of the shed. Every sidecar is attempted, and
+// the failures returned together.
+func (c *Client) createServices(ctx context.Context, shedName, shedID string, services []config.Service, limits config.Resources) error {
+	// A rebuilt shed's old sidecars share the old container's network
+	if err := c.removeServices(ctx, shedName); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, svc := range services {
+		containerConfig, hostConfig, err := serviceConfig(shedName, shedID, svc, limits)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create service %s: %w", svc.Name, err))
+			continue
+		}
+		name := config.ServiceContainerName(shedName, svc.Name)
+		resp, err := c.docker.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create service %s: %w", svc.Name, err))
+			continue
+		}
+		if err := c.docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to start service %s: %w", svc.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// listServices returns a shed's sidecar containers, sorted by service name.
+func (c *Client) listServices(ctx context.Context, shedName string) ([]container.Summary, error) {
+	containers, err := c.docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", config.LabelServiceOf+"="+shedName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Labels[config.LabelServiceName] < containers[j].Labels[config.LabelServiceName]
+	})
+	return containers, nil
+}
+
+// startServices starts a shed's sidecars after the shed has started. Any
+// still running are restarted, since they were left in the network of the
+// shed's previous run.
+func (c *Client) startServices(ctx context.Context, shedName string) error {
+	containers, err := c.listServices(ctx, shedName)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ctr := range containers {
+		var err error
+		if ctr.State == "running" || ctr.State == "restarting" {
+			err = c.docker.ContainerRestart(ctx, ctr.ID, container.StopOptions{})
+		} else {
+			err = c.docker.ContainerStart(ctx, ctr.ID, container.StartOptions{})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to start service %s: %w", ctr.Labels[config.LabelServiceName], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopServices stops a shed's running sidecars.
+func (c *Client) stopServices(ctx context.Context, shedName string) error {
+	containers, err := c.listServices(ctx, shedName)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ctr := range containers {
+		if ctr.State != "running" && ctr.State != "paused" && ctr.State != "restarting" {
+			continue
+		}
+		if err := c.docker.ContainerStop(ctx, ctr.ID, container.StopOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop service %s: %w", ctr.Labels[config.LabelServiceName], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pauseServices freezes a shed's running sidecars along with the shed.
+func (c *Client) pauseServices(ctx context.Context, shedName string) error {
+	containers, err := c.listServices(ctx, shedName)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ctr := range containers {
+		if ctr.State != "running" {
+			continue
+		}
+		if err := c.docker.ContainerPause(ctx, ctr.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to pause service %s: %w", ctr.Labels[config.LabelServiceName], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// unpauseServices unfreezes a shed's paused sidecars.
+func (c *Client) unpauseServices(ctx context.Context, shedName string) error {
+	containers, err := c.listServices(ctx, shedName)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ctr := range containers {
+		if ctr.State != "paused" {
+			continue
+		}
+		if err := c.docker.ContainerUnpause(ctx, ctr.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to resume service %s: %w", ctr.Labels[config.LabelServiceName], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeServices removes a shed's sidecars along with their anonymous
+// volumes.
+func (c *Client) removeServices(ctx context.Context, shedName string) error {
+	containers, err := c.listServices(ctx, shedName)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ctr := range containers {
+		err := c.docker.ContainerRemove(ctx, ctr.ID, container.RemoveOptions{Force: true, RemoveVolumes: true})
+		if err != nil && !cerrdefs.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to remove service %s: %w", ctr.Labels[config.LabelServiceName], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addServiceStatus fills in the status of each of a shed's sidecars.
+// Sidecars without a container are reported as missing.
+func (c *Client) addServiceStatus(ctx context.Context, shed *config.Shed) {
+	if len(shed.Services) == 0 {
+		return
+	}
+	containers, err := c.listServices(ctx, shed.Name)
+	if err != nil {
+		slog.Debug("Failed to list services", "shed", shed.Name, "err", err)
+		return
+	}
+	setServiceStatus(shed.Services, containers)
+}
+
+// setServiceStatus sets the status of each service from its container.
+func setServiceStatus(services []config.Service, containers []container.Summary) {
+	states := make(map[string]string, len(containers))
+	for _, ctr := range containers {
+		states[ctr.Labels[config.LabelServiceName]] = containerStateToStatus(ctr.State)
+	}
+	for i := range services {
+		status, ok := states[services[i].Name]
+		if !ok {
+			status = config.ServiceMissing
+		}
+		services[i].Status = status
+	}
+}
@@ -0,0 +1,133 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestServiceConfig(t *testing.T) {
+	svc := config.Service{
+		Name:    "db",
+		Image:   "postgres:16",
+		Env:     map[string]string{"POSTGRES_PASSWORD": "dev", "POSTGRES_DB": "app"},
+		Command: []string{"postgres", "-c", "fsync=off"},
+	}
+
+	cfg, host, err := serviceConfig("dev", "abc123", svc, config.Resources{CPUs: 0.5, Memory: "256m", PidsLimit: 100})
+	if err != nil {
+		t.Fatalf("serviceConfig() error = %v", err)
+	}
+
+	if cfg.Image != "postgres:16" || !reflect.DeepEqual([]string(cfg.Cmd), svc.Command) {
+		t.Errorf("Image = %q, Cmd = %v", cfg.Image, cfg.Cmd)
+	}
+	if want := []string{"POSTGRES_DB=app", "POSTGRES_PASSWORD=dev"}; !reflect.DeepEqual(cfg.Env, want) {
+		t.Errorf("Env = %v, want %v", cfg.Env, want)
+	}
+	if cfg.Labels[config.LabelServiceOf] != "dev" || cfg.Labels[config.LabelServiceName] != "db" {
+		t.Errorf("Labels = %v", cfg.Labels)
+	}
+	// A sidecar must never be mistaken for a shed
+	if _, ok := cfg.Labels[config.LabelShed]; ok {
+		t.Errorf("sidecar has the %s label", config.LabelShed)
+	}
+	if host.NetworkMode != "container:abc123" {
+		t.Errorf("NetworkMode = %q, want container:abc123", host.NetworkMode)
+	}
+	if host.NanoCPUs != 5e8 || host.Memory != 256<<20 || host.PidsLimit == nil || *host.PidsLimit != 100 {
+		t.Errorf("Resources = %+v, want the shed's limits", host.Resources)
+	}
+
+	if _, _, err := serviceConfig("dev", "abc123", svc, config.Resources{Memory: "lots"}); err == nil {
+		t.Error("serviceConfig() with invalid memory succeeded")
+	}
+}
+
+// sidecarEngine serves a shed's sidecars in the given states, recording the
+// pauses and unpauses made.
+type sidecarEngine struct {
+	mu         sync.Mutex
+	containers []container.Summary
+	calls      []string
+}
+
+func (e *sidecarEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/containers/json"):
+		_ = json.NewEncoder(w).Encode(e.containers)
+	case strings.HasSuffix(r.URL.Path, "/pause"), strings.HasSuffix(r.URL.Path, "/unpause"):
+		parts := strings.Split(r.URL.Path, "/")
+		e.calls = append(e.calls, parts[len(parts)-1]+" "+parts[len(parts)-2])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPauseServices(t *testing.T) {
+	engine := &sidecarEngine{containers: []container.Summary{
+		{ID: "db", State: "running", Labels: map[string]string{config.LabelServiceName: "db"}},
+		{ID: "cache", State: "paused", Labels: map[string]string{config.LabelServiceName: "cache"}},
+		{ID: "search", State: "exited", Labels: map[string]string{config.LabelServiceName: "search"}},
+	}}
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	docker, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.47"))
+	if err != nil {
+		t.Fatalf("NewClientWithOpts() error = %v", err)
+	}
+	defer docker.Close()
+	c := &Client{docker: docker}
+
+	if err := c.pauseServices(context.Background(), "dev"); err != nil {
+		t.Fatalf("pauseServices() error = %v", err)
+	}
+	if err := c.unpauseServices(context.Background(), "dev"); err != nil {
+		t.Fatalf("unpauseServices() error = %v", err)
+	}
+	// Only running sidecars are paused, and only paused ones resumed
+	if want := []string{"pause db", "unpause cache"}; !slices.Equal(engine.calls, want) {
+		t.Errorf("calls = %q, want %q", engine.calls, want)
+	}
+}
+
+func TestSetServiceStatus(t *testing.T) {
+	services := []config.Service{{Name: "cache"}, {Name: "db"}, {Name: "search"}}
+	containers := []container.Summary{
+		{State: "running", Labels: map[string]string{config.LabelServiceName: "db"}},
+		{State: "exited", Labels: map[string]string{config.LabelServiceName: "cache"}},
+	}
+
+	setServiceStatus(services, containers)
+
+	want := []string{config.StatusStopped, config.StatusRunning, config.ServiceMissing}
+	for i, svc := range services {
+		if svc.Status != want[i] {
+			t.Errorf("%s status = %q, want %q", svc.Name, svc.Status, want[i])
+		}
+	}
+}
+
+func TestServicesFromLabels(t *testing.T) {
+	if got := servicesFromLabels(map[string]string{}); got != nil {
+		t.Errorf("servicesFromLabels() without label = %v, want nil", got)
+	}
+	if got := servicesFromLabels(map[string]string{config.LabelServices: "not json"}); got != nil {
+		t.Errorf("servicesFromLabels() with invalid label = %v, want nil", got)
+	}
+}
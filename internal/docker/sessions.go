@@ -0,0 +1,315 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// tmuxSessionFormat is the list-sessions format string: name, attached clients,
+// window count, and last activity as a unix timestamp, separated by tabs.
+const tmuxSessionFormat = "#{session_name}\t#{session_attached}\t#{session_windows}\t#{session_activity}"
+
+// tmuxPaneFormat is the list-panes format string: owning session and the
+// command currently running in the pane.
+const tmuxPaneFormat = "#{session_name}\t#{pane_current_command}"
+
+// ListSessions returns the sessions running inside a shed, most recently
+// active first. A shed with no multiplexer, or none running, has no
+// sessions.
+func (c *Client) ListSessions(ctx context.Context, name string) ([]config.Session, error) {
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+
+	mux, err := c.multiplexer(ctx, shed.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+	if mux == nil {
+		return []config.Session{}, nil
+	}
+
+	return mux.listSessions(ctx)
+}
+
+// CreateSession starts a detached session in a running shed, running
+// req.Command in it if set. The session gets the same environment as SSH
+// sessions.
+func (c *Client) CreateSession(ctx context.Context, name string, req config.CreateSessionRequest) (*config.Session, error) {
+	shed, mux, err := c.sessionShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := mux.hasSession(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("session %q already exists in shed %q", req.Name, name)
+	}
+
+	env, err := c.SessionEnv(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := mux.newSession(ctx, req.Name, req.Command, append(env, "TERM="+config.DefaultTerminalTerm, "SHED_NAME="+shed.Name)); err != nil {
+		return nil, err
+	}
+
+	return c.findSession(ctx, name, req.Name)
+}
+
+// RenameSession renames a session in a running shed.
+func (c *Client) RenameSession(ctx context.Context, name, session string, req config.RenameSessionRequest) (*config.Session, error) {
+	_, mux, err := c.sessionShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists, err := mux.hasSession(ctx, session); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, fmt.Errorf("session %q not found in shed %q", session, name)
+	}
+	if req.Name != session {
+		if exists, err := mux.hasSession(ctx, req.Name); err != nil {
+			return nil, err
+		} else if exists {
+			return nil, fmt.Errorf("session %q already exists in shed %q", req.Name, name)
+		}
+	}
+
+	if err := mux.renameSession(ctx, session, req.Name); err != nil {
+		return nil, err
+	}
+
+	return c.findSession(ctx, name, req.Name)
+}
+
+// KillSession ends a session in a running shed, along with everything
+// running in it.
+func (c *Client) KillSession(ctx context.Context, name, session string) error {
+	_, mux, err := c.sessionShed(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if exists, err := mux.hasSession(ctx, session); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("session %q not found in shed %q", session, name)
+	}
+
+	return mux.killSession(ctx, session)
+}
+
+// sessionShells are the pane commands of a session sitting at a prompt,
+// ready to be typed a command.
+var sessionShells = []string{"bash", "zsh", "sh", "dash", "ash", "ksh"}
+
+// ExecInSession types a command into a tmux session's shell, creating the
+// session if needed, and waits for it to finish. Sheds using another
+// multiplexer don't support it. The command runs in a
+// subshell of the session's shell, so it starts in the shell's directory
+// with its variables, and its output is shown in the session as well as
+// returned. If ctx ends first the command is left running.
+func (c *Client) ExecInSession(ctx context.Context, name, session, command string) (*config.SessionExecResult, error) {
+	shed, mux, err := c.sessionShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	// Waiting for the command relies on tmux wait-for
+	if mux.name() != config.MultiplexerTmux {
+		return nil, fmt.Errorf("running commands in sessions is not supported in shed %q, which uses %s", name, mux.name())
+	}
+
+	exists, err := mux.hasSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if _, err := c.CreateSession(ctx, name, config.CreateSessionRequest{Name: session}); err != nil {
+			return nil, err
+		}
+	} else {
+		// Keys typed into a running program would go to it, not a shell
+		current, err := c.execOutput(ctx, shed.ContainerID, []string{"tmux", "display-message", "-p", "-t", "=" + session, "#{pane_current_command}"}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect session: %w", err)
+		}
+		if cmd := strings.TrimSpace(current.Stdout); !slices.Contains(sessionShells, cmd) {
+			return nil, fmt.Errorf("session %q is busy running %s", session, cmd)
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate exec id: %w", err)
+	}
+	channel := "shed-exec-" + hex.EncodeToString(id)
+	base := "/tmp/" + channel
+
+	for _, keys := range [][]string{{"-l", sessionExecLine(command, base, channel)}, {"Enter"}} {
+		cmd := append([]string{"tmux", "send-keys", "-t", "=" + session}, keys...)
+		result, err := c.execOutput(ctx, shed.ContainerID, cmd, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send command: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return nil, fmt.Errorf("failed to send command: %s", strings.TrimSpace(result.Stderr))
+		}
+	}
+
+	// tmux remembers a signal sent before anyone waits, so this can't miss
+	// a command that finishes quickly
+	if _, err := c.execOutput(ctx, shed.ContainerID, []string{"tmux", "wait-for", channel}, nil); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to wait for command: %w", err)
+	}
+
+	result := &config.SessionExecResult{Session: session}
+	status, err := c.execOutput(ctx, shed.ContainerID, []string{"cat", base + ".status"}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exit status: %w", err)
+	}
+	if status.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to read exit status: %s", strings.TrimSpace(status.Stderr))
+	}
+	result.ExitCode, _ = strconv.Atoi(strings.TrimSpace(status.Stdout))
+
+	output, err := c.execOutput(ctx, shed.ContainerID, []string{"tail", "-c", strconv.Itoa(config.MaxSessionExecOutput + 1), base + ".out"}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output: %w", err)
+	}
+	result.Output = output.Stdout
+	if len(result.Output) > config.MaxSessionExecOutput {
+		result.Output = result.Output[len(result.Output)-config.MaxSessionExecOutput:]
+		result.Truncated = true
+	}
+
+	if _, err := c.execOutput(ctx, shed.ContainerID, []string{"rm", "-f", base + ".status", base + ".out"}, nil); err != nil {
+		slog.Debug("Failed to remove exec output", "shed", name, "err", err)
+	}
+	return result, nil
+}
+
+// sessionExecLine returns the line typed into a session's shell to run
+// command, saving its exit status to base.status and its output to
+// base.out, then signal channel. The newline after the command ends any
+// comment in it.
+func sessionExecLine(command, base, channel string) string {
+	return fmt.Sprintf("{ %s\necho $? > %s.status; } 2>&1 | tee %s.out; tmux wait-for -S %s", command, base, base, channel)
+}
+
+// runningShed returns a shed, failing unless it is running.
+func (c *Client) runningShed(ctx context.Context, name string) (*config.Shed, error) {
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	switch shed.Status {
+	case config.StatusRunning:
+		return shed, nil
+	case config.StatusPaused:
+		return nil, fmt.Errorf("shed %q is paused", name)
+	default:
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+}
+
+// sessionShed returns a running shed and its multiplexer, failing if it
+// has none.
+func (c *Client) sessionShed(ctx context.Context, name string) (*config.Shed, multiplexer, error) {
+	shed, err := c.runningShed(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	mux, err := c.requireMultiplexer(ctx, shed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return shed, mux, nil
+}
+
+// findSession returns a session of a shed by name. A session whose command
+// has already exited is returned with only its name.
+func (c *Client) findSession(ctx context.Context, name, session string) (*config.Session, error) {
+	sessions, err := c.ListSessions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		if sessions[i].Name == session {
+			return &sessions[i], nil
+		}
+	}
+	return &config.Session{Name: session}, nil
+}
+
+// parseTmuxSessions parses list-sessions output produced with tmuxSessionFormat.
+// Malformed lines are skipped.
+func parseTmuxSessions(output string) []config.Session {
+	sessions := []config.Session{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 4 || fields[0] == "" {
+			continue
+		}
+
+		attached, _ := strconv.Atoi(fields[1])
+		windows, _ := strconv.Atoi(fields[2])
+
+		var lastActivity time.Time
+		if ts, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+			lastActivity = time.Unix(ts, 0).UTC()
+		}
+
+		sessions = append(sessions, config.Session{
+			Name:         fields[0],
+			Attached:     attached > 0,
+			Windows:      windows,
+			LastActivity: lastActivity,
+		})
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastActivity.After(sessions[j].LastActivity)
+	})
+
+	return sessions
+}
+
+// parseTmuxPanes parses list-panes output produced with tmuxPaneFormat into
+// a map of session name to the commands running in its panes.
+func parseTmuxPanes(output string) map[string][]string {
+	panes := make(map[string][]string)
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 2 || fields[0] == "" {
+			continue
+		}
+		panes[fields[0]] = append(panes[fields[0]], fields[1])
+	}
+
+	return panes
+}
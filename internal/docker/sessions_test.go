@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTmuxSessions(t *testing.T) {
+	output := "default\t0\t1\t1700000000\n" +
+		"debug\t1\t3\t1700000600\n" +
+		"malformed line\n"
+
+	sessions := parseTmuxSessions(output)
+	if len(sessions) != 2 {
+		t.Fatalf("len(sessions) = %d, want 2", len(sessions))
+	}
+
+	// Most recently active first
+	if sessions[0].Name != "debug" {
+		t.Errorf("sessions[0].Name = %q, want %q", sessions[0].Name, "debug")
+	}
+	if !sessions[0].Attached {
+		t.Error("sessions[0].Attached = false, want true")
+	}
+	if sessions[0].Windows != 3 {
+		t.Errorf("sessions[0].Windows = %d, want 3", sessions[0].Windows)
+	}
+	if want := time.Unix(1700000600, 0).UTC(); !sessions[0].LastActivity.Equal(want) {
+		t.Errorf("sessions[0].LastActivity = %v, want %v", sessions[0].LastActivity, want)
+	}
+	if sessions[1].Attached {
+		t.Error("sessions[1].Attached = true, want false")
+	}
+}
+
+func TestParseTmuxPanes(t *testing.T) {
+	panes := parseTmuxPanes("default\tbash\ndefault\tvim\ndebug\tnode\n")
+
+	if got := len(panes["default"]); got != 2 {
+		t.Errorf("len(panes[default]) = %d, want 2", got)
+	}
+	if got := panes["debug"]; len(got) != 1 || got[0] != "node" {
+		t.Errorf("panes[debug] = %v, want [node]", got)
+	}
+}
+
+func TestSessionExecLine(t *testing.T) {
+	got := sessionExecLine("make test # all", "/tmp/shed-exec-1", "shed-exec-1")
+	want := "{ make test # all\necho $? > /tmp/shed-exec-1.status; } 2>&1 | tee /tmp/shed-exec-1.out; tmux wait-for -S shed-exec-1"
+	if got != want {
+		t.Errorf("sessionExecLine() = %q, want %q", got, want)
+	}
+}
+
+func TestParseZellijSessions(t *testing.T) {
+	output := "dev [Created 5m 2s ago] (current)\n" +
+		"old [Created 2h ago] (EXITED - attach to resurrect)\n" +
+		"\n" +
+		"build [Created 10s ago]\n"
+
+	sessions := parseZellijSessions(output)
+	if len(sessions) != 2 {
+		t.Fatalf("len(sessions) = %d, want 2", len(sessions))
+	}
+	if sessions[0].Name != "dev" || sessions[1].Name != "build" {
+		t.Errorf("sessions = %q, %q, want dev, build", sessions[0].Name, sessions[1].Name)
+	}
+}
@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// retainedBuckets hold state that outlives a shed. Its backups can still
+// restore it after it is deleted, so their status is kept.
+var retainedBuckets = []string{backupsBucket}
+
+// forget removes everything the state store holds for a shed, except what
+// is in retainedBuckets.
+func (c *Client) forget(name string) {
+	if err := c.state.Forget(name, retainedBuckets...); err != nil {
+		slog.Warn("Failed to clear state", "shed", name, "err", err)
+	}
+}
+
+// RecordActivity remembers when a shed was last used, so it is still
+// reported after the server restarts.
+func (c *Client) RecordActivity(name string, at time.Time) {
+	c.activity.set(name, at.UTC().Format(time.RFC3339))
+}
+
+// lastActivity returns when a shed was last used, or nil if it hasn't been
+// used since the server started keeping track.
+func (c *Client) lastActivity(name string) *time.Time {
+	at, err := time.Parse(time.RFC3339, c.activity.get(name))
+	if err != nil {
+		return nil
+	}
+	return &at
+}
+
+// ReconcileState forgets the state of sheds whose containers are gone,
+// such as ones removed with docker rm while the server was down. Docker is
+// the source of truth for which sheds exist.
+func (c *Client) ReconcileState(ctx context.Context) error {
+	sheds, err := c.ListSheds(ctx)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(sheds))
+	for _, shed := range sheds {
+		exists[shed.Name] = true
+	}
+
+	names, err := c.state.Names(retainedBuckets...)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !exists[name] {
+			slog.Info("Forgetting state of missing shed", "shed", name)
+			c.forget(name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// GetShedStats returns resource usage and session activity for a running shed.
+// Workspace size and session details are only gathered if activity is true,
+// since they require running commands in the shed. They are best effort and
+// left zero if they cannot be determined.
+func (c *Client) GetShedStats(ctx context.Context, name string, activity bool) (*config.ShedStats, error) {
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+
+	// A non-streaming request waits for a second sample so CPU usage can be computed
+	statsResp, err := c.docker.ContainerStats(ctx, shed.ContainerID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	defer statsResp.Body.Close()
+
+	var raw container.StatsResponse
+	if err := json.NewDecoder(statsResp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+
+	stats := &config.ShedStats{
+		Name:        name,
+		CPUPercent:  cpuPercent(raw),
+		MemoryUsage: memoryUsage(raw.MemoryStats),
+		MemoryLimit: raw.MemoryStats.Limit,
+		PIDs:        raw.PidsStats.Current,
+	}
+	for _, nw := range raw.Networks {
+		stats.NetworkRx += nw.RxBytes
+		stats.NetworkTx += nw.TxBytes
+	}
+	stats.BlockRead, stats.BlockWrite = blockIO(raw.BlkioStats)
+
+	if !activity {
+		return stats, nil
+	}
+
+	if result, err := c.execOutput(ctx, shed.ContainerID, []string{"du", "-sb", config.WorkspacePath}, nil); err == nil && result.ExitCode == 0 {
+		if fields := strings.Fields(result.Stdout); len(fields) > 0 {
+			stats.WorkspaceSize, _ = strconv.ParseInt(fields[0], 10, 64)
+		}
+	}
+
+	if sessions, err := c.ListSessions(ctx, name); err == nil {
+		stats.Sessions = len(sessions)
+		if len(sessions) > 0 {
+			// Sessions are ordered most recently active first
+			stats.LastActivity = sessions[0].LastActivity
+		}
+	}
+
+	return stats, nil
+}
+
+// cpuPercent computes CPU usage as a percentage of one CPU from the delta
+// between the current and previous samples, matching `docker stats`.
+func cpuPercent(stats container.StatsResponse) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	return cpuDelta / systemDelta * onlineCPUs * 100
+}
+
+// blockIO returns the bytes read and written across all block devices,
+// matching `docker stats`.
+func blockIO(blkio container.BlkioStats) (read, write uint64) {
+	for _, entry := range blkio.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	return read, write
+}
+
+// memoryUsage returns memory usage excluding the page cache, matching `docker stats`.
+func memoryUsage(mem container.MemoryStats) uint64 {
+	// cgroup v2 reports inactive_file; cgroup v1 reports total_inactive_file
+	for _, key := range []string{"inactive_file", "total_inactive_file"} {
+		if cache, ok := mem.Stats[key]; ok && cache < mem.Usage {
+			return mem.Usage - cache
+		}
+	}
+	return mem.Usage
+}
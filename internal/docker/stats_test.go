@@ -0,0 +1,24 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestBlockIO(t *testing.T) {
+	blkio := container.BlkioStats{
+		IoServiceBytesRecursive: []container.BlkioStatEntry{
+			{Major: 8, Minor: 0, Op: "read", Value: 100},
+			{Major: 8, Minor: 0, Op: "write", Value: 40},
+			{Major: 8, Minor: 16, Op: "Read", Value: 20},
+			{Major: 8, Minor: 16, Op: "Write", Value: 2},
+			{Major: 8, Minor: 16, Op: "Total", Value: 22},
+		},
+	}
+
+	read, write := blockIO(blkio)
+	if read != 120 || write != 42 {
+		t.Errorf("blockIO() = %d, %d, want 120, 42", read, write)
+	}
+}
@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// Terminal is an interactive shell with a TTY running in a shed. Reads
+// return the terminal's output and writes are its input.
+type Terminal struct {
+	client *Client
+	execID string
+	conn   types.HijackedResponse
+}
+
+// OpenTerminal starts a login shell, or attaches to a session, in a
+// running shed.
+func (c *Client) OpenTerminal(ctx context.Context, name string, req config.TerminalRequest) (*Terminal, error) {
+	shed, err := c.GetShed(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	switch shed.Status {
+	case config.StatusRunning:
+	case config.StatusPaused:
+		return nil, fmt.Errorf("shed %q is paused", name)
+	default:
+		return nil, fmt.Errorf("shed %q is not running", name)
+	}
+
+	cmd := []string{"/bin/bash", "--login"}
+	if req.Session != "" {
+		mux, err := c.requireMultiplexer(ctx, shed)
+		if err != nil {
+			return nil, err
+		}
+		cmd = mux.attachCommand(req.Session)
+	}
+	term := req.Term
+	if term == "" {
+		term = config.DefaultTerminalTerm
+	}
+
+	env, err := c.SessionEnv(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	execConfig := container.ExecOptions{
+		Cmd:          cmd,
+		Env:          append(env, "TERM="+term, "SHED_NAME="+name),
+		WorkingDir:   config.WorkspacePath,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+	}
+	if req.Cols > 0 && req.Rows > 0 {
+		execConfig.ConsoleSize = &[2]uint{req.Rows, req.Cols}
+	}
+
+	execResp, err := c.docker.ContainerExecCreate(ctx, shed.ContainerID, execConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	conn, err := c.docker.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{
+		Tty:         true,
+		ConsoleSize: execConfig.ConsoleSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+
+	return &Terminal{client: c, execID: execResp.ID, conn: conn}, nil
+}
+
+// Read reads terminal output. It returns io.EOF once the shell exits.
+func (t *Terminal) Read(p []byte) (int, error) {
+	return t.conn.Reader.Read(p)
+}
+
+// Write sends input to the terminal.
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.conn.Conn.Write(p)
+}
+
+// Resize changes the terminal size.
+func (t *Terminal) Resize(ctx context.Context, cols, rows uint) error {
+	return t.client.docker.ContainerExecResize(ctx, t.execID, container.ResizeOptions{
+		Width:  cols,
+		Height: rows,
+	})
+}
+
+// ExitCode returns the shell's exit code once its output has ended.
+func (t *Terminal) ExitCode(ctx context.Context) (int, error) {
+	inspect, err := t.client.docker.ContainerExecInspect(ctx, t.execID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return inspect.ExitCode, nil
+}
+
+// Close disconnects from the terminal. The shell gets a hangup, so tmux
+// sessions keep running while plain shells exit.
+func (t *Terminal) Close() error {
+	t.conn.Close()
+	return nil
+}
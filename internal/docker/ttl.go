@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// expiredFile is the legacy state file recording the sheds whose TTL has
+// been acted on, so a stopped shed started again isn't stopped every minute.
+const expiredFile = "expired.json"
+
+// expiryCheckInterval is how often the reaper looks for expired sheds.
+const expiryCheckInterval = time.Minute
+
+// expiresAtFromLabels returns when a shed created with a TTL expires, or
+// nil if it has none.
+func expiresAtFromLabels(labels map[string]string) *time.Time {
+	if labels[config.LabelTTL] == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(labels[config.LabelTTL])
+	if err != nil {
+		slog.Warn("Ignoring invalid label", "label", config.LabelTTL, "err", err)
+		return nil
+	}
+	created, err := time.Parse(time.RFC3339, labels[config.LabelShedCreated])
+	if err != nil {
+		return nil
+	}
+	expires := created.Add(ttl)
+	return &expires
+}
+
+// ExpiryReaper deletes or stops sheds whose TTL has run out.
+type ExpiryReaper struct {
+	client   *Client
+	onDelete func(name string)
+}
+
+// NewExpiryReaper creates an ExpiryReaper for the sheds of c. onDelete is
+// called with the name of each shed it deletes.
+func NewExpiryReaper(c *Client, onDelete func(name string)) *ExpiryReaper {
+	return &ExpiryReaper{client: c, onDelete: onDelete}
+}
+
+// Run checks for expired sheds once per interval until the context is
+// cancelled.
+func (r *ExpiryReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(expiryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		r.reap(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap acts on each shed that has expired by now and hasn't been acted on.
+func (r *ExpiryReaper) reap(ctx context.Context, now time.Time) {
+	sheds, err := r.client.ListSheds(ctx)
+	if err != nil {
+		slog.Warn("Expiry check failed", "err", err)
+		return
+	}
+
+	for _, shed := range expiredSheds(sheds, now) {
+		if r.client.expired.get(shed.Name) != "" {
+			continue
+		}
+
+		if shed.TTLAction == config.TTLStop {
+			if shed.Status != config.StatusStopped {
+				if _, err := r.client.StopShed(ctx, shed.Name); err != nil {
+					slog.Warn("Failed to stop expired shed", "shed", shed.Name, "err", err)
+					continue
+				}
+				r.client.stopReasons.set(shed.Name, config.StopReasonExpired)
+			}
+			r.client.expired.set(shed.Name, now.UTC().Format(time.RFC3339))
+			slog.Info("Stopped expired shed", "shed", shed.Name)
+			continue
+		}
+
+		if err := r.client.DeleteShed(ctx, shed.Name, false); err != nil {
+			slog.Warn("Failed to delete expired shed", "shed", shed.Name, "err", err)
+			continue
+		}
+		slog.Info("Deleted expired shed", "shed", shed.Name)
+		if r.onDelete != nil {
+			r.onDelete(shed.Name)
+		}
+	}
+}
+
+// expiredSheds returns the sheds whose TTL has run out by now.
+func expiredSheds(sheds []config.Shed, now time.Time) []config.Shed {
+	var expired []config.Shed
+	for _, shed := range sheds {
+		if shed.ExpiresAt != nil && !now.Before(*shed.ExpiresAt) {
+			expired = append(expired, shed)
+		}
+	}
+	return expired
+}
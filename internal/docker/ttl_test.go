@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestExpiresAtFromLabels(t *testing.T) {
+	labels := map[string]string{
+		config.LabelShedCreated: "2026-05-01T12:00:00Z",
+		config.LabelTTL:         "90m",
+	}
+	got := expiresAtFromLabels(labels)
+	want := time.Date(2026, 5, 1, 13, 30, 0, 0, time.UTC)
+	if got == nil || !got.Equal(want) {
+		t.Errorf("expiresAtFromLabels() = %v, want %v", got, want)
+	}
+
+	delete(labels, config.LabelTTL)
+	if got := expiresAtFromLabels(labels); got != nil {
+		t.Errorf("expiresAtFromLabels() without a ttl = %v, want nil", got)
+	}
+}
+
+func TestExpiredSheds(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	sheds := []config.Shed{
+		{Name: "expired", ExpiresAt: &past},
+		{Name: "now", ExpiresAt: &now},
+		{Name: "later", ExpiresAt: &future},
+		{Name: "forever"},
+	}
+
+	got := expiredSheds(sheds, now)
+	if len(got) != 2 || got[0].Name != "expired" || got[1].Name != "now" {
+		t.Errorf("expiredSheds() = %+v, want expired and now", got)
+	}
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// WaitForStatus blocks until a shed reaches the given status, returning the
+// shed once it does. Rather than polling, the shed is re-checked each time
+// Docker reports an event for its container. The wait ends early if the
+// context is done or the shed enters the error state.
+func (c *Client) WaitForStatus(ctx context.Context, name, status string) (*config.Shed, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Subscribe before the first check so no transition is missed
+	filterArgs := filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("container", config.ContainerName(name)),
+	)
+	msgs, errs := c.docker.Events(ctx, events.ListOptions{Filters: filterArgs})
+
+	for {
+		shed, err := c.GetShed(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if shed.Status == status {
+			return shed, nil
+		}
+		if shed.Status == config.StatusError {
+			return nil, fmt.Errorf("shed %q entered error state", name)
+		}
+
+		select {
+		case <-msgs:
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to watch shed events: %w", err)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
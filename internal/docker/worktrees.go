@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// repoCacheGitDir is the bare repository inside a shared clone cache volume.
+const repoCacheGitDir = config.RepoCachePath + "/repo.git"
+
+// worktreeAddScript fetches the shared bare clone (creating it on first use)
+// and adds the shed's workspace as a worktree. The worktree metadata is
+// renamed after the shed so it can be found again on delete, since every
+// shed's worktree lives at the same /workspace path. A lock serializes
+// concurrent creates against the same cache.
+const worktreeAddScript = `set -e
+exec 9>"$SHED_CACHE/.lock"
+if command -v flock >/dev/null 2>&1; then flock 9; fi
+
+if [ ! -d "$SHED_GIT_DIR" ]; then
+	git init --bare --quiet "$SHED_GIT_DIR"
+	git -C "$SHED_GIT_DIR" remote add origin "$SHED_REPO"
+fi
+git -C "$SHED_GIT_DIR" fetch --quiet --prune origin
+git -C "$SHED_GIT_DIR" remote set-head origin --auto >/dev/null
+
+# Drop metadata left behind by a previous shed with the same name
+rm -rf "$SHED_GIT_DIR/worktrees/$SHED_NAME"
+git -C "$SHED_GIT_DIR" worktree prune
+
+if [ -n "$SHED_BRANCH" ]; then
+	git -C "$SHED_GIT_DIR" worktree add --quiet "$SHED_WORKSPACE" "$SHED_BRANCH"
+elif git -C "$SHED_GIT_DIR" show-ref --verify --quiet "refs/heads/shed/$SHED_NAME"; then
+	git -C "$SHED_GIT_DIR" worktree add --quiet "$SHED_WORKSPACE" "shed/$SHED_NAME"
+else
+	git -C "$SHED_GIT_DIR" worktree add --quiet -b "shed/$SHED_NAME" "$SHED_WORKSPACE" origin/HEAD
+fi
+
+name=$(sed -n 's|^gitdir: .*/worktrees/||p' "$SHED_WORKSPACE/.git")
+if [ "$name" != "$SHED_NAME" ]; then
+	mv "$SHED_GIT_DIR/worktrees/$name" "$SHED_GIT_DIR/worktrees/$SHED_NAME"
+	echo "gitdir: $SHED_GIT_DIR/worktrees/$SHED_NAME" > "$SHED_WORKSPACE/.git"
+fi
+`
+
+// repoCacheMount returns the mount for a repository's shared clone cache volume.
+func repoCacheMount(repo string) mount.Mount {
+	return mount.Mount{
+		Type:   mount.TypeVolume,
+		Source: config.RepoCacheVolumeName(repo),
+		Target: config.RepoCachePath,
+	}
+}
+
+// createRepoCacheVolume creates the shared clone cache volume for a repository
+// if it does not already exist.
+func (c *Client) createRepoCacheVolume(ctx context.Context, repo string) error {
+	volumeName := config.RepoCacheVolumeName(repo)
+
+	// Volume creation is idempotent: an existing volume is returned as-is
+	_, err := c.docker.VolumeCreate(ctx, volume.CreateOptions{
+		Name: volumeName,
+		Labels: map[string]string{
+			config.LabelShed:      "true",
+			config.LabelRepoCache: repo,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create repo cache volume %s: %w", volumeName, err)
+	}
+
+	return nil
+}
+
+// addWorktree checks the repository out into the shed's workspace as a
+// worktree of the shared clone.
+func (c *Client) addWorktree(ctx context.Context, containerID, shedName, repo, branch string) error {
+	env := []string{
+		"SHED_CACHE=" + config.RepoCachePath,
+		"SHED_GIT_DIR=" + repoCacheGitDir,
+		"SHED_REPO=" + repo,
+		"SHED_BRANCH=" + branch,
+		"SHED_NAME=" + shedName,
+		"SHED_WORKSPACE=" + config.WorkspacePath,
+	}
+
+	result, err := c.execOutput(ctx, containerID, []string{"/bin/sh", "-c", worktreeAddScript}, env)
+	if err != nil {
+		return fmt.Errorf("failed to add worktree: %w", err)
+	}
+
+	if result.ExitCode != 0 {
+		return fmt.Errorf("git worktree add failed with exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	return nil
+}
+
+// releaseWorktree cleans up after a deleted worktree shed. If no other shed
+// uses the repo cache the whole cache volume is removed; otherwise only the
+// shed's worktree metadata is removed from the shared clone, using a
+// short-lived helper container since the shed's own container is gone.
+func (c *Client) releaseWorktree(ctx context.Context, shedName, cacheVolume, image string) error {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", config.LabelRepoCache+"="+cacheVolume)
+
+	users, err := c.docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filterArgs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list repo cache users: %w", err)
+	}
+
+	if len(users) == 0 {
+		if err := c.docker.VolumeRemove(ctx, cacheVolume, true); err != nil {
+			return fmt.Errorf("failed to delete repo cache volume %s: %w", cacheVolume, err)
+		}
+		slog.Info("Removed unused repo cache volume", "volume", cacheVolume)
+		return nil
+	}
+
+	cmd := []string{"rm", "-rf", repoCacheGitDir + "/worktrees/" + shedName}
+	mounts := []mount.Mount{{
+		Type:   mount.TypeVolume,
+		Source: cacheVolume,
+		Target: config.RepoCachePath,
+	}}
+
+	return c.runHelper(ctx, image, cmd, mounts)
+}
+
+// runHelper runs a command to completion in a temporary container and removes it.
+// It runs as root, since the volumes helpers tidy hold files of any owner.
+func (c *Client) runHelper(ctx context.Context, image string, cmd []string, mounts []mount.Mount) error {
+	resp, err := c.docker.ContainerCreate(ctx,
+		&container.Config{Image: image, Cmd: cmd, Entrypoint: []string{}, User: "root"},
+		&container.HostConfig{Mounts: mounts},
+		nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create helper container: %w", err)
+	}
+	defer func() {
+		_ = c.docker.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	if err := c.docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start helper container: %w", err)
+	}
+
+	statusCh, errCh := c.docker.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed waiting for helper container: %w", err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("helper command %v exited with code %d", cmd, status.StatusCode)
+		}
+	}
+
+	return nil
+}
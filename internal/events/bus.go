@@ -0,0 +1,67 @@
+// Package events fans shed lifecycle events out to subscribers such as
+// clients of the event stream API.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// events are dropped for it.
+const subscriberBuffer = 64
+
+// Bus delivers published events to every current subscriber. Publishing
+// never blocks: a subscriber that falls too far behind misses events rather
+// than holding up the server.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[chan config.ShedEvent]struct{}
+}
+
+// NewBus creates a bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan config.ShedEvent]struct{})}
+}
+
+// Publish sends an event to all subscribers, stamping it with the current
+// time if it has none. It is safe to call on a nil Bus.
+func (b *Bus) Publish(ev config.ShedEvent) {
+	if b == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of events published from now on, and a
+// function that ends the subscription and closes the channel.
+func (b *Bus) Subscribe() (<-chan config.ShedEvent, func()) {
+	ch := make(chan config.ShedEvent, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, ch)
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	first, cancelFirst := bus.Subscribe()
+	second, cancelSecond := bus.Subscribe()
+	defer cancelSecond()
+
+	bus.Publish(config.ShedEvent{Type: config.ShedEventStarted, Shed: "dev"})
+
+	for _, ch := range []<-chan config.ShedEvent{first, second} {
+		ev := <-ch
+		if ev.Type != config.ShedEventStarted || ev.Shed != "dev" || ev.Time.IsZero() {
+			t.Errorf("got %+v, want timestamped started event for dev", ev)
+		}
+	}
+
+	cancelFirst()
+	cancelFirst() // Cancelling twice must not panic
+	if _, ok := <-first; ok {
+		t.Error("expected channel to be closed after cancel")
+	}
+
+	bus.Publish(config.ShedEvent{Type: config.ShedEventStopped, Shed: "dev"})
+	if ev := <-second; ev.Type != config.ShedEventStopped {
+		t.Errorf("got %+v, want stopped event", ev)
+	}
+}
+
+func TestBusDropsForSlowSubscribers(t *testing.T) {
+	bus := NewBus()
+	ch, cancel := bus.Subscribe()
+	defer cancel()
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		bus.Publish(config.ShedEvent{Type: config.ShedEventStarted, Shed: "dev"})
+	}
+	if len(ch) != subscriberBuffer {
+		t.Errorf("buffered %d events, want %d", len(ch), subscriberBuffer)
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(config.ShedEvent{}) // Must not panic
+}
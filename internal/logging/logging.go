@@ -0,0 +1,85 @@
+// Package logging sets up shed-server's structured logging and lets the
+// log level be changed while the server runs.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// level is the level of the default logger installed by Setup.
+var level = new(slog.LevelVar)
+
+// Setup installs a default slog logger writing to w in the given format,
+// text or json, at the given level. Output from the standard log package
+// goes through the same logger.
+func Setup(w io.Writer, format, lvl string) error {
+	parsed, err := ParseLevel(lvl)
+	if err != nil {
+		return err
+	}
+	level.Set(parsed)
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q (must be text or json)", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// ParseLevel parses a level name: debug, info, warn, or error.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q (must be debug, info, warn, or error)", name)
+}
+
+// Level returns the current level name.
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// SetLevel changes the level of the logger installed by Setup.
+func SetLevel(name string) error {
+	parsed, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(parsed)
+	return nil
+}
+
+// ToggleDebug switches between debug and base, or info if base is debug,
+// returning the new level name. It lets a signal turn debug logging on and
+// off again.
+func ToggleDebug(base string) string {
+	if level.Level() != slog.LevelDebug {
+		level.Set(slog.LevelDebug)
+		return Level()
+	}
+
+	parsed, err := ParseLevel(base)
+	if err != nil || parsed == slog.LevelDebug {
+		parsed = slog.LevelInfo
+	}
+	level.Set(parsed)
+	return Level()
+}
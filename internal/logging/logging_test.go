@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetupJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, "json", "info"); err != nil {
+		t.Fatal(err)
+	}
+
+	slog.Debug("hidden")
+	slog.Info("shed started", "shed", "dev")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %q", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if entry["msg"] != "shed started" || entry["shed"] != "dev" || entry["level"] != "INFO" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+func TestSetupInvalid(t *testing.T) {
+	if err := Setup(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("expected error for invalid format")
+	}
+	if err := Setup(&bytes.Buffer{}, "text", "loud"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, "text", "warn"); err != nil {
+		t.Fatal(err)
+	}
+
+	slog.Info("before")
+	if err := SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	slog.Debug("after")
+
+	if strings.Contains(buf.String(), "before") || !strings.Contains(buf.String(), "after") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+	if Level() != "debug" {
+		t.Errorf("Level() = %q, want debug", Level())
+	}
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestToggleDebug(t *testing.T) {
+	tests := []struct {
+		base  string
+		first string
+		then  string
+	}{
+		{"warn", "debug", "warn"},
+		{"debug", "info", "debug"},
+	}
+	for _, tt := range tests {
+		if err := Setup(&bytes.Buffer{}, "text", tt.base); err != nil {
+			t.Fatal(err)
+		}
+		if got := ToggleDebug(tt.base); got != tt.first {
+			t.Errorf("base %s: first toggle = %q, want %q", tt.base, got, tt.first)
+		}
+		if got := ToggleDebug(tt.base); got != tt.then {
+			t.Errorf("base %s: second toggle = %q, want %q", tt.base, got, tt.then)
+		}
+	}
+}
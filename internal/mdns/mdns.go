@@ -0,0 +1,281 @@
+// Package mdns advertises shed servers on the local network with multicast
+// DNS (RFC 6762) service discovery and finds the servers others advertise.
+package mdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceType is the DNS-SD service shed servers advertise.
+const ServiceType = "_shed._tcp"
+
+// recordTTL is how long other hosts may cache our records, in seconds.
+const recordTTL = 120
+
+var groupAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// serviceName is the name browsers query to find every shed server.
+const serviceName = ServiceType + ".local."
+
+// Service is a shed server as advertised on the network.
+type Service struct {
+	// Instance is the server's name.
+	Instance string
+	// Host is the server's mDNS host name, e.g. "lab-1.local.".
+	Host string
+	// Port is the server's HTTP API port.
+	Port int
+	// IPs are the addresses the server advertised.
+	IPs []net.IP
+	// From is the address the server's response came from when browsing.
+	From net.IP
+	// Text holds the key=value pairs from the TXT record.
+	Text map[string]string
+}
+
+// Addr returns the address to reach the service at: the one it answered
+// from, its first advertised IP, or its host name.
+func (s Service) Addr() string {
+	if s.From != nil {
+		return s.From.String()
+	}
+	if len(s.IPs) > 0 {
+		return s.IPs[0].String()
+	}
+	return strings.TrimSuffix(s.Host, ".")
+}
+
+// instanceName returns the service's full DNS-SD instance name.
+func (s Service) instanceName() string {
+	return s.Instance + "." + serviceName
+}
+
+// records returns the records describing the service.
+func (s Service) records() []record {
+	keys := make([]string, 0, len(s.Text))
+	for k := range s.Text {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	text := make([]string, 0, len(keys))
+	for _, k := range keys {
+		text = append(text, k+"="+s.Text[k])
+	}
+
+	instance := s.instanceName()
+	records := []record{
+		{name: serviceName, rtype: typePTR, ttl: recordTTL, target: instance},
+		{name: instance, rtype: typeSRV, ttl: recordTTL, target: s.Host, port: uint16(s.Port)},
+		{name: instance, rtype: typeTXT, ttl: recordTTL, text: text},
+	}
+	for _, ip := range s.IPs {
+		if ip.To4() != nil {
+			records = append(records, record{name: s.Host, rtype: typeA, ttl: recordTTL, ip: ip})
+		}
+	}
+	return records
+}
+
+// answers reports whether a query asks about the service.
+func (s Service) answers(q question) bool {
+	if q.qtype != typePTR && q.qtype != typeSRV && q.qtype != typeTXT && q.qtype != typeANY {
+		return false
+	}
+	return strings.EqualFold(q.name, serviceName) || strings.EqualFold(q.name, s.instanceName())
+}
+
+// Advertise answers queries for svc on the local network until ctx is
+// done, announcing it once at the start.
+func Advertise(ctx context.Context, svc Service) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		return fmt.Errorf("failed to join mDNS group: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	announce := &message{response: true, records: svc.records()}
+	if _, err := conn.WriteToUDP(announce.pack(), groupAddr); err != nil {
+		slog.Debug("Failed to announce over mDNS", "err", err)
+	}
+
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read mDNS query: %w", err)
+		}
+
+		query, err := parseMessage(buf[:n])
+		if err != nil || query.response {
+			continue
+		}
+		var asked []question
+		for _, q := range query.questions {
+			if svc.answers(q) {
+				asked = append(asked, q)
+			}
+		}
+		if len(asked) == 0 {
+			continue
+		}
+
+		// Queries from ports other than 5353 come from simple resolvers
+		// that only listen for a direct reply to their query
+		resp := &message{response: true, records: svc.records()}
+		dst := groupAddr
+		if src.Port != groupAddr.Port {
+			resp.id = query.id
+			resp.questions = asked
+			dst = src
+		}
+		if _, err := conn.WriteToUDP(resp.pack(), dst); err != nil {
+			slog.Debug("Failed to answer mDNS query", "err", err)
+		}
+	}
+}
+
+// Browse queries the local network for shed servers and returns those that
+// answer before ctx is done, sorted by instance name.
+func Browse(ctx context.Context) ([]Service, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open socket: %w", err)
+	}
+	defer conn.Close()
+
+	query := &message{questions: []question{{name: serviceName, qtype: typePTR}}}
+	if _, err := conn.WriteToUDP(query.pack(), groupAddr); err != nil {
+		return nil, fmt.Errorf("failed to send mDNS query: %w", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(3 * time.Second)
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	found := make(map[string]*Service)
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return nil, fmt.Errorf("failed to read mDNS response: %w", err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		resp, err := parseMessage(buf[:n])
+		if err != nil || !resp.response {
+			continue
+		}
+		collect(found, resp.records, src.IP)
+	}
+
+	services := make([]Service, 0, len(found))
+	for _, svc := range found {
+		if svc.Port != 0 {
+			services = append(services, *svc)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Instance < services[j].Instance
+	})
+	return services, nil
+}
+
+// collect adds the services described by records to found, keyed by
+// instance name. from is the address the records came from.
+func collect(found map[string]*Service, records []record, from net.IP) {
+	suffix := "." + serviceName
+	get := func(instance string) *Service {
+		key := strings.ToLower(instance)
+		svc := found[key]
+		if svc == nil {
+			name, _ := strings.CutSuffix(instance, suffix)
+			svc = &Service{Instance: name, Text: make(map[string]string)}
+			found[key] = svc
+		}
+		return svc
+	}
+
+	hosts := make(map[string][]net.IP)
+	for _, r := range records {
+		if r.rtype == typeA {
+			key := strings.ToLower(r.name)
+			hosts[key] = append(hosts[key], r.ip)
+		}
+	}
+
+	for _, r := range records {
+		if !strings.HasSuffix(strings.ToLower(r.name), strings.ToLower(suffix)) {
+			continue
+		}
+		svc := get(r.name)
+		switch r.rtype {
+		case typeSRV:
+			svc.Host = r.target
+			svc.Port = int(r.port)
+			svc.IPs = hosts[strings.ToLower(r.target)]
+			svc.From = from
+		case typeTXT:
+			for _, kv := range r.text {
+				k, v, _ := strings.Cut(kv, "=")
+				svc.Text[k] = v
+			}
+		}
+	}
+}
+
+// LocalAddrs returns this host's IPv4 addresses on interfaces that are up
+// and support multicast, for advertising. Container bridges are skipped
+// since other hosts can't reach them.
+func LocalAddrs() []net.IP {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if strings.HasPrefix(ifi.Name, "docker") || strings.HasPrefix(ifi.Name, "br-") ||
+			strings.HasPrefix(ifi.Name, "veth") || strings.HasPrefix(ifi.Name, "podman") {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				ips = append(ips, ipnet.IP.To4())
+			}
+		}
+	}
+	return ips
+}
+
+// TextInt returns an integer from a service's TXT record, or zero.
+func (s Service) TextInt(key string) int {
+	n, _ := strconv.Atoi(s.Text[key])
+	return n
+}
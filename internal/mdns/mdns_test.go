@@ -0,0 +1,92 @@
+package mdns
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func testService() Service {
+	return Service{
+		Instance: "lab-1",
+		Host:     "lab-1.local.",
+		Port:     8080,
+		IPs:      []net.IP{net.IPv4(192, 168, 1, 20).To4()},
+		Text:     map[string]string{"ssh_port": "2222", "version": "1.2.0"},
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	sent := &message{id: 7, response: true, records: testService().records()}
+	got, err := parseMessage(sent.pack())
+	if err != nil {
+		t.Fatalf("parseMessage() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, sent) {
+		t.Errorf("parseMessage(pack()) =\n%+v\nwant\n%+v", got, sent)
+	}
+}
+
+func TestReadNameCompressed(t *testing.T) {
+	// "local." at 0, then "lab-1" pointing back to it
+	b := []byte{5, 'l', 'o', 'c', 'a', 'l', 0, 5, 'l', 'a', 'b', '-', '1', 0xc0, 0x00}
+	name, next, err := readName(b, 7)
+	if err != nil {
+		t.Fatalf("readName() failed: %v", err)
+	}
+	if name != "lab-1.local." || next != len(b) {
+		t.Errorf("readName() = %q, %d, want lab-1.local., %d", name, next, len(b))
+	}
+
+	loop := []byte{0xc0, 0x00}
+	if _, _, err := readName(loop, 0); err == nil {
+		t.Error("readName() should fail on a pointer loop")
+	}
+}
+
+func TestParseMessageTruncated(t *testing.T) {
+	b := (&message{response: true, records: testService().records()}).pack()
+	for _, n := range []int{5, 20, len(b) - 3} {
+		if _, err := parseMessage(b[:n]); err == nil {
+			t.Errorf("parseMessage() of %d of %d bytes should fail", n, len(b))
+		}
+	}
+}
+
+func TestServiceAnswers(t *testing.T) {
+	svc := testService()
+	tests := []struct {
+		q    question
+		want bool
+	}{
+		{question{name: "_shed._tcp.local.", qtype: typePTR}, true},
+		{question{name: "_SHED._tcp.local.", qtype: typeANY}, true},
+		{question{name: "lab-1._shed._tcp.local.", qtype: typeSRV}, true},
+		{question{name: "_shed._tcp.local.", qtype: typeA}, false},
+		{question{name: "_http._tcp.local.", qtype: typePTR}, false},
+	}
+	for _, tt := range tests {
+		if got := svc.answers(tt.q); got != tt.want {
+			t.Errorf("answers(%+v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	found := make(map[string]*Service)
+	from := net.IPv4(192, 168, 1, 20).To4()
+	collect(found, testService().records(), from)
+
+	svc := found["lab-1._shed._tcp.local."]
+	if svc == nil {
+		t.Fatalf("collect() found %v, want lab-1", found)
+	}
+	want := testService()
+	want.From = from
+	if !reflect.DeepEqual(*svc, want) {
+		t.Errorf("collect() = %+v, want %+v", *svc, want)
+	}
+	if svc.Addr() != "192.168.1.20" || svc.TextInt("ssh_port") != 2222 {
+		t.Errorf("Addr() = %s, ssh_port = %d", svc.Addr(), svc.TextInt("ssh_port"))
+	}
+}
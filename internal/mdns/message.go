@@ -0,0 +1,237 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+// DNS record types used for service discovery.
+const (
+	typeA   uint16 = 1
+	typePTR uint16 = 12
+	typeTXT uint16 = 16
+	typeSRV uint16 = 33
+	typeANY uint16 = 255
+)
+
+const (
+	classIN = 1
+	// classMask clears the top bit of a class, which mDNS uses for the
+	// unicast-response bit in questions and cache-flush in records.
+	classMask = 0x7fff
+	// flagResponse marks a message as a response in the header flags.
+	flagResponse = 0x8400
+)
+
+var errMalformed = errors.New("malformed DNS message")
+
+// question asks for records of one type for a name.
+type question struct {
+	name  string
+	qtype uint16
+}
+
+// record is a resource record with its data decoded. Only the fields for
+// its type are set.
+type record struct {
+	name   string
+	rtype  uint16
+	ttl    uint32
+	target string   // PTR and SRV
+	port   uint16   // SRV
+	text   []string // TXT
+	ip     net.IP   // A
+}
+
+// message is a DNS message. Answers and additional records are kept
+// together since discovery treats them the same.
+type message struct {
+	id        uint16
+	response  bool
+	questions []question
+	records   []record
+}
+
+// pack encodes the message without name compression.
+func (m *message) pack() []byte {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.id)
+	if m.response {
+		binary.BigEndian.PutUint16(b[2:], flagResponse)
+	}
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.records)))
+
+	for _, q := range m.questions {
+		b = appendName(b, q.name)
+		b = binary.BigEndian.AppendUint16(b, q.qtype)
+		b = binary.BigEndian.AppendUint16(b, classIN)
+	}
+	for _, r := range m.records {
+		b = appendName(b, r.name)
+		b = binary.BigEndian.AppendUint16(b, r.rtype)
+		b = binary.BigEndian.AppendUint16(b, classIN)
+		b = binary.BigEndian.AppendUint32(b, r.ttl)
+
+		lenAt := len(b)
+		b = append(b, 0, 0)
+		switch r.rtype {
+		case typeA:
+			b = append(b, r.ip.To4()...)
+		case typePTR:
+			b = appendName(b, r.target)
+		case typeSRV:
+			b = append(b, 0, 0, 0, 0) // priority and weight
+			b = binary.BigEndian.AppendUint16(b, r.port)
+			b = appendName(b, r.target)
+		case typeTXT:
+			for _, s := range r.text {
+				b = append(b, byte(len(s)))
+				b = append(b, s...)
+			}
+		}
+		binary.BigEndian.PutUint16(b[lenAt:], uint16(len(b)-lenAt-2))
+	}
+	return b
+}
+
+// appendName appends a dot-separated name as DNS labels.
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// parseMessage decodes a DNS message. Records of types discovery doesn't
+// use are skipped.
+func parseMessage(b []byte) (*message, error) {
+	if len(b) < 12 {
+		return nil, errMalformed
+	}
+	m := &message{
+		id:       binary.BigEndian.Uint16(b[0:]),
+		response: binary.BigEndian.Uint16(b[2:])&0x8000 != 0,
+	}
+	qd := int(binary.BigEndian.Uint16(b[4:]))
+	rr := int(binary.BigEndian.Uint16(b[6:])) + int(binary.BigEndian.Uint16(b[8:])) + int(binary.BigEndian.Uint16(b[10:]))
+
+	off := 12
+	for range qd {
+		name, n, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		off = n
+		if off+4 > len(b) {
+			return nil, errMalformed
+		}
+		m.questions = append(m.questions, question{name: name, qtype: binary.BigEndian.Uint16(b[off:])})
+		off += 4
+	}
+
+	for range rr {
+		name, n, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		off = n
+		if off+10 > len(b) {
+			return nil, errMalformed
+		}
+		r := record{
+			name:  name,
+			rtype: binary.BigEndian.Uint16(b[off:]),
+			ttl:   binary.BigEndian.Uint32(b[off+4:]),
+		}
+		class := binary.BigEndian.Uint16(b[off+2:]) & classMask
+		size := int(binary.BigEndian.Uint16(b[off+8:]))
+		off += 10
+		end := off + size
+		if end > len(b) {
+			return nil, errMalformed
+		}
+
+		if class == classIN {
+			if err := r.parseData(b, off, end); err != nil {
+				return nil, err
+			}
+			if r.rtype == typeA || r.rtype == typePTR || r.rtype == typeSRV || r.rtype == typeTXT {
+				m.records = append(m.records, r)
+			}
+		}
+		off = end
+	}
+	return m, nil
+}
+
+// parseData decodes a record's data from b[off:end].
+func (r *record) parseData(b []byte, off, end int) error {
+	var err error
+	switch r.rtype {
+	case typeA:
+		if end-off != 4 {
+			return errMalformed
+		}
+		r.ip = net.IP(append([]byte(nil), b[off:end]...))
+	case typePTR:
+		r.target, _, err = readName(b, off)
+	case typeSRV:
+		if end-off < 7 {
+			return errMalformed
+		}
+		r.port = binary.BigEndian.Uint16(b[off+4:])
+		r.target, _, err = readName(b, off+6)
+	case typeTXT:
+		for off < end {
+			n := int(b[off])
+			if off+1+n > end {
+				return errMalformed
+			}
+			r.text = append(r.text, string(b[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+	return err
+}
+
+// readName decodes the name at off, following compression pointers, and
+// returns it with a trailing dot and the offset just past it.
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, errMalformed
+		}
+		n := int(b[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(b) || jumps > 16 {
+				return "", 0, errMalformed
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+n > len(b) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(b[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
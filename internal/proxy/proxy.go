@@ -0,0 +1,140 @@
+// Package proxy provides an HTTP reverse proxy that serves sheds' HTTP
+// ports at preview URLs.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// Errors a Resolver returns for sheds the proxy can't serve.
+var (
+	// ErrNoRoute means the shed doesn't exist or has no HTTP port.
+	ErrNoRoute = errors.New("no preview for this shed")
+	// ErrNotRunning means the shed exists but isn't running.
+	ErrNotRunning = errors.New("shed is not running")
+)
+
+// Resolver finds where to send a shed's preview traffic.
+// This allows the proxy package to compile independently of the docker package.
+type Resolver interface {
+	// Target returns the host:port of a shed's HTTP port.
+	Target(ctx context.Context, name string) (string, error)
+}
+
+// Server routes preview URLs to sheds. It is an http.Handler.
+type Server struct {
+	resolver   Resolver
+	subdomains bool
+	// host is the proxy URL's host without a port, for subdomain routing
+	host string
+	// prefix is the proxy URL's path without a trailing slash, for path
+	// routing
+	prefix string
+}
+
+// NewServer creates a proxy for cfg, which must be enabled and valid.
+func NewServer(resolver Resolver, cfg config.ProxyConfig) (*Server, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	return &Server{
+		resolver:   resolver,
+		subdomains: cfg.Subdomains,
+		host:       strings.ToLower(u.Hostname()),
+		prefix:     strings.TrimSuffix(u.Path, "/"),
+	}, nil
+}
+
+// ServeHTTP proxies a request to the shed its host or path names.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, path, ok := s.route(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Relative links resolve against the shed's prefix only with a trailing
+	// slash
+	if path == "" {
+		u := *r.URL
+		u.Path += "/"
+		u.RawPath = ""
+		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+		return
+	}
+
+	target, err := s.resolver.Target(r.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNoRoute):
+			http.Error(w, fmt.Sprintf("No preview for shed %q", name), http.StatusNotFound)
+		case errors.Is(err, ErrNotRunning):
+			http.Error(w, fmt.Sprintf("Shed %q is not running", name), http.StatusServiceUnavailable)
+		default:
+			slog.Warn("Failed to resolve preview", "shed", name, "err", err)
+			http.Error(w, "Failed to reach shed", http.StatusBadGateway)
+		}
+		return
+	}
+
+	prefix := ""
+	if !s.subdomains {
+		prefix = s.prefix + "/" + name
+	}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: "http", Host: target})
+			pr.Out.URL.Path = path
+			pr.Out.URL.RawPath = ""
+			pr.SetXForwarded()
+			if prefix != "" {
+				pr.Out.Header.Set("X-Forwarded-Prefix", prefix)
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Debug("Preview request failed", "shed", name, "target", target, "err", err)
+			http.Error(w, fmt.Sprintf("Shed %q is not answering on its HTTP port", name), http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// route returns the shed a request is for and the path to send it. The
+// path is empty when a path-routed request needs a trailing slash.
+func (s *Server) route(r *http.Request) (name, path string, ok bool) {
+	if s.subdomains {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		name, ok = strings.CutSuffix(strings.ToLower(host), "."+s.host)
+		if !ok || config.ValidateShedName(name) != nil {
+			return "", "", false
+		}
+		return name, r.URL.Path, true
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, s.prefix+"/")
+	if !ok {
+		return "", "", false
+	}
+	name, path, _ = strings.Cut(rest, "/")
+	if config.ValidateShedName(name) != nil {
+		return "", "", false
+	}
+	if path == "" && !strings.HasSuffix(rest, "/") {
+		return name, "", true
+	}
+	return name, "/" + path, true
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// fakeResolver sends every running shed to one backend.
+type fakeResolver struct {
+	target  string
+	stopped map[string]bool
+}
+
+func (f *fakeResolver) Target(ctx context.Context, name string) (string, error) {
+	if f.stopped[name] {
+		return "", ErrNotRunning
+	}
+	if name != "api" && name != "web" {
+		return "", ErrNoRoute
+	}
+	return f.target, nil
+}
+
+// newTestProxy starts a backend echoing the request path and forwarded
+// prefix, and a proxy in front of it.
+func newTestProxy(t *testing.T, cfg config.ProxyConfig) *Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.RequestURI()+" "+r.Header.Get("X-Forwarded-Prefix"))
+	}))
+	t.Cleanup(backend.Close)
+
+	u, _ := url.Parse(backend.URL)
+	s, err := NewServer(&fakeResolver{target: u.Host, stopped: map[string]bool{"web": true}}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestProxyPathRouting(t *testing.T) {
+	s := newTestProxy(t, config.ProxyConfig{Listen: ":8000", URL: "https://example.com/preview"})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/preview/api/users?page=2", http.StatusOK, "/users?page=2 /preview/api"},
+		{"/preview/api/", http.StatusOK, "/ /preview/api"},
+		{"/preview/api", http.StatusPermanentRedirect, ""},
+		{"/preview/web/", http.StatusServiceUnavailable, ""},
+		{"/preview/other/", http.StatusNotFound, ""},
+		{"/elsewhere/api/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+			t.Errorf("GET %s body = %q, want %q", tt.path, rec.Body.String(), tt.wantBody)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/preview/api?x=1", nil))
+	if loc := rec.Header().Get("Location"); loc != "/preview/api/?x=1" {
+		t.Errorf("redirect Location = %q, want /preview/api/?x=1", loc)
+	}
+}
+
+func TestProxySubdomainRouting(t *testing.T) {
+	s := newTestProxy(t, config.ProxyConfig{Listen: ":8000", URL: "https://preview.example.com", Subdomains: true})
+
+	tests := []struct {
+		host       string
+		wantStatus int
+	}{
+		{"api.preview.example.com", http.StatusOK},
+		{"API.preview.example.com:8443", http.StatusOK},
+		{"preview.example.com", http.StatusNotFound},
+		{"a.b.preview.example.com", http.StatusNotFound},
+		{"api.example.org", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s status = %d, want %d", tt.host, rec.Code, tt.wantStatus)
+		}
+		if tt.wantStatus == http.StatusOK && rec.Body.String() != "/docs " {
+			t.Errorf("GET %s body = %q, want %q", tt.host, rec.Body.String(), "/docs ")
+		}
+	}
+}
@@ -0,0 +1,718 @@
+// Package s3 is a small client for S3-compatible object storage, covering
+// what workspace backups need: uploading, downloading, listing, and
+// deleting objects.
+// Requests are signed with AWS Signature Version 4, and retried when the
+// service fails or is unreachable. Large objects are uploaded in parts.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRegion is used when the config doesn't name one.
+const DefaultRegion = "us-east-1"
+
+// emptyPayloadHash is the SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+const (
+	// defaultPartSize is the size of each part of a multipart upload, and
+	// the size above which objects are uploaded in parts. A single PUT is
+	// limited to 5 GiB.
+	defaultPartSize = 64 << 20
+
+	// maxParts is the most parts an upload can have; parts grow past
+	// defaultPartSize to fit larger objects.
+	maxParts = 10000
+
+	// maxAttempts is how many times a request is tried when the service
+	// fails or is unreachable.
+	maxAttempts = 4
+
+	// requestTimeout bounds each attempt at a request other than a
+	// download, whose body is read by the caller.
+	requestTimeout = 15 * time.Minute
+
+	// credentialsExpiryWindow is how long before instance role credentials
+	// expire that they are refreshed.
+	credentialsExpiryWindow = 5 * time.Minute
+)
+
+// imdsEndpoint is the EC2 instance metadata service.
+const imdsEndpoint = "http://169.254.169.254"
+
+// ErrNotFound is returned, wrapped, when a key doesn't exist.
+var ErrNotFound = errors.New("no such key")
+
+// Config locates a bucket and the credentials used to reach it.
+type Config struct {
+	// Endpoint is the storage service's URL, e.g. https://minio.lan:9000.
+	// Empty uses AWS S3 in Region.
+	Endpoint string `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	Bucket   string `yaml:"bucket"`
+
+	// Prefix is prepended to every key, e.g. "shed/".
+	Prefix string `yaml:"prefix"`
+
+	// AccessKeyID, SecretAccessKey, and SessionToken default to the
+	// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN
+	// environment variables. Without a key, the credentials of the EC2
+	// instance's role are used.
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+
+	// PathStyle addresses the bucket as <endpoint>/<bucket> rather than
+	// <bucket>.<endpoint>, which most self-hosted services need.
+	PathStyle bool `yaml:"path_style"`
+}
+
+// Validate checks the bucket and endpoint.
+func (c Config) Validate() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("endpoint must be an http:// or https:// URL, got %q", c.Endpoint)
+		}
+	}
+	return nil
+}
+
+// Object is a stored object.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Client talks to one bucket.
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	static   credentials
+	http     *http.Client
+
+	// role caches the instance role's credentials when none are configured.
+	mu   sync.Mutex
+	role credentials
+
+	// partSize, retryDelay, imds, and now are replaced in tests.
+	partSize   int64
+	retryDelay time.Duration
+	imds       string
+	now        func() time.Time
+}
+
+// credentials sign requests.
+type credentials struct {
+	accessKey string
+	secretKey string
+	token     string
+	expires   time.Time
+}
+
+// New creates a client for the bucket in cfg, which must be valid.
+func New(cfg Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Region == "" {
+		cfg.Region = DefaultRegion
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+
+	c := &Client{
+		cfg:      cfg,
+		endpoint: u,
+		static: credentials{
+			accessKey: cfg.AccessKeyID,
+			secretKey: cfg.SecretAccessKey,
+			token:     cfg.SessionToken,
+		},
+		http:       newHTTPClient(),
+		partSize:   defaultPartSize,
+		retryDelay: time.Second,
+		imds:       imdsEndpoint,
+		now:        time.Now,
+	}
+	if c.static.accessKey == "" {
+		// A session token only goes with the key it was issued for
+		c.static.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		if c.static.token == "" {
+			c.static.token = os.Getenv("AWS_SESSION_TOKEN")
+		}
+	}
+	if c.static.secretKey == "" {
+		c.static.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	return c, nil
+}
+
+// newHTTPClient returns a client whose connections give up on an
+// unresponsive service. Requests have no overall timeout, so downloads
+// of any size can finish.
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 2 * time.Minute,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConnsPerHost:   4,
+		},
+	}
+}
+
+// Put uploads size bytes from body as key, in parts if it is large.
+func (c *Client) Put(ctx context.Context, key string, body io.ReaderAt, size int64) error {
+	if size > c.partSize {
+		return c.putMultipart(ctx, key, body, size)
+	}
+	hash, err := hashSection(body, 0, size)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(ctx, http.MethodPut, key, nil, sectionBody(body, 0, size), size, hash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// putMultipart uploads an object in parts, aborting the upload if a part
+// can't be uploaded so the service doesn't keep them.
+func (c *Client) putMultipart(ctx context.Context, key string, body io.ReaderAt, size int64) error {
+	partSize := max(c.partSize, (size+maxParts-1)/maxParts)
+
+	resp, err := c.send(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return fmt.Errorf("failed to start upload: %w", err)
+	}
+	var started initiateResult
+	err = xml.NewDecoder(resp.Body).Decode(&started)
+	resp.Body.Close()
+	if err != nil || started.UploadID == "" {
+		return fmt.Errorf("failed to start upload: invalid response: %v", err)
+	}
+	uploadID := started.UploadID
+
+	if err := c.uploadParts(ctx, key, uploadID, body, size, partSize); err != nil {
+		c.abortMultipart(key, uploadID)
+		return err
+	}
+	return nil
+}
+
+// uploadParts uploads each part of an object and completes the upload.
+func (c *Client) uploadParts(ctx context.Context, key, uploadID string, body io.ReaderAt, size, partSize int64) error {
+	var complete completeUpload
+	for off, n := int64(0), 1; off < size; off, n = off+partSize, n+1 {
+		length := min(partSize, size-off)
+		hash, err := hashSection(body, off, length)
+		if err != nil {
+			return err
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+		resp, err := c.send(ctx, http.MethodPut, key, query, sectionBody(body, off, length), length, hash)
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", n, err)
+		}
+		resp.Body.Close()
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: n, ETag: resp.Header.Get("ETag")})
+	}
+
+	data, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, sectionBody(bytes.NewReader(data), 0, int64(len(data))), int64(len(data)), hashHex(data))
+	if err != nil {
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Completing can fail after the 200 status is sent
+	var e errorResponse
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e); err == nil && e.Code != "" {
+		return fmt.Errorf("failed to complete upload: object storage: %s: %s", e.Code, e.Message)
+	}
+	return nil
+}
+
+// abortMultipart discards the parts of an upload that failed. It runs
+// even if the upload's context has ended.
+func (c *Client) abortMultipart(key, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	resp, err := c.send(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// sectionBody returns a function giving a fresh reader over part of r for
+// each attempt at a request.
+func sectionBody(r io.ReaderAt, off, n int64) func() io.Reader {
+	return func() io.Reader { return io.NewSectionReader(r, off, n) }
+}
+
+// hashSection returns the hex SHA-256 of part of r, which the signature
+// covers.
+func hashSection(r io.ReaderAt, off, n int64) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(r, off, n)); err != nil {
+		return "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get downloads key. The caller must close the body.
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, key, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// List returns the objects whose keys start with prefix, sorted by key.
+// Keys are returned without the configured prefix.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {c.cfg.Prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.send(ctx, http.MethodGet, "", query, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object list: %w", err)
+		}
+		for _, o := range result.Contents {
+			objects = append(objects, Object{
+				Key:          strings.TrimPrefix(o.Key, c.cfg.Prefix),
+				Size:         o.Size,
+				LastModified: o.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	resp, err := c.send(ctx, http.MethodDelete, key, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is the body of a ListObjectsV2 response.
+type listResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// initiateResult is the body of a CreateMultipartUpload response.
+type initiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// completeUpload is the body of a CompleteMultipartUpload request.
+type completeUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// instanceCredentials is an instance role's credentials from the instance
+// metadata service.
+type instanceCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// errorResponse is the body of a failed request.
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// send makes a request for key, or for the bucket itself if key is empty,
+// retrying it when the service fails or can't be reached. body, if not
+// nil, gives the request body afresh for each attempt.
+func (c *Client) send(ctx context.Context, method, key string, query url.Values, body func() io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(ctx, method, key, query, body, size, payloadHash)
+		if err == nil || attempt == maxAttempts || !retryable(ctx, err) {
+			return resp, err
+		}
+
+		delay := c.retryDelay << (attempt - 1)
+		slog.Debug("Retrying object storage request", "method", method, "key", key, "attempt", attempt, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt makes one try at a request. Requests other than downloads are
+// bounded by requestTimeout.
+func (c *Client) attempt(ctx context.Context, method, key string, query url.Values, body func() io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	creds, err := c.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if method != http.MethodGet || key == "" {
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	}
+	var r io.Reader
+	if body != nil {
+		r = body()
+	}
+	req, err := c.newRequest(ctx, method, key, query, r, payloadHash, creds)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.ContentLength = size
+
+	resp, err := c.do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody ends a request's timeout once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryable reports whether a failed request may succeed if sent again:
+// the service failed or throttled it, or couldn't be reached.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var re *requestError
+	if errors.As(err, &re) {
+		return re.status >= 500 || re.status == http.StatusTooManyRequests
+	}
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
+
+// credentials returns the configured credentials, or the instance role's
+// if none are configured.
+func (c *Client) credentials(ctx context.Context) (credentials, error) {
+	if c.static.accessKey != "" {
+		return c.static, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.role.accessKey != "" && c.now().Before(c.role.expires.Add(-credentialsExpiryWindow)) {
+		return c.role, nil
+	}
+	role, err := c.instanceCredentials(ctx)
+	if err != nil {
+		return credentials{}, fmt.Errorf("no object storage credentials: set access_key_id and secret_access_key or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or run on an instance with a role: %w", err)
+	}
+	c.role = role
+	return role, nil
+}
+
+// instanceCredentials fetches the instance role's credentials from the
+// instance metadata service, using IMDSv2 session tokens.
+func (c *Client) instanceCredentials(ctx context.Context) (credentials, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	get := func(method, path string, header http.Header) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, method, c.imds+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header = header
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("instance metadata %s: %s", path, resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	}
+
+	token, err := get(http.MethodPut, "/latest/api/token", http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"21600"}})
+	if err != nil {
+		return credentials{}, err
+	}
+	header := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+	const rolePath = "/latest/meta-data/iam/security-credentials/"
+	roles, err := get(http.MethodGet, rolePath, header)
+	if err != nil {
+		return credentials{}, err
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(string(roles)), "\n")
+	if role == "" {
+		return credentials{}, fmt.Errorf("the instance has no role")
+	}
+	data, err := get(http.MethodGet, rolePath+role, header)
+	if err != nil {
+		return credentials{}, err
+	}
+	var ic instanceCredentials
+	if err := json.Unmarshal(data, &ic); err != nil {
+		return credentials{}, fmt.Errorf("invalid instance credentials: %w", err)
+	}
+	return credentials{accessKey: ic.AccessKeyID, secretKey: ic.SecretAccessKey, token: ic.Token, expires: ic.Expiration}, nil
+}
+
+// newRequest builds a request for key, or for the bucket itself if key is
+// empty, signed with creds.
+func (c *Client) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader, payloadHash string, creds credentials) (*http.Request, error) {
+	u := *c.endpoint
+	objectPath := ""
+	if key != "" {
+		objectPath = "/" + c.cfg.Prefix + key
+	}
+	if c.cfg.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.cfg.Bucket + objectPath
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawPath = encodePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, payloadHash, creds)
+	return req, nil
+}
+
+// requestError is a request the service refused or failed.
+type requestError struct {
+	status  int
+	text    string
+	code    string
+	message string
+}
+
+func (e *requestError) Error() string {
+	if e.code != "" {
+		return fmt.Sprintf("object storage: %s: %s", e.code, e.message)
+	}
+	return "object storage: " + e.text
+}
+
+func (e *requestError) Unwrap() error {
+	if e.code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// do sends req and turns error responses into errors.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("object storage request failed: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	re := &requestError{status: resp.StatusCode, text: resp.Status}
+	var e errorResponse
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e); err == nil && e.Code != "" {
+		re.code, re.message = e.Code, e.Message
+	}
+	return nil, re
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (c *Client) sign(req *http.Request, payloadHash string, creds credentials) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	headers := []string{
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+	}
+	if creds.token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.token)
+		signedHeaders += ";x-amz-security-token"
+		headers = append(headers, "x-amz-security-token:"+creds.token)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		strings.Join(headers, "\n"),
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey(creds.secretKey, date, c.cfg.Region, "s3"), stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+// signingKey derives the key requests made on date are signed with.
+func signingKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalQuery encodes query sorted by key, as signing requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// encodePath escapes each segment of an object path.
+func encodePath(p string) string {
+	return uriEncode(p, false)
+}
+
+// uriEncode escapes everything but unreserved characters, and slashes
+// unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,333 @@
+package s3
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSigningKey(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("signingKey() = %s, want %s", got, want)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	if got := uriEncode("/shed/dev/2026 01.tar.gz", false); got != "/shed/dev/2026%2001.tar.gz" {
+		t.Errorf("uriEncode(path) = %q", got)
+	}
+	if got := uriEncode("dev/", true); got != "dev%2F" {
+		t.Errorf("uriEncode(query) = %q", got)
+	}
+}
+
+// fakeBucket is an in-memory bucket served over HTTP with path-style
+// addressing.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string]string
+
+	// uploads holds the parts of multipart uploads in progress.
+	uploads map[string]map[int]string
+
+	// token is the session token requests must carry, if any.
+	token string
+
+	// refuseParts makes uploading parts fail.
+	refuseParts bool
+
+	// failures is how many requests fail with a 503 before the bucket
+	// serves them.
+	failures int
+	requests []string
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	query := r.URL.Query()
+	b.requests = append(b.requests, r.Method+" "+r.URL.RequestURI())
+	if b.failures > 0 {
+		b.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, `<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`)
+		return
+	}
+
+	auth := r.Header.Get("Authorization")
+	tokenSigned := strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") ||
+		r.Header.Get("X-Amz-Security-Token") != b.token || tokenSigned != (b.token != "") {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/backups/")
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		if b.uploads == nil {
+			b.uploads = map[string]map[int]string{}
+		}
+		id := "upload" + strconv.Itoa(len(b.uploads)+1)
+		b.uploads[id] = map[int]string{}
+		io.WriteString(w, "<InitiateMultipartUploadResult><UploadId>"+id+"</UploadId></InitiateMultipartUploadResult>")
+	case r.Method == http.MethodPut && query.Has("uploadId") && b.refuseParts:
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `<Error><Code>InvalidRequest</Code><Message>refused</Message></Error>`)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		b.uploads[query.Get("uploadId")][n] = string(data)
+		w.Header().Set("ETag", `"etag`+strconv.Itoa(n)+`"`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		var complete completeUpload
+		_ = xml.NewDecoder(r.Body).Decode(&complete)
+		parts := b.uploads[query.Get("uploadId")]
+		var data strings.Builder
+		for i, p := range complete.Parts {
+			if p.PartNumber != i+1 || p.ETag != `"etag`+strconv.Itoa(i+1)+`"` {
+				io.WriteString(w, `<Error><Code>InvalidPart</Code><Message>bad part</Message></Error>`)
+				return
+			}
+			data.WriteString(parts[p.PartNumber])
+		}
+		b.objects[key] = data.String()
+		delete(b.uploads, query.Get("uploadId"))
+		io.WriteString(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(b.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if hashHex(data) != r.Header.Get("X-Amz-Content-Sha256") {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `<Error><Code>XAmzContentSHA256Mismatch</Code><Message>hash mismatch</Message></Error>`)
+			return
+		}
+		b.objects[key] = string(data)
+	case r.Method == http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "":
+		data, ok := b.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		io.WriteString(w, data)
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		prefix := r.URL.Query().Get("prefix")
+		io.WriteString(w, `<ListBucketResult><IsTruncated>false</IsTruncated>`)
+		for k, v := range b.objects {
+			if strings.HasPrefix(k, prefix) {
+				io.WriteString(w, "<Contents><Key>"+k+"</Key><Size>"+strconv.Itoa(len(v))+"</Size><LastModified>2026-01-02T03:04:05.000Z</LastModified></Contents>")
+			}
+		}
+		io.WriteString(w, `</ListBucketResult>`)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestClient(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string]string{}}
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	c, err := New(Config{Endpoint: srv.URL, Bucket: "backups", Prefix: "shed/", AccessKeyID: "key", SecretAccessKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+
+	for _, key := range []string{"dev/1.tar.gz", "dev/2.tar.gz", "other/1.tar.gz"} {
+		if err := c.Put(ctx, key, strings.NewReader("data"), 4); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	if _, ok := bucket.objects["shed/dev/1.tar.gz"]; !ok {
+		t.Fatalf("objects = %v, want keys under the prefix", bucket.objects)
+	}
+
+	objects, err := c.List(ctx, "dev/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 2 || objects[0].Key != "dev/1.tar.gz" || objects[1].Key != "dev/2.tar.gz" || objects[0].Size != 4 {
+		t.Errorf("List() = %+v, want dev/1.tar.gz and dev/2.tar.gz", objects)
+	}
+
+	body, err := c.Get(ctx, "dev/2.tar.gz")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "data" {
+		t.Errorf("Get() = %q, want data", data)
+	}
+	if _, err := c.Get(ctx, "dev/missing.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := c.Delete(ctx, "dev/1.tar.gz"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok := bucket.objects["shed/dev/1.tar.gz"]; ok {
+		t.Error("Delete() left the object")
+	}
+
+	c.static.accessKey = "wrong"
+	err = c.Delete(ctx, "dev/2.tar.gz")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Delete() with bad credentials error = %v, want AccessDenied", err)
+	}
+}
+
+// newTestClient returns a client for bucket with credentials key and
+// secret, and no delay between retries.
+func newTestClient(t *testing.T, bucket *fakeBucket, token string) *Client {
+	t.Helper()
+	srv := httptest.NewServer(bucket)
+	t.Cleanup(srv.Close)
+	c, err := New(Config{Endpoint: srv.URL, Bucket: "backups", AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: token, PathStyle: true})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	c.retryDelay = time.Millisecond
+	return c
+}
+
+func TestClientMultipart(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string]string{}}
+	c := newTestClient(t, bucket, "")
+	c.partSize = 4
+
+	if err := c.Put(context.Background(), "big", strings.NewReader("0123456789"), 10); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if got := bucket.objects["big"]; got != "0123456789" {
+		t.Errorf("object = %q, want 0123456789", got)
+	}
+	var parts int
+	for _, r := range bucket.requests {
+		if strings.Contains(r, "partNumber=") {
+			parts++
+		}
+	}
+	if parts != 3 || len(bucket.uploads) != 0 {
+		t.Errorf("uploaded %d parts, %d uploads left; want 3 and 0", parts, len(bucket.uploads))
+	}
+}
+
+func TestClientMultipartAborts(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string]string{}, refuseParts: true}
+	c := newTestClient(t, bucket, "")
+	c.partSize = 4
+
+	if err := c.Put(context.Background(), "big", strings.NewReader("0123456789"), 10); err == nil {
+		t.Fatal("Put() succeeded, want the part refused")
+	}
+	if len(bucket.uploads) != 0 || len(bucket.objects) != 0 {
+		t.Errorf("uploads = %v, objects = %v; want the upload aborted", bucket.uploads, bucket.objects)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string]string{}, failures: 2}
+	c := newTestClient(t, bucket, "")
+
+	if err := c.Put(context.Background(), "dev/1.tar.gz", strings.NewReader("data"), 4); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if bucket.objects["dev/1.tar.gz"] != "data" || len(bucket.requests) != 3 {
+		t.Errorf("objects = %v after %d requests, want data after 3", bucket.objects, len(bucket.requests))
+	}
+
+	// Errors the service won't recover from aren't retried
+	bucket.requests = nil
+	if _, err := c.Get(context.Background(), "missing"); !errors.Is(err, ErrNotFound) || len(bucket.requests) != 1 {
+		t.Errorf("Get(missing) error = %v after %d requests, want ErrNotFound after 1", err, len(bucket.requests))
+	}
+
+	// The service failing every attempt is reported
+	bucket.requests = nil
+	bucket.failures = maxAttempts
+	if err := c.Delete(context.Background(), "dev/1.tar.gz"); err == nil || !strings.Contains(err.Error(), "SlowDown") || len(bucket.requests) != maxAttempts {
+		t.Errorf("Delete() error = %v after %d requests, want SlowDown after %d", err, len(bucket.requests), maxAttempts)
+	}
+}
+
+func TestClientSessionToken(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string]string{}, token: "session"}
+	c := newTestClient(t, bucket, "session")
+	if err := c.Put(context.Background(), "dev/1.tar.gz", strings.NewReader("data"), 4); err != nil {
+		t.Fatalf("Put() with session token error = %v", err)
+	}
+
+	c.static.token = ""
+	if err := c.Put(context.Background(), "dev/1.tar.gz", strings.NewReader("data"), 4); err == nil {
+		t.Error("Put() without session token succeeded, want AccessDenied")
+	}
+}
+
+func TestClientInstanceCredentials(t *testing.T) {
+	var fetches int
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			io.WriteString(w, "imds-token")
+		case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			io.WriteString(w, "shed-role\n")
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/shed-role":
+			fetches++
+			io.WriteString(w, `{"AccessKeyId":"key","SecretAccessKey":"secret","Token":"session","Expiration":"2026-01-02T04:00:00Z"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer imds.Close()
+
+	bucket := &fakeBucket{objects: map[string]string{}, token: "session"}
+	c := newTestClient(t, bucket, "")
+	c.static = credentials{}
+	c.imds = imds.URL
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for range 2 {
+		if err := c.Put(ctx, "dev/1.tar.gz", strings.NewReader("data"), 4); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("fetched credentials %d times, want them cached", fetches)
+	}
+
+	// Credentials about to expire are refreshed
+	now = now.Add(58 * time.Minute)
+	if err := c.Put(ctx, "dev/1.tar.gz", strings.NewReader("data"), 4); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if fetches != 2 {
+		t.Errorf("fetched credentials %d times, want them refreshed", fetches)
+	}
+}
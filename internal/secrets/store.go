@@ -0,0 +1,268 @@
+// Package secrets stores secrets on the server, encrypted at rest, for
+// injection into sheds.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// secretSuffix is appended to a secret's name for its file.
+const secretSuffix = ".secret"
+
+// keySize is the size of the master key, for AES-256.
+const keySize = 32
+
+// formatVersion is the first byte of each secret file.
+const formatVersion = 1
+
+// ErrNotFound is returned for a secret that doesn't exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Store manages secrets on disk. Each secret is a file named after it,
+// holding its value encrypted with AES-GCM under a master key kept in a
+// separate file. The secret's name is authenticated along with its value,
+// so files can't be swapped between names.
+type Store struct {
+	dir     string
+	keyFile string
+
+	mu  sync.Mutex
+	key []byte
+}
+
+// NewStore creates a store rooted at dir, encrypted with the master key in
+// keyFile. The directory and key are created on first write.
+func NewStore(dir, keyFile string) *Store {
+	return &Store{dir: dir, keyFile: keyFile}
+}
+
+// path returns the path of a secret's file.
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+secretSuffix)
+}
+
+// masterKey returns the master key, generating it first if create is set
+// and there is none.
+func (s *Store) masterKey(create bool) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key != nil {
+		return s.key, nil
+	}
+
+	data, err := os.ReadFile(s.keyFile)
+	if os.IsNotExist(err) && create {
+		data, err = s.generateKey()
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("secrets master key %s is missing", s.keyFile)
+		}
+		return nil, fmt.Errorf("failed to read secrets master key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("invalid secrets master key in %s: must be %d base64-encoded bytes", s.keyFile, keySize)
+	}
+	s.key = key
+	return key, nil
+}
+
+// generateKey writes a new random master key, or returns the existing one
+// if another process wrote it first.
+func (s *Store) generateKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+	data := []byte(base64.StdEncoding.EncodeToString(key) + "\n")
+
+	if err := os.MkdirAll(filepath.Dir(s.keyFile), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create master key directory: %w", err)
+	}
+	f, err := os.OpenFile(s.keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return os.ReadFile(s.keyFile)
+		}
+		return nil, fmt.Errorf("failed to write master key: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(s.keyFile)
+		return nil, fmt.Errorf("failed to write master key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(s.keyFile)
+		return nil, fmt.Errorf("failed to write master key: %w", err)
+	}
+	return data, nil
+}
+
+// aead returns the cipher secrets are sealed with.
+func (s *Store) aead(create bool) (cipher.AEAD, error) {
+	key, err := s.masterKey(create)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Set stores a secret, replacing any with the same name.
+func (s *Store) Set(name, value string) (*config.Secret, error) {
+	if err := config.ValidateSecretName(name); err != nil {
+		return nil, err
+	}
+	if err := config.ValidateSecretValue(value); err != nil {
+		return nil, err
+	}
+
+	aead, err := s.aead(true)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	data := append([]byte{formatVersion}, nonce...)
+	data = aead.Seal(data, nonce, []byte(value), []byte(name))
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	// Write then rename so a failed write can't leave a corrupt secret
+	tmp, err := os.CreateTemp(s.dir, "."+name+"-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write secret: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write secret: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write secret: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(name)); err != nil {
+		return nil, fmt.Errorf("failed to write secret: %w", err)
+	}
+
+	return s.stat(name)
+}
+
+// Get returns a secret with its value.
+func (s *Store) Get(name string) (*config.Secret, error) {
+	if err := config.ValidateSecretName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to read secret: %w", err)
+	}
+
+	aead, err := s.aead(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1+aead.NonceSize() || data[0] != formatVersion {
+		return nil, fmt.Errorf("secret %q is corrupt", name)
+	}
+	nonce, sealed := data[1:1+aead.NonceSize()], data[1+aead.NonceSize():]
+	value, err := aead.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret %q: wrong master key or corrupt file", name)
+	}
+
+	secret, err := s.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	secret.Value = string(value)
+	return secret, nil
+}
+
+// stat returns a secret's details without its value.
+func (s *Store) stat(name string) (*config.Secret, error) {
+	info, err := os.Stat(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to read secret: %w", err)
+	}
+	return &config.Secret{Name: name, UpdatedAt: info.ModTime().UTC()}, nil
+}
+
+// Exists reports whether a secret with the name exists.
+func (s *Store) Exists(name string) bool {
+	if config.ValidateSecretName(name) != nil {
+		return false
+	}
+	_, err := os.Stat(s.path(name))
+	return err == nil
+}
+
+// List returns all secrets, without their values, sorted by name.
+func (s *Store) List() ([]config.Secret, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []config.Secret{}, nil
+		}
+		return nil, fmt.Errorf("failed to read secrets directory: %w", err)
+	}
+
+	secrets := []config.Secret{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), secretSuffix)
+		if entry.IsDir() || !ok || config.ValidateSecretName(name) != nil {
+			continue
+		}
+		secret, err := s.stat(name)
+		if err != nil {
+			continue
+		}
+		secrets = append(secrets, *secret)
+	}
+
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+	return secrets, nil
+}
+
+// Delete removes a secret.
+func (s *Store) Delete(name string) error {
+	if err := config.ValidateSecretName(name); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return fmt.Errorf("failed to remove secret: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "secrets.key")
+	store := NewStore(filepath.Join(dir, "secrets"), keyFile)
+
+	if _, err := store.Set("github-token", "ghp_example"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatalf("master key not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("master key mode = %o, want 600", perm)
+	}
+
+	data, err := os.ReadFile(store.path("github-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("ghp_example")) {
+		t.Error("secret is stored in plain text")
+	}
+
+	// A fresh store reads the same key
+	secret, err := NewStore(filepath.Join(dir, "secrets"), keyFile).Get("github-token")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if secret.Value != "ghp_example" {
+		t.Errorf("Value = %q, want ghp_example", secret.Value)
+	}
+
+	if _, err := store.Set("npmrc", "//registry/:_authToken=x\n"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	list, err := store.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "github-token" || list[1].Name != "npmrc" || list[0].Value != "" {
+		t.Errorf("List() = %+v, want both secrets in order without values", list)
+	}
+
+	if err := store.Delete("github-token"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := store.Get("github-token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete("github-token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreRejectsSwappedFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir, filepath.Join(dir, "secrets.key"))
+
+	if _, err := store.Set("a", "one"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Set("b", "two"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(store.path("a"), store.path("b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("b"); err == nil {
+		t.Error("Get() should fail for a secret encrypted under another name")
+	}
+}
+
+func TestStoreWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewStore(dir, filepath.Join(dir, "one.key")).Set("token", "x"); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewStore(dir, filepath.Join(dir, "two.key"))
+	if _, err := other.Get("token"); err == nil {
+		t.Error("Get() should fail without the master key")
+	}
+	if _, err := other.Set("other", "y"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Get("token"); err == nil {
+		t.Error("Get() should fail with a different master key")
+	}
+}
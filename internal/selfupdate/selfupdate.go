@@ -0,0 +1,372 @@
+// Package selfupdate replaces the running shed or shed-server binary with
+// the newest GitHub release for its platform.
+//
+// Release archives are named <binary>-<tag>-<os>-<arch>.tar.gz, hold
+// <binary>.exe on Windows, and are checked against the release's
+// checksums.txt before anything is replaced.
+// Release builds embed a PublicKey and also require checksums.txt to carry
+// a valid ed25519 signature in checksums.txt.sig.
+package selfupdate
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charliek/shed/internal/version"
+)
+
+// DefaultRepo is the GitHub repository releases are published to.
+const DefaultRepo = "charliek/shed"
+
+// Release channels.
+const (
+	ChannelStable     = "stable"
+	ChannelPrerelease = "prerelease"
+)
+
+// Names of the release's checksum file and its signature.
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+)
+
+// goos is the platform updates are fetched and installed for, replaced in
+// tests.
+var goos = runtime.GOOS
+
+// maxBinarySize bounds downloads so a bad release can't fill the disk.
+const maxBinarySize = 512 << 20
+
+// PublicKey is the base64 ed25519 key release checksums are signed with,
+// set via ldflags by make release. Development builds have none and verify
+// releases by checksum only.
+var PublicKey = ""
+
+// Release is a GitHub release.
+type Release struct {
+	Tag        string  `json:"tag_name"`
+	Prerelease bool    `json:"prerelease"`
+	Draft      bool    `json:"draft"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// asset returns the release's asset with the given name.
+func (r *Release) asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Updater finds and installs releases of one binary.
+type Updater struct {
+	// Binary is the name of the binary, shed or shed-server.
+	Binary string
+	// Repo is the GitHub repository, defaulting to DefaultRepo.
+	Repo string
+	// APIURL is the GitHub API's base URL, defaulting to
+	// https://api.github.com.
+	APIURL string
+	// Client makes the requests, defaulting to one with a timeout.
+	Client *http.Client
+}
+
+// ValidateChannel checks that channel names a release channel.
+func ValidateChannel(channel string) error {
+	if channel != ChannelStable && channel != ChannelPrerelease {
+		return fmt.Errorf("invalid channel %q (must be %s or %s)", channel, ChannelStable, ChannelPrerelease)
+	}
+	return nil
+}
+
+// Latest returns the newest release on channel. The prerelease channel
+// includes stable releases too.
+func (u *Updater) Latest(ctx context.Context, channel string) (*Release, error) {
+	if err := ValidateChannel(channel); err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=30", u.apiURL(), u.repo())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for releases: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for releases: GitHub returned %s", resp.Status)
+	}
+
+	var releases []Release
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to parse releases: %w", err)
+	}
+
+	// Releases are listed newest first
+	for i := range releases {
+		r := &releases[i]
+		if r.Draft || (r.Prerelease && channel == ChannelStable) {
+			continue
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("no %s releases found for %s", channel, u.repo())
+}
+
+// Newer reports whether release is newer than the current version. A dev
+// build, or any version that can't be compared, is never considered older.
+// A prerelease is older than the release of the same version.
+func Newer(release *Release, current string) bool {
+	cmp, ok := version.Compare(release.Tag, current)
+	if !ok {
+		return false
+	}
+	if cmp != 0 {
+		return cmp > 0
+	}
+	return strings.Contains(current, "-") && !strings.Contains(release.Tag, "-")
+}
+
+// AssetName returns the name of the release archive for this platform.
+func (u *Updater) AssetName(tag string) string {
+	return fmt.Sprintf("%s-%s-%s-%s.tar.gz", u.Binary, tag, goos, runtime.GOARCH)
+}
+
+// Download fetches and verifies the release's binary for this platform.
+func (u *Updater) Download(ctx context.Context, release *Release) ([]byte, error) {
+	name := u.AssetName(release.Tag)
+	archive, ok := release.asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s (%s)", release.Tag, goos, runtime.GOARCH, name)
+	}
+	sums, ok := release.asset(checksumsAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s; refusing to install an unverified binary", release.Tag, checksumsAsset)
+	}
+
+	checksums, err := u.fetch(ctx, sums.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.verifySignature(ctx, release, checksums); err != nil {
+		return nil, err
+	}
+	want, err := findChecksum(checksums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := u.fetch(ctx, archive.URL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", name, got, want)
+	}
+
+	return extractBinary(data, executableName(u.Binary))
+}
+
+// executableName returns the file name binary has on this platform.
+func executableName(binary string) string {
+	if goos == "windows" {
+		return binary + ".exe"
+	}
+	return binary
+}
+
+// verifySignature checks the checksums file's signature when the build has
+// a public key.
+func (u *Updater) verifySignature(ctx context.Context, release *Release, checksums []byte) error {
+	if PublicKey == "" {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+
+	asset, ok := release.asset(signatureAsset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s; refusing to install an unsigned binary", release.Tag, signatureAsset)
+	}
+	data, err := u.fetch(ctx, asset.URL)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", signatureAsset, err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), checksums, sig) {
+		return fmt.Errorf("signature check failed for release %s", release.Tag)
+	}
+	return nil
+}
+
+// fetch downloads url.
+func (u *Updater) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", path.Base(url), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", path.Base(url), resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBinarySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", path.Base(url), err)
+	}
+	if len(data) > maxBinarySize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path.Base(url), maxBinarySize)
+	}
+	return data, nil
+}
+
+// findChecksum returns the SHA-256 for name from a sha256sum-style file.
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+}
+
+// extractBinary returns the file named binary from a gzipped tarball.
+func extractBinary(archive []byte, binary string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("release archive has no %s binary", binary)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read release archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == binary {
+			return io.ReadAll(io.LimitReader(tr, maxBinarySize))
+		}
+	}
+}
+
+// Replace atomically replaces the executable at exe with data, keeping its
+// permissions. The new file is written next to exe and renamed over it, so
+// a failed update leaves the old binary in place. On Windows the old binary
+// is kept as exe.old until the next update.
+func Replace(exe string, data []byte) error {
+	exe, err := filepath.EvalSymlinks(exe)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", exe, err)
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".new-*")
+	if err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+
+	// Windows won't replace a running executable, but will rename it, so
+	// it is moved aside first. It can't be deleted while running, so the
+	// copy left by the previous update is removed now.
+	if goos == "windows" {
+		old := exe + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", exe, err)
+		}
+		if err := os.Rename(tmp.Name(), exe); err != nil {
+			_ = os.Rename(old, exe)
+			return fmt.Errorf("failed to replace %s: %w", exe, err)
+		}
+		return nil
+	}
+
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	return nil
+}
+
+func (u *Updater) repo() string {
+	if u.Repo == "" {
+		return DefaultRepo
+	}
+	return u.Repo
+}
+
+func (u *Updater) apiURL() string {
+	if u.APIURL == "" {
+		return "https://api.github.com"
+	}
+	return strings.TrimSuffix(u.APIURL, "/")
+}
+
+func (u *Updater) client() *http.Client {
+	if u.Client == nil {
+		return &http.Client{Timeout: 5 * time.Minute}
+	}
+	return u.Client
+}
@@ -0,0 +1,271 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRelease serves a fake GitHub API with one stable release and one
+// newer prerelease of a shed binary.
+type testRelease struct {
+	server    *httptest.Server
+	updater   *Updater
+	archive   []byte
+	checksums string
+	signature string
+}
+
+func newTestRelease(t *testing.T) *testRelease {
+	t.Helper()
+	tr := &testRelease{}
+	u := &Updater{Binary: "shed"}
+	tr.archive = tarball(t, "shed", "new binary")
+	sum := sha256.Sum256(tr.archive)
+	tr.checksums = fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), u.AssetName("v1.2.0"))
+
+	mux := http.NewServeMux()
+	tr.server = httptest.NewServer(mux)
+	t.Cleanup(tr.server.Close)
+
+	asset := func(name string) Asset {
+		return Asset{Name: name, URL: tr.server.URL + "/download/" + name}
+	}
+	mux.HandleFunc("/repos/charliek/shed/releases", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]Release{
+			{Tag: "v1.3.0", Draft: true},
+			{Tag: "v1.3.0-rc.1", Prerelease: true},
+			{Tag: "v1.2.0", Assets: []Asset{
+				asset(u.AssetName("v1.2.0")), asset(checksumsAsset), asset(signatureAsset),
+			}},
+		})
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		switch filepath.Base(r.URL.Path) {
+		case checksumsAsset:
+			fmt.Fprint(w, tr.checksums)
+		case signatureAsset:
+			fmt.Fprint(w, tr.signature)
+		default:
+			_, _ = w.Write(tr.archive)
+		}
+	})
+
+	u.APIURL = tr.server.URL
+	tr.updater = u
+	return tr
+}
+
+func tarball(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct{ name, content string }{{"README.md", "readme"}, {name, content}} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0755, Size: int64(len(f.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLatest(t *testing.T) {
+	tr := newTestRelease(t)
+
+	tests := []struct {
+		channel string
+		want    string
+	}{
+		{ChannelStable, "v1.2.0"},
+		{ChannelPrerelease, "v1.3.0-rc.1"},
+	}
+	for _, tt := range tests {
+		rel, err := tr.updater.Latest(context.Background(), tt.channel)
+		if err != nil {
+			t.Fatalf("Latest(%s) error: %v", tt.channel, err)
+		}
+		if rel.Tag != tt.want {
+			t.Errorf("Latest(%s) = %s, want %s", tt.channel, rel.Tag, tt.want)
+		}
+	}
+
+	if _, err := tr.updater.Latest(context.Background(), "nightly"); err == nil {
+		t.Error("Latest(nightly) should fail")
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		tag, current string
+		want         bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.3.0", false},
+		{"v1.2.0", "v1.2.0-rc.1", true},
+		{"v1.2.0", "dev", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(&Release{Tag: tt.tag}, tt.current); got != tt.want {
+			t.Errorf("Newer(%s, %s) = %v, want %v", tt.tag, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestDownload(t *testing.T) {
+	tr := newTestRelease(t)
+	rel, err := tr.updater.Latest(context.Background(), ChannelStable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := tr.updater.Download(context.Background(), rel)
+	if err != nil {
+		t.Fatalf("Download() error: %v", err)
+	}
+	if string(data) != "new binary" {
+		t.Errorf("Download() = %q, want the binary from the archive", data)
+	}
+
+	tr.checksums = strings.Repeat("0", 64) + "  " + tr.updater.AssetName("v1.2.0") + "\n"
+	if _, err := tr.updater.Download(context.Background(), rel); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Download() with a bad checksum error = %v, want checksum mismatch", err)
+	}
+}
+
+func TestDownloadWindows(t *testing.T) {
+	old := goos
+	goos = "windows"
+	t.Cleanup(func() { goos = old })
+
+	tr := newTestRelease(t)
+	if name := tr.updater.AssetName("v1.2.0"); !strings.Contains(name, "-windows-") {
+		t.Fatalf("AssetName() = %s, want a windows archive", name)
+	}
+	rel, err := tr.updater.Latest(context.Background(), ChannelStable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.updater.Download(context.Background(), rel); err == nil {
+		t.Error("Download() found shed in a windows archive, want shed.exe")
+	}
+
+	tr.archive = tarball(t, "shed.exe", "new binary")
+	sum := sha256.Sum256(tr.archive)
+	tr.checksums = fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), tr.updater.AssetName("v1.2.0"))
+	data, err := tr.updater.Download(context.Background(), rel)
+	if err != nil {
+		t.Fatalf("Download() error: %v", err)
+	}
+	if string(data) != "new binary" {
+		t.Errorf("Download() = %q, want shed.exe from the archive", data)
+	}
+}
+
+func TestDownloadSignature(t *testing.T) {
+	tr := newTestRelease(t)
+	rel, err := tr.updater.Latest(context.Background(), ChannelStable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := PublicKey
+	PublicKey = base64.StdEncoding.EncodeToString(pub)
+	t.Cleanup(func() { PublicKey = old })
+
+	tr.signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(tr.checksums)))
+	if _, err := tr.updater.Download(context.Background(), rel); err != nil {
+		t.Fatalf("Download() with a valid signature error: %v", err)
+	}
+
+	tr.signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("something else")))
+	if _, err := tr.updater.Download(context.Background(), rel); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("Download() with a bad signature error = %v, want signature failure", err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "shed")
+	if err := os.WriteFile(exe, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "shed-link")
+	if err := os.Symlink(exe, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(link, []byte("new binary")); err != nil {
+		t.Fatalf("Replace() error: %v", err)
+	}
+
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new binary" {
+		t.Errorf("binary = %q, want new binary", data)
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("dir has %d entries, want the binary and link only", len(entries))
+	}
+}
+
+func TestReplaceWindows(t *testing.T) {
+	old := goos
+	goos = "windows"
+	t.Cleanup(func() { goos = old })
+
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "shed.exe")
+	for _, f := range []struct{ path, data string }{{exe, "v2"}, {exe + ".old", "v1"}} {
+		if err := os.WriteFile(f.path, []byte(f.data), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Replace(exe, []byte("v3")); err != nil {
+		t.Fatalf("Replace() error: %v", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "v3" {
+		t.Errorf("binary = %q, want v3", data)
+	}
+	if data, _ := os.ReadFile(exe + ".old"); string(data) != "v2" {
+		t.Errorf("old binary = %q, want the running v2 moved aside", data)
+	}
+}
@@ -0,0 +1,229 @@
+// Package sshclient is a minimal SSH client for connecting to sheds
+// without an OpenSSH binary.
+package sshclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ExitUnknown is the exit code reported when the remote command's status
+// is unknown, because the connection dropped or a signal killed it. It
+// matches OpenSSH.
+const ExitUnknown = 255
+
+// dialTimeout bounds connecting and the SSH handshake.
+const dialTimeout = 15 * time.Second
+
+// DefaultKeyFiles are the private keys in ~/.ssh tried after the agent's.
+var DefaultKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// WindowSize is a terminal's size in characters.
+type WindowSize struct {
+	Rows int
+	Cols int
+}
+
+// TTY describes the local terminal a session's pseudo-terminal mirrors.
+type TTY struct {
+	// Term is the terminal type, such as xterm-256color.
+	Term string
+	// Size is the terminal's initial size.
+	Size WindowSize
+	// Resize delivers the terminal's new size whenever it changes.
+	Resize <-chan WindowSize
+}
+
+// Options describes a session to run.
+type Options struct {
+	// Host and Port are the address of the SSH server.
+	Host string
+	Port int
+	// User is the user to log in as, which for shed is the shed's name.
+	User string
+	// HostKeys are the keys the server may present. A server rotating its
+	// host key may present either of two.
+	HostKeys []gossh.PublicKey
+	// Signers are the keys to authenticate with.
+	Signers []gossh.Signer
+	// Command is run instead of a login shell when set.
+	Command string
+	// Env lists NAME=value environment variables to send. The server
+	// may ignore any of them.
+	Env []string
+	// TTY requests a pseudo-terminal when set.
+	TTY *TTY
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Run connects, runs the session, and returns the remote command's exit
+// code. An error means the session couldn't be started.
+func Run(opts Options) (int, error) {
+	if len(opts.HostKeys) == 0 {
+		return 0, errors.New("no host key to verify the server against")
+	}
+	if len(opts.Signers) == 0 {
+		return 0, errors.New("no SSH keys found; start an SSH agent or create a key in ~/.ssh")
+	}
+
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:              opts.User,
+		Auth:              []gossh.AuthMethod{gossh.PublicKeys(opts.Signers...)},
+		HostKeyCallback:   knownHostKeys(opts.HostKeys),
+		HostKeyAlgorithms: hostKeyAlgorithms(opts.HostKeys),
+		Timeout:           dialTimeout,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = opts.Stdin
+	session.Stdout = opts.Stdout
+	session.Stderr = opts.Stderr
+
+	for _, kv := range opts.Env {
+		name, value, _ := strings.Cut(kv, "=")
+		// Like OpenSSH, carry on without variables the server refuses
+		_ = session.Setenv(name, value)
+	}
+
+	if opts.TTY != nil {
+		modes := gossh.TerminalModes{
+			gossh.ECHO:          1,
+			gossh.TTY_OP_ISPEED: 14400,
+			gossh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(opts.TTY.Term, opts.TTY.Size.Rows, opts.TTY.Size.Cols, modes); err != nil {
+			return 0, fmt.Errorf("failed to allocate a terminal: %w", err)
+		}
+	}
+
+	if opts.Command == "" {
+		err = session.Shell()
+	} else {
+		err = session.Start(opts.Command)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to start session: %w", err)
+	}
+
+	if opts.TTY != nil && opts.TTY.Resize != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for {
+				select {
+				case size := <-opts.TTY.Resize:
+					_ = session.WindowChange(size.Rows, size.Cols)
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	return exitCode(session.Wait())
+}
+
+// exitCode turns the result of waiting on a session into an exit code.
+func exitCode(err error) (int, error) {
+	var exitErr *gossh.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.As(err, &exitErr):
+		if exitErr.Signal() != "" {
+			return ExitUnknown, nil
+		}
+		return exitErr.ExitStatus(), nil
+	default:
+		var missing *gossh.ExitMissingError
+		if errors.As(err, &missing) {
+			return ExitUnknown, errors.New("connection closed before the command exited")
+		}
+		return ExitUnknown, err
+	}
+}
+
+// knownHostKeys returns a callback accepting a server presenting any of keys.
+func knownHostKeys(keys []gossh.PublicKey) gossh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		for _, known := range keys {
+			if bytes.Equal(known.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("host key %s for %s is not trusted", gossh.FingerprintSHA256(key), hostname)
+	}
+}
+
+// hostKeyAlgorithms returns the algorithms to ask the server for, so it
+// presents a key type that was recorded rather than another.
+func hostKeyAlgorithms(keys []gossh.PublicKey) []string {
+	var algos []string
+	for _, key := range keys {
+		switch {
+		case slices.Contains(algos, key.Type()):
+		case key.Type() == gossh.KeyAlgoRSA:
+			algos = append(algos, gossh.KeyAlgoRSASHA512, gossh.KeyAlgoRSASHA256, gossh.KeyAlgoRSA)
+		default:
+			algos = append(algos, key.Type())
+		}
+	}
+	return algos
+}
+
+// LoadSigners returns the keys offered by the SSH agent, if one is running,
+// followed by the unencrypted default keys in sshDir. The returned function
+// closes the agent connection.
+func LoadSigners(sshDir string) ([]gossh.Signer, func()) {
+	var signers []gossh.Signer
+	closeAgent := func() {}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+			closeAgent = func() { conn.Close() }
+		}
+	}
+
+	for _, name := range DefaultKeyFiles {
+		data, err := os.ReadFile(filepath.Join(sshDir, name))
+		if err != nil {
+			continue
+		}
+		// Keys with a passphrase are only usable through the agent
+		signer, err := gossh.ParsePrivateKey(data)
+		if err != nil {
+			continue
+		}
+		signers = append(signers, signer)
+	}
+
+	return signers, closeAgent
+}
@@ -0,0 +1,166 @@
+package sshclient
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) gossh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// startServer runs an SSH server that accepts clientKey and reports what
+// each session asked for, exiting with status 3.
+func startServer(t *testing.T, hostKey gossh.Signer, clientKey gossh.PublicKey) (string, int) {
+	t.Helper()
+	srv := &ssh.Server{
+		Handler: func(s ssh.Session) {
+			pty, winCh, isPty := s.Pty()
+			fmt.Fprintf(s, "user=%s command=%q pty=%v", s.User(), s.RawCommand(), isPty)
+			if env := s.Environ(); len(env) > 0 {
+				fmt.Fprintf(s, " env=%s", strings.Join(env, ","))
+			}
+			if isPty {
+				fmt.Fprintf(s, " term=%s size=%dx%d", pty.Term, pty.Window.Height, pty.Window.Width)
+				<-winCh // initial size
+				select {
+				case win := <-winCh:
+					fmt.Fprintf(s, " resized=%dx%d", win.Height, win.Width)
+				case <-time.After(5 * time.Second):
+				}
+			}
+			_ = s.Exit(3)
+		},
+		PublicKeyHandler: func(ctx ssh.Context, key ssh.PublicKey) bool {
+			return ssh.KeysEqual(key, clientKey)
+		},
+	}
+	srv.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { srv.Close() })
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestRun(t *testing.T) {
+	hostKey, clientKey := newSigner(t), newSigner(t)
+	host, port := startServer(t, hostKey, clientKey.PublicKey())
+
+	var stdout bytes.Buffer
+	code, err := Run(Options{
+		Host:     host,
+		Port:     port,
+		User:     "myproj",
+		HostKeys: []gossh.PublicKey{hostKey.PublicKey()},
+		Signers:  []gossh.Signer{clientKey},
+		Command:  "echo hello",
+		Env:      []string{"EDITOR=vim", "GIT_AUTHOR_NAME=Ada"},
+		Stdin:    strings.NewReader(""),
+		Stdout:   &stdout,
+		Stderr:   &bytes.Buffer{},
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+	if want := `user=myproj command="echo hello" pty=false env=EDITOR=vim,GIT_AUTHOR_NAME=Ada`; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRunTTY(t *testing.T) {
+	hostKey, clientKey := newSigner(t), newSigner(t)
+	host, port := startServer(t, hostKey, clientKey.PublicKey())
+
+	resize := make(chan WindowSize, 1)
+	resize <- WindowSize{Rows: 50, Cols: 120}
+
+	var stdout bytes.Buffer
+	code, err := Run(Options{
+		Host:     host,
+		Port:     port,
+		User:     "myproj",
+		HostKeys: []gossh.PublicKey{hostKey.PublicKey()},
+		Signers:  []gossh.Signer{clientKey},
+		TTY:      &TTY{Term: "xterm-256color", Size: WindowSize{Rows: 24, Cols: 80}, Resize: resize},
+		Stdin:    strings.NewReader(""),
+		Stdout:   &stdout,
+		Stderr:   &bytes.Buffer{},
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+	want := `user=myproj command="" pty=true term=xterm-256color size=24x80 resized=50x120`
+	if stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRunAcceptsAnyKnownHostKey(t *testing.T) {
+	hostKey, clientKey := newSigner(t), newSigner(t)
+	host, port := startServer(t, hostKey, clientKey.PublicKey())
+
+	// As after a rotation, with the old key still trusted
+	code, err := Run(Options{
+		Host:     host,
+		Port:     port,
+		User:     "myproj",
+		HostKeys: []gossh.PublicKey{newSigner(t).PublicKey(), hostKey.PublicKey()},
+		Signers:  []gossh.Signer{clientKey},
+		Stdout:   &bytes.Buffer{},
+		Stderr:   &bytes.Buffer{},
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if code != 3 {
+		t.Errorf("Run() code = %d, want 3", code)
+	}
+}
+
+func TestRunRejectsUnknownHostKey(t *testing.T) {
+	hostKey, clientKey := newSigner(t), newSigner(t)
+	host, port := startServer(t, hostKey, clientKey.PublicKey())
+
+	_, err := Run(Options{
+		Host:     host,
+		Port:     port,
+		User:     "myproj",
+		HostKeys: []gossh.PublicKey{newSigner(t).PublicKey()},
+		Signers:  []gossh.Signer{clientKey},
+		Stdout:   &bytes.Buffer{},
+		Stderr:   &bytes.Buffer{},
+	})
+	if err == nil {
+		t.Fatal("Run() should fail when the server's host key doesn't match")
+	}
+}
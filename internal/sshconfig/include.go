@@ -0,0 +1,70 @@
+package sshconfig
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// IncludeFileName is the file, beside the SSH config, that holds shed's
+// entries when they are installed with an Include line rather than a
+// managed block in the SSH config itself.
+const IncludeFileName = "shed_config"
+
+// IncludeLine includes shed's entries from the SSH config. OpenSSH resolves
+// the relative path against ~/.ssh.
+const IncludeLine = "Include " + IncludeFileName
+
+// GetIncludePath returns the path of the file holding shed's entries in
+// include mode.
+func GetIncludePath() string {
+	return filepath.Join(filepath.Dir(GetSSHConfigPath()), IncludeFileName)
+}
+
+// HasInclude reports whether SSH config content includes shed's entries.
+func HasInclude(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if isIncludeLine(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddInclude returns SSH config content with IncludeLine added. It goes at
+// the top, since an Include after a Host line only applies to that host.
+func AddInclude(content string) string {
+	if HasInclude(content) {
+		return content
+	}
+	if strings.TrimSpace(content) == "" {
+		return IncludeLine + "\n"
+	}
+	return IncludeLine + "\n\n" + content
+}
+
+// RemoveInclude returns SSH config content without IncludeLine.
+func RemoveInclude(content string) string {
+	var kept []string
+	skipBlank := false
+	for _, line := range strings.Split(content, "\n") {
+		if isIncludeLine(line) {
+			// Drop the blank line AddInclude put after it too
+			skipBlank = len(kept) == 0
+			continue
+		}
+		if skipBlank && strings.TrimSpace(line) == "" {
+			skipBlank = false
+			continue
+		}
+		skipBlank = false
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// isIncludeLine reports whether a line is IncludeLine, ignoring case and
+// surrounding space as OpenSSH does.
+func isIncludeLine(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) == 2 && strings.EqualFold(fields[0], "Include") && fields[1] == IncludeFileName
+}
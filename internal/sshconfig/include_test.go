@@ -0,0 +1,76 @@
+package sshconfig
+
+import "testing"
+
+func TestAddInclude(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", IncludeLine + "\n"},
+		{"blank", "\n\n", IncludeLine + "\n"},
+		{"existing hosts", "Host github.com\n    User git\n", IncludeLine + "\n\nHost github.com\n    User git\n"},
+		{"already included", "include  shed_config\nHost github.com\n", "include  shed_config\nHost github.com\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AddInclude(tt.content)
+			if got != tt.want {
+				t.Errorf("AddInclude() = %q, want %q", got, tt.want)
+			}
+			if !HasInclude(got) {
+				t.Error("HasInclude() = false after AddInclude()")
+			}
+			// Adding it again changes nothing
+			if again := AddInclude(got); again != got {
+				t.Errorf("second AddInclude() = %q, want %q", again, got)
+			}
+		})
+	}
+}
+
+func TestRemoveInclude(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"existing hosts", "Host github.com\n    User git\n"},
+		{"leading blank line", "\nHost github.com\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added := AddInclude(tt.content)
+			removed := RemoveInclude(added)
+			if HasInclude(removed) {
+				t.Errorf("RemoveInclude() = %q, still includes", removed)
+			}
+			// Only the blank line AddInclude wrote goes with it
+			if tt.content != "" && removed != tt.content {
+				t.Errorf("RemoveInclude(AddInclude()) = %q, want %q", removed, tt.content)
+			}
+			if again := RemoveInclude(removed); again != removed {
+				t.Errorf("second RemoveInclude() = %q, want %q", again, removed)
+			}
+		})
+	}
+}
+
+func TestIncludeLineMatching(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"Include shed_config", true},
+		{"  INCLUDE   shed_config  ", true},
+		{"Include ~/.ssh/shed_config", false},
+		{"Include shed_config other", false},
+		{"# Include shed_config", false},
+	}
+	for _, tt := range tests {
+		if got := isIncludeLine(tt.line); got != tt.want {
+			t.Errorf("isIncludeLine(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package sshconfig
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -60,7 +61,9 @@ func Parse(content string) *ParsedConfig {
 	// Trim trailing newline from BeforeBlock if present (we'll add it back when writing)
 	result.BeforeBlock = strings.TrimSuffix(result.BeforeBlock, "\n")
 
-	// Trim leading newline from AfterBlock if present
+	// Trim leading newline from AfterBlock if present, and the blank line
+	// Render writes after the block, so rewriting doesn't add another
+	result.AfterBlock = strings.TrimPrefix(result.AfterBlock, "\n")
 	result.AfterBlock = strings.TrimPrefix(result.AfterBlock, "\n")
 
 	// Parse individual Host entries from the managed content
@@ -139,3 +142,42 @@ func (p *ParsedConfig) FindEntry(name string) *ManagedEntry {
 	}
 	return nil
 }
+
+// Entry converts a managed entry back into an Entry by reading its directives.
+// Directives without a field of their own are kept in ExtraOptions.
+func (m ManagedEntry) Entry() Entry {
+	entry := Entry{Name: m.Name}
+
+	for _, line := range strings.Split(m.RawContent, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		value := strings.Join(fields[1:], " ")
+		switch strings.ToLower(fields[0]) {
+		case "hostname":
+			entry.Host = strings.ReplaceAll(value, "%%", "%")
+		case "port":
+			entry.Port, _ = strconv.Atoi(value)
+		case "user":
+			entry.User = value
+		case "userknownhostsfile":
+			entry.KnownHostsFile = value
+		case "proxyjump":
+			entry.ProxyJump = value
+		case "identityfile":
+			entry.IdentityFile = value
+		case "forwardagent":
+			entry.ForwardAgent = strings.EqualFold(value, "yes")
+		case "host":
+		default:
+			if entry.ExtraOptions == nil {
+				entry.ExtraOptions = make(map[string]string)
+			}
+			entry.ExtraOptions[fields[0]] = value
+		}
+	}
+
+	return entry
+}
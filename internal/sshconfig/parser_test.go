@@ -0,0 +1,92 @@
+package sshconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	content := "Host github.com\n    User git\n\n" +
+		BeginMarker + "\n# Do not edit manually - managed by shed CLI\n\n" +
+		"Host dev.mini\n    HostName 100.64.0.5\n    Port 2222\n    User dev\n\n" +
+		"Host api.mini\n    HostName 100.64.0.5\n    Port 2222\n    User api\n" +
+		EndMarker + "\nHost *\n    ServerAliveInterval 30\n"
+
+	parsed := Parse(content)
+	if !parsed.HasManagedBlock {
+		t.Fatal("HasManagedBlock = false, want true")
+	}
+	if want := "Host github.com\n    User git\n"; parsed.BeforeBlock != want {
+		t.Errorf("BeforeBlock = %q, want %q", parsed.BeforeBlock, want)
+	}
+	if want := "Host *\n    ServerAliveInterval 30\n"; parsed.AfterBlock != want {
+		t.Errorf("AfterBlock = %q, want %q", parsed.AfterBlock, want)
+	}
+	if got, want := parsed.GetEntryNames(), []string{"dev.mini", "api.mini"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetEntryNames() = %v, want %v", got, want)
+	}
+	if entry := parsed.FindEntry("api.mini"); entry == nil || entry.Entry().User != "api" {
+		t.Errorf("FindEntry(api.mini) = %+v, want user api", entry)
+	}
+	if entry := parsed.FindEntry("missing"); entry != nil {
+		t.Errorf("FindEntry(missing) = %+v, want nil", entry)
+	}
+}
+
+func TestParseWithoutBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"empty", ""},
+		{"no markers", "Host github.com\n    User git\n"},
+		{"end before begin", EndMarker + "\n" + BeginMarker + "\n"},
+		{"unterminated", BeginMarker + "\nHost dev.mini\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := Parse(tt.content)
+			if parsed.HasManagedBlock {
+				t.Error("HasManagedBlock = true, want false")
+			}
+			if parsed.BeforeBlock != tt.content {
+				t.Errorf("BeforeBlock = %q, want the whole content", parsed.BeforeBlock)
+			}
+			if len(parsed.ManagedEntries) != 0 {
+				t.Errorf("ManagedEntries = %v, want none", parsed.ManagedEntries)
+			}
+		})
+	}
+}
+
+func TestManagedEntryRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry Entry
+	}{
+		{"minimal", Entry{Name: "dev.mini", Host: "mini.local", Port: 2222, User: "dev"}},
+		{"every field", Entry{
+			Name:           "dev.mini",
+			Host:           "100.64.0.5",
+			Port:           2222,
+			User:           "dev",
+			KnownHostsFile: "~/.shed/known_hosts",
+			ProxyJump:      "bastion",
+			IdentityFile:   "~/.ssh/id_ed25519",
+			ForwardAgent:   true,
+			ExtraOptions:   map[string]string{"ServerAliveInterval": "30", "SetEnv": "TERM=xterm-256color"},
+		}},
+		{"ipv6 zone", Entry{Name: "dev.mini", Host: "fe80::1%eth0", Port: 2222, User: "dev"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := Parse(GenerateManagedBlock([]Entry{tt.entry}))
+			if len(parsed.ManagedEntries) != 1 {
+				t.Fatalf("ManagedEntries = %v, want one", parsed.ManagedEntries)
+			}
+			if got := parsed.ManagedEntries[0].Entry(); !reflect.DeepEqual(got, tt.entry) {
+				t.Errorf("Entry() = %+v, want %+v", got, tt.entry)
+			}
+		})
+	}
+}
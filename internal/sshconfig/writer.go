@@ -2,8 +2,10 @@ package sshconfig
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -21,6 +23,15 @@ type Entry struct {
 	User string
 	// KnownHostsFile is the path to the known_hosts file to use.
 	KnownHostsFile string
+	// ProxyJump is the jump host to connect through, if any.
+	ProxyJump string
+	// IdentityFile is the private key to authenticate with, if any.
+	IdentityFile string
+	// ForwardAgent forwards the local SSH agent.
+	ForwardAgent bool
+	// ExtraOptions are any other directives, by name. They are written in
+	// name order.
+	ExtraOptions map[string]string
 }
 
 // Diff represents the difference between current and desired SSH config entries.
@@ -29,6 +40,8 @@ type Diff struct {
 	Additions []string
 	// Removals are entries that will be removed.
 	Removals []string
+	// Updates are entries whose directives will change.
+	Updates []string
 	// Unchanged are entries that remain the same.
 	Unchanged []string
 }
@@ -38,16 +51,39 @@ func GenerateEntry(entry Entry) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("Host %s\n", entry.Name))
-	sb.WriteString(fmt.Sprintf("    HostName %s\n", entry.Host))
+	sb.WriteString(fmt.Sprintf("    HostName %s\n", hostName(entry.Host)))
 	sb.WriteString(fmt.Sprintf("    Port %d\n", entry.Port))
 	sb.WriteString(fmt.Sprintf("    User %s\n", entry.User))
 	if entry.KnownHostsFile != "" {
 		sb.WriteString(fmt.Sprintf("    UserKnownHostsFile %s\n", entry.KnownHostsFile))
 	}
+	if entry.ProxyJump != "" {
+		sb.WriteString(fmt.Sprintf("    ProxyJump %s\n", entry.ProxyJump))
+	}
+	if entry.IdentityFile != "" {
+		sb.WriteString(fmt.Sprintf("    IdentityFile %s\n", entry.IdentityFile))
+	}
+	if entry.ForwardAgent {
+		sb.WriteString("    ForwardAgent yes\n")
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(entry.ExtraOptions)) {
+		sb.WriteString(fmt.Sprintf("    %s %s\n", name, entry.ExtraOptions[name]))
+	}
 
 	return sb.String()
 }
 
+// hostName returns a host as a HostName directive takes it: IPv6 addresses
+// without brackets, and with the % before a zone, as in fe80::1%eth0,
+// escaped so ssh doesn't take it for a token.
+func hostName(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return strings.ReplaceAll(host, "%", "%%")
+}
+
 // GenerateManagedBlock generates the complete managed block content.
 func GenerateManagedBlock(entries []Entry) string {
 	if len(entries) == 0 {
@@ -85,13 +121,14 @@ func ComputeDiff(current []ManagedEntry, desired []Entry) Diff {
 	diff := Diff{
 		Additions: []string{},
 		Removals:  []string{},
+		Updates:   []string{},
 		Unchanged: []string{},
 	}
 
 	// Create maps for easier lookup
-	currentNames := make(map[string]bool)
+	currentEntries := make(map[string]Entry)
 	for _, entry := range current {
-		currentNames[entry.Name] = true
+		currentEntries[entry.Name] = entry.Entry()
 	}
 
 	desiredNames := make(map[string]bool)
@@ -101,9 +138,13 @@ func ComputeDiff(current []ManagedEntry, desired []Entry) Diff {
 
 	// Find additions (in desired but not in current)
 	for _, entry := range desired {
-		if !currentNames[entry.Name] {
+		existing, ok := currentEntries[entry.Name]
+		switch {
+		case !ok:
 			diff.Additions = append(diff.Additions, entry.Name)
-		} else {
+		case GenerateEntry(existing) != GenerateEntry(entry):
+			diff.Updates = append(diff.Updates, entry.Name)
+		default:
 			diff.Unchanged = append(diff.Unchanged, entry.Name)
 		}
 	}
@@ -118,26 +159,26 @@ func ComputeDiff(current []ManagedEntry, desired []Entry) Diff {
 	// Sort all slices for consistent output
 	sort.Strings(diff.Additions)
 	sort.Strings(diff.Removals)
+	sort.Strings(diff.Updates)
 	sort.Strings(diff.Unchanged)
 
 	return diff
 }
 
-// HasChanges returns true if the diff contains any additions or removals.
+// HasChanges returns true if the diff contains any additions, removals, or updates.
 func (d Diff) HasChanges() bool {
-	return len(d.Additions) > 0 || len(d.Removals) > 0
+	return len(d.Additions) > 0 || len(d.Removals) > 0 || len(d.Updates) > 0
 }
 
 // Write writes the SSH config file with the managed block.
 // It performs an atomic write by writing to a temp file first.
 func Write(path string, before string, entries []Entry, after string) error {
-	// Ensure the .ssh directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return fmt.Errorf("failed to create .ssh directory: %w", err)
-	}
+	return WriteFile(path, Render(before, entries, after))
+}
 
-	// Build the complete content
+// Render returns SSH config content with the managed block for entries
+// between before and after. The block is left out if there are no entries.
+func Render(before string, entries []Entry, after string) string {
 	var sb strings.Builder
 
 	// Add content before managed block
@@ -162,7 +203,17 @@ func Write(path string, before string, entries []Entry, after string) error {
 		sb.WriteString(after)
 	}
 
-	content := sb.String()
+	return sb.String()
+}
+
+// WriteFile replaces an SSH config file with content, atomically by writing
+// to a temp file first.
+func WriteFile(path, content string) error {
+	// Ensure the .ssh directory exists
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create .ssh directory: %w", err)
+	}
 
 	// Atomic write via temp file
 	tmpPath := path + ".tmp"
@@ -0,0 +1,150 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateEntry(t *testing.T) {
+	entry := Entry{
+		Name:         "dev.mini",
+		Host:         "100.64.0.5",
+		Port:         2222,
+		User:         "dev",
+		ForwardAgent: true,
+		ExtraOptions: map[string]string{"ServerAliveInterval": "30", "Compression": "yes"},
+	}
+	want := "Host dev.mini\n" +
+		"    HostName 100.64.0.5\n" +
+		"    Port 2222\n" +
+		"    User dev\n" +
+		"    ForwardAgent yes\n" +
+		"    Compression yes\n" +
+		"    ServerAliveInterval 30\n"
+	if got := GenerateEntry(entry); got != want {
+		t.Errorf("GenerateEntry() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHostName(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"mini.local", "mini.local"},
+		{"100.64.0.5", "100.64.0.5"},
+		{"fd7a:115c::5", "fd7a:115c::5"},
+		{"[fd7a:115c::5]", "fd7a:115c::5"},
+		{"fe80::1%eth0", "fe80::1%%eth0"},
+		{"[fe80::1%eth0]", "fe80::1%%eth0"},
+	}
+	for _, tt := range tests {
+		if got := hostName(tt.host); got != tt.want {
+			t.Errorf("hostName(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestComputeDiff(t *testing.T) {
+	current := Parse(GenerateManagedBlock([]Entry{
+		{Name: "gone.mini", Host: "mini", Port: 2222, User: "gone"},
+		{Name: "moved.mini", Host: "mini", Port: 2222, User: "moved"},
+		{Name: "same.mini", Host: "mini", Port: 2222, User: "same"},
+	})).ManagedEntries
+	desired := []Entry{
+		{Name: "new.mini", Host: "mini", Port: 2222, User: "new"},
+		{Name: "moved.mini", Host: "mini", Port: 2223, User: "moved"},
+		{Name: "same.mini", Host: "mini", Port: 2222, User: "same"},
+	}
+
+	diff := ComputeDiff(current, desired)
+	want := Diff{
+		Additions: []string{"new.mini"},
+		Removals:  []string{"gone.mini"},
+		Updates:   []string{"moved.mini"},
+		Unchanged: []string{"same.mini"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("ComputeDiff() = %+v, want %+v", diff, want)
+	}
+	if !diff.HasChanges() {
+		t.Error("HasChanges() = false, want true")
+	}
+	if ComputeDiff(current[2:], desired[2:]).HasChanges() {
+		t.Error("HasChanges() = true for identical entries")
+	}
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	before := "Host github.com\n    User git\n"
+	after := "Host *\n    ServerAliveInterval 30\n"
+	entries := []Entry{
+		{Name: "web.mini", Host: "fe80::1%eth0", Port: 2222, User: "web"},
+		{Name: "api.mini", Host: "100.64.0.5", Port: 2222, User: "api"},
+	}
+
+	content := Render(before, entries, after)
+	parsed := Parse(content)
+	if parsed.BeforeBlock != before || parsed.AfterBlock != after {
+		t.Errorf("before = %q, after = %q; want the surrounding content kept", parsed.BeforeBlock, parsed.AfterBlock)
+	}
+	// Entries are written in name order
+	if got, want := parsed.GetEntryNames(), []string{"api.mini", "web.mini"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entry names = %v, want %v", got, want)
+	}
+	if got := parsed.FindEntry("web.mini").Entry().Host; got != "fe80::1%eth0" {
+		t.Errorf("web.mini host = %q, want fe80::1%%eth0", got)
+	}
+
+	// Rendering what was parsed changes nothing but the timestamp
+	again := Render(parsed.BeforeBlock, entries, parsed.AfterBlock)
+	if stripTimestamp(again) != stripTimestamp(content) {
+		t.Errorf("re-rendered content =\n%s\nwant\n%s", again, content)
+	}
+
+	// Without entries the block is dropped and the rest kept
+	if got := Render(before, nil, after); got != before+"\n"+after {
+		t.Errorf("Render() without entries = %q", got)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	before := "Host github.com\n    User git\n"
+	if err := Write(path, before, []Entry{{Name: "dev.mini", Host: "mini", Port: 2222, User: "dev"}}, ""); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if err := Remove(path); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if strings.Contains(string(content), BeginMarker) || !strings.HasPrefix(string(content), before) {
+		t.Errorf("config after Remove() = %q", content)
+	}
+
+	// Removing again, or from a missing file, is a no-op
+	if err := Remove(path); err != nil {
+		t.Errorf("second Remove() error = %v", err)
+	}
+	if err := Remove(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Remove() of a missing file error = %v", err)
+	}
+}
+
+// stripTimestamp drops the managed block's "Last updated" line.
+func stripTimestamp(content string) string {
+	var kept []string
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(line, "# Last updated:") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,278 @@
+package sshd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// forwardsContextKey holds a connection's remote forward listeners.
+const forwardsContextKey = "shed-remote-forwards"
+
+// directTCPIPData is the payload of a direct-tcpip channel (RFC 4254 7.2).
+type directTCPIPData struct {
+	DestAddr   string
+	DestPort   uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// remoteForwardRequest is the payload of tcpip-forward and
+// cancel-tcpip-forward requests (RFC 4254 7.1).
+type remoteForwardRequest struct {
+	BindAddr string
+	BindPort uint32
+}
+
+// remoteForwardSuccess is the reply to a tcpip-forward request.
+type remoteForwardSuccess struct {
+	BindPort uint32
+}
+
+// forwardedTCPIPData is the payload of a forwarded-tcpip channel.
+type forwardedTCPIPData struct {
+	DestAddr   string
+	DestPort   uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// remoteForwards tracks the ssh -R listeners opened by one connection.
+type remoteForwards struct {
+	mu        sync.Mutex
+	listeners map[string]net.Listener
+}
+
+// forwardTarget returns the running shed a connection's forwards are for,
+// or an error explaining why forwarding is refused.
+func (s *Server) forwardTarget(ctx ssh.Context, remote bool) (*ShedInfo, error) {
+	if remote && !s.forwarding.Remote {
+		return nil, fmt.Errorf("remote port forwarding is disabled")
+	}
+	if !remote && !s.forwarding.Local {
+		return nil, fmt.Errorf("local port forwarding is disabled")
+	}
+
+	shed, err := s.docker.GetShed(ctx, ctx.User())
+	if err != nil {
+		return nil, fmt.Errorf("shed %q not found", ctx.User())
+	}
+	if shed.Status != config.StatusRunning {
+		return nil, fmt.Errorf("shed %q is not running", shed.Name)
+	}
+	return shed, nil
+}
+
+// checkForwardPort returns an error unless both the server's and the
+// shed's rules allow forwarding port.
+func (s *Server) checkForwardPort(shed *ShedInfo, port uint32) error {
+	if !s.forwarding.Permits(port) || (shed.Forwarding != nil && !shed.Forwarding.Permits(port)) {
+		return fmt.Errorf("forwarding port %d is not allowed", port)
+	}
+	return nil
+}
+
+// isShedLocal reports whether a forward destination means the shed itself.
+func isShedLocal(host, shedName string) bool {
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1", shedName:
+		return true
+	}
+	return false
+}
+
+// handleDirectTCPIP handles ssh -L by connecting to a port on the shed's
+// container address. Only the shed itself can be reached, so the server
+// can't be used to tunnel into other hosts.
+func (s *Server) handleDirectTCPIP(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
+	var d directTCPIPData
+	if err := gossh.Unmarshal(newChan.ExtraData(), &d); err != nil {
+		_ = newChan.Reject(gossh.ConnectionFailed, "error parsing forward data: "+err.Error())
+		return
+	}
+
+	shed, err := s.forwardTarget(ctx, false)
+	if err == nil {
+		err = s.checkForwardPort(shed, d.DestPort)
+	}
+	if err != nil {
+		slog.Warn("Rejected local forward", "user", ctx.User(), "dest", d.DestAddr, "port", d.DestPort, "err", err)
+		_ = newChan.Reject(gossh.Prohibited, err.Error())
+		return
+	}
+	if !isShedLocal(d.DestAddr, shed.Name) {
+		_ = newChan.Reject(gossh.Prohibited, "only ports on the shed itself can be forwarded")
+		return
+	}
+
+	ip, _, err := s.docker.ShedAddress(ctx, shed.Name)
+	if err != nil {
+		_ = newChan.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+
+	var dialer net.Dialer
+	dconn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(int(d.DestPort))))
+	if err != nil {
+		_ = newChan.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		dconn.Close()
+		return
+	}
+	go gossh.DiscardRequests(reqs)
+
+	slog.Info("Local forward", "shed", shed.Name, "port", d.DestPort)
+	end := s.activity.Begin(shed.Name)
+	go func() {
+		pipe(ch, dconn)
+		end()
+	}()
+}
+
+// handleRemoteForward handles ssh -R by listening on the Docker host's
+// address on the shed's network, reachable from the shed as
+// host.docker.internal, and relaying connections back to the client. Other
+// sheds on a shared network can reach that address too, so only
+// connections from the shed's own address are relayed.
+func (s *Server) handleRemoteForward(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+	var payload remoteForwardRequest
+	if err := gossh.Unmarshal(req.Payload, &payload); err != nil {
+		return false, []byte{}
+	}
+	forwards := connForwards(ctx)
+	key := net.JoinHostPort(payload.BindAddr, strconv.Itoa(int(payload.BindPort)))
+
+	if req.Type == "cancel-tcpip-forward" {
+		forwards.mu.Lock()
+		ln, ok := forwards.listeners[key]
+		delete(forwards.listeners, key)
+		forwards.mu.Unlock()
+		if ok {
+			ln.Close()
+		}
+		return true, nil
+	}
+
+	// Port 0 asks for any free port, which is checked once it is known
+	shed, err := s.forwardTarget(ctx, true)
+	if err == nil && payload.BindPort != 0 {
+		err = s.checkForwardPort(shed, payload.BindPort)
+	}
+	if err != nil {
+		slog.Warn("Rejected remote forward", "user", ctx.User(), "port", payload.BindPort, "err", err)
+		return false, []byte(err.Error())
+	}
+
+	ip, gateway, err := s.docker.ShedAddress(ctx, shed.Name)
+	if err != nil {
+		return false, []byte(err.Error())
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(gateway, strconv.Itoa(int(payload.BindPort))))
+	if err != nil {
+		slog.Warn("Remote forward listen failed", "shed", shed.Name, "port", payload.BindPort, "err", err)
+		return false, []byte(err.Error())
+	}
+	port := uint32(ln.Addr().(*net.TCPAddr).Port)
+	if payload.BindPort == 0 {
+		if err := s.checkForwardPort(shed, port); err != nil {
+			ln.Close()
+			return false, []byte(err.Error())
+		}
+	}
+
+	forwards.mu.Lock()
+	forwards.listeners[key] = ln
+	forwards.mu.Unlock()
+
+	slog.Info("Remote forward", "shed", shed.Name, "listen", ln.Addr().String())
+
+	// The shed counts as in use while the client holds the listener open
+	end := s.activity.Begin(shed.Name)
+	conn := ctx.Value(ssh.ContextKeyConn).(*gossh.ServerConn)
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		end()
+	}()
+	go acceptForwarded(ln, conn, payload.BindAddr, port, net.ParseIP(ip))
+
+	return true, gossh.Marshal(&remoteForwardSuccess{BindPort: port})
+}
+
+// acceptForwarded relays each connection to ln from the shed at shedIP back
+// to the client over a forwarded-tcpip channel until the listener is
+// closed. Connections from anywhere else are closed.
+func acceptForwarded(ln net.Listener, conn *gossh.ServerConn, bindAddr string, port uint32, shedIP net.IP) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		origin := c.RemoteAddr().(*net.TCPAddr)
+		if !origin.IP.Equal(shedIP) {
+			slog.Warn("Refused remote forward connection from another address", "listen", ln.Addr().String(), "origin", origin.IP.String())
+			c.Close()
+			continue
+		}
+		payload := gossh.Marshal(&forwardedTCPIPData{
+			DestAddr:   bindAddr,
+			DestPort:   port,
+			OriginAddr: origin.IP.String(),
+			OriginPort: uint32(origin.Port),
+		})
+
+		go func() {
+			ch, reqs, err := conn.OpenChannel("forwarded-tcpip", payload)
+			if err != nil {
+				c.Close()
+				return
+			}
+			go gossh.DiscardRequests(reqs)
+			pipe(ch, c)
+		}()
+	}
+}
+
+// connForwards returns the remote forwards for a connection, creating them
+// on first use.
+func connForwards(ctx ssh.Context) *remoteForwards {
+	ctx.Lock()
+	defer ctx.Unlock()
+	if f, ok := ctx.Value(forwardsContextKey).(*remoteForwards); ok {
+		return f
+	}
+	f := &remoteForwards{listeners: make(map[string]net.Listener)}
+	ctx.SetValue(forwardsContextKey, f)
+	return f
+}
+
+// pipe copies between an SSH channel and a connection in both directions,
+// closing both once either side is done.
+func pipe(ch gossh.Channel, conn net.Conn) {
+	var once sync.Once
+	closeBoth := func() {
+		ch.Close()
+		conn.Close()
+	}
+
+	go func() {
+		_, _ = io.Copy(ch, conn)
+		once.Do(closeBoth)
+	}()
+	_, _ = io.Copy(conn, ch)
+	once.Do(closeBoth)
+}
@@ -0,0 +1,205 @@
+package sshd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/charliek/shed/internal/activity"
+	"github.com/charliek/shed/internal/audit"
+	"github.com/charliek/shed/internal/config"
+)
+
+// forwardShedIP and forwardGateway stand in for a shed's address and its
+// network's gateway, both on loopback so the test needs no engine.
+const (
+	forwardShedIP  = "127.0.0.2"
+	forwardGateway = "127.0.0.1"
+)
+
+// fakeDocker serves one running shed, "dev", at forwardShedIP.
+type fakeDocker struct {
+	forwarding *config.ForwardingRules
+}
+
+func (f *fakeDocker) GetShed(ctx context.Context, name string) (*ShedInfo, error) {
+	if name != "dev" {
+		return nil, errors.New("not found")
+	}
+	return &ShedInfo{Name: "dev", Status: config.StatusRunning, ContainerID: "c1", Forwarding: f.forwarding}, nil
+}
+
+func (f *fakeDocker) StartShed(ctx context.Context, name string) error  { return nil }
+func (f *fakeDocker) ResumeShed(ctx context.Context, name string) error { return nil }
+func (f *fakeDocker) WaitForStatus(ctx context.Context, name, status string) error {
+	return nil
+}
+
+func (f *fakeDocker) ShedAddress(ctx context.Context, name string) (string, string, error) {
+	return forwardShedIP, forwardGateway, nil
+}
+
+func (f *fakeDocker) ExecInContainer(ctx context.Context, containerID string, opts ExecOptions) error {
+	return errors.New("not supported")
+}
+
+func (f *fakeDocker) StatPath(ctx context.Context, containerID, path string) (fs.FileMode, error) {
+	return 0, fs.ErrNotExist
+}
+
+func (f *fakeDocker) CopyFromContainer(ctx context.Context, containerID, path string) (io.ReadCloser, error) {
+	return nil, fs.ErrNotExist
+}
+
+func (f *fakeDocker) CopyToContainer(ctx context.Context, containerID, dir string, content io.Reader) error {
+	return errors.New("not supported")
+}
+
+// startForwardServer serves SSH on loopback with the given forwarding
+// policy and returns a client connected as the shed dev.
+func startForwardServer(t *testing.T, docker *fakeDocker, forwarding config.ForwardingConfig) *gossh.Client {
+	t.Helper()
+	dir := t.TempDir()
+	srv, err := NewServer(docker, ServerOptions{
+		HostKeyPath: filepath.Join(dir, "host_key"),
+		Forwarding:  forwarding,
+		Activity:    activity.NewTracker(),
+		Audit:       audit.Open(dir),
+	})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go func() { _ = srv.sshServer.Serve(ln) }()
+	t.Cleanup(func() { srv.sshServer.Close() })
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	client, err := gossh.Dial("tcp", ln.Addr().String(), &gossh.ClientConfig{
+		User:            "dev",
+		Auth:            []gossh.AuthMethod{gossh.PublicKeys(signer)},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// dialFrom connects to the gateway's port from a local address.
+func dialFrom(t *testing.T, from string, port int) net.Conn {
+	t.Helper()
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(from)}, Timeout: 5 * time.Second}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(forwardGateway, strconv.Itoa(port)))
+	if err != nil {
+		t.Skipf("can't dial from %s: %v", from, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestRemoteForward(t *testing.T) {
+	client := startForwardServer(t, &fakeDocker{}, config.ForwardingConfig{Remote: true})
+
+	ln, err := client.ListenTCP(&net.TCPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatalf("ListenTCP() error = %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// Echo what the shed sends back to it
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+
+	conn := dialFrom(t, forwardShedIP, port)
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	buf := make([]byte, 4)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("read %q, %v; want ping", buf, err)
+	}
+}
+
+func TestRemoteForwardRefusesOtherSheds(t *testing.T) {
+	client := startForwardServer(t, &fakeDocker{}, config.ForwardingConfig{Remote: true})
+
+	ln, err := client.ListenTCP(&net.TCPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatalf("ListenTCP() error = %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			accepted <- struct{}{}
+			c.Close()
+		}
+	}()
+
+	// Another shed on the same network connects from its own address
+	conn := dialFrom(t, forwardGateway, ln.Addr().(*net.TCPAddr).Port)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Read() succeeded, want the connection closed")
+	}
+	select {
+	case <-accepted:
+		t.Error("connection from another address was relayed to the client")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRemoteForwardRefused(t *testing.T) {
+	tests := []struct {
+		name       string
+		docker     *fakeDocker
+		forwarding config.ForwardingConfig
+	}{
+		{"disabled", &fakeDocker{}, config.ForwardingConfig{}},
+		{"server denies port", &fakeDocker{}, config.ForwardingConfig{Remote: true, ForwardingRules: config.ForwardingRules{Deny: []string{"1-65535"}}}},
+		{"shed denies port", &fakeDocker{forwarding: &config.ForwardingRules{Allow: []string{"1"}}}, config.ForwardingConfig{Remote: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := startForwardServer(t, tt.docker, tt.forwarding)
+			if ln, err := client.ListenTCP(&net.TCPAddr{IP: net.IPv4zero}); err == nil {
+				ln.Close()
+				t.Error("ListenTCP() succeeded, want refused")
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package sshd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// NextHostKeyPath returns where the key replacing the host key at path is
+// kept while a rotation is in progress.
+func NextHostKeyPath(path string) string {
+	return path + ".next"
+}
+
+// OldHostKeyPath returns where the host key at path is kept once a rotation
+// replaces it.
+func OldHostKeyPath(path string) string {
+	return path + ".old"
+}
+
+// GenerateHostKey writes a new ED25519 host key to path and its public key
+// to path.pub, replacing any there.
+func GenerateHostKey(path string) (gossh.Signer, error) {
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ED25519 key: %w", err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(privKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %w", err)
+	}
+
+	pemBlock, err := gossh.MarshalPrivateKey(privKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	// The server may read the key at any time, so it must never be partial
+	if err := writeFileAtomic(path, pem.EncodeToMemory(pemBlock), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write host key: %w", err)
+	}
+
+	// Also save the public key for convenience.
+	if err := os.WriteFile(path+".pub", gossh.MarshalAuthorizedKey(signer.PublicKey()), 0644); err != nil {
+		slog.Warn("Failed to write public key file", "err", err)
+	}
+
+	return signer, nil
+}
+
+// ReadHostKey reads the host key at path, returning nil if there is none.
+func ReadHostKey(path string) (gossh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read host key: %w", err)
+	}
+	signer, err := gossh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse host key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// RotateHostKey starts replacing the host key at path by generating the key
+// that will replace it. The server keeps presenting the current key and
+// publishes both until FinishHostKeyRotation, giving clients time to trust
+// the new one.
+func RotateHostKey(path string) (gossh.Signer, error) {
+	current, err := ReadHostKey(path)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, fmt.Errorf("no host key at %s to rotate", path)
+	}
+
+	next, err := ReadHostKey(NextHostKeyPath(path))
+	if err != nil {
+		return nil, err
+	}
+	if next != nil {
+		return nil, fmt.Errorf("a host key rotation is already in progress")
+	}
+
+	return GenerateHostKey(NextHostKeyPath(path))
+}
+
+// FinishHostKeyRotation makes the key generated by RotateHostKey the host
+// key at path, keeping the replaced key at OldHostKeyPath. It returns the
+// new host key.
+func FinishHostKeyRotation(path string) (gossh.Signer, error) {
+	nextPath := NextHostKeyPath(path)
+	next, err := ReadHostKey(nextPath)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, fmt.Errorf("no host key rotation is in progress")
+	}
+
+	if err := copyFile(path, OldHostKeyPath(path), 0600); err != nil {
+		return nil, fmt.Errorf("failed to keep old host key: %w", err)
+	}
+	if err := copyFile(path+".pub", OldHostKeyPath(path)+".pub", 0644); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to keep old public key file", "err", err)
+	}
+
+	// Renaming swaps the key in one step for a server reading it
+	if err := os.Rename(nextPath, path); err != nil {
+		return nil, fmt.Errorf("failed to replace host key: %w", err)
+	}
+	if err := os.Rename(nextPath+".pub", path+".pub"); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to replace public key file", "err", err)
+	}
+
+	return next, nil
+}
+
+// writeFileAtomic writes data to path through a temporary file, so readers
+// see either the old contents or the new.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// copyFile copies src to dst, replacing dst.
+func copyFile(src, dst string, perm os.FileMode) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data, perm)
+}
+
+// hostKeys holds a server's host key and the key replacing it, rereading
+// them from disk when they change so rotations need no restart.
+type hostKeys struct {
+	path string
+
+	mu          sync.Mutex
+	current     gossh.Signer
+	currentData []byte
+	next        gossh.Signer
+	nextData    []byte
+}
+
+// loadHostKeys loads the host key at path, generating one if there is none.
+func loadHostKeys(path string) (*hostKeys, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		slog.Info("Generating new ED25519 host key")
+		signer, err := GenerateHostKey(path)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Generated new host key", "path", path)
+		slog.Info("Host key fingerprint", "fingerprint", gossh.FingerprintSHA256(signer.PublicKey()))
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read host key file: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read host key file: %w", err)
+	}
+
+	signer, err := gossh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing host key: %w", err)
+	}
+	slog.Info("Loaded existing host key", "path", path)
+
+	k := &hostKeys{path: path, current: signer, currentData: data}
+	k.load()
+	return k, nil
+}
+
+// load rereads the keys from disk and returns them. next is nil unless a
+// rotation is in progress. A key that can't be read leaves the last one
+// loaded in place.
+func (k *hostKeys) load() (current, next gossh.Signer) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if data, err := os.ReadFile(k.path); err == nil && !bytes.Equal(data, k.currentData) {
+		if signer, err := gossh.ParsePrivateKey(data); err == nil {
+			k.current, k.currentData = signer, data
+			slog.Info("Host key changed", "fingerprint", gossh.FingerprintSHA256(signer.PublicKey()))
+		} else {
+			slog.Warn("Failed to parse host key", "path", k.path, "err", err)
+		}
+	}
+
+	data, err := os.ReadFile(NextHostKeyPath(k.path))
+	switch {
+	case os.IsNotExist(err):
+		k.next, k.nextData = nil, nil
+	case err != nil || bytes.Equal(data, k.nextData):
+	default:
+		if signer, err := gossh.ParsePrivateKey(data); err == nil {
+			k.next, k.nextData = signer, data
+		} else {
+			slog.Warn("Failed to parse next host key", "path", NextHostKeyPath(k.path), "err", err)
+		}
+	}
+
+	return k.current, k.next
+}
+
+// signer returns the current host key without rereading it.
+func (k *hostKeys) signer() gossh.Signer {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.current
+}
+
+// rotatingSigner signs with whichever host key is current, so a rotation
+// takes effect for new connections.
+type rotatingSigner struct {
+	keys *hostKeys
+}
+
+func (r *rotatingSigner) PublicKey() gossh.PublicKey {
+	return r.keys.signer().PublicKey()
+}
+
+func (r *rotatingSigner) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	return r.keys.signer().Sign(rand, data)
+}
+
+func (r *rotatingSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*gossh.Signature, error) {
+	signer, ok := r.keys.signer().(gossh.AlgorithmSigner)
+	if !ok {
+		return nil, fmt.Errorf("host key does not support algorithm %s", algorithm)
+	}
+	return signer.SignWithAlgorithm(rand, data, algorithm)
+}
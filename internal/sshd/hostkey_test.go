@@ -0,0 +1,74 @@
+package sshd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestHostKeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "host_key")
+
+	keys, err := loadHostKeys(path)
+	if err != nil {
+		t.Fatalf("loadHostKeys() error = %v", err)
+	}
+	original, next := keys.load()
+	if next != nil {
+		t.Fatal("load() returned a next key before any rotation")
+	}
+
+	if _, err := FinishHostKeyRotation(path); err == nil {
+		t.Error("FinishHostKeyRotation() should fail with no rotation in progress")
+	}
+
+	rotated, err := RotateHostKey(path)
+	if err != nil {
+		t.Fatalf("RotateHostKey() error = %v", err)
+	}
+	if _, err := RotateHostKey(path); err == nil {
+		t.Error("RotateHostKey() should fail while a rotation is in progress")
+	}
+
+	// Both keys are published, but the current one is still presented
+	current, next := keys.load()
+	if !sameKey(current.PublicKey(), original.PublicKey()) {
+		t.Error("load() current key changed before the rotation finished")
+	}
+	if next == nil || !sameKey(next.PublicKey(), rotated.PublicKey()) {
+		t.Error("load() next key is not the rotated key")
+	}
+	if !sameKey((&rotatingSigner{keys: keys}).PublicKey(), original.PublicKey()) {
+		t.Error("rotatingSigner presents the next key before the rotation finished")
+	}
+
+	if _, err := FinishHostKeyRotation(path); err != nil {
+		t.Fatalf("FinishHostKeyRotation() error = %v", err)
+	}
+
+	current, next = keys.load()
+	if !sameKey(current.PublicKey(), rotated.PublicKey()) {
+		t.Error("load() current key is not the rotated key after finishing")
+	}
+	if next != nil {
+		t.Error("load() returned a next key after finishing")
+	}
+	if !sameKey((&rotatingSigner{keys: keys}).PublicKey(), rotated.PublicKey()) {
+		t.Error("rotatingSigner doesn't present the rotated key after finishing")
+	}
+
+	old, err := ReadHostKey(OldHostKeyPath(path))
+	if err != nil || old == nil || !sameKey(old.PublicKey(), original.PublicKey()) {
+		t.Errorf("ReadHostKey(old) = %v, %v, want the original key", old, err)
+	}
+	if _, err := os.Stat(NextHostKeyPath(path)); !os.IsNotExist(err) {
+		t.Errorf("next key file still exists after finishing: %v", err)
+	}
+}
+
+// sameKey reports whether two public keys are the same.
+func sameKey(a, b gossh.PublicKey) bool {
+	return string(a.Marshal()) == string(b.Marshal())
+}
@@ -0,0 +1,150 @@
+package sshd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// keepaliveStartedKey marks a connection whose keepalives have started.
+type keepaliveStartedKey struct{}
+
+// withKeepalive wraps a channel handler to start keepalives on its
+// connection.
+func (s *Server) withKeepalive(h ssh.ChannelHandler) ssh.ChannelHandler {
+	return func(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
+		s.startKeepalive(ctx)
+		h(srv, conn, newChan, ctx)
+	}
+}
+
+// withKeepaliveRequest wraps a global request handler to start keepalives
+// on its connection.
+func (s *Server) withKeepaliveRequest(h ssh.RequestHandler) ssh.RequestHandler {
+	return func(ctx ssh.Context, srv *ssh.Server, req *gossh.Request) (bool, []byte) {
+		s.startKeepalive(ctx)
+		return h(ctx, srv, req)
+	}
+}
+
+// startKeepalive starts probing a connection, once per connection, if
+// keepalives are enabled.
+func (s *Server) startKeepalive(ctx ssh.Context) {
+	conn, ok := ctx.Value(ssh.ContextKeyConn).(gossh.Conn)
+	if s.sshConfig.KeepaliveInterval <= 0 || !ok {
+		return
+	}
+	ctx.Lock()
+	started := ctx.Value(keepaliveStartedKey{}) != nil
+	if !started {
+		ctx.SetValue(keepaliveStartedKey{}, true)
+	}
+	ctx.Unlock()
+	if started {
+		return
+	}
+
+	go keepalive(ctx, conn, s.sshConfig.KeepaliveInterval, s.sshConfig.KeepaliveCount())
+}
+
+// keepalive sends a keepalive request every interval until the connection
+// ends, closing it after countMax requests in a row go unanswered. Clients
+// answer unknown requests with a failure, which still shows they're there.
+func keepalive(ctx context.Context, conn gossh.Conn, interval time.Duration, countMax int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Replies may arrive late, so at most one request is outstanding
+	var pending chan error
+	missed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if pending == nil {
+			pending = make(chan error, 1)
+			go func(reply chan<- error) {
+				_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+				reply <- err
+			}(pending)
+		}
+
+		select {
+		case err := <-pending:
+			pending = nil
+			if err != nil {
+				return
+			}
+			missed = 0
+			continue
+		case <-time.After(interval):
+		}
+
+		missed++
+		if missed >= countMax {
+			slog.Info("Closing unresponsive SSH connection", "remote", conn.RemoteAddr().String(), "missed", missed)
+			conn.Close()
+			return
+		}
+	}
+}
+
+// idleWatch records when a session last had input or output. A nil
+// idleWatch ignores activity.
+type idleWatch struct {
+	last atomic.Int64
+}
+
+func newIdleWatch() *idleWatch {
+	w := &idleWatch{}
+	w.touch()
+	return w
+}
+
+func (w *idleWatch) touch() {
+	if w == nil {
+		return
+	}
+	w.last.Store(time.Now().UnixNano())
+}
+
+// idleFor returns how long the session has had no input or output.
+func (w *idleWatch) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, w.last.Load()))
+}
+
+// closeWhenIdle calls cancel once the session has been idle for timeout,
+// telling the client why, or returns when ctx ends.
+func closeWhenIdle(ctx context.Context, sess ssh.Session, idle *idleWatch, timeout time.Duration, cancel context.CancelFunc) {
+	check := time.NewTicker(idleCheckInterval(timeout))
+	defer check.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-check.C:
+			if idle.idleFor(now) < timeout {
+				continue
+			}
+			slog.Info("Closing idle SSH session", "user", sess.User(), "idle_timeout", timeout)
+			fmt.Fprintf(sess.Stderr(), "\r\nClosing session after %s idle.\r\n", timeout)
+			cancel()
+			return
+		}
+	}
+}
+
+// idleCheckInterval is how often a session is checked for idleness, often
+// enough to close it soon after timeout.
+func idleCheckInterval(timeout time.Duration) time.Duration {
+	return min(max(timeout/10, time.Second), time.Minute)
+}
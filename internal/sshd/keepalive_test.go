@@ -0,0 +1,41 @@
+package sshd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleWatch(t *testing.T) {
+	w := newIdleWatch()
+	w.last.Store(time.Now().Add(-time.Hour).UnixNano())
+
+	if idle := w.idleFor(time.Now()); idle < time.Hour {
+		t.Errorf("idleFor() = %v, want at least 1h", idle)
+	}
+
+	w.touch()
+	if idle := w.idleFor(time.Now()); idle > time.Minute {
+		t.Errorf("idleFor() after touch = %v, want under 1m", idle)
+	}
+
+	// A nil watch ignores activity
+	var nilWatch *idleWatch
+	nilWatch.touch()
+}
+
+func TestIdleCheckInterval(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{timeout: 5 * time.Second, want: time.Second},
+		{timeout: 5 * time.Minute, want: 30 * time.Second},
+		{timeout: 24 * time.Hour, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := idleCheckInterval(tt.timeout); got != tt.want {
+			t.Errorf("idleCheckInterval(%v) = %v, want %v", tt.timeout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package sshd
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// scpRequest is an scp command run by a client using the original scp
+// protocol, in sink mode (-t) to receive files or source mode (-f) to
+// send them.
+type scpRequest struct {
+	sink      bool
+	recursive bool
+	targetDir bool
+	preserve  bool
+	paths     []string
+}
+
+// parseSCPCommand recognizes the command an scp client runs on the server.
+// Options that only affect output, such as -v, are ignored.
+func parseSCPCommand(cmd []string) (*scpRequest, bool) {
+	if len(cmd) < 2 || cmd[0] != "scp" {
+		return nil, false
+	}
+
+	req := &scpRequest{}
+	var source bool
+	args := cmd[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for _, flag := range arg[1:] {
+			switch flag {
+			case 't':
+				req.sink = true
+			case 'f':
+				source = true
+			case 'r':
+				req.recursive = true
+			case 'd':
+				req.targetDir = true
+			case 'p':
+				req.preserve = true
+			}
+		}
+	}
+	if req.sink == source || len(args) == 0 {
+		return nil, false
+	}
+	if req.sink && len(args) != 1 {
+		return nil, false
+	}
+
+	for _, p := range args {
+		req.paths = append(req.paths, scpPath(p))
+	}
+	return req, true
+}
+
+// scpPath resolves a path from an scp client. Relative paths are relative
+// to the workspace, where exec sessions start.
+func scpPath(p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(config.WorkspacePath, p)
+	}
+	return path.Clean(p)
+}
+
+// handleSCP serves an scp command by copying files in or out of the shed
+// with Docker's archive API, so scp works in images without an scp binary.
+func (s *Server) handleSCP(sess ssh.Session, shed *ShedInfo, req *scpRequest) {
+	var err error
+	if req.sink {
+		err = s.receiveSCP(sess.Context(), sess, shed, req)
+	} else {
+		err = s.sendSCP(sess.Context(), sess, shed, req)
+	}
+	if err != nil {
+		slog.Warn("SCP failed", "shed", shed.Name, "err", err)
+		_ = sess.Exit(1)
+		return
+	}
+	_ = sess.Exit(0)
+}
+
+// receiveSCP stores the files an scp client sends in the shed.
+func (s *Server) receiveSCP(ctx context.Context, sess ssh.Session, shed *ShedInfo, req *scpRequest) error {
+	target := req.paths[0]
+	dir, rename := target, ""
+	mode, err := s.docker.StatPath(ctx, shed.ContainerID, target)
+	switch {
+	case err == nil && mode.IsDir():
+	case req.targetDir:
+		return scpFatal(sess, fmt.Errorf("%s: not a directory", target))
+	case err == nil || errors.Is(err, fs.ErrNotExist):
+		// The first file or directory sent takes the target's name
+		dir, rename = path.Dir(target), path.Base(target)
+	default:
+		return scpFatal(sess, fmt.Errorf("%s: %v", target, err))
+	}
+
+	pr, pw := io.Pipe()
+	copied := make(chan error, 1)
+	go func() {
+		err := s.docker.CopyToContainer(ctx, shed.ContainerID, "/", pr)
+		// Unblock the receiver if Docker stopped reading early
+		pr.CloseWithError(io.ErrClosedPipe)
+		copied <- err
+	}()
+
+	tw := tar.NewWriter(pw)
+	err = scpReceive(bufio.NewReader(sess), sess, tw, dir, rename)
+	if err == nil {
+		err = tw.Close()
+	}
+	pw.CloseWithError(err)
+
+	// A failed copy is the real cause of any receive error it led to
+	if copyErr := <-copied; copyErr != nil {
+		fmt.Fprintf(sess.Stderr(), "scp: failed to copy to shed: %v\n", copyErr)
+		return copyErr
+	}
+	return err
+}
+
+// sendSCP sends files from the shed to an scp client.
+func (s *Server) sendSCP(ctx context.Context, sess ssh.Session, shed *ShedInfo, req *scpRequest) error {
+	r := bufio.NewReader(sess)
+	if err := scpReadAck(r); err != nil {
+		return err
+	}
+
+	var failed error
+	for _, p := range req.paths {
+		archive, err := s.docker.CopyFromContainer(ctx, shed.ContainerID, p)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				err = errors.New("No such file or directory")
+			}
+			failed = fmt.Errorf("%s: %v", p, err)
+			scpWarn(sess, failed)
+			continue
+		}
+		err = scpSend(r, sess, tar.NewReader(archive), req.recursive, req.preserve)
+		archive.Close()
+
+		var skipped *scpSkipError
+		switch {
+		case errors.As(err, &skipped):
+			failed = fmt.Errorf("%s: %v", p, err)
+			scpWarn(sess, failed)
+		case err != nil:
+			return err
+		}
+	}
+	return failed
+}
+
+// scpSkipError reports a path that can't be sent, after which the rest
+// are still sent.
+type scpSkipError struct {
+	msg string
+}
+
+func (e *scpSkipError) Error() string {
+	return e.msg
+}
+
+// scpReceive reads the files and directories an scp client sends on r,
+// acknowledging each on w, and writes them to tw under dir. If rename is
+// set, the first file or directory is given that name instead of its own,
+// and nothing else may be sent alongside it.
+func scpReceive(r *bufio.Reader, w io.Writer, tw *tar.Writer, dir, rename string) error {
+	if err := scpAck(w); err != nil {
+		return err
+	}
+
+	var stack []string
+	var modTime time.Time
+	topLevel := 0
+	for {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			if len(stack) > 0 {
+				return errors.New("connection closed inside a directory")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return scpFatal(w, errors.New("empty protocol message"))
+		}
+
+		switch line[0] {
+		case '\x01', '\x02':
+			// The client reporting its own error
+			return errors.New(line[1:])
+		case 'T':
+			mtime, err := parseSCPTimes(line[1:])
+			if err != nil {
+				return scpFatal(w, err)
+			}
+			modTime = mtime
+		case 'E':
+			if len(stack) == 0 {
+				return scpFatal(w, errors.New("unexpected end of directory"))
+			}
+			stack = stack[:len(stack)-1]
+		case 'C', 'D':
+			mode, size, name, err := parseSCPEntry(line[1:])
+			if err != nil {
+				return scpFatal(w, err)
+			}
+			if len(stack) == 0 {
+				topLevel++
+				if rename != "" {
+					if topLevel > 1 {
+						return scpFatal(w, fmt.Errorf("%s: not a directory", path.Join(dir, rename)))
+					}
+					name = rename
+				}
+			}
+			entry := path.Join(append(append([]string{dir}, stack...), name)...)
+			if modTime.IsZero() {
+				modTime = time.Now()
+			}
+			hdr := &tar.Header{
+				Name:    strings.TrimPrefix(entry, "/"),
+				Mode:    int64(mode),
+				ModTime: modTime,
+			}
+			modTime = time.Time{}
+
+			if line[0] == 'D' {
+				hdr.Typeflag = tar.TypeDir
+				hdr.Name += "/"
+				if err := tw.WriteHeader(hdr); err != nil {
+					return scpFatal(w, err)
+				}
+				stack = append(stack, name)
+				break
+			}
+
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = size
+			if err := tw.WriteHeader(hdr); err != nil {
+				return scpFatal(w, err)
+			}
+			if err := scpAck(w); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(tw, r, size); err != nil {
+				return scpFatal(w, err)
+			}
+			// The data is followed by the client's status byte
+			if err := scpReadAck(r); err != nil {
+				return err
+			}
+		default:
+			return scpFatal(w, fmt.Errorf("unexpected protocol message %q", line))
+		}
+
+		if err := scpAck(w); err != nil {
+			return err
+		}
+	}
+}
+
+// scpSend sends the files and directories in tr, a Docker archive of one
+// path, to an scp client, reading its acknowledgements from r.
+func scpSend(r *bufio.Reader, w io.Writer, tr *tar.Reader, recursive, preserve bool) error {
+	var stack []string
+	send := func(msg string) error {
+		if _, err := io.WriteString(w, msg); err != nil {
+			return err
+		}
+		return scpReadAck(r)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+		parent, base := path.Split(name)
+		parents := strings.Split(strings.TrimSuffix(parent, "/"), "/")
+		if parent == "" {
+			parents = nil
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if !recursive {
+				return &scpSkipError{"not a regular file"}
+			}
+		case tar.TypeReg:
+		default:
+			// scp only copies regular files and directories
+			continue
+		}
+
+		// Leave the directories this entry isn't in
+		for !samePrefix(stack, parents) {
+			if err := send("E\n"); err != nil {
+				return err
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		if preserve {
+			mtime := hdr.ModTime.Unix()
+			if err := send(fmt.Sprintf("T%d 0 %d 0\n", mtime, mtime)); err != nil {
+				return err
+			}
+		}
+
+		mode := hdr.FileInfo().Mode().Perm()
+		if hdr.Typeflag == tar.TypeDir {
+			if err := send(fmt.Sprintf("D%04o 0 %s\n", mode, base)); err != nil {
+				return err
+			}
+			stack = append(stack, base)
+			continue
+		}
+
+		if err := send(fmt.Sprintf("C%04o %d %s\n", mode, hdr.Size, base)); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, tr, hdr.Size); err != nil {
+			return err
+		}
+		if err := send("\x00"); err != nil {
+			return err
+		}
+	}
+
+	for range stack {
+		if err := send("E\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// samePrefix reports whether stack is a prefix of dirs.
+func samePrefix(stack, dirs []string) bool {
+	if len(stack) > len(dirs) {
+		return false
+	}
+	for i := range stack {
+		if stack[i] != dirs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSCPEntry parses the mode, size, and name of a C or D message.
+func parseSCPEntry(s string) (fs.FileMode, int64, string, error) {
+	fields := strings.SplitN(s, " ", 3)
+	if len(fields) != 3 {
+		return 0, 0, "", fmt.Errorf("invalid protocol message %q", s)
+	}
+	mode, err := strconv.ParseUint(fields[0], 8, 32)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("invalid mode %q", fields[0])
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		return 0, 0, "", fmt.Errorf("invalid size %q", fields[1])
+	}
+	name := fields[2]
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return 0, 0, "", fmt.Errorf("invalid file name %q", name)
+	}
+	return fs.FileMode(mode) & fs.ModePerm, size, name, nil
+}
+
+// parseSCPTimes parses the modification time from a T message.
+func parseSCPTimes(s string) (time.Time, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 4 {
+		return time.Time{}, fmt.Errorf("invalid times %q", s)
+	}
+	mtime, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid times %q", s)
+	}
+	return time.Unix(mtime, 0), nil
+}
+
+// scpAck tells the client the last message was handled.
+func scpAck(w io.Writer) error {
+	_, err := w.Write([]byte{0})
+	return err
+}
+
+// scpReadAck reads the client's reply to the last message.
+func scpReadAck(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0 {
+		return nil
+	}
+	msg, _ := r.ReadString('\n')
+	return errors.New(strings.TrimSpace(msg))
+}
+
+// scpWarn reports an error the transfer continues after.
+func scpWarn(w io.Writer, err error) {
+	fmt.Fprintf(w, "\x01scp: %v\n", err)
+}
+
+// scpFatal reports an error that ends the transfer, and returns it.
+func scpFatal(w io.Writer, err error) error {
+	fmt.Fprintf(w, "\x02scp: %v\n", err)
+	return err
+}
@@ -0,0 +1,182 @@
+package sshd
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSCPCommand(t *testing.T) {
+	tests := []struct {
+		cmd  []string
+		want *scpRequest
+	}{
+		{[]string{"scp", "-t", "/workspace/"}, &scpRequest{sink: true, paths: []string{"/workspace"}}},
+		{[]string{"scp", "-v", "-r", "-d", "-t", "--", "src"}, &scpRequest{sink: true, recursive: true, targetDir: true, paths: []string{"/workspace/src"}}},
+		{[]string{"scp", "-pf", "a.txt", "/etc/hosts"}, &scpRequest{preserve: true, paths: []string{"/workspace/a.txt", "/etc/hosts"}}},
+		{[]string{"scp", "-t", "a", "b"}, nil},
+		{[]string{"scp", "-t", "-f", "a"}, nil},
+		{[]string{"scp", "a", "b"}, nil},
+		{[]string{"ls", "-t", "a"}, nil},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseSCPCommand(tt.cmd)
+		if ok != (tt.want != nil) {
+			t.Errorf("parseSCPCommand(%q) ok = %v, want %v", tt.cmd, ok, tt.want != nil)
+			continue
+		}
+		if ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSCPCommand(%q) = %+v, want %+v", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+// tarEntries lists the entries of a tar archive as "name mode [content]".
+func tarEntries(t *testing.T, data []byte) []string {
+	t.Helper()
+	var entries []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(tr)
+		entry := hdr.Name + " " + hdr.FileInfo().Mode().Perm().String()
+		if hdr.Typeflag == tar.TypeReg {
+			entry += " " + string(content)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func TestSCPReceive(t *testing.T) {
+	input := "T1700000000 0 1700000000 0\n" +
+		"C0644 5 hello.txt\nhello\x00" +
+		"D0755 0 src\n" +
+		"C0600 2 main.go\nhi\x00" +
+		"E\n"
+
+	var out, archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := scpReceive(bufio.NewReader(strings.NewReader(input)), &out, tw, "/workspace", ""); err != nil {
+		t.Fatalf("scpReceive() error: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// One ack to start, then one per message and one per file's data
+	if want := strings.Repeat("\x00", 8); out.String() != want {
+		t.Errorf("acks = %q, want %q", out.String(), want)
+	}
+	want := []string{
+		"workspace/hello.txt -rw-r--r-- hello",
+		"workspace/src/ -rwxr-xr-x",
+		"workspace/src/main.go -rw------- hi",
+	}
+	if got := tarEntries(t, archive.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	hdr, _ := tr.Next()
+	if !hdr.ModTime.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("hello.txt ModTime = %v, want the time sent", hdr.ModTime)
+	}
+}
+
+func TestSCPReceiveRename(t *testing.T) {
+	var out, archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	input := "C0644 2 local.txt\nhi\x00"
+	if err := scpReceive(bufio.NewReader(strings.NewReader(input)), &out, tw, "/workspace", "remote.txt"); err != nil {
+		t.Fatalf("scpReceive() error: %v", err)
+	}
+	_ = tw.Close()
+	if got := tarEntries(t, archive.Bytes()); !reflect.DeepEqual(got, []string{"workspace/remote.txt -rw-r--r-- hi"}) {
+		t.Errorf("entries = %q, want the file renamed", got)
+	}
+
+	// A second file can't be sent to a target that isn't a directory
+	input += "C0644 2 other.txt\nhi\x00"
+	err := scpReceive(bufio.NewReader(strings.NewReader(input)), &out, tar.NewWriter(io.Discard), "/workspace", "remote.txt")
+	if err == nil {
+		t.Error("scpReceive() should reject a second file for a renamed target")
+	}
+}
+
+func TestSCPReceiveRejectsBadNames(t *testing.T) {
+	for _, name := range []string{"..", "../etc/passwd", "a/b"} {
+		var out bytes.Buffer
+		input := "C0644 2 " + name + "\nhi\x00"
+		err := scpReceive(bufio.NewReader(strings.NewReader(input)), &out, tar.NewWriter(io.Discard), "/workspace", "")
+		if err == nil {
+			t.Errorf("scpReceive() accepted file name %q", name)
+		}
+		if !strings.Contains(out.String(), "\x02scp: invalid file name") {
+			t.Errorf("output = %q, want a fatal error for %q", out.String(), name)
+		}
+	}
+}
+
+func TestSCPSend(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	mtime := time.Unix(1700000000, 0)
+	for _, f := range []struct {
+		name    string
+		mode    int64
+		content string
+	}{
+		{"src/", 0755, ""},
+		{"src/main.go", 0644, "package main"},
+		{"src/lib/", 0700, ""},
+		{"src/lib/lib.go", 0600, "x"},
+		{"src/README", 0644, "hi"},
+	} {
+		hdr := &tar.Header{Name: f.name, Mode: f.mode, Size: int64(len(f.content)), ModTime: mtime, Typeflag: tar.TypeReg}
+		if strings.HasSuffix(f.name, "/") {
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write([]byte(f.content))
+	}
+	_ = tw.Close()
+
+	acks := bufio.NewReader(strings.NewReader(strings.Repeat("\x00", 32)))
+	var out bytes.Buffer
+	if err := scpSend(acks, &out, tar.NewReader(bytes.NewReader(archive.Bytes())), true, false); err != nil {
+		t.Fatalf("scpSend() error: %v", err)
+	}
+
+	want := "D0755 0 src\n" +
+		"C0644 12 main.go\npackage main\x00" +
+		"D0700 0 lib\n" +
+		"C0600 1 lib.go\nx\x00" +
+		"E\n" +
+		"C0644 2 README\nhi\x00" +
+		"E\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	// Directories are only sent with -r
+	err := scpSend(acks, io.Discard, tar.NewReader(bytes.NewReader(archive.Bytes())), false, false)
+	var skipped *scpSkipError
+	if !errors.As(err, &skipped) {
+		t.Errorf("scpSend() without recursive error = %v, want a skipped path", err)
+	}
+}
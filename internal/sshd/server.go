@@ -3,18 +3,18 @@ package sshd
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/rand"
-	"encoding/pem"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
 	"net"
-	"os"
-	"path/filepath"
 
 	"github.com/gliderlabs/ssh"
 	gossh "golang.org/x/crypto/ssh"
 
+	"github.com/charliek/shed/internal/activity"
+	"github.com/charliek/shed/internal/audit"
+	"github.com/charliek/shed/internal/config"
 	"github.com/charliek/shed/internal/terminal"
 )
 
@@ -27,8 +27,31 @@ type DockerClient interface {
 	// StartShed starts a stopped shed.
 	StartShed(ctx context.Context, name string) error
 
+	// ResumeShed unfreezes a paused shed.
+	ResumeShed(ctx context.Context, name string) error
+
+	// WaitForStatus blocks until a shed reaches the given status or ctx is done.
+	WaitForStatus(ctx context.Context, name, status string) error
+
+	// ShedAddress returns a running shed's IP address and its network's
+	// gateway, the Docker host as seen from the shed.
+	ShedAddress(ctx context.Context, name string) (ip, gateway string, err error)
+
 	// ExecInContainer executes a command in a container with the given options.
+	// A command that runs but exits non-zero returns an *ExitError.
 	ExecInContainer(ctx context.Context, containerID string, opts ExecOptions) error
+
+	// StatPath returns the mode of a path in a container, or an error
+	// matching fs.ErrNotExist if there is nothing there.
+	StatPath(ctx context.Context, containerID, path string) (fs.FileMode, error)
+
+	// CopyFromContainer returns a tar archive of a path in a container,
+	// with the path's base name as its root.
+	CopyFromContainer(ctx context.Context, containerID, path string) (io.ReadCloser, error)
+
+	// CopyToContainer extracts a tar archive into dir in a container,
+	// owned by the container's user.
+	CopyToContainer(ctx context.Context, containerID, dir string, content io.Reader) error
 }
 
 // ShedInfo contains information about a shed needed by the SSH server.
@@ -36,6 +59,14 @@ type ShedInfo struct {
 	Name        string
 	Status      string
 	ContainerID string
+
+	// Forwarding holds the shed's own port forwarding rules, if any.
+	Forwarding *config.ForwardingRules
+
+	// Env holds environment variables to start sessions with that were
+	// changed since the shed's container was created. A name without a
+	// value unsets the variable.
+	Env []string
 }
 
 // ExecOptions contains options for executing a command in a container.
@@ -59,6 +90,21 @@ type ExecOptions struct {
 
 	// ResizeChan receives terminal resize events.
 	ResizeChan <-chan TerminalSize
+
+	// KillOnCancel kills the command, and everything it started, if ctx
+	// ends first. Interactive shells and subsystems don't need it: they
+	// end when their input does.
+	KillOnCancel bool
+}
+
+// ExitError reports that a command run by ExecInContainer exited with a
+// non-zero code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.Code)
 }
 
 // TerminalSize represents terminal dimensions.
@@ -79,154 +125,199 @@ type WriteCloser interface {
 	Close() error
 }
 
+// KeyAuthorizer decides which public keys may connect to which sheds.
+type KeyAuthorizer interface {
+	// Enabled reports whether keys are checked at all. When false, any key
+	// is accepted.
+	Enabled() bool
+
+	// Authorized returns the name of a key matching key that may connect
+	// to shed, or false if there is none.
+	Authorized(shed string, key gossh.PublicKey) (string, bool)
+}
+
 // Server is an SSH server that connects users to shed containers.
 type Server struct {
-	sshServer   *ssh.Server
-	docker      DockerClient
-	hostKeyPath string
-	port        int
-	hostKey     gossh.Signer
-	listener    net.Listener
-	termConfig  *terminal.Config
+	sshServer  *ssh.Server
+	docker     DockerClient
+	addrs      []string
+	hostKeys   *hostKeys
+	termConfig *terminal.Config
+	forwarding config.ForwardingConfig
+	sshConfig  config.SSHConfig
+	keys       KeyAuthorizer
+	activity   *activity.Tracker
+	audit      *audit.Log
+}
+
+// ServerOptions configures an SSH server.
+type ServerOptions struct {
+	// HostKeyPath is where the host key is kept, generated if missing.
+	HostKeyPath string
+	// Addrs are the addresses to listen on.
+	Addrs []string
+	// Terminal sets up the sessions of interactive shells.
+	Terminal *terminal.Config
+	// Forwarding is the server's port forwarding policy.
+	Forwarding config.ForwardingConfig
+	// SSH holds the keepalive and idle timeout settings.
+	SSH config.SSHConfig
+	// Keys decides which keys may connect to which sheds. Nil accepts any
+	// key.
+	Keys KeyAuthorizer
+	// Activity is told when sheds are in use, and Audit records
+	// connections.
+	Activity *activity.Tracker
+	Audit    *audit.Log
 }
 
-// NewServer creates a new SSH server.
-func NewServer(dockerClient DockerClient, hostKeyPath string, port int, termConfig *terminal.Config) (*Server, error) {
+// NewServer creates a new SSH server that listens on opts.Addrs.
+func NewServer(dockerClient DockerClient, opts ServerOptions) (*Server, error) {
 	s := &Server{
-		docker:      dockerClient,
-		hostKeyPath: hostKeyPath,
-		port:        port,
-		termConfig:  termConfig,
+		docker:     dockerClient,
+		addrs:      opts.Addrs,
+		termConfig: opts.Terminal,
+		forwarding: opts.Forwarding,
+		sshConfig:  opts.SSH,
+		keys:       opts.Keys,
+		activity:   opts.Activity,
+		audit:      opts.Audit,
 	}
 
 	// Load or generate the host key.
-	hostKey, err := s.loadOrGenerateHostKey()
+	hostKeys, err := loadHostKeys(opts.HostKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load or generate host key: %w", err)
 	}
-	s.hostKey = hostKey
+	s.hostKeys = hostKeys
 
 	// Create the SSH server.
 	s.sshServer = &ssh.Server{
-		Addr: fmt.Sprintf(":%d", port),
+		ConnCallback: func(ctx ssh.Context, conn net.Conn) net.Conn {
+			// Pick up a finished host key rotation
+			hostKeys.load()
+			return conn
+		},
 		PublicKeyHandler: func(ctx ssh.Context, key ssh.PublicKey) bool {
 			return s.handlePublicKey(ctx, key)
 		},
 		Handler: func(sess ssh.Session) {
 			s.handleSession(sess)
 		},
+		SubsystemHandlers: map[string]ssh.SubsystemHandler{
+			"sftp": func(sess ssh.Session) {
+				s.handleSFTP(sess)
+			},
+		},
+		ChannelHandlers: map[string]ssh.ChannelHandler{
+			"session":      s.withKeepalive(ssh.DefaultSessionHandler),
+			"direct-tcpip": s.withKeepalive(s.handleDirectTCPIP),
+		},
+		RequestHandlers: map[string]ssh.RequestHandler{
+			"tcpip-forward":        s.withKeepaliveRequest(s.handleRemoteForward),
+			"cancel-tcpip-forward": s.withKeepaliveRequest(s.handleRemoteForward),
+		},
 	}
 
-	// Add the host key to the server.
-	s.sshServer.AddHostKey(hostKey)
+	// Add the host key to the server. It follows rotations on disk.
+	s.sshServer.AddHostKey(&rotatingSigner{keys: hostKeys})
 
 	return s, nil
 }
 
-// loadOrGenerateHostKey loads an ED25519 host key from the configured path,
-// or generates a new one if it doesn't exist.
-func (s *Server) loadOrGenerateHostKey() (gossh.Signer, error) {
-	// Check if the key file exists.
-	keyData, err := os.ReadFile(s.hostKeyPath)
-	if err == nil {
-		// Key exists, parse it.
-		signer, err := gossh.ParsePrivateKey(keyData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse existing host key: %w", err)
-		}
-		log.Printf("Loaded existing host key from %s", s.hostKeyPath)
-		return signer, nil
-	}
-
-	if !os.IsNotExist(err) {
-		return nil, fmt.Errorf("failed to read host key file: %w", err)
-	}
-
-	// Key doesn't exist, generate a new one.
-	log.Printf("Generating new ED25519 host key...")
-	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate ED25519 key: %w", err)
-	}
-
-	// Convert to OpenSSH format.
-	signer, err := gossh.NewSignerFromKey(privKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create signer: %w", err)
-	}
-
-	// Marshal the private key to PEM format.
-	pemBlock, err := gossh.MarshalPrivateKey(privKey, "")
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal private key: %w", err)
-	}
-
-	pemData := pem.EncodeToMemory(pemBlock)
-
-	// Ensure the directory exists.
-	dir := filepath.Dir(s.hostKeyPath)
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create key directory: %w", err)
-	}
-
-	// Write the private key file with restricted permissions.
-	if err := os.WriteFile(s.hostKeyPath, pemData, 0600); err != nil {
-		return nil, fmt.Errorf("failed to write host key: %w", err)
-	}
-
-	log.Printf("Generated new host key: %s", s.hostKeyPath)
-	log.Printf("Public key fingerprint: %s", gossh.FingerprintSHA256(signer.PublicKey()))
-
-	// Also save the public key for convenience.
-	pubKeyPath := s.hostKeyPath + ".pub"
-	pubKeyData := gossh.MarshalAuthorizedKey(signer.PublicKey())
-	if err := os.WriteFile(pubKeyPath, pubKeyData, 0644); err != nil {
-		log.Printf("Warning: failed to write public key file: %v", err)
-	}
-
-	_ = pubKey // Silence unused variable warning.
-
-	return signer, nil
-}
-
-// GetHostPublicKey returns the SSH public key in authorized_keys format.
-func (s *Server) GetHostPublicKey() string {
-	if s.hostKey == nil {
-		return ""
+// HostPublicKeys returns the host key the server presents and, while a
+// rotation is in progress, the key that will replace it, both in
+// authorized_keys format.
+func (s *Server) HostPublicKeys() (current, next string) {
+	currentKey, nextKey := s.hostKeys.load()
+	current = string(gossh.MarshalAuthorizedKey(currentKey.PublicKey()))
+	if nextKey != nil {
+		next = string(gossh.MarshalAuthorizedKey(nextKey.PublicKey()))
 	}
-	return string(gossh.MarshalAuthorizedKey(s.hostKey.PublicKey()))
+	return current, next
 }
 
-// Start begins listening for SSH connections.
+// Start begins listening for SSH connections on every address and serves
+// them until one listener fails.
 func (s *Server) Start() error {
-	addr := fmt.Sprintf(":%d", s.port)
-	listener, err := net.Listen("tcp", addr)
-	if err != nil {
-		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	listeners := make([]net.Listener, 0, len(s.addrs))
+	for _, addr := range s.addrs {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, ln)
+		slog.Info("SSH server listening", "addr", addr)
 	}
-	s.listener = listener
 
-	log.Printf("SSH server listening on %s", addr)
-	log.Printf("Host key fingerprint: %s", gossh.FingerprintSHA256(s.hostKey.PublicKey()))
+	current, next := s.hostKeys.load()
+	slog.Info("Host key fingerprint", "fingerprint", gossh.FingerprintSHA256(current.PublicKey()))
+	if next != nil {
+		slog.Info("Host key rotation in progress", "next_fingerprint", gossh.FingerprintSHA256(next.PublicKey()))
+	}
 
-	return s.sshServer.Serve(listener)
+	errc := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			errc <- s.sshServer.Serve(ln)
+		}(ln)
+	}
+	return <-errc
 }
 
 // Shutdown gracefully shuts down the SSH server.
 func (s *Server) Shutdown(ctx context.Context) error {
-	log.Printf("Shutting down SSH server...")
+	slog.Info("Shutting down SSH server")
 	return s.sshServer.Shutdown(ctx)
 }
 
-// handlePublicKey handles public key authentication.
-// For MVP, we accept all keys and just log the fingerprint.
+// Context keys for the key a connection authenticated with.
+const (
+	keyNameContextKey        = "shed-key-name"
+	keyFingerprintContextKey = "shed-key-fingerprint"
+)
+
+// handlePublicKey handles public key authentication. The username is the
+// shed name, so keys registered for a single shed are checked against it.
+// Until any key is configured, all keys are accepted.
 func (s *Server) handlePublicKey(ctx ssh.Context, key ssh.PublicKey) bool {
 	fingerprint := gossh.FingerprintSHA256(key)
 	user := ctx.User()
 
-	log.Printf("SSH auth attempt: user=%s fingerprint=%s", user, fingerprint)
+	ctx.SetValue(keyFingerprintContextKey, fingerprint)
+
+	if s.keys == nil || !s.keys.Enabled() {
+		slog.Info("SSH auth accepted with no keys configured", "user", user, "fingerprint", fingerprint)
+		return true
+	}
 
-	// For MVP, accept all keys.
-	// TODO: Implement proper key verification against stored keys.
+	name, ok := s.keys.Authorized(user, key)
+	if !ok {
+		slog.Warn("SSH auth rejected", "user", user, "fingerprint", fingerprint)
+		return false
+	}
+
+	slog.Info("SSH auth accepted", "user", user, "fingerprint", fingerprint, "key", name)
+	ctx.SetValue(keyNameContextKey, name)
 	return true
 }
+
+// recordAudit records an action taken by a session in the audit log,
+// naming the key the connection authenticated with.
+func (s *Server) recordAudit(sess ssh.Session, action, shed, detail string) {
+	ctx := sess.Context()
+	name, _ := ctx.Value(keyNameContextKey).(string)
+	fingerprint, _ := ctx.Value(keyFingerprintContextKey).(string)
+	s.audit.Record(config.AuditEntry{
+		Via:         config.AuditViaSSH,
+		Actor:       name,
+		Fingerprint: fingerprint,
+		Remote:      sess.RemoteAddr().String(),
+		Action:      action,
+		Shed:        shed,
+		Detail:      detail,
+	})
+}
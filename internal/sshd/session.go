@@ -2,8 +2,10 @@ package sshd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/gliderlabs/ssh"
@@ -17,9 +19,6 @@ const (
 
 	// containerReadyTimeout is the maximum time to wait for a container to be ready.
 	containerReadyTimeout = 10 * time.Second
-
-	// containerReadyPollInterval is how often to check if the container is ready.
-	containerReadyPollInterval = 250 * time.Millisecond
 )
 
 // handleSession is the main session handler for SSH connections.
@@ -27,15 +26,60 @@ func (s *Server) handleSession(sess ssh.Session) {
 	user := sess.User()
 	remoteAddr := sess.RemoteAddr()
 
-	log.Printf("SSH session started: user=%s remote=%s", user, remoteAddr)
-	defer log.Printf("SSH session ended: user=%s remote=%s", user, remoteAddr)
+	slog.Info("SSH session started", "user", user, "remote", remoteAddr.String())
+	defer slog.Info("SSH session ended", "user", user, "remote", remoteAddr.String())
+
+	shed := s.resolveShed(sess)
+	if shed == nil {
+		return
+	}
+	if req, ok := parseSCPCommand(sess.Command()); ok {
+		s.recordAudit(sess, "scp", shed.Name, sess.RawCommand())
+		defer s.activity.Begin(shed.Name)()
+		s.handleSCP(sess, shed, req)
+		return
+	}
+	if cmd := sess.RawCommand(); cmd != "" {
+		s.recordAudit(sess, "exec", shed.Name, cmd)
+	} else {
+		s.recordAudit(sess, "console", shed.Name, "")
+	}
+	defer s.activity.Begin(shed.Name)()
+
+	// Execute in the container.
+	err := s.execInContainer(sess.Context(), sess, shed)
+	if err != nil && !errors.As(err, new(*ExitError)) {
+		slog.Warn("Exec failed", "shed", shed.Name, "err", err)
+		// Don't write error to stderr here as it may have already been closed.
+	}
+	_ = sess.Exit(exitStatus(err))
+}
+
+// exitStatus returns the exit status to report to the client for the
+// result of an exec: the command's own exit code, or 1 if it didn't run.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
+// resolveShed looks up the shed named by the session's user, starting or
+// resuming it as needed. On failure the error is reported to the client,
+// the session is exited, and nil is returned.
+func (s *Server) resolveShed(sess ssh.Session) *ShedInfo {
+	user := sess.User()
 
 	// Check for reserved usernames.
 	if user == reservedAPIUser {
-		log.Printf("Rejected reserved username: %s", user)
+		slog.Warn("Rejected reserved username", "user", user)
 		fmt.Fprintf(sess.Stderr(), "Error: username '%s' is reserved for API access\n", user)
 		_ = sess.Exit(1)
-		return
+		return nil
 	}
 
 	// Extract shed name from username (username maps directly to shed name).
@@ -43,10 +87,10 @@ func (s *Server) handleSession(sess ssh.Session) {
 
 	// Validate shed name.
 	if shedName == "" {
-		log.Printf("Empty shed name from user")
+		slog.Warn("Rejected empty username")
 		fmt.Fprintf(sess.Stderr(), "Error: invalid username\n")
 		_ = sess.Exit(1)
-		return
+		return nil
 	}
 
 	ctx := sess.Context()
@@ -54,90 +98,80 @@ func (s *Server) handleSession(sess ssh.Session) {
 	// Look up the shed.
 	shed, err := s.docker.GetShed(ctx, shedName)
 	if err != nil {
-		log.Printf("Failed to get shed %s: %v", shedName, err)
+		slog.Warn("Failed to get shed", "shed", shedName, "err", err)
 		fmt.Fprintf(sess.Stderr(), "Error: shed '%s' not found\n", shedName)
 		_ = sess.Exit(1)
-		return
+		return nil
 	}
 
 	// Auto-start if stopped.
 	if shed.Status == config.StatusStopped {
-		log.Printf("Auto-starting stopped shed: %s", shedName)
+		slog.Info("Auto-starting stopped shed", "shed", shedName)
 		fmt.Fprintf(sess.Stderr(), "Starting shed '%s'...\n", shedName)
 
 		if err := s.docker.StartShed(ctx, shedName); err != nil {
-			log.Printf("Failed to start shed %s: %v", shedName, err)
+			slog.Warn("Failed to start shed", "shed", shedName, "err", err)
 			fmt.Fprintf(sess.Stderr(), "Error: failed to start shed: %v\n", err)
 			_ = sess.Exit(1)
-			return
+			return nil
 		}
 
 		// Wait for the container to be ready.
 		if err := s.waitForReady(ctx, shedName); err != nil {
-			log.Printf("Shed %s not ready: %v", shedName, err)
+			slog.Warn("Shed not ready", "shed", shedName, "err", err)
 			fmt.Fprintf(sess.Stderr(), "Error: shed not ready: %v\n", err)
 			_ = sess.Exit(1)
-			return
+			return nil
 		}
 
 		// Refresh shed info after starting.
 		shed, err = s.docker.GetShed(ctx, shedName)
 		if err != nil {
-			log.Printf("Failed to get shed %s after start: %v", shedName, err)
+			slog.Warn("Failed to get shed after start", "shed", shedName, "err", err)
 			fmt.Fprintf(sess.Stderr(), "Error: failed to get shed after start: %v\n", err)
 			_ = sess.Exit(1)
-			return
+			return nil
+		}
+	}
+
+	// Resume if paused; the container is still running so no wait is needed.
+	if shed.Status == config.StatusPaused {
+		slog.Info("Resuming paused shed", "shed", shedName)
+		fmt.Fprintf(sess.Stderr(), "Resuming shed '%s'...\n", shedName)
+
+		if err := s.docker.ResumeShed(ctx, shedName); err != nil {
+			slog.Warn("Failed to resume shed", "shed", shedName, "err", err)
+			fmt.Fprintf(sess.Stderr(), "Error: failed to resume shed: %v\n", err)
+			_ = sess.Exit(1)
+			return nil
 		}
+
+		shed.Status = config.StatusRunning
 	}
 
 	// Verify the shed is running.
 	if shed.Status != config.StatusRunning {
-		log.Printf("Shed %s is not running (status: %s)", shedName, shed.Status)
+		slog.Warn("Shed is not running", "shed", shedName, "status", shed.Status)
 		fmt.Fprintf(sess.Stderr(), "Error: shed '%s' is not running (status: %s)\n", shedName, shed.Status)
 		_ = sess.Exit(1)
-		return
-	}
-
-	// Execute in the container.
-	if err := s.execInContainer(ctx, sess, shed); err != nil {
-		log.Printf("Exec failed for shed %s: %v", shedName, err)
-		// Don't write error to stderr here as it may have already been closed.
-		_ = sess.Exit(1)
-		return
+		return nil
 	}
 
-	_ = sess.Exit(0)
+	return shed
 }
 
-// waitForReady polls until the container is ready or timeout.
+// waitForReady waits until the container is running or the timeout passes.
 func (s *Server) waitForReady(ctx context.Context, shedName string) error {
-	deadline := time.Now().Add(containerReadyTimeout)
-	ticker := time.NewTicker(containerReadyPollInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			if time.Now().After(deadline) {
-				return fmt.Errorf("timeout waiting for shed to be ready")
-			}
+	ctx, cancel := context.WithTimeout(ctx, containerReadyTimeout)
+	defer cancel()
 
-			shed, err := s.docker.GetShed(ctx, shedName)
-			if err != nil {
-				continue // Keep trying.
-			}
-
-			if shed.Status == config.StatusRunning {
-				return nil
-			}
-
-			if shed.Status == config.StatusError {
-				return fmt.Errorf("shed entered error state")
-			}
+	if err := s.docker.WaitForStatus(ctx, shedName, config.StatusRunning); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("timeout waiting for shed to be ready")
 		}
+		return err
 	}
+	return nil
 }
 
 // execInContainer executes a command or shell in the container.
@@ -148,8 +182,10 @@ func (s *Server) execInContainer(ctx context.Context, sess ssh.Session, shed *Sh
 	// Check if we have a PTY request.
 	ptyReq, winCh, isPTY := sess.Pty()
 
-	// Build environment variables.
-	var env []string
+	// Build environment variables, starting with those the client sent
+	// that the server accepts. The shed's own variables win over them.
+	env := withoutEnv(s.termConfig.AcceptedEnv(sess.Environ()), shed.Env)
+	env = append(env, shed.Env...)
 	if isPTY {
 		// Normalize the TERM value using configured mappings
 		term := s.termConfig.NormalizeTerm(ptyReq.Term)
@@ -177,23 +213,57 @@ func (s *Server) execInContainer(ctx context.Context, sess ssh.Session, shed *Sh
 		}
 	}
 
+	// Close sessions that go idle for too long, which kills the command
+	var idle *idleWatch
+	if timeout := s.sshConfig.IdleTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		idle = newIdleWatch()
+		go closeWhenIdle(ctx, sess, idle, timeout, cancel)
+	}
+
 	// Create the exec options.
 	opts := ExecOptions{
 		Cmd:         cmd,
-		Stdin:       &sessionReadCloser{sess},
-		Stdout:      &sessionWriteCloser{sess},
-		Stderr:      &sessionStderrWriteCloser{sess},
+		Stdin:       &sessionReadCloser{sess, idle},
+		Stdout:      &sessionWriteCloser{sess, idle},
+		Stderr:      &sessionStderrWriteCloser{sess, idle},
 		TTY:         isPTY,
 		Env:         env,
 		InitialSize: initialSize,
 		ResizeChan:  resizeChan,
+
+		// A command run without a terminal would otherwise keep running
+		// after the client disconnects
+		KillOnCancel: !isPTY && len(cmd) > 0,
 	}
 
-	log.Printf("Executing in container %s: tty=%v cmd=%v", shed.ContainerID, isPTY, cmd)
+	slog.Debug("Executing in container", "shed", shed.Name, "container", shed.ContainerID, "tty", isPTY, "cmd", cmd)
 
 	return s.docker.ExecInContainer(ctx, shed.ContainerID, opts)
 }
 
+// withoutEnv returns the variables in env that aren't named in override.
+func withoutEnv(env, override []string) []string {
+	if len(override) == 0 {
+		return env
+	}
+	names := make(map[string]bool, len(override))
+	for _, kv := range override {
+		name, _, _ := strings.Cut(kv, "=")
+		names[name] = true
+	}
+	kept := env[:0]
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !names[name] {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
 // handleWindowResize forwards window resize events from SSH to the resize channel.
 func (s *Server) handleWindowResize(ctx context.Context, winCh <-chan ssh.Window, resizeChan chan<- TerminalSize) {
 	for {
@@ -220,10 +290,13 @@ func (s *Server) handleWindowResize(ctx context.Context, winCh <-chan ssh.Window
 // sessionReadCloser wraps an ssh.Session to implement ReadCloser.
 type sessionReadCloser struct {
 	sess ssh.Session
+	idle *idleWatch
 }
 
 func (r *sessionReadCloser) Read(p []byte) (n int, err error) {
-	return r.sess.Read(p)
+	n, err = r.sess.Read(p)
+	r.idle.touch()
+	return n, err
 }
 
 func (r *sessionReadCloser) Close() error {
@@ -233,9 +306,11 @@ func (r *sessionReadCloser) Close() error {
 // sessionWriteCloser wraps an ssh.Session to implement WriteCloser for stdout.
 type sessionWriteCloser struct {
 	sess ssh.Session
+	idle *idleWatch
 }
 
 func (w *sessionWriteCloser) Write(p []byte) (n int, err error) {
+	w.idle.touch()
 	return w.sess.Write(p)
 }
 
@@ -246,9 +321,11 @@ func (w *sessionWriteCloser) Close() error {
 // sessionStderrWriteCloser wraps an ssh.Session to implement WriteCloser for stderr.
 type sessionStderrWriteCloser struct {
 	sess ssh.Session
+	idle *idleWatch
 }
 
 func (w *sessionStderrWriteCloser) Write(p []byte) (n int, err error) {
+	w.idle.touch()
 	return w.sess.Stderr().Write(p)
 }
 
@@ -0,0 +1,27 @@
+package sshd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, 0},
+		{"exit code", &ExitError{Code: 42}, 42},
+		{"wrapped exit code", fmt.Errorf("exec: %w", &ExitError{Code: 130}), 130},
+		{"failed to run", errors.New("failed to create exec"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitStatus(tt.err); got != tt.want {
+				t.Errorf("exitStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package sshd
+
+import (
+	"log/slog"
+
+	"github.com/gliderlabs/ssh"
+
+	"github.com/charliek/shed/internal/config"
+)
+
+// sftpServerCmd runs the image's OpenSSH sftp-server rooted at the
+// workspace, checking the install paths used by common distributions.
+var sftpServerCmd = []string{"/bin/sh", "-c", `
+for p in /usr/lib/openssh/sftp-server /usr/libexec/openssh/sftp-server /usr/lib/ssh/sftp-server /usr/libexec/sftp-server; do
+	[ -x "$p" ] && exec "$p" -d ` + config.WorkspacePath + `
+done
+echo "sftp-server is not installed in this shed's image" >&2
+exit 127
+`}
+
+// handleSFTP serves the sftp subsystem by running sftp-server inside the
+// shed, so sftp clients and editor plugins see the shed's filesystem.
+func (s *Server) handleSFTP(sess ssh.Session) {
+	slog.Info("SFTP session started", "user", sess.User(), "remote", sess.RemoteAddr().String())
+	defer slog.Info("SFTP session ended", "user", sess.User(), "remote", sess.RemoteAddr().String())
+
+	shed := s.resolveShed(sess)
+	if shed == nil {
+		return
+	}
+	s.recordAudit(sess, "sftp", shed.Name, "")
+	defer s.activity.Begin(shed.Name)()
+
+	opts := ExecOptions{
+		Cmd:    sftpServerCmd,
+		Stdin:  &sessionReadCloser{sess: sess},
+		Stdout: &sessionWriteCloser{sess: sess},
+		Stderr: &sessionStderrWriteCloser{sess: sess},
+	}
+
+	err := s.docker.ExecInContainer(sess.Context(), shed.ContainerID, opts)
+	if err != nil {
+		slog.Warn("SFTP failed", "shed", shed.Name, "err", err)
+	}
+	_ = sess.Exit(exitStatus(err))
+}
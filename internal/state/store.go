@@ -0,0 +1,198 @@
+// Package state is the server's embedded store for shed metadata that
+// can't live in container labels, such as setup errors, schedules, and
+// last activity.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// dbFile is the name of the database file in the state dir.
+const dbFile = "state.db"
+
+// openTimeout is how long Open waits for another process holding the
+// database to let go of it.
+const openTimeout = 5 * time.Second
+
+// Store holds values for each shed in named buckets, one bucket per kind
+// of value, keyed by shed name. Values are stored as JSON.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store in dir, creating it if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state dir: %w", err)
+	}
+
+	path := filepath.Join(dir, dbFile)
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("state store %s is in use by another process", path)
+		}
+		return nil, fmt.Errorf("failed to open state store %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get decodes the value stored for name in bucket into v, and reports
+// whether there was one.
+func (s *Store) Get(bucket, name string, v any) (bool, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			// Values are only valid during the transaction
+			if value := b.Get([]byte(name)); value != nil {
+				data = append([]byte(nil), value...)
+			}
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s for %q: %w", bucket, name, err)
+	}
+	return true, nil
+}
+
+// Put stores v for name in bucket, replacing any value it had.
+func (s *Store) Put(bucket, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s for %q: %w", bucket, name, err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(name), data)
+	})
+}
+
+// Delete removes the value stored for name in bucket, if any.
+func (s *Store) Delete(bucket, name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			return b.Delete([]byte(name))
+		}
+		return nil
+	})
+}
+
+// All returns the raw values in bucket, keyed by shed name.
+func (s *Store) All(bucket string) (map[string]json.RawMessage, error) {
+	all := make(map[string]json.RawMessage)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			all[string(k)] = append(json.RawMessage(nil), v...)
+			return nil
+		})
+	})
+	return all, err
+}
+
+// Names returns every shed with a value in any bucket but those in skip,
+// sorted.
+func (s *Store) Names(skip ...string) ([]string, error) {
+	seen := make(map[string]bool)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(bucket []byte, b *bolt.Bucket) error {
+			if slices.Contains(skip, string(bucket)) {
+				return nil
+			}
+			return b.ForEach(func(k, _ []byte) error {
+				seen[string(k)] = true
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Forget removes everything stored for name, in every bucket but those in
+// keep.
+func (s *Store) Forget(name string, keep ...string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(bucket []byte, b *bolt.Bucket) error {
+			if slices.Contains(keep, string(bucket)) {
+				return nil
+			}
+			return b.Delete([]byte(name))
+		})
+	})
+}
+
+// Import loads a JSON state file written by older servers, an object keyed
+// by shed name, into bucket. The file is renamed so it is only imported
+// once. A missing file is not an error.
+func (s *Store) Import(bucket, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		for name, value := range values {
+			// Values written since the upgrade are newer than the file
+			if b.Get([]byte(name)) != nil {
+				continue
+			}
+			if err := b.Put([]byte(name), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(path, path+".imported"); err != nil {
+		return 0, fmt.Errorf("failed to rename %s: %w", path, err)
+	}
+	return len(values), nil
+}
@@ -0,0 +1,116 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStorePutGetForget(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("owners", "api", "ci"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("labels", "api", map[string]string{"team": "payments"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("owners", "web", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var owner string
+	if ok, err := s.Get("owners", "api", &owner); err != nil || !ok || owner != "ci" {
+		t.Errorf("Get(owners, api) = %q, %v, %v", owner, ok, err)
+	}
+	if ok, err := s.Get("owners", "missing", &owner); err != nil || ok {
+		t.Errorf("Get(owners, missing) = %v, %v, want not found", ok, err)
+	}
+	if ok, err := s.Get("nobucket", "api", &owner); err != nil || ok {
+		t.Errorf("Get(nobucket, api) = %v, %v, want not found", ok, err)
+	}
+
+	names, err := s.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"api", "web"}; !slices.Equal(names, want) {
+		t.Errorf("Names() = %v, want %v", names, want)
+	}
+
+	if err := s.Forget("api"); err != nil {
+		t.Fatal(err)
+	}
+	names, _ = s.Names()
+	if want := []string{"web"}; !slices.Equal(names, want) {
+		t.Errorf("Names() after Forget = %v, want %v", names, want)
+	}
+
+	// Buckets kept by Forget are left alone, and can be skipped by Names
+	if err := s.Put("backups", "web", "20260102-030405"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Forget("web", "backups"); err != nil {
+		t.Fatal(err)
+	}
+	var backup string
+	if ok, err := s.Get("backups", "web", &backup); err != nil || !ok {
+		t.Errorf("Get(backups, web) after Forget = %v, %v, want kept", ok, err)
+	}
+	if ok, _ := s.Get("owners", "web", &owner); ok {
+		t.Error("Get(owners, web) after Forget found a value")
+	}
+	if names, _ := s.Names("backups"); len(names) != 0 {
+		t.Errorf("Names(backups) = %v, want none", names)
+	}
+}
+
+func TestStoreImport(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if n, err := s.Import("labels", filepath.Join(dir, "missing.json")); err != nil || n != 0 {
+		t.Errorf("Import(missing) = %d, %v", n, err)
+	}
+
+	if err := s.Put("labels", "web", map[string]string{"team": "new"}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "labels.json")
+	data := `{"api": {"team": "payments"}, "web": {"team": "old"}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.Import("labels", path); err != nil || n != 2 {
+		t.Fatalf("Import() = %d, %v", n, err)
+	}
+	var labels map[string]string
+	if _, err := s.Get("labels", "api", &labels); err != nil || labels["team"] != "payments" {
+		t.Errorf("Get(labels, api) = %v, %v", labels, err)
+	}
+	if _, err := s.Get("labels", "web", &labels); err != nil || labels["team"] != "new" {
+		t.Errorf("Get(labels, web) = %v, %v, want value stored since upgrade", labels, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state file still present after import: %v", err)
+	}
+}
@@ -1,6 +1,21 @@
 // Package terminal provides terminal configuration and normalization.
 package terminal
 
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultAcceptEnv lists the client environment variables passed into
+// sessions by default. LANG, LC_*, and TZ are left out so a shed's own
+// locale and timezone win unless they're added.
+var DefaultAcceptEnv = []string{"COLORTERM", "EDITOR", "VISUAL", "GIT_AUTHOR_*", "GIT_COMMITTER_*"}
+
+// reservedEnv are set by the server in every session and can't be
+// overridden by clients.
+var reservedEnv = map[string]bool{"TERM": true, "SHED_NAME": true}
+
 // Config holds terminal-related configuration settings.
 type Config struct {
 	// FallbackTerm is the default TERM value to use when the client's terminal
@@ -10,6 +25,10 @@ type Config struct {
 	// TermMappings provides explicit TERM value overrides.
 	// Key is the original TERM, value is the replacement.
 	TermMappings map[string]string `yaml:"term_mappings"`
+
+	// AcceptEnv lists the environment variables SSH clients may set in a
+	// session, by name or by pattern such as LC_*.
+	AcceptEnv []string `yaml:"accept_env"`
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -20,7 +39,50 @@ func DefaultConfig() *Config {
 			// Ghostty uses xterm-ghostty which isn't in ncurses-term
 			"xterm-ghostty": "xterm-256color",
 		},
+		AcceptEnv: append([]string(nil), DefaultAcceptEnv...),
+	}
+}
+
+// Validate checks the environment patterns.
+func (c *Config) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, pattern := range c.AcceptEnv {
+		if pattern == "" || strings.Contains(pattern, "=") {
+			return fmt.Errorf("invalid accept_env pattern %q", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid accept_env pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// AcceptedEnv returns the NAME=value pairs in env that AcceptEnv allows.
+func (c *Config) AcceptedEnv(env []string) []string {
+	if c == nil {
+		return nil
+	}
+	var accepted []string
+	for _, kv := range env {
+		name, _, ok := strings.Cut(kv, "=")
+		if ok && !reservedEnv[name] && MatchEnv(c.AcceptEnv, name) {
+			accepted = append(accepted, kv)
+		}
+	}
+	return accepted
+}
+
+// MatchEnv reports whether an environment variable name matches any of
+// patterns, where * matches any run of characters and ? any one.
+func MatchEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
 	}
+	return false
 }
 
 // NormalizeTerm applies terminal mappings and fallback logic to a TERM value.
@@ -0,0 +1,55 @@
+package terminal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAcceptedEnv(t *testing.T) {
+	cfg := DefaultConfig()
+	env := []string{
+		"EDITOR=vim",
+		"GIT_AUTHOR_NAME=Ada",
+		"LANG=en_US.UTF-8",
+		"TERM=dumb",
+		"AWS_SECRET_ACCESS_KEY=hunter2",
+		"malformed",
+	}
+
+	want := []string{"EDITOR=vim", "GIT_AUTHOR_NAME=Ada"}
+	if got := cfg.AcceptedEnv(env); !reflect.DeepEqual(got, want) {
+		t.Errorf("AcceptedEnv() = %q, want %q", got, want)
+	}
+
+	// Reserved variables stay with the server even when everything is accepted
+	cfg.AcceptEnv = []string{"*"}
+	for _, kv := range cfg.AcceptedEnv(env) {
+		if kv == "TERM=dumb" {
+			t.Error("AcceptedEnv() passed TERM through")
+		}
+	}
+
+	var none *Config
+	if got := none.AcceptedEnv(env); got != nil {
+		t.Errorf("nil config AcceptedEnv() = %q, want none", got)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		wantErr  bool
+	}{
+		{[]string{"LANG", "LC_*", "GIT_?UTHOR_NAME"}, false},
+		{nil, false},
+		{[]string{""}, true},
+		{[]string{"FOO=bar"}, true},
+		{[]string{"LC_["}, true},
+	}
+	for _, tt := range tests {
+		cfg := &Config{AcceptEnv: tt.patterns}
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) error = %v, wantErr %v", tt.patterns, err, tt.wantErr)
+		}
+	}
+}
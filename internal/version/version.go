@@ -1,6 +1,11 @@
 // Package version provides build-time version information.
 package version
 
+import (
+	"strconv"
+	"strings"
+)
+
 var (
 	// Version is the semantic version (set via ldflags)
 	Version = "dev"
@@ -21,3 +26,53 @@ func Info() string {
 func FullInfo() string {
 	return Version + " (commit: " + GitCommit + ", built: " + BuildDate + ")"
 }
+
+// APIVersion is the version of the HTTP API; routes are served under
+// /api/<APIVersion>.
+const APIVersion = "v1"
+
+// MinClientVersion is the oldest shed CLI the server works with. Raise it
+// when the API changes in a way older clients can't handle.
+var MinClientVersion = "0.0.0"
+
+// Compare compares two versions such as v1.2.3 or 1.2.3-4-gabcdef (as set
+// from git describe), returning -1, 0, or 1. ok is false if either isn't a
+// release version, such as a dev build.
+func Compare(a, b string) (result int, ok bool) {
+	pa, ok := parse(a)
+	if !ok {
+		return 0, false
+	}
+	pb, ok := parse(b)
+	if !ok {
+		return 0, false
+	}
+	for i := range pa {
+		switch {
+		case pa[i] < pb[i]:
+			return -1, true
+		case pa[i] > pb[i]:
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+// parse splits a version into its major, minor, and patch numbers.
+func parse(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "-")
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
@@ -0,0 +1,27 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"1.2.3", "1.2.3", 0, true},
+		{"v1.2.3", "1.2.4", -1, true},
+		{"v1.10.0", "v1.9.9", 1, true},
+		{"v2.0.0-3-gabc1234", "1.9.0", 1, true},
+		{"v0.1.0-dirty", "0.1.0", 0, true},
+		{"dev", "0.1.0", 0, false},
+		{"0.1.0", "abc1234", 0, false},
+		{"1.2", "1.2.0", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Compare(tt.a, tt.b)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Compare(%q, %q) = %d, %v; want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
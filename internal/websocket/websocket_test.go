@@ -0,0 +1,217 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testClient is a minimal WebSocket client for exercising Conn.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dial performs the opening handshake against an echo server.
+func dial(t *testing.T, srv *httptest.Server) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req := "GET / HTTP/1.1\r\n" +
+		"Host: " + strings.TrimPrefix(srv.URL, "http://") + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	// The example key and accept value from RFC 6455 section 1.3
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+
+	return &testClient{t: t, conn: conn, br: br}
+}
+
+// send writes a frame, masked unless unmasked is set.
+func (c *testClient) send(fin bool, opcode int, payload []byte, unmasked bool) {
+	c.t.Helper()
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	maskBit := byte(0x80)
+	if unmasked {
+		maskBit = 0
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	default:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	if unmasked {
+		frame = append(frame, payload...)
+	} else {
+		mask := []byte{1, 2, 3, 4}
+		frame = append(frame, mask...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatalf("write frame: %v", err)
+	}
+}
+
+// recv reads one unmasked server frame.
+func (c *testClient) recv() (int, []byte) {
+	c.t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		c.t.Fatalf("read frame: %v", err)
+	}
+	if header[1]&0x80 != 0 {
+		c.t.Fatal("server frame is masked")
+	}
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatalf("read payload: %v", err)
+	}
+	return int(header[0] & 0x0f), payload
+}
+
+// echoServer echoes every message until the connection closes.
+func echoServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close(CloseNormal, "")
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEcho(t *testing.T) {
+	c := dial(t, echoServer(t))
+
+	c.send(true, int(TextMessage), []byte("hello"), false)
+	if op, msg := c.recv(); op != int(TextMessage) || string(msg) != "hello" {
+		t.Errorf("got opcode %d %q, want text %q", op, msg, "hello")
+	}
+
+	large := []byte(strings.Repeat("x", 1000))
+	c.send(true, int(BinaryMessage), large, false)
+	if op, msg := c.recv(); op != int(BinaryMessage) || string(msg) != string(large) {
+		t.Errorf("got opcode %d with %d bytes, want binary with %d", op, len(msg), len(large))
+	}
+}
+
+func TestFragmentsAndPing(t *testing.T) {
+	c := dial(t, echoServer(t))
+
+	// A ping between fragments is answered without breaking the message
+	c.send(false, int(TextMessage), []byte("hel"), false)
+	c.send(true, opPing, []byte("p"), false)
+	c.send(true, opContinuation, []byte("lo"), false)
+
+	if op, msg := c.recv(); op != opPong || string(msg) != "p" {
+		t.Errorf("got opcode %d %q, want pong %q", op, msg, "p")
+	}
+	if op, msg := c.recv(); op != int(TextMessage) || string(msg) != "hello" {
+		t.Errorf("got opcode %d %q, want text %q", op, msg, "hello")
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := dial(t, echoServer(t))
+
+	c.send(true, opClose, binary.BigEndian.AppendUint16(nil, CloseNormal), false)
+	op, msg := c.recv()
+	if op != opClose || binary.BigEndian.Uint16(msg) != CloseNormal {
+		t.Errorf("got opcode %d %v, want close %d", op, msg, CloseNormal)
+	}
+}
+
+func TestUnmaskedFrameRejected(t *testing.T) {
+	c := dial(t, echoServer(t))
+
+	c.send(true, int(TextMessage), []byte("hi"), true)
+	op, msg := c.recv()
+	if op != opClose || binary.BigEndian.Uint16(msg) != CloseProtocolError {
+		t.Errorf("got opcode %d %v, want close %d", op, msg, CloseProtocolError)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	srv := echoServer(t)
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://shed.local:8080", true},
+		{"https://SHED.local:8080", true},
+		{"http://evil.example", false},
+		{"http://shed.local:9090", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://shed.local:8080/api/sheds/x/terminal", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := SameOrigin(r); got != tt.want {
+			t.Errorf("SameOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
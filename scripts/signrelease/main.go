@@ -0,0 +1,73 @@
+// Command signrelease signs a release's checksums.txt with the ed25519
+// key that shed and shed-server builds verify updates against.
+//
+// The key is read from RELEASE_SIGNING_KEY, the base64 32-byte seed.
+//
+//	signrelease keygen       # print a new seed and its public key
+//	signrelease pubkey       # print the public key, for -ldflags
+//	signrelease sign <file>  # write <file>.sig
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "signrelease:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: signrelease keygen | pubkey | sign <file>")
+	}
+
+	switch args[0] {
+	case "keygen":
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		fmt.Println("RELEASE_SIGNING_KEY=" + base64.StdEncoding.EncodeToString(key.Seed()))
+		fmt.Println("public key: " + base64.StdEncoding.EncodeToString(pub))
+		return nil
+	case "pubkey":
+		key, err := signingKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+		return nil
+	case "sign":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: signrelease sign <file>")
+		}
+		key, err := signingKey()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+		return os.WriteFile(args[1]+".sig", []byte(sig+"\n"), 0644)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// signingKey returns the key whose seed is in RELEASE_SIGNING_KEY.
+func signingKey() (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(os.Getenv("RELEASE_SIGNING_KEY"))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("RELEASE_SIGNING_KEY must be a base64 %d-byte ed25519 seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}